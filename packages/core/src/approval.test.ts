@@ -0,0 +1,38 @@
+import { describe, expect, test } from "bun:test";
+import { ApprovalGate } from "./approval";
+
+describe("ApprovalGate change limits", () => {
+  test("a limit prompt that times out denies even when the default approves", async () => {
+    const gate = new ApprovalGate({
+      tools: [],
+      timeoutMs: 20,
+      defaultAction: "approve",
+      maxLinesPerTurn: 10,
+    });
+    gate.startTurn();
+
+    const denial = await gate.check(
+      "writeFile",
+      { path: "big.txt", content: "" },
+      new Map([["big.txt", 50]]),
+    );
+    expect(denial).toContain("denied by default");
+  });
+
+  test("other prompts that time out still follow the default", async () => {
+    const gate = new ApprovalGate({
+      tools: ["writeFile"],
+      timeoutMs: 20,
+      defaultAction: "approve",
+      maxLinesPerTurn: 10,
+    });
+    gate.startTurn();
+
+    const denial = await gate.check(
+      "writeFile",
+      { path: "small.txt", content: "" },
+      new Map([["small.txt", 2]]),
+    );
+    expect(denial).toBeNull();
+  });
+});
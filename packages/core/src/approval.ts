@@ -0,0 +1,396 @@
+import { existsSync, readFileSync } from "node:fs";
+import { z } from "zod";
+import { parsePatch, patchedPaths } from "./patch";
+import { resolveToolPath } from "./paths";
+
+// Bash commands (case-insensitive regular expressions) that always need the
+// command typed back, whatever the approval policy or earlier approvals allow
+export const DEFAULT_GUARDED_COMMANDS = [
+  "\\brm\\s+(-\\S*r\\S*f|-\\S*f\\S*r|-r\\s+-f|-f\\s+-r|--recursive\\s+--force|--force\\s+--recursive)",
+  "\\bgit\\s+push\\b.*(\\s--force|\\s-f\\b|\\s\\+\\S)",
+  "\\bgit\\s+reset\\s+--hard\\b",
+  "\\bgit\\s+clean\\s+-\\S*f",
+  "\\bdrop\\s+(table|database|schema)\\b",
+  "\\btruncate\\s+table\\b",
+  "\\bkubectl\\s+delete\\b",
+  "\\bmkfs\\b",
+  "\\bdd\\b.*\\bof=/dev/",
+];
+
+// Which tool calls need the user's confirmation, and what happens when nobody answers
+export const ApprovalPolicy = z.object({
+  tools: z.array(z.string()).default([]),
+  timeoutMs: z.number().int().nonnegative().default(60000),
+  defaultAction: z.enum(["approve", "deny"]).default("deny"),
+  guardedCommands: z.array(z.string()).default([]),
+  // Lines (added plus removed) and files the file tools may change in one
+  // turn before asking to continue; 0 for no limit
+  maxLinesPerTurn: z.number().int().nonnegative().default(0),
+  maxFilesPerTurn: z.number().int().nonnegative().default(0),
+});
+export type ApprovalPolicy = z.infer<typeof ApprovalPolicy>;
+
+export const ApprovalDecision = z.object({
+  decision: z.enum(["approve", "deny", "approve_turn", "approve_pattern"]),
+  pattern: z.string().optional(),
+  confirmation: z.string().optional(),
+});
+export type ApprovalDecision = z.infer<typeof ApprovalDecision>;
+
+export interface PendingApproval {
+  id: string;
+  name: string;
+  parameters: Record<string, any>;
+  subject: string;
+  suggestedPattern: string;
+  guardedBy?: string;
+  changeLimit?: string;
+  createdAt: number;
+  expiresAt?: number;
+}
+
+interface PendingEntry {
+  approval: PendingApproval;
+  resolve: (decision: ApprovalDecision) => void;
+  timer?: ReturnType<typeof setTimeout>;
+}
+
+interface SessionPattern {
+  tool: string;
+  pattern: string;
+}
+
+// The part of a tool call that session patterns are matched against
+export function approvalSubject(
+  name: string,
+  params: Record<string, any>,
+): string {
+  if (name === "bash") {
+    return String(params.command ?? "");
+  }
+  if (name === "apply_patch") {
+    return patchedPaths(String(params.patch ?? "")).join(" ") || String(params.path ?? "");
+  }
+  return String(params.path ?? "");
+}
+
+// Default pattern: the command name for bash, the parent directory for file tools
+export function suggestPattern(name: string, subject: string): string {
+  if (name === "bash") {
+    const command = subject.trim().split(/\s+/)[0];
+    return command ? `${command} *` : "*";
+  }
+  const slash = subject.lastIndexOf("/");
+  return slash > 0 ? `${subject.slice(0, slash)}/*` : "*";
+}
+
+// The guarded command pattern a bash command matches, if any
+export function guardedBy(
+  name: string,
+  params: Record<string, any>,
+  extra: string[] = [],
+): string | undefined {
+  if (name !== "bash") {
+    return undefined;
+  }
+  const command = String(params.command ?? "");
+  return [...DEFAULT_GUARDED_COMMANDS, ...extra].find((pattern) => {
+    try {
+      return new RegExp(pattern, "i").test(command);
+    } catch {
+      return false;
+    }
+  });
+}
+
+// Lines changed between two texts, added plus removed, ignoring moves
+function lineDelta(before: string, after: string): number {
+  const counts = new Map<string, number>();
+  const beforeLines = before === "" ? [] : before.split("\n");
+  const afterLines = after === "" ? [] : after.split("\n");
+  for (const line of beforeLines) {
+    counts.set(line, (counts.get(line) ?? 0) + 1);
+  }
+  let kept = 0;
+  for (const line of afterLines) {
+    const left = counts.get(line) ?? 0;
+    if (left > 0) {
+      counts.set(line, left - 1);
+      kept++;
+    }
+  }
+  return beforeLines.length + afterLines.length - 2 * kept;
+}
+
+function currentContent(requested: string): string {
+  try {
+    const target = resolveToolPath(requested, "read");
+    return existsSync(target) ? readFileSync(target, "utf8") : "";
+  } catch {
+    return ""; // The tool reports the bad path itself
+  }
+}
+
+// Lines each file would change if the call runs; null for tools that do not edit files
+export function changeSize(
+  name: string,
+  params: Record<string, any>,
+): Map<string, number> | null {
+  const sizes = new Map<string, number>();
+  if (name === "writeFile") {
+    const path = String(params.path ?? "");
+    sizes.set(path, lineDelta(currentContent(path), String(params.content ?? "")));
+  } else if (name === "editFile") {
+    sizes.set(
+      String(params.path ?? ""),
+      lineDelta(String(params.oldContent ?? ""), String(params.newContent ?? "")),
+    );
+  } else if (name === "apply_patch") {
+    try {
+      for (const file of parsePatch(String(params.patch ?? ""), params.path)) {
+        const path = (file.newPath ?? file.oldPath) as string;
+        let lines = file.hunks.reduce(
+          (sum, hunk) => sum + hunk.ops.filter((op) => op.kind !== " ").length,
+          0,
+        );
+        // A deletion without hunks removes the whole file
+        if (file.newPath === null && file.hunks.length === 0) {
+          lines = currentContent(path).split("\n").length;
+        }
+        sizes.set(path, (sizes.get(path) ?? 0) + lines);
+      }
+    } catch {
+      return null; // The tool reports the malformed patch itself
+    }
+  } else {
+    return null;
+  }
+  return sizes;
+}
+
+// Match a subject against a pattern where * matches anything
+export function matchPattern(pattern: string, subject: string): boolean {
+  const escaped = pattern
+    .split("*")
+    .map((part) => part.replace(/[.+?^${}()|[\]\\]/g, "\\$&"))
+    .join(".*");
+  return new RegExp(`^${escaped}$`).test(subject);
+}
+
+// Shell operators that chain commands: ;, &&, ||, |, &, and newlines
+// (not the & of redirections like 2>&1 or &>)
+const COMMAND_SEPARATORS = /&&|\|\||[;|\n]|(?<![>&])&(?![>&])/;
+
+// Redirections that write nowhere: to /dev/null, or to another descriptor
+const HARMLESS_REDIRECTS = /\d*>>?\s*\/dev\/null|\d*>&\d+/g;
+
+// Match a bash command against a session pattern. Each command chained in
+// it must match on its own, so `ls *` approves `ls -la` but not
+// `ls; curl ... | sh`. Substitutions ($( ) and backticks) and output
+// redirected to a file never match: the pattern can't show what they run
+// or overwrite.
+export function matchCommandPattern(pattern: string, command: string): boolean {
+  if (command.includes("$(") || command.includes("`")) {
+    return false;
+  }
+  const segments = command
+    .split(COMMAND_SEPARATORS)
+    .map((segment) => segment.trim())
+    .filter((segment) => segment !== "");
+  return (
+    segments.length > 0 &&
+    segments.every(
+      (segment) =>
+        !segment.replace(HARMLESS_REDIRECTS, "").includes(">") &&
+        matchPattern(pattern, segment),
+    )
+  );
+}
+
+export class ApprovalGate {
+  private policy: ApprovalPolicy;
+  private pending = new Map<string, PendingEntry>();
+  private patterns: SessionPattern[] = [];
+  private approveTurn = false;
+
+  // Changes the file tools made this turn, per file, against the per-turn limits
+  private turnChanges = new Map<string, number>();
+  private changesApproved = false;
+  private changesDenied = false;
+
+  constructor(policy?: Partial<ApprovalPolicy>) {
+    this.policy = ApprovalPolicy.parse(policy || {});
+  }
+
+  // Session patterns and pending approvals survive a policy change
+  setPolicy(policy?: Partial<ApprovalPolicy>): void {
+    this.policy = ApprovalPolicy.parse(policy || {});
+  }
+
+  // "Approve all for this turn" only lasts until the next user message
+  startTurn(): void {
+    this.approveTurn = false;
+    this.turnChanges.clear();
+    this.changesApproved = false;
+    this.changesDenied = false;
+  }
+
+  private recordChanges(sizes: Map<string, number> | null): void {
+    for (const [path, lines] of sizes ?? []) {
+      this.turnChanges.set(path, (this.turnChanges.get(path) ?? 0) + lines);
+    }
+  }
+
+  // Summary of the turn's changes if this call would take them past a
+  // per-turn limit, for the user to decide whether the turn goes on
+  private changeLimitExceeded(sizes: Map<string, number> | null): string | undefined {
+    const { maxLinesPerTurn, maxFilesPerTurn } = this.policy;
+    if (!sizes || this.changesApproved || (maxLinesPerTurn === 0 && maxFilesPerTurn === 0)) {
+      return undefined;
+    }
+    const totals = new Map(this.turnChanges);
+    for (const [path, lines] of sizes) {
+      totals.set(path, (totals.get(path) ?? 0) + lines);
+    }
+    const lines = [...totals.values()].reduce((sum, n) => sum + n, 0);
+    const overLines = maxLinesPerTurn > 0 && lines > maxLinesPerTurn;
+    const overFiles = maxFilesPerTurn > 0 && totals.size > maxFilesPerTurn;
+    if (!overLines && !overFiles) {
+      return undefined;
+    }
+
+    const limits = [
+      maxLinesPerTurn > 0 ? `${maxLinesPerTurn} lines` : "",
+      maxFilesPerTurn > 0 ? `${maxFilesPerTurn} files` : "",
+    ].filter(Boolean);
+    const files = [...totals.entries()]
+      .sort((a, b) => b[1] - a[1])
+      .map(([path, n]) => `${path} (${n})`);
+    const shown = files.slice(0, 8).join(", ") + (files.length > 8 ? `, and ${files.length - 8} more` : "");
+    return `${lines} lines in ${totals.size} files this turn, over the limit of ${limits.join(" and ")}: ${shown}`;
+  }
+
+  requiresApproval(name: string): boolean {
+    return this.policy.tools.includes("*") || this.policy.tools.includes(name);
+  }
+
+  // Wait for a decision on a tool call; resolves to a denial reason, or null if it may run.
+  // planned gives the lines a tool that can't be read from its parameters would change.
+  async check(
+    name: string,
+    params: Record<string, any>,
+    planned: Map<string, number> | null = null,
+  ): Promise<string | null> {
+    // Guarded commands skip every shortcut and never default to approval
+    const guard = guardedBy(name, params, this.policy.guardedCommands);
+
+    // So do changes past the per-turn limits, until the user explicitly lets the turn go on
+    const sizes = planned ?? changeSize(name, params);
+    const changeLimit = this.changeLimitExceeded(sizes);
+    const changeDenial = `Denied: ${changeLimit}. Stop editing and summarize the remaining changes so the user can review what is done first.`;
+    if (changeLimit && this.changesDenied) {
+      return changeDenial; // Asked once this turn already
+    }
+    if (!guard && !changeLimit && (!this.requiresApproval(name) || this.approveTurn)) {
+      this.recordChanges(sizes);
+      return null;
+    }
+
+    const subject = approvalSubject(name, params);
+    if (
+      !guard &&
+      !changeLimit &&
+      this.patterns.some(
+        (p) =>
+          p.tool === name &&
+          (name === "bash"
+            ? matchCommandPattern(p.pattern, subject)
+            : matchPattern(p.pattern, subject)),
+      )
+    ) {
+      this.recordChanges(sizes);
+      return null;
+    }
+
+    const now = Date.now();
+    const { timeoutMs } = this.policy;
+    const defaultAction = guard || changeLimit ? "deny" : this.policy.defaultAction;
+    const approval: PendingApproval = {
+      id: crypto.randomUUID(),
+      name,
+      parameters: params,
+      subject,
+      suggestedPattern: suggestPattern(name, subject),
+      guardedBy: guard,
+      changeLimit,
+      createdAt: now,
+      expiresAt: timeoutMs > 0 ? now + timeoutMs : undefined,
+    };
+
+    let timedOut = false;
+    const { decision } = await new Promise<ApprovalDecision>((resolve) => {
+      const entry: PendingEntry = { approval, resolve };
+      if (timeoutMs > 0) {
+        entry.timer = setTimeout(() => {
+          timedOut = true;
+          this.pending.delete(approval.id);
+          resolve({ decision: defaultAction });
+        }, timeoutMs);
+      }
+      this.pending.set(approval.id, entry);
+    });
+
+    if (decision !== "deny") {
+      this.changesApproved ||= !!changeLimit;
+      this.recordChanges(sizes);
+      return null;
+    }
+    this.changesDenied ||= !!changeLimit;
+    if (timedOut) {
+      return `No approval within ${Math.round(timeoutMs / 1000)}s; denied by default`;
+    }
+    if (changeLimit) {
+      return changeDenial;
+    }
+    return guard
+      ? "Denied: destructive command was not confirmed by the user. Do not retry it; explain what you wanted to do instead."
+      : "Denied by user";
+  }
+
+  list(): PendingApproval[] {
+    return [...this.pending.values()].map((entry) => entry.approval);
+  }
+
+  // Answer a pending approval; returns false if it no longer exists
+  resolve(id: string, answer: ApprovalDecision): boolean {
+    const entry = this.pending.get(id);
+    if (!entry) {
+      return false;
+    }
+
+    let { decision, pattern, confirmation } = ApprovalDecision.parse(answer);
+    if (entry.timer) {
+      clearTimeout(entry.timer);
+    }
+    this.pending.delete(id);
+
+    // A guarded command runs once, and only when typed back exactly
+    if (entry.approval.guardedBy) {
+      const confirmed =
+        confirmation?.trim() === entry.approval.subject.trim();
+      decision = decision !== "deny" && confirmed ? "approve" : "deny";
+    }
+
+    if (decision === "approve_turn") {
+      this.approveTurn = true;
+    } else if (decision === "approve_pattern") {
+      this.patterns.push({
+        tool: entry.approval.name,
+        pattern: pattern || entry.approval.suggestedPattern,
+      });
+    }
+
+    entry.resolve({ decision, pattern });
+    return true;
+  }
+}
@@ -0,0 +1,84 @@
+import { z } from "zod";
+
+// What a model supports; requests are adapted to it instead of failing
+export const ModelCapabilities = z.object({
+  tools: z.boolean(), // Native tool calling (otherwise emulated in the prompt)
+  vision: z.boolean(), // Image input
+  streaming: z.boolean(),
+  jsonMode: z.boolean(), // response_format: json_object
+  toolChoice: z.boolean(), // tool_choice and parallel_tool_calls
+  contextTokens: z.number().int().positive(),
+});
+export type ModelCapabilities = z.infer<typeof ModelCapabilities>;
+
+interface KnownModel {
+  provider: string;
+  prefix: string; // Model names starting with this, lowercased
+  capabilities: Partial<ModelCapabilities>;
+}
+
+// Models whose capabilities differ from their provider's defaults. The
+// first entry whose prefix matches wins, so longer prefixes come first.
+const KNOWN_MODELS: KnownModel[] = [
+  // Groq
+  { provider: "groq", prefix: "llama-3.3-70b", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "llama-3.1-8b", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "llama-3.2-11b-vision", capabilities: { vision: true, contextTokens: 8192 } },
+  { provider: "groq", prefix: "llama-3.2-90b-vision", capabilities: { vision: true, contextTokens: 8192 } },
+  { provider: "groq", prefix: "meta-llama/llama-4", capabilities: { vision: true, contextTokens: 131072 } },
+  { provider: "groq", prefix: "llama3-", capabilities: { contextTokens: 8192 } },
+  { provider: "groq", prefix: "gemma2-9b", capabilities: { contextTokens: 8192 } },
+  { provider: "groq", prefix: "mixtral-8x7b", capabilities: { contextTokens: 32768 } },
+  { provider: "groq", prefix: "deepseek-r1-distill", capabilities: { jsonMode: false, contextTokens: 131072 } },
+  { provider: "groq", prefix: "qwen", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "openai/gpt-oss", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "moonshotai/kimi-k2", capabilities: { contextTokens: 131072 } },
+
+  // Ollama (names as installed, e.g. "llama3.1:8b")
+  { provider: "ollama", prefix: "llama3.2-vision", capabilities: { tools: false, vision: true, contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.1", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.2", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.3", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama4", capabilities: { vision: true, contextTokens: 131072 } },
+  { provider: "ollama", prefix: "qwen2.5", capabilities: { contextTokens: 32768 } },
+  { provider: "ollama", prefix: "qwen3", capabilities: { contextTokens: 40960 } },
+  { provider: "ollama", prefix: "mistral-nemo", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "mistral", capabilities: { contextTokens: 32768 } },
+  { provider: "ollama", prefix: "command-r", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "deepseek-coder-v2", capabilities: { tools: false, contextTokens: 163840 } },
+  { provider: "ollama", prefix: "deepseek-coder", capabilities: { tools: false, contextTokens: 16384 } },
+  { provider: "ollama", prefix: "codellama", capabilities: { tools: false, contextTokens: 16384 } },
+  { provider: "ollama", prefix: "codegemma", capabilities: { tools: false } },
+  { provider: "ollama", prefix: "gemma", capabilities: { tools: false } },
+  { provider: "ollama", prefix: "phi", capabilities: { tools: false, contextTokens: 4096 } },
+  { provider: "ollama", prefix: "starcoder", capabilities: { tools: false } },
+  { provider: "ollama", prefix: "llava", capabilities: { tools: false, vision: true, contextTokens: 4096 } },
+];
+
+// Capabilities of models not in the table
+const PROVIDER_DEFAULTS: Record<string, ModelCapabilities> = {
+  groq: { tools: true, vision: false, streaming: true, jsonMode: true, toolChoice: true, contextTokens: 32768 },
+  ollama: { tools: true, vision: false, streaming: true, jsonMode: true, toolChoice: false, contextTokens: 8192 },
+};
+
+export function capabilitiesFor(provider: string, model: string): ModelCapabilities {
+  const name = model.toLowerCase();
+  const known = KNOWN_MODELS.find(
+    (entry) => entry.provider === provider && name.startsWith(entry.prefix),
+  );
+  return {
+    ...(PROVIDER_DEFAULTS[provider] ?? PROVIDER_DEFAULTS.groq),
+    ...known?.capabilities,
+  };
+}
+
+// Provider errors that mean the model can't take tools
+export const TOOLS_UNSUPPORTED =
+  /(does not support|not supported|unsupported)[^.]{0,40}\btool|\btool[^.]{0,40}(not supported|unsupported)/i;
+
+// Provider errors that mean the model can't take response_format: json_object
+export const JSON_MODE_UNSUPPORTED =
+  /(response_format|json[ _]?(mode|object))[^.]{0,60}(not supported|unsupported|not available)|(does not support|not supported|unsupported)[^.]{0,40}(response_format|json)/i;
+
+// Provider errors about tool_choice or parallel_tool_calls
+export const TOOL_CHOICE_UNSUPPORTED = /tool_choice|parallel_tool_calls/i;
@@ -1,14 +1,33 @@
 import { z } from "zod";
-import type { Message } from "./messages.ts";
+import { estimateTokens, type Message } from "./messages.ts";
+import { log } from "./log.ts";
+import {
+  capabilitiesFor,
+  JSON_MODE_UNSUPPORTED,
+  type ModelCapabilities,
+  TOOL_CHOICE_UNSUPPORTED,
+  TOOLS_UNSUPPORTED,
+} from "./capabilities.ts";
 
 // Groq configuration
 export const GroqConfig = z.object({
   token: z.string(),
   model: z.string().default("llama-3.3-70b-versatile"),
   baseURL: z.string().default("https://api.groq.com/openai"),
+  temperature: z.number().min(0).max(2).default(0.7),
+  provider: z.string().default("groq"), // Picks the capability defaults
 });
 export type GroqConfig = z.infer<typeof GroqConfig>;
 
+// Provider features a conversation's requests are sent with; fields left out
+// keep the provider's defaults
+export const RequestShaping = z.object({
+  jsonMode: z.boolean().optional(), // response_format: json_object
+  toolChoice: z.enum(["auto", "required", "none"]).optional(),
+  parallelToolCalls: z.boolean().optional(),
+});
+export type RequestShaping = z.infer<typeof RequestShaping>;
+
 // Groq API response
 export const GroqResponse = z.object({
   content: z.string(),
@@ -31,11 +50,26 @@ export const GroqResponse = z.object({
 });
 export type GroqResponse = z.infer<typeof GroqResponse>;
 
+// Provider API failure, carrying the HTTP status when there is one
+export class ProviderError extends Error {
+  constructor(
+    message: string,
+    public status?: number,
+  ) {
+    super(message);
+    this.name = "ProviderError";
+  }
+}
+
 /**
  * GroqClient - Handles communication with Groq API
  */
 export class GroqClient {
   private config: GroqConfig;
+  private defaultTemperature: number;
+
+  // Capabilities the provider turned out to lack, until the model changes
+  private learned: Partial<ModelCapabilities> = {};
 
   constructor(config: GroqConfig) {
     if (!config) {
@@ -47,9 +81,171 @@ export class GroqClient {
 
     // Parse and validate config to apply default
     this.config = GroqConfig.parse(config);
+    this.defaultTemperature = this.config.temperature;
+  }
+
+  // Override the sampling temperature; undefined restores the configured one
+  setTemperature(temperature?: number): void {
+    this.config.temperature = temperature ?? this.defaultTemperature;
+  }
+
+  // Change the configured temperature; undefined restores the built-in default
+  setDefaultTemperature(temperature?: number): void {
+    this.defaultTemperature =
+      temperature ?? GroqConfig.shape.temperature.parse(undefined);
+  }
+
+  setModel(model: string): void {
+    this.config.model = model;
+    this.learned = {};
+  }
+
+  // What the current model supports, from the table and from provider errors
+  capabilities(): ModelCapabilities {
+    return {
+      ...capabilitiesFor(this.config.provider, this.config.model),
+      ...this.learned,
+    };
+  }
+
+  // Model and temperature the next request will use
+  getSettings(): { model: string; temperature: number } {
+    return { model: this.config.model, temperature: this.config.temperature };
+  }
+
+  // Complete a reply; with onText, the reply is streamed and its text passed
+  // to onText as it arrives. Models without tool calling get the tools
+  // described in the prompt instead, and shaping the model can't take is
+  // asked for in the prompt.
+  async complete(
+    messages: Message[],
+    tools?: any[],
+    onText?: (text: string) => void,
+    shaping: RequestShaping = {},
+  ): Promise<GroqResponse> {
+    const capabilities = this.capabilities();
+    const nativeChoice = capabilities.tools && capabilities.toolChoice;
+    // Without tool_choice, "none" is honored by not offering the tools
+    const offered =
+      tools?.length && !(shaping.toolChoice === "none" && !nativeChoice)
+        ? tools
+        : undefined;
+    const shaped = withInstructions(
+      messages,
+      shapingInstructions(shaping, capabilities, !!offered),
+    );
+
+    let response: GroqResponse;
+    if (offered && !capabilities.tools) {
+      response = await this.completeWithEmulatedTools(shaped, offered, onText);
+    } else {
+      try {
+        response = await this.request(shaped, offered, onText, shaping);
+      } catch (error) {
+        if (!this.learnUnsupported(error, shaping, !!offered)) {
+          throw error;
+        }
+        return this.complete(messages, tools, onText, shaping);
+      }
+    }
+
+    // Providers without parallel_tool_calls may still call several tools
+    if (shaping.parallelToolCalls === false && (response.toolCalls?.length ?? 0) > 1) {
+      response = { ...response, toolCalls: response.toolCalls!.slice(0, 1) };
+    }
+    return response;
   }
 
-  async complete(messages: Message[], tools?: any[]): Promise<GroqResponse> {
+  // Note a feature the provider rejected the request over, so the next
+  // attempt goes without it; false when the error is about something else
+  private learnUnsupported(
+    error: unknown,
+    shaping: RequestShaping,
+    withTools: boolean,
+  ): boolean {
+    if (!(error instanceof ProviderError) || error.status !== 400) {
+      return false;
+    }
+    const capabilities = this.capabilities();
+    const model = this.config.model;
+    if (
+      withTools &&
+      capabilities.toolChoice &&
+      (shaping.toolChoice !== undefined || shaping.parallelToolCalls !== undefined) &&
+      TOOL_CHOICE_UNSUPPORTED.test(error.message)
+    ) {
+      log("warn", "", "Model rejected tool_choice; asking for it in the prompt instead", { model });
+      this.learned.toolChoice = false;
+      return true;
+    }
+    if (withTools && capabilities.tools && TOOLS_UNSUPPORTED.test(error.message)) {
+      log("warn", "", "Model rejected tools; describing them in the prompt instead", { model });
+      this.learned.tools = false;
+      return true;
+    }
+    if (shaping.jsonMode && capabilities.jsonMode && JSON_MODE_UNSUPPORTED.test(error.message)) {
+      log("warn", "", "Model rejected JSON mode; asking for JSON in the prompt instead", { model });
+      this.learned.jsonMode = false;
+      return true;
+    }
+    return false;
+  }
+
+  // Tool calls for models without native support: the tools are described
+  // in the system prompt, calls come back as tool_call blocks in the reply,
+  // and earlier calls and results are replayed as plain text
+  private async completeWithEmulatedTools(
+    messages: Message[],
+    tools: any[],
+    onText?: (text: string) => void,
+  ): Promise<GroqResponse> {
+    const names = new Map<string, string>();
+    const converted = messages.map((msg, i): Message => {
+      if (msg.role === "system" && i === 0) {
+        return { ...msg, content: `${msg.content}\n\n${emulatedToolsPrompt(tools)}` };
+      }
+      if (msg.toolCalls?.length) {
+        const calls = msg.toolCalls.map((call) => {
+          names.set(call.id, call.name);
+          return toolCallBlock(call.name, call.parameters);
+        });
+        return {
+          ...msg,
+          content: [msg.content, ...calls].filter(Boolean).join("\n"),
+          toolCalls: undefined,
+        };
+      }
+      if (msg.role === "tool") {
+        const name = names.get(msg.toolResults?.[0]?.id ?? "") ?? "the tool";
+        return {
+          ...msg,
+          role: "user",
+          content: `Result of ${name}:\n${msg.content}`,
+          toolResults: undefined,
+        };
+      }
+      return msg;
+    });
+
+    // Not streamed, so tool_call blocks never reach the screen
+    const response = await this.request(converted);
+    const { content, toolCalls } = parseEmulatedToolCalls(response.content);
+    if (onText && content) {
+      onText(content);
+    }
+    return { ...response, content, toolCalls };
+  }
+
+  private async request(
+    messages: Message[],
+    tools?: any[],
+    onText?: (text: string) => void,
+    shaping: RequestShaping = {},
+  ): Promise<GroqResponse> {
+    checkOffline(this.config.baseURL);
+    const capabilities = this.capabilities();
+    // Models that can't stream answer in one piece, which onText gets whole
+    const stream = !!onText && capabilities.streaming;
     const payload: any = {
       model: this.config.model,
       messages: messages.map((msg) => {
@@ -77,15 +273,34 @@ export class GroqClient {
 
         return groqMsg;
       }),
-      stream: false,
-      temperature: 0.7,
-      max_tokens: 4096,
+      stream,
+      temperature: this.config.temperature,
+      max_tokens: MAX_REPLY_TOKENS,
     };
 
     if (tools && tools.length > 0) {
       payload.tools = tools;
       payload.tool_choice = "auto";
+      if (capabilities.toolChoice) {
+        payload.tool_choice = shaping.toolChoice ?? "auto";
+        if (shaping.parallelToolCalls !== undefined) {
+          payload.parallel_tool_calls = shaping.parallelToolCalls;
+        }
+      }
+    }
+    if (shaping.jsonMode && capabilities.jsonMode) {
+      payload.response_format = { type: "json_object" };
     }
+    if (stream) {
+      payload.stream_options = { include_usage: true };
+    }
+
+    // Text already shown can't be taken back, so a failure after it is not retried
+    let streamed = false;
+    const emit = (text: string) => {
+      streamed = true;
+      onText?.(text);
+    };
 
     //Retry logic for rate limits and temporary errors
     const maxRetries = 3;
@@ -108,8 +323,9 @@ export class GroqClient {
 
         if (!response.ok) {
           const errorText = await response.text();
-          const error = new Error(
+          const error = new ProviderError(
             `Groq API error: ${response.status} ${response.statusText} - ${errorText}`,
+            response.status,
           );
 
           // Check if it's a retryable error
@@ -117,8 +333,11 @@ export class GroqClient {
             lastError = error;
             if (attempt < maxRetries) {
               const delay = Math.pow(2, attempt) * 1000; // Exponential backoff
-              console.warn(
+              log(
+                "warn",
+                "",
                 `Attempt ${attempt} failed, retrying in ${delay}ms...`,
+                { status: response.status, attempt, delayMs: delay },
               );
               await new Promise((resolve) => setTimeout(resolve, delay));
               continue;
@@ -128,9 +347,19 @@ export class GroqClient {
           throw error;
         }
 
+        if (
+          stream &&
+          response.headers.get("content-type")?.includes("text/event-stream")
+        ) {
+          return await this.readCompletionStream(response, payload, emit);
+        }
+
         const data = await response.json();
 
         const choice = data.choices[0];
+        if (onText && choice?.message?.content) {
+          emit(choice.message.content); // The provider ignored stream
+        }
         return {
           content: choice?.message?.content || "",
           tokens: {
@@ -141,32 +370,144 @@ export class GroqClient {
         };
       } catch (error) {
         lastError = error instanceof Error ? error : new Error(String(error));
+        // Requests the provider rejected fail the same way when repeated
+        const rejected =
+          lastError instanceof ProviderError &&
+          lastError.status !== undefined &&
+          lastError.status < 500 &&
+          lastError.status !== 429;
+        if (streamed || rejected) {
+          throw lastError;
+        }
         if (attempt < maxRetries) {
           const delay = Math.pow(2, attempt) * 1000; // Exponential backoff
-          console.warn(`Attempt ${attempt} failed, retrying in ${delay}ms...`);
+          log("warn", "", `Attempt ${attempt} failed, retrying in ${delay}ms...`, {
+            attempt,
+            delayMs: delay,
+            error: lastError.message,
+          });
           await new Promise((resolve) => setTimeout(resolve, delay));
           continue;
         }
         break;
       }
     }
-    throw new Error(
+    throw new ProviderError(
       `Failed to complete with Groq after ${maxRetries} attempts: ${lastError?.message || "Unknown error"}`,
+      lastError instanceof ProviderError ? lastError.status : undefined,
     );
   }
 
+  // Read a streamed completion into the shape complete() returns: text
+  // deltas go to emit, tool call fragments are joined by index
+  private async readCompletionStream(
+    response: Response,
+    payload: any,
+    emit: (text: string) => void,
+  ): Promise<GroqResponse> {
+    const reader = response.body?.getReader();
+    if (!reader) {
+      throw new Error("Response body is not readable");
+    }
+
+    const decoder = new TextDecoder();
+    let buffer = "";
+    let content = "";
+    let finished = false;
+    let usage: any = null;
+    const toolCalls: NonNullable<GroqResponse["toolCalls"]> = [];
+
+    try {
+      // Usage comes in a chunk after the finish reason, so read to the end
+      read: while (true) {
+        const { done, value } = await reader.read();
+        if (done) break;
+
+        buffer += decoder.decode(value, { stream: true });
+        const lines = buffer.split("\n");
+        buffer = lines.pop() || "";
+
+        for (const line of lines) {
+          if (!line.startsWith("data:")) continue;
+          const data = line.slice(5).trim();
+          if (data === "[DONE]") {
+            finished = true;
+            break read;
+          }
+
+          let parsed: any;
+          try {
+            parsed = JSON.parse(data);
+          } catch {
+            continue; // Skip invalid JSON lines
+          }
+          usage = parsed.usage || parsed.x_groq?.usage || usage;
+          const choice = parsed.choices?.[0];
+          if (choice?.finish_reason) finished = true;
+          const delta = choice?.delta;
+          if (delta?.content) {
+            content += delta.content;
+            emit(delta.content);
+          }
+          for (const part of delta?.tool_calls || []) {
+            const call = (toolCalls[part.index ?? toolCalls.length] ??= {
+              id: "",
+              type: "function",
+              function: { name: "", arguments: "" },
+            });
+            call.id = part.id || call.id;
+            call.function.name += part.function?.name || "";
+            call.function.arguments += part.function?.arguments || "";
+          }
+        }
+      }
+    } catch (error) {
+      throw new ProviderError(
+        `Stream interrupted: ${error instanceof Error ? error.message : String(error)}`,
+      );
+    } finally {
+      reader.releaseLock();
+    }
+    if (!finished) {
+      throw new ProviderError("Stream ended before the reply was complete");
+    }
+
+    const calls = toolCalls.filter(Boolean);
+    return {
+      content,
+      tokens: {
+        // Providers that report no usage when streaming are estimated
+        input:
+          usage?.prompt_tokens ?? estimateTokens(JSON.stringify(payload.messages)),
+        output:
+          usage?.completion_tokens ??
+          estimateTokens(
+            content + calls.map((call) => call.function.arguments).join(""),
+          ),
+      },
+      toolCalls: calls,
+    };
+  }
+
   async stream(
     messages: Message[],
   ): Promise<AsyncGenerator<string, void, unknown>> {
+    const response = await this.openStream(
+      messages.map((msg) => ({ role: msg.role, content: msg.content })),
+    );
+    return this.resumableStream(messages, response);
+  }
+
+  private async openStream(
+    messages: { role: string; content: string }[],
+  ): Promise<Response> {
+    checkOffline(this.config.baseURL);
     const payload = {
       model: this.config.model,
-      messages: messages.map((msg) => ({
-        role: msg.role,
-        content: msg.content,
-      })),
+      messages,
       stream: true,
-      temperature: 0.7,
-      max_tokens: 4096,
+      temperature: this.config.temperature,
+      max_tokens: MAX_REPLY_TOKENS,
     };
 
     const response = await fetch(`${this.config.baseURL}/v1/chat/completions`, {
@@ -181,17 +522,81 @@ export class GroqClient {
 
     if (!response.ok) {
       const errorText = await response.text();
-      throw new Error(
+      throw new ProviderError(
         `Groq API error: ${response.status} ${response.statusText} - ${errorText}`,
+        response.status,
       );
     }
+    return response;
+  }
+
+  // Yield the reply, reconnecting when the connection drops mid-stream. The
+  // provider is asked to continue from the partial text, and any text the
+  // continuation repeats is dropped so the chunks read as one reply.
+  private async *resumableStream(
+    messages: Message[],
+    response: Response,
+  ): AsyncGenerator<string, void, unknown> {
+    let partial = "";
+
+    for (let attempt = 1; ; attempt++) {
+      // A continuation is held back until it is longer than the overlap it may repeat
+      let pending = partial === "" ? null : "";
+      const parser = this.parseStream(response);
+      let finished = false;
+
+      while (true) {
+        const next = await parser.next();
+        if (next.done) {
+          finished = next.value;
+          break;
+        }
+        let chunk = next.value;
+        if (pending !== null) {
+          pending += chunk;
+          if (pending.length <= MAX_RESUME_OVERLAP) {
+            continue;
+          }
+          chunk = stripOverlap(partial, pending);
+          pending = null;
+        }
+        partial += chunk;
+        yield chunk;
+      }
+
+      if (pending) {
+        const rest = stripOverlap(partial, pending);
+        partial += rest;
+        yield rest;
+      }
+      if (finished) {
+        return;
+      }
+      if (attempt > MAX_STREAM_RESUMES) {
+        throw new ProviderError(
+          `Stream interrupted ${attempt} times; keeping the partial reply`,
+        );
+      }
 
-    return this.parseStream(response);
+      const delay = Math.pow(2, attempt) * 1000;
+      log("warn", "", `Stream interrupted, resuming in ${delay}ms...`, {
+        attempt,
+        delayMs: delay,
+      });
+      await new Promise((resolve) => setTimeout(resolve, delay));
+      response = await this.openStream(
+        partial === ""
+          ? messages.map((msg) => ({ role: msg.role, content: msg.content }))
+          : continuationMessages(messages, partial),
+      );
+    }
   }
 
+  // Yield content chunks; returns whether the provider finished the reply
+  // (false when the connection ended or failed before [DONE])
   private async *parseStream(
     response: Response,
-  ): AsyncGenerator<string, void, unknown> {
+  ): AsyncGenerator<string, boolean, unknown> {
     const reader = response.body?.getReader();
     if (!reader) {
       throw new Error("Response body is not readable");
@@ -199,6 +604,7 @@ export class GroqClient {
 
     const decoder = new TextDecoder();
     let buffer = "";
+    let finishReason: string | null = null;
 
     try {
       while (true) {
@@ -212,11 +618,12 @@ export class GroqClient {
         for (const line of lines) {
           if (line.startsWith("data")) {
             const data = line.slice(6);
-            if (data === "[DONE]") return;
+            if (data === "[DONE]") return true;
 
             try {
               const parsed = JSON.parse(data);
               const content = parsed.choices[0]?.delta?.content || "";
+              finishReason = parsed.choices[0]?.finish_reason || finishReason;
               if (content) {
                 yield content;
               }
@@ -226,8 +633,152 @@ export class GroqClient {
           }
         }
       }
+    } catch (e) {
+      // Connection dropped mid-stream
+      return false;
     } finally {
       reader.releaseLock();
     }
+    // Some providers close the stream without [DONE] after the last chunk
+    return finishReason !== null;
+  }
+}
+
+// Reconnects after a dropped stream before giving up
+const MAX_STREAM_RESUMES = 3;
+
+// In offline mode (painika --offline) only this machine and the hosts the
+// client allowed, passed as PAINIKA_OFFLINE_ALLOW, may be reached
+export function checkOffline(url: string): void {
+  const allow = process.env.PAINIKA_OFFLINE_ALLOW;
+  if (allow === undefined) return;
+  const { host, hostname } = new URL(url);
+  const name = hostname.replace(/^\[|\]$/g, "");
+  const local = name === "localhost" || /^127\./.test(name) || name === "::1";
+  const allowed = allow
+    .split(",")
+    .some((entry) => entry !== "" && (entry === host || entry === hostname));
+  if (!local && !allowed) {
+    throw new Error(`Offline mode: refusing to connect to ${host}`);
+  }
+}
+
+// Tokens a reply may use
+export const MAX_REPLY_TOKENS = 4096;
+
+// What a request asks for in the prompt: JSON always (JSON mode also needs
+// the word in the messages), and tool_choice and parallel_tool_calls when
+// the model can't take them
+function shapingInstructions(
+  shaping: RequestShaping,
+  capabilities: ModelCapabilities,
+  withTools: boolean,
+): string[] {
+  const instructions: string[] = [];
+  if (shaping.jsonMode) {
+    instructions.push("Write your final answer as a single JSON object, with no text before or after it");
+  }
+  if (withTools && !(capabilities.tools && capabilities.toolChoice)) {
+    if (shaping.toolChoice === "required") {
+      instructions.push("Call at least one tool before answering");
+    }
+    if (shaping.parallelToolCalls === false) {
+      instructions.push("Call one tool at a time");
+    }
+  }
+  return instructions;
+}
+
+// Add instructions to the end of the system prompt
+function withInstructions(messages: Message[], instructions: string[]): Message[] {
+  const system = messages.findIndex((msg) => msg.role === "system");
+  if (instructions.length === 0 || system < 0) {
+    return messages;
+  }
+  const section = `# Reply format\n${instructions.map((line) => `- ${line}`).join("\n")}`;
+  return messages.map((msg, i) =>
+    i === system ? { ...msg, content: `${msg.content}\n\n${section}` } : msg,
+  );
+}
+
+// Fenced block an emulated tool call is written in
+function toolCallBlock(name: string, args: Record<string, any>): string {
+  return "```tool_call\n" + JSON.stringify({ name, arguments: args }) + "\n```";
+}
+
+// System prompt section describing the tools to a model without tool calling
+function emulatedToolsPrompt(tools: any[]): string {
+  const list = tools
+    .map(
+      ({ function: fn }) =>
+        `- ${fn.name}: ${fn.description}. Arguments: ${JSON.stringify(fn.parameters?.properties ?? {})}`,
+    )
+    .join("\n");
+  return `# Tools
+To use a tool, reply with a fenced block tagged tool_call holding a JSON object with the tool's name and arguments, for example:
+${toolCallBlock("readFile", { path: "main.go" })}
+Write one block per call and stop after the blocks; the results come back in the next message. Reply without tool_call blocks when you are done.
+To write a whole file without a tool call, reply with a fenced block whose info string names it, such as \`\`\`go path=cmd/main.go; the user reviews the change before it is written.
+
+${list}`;
+}
+
+// Take tool_call blocks out of a reply as tool calls; blocks that are not
+// valid JSON stay in the text
+export function parseEmulatedToolCalls(reply: string): {
+  content: string;
+  toolCalls: NonNullable<GroqResponse["toolCalls"]>;
+} {
+  const toolCalls: NonNullable<GroqResponse["toolCalls"]> = [];
+  const content = reply.replace(/```tool_call[^\n]*\n([\s\S]*?)```/g, (block, body) => {
+    try {
+      const call = JSON.parse(body);
+      if (typeof call?.name !== "string") {
+        return block;
+      }
+      toolCalls.push({
+        id: `call_${crypto.randomUUID().slice(0, 8)}`,
+        type: "function",
+        function: { name: call.name, arguments: JSON.stringify(call.arguments ?? {}) },
+      });
+      return "";
+    } catch {
+      return block;
+    }
+  });
+  return { content: content.trim(), toolCalls };
+}
+
+// Characters of the partial reply a continuation may repeat; shorter
+// overlaps are as likely to be a coincidence as a repeat
+const MAX_RESUME_OVERLAP = 200;
+const MIN_RESUME_OVERLAP = 8;
+
+// Ask for the rest of a reply that was cut off after partial
+function continuationMessages(
+  messages: Message[],
+  partial: string,
+): { role: string; content: string }[] {
+  const tail = partial.slice(-MAX_RESUME_OVERLAP);
+  return [
+    ...messages.map((msg) => ({ role: msg.role, content: msg.content })),
+    { role: "assistant", content: partial },
+    {
+      role: "user",
+      content:
+        "Your reply was cut off. Continue from exactly where it stopped, without repeating anything or adding commentary. Continue from:\n" +
+        tail,
+    },
+  ];
+}
+
+// Drop the start of a continuation that repeats the end of the partial reply
+export function stripOverlap(partial: string, continuation: string): string {
+  const max = Math.min(partial.length, continuation.length, MAX_RESUME_OVERLAP);
+  for (let length = max; length >= MIN_RESUME_OVERLAP; length--) {
+    if (partial.endsWith(continuation.slice(0, length))) {
+      return continuation.slice(length);
+    }
   }
+  return continuation;
 }
@@ -1,29 +1,130 @@
 import { serve } from "bun";
+import { randomBytes, timingSafeEqual } from "node:crypto";
+import { existsSync, renameSync, unlinkSync, writeFileSync } from "node:fs";
+import { createServer } from "node:net";
 import { Hono } from "hono";
 import { Session, type SessionConfig } from "./session";
+import { ProviderError } from "./groq";
+import { log } from "./log";
+import { TurnLock, type TurnClient } from "./turns";
 
 const app = new Hono();
 
+// Bump when endpoints or payloads change so older clients refuse to talk to us
+const PROTOCOL_VERSION = 2;
+
+// Token every request but /health must carry. The client that starts the
+// server passes it in SERVER_TOKEN; it is removed from the environment so
+// commands run by the tools cannot read it.
+const TOKEN_HEADER = "X-Painika-Token";
+
+// Headers naming the client, for sessions several clients share
+const CLIENT_HEADER = "X-Painika-Client";
+const CLIENT_ID_HEADER = "X-Painika-Client-Id";
+let serverToken = process.env.SERVER_TOKEN || "";
+delete process.env.SERVER_TOKEN;
+if (!serverToken) {
+	serverToken = randomBytes(32).toString("hex");
+	log("info", "🔑", `No SERVER_TOKEN set; clients must send this token: ${serverToken}`);
+}
+
+// Hosts and browser origins allowed to reach the server. Checking Host stops
+// DNS rebinding (a page on another domain that resolves to 127.0.0.1).
+const allowedHosts = [
+	"localhost",
+	"127.0.0.1",
+	"[::1]",
+	...splitList(process.env.SERVER_ALLOWED_HOSTS),
+];
+const allowedOrigins = splitList(process.env.SERVER_ALLOWED_ORIGINS);
+
+function splitList(value?: string): string[] {
+	return (value || "")
+		.split(",")
+		.map((item) => item.trim().toLowerCase())
+		.filter((item) => item !== "");
+}
+
+function tokenMatches(token?: string): boolean {
+	if (!token) {
+		return false;
+	}
+	const encoder = new TextEncoder();
+	const given = encoder.encode(token);
+	const expected = encoder.encode(serverToken);
+	return given.length === expected.length && timingSafeEqual(given, expected);
+}
+
+app.use("*", async (c, next) => {
+	// Requests over a Unix domain socket carry a placeholder host
+	if (!process.env.SERVER_SOCKET) {
+		const host = (c.req.header("Host") || "").toLowerCase().replace(/:\d+$/, "");
+		if (!allowedHosts.includes(host)) {
+			return c.json({ success: false, error: "Host not allowed" }, 403);
+		}
+	}
+
+	const origin = c.req.header("Origin");
+	if (origin && !allowedOrigins.includes(origin.toLowerCase())) {
+		return c.json({ success: false, error: "Origin not allowed" }, 403);
+	}
+
+	if (c.req.path !== "/health" && !tokenMatches(c.req.header(TOKEN_HEADER))) {
+		return c.json({ success: false, error: "Missing or invalid server token" }, 401);
+	}
+	await next();
+});
+
+// Uncaught handler errors, logged as one line so JSON logs stay parseable
+app.onError((err, c) => {
+	log("error", "❌", `${c.req.method} ${c.req.path} failed: ${err.message}`, {
+		method: c.req.method,
+		path: c.req.path,
+		stack: err.stack,
+	});
+	return c.json({ success: false, error: err.message }, 500);
+});
+
 // Global session
 let currentSession: Session | null = null;
 
+// Turns from every client attached to the session, one at a time
+const turns = new TurnLock();
+
+function turnClient(c: { req: { header(name: string): string | undefined } }): TurnClient {
+	return {
+		name: (c.req.header(CLIENT_HEADER) || "").trim().slice(0, 64),
+		id: (c.req.header(CLIENT_ID_HEADER) || "").trim().slice(0, 64),
+	};
+}
+
 // Health check endpoin
 app.get("/health", (c) => {
 	return c.json({
 		status: "ok",
+		protocolVersion: PROTOCOL_VERSION,
 		timestamp: Date.now(),
 		hasSession: !!currentSession,
+		authorized: tokenMatches(c.req.header(TOKEN_HEADER)),
 	});
 });
 
 // Initialize session
 app.post("/session", async (c) => {
 	try {
-		const config = (await c.req.json()) as SessionConfig;
-		currentSession = new Session(config);
+		const { attach, ...config } = (await c.req.json()) as SessionConfig & {
+			attach?: boolean;
+		};
+		// Joining keeps the open session and its settings for everyone
+		const attached = !!attach && !!currentSession;
+		if (!attached) {
+			currentSession = new Session(config);
+		}
 		return c.json({
 			success: true,
-			sessionId: currentSession.getConversation().id,
+			sessionId: currentSession!.getConversation().id,
+			capabilities: currentSession!.getCapabilities(),
+			attached,
 		});
 	} catch (error) {
 		return c.json(
@@ -33,16 +134,192 @@ app.post("/session", async (c) => {
 	}
 });
 
-// Send message
+// Run a turn as server-sent events: {"chunk"} for reply text as it is
+// generated, then one event with what /message would return
+function streamTurn(
+	session: Session,
+	content: string,
+	client: TurnClient,
+	contentTokens?: number,
+) {
+	const encoder = new TextEncoder();
+	const body = new ReadableStream({
+		async start(controller) {
+			const send = (event: object) =>
+				controller.enqueue(encoder.encode(`data: ${JSON.stringify(event)}\n\n`));
+			try {
+				const messages = await turns.run(session, client, content, () =>
+					session.sendMessage(
+						content,
+						(chunk) => send({ chunk }),
+						client.name,
+						contentTokens,
+					),
+				);
+				send({ success: true, messages });
+			} catch (error) {
+				send({
+					success: false,
+					error: error instanceof Error ? error.message : "Unknown error",
+					providerStatus:
+						error instanceof ProviderError ? error.status : undefined,
+				});
+			}
+			controller.close();
+		},
+	});
+
+	return new Response(body, {
+		headers: {
+			"Content-Type": "text/event-stream",
+			"Cache-Control": "no-cache",
+			Connection: "keep-alive",
+		},
+	});
+}
+
+// Send message; with "stream": true, the reply streams as server-sent events
 app.post("/message", async (c) => {
 	if (!currentSession) {
 		return c.json({ success: false, error: "No active session" }, 400);
 	}
 
+	try {
+		// The client's tokenizer counts trim the history in place of the estimate
+		const { content, stream, contentTokens: counted, contextTokens } = await c.req.json();
+		const contentTokens =
+			Number.isInteger(counted) && counted >= 0 ? counted : undefined;
+		const client = turnClient(c);
+		const session = currentSession;
+		if (contextTokens && typeof contextTokens === "object") {
+			session.setContextTokens(contextTokens);
+		}
+		if (stream) {
+			return streamTurn(session, content, client, contentTokens);
+		}
+
+		// Return every message produced by this turn, ending with the reply
+		const messages = await turns.run(session, client, content, () =>
+			session.sendMessage(content, undefined, client.name, contentTokens),
+		);
+		return c.json({ success: true, messages });
+	} catch (error) {
+		return c.json(
+			{
+				success: false,
+				error: error instanceof Error ? error.message : "Unknown error",
+				providerStatus:
+					error instanceof ProviderError ? error.status : undefined,
+			},
+			500,
+		);
+	}
+});
+
+// Update runtime settings
+app.post("/settings", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	try {
+		const {
+			historyWindow,
+			model,
+			temperature,
+			approval,
+			customTools,
+			toolLimits,
+			persona,
+			gitContext,
+			incognito,
+			toolEnv,
+			resultPreviewTokens,
+			requestShaping,
+		} = await c.req.json();
+		if (historyWindow !== undefined) {
+			currentSession.setHistoryWindow(historyWindow);
+		}
+		if (typeof model === "string" && model !== "") {
+			currentSession.setModel(model);
+		}
+		if (temperature !== undefined) {
+			currentSession.setTemperature(temperature);
+		}
+		if (approval !== undefined) {
+			currentSession.setApprovalPolicy(approval);
+		}
+		if (customTools !== undefined) {
+			currentSession.setCustomTools(customTools);
+		}
+		if (toolLimits !== undefined) {
+			currentSession.setToolLimits(toolLimits);
+		}
+		if (persona !== undefined) {
+			currentSession.setPersona(persona);
+		}
+		if (typeof gitContext === "string") {
+			currentSession.setGitContext(gitContext);
+		}
+		if (typeof incognito === "boolean") {
+			currentSession.setIncognito(incognito);
+		}
+		if (toolEnv !== undefined) {
+			currentSession.setToolEnv(toolEnv);
+		}
+		if (resultPreviewTokens !== undefined) {
+			currentSession.setResultPreview(resultPreviewTokens);
+		}
+		if (requestShaping !== undefined) {
+			currentSession.setRequestShaping(requestShaping);
+		}
+		return c.json({ success: true });
+	} catch (error) {
+		return c.json(
+			{
+				success: false,
+				error: error instanceof Error ? error.message : "Invalid settings",
+			},
+			400,
+		);
+	}
+});
+
+// One-off completion that is not added to the conversation
+app.post("/complete", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	try {
+		const { instructions, content, groq } = await c.req.json();
+		const result = await currentSession.completeOnce(
+			instructions,
+			content,
+			groq,
+		);
+		return c.json({ success: true, content: result });
+	} catch (error) {
+		return c.json(
+			{
+				success: false,
+				error: error instanceof Error ? error.message : "Unknown error",
+			},
+			500,
+		);
+	}
+});
+
+// Plan a message without executing tools (dry run)
+app.post("/plan", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
 	try {
 		const { content } = await c.req.json();
-		const message = await currentSession.sendMessage(content);
-		return c.json({ success: true, messages: [message] });
+		const plan = await currentSession.planMessage(content);
+		return c.json({ success: true, plan });
 	} catch (error) {
 		return c.json(
 			{
@@ -54,6 +331,93 @@ app.post("/message", async (c) => {
 	}
 });
 
+// List tool calls waiting for approval
+app.get("/approvals", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	const approvals = currentSession.getPendingApprovals();
+	return c.json({ success: true, approvals });
+});
+
+// Approve or deny a pending tool call
+app.post("/approvals/:id", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	try {
+		const decision = await c.req.json();
+		if (!currentSession.resolveApproval(c.req.param("id"), decision)) {
+			return c.json(
+				{ success: false, error: "Approval not found or already resolved" },
+				404,
+			);
+		}
+		return c.json({ success: true });
+	} catch (error) {
+		return c.json(
+			{
+				success: false,
+				error: error instanceof Error ? error.message : "Invalid decision",
+			},
+			400,
+		);
+	}
+});
+
+// List questions the model is waiting on
+app.get("/questions", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	const questions = currentSession.getPendingQuestions();
+	return c.json({ success: true, questions });
+});
+
+// Answer a pending question with an option index or free text
+app.post("/questions/:id", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	try {
+		const answer = await c.req.json();
+		if (!currentSession.answerQuestion(c.req.param("id"), answer)) {
+			return c.json(
+				{ success: false, error: "Question not found or already answered" },
+				404,
+			);
+		}
+		return c.json({ success: true });
+	} catch (error) {
+		return c.json(
+			{
+				success: false,
+				error: error instanceof Error ? error.message : "Invalid answer",
+			},
+			400,
+		);
+	}
+});
+
+// Drop a message's content from the conversation history
+app.post("/messages/:id/drop", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	if (!currentSession.dropMessage(c.req.param("id"))) {
+		return c.json(
+			{ success: false, error: "Message not found or not droppable" },
+			404,
+		);
+	}
+	return c.json({ success: true });
+});
+
 // Stream message
 app.get("/stream", async (c) => {
 	if (!currentSession) {
@@ -120,7 +484,11 @@ app.post("/tool", async (c) => {
 
 	try {
 		const { name, params } = await c.req.json();
-		const execution = await currentSession.executeTool(name, params);
+		const session = currentSession;
+		let execution: unknown;
+		await turns.run(session, turnClient(c), `(ran ${name})`, async () => {
+			execution = await session.executeTool(name, params);
+		});
 		return c.json({ success: true, execution });
 	} catch (error) {
 		return c.json(
@@ -133,6 +501,34 @@ app.post("/tool", async (c) => {
 	}
 });
 
+// Turns of every client attached to the session, as server-sent events
+app.get("/events", () => {
+	const encoder = new TextEncoder();
+	let unsubscribe = () => {};
+	let keepAlive: ReturnType<typeof setInterval> | undefined;
+	const body = new ReadableStream({
+		start(controller) {
+			unsubscribe = turns.subscribe((event) =>
+				controller.enqueue(encoder.encode(`data: ${JSON.stringify(event)}\n\n`)),
+			);
+			// Comments keep the connection from timing out between turns
+			keepAlive = setInterval(() => controller.enqueue(encoder.encode(": keep-alive\n\n")), 5000);
+		},
+		cancel() {
+			unsubscribe();
+			clearInterval(keepAlive);
+		},
+	});
+
+	return new Response(body, {
+		headers: {
+			"Content-Type": "text/event-stream",
+			"Cache-Control": "no-cache",
+			Connection: "keep-alive",
+		},
+	});
+});
+
 // Get conversation
 app.get("/conversation", async (c) => {
 	if (!currentSession) {
@@ -143,6 +539,18 @@ app.get("/conversation", async (c) => {
 	return c.json({ success: true, conversation });
 });
 
+// What the current model supports
+app.get("/capabilities", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	return c.json({
+		success: true,
+		capabilities: currentSession.getCapabilities(),
+	});
+});
+
 // Get available tools
 app.get("/tools", async (c) => {
 	if (!currentSession) {
@@ -153,6 +561,16 @@ app.get("/tools", async (c) => {
 	return c.json({ success: true, tools });
 });
 
+// Preview the request the next message would send
+app.get("/preview", async (c) => {
+	if (!currentSession) {
+		return c.json({ success: false, error: "No active session" }, 400);
+	}
+
+	const preview = currentSession.previewRequest();
+	return c.json({ success: true, preview });
+});
+
 // Get token usage
 app.get("/tokens", async (c) => {
 	if (!currentSession) {
@@ -176,13 +594,12 @@ app.delete("/session", async (c) => {
 // Find an available port starting from 3000
 async function findAvailablePort(startPort: number = 3000): Promise<number> {
 	return new Promise((resolve, reject) => {
-		const net = require('net');
 		let port = startPort;
 		
 		function tryPort(portToTry: number) {
-			const server = net.createServer();
+			const server = createServer();
 			
-			server.listen(portToTry, () => {
+			server.listen(portToTry, "127.0.0.1", () => {
 				server.once('close', () => {
 					resolve(portToTry);
 				});
@@ -202,14 +619,51 @@ async function findAvailablePort(startPort: number = 3000): Promise<number> {
 	});
 }
 
-const specifiedPort = process.env.PORT ? parseInt(process.env.PORT) : null;
-const port = specifiedPort || await findAvailablePort(3000);
+// Tell whoever started the server where it listens: JSON in SERVER_READY_FILE,
+// renamed into place so it is never read half-written
+function announceReady(where: { port: number } | { socket: string }) {
+	const readyFile = process.env.SERVER_READY_FILE;
+	if (!readyFile) {
+		return;
+	}
+	const ready = { ...where, pid: process.pid, protocolVersion: PROTOCOL_VERSION };
+	try {
+		writeFileSync(`${readyFile}.tmp`, JSON.stringify(ready));
+		renameSync(`${readyFile}.tmp`, readyFile);
+	} catch (error) {
+		log("warn", "⚠️", `Could not write ${readyFile}: ${error instanceof Error ? error.message : String(error)}`);
+	}
+}
+
+const socketPath = process.env.SERVER_SOCKET;
 
-console.log(`🚀 Code Agent server starting on port ${port}`);
+if (socketPath) {
+	// Listen on a Unix domain socket instead of occupying a TCP port
+	if (existsSync(socketPath)) {
+		unlinkSync(socketPath);
+	}
 
-serve({
-	fetch: app.fetch,
-	port,
-});
+	log("info", "🚀", `Code Agent server listening on ${socketPath}`, { socket: socketPath });
+
+	serve({
+		fetch: app.fetch,
+		unix: socketPath,
+	});
+	announceReady({ socket: socketPath });
+} else {
+	const specifiedPort = process.env.PORT ? parseInt(process.env.PORT) : null;
+	const port = specifiedPort || await findAvailablePort(3000);
+
+	log("info", "🚀", `Code Agent server starting on port ${port}`, { port });
+
+	// Loopback only; other machines go through a proxy (see SERVER_ALLOWED_HOSTS).
+	// SERVER_HOST is for containers, where the published port is the boundary.
+	serve({
+		fetch: app.fetch,
+		hostname: process.env.SERVER_HOST || "127.0.0.1",
+		port,
+	});
+	announceReady({ port });
+}
 
 export { app };
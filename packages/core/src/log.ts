@@ -0,0 +1,29 @@
+// Server log lines: emoji-led text for a terminal, or one JSON object per
+// line (LOG_FORMAT=json) for journald, launchd, and log collectors
+export type LogLevel = "debug" | "info" | "warn" | "error";
+
+const jsonLogs = process.env.LOG_FORMAT === "json";
+
+// Write a log line; warnings and errors go to stderr. Fields are only
+// written in JSON, where they can be queried.
+export function log(
+  level: LogLevel,
+  icon: string,
+  message: string,
+  fields: Record<string, unknown> = {},
+) {
+  const write = level === "warn" || level === "error" ? console.error : console.log;
+  if (jsonLogs) {
+    write(
+      JSON.stringify({
+        time: new Date().toISOString(),
+        level,
+        msg: message,
+        source: "server",
+        ...fields,
+      }),
+    );
+    return;
+  }
+  write(icon ? `${icon} ${message}` : message);
+}
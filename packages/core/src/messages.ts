@@ -29,6 +29,15 @@ export const Message = z.object({
       output: z.number().optional(),
     })
     .optional(),
+  timing: z
+    .object({
+      startTime: z.number(),
+      endTime: z.number(),
+    })
+    .optional(),
+  author: z.string().optional(),
+  // Tokens the client's tokenizer counted, used to trim the history
+  contextTokens: z.number().int().nonnegative().optional(),
 });
 export type Message = z.infer<typeof Message>;
 
@@ -47,7 +56,12 @@ export type Conversation = z.infer<typeof Conversation>;
 export function createMessage(
   role: MessageRole,
   content: string,
-  options: Partial<Pick<Message, "toolCalls" | "toolResults" | "tokens">> = {},
+  options: Partial<
+    Pick<
+      Message,
+      "toolCalls" | "toolResults" | "tokens" | "timing" | "author" | "contextTokens"
+    >
+  > = {},
 ): Message {
   return {
     id: crypto.randomUUID(),
@@ -68,6 +82,13 @@ export function createConversation(): Conversation {
   };
 }
 
+// Rough token count at ~4 characters (UTF-16 code units) per token, for
+// usage the provider doesn't report and messages no client has counted
+// with its tokenizer yet (see Message.contextTokens)
+export function estimateTokens(text: string): number {
+  return Math.ceil(text.length / 4);
+}
+
 if (import.meta.main) {
   const testConversation = createConversation();
   const message = createMessage("user", "Hello!");
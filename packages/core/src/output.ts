@@ -0,0 +1,177 @@
+import { mkdirSync, writeFileSync } from "node:fs";
+import { homedir } from "node:os";
+import path from "node:path";
+
+// How much command output may enter the conversation verbatim
+export const OUTPUT_LIMITS = {
+  maxLines: 400,
+  maxChars: 40000,
+  headLines: 40,
+  tailLines: 120,
+  failureLines: 150,
+};
+
+// Lines worth keeping from the middle of a long output
+const FAILURE_PATTERN =
+  /\b(error|errors|fail|failed|failure|failing|panic|fatal|exception|traceback|assert(ion)?)\b|^\s*(---|===) FAIL|✗|✘/i;
+
+export interface SummarizedOutput {
+  text: string;
+  truncated: boolean;
+  totalLines: number;
+}
+
+// Keep the head, the tail, and failure lines (with their line numbers) of a huge output
+export function summarizeOutput(
+  text: string,
+  limits = OUTPUT_LIMITS,
+): SummarizedOutput {
+  const lines = text.split("\n");
+  if (lines.length <= limits.maxLines && text.length <= limits.maxChars) {
+    return { text, truncated: false, totalLines: lines.length };
+  }
+
+  const head = lines.slice(0, limits.headLines);
+  const tailStart = Math.max(limits.headLines, lines.length - limits.tailLines);
+  const tail = lines.slice(tailStart);
+
+  const failures: string[] = [];
+  let matched = 0;
+  for (let i = limits.headLines; i < tailStart; i++) {
+    if (FAILURE_PATTERN.test(lines[i])) {
+      matched++;
+      if (failures.length < limits.failureLines) {
+        failures.push(`${i + 1}: ${lines[i]}`);
+      }
+    }
+  }
+
+  const omitted = tailStart - limits.headLines;
+  const parts = [...head];
+  if (failures.length > 0) {
+    parts.push(
+      `... [${omitted} lines omitted; ${matched} failure lines among them${matched > failures.length ? `, first ${failures.length} shown` : ""}] ...`,
+      ...failures,
+      "... [end of failure lines] ...",
+    );
+  } else {
+    parts.push(`... [${omitted} lines omitted] ...`);
+  }
+  parts.push(...tail);
+
+  // Very long lines can still blow the budget; cut the middle of the summary
+  let summary = parts.join("\n");
+  if (summary.length > limits.maxChars) {
+    const half = Math.floor(limits.maxChars / 2);
+    summary = `${summary.slice(0, half)}\n... [${summary.length - limits.maxChars} characters omitted] ...\n${summary.slice(-half)}`;
+  }
+
+  return { text: summary, truncated: true, totalLines: lines.length };
+}
+
+// Size for messages, e.g. "200 KB" or "1.5 MB"
+export function formatSize(bytes: number): string {
+  if (bytes < 1024) return `${bytes} bytes`;
+  if (bytes < 1024 * 1024) return `${Math.round(bytes / 1024)} KB`;
+  return `${Math.round((bytes / (1024 * 1024)) * 10) / 10} MB`;
+}
+
+export interface CappedOutput {
+  text: string;
+  dropped: number; // Bytes cut from the middle
+}
+
+// Read a stream, keeping at most limit bytes: its start and its end. Reading
+// stops early when signal aborts (a timed-out command whose children still
+// hold the pipe open).
+export async function readCapped(
+  stream: ReadableStream<Uint8Array>,
+  limit: number | undefined,
+  signal: AbortSignal,
+): Promise<CappedOutput> {
+  const reader = stream.getReader();
+  const cancel = () => {
+    reader.cancel().catch(() => {});
+  };
+  signal.addEventListener("abort", cancel);
+
+  const half = (limit ?? Infinity) / 2;
+  const head: Uint8Array[] = [];
+  const tail: Uint8Array[] = [];
+  let headBytes = 0;
+  let tailBytes = 0;
+  let total = 0;
+  try {
+    while (true) {
+      const { done, value } = await reader.read();
+      if (done) break;
+      total += value.length;
+      let rest = value;
+      if (headBytes < half) {
+        const taken = rest.subarray(0, half - headBytes);
+        head.push(taken);
+        headBytes += taken.length;
+        rest = rest.subarray(taken.length);
+      }
+      if (rest.length === 0) continue;
+      tail.push(rest);
+      tailBytes += rest.length;
+      while (tailBytes > half) {
+        const extra = tailBytes - half;
+        if (tail[0].length <= extra) {
+          tailBytes -= tail.shift()!.length;
+        } else {
+          tail[0] = tail[0].subarray(extra);
+          tailBytes -= extra;
+        }
+      }
+    }
+  } catch {
+    // Cancelled: keep what arrived
+  } finally {
+    signal.removeEventListener("abort", cancel);
+  }
+
+  const decode = (chunks: Uint8Array[]) => Buffer.concat(chunks).toString("utf8");
+  const dropped = total - headBytes - tailBytes;
+  if (dropped === 0) {
+    return { text: decode([...head, ...tail]), dropped };
+  }
+  return {
+    text: `${decode(head)}\n... [${formatSize(dropped)} omitted: output over the ${formatSize(limit!)} limit] ...\n${decode(tail)}`,
+    dropped,
+  };
+}
+
+// Directory where full outputs of summarized commands are kept
+export function jobsDir(): string {
+  return path.join(homedir(), ".painika", "jobs");
+}
+
+// Full outputs stay off disk while the client is in incognito mode
+let keepJobOutputs = true;
+
+export function setJobOutputsKept(kept: boolean): void {
+  keepJobOutputs = kept;
+}
+
+// Store a command's full output on disk; returns the job ID (null when
+// outputs are not kept)
+export function saveJobOutput(
+  command: string,
+  output: string,
+  error: string,
+  exitCode: number | null,
+): string | null {
+  if (!keepJobOutputs) {
+    return null;
+  }
+  const id = `${Date.now().toString(36)}-${crypto.randomUUID().slice(0, 6)}`;
+  const dir = jobsDir();
+  mkdirSync(dir, { recursive: true });
+
+  const header = `$ ${command}\n# exit code: ${exitCode ?? "unknown"}\n`;
+  const body = error ? `${output}\n\n# stderr\n${error}\n` : `${output}\n`;
+  writeFileSync(path.join(dir, `${id}.log`), header + body);
+  return id;
+}
@@ -0,0 +1,228 @@
+// Unified diff parsing and fuzzy hunk application for the apply_patch tool
+
+export interface Hunk {
+  header: string;
+  oldStart: number; // 1-based; 0 when the header carries no line numbers
+  ops: { kind: " " | "-" | "+"; text: string }[];
+}
+
+export interface FilePatch {
+  oldPath: string | null; // null for a new file (--- /dev/null)
+  newPath: string | null; // null for a deleted file (+++ /dev/null)
+  hunks: Hunk[];
+}
+
+export interface RejectedHunk {
+  hunk: string;
+  reason: string;
+}
+
+export interface HunkResult {
+  content: string;
+  applied: number;
+  fuzzy: string[];
+  rejected: RejectedHunk[];
+}
+
+// Context lines a hunk may lose at each end and still apply (like patch --fuzz=2)
+const MAX_FUZZ = 2;
+
+function stripPrefix(header: string): string | null {
+  let file = header.replace(/^(---|\+\+\+)\s+/, "").split("\t")[0].trim();
+  if (file === "/dev/null") {
+    return null;
+  }
+  if (file.startsWith('"') && file.endsWith('"')) {
+    file = file.slice(1, -1);
+  }
+  return file.replace(/^[ab]\//, "");
+}
+
+// Parse one or more file sections; a bare list of hunks applies to defaultPath
+export function parsePatch(text: string, defaultPath?: string): FilePatch[] {
+  const lines = text.replace(/\r\n/g, "\n").split("\n");
+  const files: FilePatch[] = [];
+  let file: FilePatch | null = null;
+  let hunk: Hunk | null = null;
+
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i];
+
+    if (line.startsWith("--- ") && lines[i + 1]?.startsWith("+++ ")) {
+      file = {
+        oldPath: stripPrefix(line),
+        newPath: stripPrefix(lines[i + 1]),
+        hunks: [],
+      };
+      files.push(file);
+      hunk = null;
+      i++;
+      continue;
+    }
+
+    if (line.startsWith("@@")) {
+      if (!file) {
+        if (!defaultPath) {
+          throw new Error("Patch has no ---/+++ file headers; pass path for a bare list of hunks");
+        }
+        file = { oldPath: defaultPath, newPath: defaultPath, hunks: [] };
+        files.push(file);
+      }
+      const match = line.match(/^@@\s+-(\d+)(?:,\d+)?\s+\+\d+(?:,\d+)?\s+@@/);
+      hunk = { header: line, oldStart: match ? Number(match[1]) : 0, ops: [] };
+      file.hunks.push(hunk);
+      continue;
+    }
+
+    if (!hunk) {
+      // diff --git, index, and other preamble lines
+      continue;
+    }
+
+    if (line.startsWith("diff ")) {
+      hunk = null;
+    } else if (line.startsWith("\\")) {
+      // "\ No newline at end of file"
+    } else if (line.startsWith("+") || line.startsWith("-") || line.startsWith(" ")) {
+      hunk.ops.push({ kind: line[0] as " " | "-" | "+", text: line.slice(1) });
+    } else if (line === "") {
+      // Editors and models often strip the space of an empty context line
+      hunk.ops.push({ kind: " ", text: "" });
+    } else {
+      hunk = null;
+    }
+  }
+
+  // The trailing newline of the patch text is not an empty context line
+  for (const f of files) {
+    for (const h of f.hunks) {
+      while (h.ops.length > 0 && h.ops[h.ops.length - 1].kind === " " && h.ops[h.ops.length - 1].text === "") {
+        h.ops.pop();
+      }
+    }
+    f.hunks = f.hunks.filter((h) => h.ops.length > 0);
+  }
+
+  if (files.every((f) => f.hunks.length === 0 && f.newPath !== null)) {
+    throw new Error("Patch contains no hunks");
+  }
+  return files;
+}
+
+// Drop up to fuzz context lines from each end of a hunk
+function trimContext(ops: Hunk["ops"], fuzz: number): { ops: Hunk["ops"]; skipped: number } {
+  let start = 0;
+  let end = ops.length;
+  while (start < fuzz && start < end && ops[start].kind === " ") {
+    start++;
+  }
+  while (ops.length - end < fuzz && end > start && ops[end - 1].kind === " ") {
+    end--;
+  }
+  return { ops: ops.slice(start, end), skipped: start };
+}
+
+// Find the old lines in the file, nearest to the expected position first
+function locate(
+  lines: string[],
+  old: string[],
+  expected: number,
+  loose: boolean,
+): number {
+  const same = loose
+    ? (a: string, b: string) => a.trimEnd() === b.trimEnd()
+    : (a: string, b: string) => a === b;
+  const matchesAt = (at: number) => old.every((line, k) => same(lines[at + k], line));
+
+  const last = lines.length - old.length;
+  for (let distance = 0; distance <= Math.max(expected, last - expected); distance++) {
+    for (const at of distance === 0 ? [expected] : [expected - distance, expected + distance]) {
+      if (at >= 0 && at <= last && matchesAt(at)) {
+        return at;
+      }
+    }
+  }
+  return -1;
+}
+
+// Apply hunks in order; hunks that do not match are rejected with a reason
+export function applyHunks(content: string, hunks: Hunk[]): HunkResult {
+  const trailingNewline = content === "" || content.endsWith("\n");
+  const lines = content === "" ? [] : content.replace(/\n$/, "").split("\n");
+  const result: HunkResult = { content, applied: 0, fuzzy: [], rejected: [] };
+  let delta = 0;
+
+  for (const hunk of hunks) {
+    const expected = hunk.oldStart > 0 ? hunk.oldStart - 1 + delta : 0;
+    let placed = false;
+
+    for (let fuzz = 0; fuzz <= MAX_FUZZ && !placed; fuzz++) {
+      const { ops, skipped } = trimContext(hunk.ops, fuzz);
+      const old = ops.filter((op) => op.kind !== "+").map((op) => op.text);
+      const added = ops.filter((op) => op.kind !== "-").map((op) => op.text);
+
+      // Pure insertions go at the stated line, or the end without one
+      if (old.length === 0) {
+        if (fuzz > 0) {
+          // Fuzz trimmed away all the context; nothing left to anchor on
+          break;
+        }
+        const at = hunk.oldStart > 0 ? Math.min(expected + skipped, lines.length) : lines.length;
+        lines.splice(at, 0, ...added);
+        delta += added.length;
+        placed = true;
+        break;
+      }
+
+      for (const loose of [false, true]) {
+        const at = locate(lines, old, expected + skipped, loose);
+        if (at < 0) {
+          continue;
+        }
+        lines.splice(at, old.length, ...added);
+        delta += added.length - old.length;
+        placed = true;
+
+        const notes: string[] = [];
+        if (hunk.oldStart > 0 && at !== expected + skipped) {
+          notes.push(`offset ${at - expected - skipped} lines`);
+        }
+        if (fuzz > 0) {
+          notes.push(`fuzz ${fuzz}`);
+        }
+        if (loose) {
+          notes.push("ignoring trailing whitespace");
+        }
+        if (notes.length > 0) {
+          result.fuzzy.push(`${hunk.header} (${notes.join(", ")})`);
+        }
+        break;
+      }
+    }
+
+    if (placed) {
+      result.applied++;
+      continue;
+    }
+
+    const first = hunk.ops.find((op) => op.kind !== "+");
+    result.rejected.push({
+      hunk: hunk.header,
+      reason: first
+        ? `context not found in file (first line: ${JSON.stringify(first.text)})`
+        : "hunk is empty",
+    });
+  }
+
+  result.content = lines.join("\n") + (trailingNewline && lines.length > 0 ? "\n" : "");
+  return result;
+}
+
+// Paths a patch touches, for approvals and display
+export function patchedPaths(text: string): string[] {
+  try {
+    return parsePatch(text).map((f) => (f.newPath ?? f.oldPath) as string);
+  } catch {
+    return [];
+  }
+}
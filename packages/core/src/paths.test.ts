@@ -0,0 +1,38 @@
+import { afterEach, describe, expect, test } from "bun:test";
+import { mkdirSync, mkdtempSync, rmSync, symlinkSync, writeFileSync } from "node:fs";
+import { tmpdir } from "node:os";
+import path from "node:path";
+import { resolveToolPath, setFileAccessPolicy } from "./paths";
+
+describe("resolveToolPath protected paths", () => {
+  let root = "";
+
+  afterEach(() => {
+    rmSync(root, { recursive: true, force: true });
+    setFileAccessPolicy();
+  });
+
+  function workspace(): string {
+    root = mkdtempSync(path.join(tmpdir(), "painika-paths-"));
+    mkdirSync(path.join(root, ".git"));
+    writeFileSync(path.join(root, ".git", "config"), "[core]\n");
+    setFileAccessPolicy({ root, followSymlinks: "workspace" });
+    return root;
+  }
+
+  test("a symlink to .git/config is not written through", () => {
+    symlinkSync(".git/config", path.join(workspace(), "notes.txt"));
+    expect(() => resolveToolPath("notes.txt", "write")).toThrow("protected path");
+  });
+
+  test("a symlinked directory into .git is not written through", () => {
+    symlinkSync(".git", path.join(workspace(), "meta"));
+    expect(() => resolveToolPath("meta/hooks/pre-commit", "write")).toThrow("protected path");
+  });
+
+  test("reads through the symlink and plain writes still work", () => {
+    symlinkSync(".git/config", path.join(workspace(), "notes.txt"));
+    expect(resolveToolPath("notes.txt", "read")).toBe(path.join(root, "notes.txt"));
+    expect(resolveToolPath("src/main.ts", "write")).toBe(path.join(root, "src/main.ts"));
+  });
+});
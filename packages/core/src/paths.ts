@@ -0,0 +1,148 @@
+import { existsSync, lstatSync, realpathSync } from "node:fs";
+import path from "node:path";
+import { z } from "zod";
+
+// File access policy for the file tools
+export const FileAccessPolicy = z.object({
+  root: z.string().default(process.cwd()),
+  followSymlinks: z.enum(["never", "workspace", "always"]).default("workspace"),
+  protectedPaths: z.array(z.string()).default([]),
+  allowProtected: z.array(z.string()).default([]),
+});
+export type FileAccessPolicy = z.infer<typeof FileAccessPolicy>;
+
+// Paths the agent may never write unless explicitly allowed
+export const DEFAULT_PROTECTED_PATHS = [
+  ".git/**",
+  "**/.git/**",
+  "**/.env",
+  "**/package-lock.json",
+  "**/yarn.lock",
+  "**/pnpm-lock.yaml",
+  "**/bun.lockb",
+  "**/go.sum",
+  "**/Cargo.lock",
+  "**/poetry.lock",
+  "**/Gemfile.lock",
+  "**/composer.lock",
+];
+
+let policy: FileAccessPolicy = FileAccessPolicy.parse({});
+
+export function setFileAccessPolicy(config?: Partial<FileAccessPolicy>): void {
+  policy = FileAccessPolicy.parse(config || {});
+}
+
+// Convert a glob (*, **, ?) to an anchored regular expression
+export function globToRegExp(glob: string): RegExp {
+  let pattern = "";
+  for (let i = 0; i < glob.length; i++) {
+    const ch = glob[i];
+    if (ch === "*" && glob[i + 1] === "*") {
+      if (glob[i + 2] === "/") {
+        pattern += "(?:.*/)?";
+        i += 2;
+      } else {
+        pattern += ".*";
+        i += 1;
+      }
+    } else if (ch === "*") {
+      pattern += "[^/]*";
+    } else if (ch === "?") {
+      pattern += "[^/]";
+    } else {
+      pattern += ch.replace(/[.+^${}()|[\]\\]/g, "\\$&");
+    }
+  }
+  return new RegExp(`^${pattern}$`);
+}
+
+function matchesAny(relative: string, globs: string[]): boolean {
+  return globs.some((glob) => globToRegExp(glob).test(relative));
+}
+
+function isInside(root: string, target: string): boolean {
+  const relative = path.relative(root, target);
+  return (
+    relative === "" || (!relative.startsWith("..") && !path.isAbsolute(relative))
+  );
+}
+
+// Resolve symlinks on the deepest existing ancestor (the target may not exist yet)
+function realpathOfExisting(target: string): string {
+  let current = target;
+  const rest: string[] = [];
+  while (!existsSync(current)) {
+    const parent = path.dirname(current);
+    if (parent === current) break;
+    rest.unshift(path.basename(current));
+    current = parent;
+  }
+  return path.join(realpathSync(current), ...rest);
+}
+
+function hasSymlink(root: string, target: string): boolean {
+  let current = target;
+  while (isInside(root, current) && current !== root) {
+    if (existsSync(current) && lstatSync(current).isSymbolicLink()) {
+      return true;
+    }
+    current = path.dirname(current);
+  }
+  return false;
+}
+
+/**
+ * Canonicalize a tool path and enforce the workspace policy:
+ * no traversal outside the root, symlinks per policy, and
+ * no writes to protected paths unless explicitly allowed.
+ */
+export function resolveToolPath(
+  requested: string,
+  mode: "read" | "write",
+): string {
+  const root = path.resolve(policy.root);
+  const absolute = path.resolve(root, requested);
+
+  if (!isInside(root, absolute)) {
+    throw new Error(`Access denied: ${requested} is outside the workspace root`);
+  }
+
+  if (policy.followSymlinks === "never" && hasSymlink(root, absolute)) {
+    throw new Error(`Access denied: ${requested} goes through a symlink`);
+  }
+  if (policy.followSymlinks === "workspace") {
+    const real = realpathOfExisting(absolute);
+    if (!isInside(realpathOfExisting(root), real)) {
+      throw new Error(
+        `Access denied: ${requested} links outside the workspace root`,
+      );
+    }
+  }
+
+  if (mode === "write") {
+    // A symlink inside the workspace may lead to a protected path, so the
+    // path it resolves to is checked as well as the one requested
+    const relatives = [path.relative(root, absolute)];
+    const realRoot = realpathOfExisting(root);
+    const real = realpathOfExisting(absolute);
+    if (isInside(realRoot, real)) {
+      relatives.push(path.relative(realRoot, real));
+    }
+    const protectedPaths = [...DEFAULT_PROTECTED_PATHS, ...policy.protectedPaths];
+    const blocked = relatives
+      .map((relative) => relative.split(path.sep).join("/"))
+      .some(
+        (relative) =>
+          matchesAny(relative, protectedPaths) &&
+          !matchesAny(relative, policy.allowProtected),
+      );
+    if (blocked) {
+      throw new Error(
+        `Access denied: ${requested} is a protected path (allow it with ALLOW_PROTECTED_WRITES)`,
+      );
+    }
+  }
+
+  return absolute;
+}
@@ -0,0 +1,123 @@
+import { z } from "zod";
+import type { Tool } from "./tools";
+
+// The user's answer to a question; skipped when nobody answered
+export const QuestionAnswer = z.object({
+  selected: z.number().int().nonnegative().optional(),
+  text: z.string().optional(),
+  skipped: z.boolean().optional(),
+});
+export type QuestionAnswer = z.infer<typeof QuestionAnswer>;
+
+export interface PendingQuestion {
+  id: string;
+  question: string;
+  options: string[];
+  allowOther: boolean;
+  createdAt: number;
+  expiresAt?: number;
+}
+
+interface PendingEntry {
+  question: PendingQuestion;
+  resolve: (answer: QuestionAnswer) => void;
+  timer?: ReturnType<typeof setTimeout>;
+}
+
+// Questions the model asked, waiting for the client to answer them
+export class QuestionBoard {
+  private pending = new Map<string, PendingEntry>();
+
+  constructor(private timeoutMs = 300000) {}
+
+  // Wait for the user's answer; resolves as skipped if none arrives in time
+  ask(
+    question: string,
+    options: string[],
+    allowOther: boolean,
+  ): Promise<QuestionAnswer> {
+    const now = Date.now();
+    const pending: PendingQuestion = {
+      id: crypto.randomUUID(),
+      question,
+      options,
+      allowOther,
+      createdAt: now,
+      expiresAt: this.timeoutMs > 0 ? now + this.timeoutMs : undefined,
+    };
+
+    return new Promise<QuestionAnswer>((resolve) => {
+      const entry: PendingEntry = { question: pending, resolve };
+      if (this.timeoutMs > 0) {
+        entry.timer = setTimeout(() => {
+          this.pending.delete(pending.id);
+          resolve({ skipped: true });
+        }, this.timeoutMs);
+      }
+      this.pending.set(pending.id, entry);
+    });
+  }
+
+  list(): PendingQuestion[] {
+    return [...this.pending.values()].map((entry) => entry.question);
+  }
+
+  // Answer a pending question; returns false if it no longer exists
+  answer(id: string, answer: QuestionAnswer): boolean {
+    const entry = this.pending.get(id);
+    if (!entry) {
+      return false;
+    }
+
+    const parsed = QuestionAnswer.parse(answer);
+    const { options, allowOther } = entry.question;
+    if (parsed.selected !== undefined && parsed.selected >= options.length) {
+      throw new Error(`Option ${parsed.selected} out of range`);
+    }
+    if (parsed.selected === undefined && parsed.text && !allowOther) {
+      throw new Error("This question only accepts one of its options");
+    }
+
+    if (entry.timer) {
+      clearTimeout(entry.timer);
+    }
+    this.pending.delete(id);
+    entry.resolve(parsed);
+    return true;
+  }
+}
+
+// Tool the model uses to ask a clarifying question with a fixed set of options
+export function createAskUserTool(board: QuestionBoard): Tool {
+  return {
+    name: "ask_user",
+    description:
+      "Ask the user a clarifying question with 2-6 short options to choose from. Use it when the task is ambiguous and the choice matters, instead of guessing",
+    parameters: z.object({
+      question: z.string(),
+      options: z.array(z.string()).min(2).max(6),
+      allowOther: z.boolean().default(false),
+    }),
+    execute: async (params) => {
+      const answer = await board.ask(
+        params.question,
+        params.options,
+        params.allowOther,
+      );
+
+      if (answer.skipped) {
+        return {
+          skipped: true,
+          note: "The user did not answer; proceed with the most reasonable option and say which one you chose",
+        };
+      }
+      if (answer.selected !== undefined) {
+        return {
+          answer: params.options[answer.selected],
+          optionIndex: answer.selected,
+        };
+      }
+      return { answer: answer.text, other: true };
+    },
+  };
+}
@@ -0,0 +1,126 @@
+import { z } from "zod";
+import { formatSize, OUTPUT_LIMITS } from "./output";
+import type { Tool } from "./tools";
+
+// Tool results over this many tokens reach the model as a preview with a
+// reference it can expand (0 sends every result whole)
+export const DEFAULT_RESULT_PREVIEW_TOKENS = 2000;
+
+// Lines of a result's text a preview keeps
+const PREVIEW_LINES = 40;
+
+// The text a preview shortens and expand_result pages through: the result
+// itself when it is a string, else its longest string field (a file's
+// content, a command's output), else the whole result as JSON
+function resultText(result: unknown): { key: string | null; text: string } {
+  if (typeof result === "string") {
+    return { key: null, text: result };
+  }
+  if (result && typeof result === "object" && !Array.isArray(result)) {
+    let key: string | null = null;
+    for (const [name, value] of Object.entries(result)) {
+      if (
+        typeof value === "string" &&
+        (key === null || value.length > (result as any)[key].length)
+      ) {
+        key = name;
+      }
+    }
+    if (key !== null) {
+      return { key, text: (result as any)[key] };
+    }
+  }
+  return { key: null, text: JSON.stringify(result, null, 2) ?? "" };
+}
+
+// What the model is sent for a tool result: the result itself, or when it
+// is longer than previewChars, its first lines with a ref for expand_result.
+// The full result stays in the message's toolResults.
+export function toolResultContent(
+  output: unknown,
+  error: string | undefined,
+  previewChars: number,
+): string {
+  if (error) {
+    return JSON.stringify({ error });
+  }
+  const full = JSON.stringify(output ?? null);
+  if (!previewChars || full.length <= previewChars) {
+    return full;
+  }
+
+  const ref = `res_${crypto.randomUUID().slice(0, 8)}`;
+  const { key, text } = resultText(output);
+  const lines = text.split("\n");
+  const preview = lines
+    .slice(0, PREVIEW_LINES)
+    .join("\n")
+    .slice(0, Math.floor(previewChars / 2));
+  const shownLines = preview.split("\n").length;
+  const notes = [
+    `This ${formatSize(full.length)} result was cut to a preview (${shownLines} of ${lines.length} lines). Call expand_result with ref "${ref}" and the line range you need (from, to) instead of running the tool again.`,
+  ];
+  const shown: Record<string, unknown> =
+    key !== null ? { ...(output as object), [key]: preview } : { preview };
+  if (typeof shown.note === "string" && shown.note) {
+    notes.unshift(shown.note);
+  }
+  return JSON.stringify({ ...shown, ref, note: notes.join(" ") });
+}
+
+// Ref of a tool message's preview, if it was cut to one
+export function resultRef(content: string): string | undefined {
+  try {
+    const parsed = JSON.parse(content);
+    return typeof parsed?.ref === "string" ? parsed.ref : undefined;
+  } catch {
+    return undefined;
+  }
+}
+
+// Tool the model pages through a previewed result with; find returns the
+// full result behind a ref, undefined when the conversation no longer has it
+export function createExpandResultTool(
+  find: (ref: string) => unknown,
+): Tool {
+  return {
+    name: "expand_result",
+    description:
+      "Get lines of a tool result that was cut to a preview with a ref. Ask for the range you need (1-based, inclusive); long ranges come back in parts",
+    parameters: z.object({
+      ref: z.string(),
+      from: z.number().int().positive().default(1),
+      to: z.number().int().positive().optional(),
+    }),
+    execute: async ({ ref, from, to }) => {
+      const result = find(ref);
+      if (result === undefined) {
+        throw new Error(
+          `No result with ref ${ref} in this conversation; it may have been dropped from the history`,
+        );
+      }
+
+      const lines = resultText(result).text.split("\n");
+      const wanted = Math.min(to ?? lines.length, lines.length);
+      let last = Math.min(wanted, from + OUTPUT_LIMITS.maxLines - 1);
+      let content = lines.slice(from - 1, last).join("\n");
+      while (content.length > OUTPUT_LIMITS.maxChars && last > from) {
+        last = from + Math.floor((last - from) / 2);
+        content = lines.slice(from - 1, last).join("\n");
+      }
+      if (content.length > OUTPUT_LIMITS.maxChars) {
+        content = `${content.slice(0, OUTPUT_LIMITS.maxChars)}\n... [the rest of line ${from} was cut] ...`;
+      }
+      return {
+        content,
+        from,
+        to: last,
+        totalLines: lines.length,
+        note:
+          last < wanted
+            ? `Lines ${last + 1}-${wanted} were left out to keep this small; ask for them with from: ${last + 1}.`
+            : undefined,
+      };
+    },
+  };
+}
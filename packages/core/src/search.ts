@@ -0,0 +1,230 @@
+// Text search over the workspace for the search_text tool: regex or keyword
+// matching with BM25 ranking, in plain JS so it behaves the same on every OS
+
+import { readdirSync, readFileSync, statSync } from "node:fs";
+import path from "node:path";
+import { globToRegExp } from "./paths";
+
+// Directories of dependencies and build output, never searched
+const SKIPPED_DIRS = new Set([
+  ".git",
+  "node_modules",
+  "vendor",
+  "third_party",
+  ".venv",
+  "venv",
+  "__pycache__",
+  "dist",
+  "build",
+  "target",
+  ".next",
+  "coverage",
+]);
+
+const MAX_FILE_BYTES = 1024 * 1024;
+export const MAX_FILES = 20000;
+const MAX_MATCHES_PER_FILE = 5;
+
+// A keyword before a match marks a line that defines what it matched; those
+// rank above lines that only use it
+const DEFINITION =
+  /\b(?:func|function|def|class|interface|struct|type|enum|const|let|var|fn|impl|module|trait)\b/;
+
+export interface SearchOptions {
+  query: string;
+  regex: boolean;
+  caseSensitive: boolean;
+  glob?: string;
+  contextLines: number;
+  maxResults: number;
+}
+
+export interface SearchResult {
+  path: string;
+  score: number;
+  matches: number;
+  lines: string; // grep style: "12: match" and "11- context"
+}
+
+// Files under root, skipping vendored and symlinked directories
+export function listFiles(root: string, glob: RegExp | null): string[] {
+  const files: string[] = [];
+  const walk = (dir: string) => {
+    let entries;
+    try {
+      entries = readdirSync(dir, { withFileTypes: true });
+    } catch {
+      return;
+    }
+    entries.sort((a, b) => a.name.localeCompare(b.name));
+    for (const entry of entries) {
+      if (files.length >= MAX_FILES) return;
+      const full = path.join(dir, entry.name);
+      if (entry.isDirectory()) {
+        if (!SKIPPED_DIRS.has(entry.name)) walk(full);
+        continue;
+      }
+      if (!entry.isFile()) continue;
+      const relative = path.relative(root, full).split(path.sep).join("/");
+      if (glob && !glob.test(relative) && !glob.test(entry.name)) continue;
+      files.push(full);
+    }
+  };
+  walk(root);
+  return files;
+}
+
+// Text of a file, or null for large and binary files
+export function readText(file: string): string | null {
+  try {
+    if (statSync(file).size > MAX_FILE_BYTES) return null;
+    const data = readFileSync(file);
+    if (data.subarray(0, 8000).includes(0)) return null;
+    return data.toString("utf8");
+  } catch {
+    return null;
+  }
+}
+
+function escapeRegExp(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+}
+
+// Search files under dir; the best files first, each with its best lines.
+// Paths in the results are relative to root.
+export function searchText(
+  dir: string,
+  root: string,
+  options: SearchOptions,
+): { results: SearchResult[]; searchedFiles: number; matchedFiles: number } {
+  const flags = options.caseSensitive ? "g" : "gi";
+  let terms: RegExp[];
+  let phrase: RegExp | null = null;
+  if (options.regex) {
+    try {
+      terms = [new RegExp(options.query, flags)];
+    } catch (error) {
+      throw new Error(
+        `Invalid regex: ${error instanceof Error ? error.message : String(error)}`,
+      );
+    }
+  } else {
+    const words = [...new Set(options.query.split(/\s+/).filter(Boolean))];
+    if (words.length === 0) throw new Error("Empty query");
+    terms = words.map((word) => new RegExp(escapeRegExp(word), flags));
+    if (words.length > 1) {
+      phrase = new RegExp(escapeRegExp(options.query.trim()), flags);
+    }
+  }
+  const count = (pattern: RegExp, text: string) => {
+    pattern.lastIndex = 0;
+    let n = 0;
+    let match;
+    while ((match = pattern.exec(text)) && n < 1000) {
+      n++;
+      if (match[0] === "") pattern.lastIndex++; // Zero-width match
+    }
+    pattern.lastIndex = 0;
+    return n;
+  };
+
+  const glob = options.glob ? globToRegExp(options.glob) : null;
+  const files = listFiles(dir, glob);
+
+  // Term frequencies per file, and per line for the lines that match
+  const docs: {
+    file: string;
+    lines: string[];
+    length: number;
+    tf: number[];
+    hits: { index: number; score: number }[];
+  }[] = [];
+  const df = terms.map(() => 0);
+  let totalLength = 0;
+  let searched = 0;
+  for (const file of files) {
+    const text = readText(file);
+    if (text === null) continue;
+    searched++;
+    const length = text.split(/\s+/).length;
+    totalLength += length;
+
+    const lines = text.split(/\r?\n/);
+    const tf = terms.map(() => 0);
+    const hits: { index: number; score: number }[] = [];
+    lines.forEach((line, index) => {
+      let lineScore = 0; // Terms on the line
+      let first = line.length;
+      terms.forEach((term, t) => {
+        const n = count(term, line);
+        if (n > 0) {
+          tf[t] += n;
+          lineScore++;
+          first = Math.min(first, line.search(term));
+        }
+      });
+      if (lineScore === 0) return;
+      if (phrase && count(phrase, line) > 0) lineScore += terms.length;
+      if (DEFINITION.test(line.slice(0, first))) lineScore += 3;
+      hits.push({ index, score: lineScore });
+    });
+    if (hits.length === 0) continue;
+    tf.forEach((n, t) => {
+      if (n > 0) df[t]++;
+    });
+    docs.push({ file, lines, length, tf, hits });
+  }
+
+  // BM25 over files, plus the best line's score so definitions and whole
+  // phrases surface first
+  const k1 = 1.2;
+  const b = 0.75;
+  const averageLength = totalLength / Math.max(searched, 1);
+  const ranked = docs.map((doc) => {
+    let score = 0;
+    doc.tf.forEach((n, t) => {
+      if (n === 0) return;
+      const idf = Math.log(1 + (searched - df[t] + 0.5) / (df[t] + 0.5));
+      score +=
+        (idf * n * (k1 + 1)) / (n + k1 * (1 - b + (b * doc.length) / averageLength));
+    });
+    score += Math.max(...doc.hits.map((hit) => hit.score));
+    return { doc, score };
+  });
+  ranked.sort((x, y) => y.score - x.score);
+
+  const results = ranked.slice(0, options.maxResults).map(({ doc, score }) => {
+    // The best lines, shown in file order with their context
+    const best = [...doc.hits]
+      .sort((x, y) => y.score - x.score || x.index - y.index)
+      .slice(0, MAX_MATCHES_PER_FILE)
+      .map((hit) => hit.index)
+      .sort((x, y) => x - y);
+    const shown = new Map<number, boolean>(); // line index -> is a match
+    for (const index of best) {
+      const from = Math.max(0, index - options.contextLines);
+      const to = Math.min(doc.lines.length - 1, index + options.contextLines);
+      for (let i = from; i <= to; i++) {
+        shown.set(i, shown.get(i) || i === index);
+      }
+    }
+    const out: string[] = [];
+    let previous = -1;
+    for (const index of [...shown.keys()].sort((x, y) => x - y)) {
+      if (previous >= 0 && index > previous + 1) out.push("--");
+      const line = doc.lines[index];
+      out.push(
+        `${index + 1}${shown.get(index) ? ":" : "-"} ${line.length > 300 ? line.slice(0, 300) + "..." : line}`,
+      );
+      previous = index;
+    }
+    return {
+      path: path.relative(root, doc.file).split(path.sep).join("/"),
+      score: Math.round(score * 100) / 100,
+      matches: doc.hits.length,
+      lines: out.join("\n"),
+    };
+  });
+
+  return { results, searchedFiles: searched, matchedFiles: docs.length };
+}
@@ -3,32 +3,125 @@ import {
   Conversation,
   createConversation,
   createMessage,
+  estimateTokens,
   Message,
+  type ToolCall,
 } from "./messages";
 import {
+  applyPatchTool,
   bashTool,
+  createCustomTool,
+  createRenameSymbolTool,
+  CustomTool,
+  type GroqAITool,
   listFilesTool,
+  searchTextTool,
+  projectStatsTool,
   makeDirTool,
   readFileTool,
+  rememberTool,
+  setCommandEnv,
+  type ToolExecution,
   ToolExecutor,
+  ToolLimit,
   writeFileTool,
 } from "./tools";
-import { GroqClient } from "./groq";
+import { GroqClient, MAX_REPLY_TOKENS, RequestShaping } from "./groq";
+import type { ModelCapabilities } from "./capabilities";
+import { FileAccessPolicy, setFileAccessPolicy } from "./paths";
+import { setJobOutputsKept } from "./output";
+import {
+  createExpandResultTool,
+  DEFAULT_RESULT_PREVIEW_TOKENS,
+  resultRef,
+  toolResultContent,
+} from "./results";
+import {
+  ApprovalDecision,
+  ApprovalGate,
+  ApprovalPolicy,
+  type PendingApproval,
+} from "./approval";
+import {
+  createAskUserTool,
+  QuestionAnswer,
+  QuestionBoard,
+  type PendingQuestion,
+} from "./questions";
+
+// How much prior conversation is sent with each request
+export const HistoryWindow = z.object({
+  turns: z.number().int().positive().optional(),
+  tokens: z.number().int().positive().optional(),
+});
+export type HistoryWindow = z.infer<typeof HistoryWindow>;
+
+// Request shaping for the conversation's model calls
+export const ShapingPolicy = z.object({
+  models: z.record(RequestShaping).optional(), // By model name, from the config files
+  session: RequestShaping.optional(), // Set for this session; wins over the model's
+});
+export type ShapingPolicy = z.infer<typeof ShapingPolicy>;
 
 export const SessionConfig = z.object({
   groq: z.object({
     token: z.string(),
     model: z.string().default("llama-3.3-70b-versatile"),
     baseURL: z.string().default("https://api.groq.com/openai"),
+    temperature: z.number().min(0).max(2).optional(),
+    provider: z.string().optional(),
   }),
+  systemContext: z.string().optional(),
+  gitContext: z.string().optional(),
+  historyWindow: HistoryWindow.optional(),
+  fileAccess: FileAccessPolicy.partial().optional(),
+  approval: ApprovalPolicy.partial().optional(),
+  customTools: z.array(CustomTool).optional(),
+  toolLimits: z.record(ToolLimit).optional(),
+  renameCommand: z.string().optional(), // Command behind rename_symbol, when the client offers one
+  toolEnv: z.record(z.string()).optional(), // Variables for the commands tools run
+  resultPreviewTokens: z.number().int().min(0).optional(), // Larger tool results are sent as a preview (0: never)
+  requestShaping: ShapingPolicy.optional(),
+  restore: Conversation.optional(),
 });
 
 export type SessionConfig = z.infer<typeof SessionConfig>;
 
+// Persona layered on the base system prompt, with its own sampling and tool policy
+export const Persona = z.object({
+  name: z.string(),
+  prompt: z.string().default(""),
+  temperature: z.number().min(0).max(2).optional(),
+  tools: z.array(z.string()).optional(),
+});
+export type Persona = z.infer<typeof Persona>;
+
+// Tokens of messages for history trimming: the client's tokenizer counts,
+// estimated for messages it hasn't counted yet (the running turn's)
+function messagesTokens(messages: Message[]): number {
+  return messages.reduce(
+    (total, msg) =>
+      total +
+      (msg.contextTokens ??
+        estimateTokens(msg.content + JSON.stringify(msg.toolCalls || []))),
+    0,
+  );
+}
+
 export class Session {
   private conversation: Conversation;
   private groq: GroqClient;
   private toolExecutor: ToolExecutor;
+  private historyWindow: HistoryWindow = {};
+  private approvals: ApprovalGate;
+  private questions = new QuestionBoard();
+  private systemPrompt: string;
+  private systemMessage: Message;
+  private gitContext = "";
+  private persona: Persona | null = null;
+  private customTools: string[] = [];
+  private resultPreviewChars = DEFAULT_RESULT_PREVIEW_TOKENS * 4;
+  private shaping: ShapingPolicy = {};
 
   constructor(config: SessionConfig) {
     const validatedConfig = SessionConfig.parse(config);
@@ -36,13 +129,34 @@ export class Session {
     this.conversation = createConversation();
     this.groq = new GroqClient(validatedConfig.groq);
     this.toolExecutor = new ToolExecutor();
+    setFileAccessPolicy(validatedConfig.fileAccess);
+    this.setHistoryWindow(validatedConfig.historyWindow);
+    this.approvals = new ApprovalGate(validatedConfig.approval);
 
     // Register built-in tools
     this.toolExecutor.registerTool(bashTool);
     this.toolExecutor.registerTool(readFileTool);
     this.toolExecutor.registerTool(writeFileTool);
+    this.toolExecutor.registerTool(applyPatchTool);
     this.toolExecutor.registerTool(listFilesTool);
+    this.toolExecutor.registerTool(searchTextTool);
+    this.toolExecutor.registerTool(projectStatsTool);
     this.toolExecutor.registerTool(makeDirTool);
+    this.toolExecutor.registerTool(rememberTool);
+    this.toolExecutor.registerTool(createAskUserTool(this.questions));
+    this.toolExecutor.registerTool(
+      createExpandResultTool((ref) => this.findResult(ref)),
+    );
+    if (validatedConfig.renameCommand) {
+      this.toolExecutor.registerTool(
+        createRenameSymbolTool(validatedConfig.renameCommand),
+      );
+    }
+    this.setCustomTools(validatedConfig.customTools);
+    this.setToolLimits(validatedConfig.toolLimits);
+    this.setToolEnv(validatedConfig.toolEnv);
+    this.setResultPreview(validatedConfig.resultPreviewTokens);
+    this.setRequestShaping(validatedConfig.requestShaping);
 
     // Add system prompt
     const systemMessage = createMessage(
@@ -63,6 +177,10 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
 - Use tools when you need to interact with the file system, execute code, or perform system operations
 - For simple questions like math problems or general knowledge, answer directly without using tools
 - When making file changes, first understand the file's code conventions and follow existing patterns
+- Edit existing files with apply_patch (a unified diff) rather than rewriting them with writeFile
+- Find code with search_text rather than grep or find through bash
+- Get an overview of an unfamiliar project (languages, TODOs, tests, largest files, dependencies) with project_stats rather than a series of shell commands
+- Large tool results come back as a preview with a ref; fetch the lines you need with expand_result rather than running the tool again
 
 # Code Standards
 - Follow existing code style, libraries, and patterns in the codebase
@@ -74,24 +192,265 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
 - Understand the codebase context before making changes
 - Mimic existing code style and use established libraries
 - Verify solutions when possible
-- Be proactive but not surprising - do what's asked, nothing more`,
+- Be proactive but not surprising - do what's asked, nothing more
+- When a request is ambiguous and the choice matters, ask with the ask_user tool and a few concrete options rather than guessing
+
+# Memory
+- Use the remember tool to save durable facts the user states about their tooling, conventions, or preferences`,
     );
+
+    // Append client-provided context (memories, etc.)
+    if (validatedConfig.systemContext) {
+      systemMessage.content += `\n\n${validatedConfig.systemContext}`;
+    }
+    this.systemPrompt = systemMessage.content;
+    this.systemMessage = systemMessage;
+    this.setGitContext(validatedConfig.gitContext);
     this.conversation.messages.push(systemMessage);
+
+    // Resume a conversation from a previous server process
+    if (validatedConfig.restore) {
+      const { restore } = validatedConfig;
+      this.conversation = {
+        ...restore,
+        messages: [
+          systemMessage,
+          ...restore.messages.filter((msg) => msg.role !== "system"),
+        ],
+      };
+    }
+  }
+
+  // Switch persona; null restores the base prompt, temperature, and tools
+  setPersona(persona?: Persona | null): void {
+    this.persona = persona ? Persona.parse(persona) : null;
+    this.renderSystemMessage();
+    this.groq.setTemperature(this.persona?.temperature);
+  }
+
+  // Replace the project-defined tools the user trusted
+  setCustomTools(tools?: CustomTool[]): void {
+    for (const name of this.customTools) {
+      this.toolExecutor.unregisterTool(name);
+    }
+    this.customTools = [];
+    for (const definition of tools || []) {
+      const tool = createCustomTool(definition);
+      this.toolExecutor.registerTool(tool);
+      this.customTools.push(tool.name);
+    }
+  }
+
+  // Change the base temperature; an active persona's temperature still wins
+  setTemperature(temperature?: number | null): void {
+    this.groq.setDefaultTemperature(temperature ?? undefined);
+    this.groq.setTemperature(this.persona?.temperature);
+  }
+
+  // Replace the tools that need confirmation and the timeout behavior
+  setApprovalPolicy(policy: Partial<ApprovalPolicy>): void {
+    this.approvals.setPolicy(policy);
+  }
+
+  // Replace the variables set for the commands tools run
+  setToolEnv(env?: Record<string, string>): void {
+    setCommandEnv(z.record(z.string()).parse(env || {}));
+  }
+
+  // Send tool results over this many tokens as a preview (0: never)
+  setResultPreview(tokens?: number): void {
+    this.resultPreviewChars =
+      z.number().int().min(0).parse(tokens ?? DEFAULT_RESULT_PREVIEW_TOKENS) * 4;
+  }
+
+  // Replace the JSON mode, tool_choice, and parallel_tool_calls settings
+  setRequestShaping(policy?: ShapingPolicy): void {
+    this.shaping = ShapingPolicy.parse(policy || {});
+  }
+
+  // Shaping for the current model, found by its name with or without a tag
+  // (llama3.1:8b). After tools ran the model may answer without calling
+  // another, as those calls would not be run.
+  private requestShaping(followUp = false): RequestShaping {
+    const { model } = this.groq.getSettings();
+    const models = this.shaping.models ?? {};
+    const shaping = {
+      ...(models[model] ?? models[model.split(":")[0]]),
+      ...this.shaping.session,
+    };
+    if (followUp && shaping.toolChoice === "required") {
+      shaping.toolChoice = "auto";
+    }
+    return shaping;
+  }
+
+  // Full result behind a preview's ref
+  private findResult(ref: string): unknown {
+    const message = this.conversation.messages.find(
+      (msg) => msg.role === "tool" && resultRef(msg.content) === ref,
+    );
+    return message?.toolResults?.[0]?.result ?? undefined;
+  }
+
+  // What the model is sent for a tool's result; pages of expand_result are
+  // sent whole, as the model asked for them
+  private resultContent(execution: ToolExecution): string {
+    const previewChars =
+      execution.name === "expand_result" ? 0 : this.resultPreviewChars;
+    return toolResultContent(execution.output, execution.error, previewChars);
+  }
+
+  // In incognito mode full command outputs are not written to disk
+  setIncognito(incognito: boolean): void {
+    setJobOutputsKept(!incognito);
+  }
+
+  // Replace the branch, commits, and dirty files the client last reported
+  setGitContext(context?: string): void {
+    this.gitContext = context || "";
+    this.renderSystemMessage();
+  }
+
+  // Base prompt, then git context, then the persona's instructions
+  private renderSystemMessage(): void {
+    let content = this.systemPrompt;
+    if (this.gitContext) {
+      content += `\n\n${this.gitContext}`;
+    }
+    if (this.persona?.prompt) {
+      content += `\n\n# Persona: ${this.persona.name}\n${this.persona.prompt}`;
+    }
+    if (this.systemMessage.content !== content) {
+      this.systemMessage.content = content;
+      delete this.systemMessage.contextTokens; // Counted again by the client
+    }
+  }
+
+  private toolAllowed(name: string): boolean {
+    // Asking the user a question is never restricted
+    if (name === "ask_user") {
+      return true;
+    }
+    return !this.persona?.tools || this.persona.tools.includes(name);
+  }
+
+  // Tools offered to the model under the current persona
+  private availableTools() {
+    return this.toolExecutor
+      .getGroqAITools()
+      .filter((tool) => this.toolAllowed(tool.function.name));
+  }
+
+  setModel(model: string): void {
+    this.groq.setModel(model);
+  }
+
+  getCapabilities(): ModelCapabilities {
+    return this.groq.capabilities();
+  }
+
+  setHistoryWindow(window?: HistoryWindow): void {
+    this.historyWindow = HistoryWindow.parse(window || {});
+  }
+
+  // Per-tool timeouts and output limits, by tool name
+  setToolLimits(limits?: Record<string, ToolLimit>): void {
+    this.toolExecutor.setLimits(z.record(ToolLimit).parse(limits || {}));
   }
 
-  async sendMessage(content: string): Promise<Message> {
+  // System prompt plus the most recent turns that fit the history window.
+  // Older turns are also left out when the conversation would overflow the
+  // model's context.
+  private contextMessages(): Message[] {
+    const messages = this.conversation.messages;
+    const { turns } = this.historyWindow;
+    const systemTokens = messagesTokens(
+      messages.filter((msg) => msg.role === "system"),
+    );
+    const room =
+      this.groq.capabilities().contextTokens - MAX_REPLY_TOKENS - systemTokens;
+    const tokens = Math.min(this.historyWindow.tokens ?? room, room);
+    if (!turns && messagesTokens(messages) - systemTokens <= tokens) {
+      return messages;
+    }
+
+    const system = messages.filter((msg) => msg.role === "system");
+    const rest = messages.filter((msg) => msg.role !== "system");
+
+    // Turns start at user messages, so tool calls stay with their results
+    const starts = rest
+      .map((msg, i) => (msg.role === "user" ? i : -1))
+      .filter((i) => i >= 0);
+    if (starts.length === 0) {
+      return messages;
+    }
+
+    let start = 0;
+    if (turns && starts.length > turns) {
+      start = starts[starts.length - turns];
+    }
+    if (tokens) {
+      // Always keep the current turn, then add older turns while they fit
+      let budgetStart = starts[starts.length - 1];
+      for (let t = starts.length - 2; t >= 0; t--) {
+        if (messagesTokens(rest.slice(starts[t])) > tokens) break;
+        budgetStart = starts[t];
+      }
+      start = Math.max(start, budgetStart);
+    }
+
+    return [...system, ...rest.slice(start)];
+  }
+
+  // With onText, the reply text is passed on as it is generated. Each model
+  // call's text ends with a newline, so the client can finish the line
+  // before tools run. The author names the client that sent the message
+  // when several share the session.
+  async sendMessage(
+    content: string,
+    onText?: (text: string) => void,
+    author?: string,
+    contentTokens?: number,
+  ): Promise<Message> {
     // Add user message to conversation
-    const userMessage = createMessage("user", content);
+    const userMessage = createMessage("user", content, {
+      ...(author ? { author } : {}),
+      ...(contentTokens !== undefined ? { contextTokens: contentTokens } : {}),
+    });
     this.conversation.messages.push(userMessage);
+    this.approvals.startTurn();
 
     // Get available tools
-    const tools = this.toolExecutor.getGroqAITools();
+    const tools = this.availableTools();
+    const complete = async (followUp = false) => {
+      let last = "";
+      const response = await this.groq.complete(
+        this.contextMessages(),
+        tools,
+        onText &&
+          ((text) => {
+            last = text;
+            onText(text);
+          }),
+        this.requestShaping(followUp),
+      );
+      if (onText && last !== "" && !last.endsWith("\n")) {
+        onText("\n");
+      }
+      return response;
+    };
 
     // Get response from Groq
-    const response = await this.groq.complete(
-      this.conversation.messages,
-      tools,
-    );
+    const requestStart = Date.now();
+    let response;
+    try {
+      response = await complete();
+    } catch (error) {
+      // Nothing ran yet, so drop the message and let the client retry cleanly
+      this.conversation.messages.pop();
+      throw error;
+    }
+    const timing = { startTime: requestStart, endTime: Date.now() };
 
     // Handle tool calls
     if (response.toolCalls && response.toolCalls.length > 0) {
@@ -101,6 +460,7 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
         response.content || "",
         {
           tokens: response.tokens,
+          timing,
           toolCalls: response.toolCalls.map((call) => ({
             id: call.id,
             name: call.function.name,
@@ -115,6 +475,33 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
       for (const toolCall of response.toolCalls) {
         try {
           const params = JSON.parse(toolCall.function.arguments);
+
+          if (!this.toolAllowed(toolCall.function.name)) {
+            throw new Error(
+              `Tool ${toolCall.function.name} is not available to the ${this.persona?.name} persona`,
+            );
+          }
+
+          // Wait for the user to approve the call if the policy requires it;
+          // a question already waits for the user and a rename preview
+          // changes nothing, so neither is gated
+          const ungated =
+            toolCall.function.name === "ask_user" ||
+            (toolCall.function.name === "rename_symbol" && !params.apply);
+          const denied = ungated
+            ? null
+            : await this.approvals.check(
+                toolCall.function.name,
+                params,
+                await this.toolExecutor.plannedChanges(
+                  toolCall.function.name,
+                  params,
+                ),
+              );
+          if (denied) {
+            throw new Error(denied);
+          }
+
           const execution = await this.toolExecutor.execute(
             toolCall.function.name,
             params,
@@ -123,7 +510,7 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
           // Add tool result message
           const toolMessage = createMessage(
             "tool",
-            JSON.stringify(execution.output),
+            this.resultContent(execution),
             {
               toolResults: [
                 {
@@ -132,6 +519,10 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
                   error: execution.error,
                 },
               ],
+              timing: {
+                startTime: execution.startTime,
+                endTime: execution.endTime || Date.now(),
+              },
             },
           );
 
@@ -158,15 +549,14 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
         }
       }
       // Get final response from Groq
-      const finalResponse = await this.groq.complete(
-        this.conversation.messages,
-        tools,
-      );
+      const finalStart = Date.now();
+      const finalResponse = await complete(true);
       const finalMessage = createMessage(
         "assistant",
         finalResponse.content || "",
         {
           tokens: finalResponse.tokens,
+          timing: { startTime: finalStart, endTime: Date.now() },
         },
       );
 
@@ -184,6 +574,7 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
       // No tool calls, just regular response
       const assistantMessage = createMessage("assistant", response.content, {
         tokens: response.tokens,
+        timing,
       });
 
       this.conversation.messages.push(assistantMessage);
@@ -197,6 +588,66 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
     }
   }
 
+  // One-off completion outside the conversation (no tools, nothing recorded)
+  // One-off completion, answered by another model (such as the critic)
+  // when its provider config is given
+  async completeOnce(
+    instructions: string,
+    content: string,
+    groq?: unknown,
+  ): Promise<string> {
+    const client = groq
+      ? new GroqClient(SessionConfig.shape.groq.parse(groq))
+      : this.groq;
+    const response = await client.complete([
+      createMessage("system", instructions),
+      createMessage("user", content),
+    ]);
+
+    this.conversation.totalTokens.input += response.tokens?.input || 0;
+    this.conversation.totalTokens.output += response.tokens?.output || 0;
+
+    return response.content || "";
+  }
+
+  async planMessage(
+    content: string,
+  ): Promise<{ content: string; toolCalls: ToolCall[] }> {
+    // Plan against a copy of the history; nothing is recorded or executed
+    const planMessages = [
+      ...this.contextMessages(),
+      createMessage(
+        "user",
+        `${content}
+
+(DRY RUN: nothing will be executed. Issue every tool call you would need to complete this request now, in order, with complete arguments, and briefly explain the plan.)`,
+      ),
+    ];
+
+    const response = await this.groq.complete(
+      planMessages,
+      this.availableTools(),
+      undefined,
+      this.requestShaping(),
+    );
+
+    this.conversation.totalTokens.input += response.tokens?.input || 0;
+    this.conversation.totalTokens.output += response.tokens?.output || 0;
+
+    return {
+      content: response.content || "",
+      toolCalls: (response.toolCalls || []).map((call) => {
+        let parameters: Record<string, any>;
+        try {
+          parameters = JSON.parse(call.function.arguments);
+        } catch {
+          parameters = { raw: call.function.arguments };
+        }
+        return { id: call.id, name: call.function.name, parameters };
+      }),
+    };
+  }
+
   async *streamMessage(
     content: string,
   ): AsyncGenerator<string, Message, unknown> {
@@ -206,12 +657,23 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
 
     let assistantContent = "";
 
-    // Stream response from Groq
-    const stream = await this.groq.stream(this.conversation.messages);
+    // Stream response from Groq (resumed by the client if the connection drops)
+    const stream = await this.groq.stream(this.contextMessages());
 
-    for await (const chunk of stream) {
-      assistantContent += chunk;
-      yield chunk;
+    try {
+      for await (const chunk of stream) {
+        assistantContent += chunk;
+        yield chunk;
+      }
+    } catch (error) {
+      // Keep what arrived so the conversation shows the partial reply
+      if (assistantContent !== "") {
+        this.conversation.messages.push(
+          createMessage("assistant", assistantContent),
+        );
+        this.conversation.updatedAt = new Date().toISOString();
+      }
+      throw error;
     }
 
     // Create assistant message
@@ -225,13 +687,32 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
     return assistantMessage;
   }
 
+  // Run a tool the user asked for (e.g. /run), under the same approval
+  // policy as the model's calls. The call and its result are recorded as a
+  // tool call, so the next turn sees the output.
   async executeTool(name: string, params: any): Promise<any> {
+    this.approvals.startTurn();
+    const denied = await this.approvals.check(
+      name,
+      params,
+      await this.toolExecutor.plannedChanges(name, params),
+    );
+    if (denied) {
+      throw new Error(denied);
+    }
+
     const execution = await this.toolExecutor.execute(name, params);
 
+    this.conversation.messages.push(
+      createMessage("assistant", "", {
+        toolCalls: [{ id: execution.id, name, parameters: params }],
+      }),
+    );
+
     // Add tool result message to conversation
     const toolMessage = createMessage(
       "tool",
-      JSON.stringify(execution.output),
+      this.resultContent(execution),
       {
         toolResults: [
           {
@@ -240,6 +721,10 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
             error: execution.error,
           },
         ],
+        timing: {
+          startTime: execution.startTime,
+          endTime: execution.endTime || Date.now(),
+        },
       },
     );
 
@@ -249,14 +734,76 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
     return execution;
   }
 
+  getPendingApprovals(): PendingApproval[] {
+    return this.approvals.list();
+  }
+
+  resolveApproval(id: string, decision: ApprovalDecision): boolean {
+    return this.approvals.resolve(id, decision);
+  }
+
+  getPendingQuestions(): PendingQuestion[] {
+    return this.questions.list();
+  }
+
+  answerQuestion(id: string, answer: QuestionAnswer): boolean {
+    return this.questions.answer(id, answer);
+  }
+
   getConversation(): Conversation {
     return { ...this.conversation };
   }
 
+  // Take the client's tokenizer counts of messages, by id
+  setContextTokens(counts: Record<string, number>): void {
+    for (const msg of this.conversation.messages) {
+      const tokens = counts[msg.id];
+      if (Number.isInteger(tokens) && tokens >= 0) {
+        msg.contextTokens = tokens;
+      }
+    }
+  }
+
+  // Replace a message's content with a placeholder so it stops filling the
+  // context; the message stays so tool calls keep a matching result
+  dropMessage(id: string): boolean {
+    const message = this.conversation.messages.find((msg) => msg.id === id);
+    if (!message || message.role === "system") {
+      return false;
+    }
+
+    const note = `[removed from history by the user; was ${message.content.length} characters]`;
+    message.content = message.role === "tool" ? JSON.stringify({ note }) : note;
+    delete message.contextTokens;
+    message.toolResults = message.toolResults?.map((result) => ({
+      ...result,
+      result: note,
+    }));
+    this.conversation.updatedAt = new Date().toISOString();
+    return true;
+  }
+
   getAvailableTools(): string[] {
     return this.toolExecutor.getTools();
   }
 
+  // What the next message would be sent with, before the new user message
+  previewRequest(): {
+    model: string;
+    temperature: number;
+    messages: Message[];
+    tools: GroqAITool[];
+    omitted: number;
+  } {
+    const messages = this.contextMessages();
+    return {
+      ...this.groq.getSettings(),
+      messages,
+      tools: this.availableTools(),
+      omitted: this.conversation.messages.length - messages.length,
+    };
+  }
+
   getTokenUsage(): { input: number; output: number; total: number } {
     const { input, output } = this.conversation.totalTokens;
     return { input, output, total: input + output };
@@ -264,5 +811,6 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
 
   clear(): void {
     this.conversation = createConversation();
+    this.conversation.messages.push(this.systemMessage);
   }
 }
@@ -0,0 +1,215 @@
+// Workspace overview for the project_stats tool: lines by language, TODOs,
+// tests and coverage reports, the largest files, and dependency counts
+
+import { existsSync, statSync } from "node:fs";
+import path from "node:path";
+import { formatSize } from "./output";
+import { listFiles, MAX_FILES, readText } from "./search";
+
+// Languages by file extension; other files count toward files only
+const LANGUAGES: Record<string, string> = {
+  ".go": "Go",
+  ".ts": "TypeScript",
+  ".tsx": "TypeScript",
+  ".js": "JavaScript",
+  ".jsx": "JavaScript",
+  ".mjs": "JavaScript",
+  ".cjs": "JavaScript",
+  ".py": "Python",
+  ".rs": "Rust",
+  ".java": "Java",
+  ".kt": "Kotlin",
+  ".rb": "Ruby",
+  ".php": "PHP",
+  ".c": "C",
+  ".h": "C",
+  ".cc": "C++",
+  ".cpp": "C++",
+  ".hpp": "C++",
+  ".cs": "C#",
+  ".swift": "Swift",
+  ".scala": "Scala",
+  ".dart": "Dart",
+  ".lua": "Lua",
+  ".ex": "Elixir",
+  ".exs": "Elixir",
+  ".zig": "Zig",
+  ".vue": "Vue",
+  ".svelte": "Svelte",
+  ".sh": "Shell",
+  ".bash": "Shell",
+  ".sql": "SQL",
+  ".html": "HTML",
+  ".css": "CSS",
+  ".scss": "CSS",
+  ".md": "Markdown",
+  ".json": "JSON",
+  ".yaml": "YAML",
+  ".yml": "YAML",
+  ".toml": "TOML",
+};
+
+// Test files by name, across the common conventions
+const TEST_FILE =
+  /(_test\.go|\.(test|spec)\.[cm]?[jt]sx?|^test_.*\.py|_test\.py|Tests?\.(java|kt|cs|swift)|_spec\.rb)$/;
+
+// Reports coverage tools leave behind, looked for in the top directory
+const COVERAGE_FILES = [
+  "coverage",
+  "coverage.out",
+  "cover.out",
+  "coverage.txt",
+  "coverage.xml",
+  "lcov.info",
+  ".coverage",
+  "htmlcov",
+  ".nyc_output",
+];
+
+const TODO = /\b(TODO|FIXME|HACK|XXX)\b/;
+const MAX_TODOS_LISTED = 10;
+
+export interface ProjectStats {
+  files: number;
+  languages: { language: string; files: number; lines: number }[];
+  todos: { count: number; first: string[] };
+  tests: { files: number; coverageReports: string[] };
+  largestFiles: { path: string; size: string; lines?: number }[];
+  dependencies: { manifest: string; direct: number; dev?: number; indirect?: number }[];
+  note?: string;
+}
+
+// Count the dependencies a manifest declares; null for files that aren't one
+function countDependencies(
+  name: string,
+  text: string,
+): { direct: number; dev?: number; indirect?: number } | null {
+  if (name === "package.json" || name === "composer.json") {
+    try {
+      const manifest = JSON.parse(text);
+      const dev = name === "package.json" ? "devDependencies" : "require-dev";
+      const direct = name === "package.json" ? "dependencies" : "require";
+      return {
+        direct: Object.keys(manifest[direct] ?? {}).length,
+        dev: Object.keys(manifest[dev] ?? {}).length,
+      };
+    } catch {
+      return null;
+    }
+  }
+  if (name === "go.mod") {
+    // Both "require x v1" and the lines of a require ( ... ) block
+    const requires = [...text.matchAll(/^\s*(?:require\s+)?[\w.-]+\.[\w./-]+\s+v\S+.*$/gm)];
+    const indirect = requires.filter((line) => line[0].includes("// indirect")).length;
+    return { direct: requires.length - indirect, indirect };
+  }
+  if (/^requirements.*\.txt$/.test(name)) {
+    const lines = text
+      .split("\n")
+      .map((line) => line.trim())
+      .filter((line) => line && !line.startsWith("#") && !line.startsWith("-"));
+    return { direct: lines.length };
+  }
+  if (name === "Cargo.toml" || name === "pyproject.toml") {
+    // Keys of [dependencies]-style tables, or the entries of dependencies = [...]
+    const count = (table: RegExp) => {
+      let n = 0;
+      let inside = false;
+      for (const line of text.split("\n")) {
+        const trimmed = line.trim();
+        if (trimmed.startsWith("[")) {
+          inside = table.test(trimmed);
+          continue;
+        }
+        if (inside && /^[\w"'-]+\s*=/.test(trimmed)) n++;
+      }
+      return n;
+    };
+    if (name === "Cargo.toml") {
+      return {
+        direct: count(/^\[(dependencies|build-dependencies)\]$/),
+        dev: count(/^\[dev-dependencies\]$/),
+      };
+    }
+    const list = text.match(/^dependencies\s*=\s*\[([\s\S]*?)\]/m);
+    const listed = list ? (list[1].match(/["'][^"']+["']/g) ?? []).length : 0;
+    return { direct: listed + count(/^\[tool\.poetry\.dependencies\]$/) };
+  }
+  if (name === "Gemfile") {
+    return { direct: (text.match(/^\s*gem\s+["']/gm) ?? []).length };
+  }
+  return null;
+}
+
+// Stats for the files under dir; paths are relative to root
+export function projectStats(dir: string, root: string, top: number): ProjectStats {
+  const files = listFiles(dir, null);
+  const relative = (file: string) => path.relative(root, file).split(path.sep).join("/");
+
+  const languages = new Map<string, { files: number; lines: number }>();
+  const todos: string[] = [];
+  let todoCount = 0;
+  let testFiles = 0;
+  const sizes: { file: string; size: number; lines?: number }[] = [];
+  const dependencies: ProjectStats["dependencies"] = [];
+
+  for (const file of files) {
+    const name = path.basename(file);
+    let size = 0;
+    try {
+      size = statSync(file).size;
+    } catch {
+      continue;
+    }
+    if (TEST_FILE.test(name)) testFiles++;
+
+    const text = readText(file);
+    let lines: number | undefined;
+    if (text !== null) {
+      const all = text.split(/\r?\n/);
+      lines = all.filter((line) => line.trim() !== "").length;
+      const language = LANGUAGES[path.extname(name).toLowerCase()];
+      if (language) {
+        const entry = languages.get(language) ?? { files: 0, lines: 0 };
+        entry.files++;
+        entry.lines += lines;
+        languages.set(language, entry);
+      }
+      all.forEach((line, index) => {
+        if (!TODO.test(line)) return;
+        todoCount++;
+        if (todos.length < MAX_TODOS_LISTED) {
+          todos.push(`${relative(file)}:${index + 1}: ${line.trim().slice(0, 120)}`);
+        }
+      });
+      const declared = countDependencies(name, text);
+      if (declared) {
+        dependencies.push({ manifest: relative(file), ...declared });
+      }
+    }
+    sizes.push({ file, size, lines });
+  }
+
+  sizes.sort((a, b) => b.size - a.size);
+  return {
+    files: files.length,
+    languages: [...languages.entries()]
+      .map(([language, entry]) => ({ language, ...entry }))
+      .sort((a, b) => b.lines - a.lines),
+    todos: { count: todoCount, first: todos },
+    tests: {
+      files: testFiles,
+      coverageReports: COVERAGE_FILES.filter((name) => existsSync(path.join(dir, name))),
+    },
+    largestFiles: sizes.slice(0, top).map(({ file, size, lines }) => ({
+      path: relative(file),
+      size: formatSize(size),
+      lines,
+    })),
+    dependencies,
+    note:
+      files.length >= MAX_FILES
+        ? `Only the first ${MAX_FILES} files were counted; pass a subdirectory as path for exact numbers.`
+        : undefined,
+  };
+}
@@ -1,4 +1,15 @@
 import { z } from "zod";
+import { resolveToolPath } from "./paths";
+import {
+  formatSize,
+  readCapped,
+  saveJobOutput,
+  summarizeOutput,
+} from "./output";
+import { applyHunks, parsePatch } from "./patch";
+import { searchText } from "./search";
+import { projectStats } from "./stats";
+import { closeSync, openSync, readSync, unlinkSync } from "node:fs";
 
 //  Simple Zod to JSON schema converter
 function zodToJsonSchema(schema: z.ZodTypeAny): any {
@@ -75,11 +86,28 @@ export const ToolExecution = z.object({
 });
 export type ToolExecution = z.infer<typeof ToolExecution>;
 
+// Limits on one tool: commands are stopped after timeoutMs, and output (or a
+// file's content) past maxOutputBytes is cut before the model sees it
+export const ToolLimit = z.object({
+  timeoutMs: z.number().int().positive().optional(),
+  maxOutputBytes: z.number().int().positive().optional(),
+});
+export type ToolLimit = z.infer<typeof ToolLimit>;
+
+export const DEFAULT_TOOL_LIMITS: Record<string, ToolLimit> = {
+  bash: { timeoutMs: 120_000, maxOutputBytes: 200 * 1024 },
+  readFile: { maxOutputBytes: 1024 * 1024 },
+};
+
 export interface Tool {
   name: string;
   description: string;
   parameters: z.ZodSchema;
-  execute: (params: any) => Promise<any>;
+  execute: (params: any, limit: ToolLimit) => Promise<any>;
+  limitsFrom?: string; // Tool whose limits apply where this one sets none
+  // Lines a call would change per file, for tools whose changes can't be
+  // read from their parameters; null when it changes nothing
+  plannedChanges?: (params: any) => Promise<Map<string, number> | null>;
 }
 
 export interface GroqAITool {
@@ -98,11 +126,35 @@ export interface GroqAITool {
 export class ToolExecutor {
   private tools = new Map<string, Tool>();
   private executions = new Map<string, ToolExecution>();
+  private limits: Record<string, ToolLimit> = {};
 
   registerTool(tool: Tool): void {
     this.tools.set(tool.name, tool);
   }
 
+  unregisterTool(name: string): void {
+    this.tools.delete(name);
+  }
+
+  // Replace the configured limits; tools not named keep their defaults
+  setLimits(limits?: Record<string, ToolLimit>): void {
+    this.limits = limits ?? {};
+  }
+
+  limitFor(name: string): ToolLimit {
+    const tool = this.tools.get(name);
+    const inherited = tool?.limitsFrom ? this.limitFor(tool.limitsFrom) : {};
+    return { ...inherited, ...DEFAULT_TOOL_LIMITS[name], ...this.limits[name] };
+  }
+
+  // Lines a call would change per file, when its tool can tell in advance
+  async plannedChanges(
+    name: string,
+    params: any,
+  ): Promise<Map<string, number> | null> {
+    return (await this.tools.get(name)?.plannedChanges?.(params)) ?? null;
+  }
+
   async execute(name: string, params: any): Promise<ToolExecution> {
     const tool = this.tools.get(name);
     if (!tool) {
@@ -122,7 +174,7 @@ export class ToolExecutor {
     try {
       execution.state = "running";
       const validatedParams = tool.parameters.parse(params);
-      const result = await tool.execute(validatedParams);
+      const result = await tool.execute(validatedParams, this.limitFor(name));
 
       execution.state = "completed";
       execution.output = result;
@@ -156,6 +208,86 @@ export class ToolExecutor {
   }
 }
 
+// Variables the user set for this session's commands (/env in the client)
+let commandEnv: Record<string, string> = {};
+
+export function setCommandEnv(env?: Record<string, string>): void {
+  commandEnv = env ?? {};
+}
+
+// Run a shell command and return its output, summarizing huge outputs. The
+// command is killed at the tool's timeout, and only the start and end of
+// output past its size limit are kept.
+async function runCommand(
+  command: string,
+  limit: ToolLimit,
+  env?: Record<string, string>,
+) {
+  const vars = { ...commandEnv, ...env };
+  const proc = Bun.spawn(["bash", "-c", command], {
+    env: Object.keys(vars).length ? { ...process.env, ...vars } : undefined,
+  });
+  const stopped = new AbortController();
+  let timedOut = false;
+  const timer = limit.timeoutMs
+    ? setTimeout(() => {
+        timedOut = true;
+        proc.kill("SIGKILL");
+        stopped.abort();
+      }, limit.timeoutMs)
+    : undefined;
+  const [out, err] = await Promise.all([
+    readCapped(proc.stdout, limit.maxOutputBytes, stopped.signal),
+    readCapped(proc.stderr, limit.maxOutputBytes, stopped.signal),
+  ]);
+  await proc.exited;
+  clearTimeout(timer);
+  const output = out.text.trim();
+  const error = err.text.trim();
+
+  const notes: string[] = [];
+  if (timedOut) {
+    notes.push(
+      `The command was killed after ${limit.timeoutMs! / 1000}s, its time limit; the output is what it printed until then. Run long commands in the background with their output redirected to a file, or ask the user to raise the limit.`,
+    );
+  }
+  if (out.dropped > 0 || err.dropped > 0) {
+    notes.push(
+      `Output past the ${formatSize(limit.maxOutputBytes!)} limit lost its middle, which was not kept; rerun with a narrower command (grep, head, tail) if you need it.`,
+    );
+  }
+
+  const stdout = summarizeOutput(output);
+  const stderr = summarizeOutput(error);
+  if (!stdout.truncated && !stderr.truncated) {
+    return {
+      output,
+      error: error || undefined,
+      exitCode: proc.exitCode,
+      timedOut: timedOut || undefined,
+      note: notes.length > 0 ? notes.join(" ") : undefined,
+    };
+  }
+
+  // Huge outputs are summarized; the full text stays on disk
+  const jobId = saveJobOutput(command, output, error, proc.exitCode);
+  notes.push(
+    jobId
+      ? `Output was summarized (head, tail, and failure lines kept). The user can view the full output with /job output ${jobId}; rerun with a narrower command (grep, head, tail) if you need more.`
+      : "Output was summarized (head, tail, and failure lines kept) and the full output was not kept; rerun with a narrower command (grep, head, tail) if you need more.",
+  );
+  return {
+    output: stdout.text,
+    error: stderr.text || undefined,
+    exitCode: proc.exitCode,
+    timedOut: timedOut || undefined,
+    truncated: true,
+    totalLines: stdout.totalLines,
+    jobId: jobId ?? undefined,
+    note: notes.join(" "),
+  };
+}
+
 // Built in tools
 export const bashTool: Tool = {
   name: "bash",
@@ -163,33 +295,132 @@ export const bashTool: Tool = {
   parameters: z.object({
     command: z.string(),
   }),
-  execute: async (params) => {
-    const proc = Bun.spawn(["bash", "-c", params.command]);
-    const output = await new Response(proc.stdout).text();
-    const error = await new Response(proc.stderr).text();
-
-    return {
-      output: output.trim(),
-      error: error.trim() || undefined,
-      exitCode: proc.exitCode,
-    };
-  },
+  execute: async (params, limit) => runCommand(params.command, limit),
 };
 
+// Tool defined by a project (.painika/tools.json) that runs a fixed command
+export const CustomTool = z.object({
+  name: z.string().regex(/^[A-Za-z_][A-Za-z0-9_-]*$/),
+  description: z.string(),
+  command: z.string(),
+  parameters: z.record(z.string()).default({}),
+});
+export type CustomTool = z.infer<typeof CustomTool>;
+
+// Arguments reach the command as environment variables, never spliced into it
+export function createCustomTool(definition: CustomTool): Tool {
+  const { name, command, parameters } = CustomTool.parse(definition);
+  const params = Object.keys(parameters);
+  const described = params.length
+    ? `${definition.description} (parameters: ${params.map((p) => `${p}: ${parameters[p]}`).join("; ")})`
+    : definition.description;
+
+  return {
+    name,
+    description: described,
+    parameters: z.object(
+      Object.fromEntries(params.map((p) => [p, z.string()])),
+    ),
+    execute: async (values, limit) => runCommand(command, limit, values),
+    limitsFrom: "bash",
+  };
+}
+
+// Lines a rename changes per file, from the client binary's preview
+// (painika rename --json)
+async function renamePlan(
+  binary: string,
+  params: { symbol: string; new_name: string; path?: string },
+): Promise<Map<string, number>> {
+  const proc = Bun.spawn(
+    [binary, "rename", "--json", "--", params.symbol, params.new_name, params.path ?? "."],
+    { env: { ...process.env, ...commandEnv }, stderr: "ignore" },
+  );
+  const output = (await new Response(proc.stdout).text()).trim();
+  await proc.exited;
+  const last = output.split("\n").pop() ?? "";
+  if (proc.exitCode !== 0 || !last.startsWith("{")) {
+    throw new Error(output || `painika rename exited with ${proc.exitCode}`);
+  }
+  const { files } = JSON.parse(last) as { files: Record<string, number> };
+  return new Map(Object.entries(files));
+}
+
+// Rename run by the client binary (painika rename), which picks gopls or
+// gofmt -r for Go files and a whole-word rename for the rest. Applying it
+// checks every file it changes against the write policy first, and the
+// approval gate counts those changes against the per-turn limits.
+export function createRenameSymbolTool(binary: string): Tool {
+  return {
+    name: "rename_symbol",
+    description:
+      "Rename a symbol (function, type, variable, ...) everywhere in the workspace or under a path. Call it without apply first to get every line it changes, then again with apply: true. Prefer it over editing each file for mechanical renames",
+    parameters: z.object({
+      symbol: z.string(),
+      new_name: z.string(),
+      path: z.string().optional(),
+      apply: z.boolean().default(false),
+    }),
+    execute: async (params, limit) => {
+      const path = params.path ?? ".";
+      resolveToolPath(path, params.apply ? "write" : "read");
+      if (params.apply) {
+        for (const file of (await renamePlan(binary, params)).keys()) {
+          resolveToolPath(file, "write");
+        }
+      }
+      return runCommand(
+        `"$RENAME_BINARY" rename $RENAME_APPLY -- "$RENAME_SYMBOL" "$RENAME_TO" "$RENAME_PATH"`,
+        limit,
+        {
+          RENAME_BINARY: binary,
+          RENAME_SYMBOL: params.symbol,
+          RENAME_TO: params.new_name,
+          RENAME_PATH: path,
+          RENAME_APPLY: params.apply ? "--apply" : "",
+        },
+      );
+    },
+    plannedChanges: async (params) =>
+      params.apply ? renamePlan(binary, params).catch(() => null) : null,
+    limitsFrom: "bash",
+  };
+}
+
 export const readFileTool: Tool = {
   name: "readFile",
   description: "Read a file from the filesystem",
   parameters: z.object({
     path: z.string(),
   }),
-  execute: async (params) => {
-    const file = Bun.file(params.path);
+  execute: async (params, limit) => {
+    const target = resolveToolPath(params.path, "read");
+    const file = Bun.file(target);
     const exists = await file.exists();
 
     if (!exists) {
       throw new Error(`File not found: ${params.path}`);
     }
 
+    // Only the start of a file over the limit is read
+    const max = limit.maxOutputBytes;
+    if (max && file.size > max) {
+      const buffer = Buffer.alloc(max);
+      const fd = openSync(target, "r");
+      let read;
+      try {
+        read = readSync(fd, buffer, 0, max, 0);
+      } finally {
+        closeSync(fd);
+      }
+      return {
+        content: buffer.subarray(0, read).toString("utf8"),
+        size: file.size,
+        truncated: true,
+        note: `The file is ${formatSize(file.size)}; only its first ${formatSize(max)} (the readFile limit) is shown. Read further with bash (sed -n '2000,2400p' <file>) or find the part you need with search_text.`,
+      };
+    }
+
     const content = await file.text();
     return {
       content,
@@ -206,7 +437,7 @@ export const writeFileTool: Tool = {
     content: z.string(),
   }),
   execute: async (params) => {
-    await Bun.write(params.path, params.content);
+    await Bun.write(resolveToolPath(params.path, "write"), params.content);
     return {
       path: params.path,
       size: params.content.length,
@@ -223,7 +454,8 @@ export const editFileTool: Tool = {
     newContent: z.string(),
   }),
   execute: async (params) => {
-    const file = Bun.file(params.path);
+    const target = resolveToolPath(params.path, "write");
+    const file = Bun.file(target);
     const exists = await file.exists();
 
     if (!exists) {
@@ -237,7 +469,7 @@ export const editFileTool: Tool = {
     }
 
     const newContent = content.replace(params.oldContent, params.newContent);
-    await Bun.write(params.path, newContent);
+    await Bun.write(target, newContent);
 
     return {
       path: params.path,
@@ -246,6 +478,73 @@ export const editFileTool: Tool = {
   },
 };
 
+export const applyPatchTool: Tool = {
+  name: "apply_patch",
+  description:
+    "Edit files by applying a unified diff (---/+++ headers, @@ hunks with a few context lines). Prefer this over writeFile for small changes to existing files. Hunks that do not match are rejected and reported; re-read the file and resend only those.",
+  parameters: z.object({
+    patch: z.string(),
+    path: z.string().optional(),
+  }),
+  execute: async (params) => {
+    const files = parsePatch(params.patch, params.path);
+    const results = [];
+    let rejected = 0;
+
+    for (const patch of files) {
+      const name = (patch.newPath ?? patch.oldPath) as string;
+      const source = patch.oldPath ? resolveToolPath(patch.oldPath, "write") : null;
+      const target = patch.newPath ? resolveToolPath(patch.newPath, "write") : null;
+
+      let content = "";
+      if (source) {
+        const file = Bun.file(source);
+        if (!(await file.exists())) {
+          throw new Error(`File not found: ${patch.oldPath}`);
+        }
+        content = await file.text();
+      }
+
+      const result = applyHunks(content, patch.hunks);
+      rejected += result.rejected.length;
+
+      // A file is only written when at least one hunk applied, or it is being deleted
+      if (target && (result.applied > 0 || patch.hunks.length === 0)) {
+        await Bun.write(target, result.content);
+      }
+      if (source && (!target || source !== target) && result.rejected.length === 0) {
+        unlinkSync(source);
+      }
+
+      results.push({
+        path: name,
+        created: patch.oldPath === null || undefined,
+        deleted: patch.newPath === null || undefined,
+        applied: result.applied,
+        fuzzy: result.fuzzy.length > 0 ? result.fuzzy : undefined,
+        rejected: result.rejected.length > 0 ? result.rejected : undefined,
+      });
+    }
+
+    if (rejected > 0 && results.every((r) => r.applied === 0)) {
+      throw new Error(
+        `No hunks applied: ${results
+          .flatMap((r) => (r.rejected ?? []).map((h) => `${r.path} ${h.hunk}: ${h.reason}`))
+          .join("; ")}`,
+      );
+    }
+
+    return {
+      files: results,
+      rejected,
+      note:
+        rejected > 0
+          ? "Rejected hunks were not applied. Read the file again and send a new patch for just those hunks."
+          : undefined,
+    };
+  },
+};
+
 export const makeDirTool: Tool = {
   name: "makeDir",
   description: "Create a directory (and parent directories if needed)",
@@ -254,8 +553,9 @@ export const makeDirTool: Tool = {
     recursive: z.boolean().default(true),
   }),
   execute: async (params) => {
+    const target = resolveToolPath(params.path, "write");
     const proc = Bun.spawn(
-      ["mkdir", params.recursive ? "-p" : "", params.path].filter(Boolean),
+      ["mkdir", params.recursive ? "-p" : "", target].filter(Boolean),
     );
     await proc.exited;
 
@@ -277,7 +577,7 @@ export const listFilesTool: Tool = {
     path: z.string().default("."),
   }),
   execute: async (params) => {
-    const proc = Bun.spawn(["ls", "-la", params.path]);
+    const proc = Bun.spawn(["ls", "-la", resolveToolPath(params.path, "read")]);
     const output = await new Response(proc.stdout).text();
 
     return {
@@ -287,6 +587,77 @@ export const listFilesTool: Tool = {
   },
 };
 
+export const searchTextTool: Tool = {
+  name: "search_text",
+  description:
+    "Search file contents in the workspace, faster and more portable than grep through bash. " +
+    "Returns the best-matching files first (BM25 ranking; definitions and exact phrases rank higher), " +
+    "each with its best lines as \"line: match\" and \"line- context\". " +
+    "query: words to find (any of them) or, with regex, a JavaScript regular expression; " +
+    "path: directory to search; glob: only files matching, e.g. \"*.go\" or \"src/**/*.ts\"; " +
+    "caseSensitive, contextLines (0-10), maxResults (files, 1-100). Skips vendored, build, binary, and large files.",
+  parameters: z.object({
+    query: z.string(),
+    regex: z.boolean().default(false),
+    path: z.string().default("."),
+    glob: z.string().optional(),
+    caseSensitive: z.boolean().default(false),
+    contextLines: z.number().int().min(0).max(10).default(2),
+    maxResults: z.number().int().min(1).max(100).default(20),
+  }),
+  execute: async (params) => {
+    const dir = resolveToolPath(params.path, "read");
+    const { results, searchedFiles, matchedFiles } = searchText(
+      dir,
+      resolveToolPath(".", "read"),
+      params,
+    );
+    return {
+      results,
+      searchedFiles,
+      matchedFiles,
+      note:
+        matchedFiles > results.length
+          ? `Showing the best ${results.length} of ${matchedFiles} matching files; narrow the query, path, or glob to see others.`
+          : undefined,
+    };
+  },
+};
+
+export const projectStatsTool: Tool = {
+  name: "project_stats",
+  description:
+    "Overview of the workspace in one call, instead of many shell commands: non-blank lines and files by language, " +
+    "TODO/FIXME counts with the first few locations, test files and coverage reports present, the largest files, " +
+    "and dependency counts from package.json, go.mod, Cargo.toml, requirements.txt, pyproject.toml, Gemfile, and composer.json. " +
+    "path: directory to describe; top: how many of the largest files to list (1-50). Skips vendored and build directories.",
+  parameters: z.object({
+    path: z.string().default("."),
+    top: z.number().int().min(1).max(50).default(10),
+  }),
+  execute: async (params) => {
+    return projectStats(
+      resolveToolPath(params.path, "read"),
+      resolveToolPath(".", "read"),
+      params.top,
+    );
+  },
+};
+
+export const rememberTool: Tool = {
+  name: "remember",
+  description:
+    "Save a durable fact about the user or project (tooling, conventions, preferences) so it is available in future sessions",
+  parameters: z.object({
+    fact: z.string(),
+  }),
+  execute: async (params) => {
+    return {
+      remembered: params.fact,
+    };
+  },
+};
+
 if (import.meta.main) {
   const executor = new ToolExecutor();
   executor.registerTool(bashTool);
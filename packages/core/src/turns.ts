@@ -0,0 +1,69 @@
+import type { Message } from "./messages";
+import type { Session } from "./session";
+
+// Event for the clients attached to a session (see /events)
+export interface TurnEvent {
+  type: "turn" | "message" | "idle";
+  client?: string;
+  clientId?: string;
+  content?: string;
+  message?: Message;
+}
+
+// Client a request came from, as named by its headers
+export interface TurnClient {
+  name: string;
+  id: string;
+}
+
+// Several clients can attach to one session, as when two people share a
+// `painika server`. Their turns run one at a time, in the order they
+// arrived, and every attached client hears what each turn added.
+export class TurnLock {
+  private tail: Promise<void> = Promise.resolve();
+  private listeners = new Set<(event: TurnEvent) => void>();
+
+  // Receive every event until the returned function is called
+  subscribe(listener: (event: TurnEvent) => void): () => void {
+    this.listeners.add(listener);
+    return () => this.listeners.delete(listener);
+  }
+
+  private emit(event: TurnEvent): void {
+    for (const listener of this.listeners) {
+      try {
+        listener(event);
+      } catch {
+        // A listener whose stream closed is removed when it cancels
+      }
+    }
+  }
+
+  // Run a turn once every earlier one has finished; returns the messages
+  // it added. They are broadcast when it ends, even if it failed partway.
+  async run(
+    session: Session,
+    client: TurnClient,
+    content: string,
+    turn: () => Promise<unknown>,
+  ): Promise<Message[]> {
+    const previous = this.tail;
+    let release!: () => void;
+    this.tail = new Promise((resolve) => (release = resolve));
+    await previous;
+
+    const who = { client: client.name, clientId: client.id };
+    const start = session.getConversation().messages.length;
+    this.emit({ type: "turn", ...who, content });
+    try {
+      await turn();
+      return session.getConversation().messages.slice(start);
+    } finally {
+      for (const message of session.getConversation().messages.slice(start)) {
+        this.emit({ type: "message", ...who, message });
+      }
+      this.emit({ type: "idle", ...who });
+      release();
+    }
+  }
+}
@@ -0,0 +1,3 @@
+module code-agent/server
+
+go 1.21
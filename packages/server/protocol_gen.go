@@ -0,0 +1,251 @@
+// Code generated by protogen from ../protocol/openapi.json; DO NOT EDIT.
+
+package server
+
+// Client/server protocol version; must match PROTOCOL_VERSION in the server
+const protocolVersion = 2
+
+// Tool call requested by the model
+type ToolCall struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// Result of a tool call
+type ToolResult struct {
+	ID     string      `json:"id"`
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Message timing, in Unix milliseconds
+type MessageTiming struct {
+	StartTime int64 `json:"startTime"`
+	EndTime   int64 `json:"endTime"`
+}
+
+// Input and output token counts
+type TokenCounts struct {
+	Input  int `json:"input"`
+	Output int `json:"output"`
+}
+
+// Conversation message
+type Message struct {
+	ID            string         `json:"id"`
+	Role          string         `json:"role"` // "system", "user", "assistant", or "tool"
+	Content       string         `json:"content"`
+	ToolCalls     []ToolCall     `json:"toolCalls,omitempty"`
+	ToolResults   []ToolResult   `json:"toolResults,omitempty"`
+	Timestamp     string         `json:"timestamp"` // ISO 8601 format
+	Tokens        *TokenCounts   `json:"tokens,omitempty"`
+	Timing        *MessageTiming `json:"timing,omitempty"`
+	Author        string         `json:"author,omitempty"`        // Client that sent a user message, when several share the session
+	ContextTokens int            `json:"contextTokens,omitempty"` // Tokens the client's tokenizer counted, used to trim the history
+}
+
+// Conversation with its messages and token totals
+type Conversation struct {
+	ID          string      `json:"id"`
+	Messages    []Message   `json:"messages"`
+	TotalTokens TokenCounts `json:"totalTokens"`
+	CreatedAt   string      `json:"createdAt"` // ISO 8601 format
+	UpdatedAt   string      `json:"updatedAt"` // ISO 8601 format
+}
+
+// Token usage for the session
+type TokenUsage struct {
+	Input  int `json:"input"`
+	Output int `json:"output"`
+	Total  int `json:"total"`
+}
+
+// Dry-run plan: the model's explanation and the tool calls it would make
+type Plan struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"toolCalls"`
+}
+
+// Tool call waiting for a decision
+type PendingApproval struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name"`
+	Parameters       map[string]interface{} `json:"parameters"`
+	Subject          string                 `json:"subject"` // Command or path that session patterns match
+	SuggestedPattern string                 `json:"suggestedPattern"`
+	GuardedBy        string                 `json:"guardedBy,omitempty"`   // Destructive command pattern the call matched; it must be typed back to run
+	ChangeLimit      string                 `json:"changeLimit,omitempty"` // Summary of the turn's file changes when the call would take them past the per-turn limits
+	CreatedAt        int64                  `json:"createdAt"`
+	ExpiresAt        int64                  `json:"expiresAt,omitempty"` // Absent when the approval never times out
+}
+
+// Clarifying question from the model with options to choose from
+type PendingQuestion struct {
+	ID         string   `json:"id"`
+	Question   string   `json:"question"`
+	Options    []string `json:"options"`
+	AllowOther bool     `json:"allowOther"` // Whether a free-text answer is accepted
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt,omitempty"` // Absent when the question never times out
+}
+
+// Answer to a question: an option index, free text, or skipped
+type QuestionAnswer struct {
+	Selected *int   `json:"selected,omitempty"` // 0-based option index
+	Text     string `json:"text,omitempty"`
+	Skipped  bool   `json:"skipped,omitempty"`
+}
+
+// Health check response; servers from before the handshake report no protocol version
+type HealthResponse struct {
+	Status          string `json:"status"`
+	ProtocolVersion int    `json:"protocolVersion,omitempty"`
+	HasSession      bool   `json:"hasSession"`
+	Authorized      bool   `json:"authorized,omitempty"` // Whether the request carried the server's token
+}
+
+// Written to SERVER_READY_FILE once the server is listening, so whoever started it learns where without reading its log
+type ServerReady struct {
+	Port            int    `json:"port,omitempty"`   // TCP port on 127.0.0.1; absent on a Unix domain socket
+	Socket          string `json:"socket,omitempty"` // Unix domain socket path; absent on a TCP port
+	Pid             int    `json:"pid"`
+	ProtocolVersion int    `json:"protocolVersion"`
+}
+
+// Response carrying only success or an error
+type StatusResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Session response structure
+type SessionResponse struct {
+	Success      bool               `json:"success"`
+	SessionID    string             `json:"sessionId"`
+	Capabilities *ModelCapabilities `json:"capabilities,omitempty"`
+	Attached     bool               `json:"attached,omitempty"` // Whether the client joined a session that was already open
+	Error        string             `json:"error,omitempty"`
+}
+
+// What a model supports; unsupported features are emulated or left out
+type ModelCapabilities struct {
+	Tools         bool `json:"tools"`
+	Vision        bool `json:"vision"`
+	Streaming     bool `json:"streaming"`
+	JSONMode      bool `json:"jsonMode"`
+	ToolChoice    bool `json:"toolChoice"` // Takes tool_choice and parallel_tool_calls
+	ContextTokens int  `json:"contextTokens"`
+}
+
+// Capabilities response structure
+type CapabilitiesResponse struct {
+	Success      bool               `json:"success"`
+	Capabilities *ModelCapabilities `json:"capabilities,omitempty"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// Chat response structure
+type ChatResponse struct {
+	Success        bool      `json:"success"`
+	Messages       []Message `json:"messages"`
+	Error          string    `json:"error,omitempty"`
+	ProviderStatus int       `json:"providerStatus,omitempty"` // HTTP status from the provider when it failed the request
+}
+
+// Server-sent event of a streamed turn: reply text, or the outcome of the turn
+type ChatStreamEvent struct {
+	Chunk          string    `json:"chunk,omitempty"` // Reply text generated since the last event; each model call's text ends with a newline
+	Success        bool      `json:"success,omitempty"`
+	Messages       []Message `json:"messages,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	ProviderStatus int       `json:"providerStatus,omitempty"` // HTTP status from the provider when it failed the request
+}
+
+// Server-sent event about the shared session: "turn" when a client's turn starts (with its message), "message" for each message the turn adds, "idle" when it ends
+type TurnEvent struct {
+	Type     string   `json:"type"`
+	Client   string   `json:"client,omitempty"`   // Name of the client whose turn it is
+	ClientID string   `json:"clientId,omitempty"` // X-Painika-Client-Id of that client, so it can skip its own turns
+	Content  string   `json:"content,omitempty"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+type PlanResponse struct {
+	Success bool   `json:"success"`
+	Plan    *Plan  `json:"plan,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type CompleteResponse struct {
+	Success bool   `json:"success"`
+	Content string `json:"content"`
+	Error   string `json:"error,omitempty"`
+}
+
+// One run of a tool
+type ToolExecution struct {
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	State  string      `json:"state"` // pending, running, completed, or error
+	Output interface{} `json:"output,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type ToolRunResponse struct {
+	Success   bool           `json:"success"`
+	Execution *ToolExecution `json:"execution,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type ApprovalsResponse struct {
+	Success   bool              `json:"success"`
+	Approvals []PendingApproval `json:"approvals"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type QuestionsResponse struct {
+	Success   bool              `json:"success"`
+	Questions []PendingQuestion `json:"questions"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type ConversationResponse struct {
+	Success      bool          `json:"success"`
+	Conversation *Conversation `json:"conversation,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+type TokensResponse struct {
+	Success bool        `json:"success"`
+	Usage   *TokenUsage `json:"usage,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// Tool name, description, and JSON Schema of its parameters
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// Tool definition as sent to the provider
+type ToolDefinition struct {
+	Type     string       `json:"type"` // Always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// What the next message would be sent with, before the new user message is added
+type RequestPreview struct {
+	Model       string           `json:"model"`
+	Temperature float64          `json:"temperature"`
+	Messages    []Message        `json:"messages"` // System prompt and the history inside the history window
+	Tools       []ToolDefinition `json:"tools"`
+	Omitted     int              `json:"omitted"` // Messages left out by the history window
+}
+
+type PreviewResponse struct {
+	Success bool            `json:"success"`
+	Preview *RequestPreview `json:"preview,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
@@ -0,0 +1,210 @@
+// Package server is painika's native server: the /health, /session,
+// /message, /conversation and /tokens endpoints, without the Bun bundle.
+// It talks to the model directly but runs no tools, keeps no history
+// window, and answers streamed turns whole, so the embedded JavaScript
+// server stays the default; `painika server --native` serves this one.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Headers carrying the server token and the name of the client
+const (
+	tokenHeader  = "X-Painika-Token"
+	clientHeader = "X-Painika-Client"
+)
+
+// Server holds the one session its clients share
+type Server struct {
+	token   string
+	hosts   []string // Host headers accepted, against DNS rebinding
+	origins []string // Browser origins accepted
+	http    *http.Client
+
+	mu      sync.Mutex
+	session *session
+	turn    sync.Mutex // One turn at a time, whoever sends it
+}
+
+// New creates a server whose clients must send token with every request
+// but /health, and that calls the model with client. SERVER_ALLOWED_HOSTS
+// and SERVER_ALLOWED_ORIGINS work as for the JavaScript server.
+func New(token string, client *http.Client) *Server {
+	return &Server{
+		token:   token,
+		hosts:   append([]string{"localhost", "127.0.0.1", "[::1]"}, splitList(os.Getenv("SERVER_ALLOWED_HOSTS"))...),
+		origins: splitList(os.Getenv("SERVER_ALLOWED_ORIGINS")),
+		http:    client,
+	}
+}
+
+// Handler routes the endpoints behind the host, origin, and token checks
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/session", s.handleSession)
+	mux.HandleFunc("/message", s.handleMessage)
+	mux.HandleFunc("/conversation", s.handleConversation)
+	mux.HandleFunc("/tokens", s.handleTokens)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+			if strings.Contains(h, ":") {
+				host = "[" + h + "]"
+			}
+		}
+		if !containsString(s.hosts, host) {
+			writeJSON(w, http.StatusForbidden, StatusResponse{Error: "Host not allowed"})
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !containsString(s.origins, strings.ToLower(origin)) {
+			writeJSON(w, http.StatusForbidden, StatusResponse{Error: "Origin not allowed"})
+			return
+		}
+		if r.URL.Path != "/health" && !s.authorized(r) {
+			writeJSON(w, http.StatusUnauthorized, StatusResponse{Error: "Missing or invalid server token"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	given := r.Header.Get(tokenHeader)
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+}
+
+// Open session, nil before the first POST /session
+func (s *Server) current() *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{
+		Status:          "ok",
+		ProtocolVersion: protocolVersion,
+		HasSession:      s.current() != nil,
+		Authorized:      s.authorized(r),
+	})
+}
+
+// POST starts a session (or joins the open one with "attach"); DELETE
+// clears its conversation
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var config sessionConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil || config.Provider.Model == "" {
+			writeJSON(w, http.StatusBadRequest, SessionResponse{Error: "Failed to initialize session"})
+			return
+		}
+		s.mu.Lock()
+		attached := config.Attach && s.session != nil
+		if !attached {
+			s.session = newSession(config)
+		}
+		id := s.session.id()
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, SessionResponse{Success: true, SessionID: id, Attached: attached})
+	case http.MethodDelete:
+		current := s.current()
+		if current == nil {
+			writeJSON(w, http.StatusBadRequest, StatusResponse{Error: "No active session"})
+			return
+		}
+		current.clear()
+		writeJSON(w, http.StatusOK, StatusResponse{Success: true})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Run a turn and return its messages, ending with the reply
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	current := s.current()
+	if current == nil {
+		writeJSON(w, http.StatusBadRequest, ChatResponse{Error: "No active session"})
+		return
+	}
+	var request struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, ChatResponse{Error: "Invalid message"})
+		return
+	}
+
+	s.turn.Lock()
+	defer s.turn.Unlock()
+	messages, err := current.send(r.Context(), s.http, request.Content, r.Header.Get(clientHeader))
+	if err != nil {
+		response := ChatResponse{Error: err.Error()}
+		if providerErr, ok := err.(*providerError); ok {
+			response.ProviderStatus = providerErr.status
+		}
+		writeJSON(w, http.StatusInternalServerError, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, ChatResponse{Success: true, Messages: messages})
+}
+
+func (s *Server) handleConversation(w http.ResponseWriter, r *http.Request) {
+	current := s.current()
+	if current == nil {
+		writeJSON(w, http.StatusBadRequest, ConversationResponse{Error: "No active session"})
+		return
+	}
+	conversation := current.conversation()
+	writeJSON(w, http.StatusOK, ConversationResponse{Success: true, Conversation: &conversation})
+}
+
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	current := s.current()
+	if current == nil {
+		writeJSON(w, http.StatusBadRequest, TokensResponse{Error: "No active session"})
+		return
+	}
+	usage := current.usage()
+	writeJSON(w, http.StatusOK, TokensResponse{Success: true, Usage: &usage})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Lowercased, non-empty items of a comma-separated list
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func request(t *testing.T, handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHealthNeedsNoToken(t *testing.T) {
+	handler := New("secret", http.DefaultClient).Handler()
+
+	rec := request(t, handler, http.MethodGet, "/health", "", "")
+	var health HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || health.Status != "ok" || health.Authorized {
+		t.Fatalf("unexpected health: %d %+v", rec.Code, health)
+	}
+
+	if rec := request(t, handler, http.MethodGet, "/tokens", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", rec.Code)
+	}
+}
+
+func TestRefusesOtherHosts(t *testing.T) {
+	handler := New("secret", http.DefaultClient).Handler()
+	req := httptest.NewRequest(http.MethodGet, "http://evil.example/health", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another host, got %d", rec.Code)
+	}
+}
+
+func TestMessageAsksTheModel(t *testing.T) {
+	var sent struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer gsk_test" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"choices":[{"message":{"content":"pong"}}],"usage":{"prompt_tokens":12,"completion_tokens":1}}`))
+	}))
+	defer provider.Close()
+
+	handler := New("secret", provider.Client()).Handler()
+	config, _ := json.Marshal(map[string]interface{}{
+		"groq":          map[string]string{"token": "gsk_test", "model": "test-model", "baseURL": provider.URL},
+		"systemContext": "Project notes",
+	})
+	if rec := request(t, handler, http.MethodPost, "/session", "secret", string(config)); rec.Code != http.StatusOK {
+		t.Fatalf("session: %d %s", rec.Code, rec.Body)
+	}
+
+	rec := request(t, handler, http.MethodPost, "/message", "secret", `{"content":"ping"}`)
+	var chat ChatResponse
+	if err := json.NewDecoder(rec.Body).Decode(&chat); err != nil {
+		t.Fatal(err)
+	}
+	if !chat.Success || len(chat.Messages) != 2 || chat.Messages[1].Content != "pong" {
+		t.Fatalf("unexpected reply: %+v", chat)
+	}
+	if sent.Model != "test-model" || len(sent.Messages) != 2 || !strings.Contains(sent.Messages[0].Content, "Project notes") {
+		t.Fatalf("unexpected provider request: %+v", sent)
+	}
+
+	rec = request(t, handler, http.MethodGet, "/tokens", "secret", "")
+	var tokens TokensResponse
+	json.NewDecoder(rec.Body).Decode(&tokens)
+	if tokens.Usage == nil || tokens.Usage.Total != 13 {
+		t.Fatalf("unexpected usage: %+v", tokens.Usage)
+	}
+
+	if rec := request(t, handler, http.MethodDelete, "/session", "secret", ""); rec.Code != http.StatusOK {
+		t.Fatalf("clear: %d", rec.Code)
+	}
+	rec = request(t, handler, http.MethodGet, "/conversation", "secret", "")
+	var conv ConversationResponse
+	json.NewDecoder(rec.Body).Decode(&conv)
+	if conv.Conversation == nil || len(conv.Conversation.Messages) != 1 {
+		t.Fatalf("expected only the system prompt after clearing: %+v", conv.Conversation)
+	}
+}
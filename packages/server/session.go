@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prompt every session starts with; the client's project instructions and
+// git context follow it
+const systemPrompt = `You are painika, a coding assistant working in the user's terminal.
+This server runs no tools: you cannot read or change files or run commands, so work from what the conversation shows and say when you need the user to paste something.`
+
+// Fields of POST /session this server uses; the rest (tools, approvals,
+// file access) only matter to a server that runs tools
+type sessionConfig struct {
+	Provider struct {
+		Token       string   `json:"token"`
+		Model       string   `json:"model"`
+		BaseURL     string   `json:"baseURL"`
+		Temperature *float64 `json:"temperature"`
+	} `json:"groq"`
+	SystemContext string        `json:"systemContext"`
+	GitContext    string        `json:"gitContext"`
+	Restore       *Conversation `json:"restore"`
+	Attach        bool          `json:"attach"`
+}
+
+// One conversation with the model
+type session struct {
+	config sessionConfig
+
+	mu   sync.Mutex
+	conv Conversation
+}
+
+func newSession(config sessionConfig) *session {
+	if config.Provider.BaseURL == "" {
+		config.Provider.BaseURL = "https://api.groq.com/openai"
+	}
+	s := &session{config: config}
+	if config.Restore != nil {
+		s.conv = *config.Restore
+		return s
+	}
+
+	prompt := systemPrompt
+	for _, extra := range []string{config.SystemContext, config.GitContext} {
+		if extra != "" {
+			prompt += "\n\n" + extra
+		}
+	}
+	now := timestamp()
+	s.conv = Conversation{
+		ID:        newID(),
+		Messages:  []Message{newMessage("system", prompt)},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return s
+}
+
+func (s *session) id() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conv.ID
+}
+
+// Copy of the conversation, safe to encode while a turn runs
+func (s *session) conversation() Conversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv := s.conv
+	conv.Messages = append([]Message(nil), s.conv.Messages...)
+	return conv
+}
+
+func (s *session) usage() TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := s.conv.TotalTokens
+	return TokenUsage{Input: total.Input, Output: total.Output, Total: total.Input + total.Output}
+}
+
+// Forget everything but the system prompt
+func (s *session) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []Message
+	for _, msg := range s.conv.Messages {
+		if msg.Role == "system" {
+			kept = append(kept, msg)
+		}
+	}
+	s.conv.Messages = kept
+	s.conv.TotalTokens = TokenCounts{}
+	s.conv.UpdatedAt = timestamp()
+}
+
+// Add the user's message, ask the model, and return both messages
+func (s *session) send(ctx context.Context, client *http.Client, content, author string) ([]Message, error) {
+	user := newMessage("user", content)
+	user.Author = author
+	s.mu.Lock()
+	s.conv.Messages = append(s.conv.Messages, user)
+	history := append([]Message(nil), s.conv.Messages...)
+	s.mu.Unlock()
+
+	start := time.Now()
+	reply, usage, err := s.complete(ctx, client, history)
+	if err != nil {
+		return nil, err
+	}
+	assistant := newMessage("assistant", reply)
+	assistant.Tokens = &usage
+	assistant.Timing = &MessageTiming{StartTime: start.UnixMilli(), EndTime: time.Now().UnixMilli()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conv.Messages = append(s.conv.Messages, assistant)
+	s.conv.TotalTokens.Input += usage.Input
+	s.conv.TotalTokens.Output += usage.Output
+	s.conv.UpdatedAt = timestamp()
+	return []Message{user, assistant}, nil
+}
+
+// Failure reported by the model's API
+type providerError struct {
+	status  int
+	message string
+}
+
+func (e *providerError) Error() string {
+	return e.message
+}
+
+// Call the OpenAI-compatible chat completions API of Groq or Ollama
+func (s *session) complete(ctx context.Context, client *http.Client, history []Message) (string, TokenCounts, error) {
+	type chatMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	request := struct {
+		Model       string        `json:"model"`
+		Messages    []chatMessage `json:"messages"`
+		Temperature *float64      `json:"temperature,omitempty"`
+	}{Model: s.config.Provider.Model, Temperature: s.config.Provider.Temperature}
+	for _, msg := range history {
+		// Tool calls and results come from another server; their text is all this one can pass on
+		role := msg.Role
+		if role == "tool" {
+			role = "user"
+		}
+		request.Messages = append(request.Messages, chatMessage{Role: role, Content: msg.Content})
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", TokenCounts{}, err
+	}
+	endpoint := strings.TrimSuffix(s.config.Provider.BaseURL, "/") + "/v1/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", TokenCounts{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Provider.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Provider.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", TokenCounts{}, fmt.Errorf("provider request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return "", TokenCounts{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", TokenCounts{}, &providerError{
+			status:  resp.StatusCode,
+			message: fmt.Sprintf("Provider API error: %d %s", resp.StatusCode, strings.TrimSpace(string(data))),
+		}
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", TokenCounts{}, fmt.Errorf("invalid provider response: %v", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", TokenCounts{}, fmt.Errorf("provider returned no reply")
+	}
+	var usage TokenCounts
+	if result.Usage != nil {
+		usage = TokenCounts{Input: result.Usage.PromptTokens, Output: result.Usage.CompletionTokens}
+	}
+	return result.Choices[0].Message.Content, usage, nil
+}
+
+func newMessage(role, content string) Message {
+	return Message{ID: newID(), Role: role, Content: content, Timestamp: timestamp()}
+}
+
+// Random UUID (version 4), as the JavaScript server's ids
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Current time in the ISO 8601 form JavaScript's toISOString writes
+func timestamp() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+}
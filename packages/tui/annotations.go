@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels with their own icon; any other first word is part of the note
+var annotationLabels = map[string]string{
+	"useful":    "⭐",
+	"wrong":     "❌",
+	"follow-up": "📌",
+	"decision":  "🧭",
+}
+
+// Annotation structure for flagged messages
+type Annotation struct {
+	ConversationID string `json:"conversationId"`
+	MessageID      string `json:"messageId"`
+	Index          int    `json:"index"` // 1-based position in the conversation
+	Label          string `json:"label"`
+	Note           string `json:"note,omitempty"`
+	CreatedAt      string `json:"createdAt"` // ISO 8601 format
+}
+
+// Icon for an annotation label
+func (a Annotation) Icon() string {
+	if icon, ok := annotationLabels[a.Label]; ok {
+		return icon
+	}
+	return "🚩"
+}
+
+// Get the path of the annotation store
+func annotationsPath() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "annotations.json"), nil
+}
+
+// Load all annotations
+func loadAnnotations() ([]Annotation, error) {
+	path, err := annotationsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var annotations []Annotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return annotations, nil
+}
+
+// Save all annotations
+func saveAnnotations(annotations []Annotation) error {
+	path, err := annotationsPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(annotations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Get annotations for one conversation, keyed by message ID
+func annotationsFor(conversationID string) map[string][]Annotation {
+	annotations, _ := loadAnnotations()
+
+	byMessage := make(map[string][]Annotation)
+	for _, a := range annotations {
+		if a.ConversationID == conversationID {
+			byMessage[a.MessageID] = append(byMessage[a.MessageID], a)
+		}
+	}
+	return byMessage
+}
+
+// Handle /flag <n> [useful|wrong|follow-up|decision] [note]
+func flagMessage(client *Client, args string) {
+	indexStr, rest, _ := strings.Cut(args, " ")
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		fmt.Println("Usage: /flag <n> [useful|wrong|follow-up|decision] [note]")
+		fmt.Println()
+		return
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+	if index < 1 || index > len(conversation.Messages) {
+		fmt.Printf("❌ No message #%d (see 'history' for numbers)\n\n", index)
+		return
+	}
+	msg := conversation.Messages[index-1]
+
+	label, note := "flag", strings.TrimSpace(rest)
+	first, remainder, _ := strings.Cut(note, " ")
+	if _, ok := annotationLabels[strings.ToLower(first)]; ok {
+		label, note = strings.ToLower(first), strings.TrimSpace(remainder)
+	}
+
+	annotations, err := loadAnnotations()
+	if err != nil {
+		fmt.Printf("❌ Error loading annotations: %v\n\n", err)
+		return
+	}
+
+	annotation := Annotation{
+		ConversationID: conversation.ID,
+		MessageID:      msg.ID,
+		Index:          index,
+		Label:          label,
+		Note:           note,
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := saveAnnotations(append(annotations, annotation)); err != nil {
+		fmt.Printf("❌ Error saving annotation: %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("%s Flagged message #%d as %s\n", annotation.Icon(), index, label)
+	fmt.Println()
+}
+
+// Handle /flags [label] - list annotations in this conversation
+func showFlags(client *Client, filter string) {
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+
+	byMessage := annotationsFor(conversation.ID)
+	filter = strings.ToLower(filter)
+
+	fmt.Println("🚩 Flagged Messages:")
+	found := 0
+	for i, msg := range conversation.Messages {
+		for _, a := range byMessage[msg.ID] {
+			if filter != "" && a.Label != filter {
+				continue
+			}
+			found++
+			fmt.Printf("   #%d %s %s", i+1, a.Icon(), a.Label)
+			if a.Note != "" {
+				fmt.Printf(" - %s", a.Note)
+			}
+			fmt.Printf("\n      %s\n", truncateWidth(msg.Content, termWidth()-8))
+		}
+	}
+
+	if found == 0 {
+		fmt.Println("   Nothing flagged yet. Use /flag <n> [label] [note]")
+	}
+	fmt.Println()
+}
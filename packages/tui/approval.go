@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Tool approval policy enforced by the server before running tools
+type ApprovalPolicy struct {
+	Tools         []string `json:"tools"`         // Tool names that need approval ("*" for all)
+	TimeoutMs     int      `json:"timeoutMs"`     // 0 waits forever
+	DefaultAction string   `json:"defaultAction"` // "deny" or "approve" when the timeout expires
+
+	// Extra bash command patterns (regular expressions) that must be typed back to run,
+	// on top of the server's defaults (rm -rf, git push --force, DROP TABLE, ...)
+	GuardedCommands []string `json:"guardedCommands,omitempty"`
+
+	// Lines (added plus removed) and files the file tools may change in one turn
+	// before the user is asked whether the turn goes on; 0 for no limit
+	MaxLinesPerTurn int `json:"maxLinesPerTurn"`
+	MaxFilesPerTurn int `json:"maxFilesPerTurn"`
+}
+
+// Per-turn change limits when neither the environment nor a config file sets them
+const (
+	defaultMaxLinesPerTurn = 500
+	defaultMaxFilesPerTurn = 20
+)
+
+// Set while a confirmation prompt owns the terminal
+var promptActive atomic.Bool
+
+// Load the approval policy from the environment.
+// APPROVE_TOOLS lists tools to confirm ("all" for every tool), falling back to the
+// configured approve_tools; empty disables approvals except for guarded commands.
+// GUARDED_COMMANDS and guarded_commands add destructive command patterns to the server's defaults.
+// MAX_LINES_PER_TURN and MAX_FILES_PER_TURN (or max_lines_per_turn and max_files_per_turn) cap
+// how much the file tools change in one turn without asking.
+func approvalConfig(user UserConfig) (ApprovalPolicy, error) {
+	tools := splitList(getEnv("APPROVE_TOOLS", strings.Join(user.ApproveTools, ",")))
+	for i, tool := range tools {
+		if strings.EqualFold(tool, "all") {
+			tools[i] = "*"
+		}
+	}
+	tools = offlineApprovalTools(tools, nil)
+
+	timeout, err := strconv.Atoi(getEnv("APPROVAL_TIMEOUT", "60"))
+	if err != nil || timeout < 0 {
+		return ApprovalPolicy{}, fmt.Errorf("invalid APPROVAL_TIMEOUT %q (expected seconds, 0 to wait forever)", getEnv("APPROVAL_TIMEOUT", ""))
+	}
+
+	action := strings.ToLower(getEnv("APPROVAL_DEFAULT", "deny"))
+	if action != "deny" && action != "approve" {
+		return ApprovalPolicy{}, fmt.Errorf("invalid APPROVAL_DEFAULT %q (expected deny or approve)", action)
+	}
+
+	guarded := append(splitList(getEnv("GUARDED_COMMANDS", "")), user.GuardedCommands...)
+	for _, pattern := range guarded {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			return ApprovalPolicy{}, fmt.Errorf("invalid GUARDED_COMMANDS pattern %q: %v", pattern, err)
+		}
+	}
+
+	limits := map[string]*int{"MAX_LINES_PER_TURN": &user.MaxLinesPerTurn, "MAX_FILES_PER_TURN": &user.MaxFilesPerTurn}
+	defaults := map[string]int{"MAX_LINES_PER_TURN": defaultMaxLinesPerTurn, "MAX_FILES_PER_TURN": defaultMaxFilesPerTurn}
+	for key, limit := range limits {
+		fallback := defaults[key]
+		if *limit > 0 {
+			fallback = *limit
+		}
+		value, err := strconv.Atoi(getEnv(key, strconv.Itoa(fallback)))
+		if err != nil || value < 0 {
+			return ApprovalPolicy{}, fmt.Errorf("invalid %s %q (expected a number, 0 for no limit)", key, getEnv(key, ""))
+		}
+		*limit = value
+	}
+
+	return ApprovalPolicy{
+		Tools:           tools,
+		TimeoutMs:       timeout * 1000,
+		DefaultAction:   action,
+		GuardedCommands: guarded,
+		MaxLinesPerTurn: user.MaxLinesPerTurn,
+		MaxFilesPerTurn: user.MaxFilesPerTurn,
+	}, nil
+}
+
+func (c *Client) PendingApprovals() ([]PendingApproval, error) {
+	resp, err := c.client.Get(c.config.ServerURL + "/approvals")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ApprovalsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list approvals: %s", result.Error)
+	}
+
+	return result.Approvals, nil
+}
+
+func (c *Client) ResolveApproval(id, decision, pattern, confirmation string) error {
+	payload := map[string]string{"decision": decision}
+	if pattern != "" {
+		payload["pattern"] = pattern
+	}
+	if confirmation != "" {
+		payload["confirmation"] = confirmation
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.config.ServerURL+"/approvals/"+id, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Success {
+		return fmt.Errorf("failed to resolve approval: %s", result.Error)
+	}
+
+	return nil
+}
+
+// Poll for pending approvals while a turn runs and prompt for each one.
+// Returns a function that stops watching.
+// Guarded commands need confirmation even when no tools are listed for approval.
+func watchApprovals(client *Client) func() {
+	done := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+
+		// Prompt once per approval, even if it outlives an unanswered prompt
+		seen := map[string]bool{}
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				approvals, err := client.PendingApprovals()
+				if err != nil {
+					continue
+				}
+				for _, approval := range approvals {
+					if !seen[approval.ID] {
+						seen[approval.ID] = true
+						promptApproval(client, approval)
+					}
+				}
+			}
+		}
+	}()
+
+	return func() { done <- true }
+}
+
+// Ask the user about one tool call, falling back to the default action on timeout
+func promptApproval(client *Client, approval PendingApproval) {
+	promptActive.Store(true)
+	defer promptActive.Store(false)
+
+	// Time left before the server applies the default action (0 waits forever)
+	remaining := func() time.Duration {
+		if approval.ExpiresAt == 0 {
+			return 0
+		}
+		if left := time.Until(time.UnixMilli(approval.ExpiresAt)); left > 0 {
+			return left
+		}
+		return time.Millisecond
+	}
+
+	if approval.GuardedBy != "" {
+		confirmGuarded(client, approval, remaining())
+		return
+	}
+	if approval.ChangeLimit != "" {
+		confirmChangeLimit(client, approval, remaining())
+		return
+	}
+
+	policy := client.config.Approval
+	fmt.Printf("\n🔐 Approval needed: %s\n", describeToolCall(ToolCall{Name: approval.Name, Parameters: approval.Parameters}))
+	if apply, _ := approval.Parameters["apply"].(bool); approval.Name == "rename_symbol" && apply {
+		previewRename(approval.Parameters)
+	}
+	fmt.Println("   [y] yes  [n] no  [a] all this turn  [p] pattern for this session")
+
+	if approval.ExpiresAt > 0 {
+		fmt.Printf("   %s in %ds > ", policy.DefaultAction, int(remaining().Seconds()+0.5))
+	} else {
+		fmt.Print("   > ")
+	}
+
+	answer, ok := stdin.ReadLineTimeout(remaining())
+	if !ok {
+		// The server applies the default action itself when it expires
+		fmt.Printf("\n⏱️  No answer, %s by default\n", pastTense(policy.DefaultAction))
+		return
+	}
+
+	decision, pattern := "deny", ""
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		decision = "approve"
+	case "a", "all":
+		decision = "approve_turn"
+	case "p", "pattern":
+		fmt.Printf("   Pattern [%s]: ", approval.SuggestedPattern)
+		pattern, _ = stdin.ReadLineTimeout(remaining())
+		if pattern == "" {
+			pattern = approval.SuggestedPattern
+		}
+		decision = "approve_pattern"
+	}
+
+	if err := client.ResolveApproval(approval.ID, decision, pattern, ""); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+
+	switch decision {
+	case "approve_pattern":
+		fmt.Printf("✅ Approved %s matching %q for this session\n", approval.Name, pattern)
+	case "approve_turn":
+		fmt.Println("✅ Approved all tool calls for this turn")
+	case "approve":
+		fmt.Println("✅ Approved")
+	default:
+		fmt.Println("🚫 Denied")
+	}
+}
+
+// Ask for a destructive command to be typed back; anything else denies it.
+// Earlier approvals and patterns never cover these, and silence always denies.
+func confirmGuarded(client *Client, approval PendingApproval, timeout time.Duration) {
+	command := approval.Subject
+	fmt.Printf("\n🛑 Destructive command: %s\n", command)
+	fmt.Printf("   Matches guarded pattern %s\n", approval.GuardedBy)
+	fmt.Println("   Type the command to confirm, or press Enter to deny")
+	if approval.ExpiresAt > 0 {
+		fmt.Printf("   deny in %ds > ", int(timeout.Seconds()+0.5))
+	} else {
+		fmt.Print("   > ")
+	}
+
+	answer, ok := stdin.ReadLineTimeout(timeout)
+	if !ok {
+		fmt.Println("\n⏱️  No answer, denied")
+		return
+	}
+
+	confirmed := strings.TrimSpace(answer) == strings.TrimSpace(command)
+	decision := "deny"
+	if confirmed {
+		decision = "approve"
+	}
+	if err := client.ResolveApproval(approval.ID, decision, "", answer); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+
+	switch {
+	case confirmed:
+		fmt.Println("✅ Confirmed")
+	case answer == "":
+		fmt.Println("🚫 Denied")
+	default:
+		fmt.Println("🚫 Denied (the command did not match)")
+	}
+}
+
+// Ask whether a turn may keep editing after a tool call would take its
+// changes past the per-turn limits; yes lifts the limits for the rest of the
+// turn, and silence always denies
+func confirmChangeLimit(client *Client, approval PendingApproval, timeout time.Duration) {
+	fmt.Printf("\n✋ Large change: %s would make it %s\n", describeToolCall(ToolCall{Name: approval.Name, Parameters: approval.Parameters}), approval.ChangeLimit)
+	fmt.Println("   Review the changes so far, then let the turn go on?")
+	if approval.ExpiresAt > 0 {
+		fmt.Printf("   [y/N] deny in %ds > ", int(timeout.Seconds()+0.5))
+	} else {
+		fmt.Print("   [y/N] > ")
+	}
+
+	answer, ok := stdin.ReadLineTimeout(timeout)
+	if !ok {
+		fmt.Println("\n⏱️  No answer, stopped; the AI will summarize what is left")
+		return
+	}
+	decision := "deny"
+	if strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes") {
+		decision = "approve"
+	}
+	if err := client.ResolveApproval(approval.ID, decision, "", ""); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if decision == "approve" {
+		fmt.Println("✅ Continuing; no more limits this turn")
+	} else {
+		fmt.Println("🚫 Stopped; the AI will summarize what is left")
+	}
+}
+
+func pastTense(action string) string {
+	if action == "approve" {
+		return "approved"
+	}
+	return "denied"
+}
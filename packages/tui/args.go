@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Largest file attached from the command line
+const maxAttachmentBytes = 100 * 1024
+
+// Task given on the command line, sent as the first message
+type StartupArgs struct {
+	Prompt        string
+	Files         []string
+	Attachments   []Attachment // Contents of Files, for the cost preflight
+	Message       string       // Prompt with the attached files, empty if no task was given
+	PrintOnExit   string       // "last" or a message number to write to stdout on exit, "" for none
+	Pprof         string       // Address to serve pprof on, "" for none
+	Resume        string       // Saved session to continue, "" for a new one
+	Offline       bool         // Reach nothing but this machine and allowed hosts
+	Attach        bool         // Join the session open on SERVER_URL instead of starting one
+	Schema        string       // JSON schema the one-shot reply must match, "" for a normal run
+	SchemaRetries int          // Corrections asked for before a --schema run fails
+}
+
+// Parse `painika [prompt...] [--file path]... [--print-on-exit[=n]] [--pprof[=addr]] [--resume id] [--offline] [--attach] [--schema path [--schema-retries n]]`, reading the attached files
+func parseStartupArgs(args []string) (StartupArgs, error) {
+	startup := StartupArgs{SchemaRetries: defaultSchemaRetries}
+	var words []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--file" || arg == "-f":
+			if i+1 >= len(args) {
+				return StartupArgs{}, fmt.Errorf("%s needs a path", arg)
+			}
+			startup.Files = append(startup.Files, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--file="):
+			startup.Files = append(startup.Files, strings.TrimPrefix(arg, "--file="))
+		case arg == "--print-on-exit":
+			startup.PrintOnExit = "last"
+		case strings.HasPrefix(arg, "--print-on-exit="):
+			startup.PrintOnExit = strings.TrimPrefix(arg, "--print-on-exit=")
+			if _, err := strconv.Atoi(startup.PrintOnExit); err != nil && startup.PrintOnExit != "last" {
+				return StartupArgs{}, fmt.Errorf("--print-on-exit expects a message number or \"last\"")
+			}
+		case arg == "--resume":
+			if i+1 >= len(args) {
+				return StartupArgs{}, fmt.Errorf("%s needs a session ID", arg)
+			}
+			startup.Resume = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--resume="):
+			startup.Resume = strings.TrimPrefix(arg, "--resume=")
+		case arg == "--offline":
+			startup.Offline = true
+		case arg == "--attach":
+			startup.Attach = true
+		case arg == "--schema":
+			if i+1 >= len(args) {
+				return StartupArgs{}, fmt.Errorf("%s needs a path", arg)
+			}
+			startup.Schema = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--schema="):
+			startup.Schema = strings.TrimPrefix(arg, "--schema=")
+		case arg == "--schema-retries" || strings.HasPrefix(arg, "--schema-retries="):
+			value, ok := strings.CutPrefix(arg, "--schema-retries=")
+			if !ok {
+				if i+1 >= len(args) {
+					return StartupArgs{}, fmt.Errorf("%s needs a number", arg)
+				}
+				value = args[i+1]
+				i++
+			}
+			retries, err := strconv.Atoi(value)
+			if err != nil || retries < 0 {
+				return StartupArgs{}, fmt.Errorf("--schema-retries expects a number of corrections (0 or more)")
+			}
+			startup.SchemaRetries = retries
+		case arg == "--pprof":
+			startup.Pprof = "localhost:6060"
+		case strings.HasPrefix(arg, "--pprof="):
+			startup.Pprof = strings.TrimPrefix(arg, "--pprof=")
+		case arg == "--":
+			words = append(words, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return StartupArgs{}, fmt.Errorf("unknown flag: %s", arg)
+		default:
+			words = append(words, arg)
+		}
+	}
+
+	if startup.Attach && startup.Resume != "" {
+		return StartupArgs{}, fmt.Errorf("--attach joins the open session, so it can't be used with --resume")
+	}
+
+	if startup.Schema != "" && len(words) == 0 {
+		return StartupArgs{}, fmt.Errorf("--schema needs a prompt to answer")
+	}
+	if startup.Schema != "" && (startup.Attach || startup.Resume != "") {
+		return StartupArgs{}, fmt.Errorf("--schema runs one turn in a new session, so it can't be used with --attach or --resume")
+	}
+
+	startup.Prompt = strings.TrimSpace(strings.Join(words, " "))
+	message, attachments, err := startupMessage(startup.Prompt, startup.Files)
+	if err != nil {
+		return StartupArgs{}, err
+	}
+	startup.Message = message
+	startup.Attachments = attachments
+	return startup, nil
+}
+
+// Build the first message: the prompt followed by each attached file
+func startupMessage(prompt string, files []string) (string, []Attachment, error) {
+	var b strings.Builder
+	b.WriteString(prompt)
+
+	var attachments []Attachment
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to attach %s: %v", path, err)
+		}
+		if len(data) > maxAttachmentBytes {
+			return "", nil, fmt.Errorf("failed to attach %s: larger than %d KB", path, maxAttachmentBytes/1024)
+		}
+
+		lang := strings.TrimPrefix(filepath.Ext(path), ".")
+		block := fmt.Sprintf("File: %s\n```%s\n%s\n```", path, lang, strings.TrimRight(string(data), "\n"))
+		b.WriteString("\n\n" + block)
+		attachments = append(attachments, Attachment{Name: path, Text: block})
+	}
+
+	return strings.TrimSpace(b.String()), attachments, nil
+}
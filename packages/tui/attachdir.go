@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token budget for /attach-dir without --max-tokens
+const defaultAttachDirTokens = 8000
+
+// Least budget worth spending on the start of a file that does not fit whole
+const minTruncatedTokens = 200
+
+// Directories of dependencies and build output, never attached
+var vendoredDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	"third_party":  true,
+	".venv":        true,
+	"venv":         true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	".next":        true,
+	"coverage":     true,
+}
+
+// Generated files recognized by name
+var generatedSuffixes = []string{
+	".pb.go", "_gen.go", "_generated.go", ".gen.ts", ".min.js", ".min.css", ".map",
+	".lock", "lock.json", "lock.yaml", "go.sum", ".snap",
+}
+
+// Attachments waiting for the next message (/attach-dir, piped input)
+var pendingAttachments []Attachment
+
+// A file considered for /attach-dir
+type dirFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// What /attach-dir packed
+type dirPack struct {
+	Text      string
+	Included  []string
+	Truncated []string
+	Omitted   []string // Did not fit the budget
+	Skipped   int      // Vendored, generated, binary, or too large
+	Tokens    int
+}
+
+// Whether a file is generated: by name, or by the "Code generated ... DO
+// NOT EDIT." header Go and other tools write
+func isGeneratedFile(path string, head []byte) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	firstLines := head
+	if len(firstLines) > 1024 {
+		firstLines = firstLines[:1024]
+	}
+	return bytes.Contains(firstLines, []byte("DO NOT EDIT")) || bytes.Contains(firstLines, []byte("@generated"))
+}
+
+// Files under dir, honoring .gitignore inside a git repository
+func listDirFiles(dir string) ([]string, error) {
+	if isGitRepo(dir) {
+		output, err := runGit(dir, "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+		if err == nil {
+			var paths []string
+			for _, name := range strings.Split(output, "\x00") {
+				if name != "" {
+					paths = append(paths, filepath.Join(dir, name))
+				}
+			}
+			return paths, nil
+		}
+	}
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path != dir && (vendoredDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// Indented listing of the files, so the AI sees the layout of what was left out
+func fileTree(dir string, paths []string) string {
+	var b strings.Builder
+	b.WriteString(filepath.ToSlash(filepath.Clean(dir)) + "/\n")
+	shown := map[string]bool{}
+	for i, path := range paths {
+		if i == 200 {
+			fmt.Fprintf(&b, "... and %d more files\n", len(paths)-i)
+			break
+		}
+		rel, _ := filepath.Rel(dir, path)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for depth := range parts {
+			key := strings.Join(parts[:depth+1], "/")
+			if shown[key] {
+				continue
+			}
+			shown[key] = true
+			name := parts[depth]
+			if depth < len(parts)-1 {
+				name += "/"
+			}
+			b.WriteString(strings.Repeat("  ", depth+1) + name + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Pack a directory into one attachment of at most maxTokens: a tree of the
+// files, then as many files as fit, recently modified and small ones first
+func packDirectory(tok Tokenizer, dir string, maxTokens int) (dirPack, error) {
+	var pack dirPack
+	paths, err := listDirFiles(dir)
+	if err != nil {
+		return pack, err
+	}
+
+	var files []dirFile
+	for _, path := range paths {
+		rel, _ := filepath.Rel(dir, path)
+		vendored := false
+		for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+			vendored = vendored || vendoredDirs[part]
+		}
+		info, err := os.Stat(path)
+		if vendored || err != nil || !info.Mode().IsRegular() || info.Size() > maxAttachmentBytes {
+			pack.Skipped++
+			continue
+		}
+		head := make([]byte, 8000)
+		f, err := os.Open(path)
+		if err != nil {
+			pack.Skipped++
+			continue
+		}
+		n, _ := f.Read(head)
+		f.Close()
+		head = head[:n]
+		if bytes.IndexByte(head, 0) >= 0 || isGeneratedFile(path, head) {
+			pack.Skipped++
+			continue
+		}
+		files = append(files, dirFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	if len(files) == 0 {
+		return pack, fmt.Errorf("no files to attach in %s", dir)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	treePaths := make([]string, len(files))
+	for i, file := range files {
+		treePaths[i] = file.Path
+	}
+	tree := fileTree(dir, treePaths)
+
+	// Rank by recency and by size, and take the best combined rank first
+	rank := map[string]int{}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	for i, file := range files {
+		rank[file.Path] = i
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size < files[j].Size })
+	for i, file := range files {
+		rank[file.Path] += i
+	}
+	sort.SliceStable(files, func(i, j int) bool { return rank[files[i].Path] < rank[files[j].Path] })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Directory: %s\n```\n%s```", filepath.ToSlash(dir), tree)
+	used := tok.Count(b.String())
+	for _, file := range files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			pack.Skipped++
+			continue
+		}
+		content := strings.TrimRight(string(data), "\n")
+		lang := strings.TrimPrefix(filepath.Ext(file.Path), ".")
+		block := fmt.Sprintf("\n\nFile: %s\n```%s\n%s\n```", file.Path, lang, content)
+		tokens := tok.Count(block)
+
+		if used+tokens > maxTokens {
+			// Spend what is left on the start of the first file that does not fit
+			if left := maxTokens - used; len(pack.Truncated) == 0 && left >= minTruncatedTokens {
+				lines := strings.Split(content, "\n")
+				for len(lines) > 1 && tok.Count(block) > left {
+					lines = lines[:len(lines)*3/4]
+					block = fmt.Sprintf("\n\nFile: %s (truncated)\n```%s\n%s\n...\n```", file.Path, lang, strings.Join(lines, "\n"))
+				}
+				if tokens = tok.Count(block); used+tokens <= maxTokens {
+					b.WriteString(block)
+					used += tokens
+					pack.Truncated = append(pack.Truncated, file.Path)
+					continue
+				}
+			}
+			pack.Omitted = append(pack.Omitted, file.Path)
+			continue
+		}
+		b.WriteString(block)
+		used += tokens
+		pack.Included = append(pack.Included, file.Path)
+	}
+
+	pack.Text = b.String()
+	pack.Tokens = used
+	return pack, nil
+}
+
+// Parse `<dir> [--max-tokens n]`
+func parseAttachDirArgs(args string) (string, int, error) {
+	var dir string
+	maxTokens := defaultAttachDirTokens
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i++ {
+		value := ""
+		switch {
+		case fields[i] == "--max-tokens":
+			if i+1 >= len(fields) {
+				return "", 0, fmt.Errorf("--max-tokens needs a number")
+			}
+			i++
+			value = fields[i]
+		case strings.HasPrefix(fields[i], "--max-tokens="):
+			value = strings.TrimPrefix(fields[i], "--max-tokens=")
+		case strings.HasPrefix(fields[i], "-"):
+			return "", 0, fmt.Errorf("unknown flag: %s", fields[i])
+		case dir == "":
+			dir = fields[i]
+			continue
+		default:
+			return "", 0, fmt.Errorf("one directory at a time")
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return "", 0, fmt.Errorf("--max-tokens must be a positive number, got %q", value)
+		}
+		maxTokens = n
+	}
+	if dir == "" {
+		return "", 0, fmt.Errorf("name a directory")
+	}
+	return dir, maxTokens, nil
+}
+
+// List up to a few paths, then how many more there are
+func summarizePaths(paths []string) string {
+	const shown = 5
+	if len(paths) <= shown {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s, and %d more", strings.Join(paths[:shown], ", "), len(paths)-shown)
+}
+
+// Handle /attach-dir <dir> [--max-tokens n]
+func handleAttachDir(client *Client, args string) {
+	dir, maxTokens, err := parseAttachDirArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("Usage: /attach-dir <dir> [--max-tokens n]")
+		fmt.Println()
+		return
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("❌ %s is not a directory\n\n", dir)
+		return
+	}
+
+	pack, err := packDirectory(tokenizerForModel(client.config.Model), dir, maxTokens)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("🗂️  %s: %d file(s) in full", dir, len(pack.Included))
+	if len(pack.Truncated) > 0 {
+		fmt.Printf(", %d truncated", len(pack.Truncated))
+	}
+	fmt.Printf(" (~%s of %s tokens)\n", formatTokens(pack.Tokens), formatTokens(maxTokens))
+	if len(pack.Included) > 0 {
+		fmt.Printf("   Included: %s\n", summarizePaths(pack.Included))
+	}
+	if len(pack.Truncated) > 0 {
+		fmt.Printf("   Truncated: %s\n", summarizePaths(pack.Truncated))
+	}
+	if len(pack.Omitted) > 0 {
+		fmt.Printf("   Left out (over budget, listed in the tree): %s\n", summarizePaths(pack.Omitted))
+	}
+	if pack.Skipped > 0 {
+		fmt.Printf("   Skipped %d vendored, generated, binary, or large file(s)\n", pack.Skipped)
+	}
+
+	attachment := Attachment{Name: dir + "/", Text: pack.Text}
+	if !preflightAttachments(client, []Attachment{attachment}) {
+		return
+	}
+	pendingAttachments = append(pendingAttachments, attachment)
+	fmt.Println("📎 Attached to your next message")
+	fmt.Println()
+}
+
+// Put the attachments waiting for the next message in front of it
+func withPendingAttachments(input string) string {
+	if len(pendingAttachments) == 0 {
+		return input
+	}
+	var b strings.Builder
+	for _, attachment := range pendingAttachments {
+		b.WriteString(attachment.Text + "\n\n")
+	}
+	pendingAttachments = nil
+	return b.String() + input
+}
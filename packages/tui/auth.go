@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Header carrying the server token on every request
+const serverTokenHeader = "X-Painika-Token"
+
+// Token the server requires on every request except /health, picked on
+// first use so SERVER_TOKEN and SERVER_URL from .env are seen
+var serverToken = sync.OnceValue(serverTokenConfig)
+
+// Where `painika server` saves its token for clients on the same machine
+func serverTokenFile() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "server-token"), nil
+}
+
+// Pick this run's server token: SERVER_TOKEN, the token saved by
+// `painika server` when connecting to SERVER_URL, or a fresh one for the
+// servers this run starts
+func serverTokenConfig() string {
+	if token := getEnv("SERVER_TOKEN", ""); token != "" {
+		return token
+	}
+	if getEnv("SERVER_URL", "") != "" {
+		if path, err := serverTokenFile(); err == nil {
+			if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) != "" {
+				return strings.TrimSpace(string(data))
+			}
+		}
+	}
+	return randomID(32)
+}
+
+// Environment for a server process: the filtered environment plus its token
+// (SERVER_TOKEN itself is withheld from subprocesses like any other secret)
+func serverEnv() []string {
+	return append(append(subprocessEnv(), offlineEnv()...), "SERVER_TOKEN="+serverToken())
+}
+
+// Adds the server token and the client's name to every request to the server
+type authTransport struct {
+	base http.RoundTripper
+}
+
+func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if token := serverToken(); token != "" {
+		req.Header.Set(serverTokenHeader, token)
+	}
+	req.Header.Set(clientHeader, clientName())
+	req.Header.Set(clientIDHeader, clientID)
+	return t.base.RoundTrip(req)
+}
+
+// Explain a server that rejects this client's token
+func unauthorizedServerMessage(serverURL string) string {
+	return fmt.Sprintf("Server at %s did not accept this client's token", displayServerURL(serverURL))
+}
+
+// Save the token of a `painika server` so local clients can connect
+func saveServerToken(token string) (string, error) {
+	path, err := serverTokenFile()
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(token+"\n"), 0600)
+}
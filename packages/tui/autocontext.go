@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Most files offered for one prompt
+const autoContextMaxFiles = 3
+
+// Files larger than this are neither offered nor searched for identifiers,
+// and only this many files are searched, so big trees don't stall a prompt
+const (
+	autoContextMaxBytes = 64 * 1024
+	autoContextMaxReads = 2000
+)
+
+var (
+	// Words in a prompt that may name a file: main.go, src/session.ts
+	autoContextFileWord = regexp.MustCompile(`[\w.-]*[\w-]\.[A-Za-z]\w*|[\w.-]+(?:/[\w.-]+)+`)
+	// Words that look like code identifiers: camelCase, PascalCase, snake_case
+	autoContextIdent = regexp.MustCompile(`\b(?:[a-z]+[A-Z]\w*|[A-Z][a-z0-9]+[A-Z]\w*|[A-Za-z]+_\w+)\b`)
+)
+
+// A workspace file a prompt seems to mean
+type contextMatch struct {
+	Path   string // Relative to the working directory
+	Reason string
+	Score  int
+}
+
+// Read AUTO_CONTEXT: ask before attaching files a prompt mentions, attach
+// them without asking (on), or don't look (off)
+func autoContextConfig() (string, error) {
+	mode := strings.ToLower(getEnv("AUTO_CONTEXT", "ask"))
+	switch mode {
+	case "ask", "on", "off":
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid AUTO_CONTEXT %q (expected ask, on, or off)", mode)
+}
+
+// Whether a file name is close to a word: the same, or one typo away for
+// names long enough that a typo can't make them another word
+func fuzzyNameMatch(word, name string) bool {
+	word, name = strings.ToLower(word), strings.ToLower(name)
+	if word == name {
+		return true
+	}
+	return len(word) >= 6 && levenshtein(word, name) <= 1
+}
+
+// Find workspace files the prompt mentions by path, by name (allowing a
+// typo), or by an identifier they define; best matches first
+func findContextFiles(prompt string) []contextMatch {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	files, err := listDirFiles(cwd)
+	if err != nil {
+		return nil
+	}
+
+	fileWords := autoContextFileWord.FindAllString(prompt, -1)
+	var idents []string
+	for _, ident := range autoContextIdent.FindAllString(prompt, -1) {
+		if len(ident) >= 4 && !containsTag(idents, ident) {
+			idents = append(idents, ident)
+		}
+	}
+	if len(fileWords) == 0 && len(idents) == 0 {
+		return nil
+	}
+	var definitions *regexp.Regexp
+	if len(idents) > 0 {
+		quoted := make([]string, len(idents))
+		for i, ident := range idents {
+			quoted[i] = regexp.QuoteMeta(ident)
+		}
+		definitions = regexp.MustCompile(`\b(?:func(?:\s*\([^)]*\))?|type|class|interface|struct|enum|def|fn|function|const|let|var)\s+(` + strings.Join(quoted, "|") + `)\b`)
+	}
+
+	var matches []contextMatch
+	reads := 0
+	for _, path := range files {
+		rel, err := filepath.Rel(cwd, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > autoContextMaxBytes {
+			continue
+		}
+
+		best := contextMatch{Path: rel}
+		base := filepath.Base(rel)
+		stem := strings.TrimSuffix(base, filepath.Ext(base))
+		for _, word := range fileWords {
+			word = strings.TrimPrefix(strings.TrimRight(word, linkTrailing), "./")
+			switch {
+			case word == rel:
+				best = maxMatch(best, contextMatch{Path: rel, Reason: "named in the prompt", Score: 100})
+			case strings.Contains(word, "/") && strings.HasSuffix(rel, "/"+word):
+				best = maxMatch(best, contextMatch{Path: rel, Reason: "named in the prompt", Score: 90})
+			case !strings.Contains(word, "/") && strings.EqualFold(word, base):
+				best = maxMatch(best, contextMatch{Path: rel, Reason: "file name in the prompt", Score: 80})
+			case !strings.Contains(word, "/") && fuzzyNameMatch(word, base):
+				best = maxMatch(best, contextMatch{Path: rel, Reason: "close to " + word, Score: 40})
+			}
+		}
+		for _, ident := range idents {
+			if strings.EqualFold(ident, stem) {
+				best = maxMatch(best, contextMatch{Path: rel, Reason: "named like " + ident, Score: 60})
+			}
+		}
+		if definitions != nil && best.Score < 50 && reads < autoContextMaxReads {
+			reads++
+			data, err := os.ReadFile(path)
+			if err == nil && !isGeneratedFile(path, data) {
+				if match := definitions.FindSubmatch(data); match != nil {
+					best = maxMatch(best, contextMatch{Path: rel, Reason: "defines " + string(match[1]), Score: 50})
+				}
+			}
+		}
+		if best.Score > 0 {
+			matches = append(matches, best)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Path < matches[j].Path
+	})
+	if len(matches) > autoContextMaxFiles {
+		matches = matches[:autoContextMaxFiles]
+	}
+	return matches
+}
+
+func maxMatch(a, b contextMatch) contextMatch {
+	if b.Score > a.Score {
+		return b
+	}
+	return a
+}
+
+// Which of n offered files an answer picks: all for yes or nothing typed,
+// none for no, or a list of numbers such as "1 3" or "1,3"
+func pickContextFiles(answer string, n int) []int {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	var picked []int
+	switch answer {
+	case "", "y", "yes", "a", "all":
+		for i := 0; i < n; i++ {
+			picked = append(picked, i)
+		}
+		return picked
+	case "n", "no":
+		return nil
+	}
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if i, err := strconv.Atoi(field); err == nil && i >= 1 && i <= n && !containsInt(picked, i-1) {
+			picked = append(picked, i-1)
+		}
+	}
+	return picked
+}
+
+// Offer the files a prompt mentions as attachments to it, leaving out any
+// already attached; chosen files join the pending attachments
+func offerAutoContext(client *Client, input string) {
+	mode := client.config.AutoContext
+	if mode == "off" || (mode == "ask" && !renderer.Interactive()) {
+		return
+	}
+
+	var matches []contextMatch
+	for _, match := range findContextFiles(input) {
+		attached := false
+		for _, attachment := range pendingAttachments {
+			attached = attached || attachment.Name == match.Path
+		}
+		if !attached {
+			matches = append(matches, match)
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+
+	var picked []int
+	if mode == "on" {
+		picked = pickContextFiles("all", len(matches))
+	} else {
+		fmt.Println("🔎 Your message seems to be about:")
+		for i, match := range matches {
+			fmt.Printf("   %d. %s (%s)\n", i+1, match.Path, match.Reason)
+		}
+		fmt.Print("   Attach them? [Y/n/numbers] ")
+		answer, _ := stdin.ReadLine()
+		picked = pickContextFiles(answer, len(matches))
+	}
+	if len(picked) == 0 {
+		return
+	}
+
+	paths := make([]string, len(picked))
+	for i, index := range picked {
+		paths[i] = matches[index].Path
+	}
+	_, attachments, err := startupMessage("", paths)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if preflightAttachments(client, attachments) {
+		pendingAttachments = append(pendingAttachments, attachments...)
+		fmt.Printf("📎 %s attached\n", strings.Join(paths, ", "))
+	}
+}
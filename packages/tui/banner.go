@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Longest a GREETING_COMMAND may take before startup goes on without it
+const greetingTimeout = 5 * time.Second
+
+// What the welcome banner reports
+type BannerInfo struct {
+	Model    string
+	Provider string
+	Server   string
+}
+
+// How a session starts: BANNER (full, minimal, or off) and a GREETING
+// message or GREETING_COMMAND shown in place of the banner
+type StartupPolicy struct {
+	Banner          string
+	Greeting        string // {model}, {provider}, and {server} are filled in
+	GreetingCommand string // Its output is the greeting; PAINIKA_MODEL, PAINIKA_PROVIDER, and PAINIKA_SERVER are set
+}
+
+// Read BANNER, GREETING, and GREETING_COMMAND
+func startupPolicyConfig() (StartupPolicy, error) {
+	policy := StartupPolicy{
+		Banner:          strings.ToLower(getEnv("BANNER", "full")),
+		Greeting:        getEnv("GREETING", ""),
+		GreetingCommand: strings.TrimSpace(getEnv("GREETING_COMMAND", "")),
+	}
+	switch policy.Banner {
+	case "full", "minimal", "off":
+		return policy, nil
+	}
+	return StartupPolicy{}, fmt.Errorf("invalid BANNER %q (expected full, minimal, or off)", policy.Banner)
+}
+
+// Active startup policy; set before the server starts so its progress
+// notes can be left out too
+var bannerPolicy StartupPolicy
+
+// Progress note while the session starts, left out unless the full banner
+// is shown; warnings and errors go to the renderer regardless
+func startupNotice(text string) {
+	if bannerPolicy.Banner == "full" && bannerPolicy.Greeting == "" && bannerPolicy.GreetingCommand == "" {
+		renderer.Notice(text)
+	}
+}
+
+// Show the greeting, or the banner BANNER asks for
+func showBanner(info BannerInfo) {
+	if bannerPolicy.GreetingCommand != "" {
+		greeting, err := runGreetingCommand(bannerPolicy.GreetingCommand, info)
+		if err == nil {
+			if greeting != "" {
+				renderer.Notice(greeting)
+			}
+			return
+		}
+		renderer.Error("Greeting command failed", err)
+	}
+	if bannerPolicy.Greeting != "" {
+		renderer.Notice(strings.NewReplacer("{model}", info.Model, "{provider}", info.Provider, "{server}", info.Server).Replace(bannerPolicy.Greeting))
+		return
+	}
+
+	switch bannerPolicy.Banner {
+	case "full":
+		renderer.Banner(info)
+	case "minimal":
+		renderer.Notice(fmt.Sprintf("painika %s (%s)", info.Model, info.Provider))
+	}
+}
+
+// Run GREETING_COMMAND through the platform shell and return its output
+func runGreetingCommand(command string, info BannerInfo) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), greetingTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"PAINIKA_MODEL="+info.Model,
+		"PAINIKA_PROVIDER="+info.Provider,
+		"PAINIKA_SERVER="+info.Server,
+	)
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("no output after %s", greetingTimeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
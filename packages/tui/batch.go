@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default number of prompts run in parallel by `painika batch`
+const defaultBatchConcurrency = 4
+
+// Outcome of one batch prompt, written as a JSON line
+type BatchResult struct {
+	Index      int    `json:"index"` // 1-based position in the input
+	Prompt     string `json:"prompt"`
+	Reply      string `json:"reply,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	// Other prompts answered in the same model call (--coalesce)
+	CoalescedWith []int `json:"coalescedWith,omitempty"`
+}
+
+// Servers started for batch workers, stopped on exit
+var batchServers struct {
+	sync.Mutex
+	cmds []*exec.Cmd
+}
+
+// Parse `batch [file|-] [--concurrency n] [--coalesce]`; the file defaults to stdin
+func parseBatchArgs(args []string) (string, int, bool, error) {
+	path := "-"
+	coalesce := false
+	concurrency, err := strconv.Atoi(getEnv("BATCH_CONCURRENCY", strconv.Itoa(defaultBatchConcurrency)))
+	if err != nil {
+		return "", 0, false, fmt.Errorf("invalid BATCH_CONCURRENCY %q", getEnv("BATCH_CONCURRENCY", ""))
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := ""
+		switch {
+		case arg == "--coalesce":
+			coalesce = true
+			continue
+		case arg == "--concurrency" || arg == "-j":
+			if i+1 >= len(args) {
+				return "", 0, false, fmt.Errorf("%s needs a number", arg)
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(arg, "--concurrency="):
+			value = strings.TrimPrefix(arg, "--concurrency=")
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return "", 0, false, fmt.Errorf("unknown flag: %s", arg)
+		default:
+			path = arg
+			continue
+		}
+
+		if concurrency, err = strconv.Atoi(value); err != nil {
+			return "", 0, false, fmt.Errorf("invalid concurrency %q", value)
+		}
+	}
+
+	if concurrency < 1 {
+		return "", 0, false, fmt.Errorf("concurrency must be at least 1")
+	}
+	return path, concurrency, coalesce, nil
+}
+
+// Read one prompt per line, skipping blank lines and # comments
+func readBatchPrompts(path string) ([]string, error) {
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var prompts []string
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAttachmentBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			prompts = append(prompts, line)
+		}
+	}
+	return prompts, scanner.Err()
+}
+
+// Start a server for one batch worker; returns its URL
+func startBatchServer() (string, error) {
+	port, cmd, err := startServerInBackgroundWithPort()
+	if err != nil {
+		return "", err
+	}
+
+	batchServers.Lock()
+	batchServers.cmds = append(batchServers.cmds, cmd)
+	batchServers.Unlock()
+
+	serverURL := fmt.Sprintf("http://localhost:%d", port)
+	if !waitForServer(serverURL, 15*time.Second, false) {
+		return "", fmt.Errorf("server on port %d failed to start within 15 seconds", port)
+	}
+	return serverURL, nil
+}
+
+// Stop every batch worker's server
+func stopBatchServers() {
+	batchServers.Lock()
+	defer batchServers.Unlock()
+
+	for _, cmd := range batchServers.cmds {
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}
+	batchServers.cmds = nil
+}
+
+// Run one prompt in a fresh session
+func runBatchPrompt(client *Client, index int, prompt string) BatchResult {
+	result := BatchResult{Index: index, Prompt: prompt}
+	start := time.Now()
+
+	if err := client.InitSession(); err != nil {
+		result.Error = err.Error()
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result
+	}
+
+	// Nobody can answer questions in a batch; they are skipped right away
+	stopQuestions := watchQuestions(client)
+	response, err := client.SendMessage(prompt)
+	stopQuestions()
+
+	if err != nil {
+		result.Error = err.Error()
+	} else if msg := lastMessage(response.Messages); msg != nil {
+		result.Reply = msg.Content
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// Run a group of prompts as one message and give each its share of the reply
+func runBatchGroup(client *Client, group []int, prompts []string) []BatchResult {
+	if len(group) == 1 {
+		return []BatchResult{runBatchPrompt(client, group[0]+1, prompts[group[0]])}
+	}
+
+	texts := make([]string, len(group))
+	for i, index := range group {
+		texts[i] = prompts[index]
+	}
+	merged := runBatchPrompt(client, group[0]+1, coalescedPrompt(texts))
+	answers := splitCoalescedReply(merged.Reply, len(group))
+
+	results := make([]BatchResult, len(group))
+	for i, index := range group {
+		results[i] = BatchResult{Index: index + 1, Prompt: texts[i], Error: merged.Error, DurationMs: merged.DurationMs}
+		if merged.Error == "" {
+			results[i].Reply = answers[i]
+		}
+		for _, other := range group {
+			if other != index {
+				results[i].CoalescedWith = append(results[i].CoalescedWith, other+1)
+			}
+		}
+	}
+	return results
+}
+
+// Handle `painika batch`: run prompts through a pool of workers, each with
+// its own server (the server keeps one session and one file policy per
+// process), and write a JSON line per result as it finishes
+func runBatch(args []string) {
+	path, concurrency, coalesce, err := parseBatchArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(2)
+	}
+
+	// Results own stdout; progress goes to stderr
+	redirectChrome()
+	renderer = PlainRenderer{}
+	setupCleanupHandlers()
+
+	prompts, err := readBatchPrompts(path)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if len(prompts) == 0 {
+		fmt.Println("❌ No prompts to run")
+		exit(1)
+	}
+
+	config := loadConfig()
+	config.SystemContext = sessionContext()
+
+	// Each group is one model call; without --coalesce, one prompt each
+	groups := make([][]int, len(prompts))
+	for i := range prompts {
+		groups[i] = []int{i}
+	}
+	if coalesce {
+		groups = coalescePrompts(prompts, tokenizerForModel(config.Model))
+		if len(groups) < len(prompts) {
+			fmt.Printf("🔗 Coalesced %d prompts into %d model calls\n", len(prompts), len(groups))
+		}
+	}
+
+	// A given server can only hold one session at a time
+	var serverURLs []string
+	if getEnv("SERVER_URL", "") != "" {
+		if concurrency > 1 {
+			fmt.Println("⚠️  SERVER_URL is set, so prompts run one at a time on that server")
+		}
+		serverURLs = []string{config.ServerURL}
+	} else {
+		if concurrency > len(groups) {
+			concurrency = len(groups)
+		}
+
+		// Start servers one after another so they don't race for a port
+		fmt.Printf("🔄 Starting %d server(s)...\n", concurrency)
+		for i := 0; i < concurrency; i++ {
+			serverURL, err := startBatchServer()
+			if err != nil {
+				fmt.Printf("❌ Failed to start server: %v\n", err)
+				stopBatchServers()
+				exit(1)
+			}
+			serverURLs = append(serverURLs, serverURL)
+		}
+	}
+
+	jobs := make(chan []int)
+	encoder := json.NewEncoder(resultOutput)
+	var mu sync.Mutex
+	failed := 0
+
+	var wg sync.WaitGroup
+	for _, serverURL := range serverURLs {
+		workerConfig := config
+		workerConfig.ServerURL = serverURL
+		client := NewClient(workerConfig)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range jobs {
+				results := runBatchGroup(client, group, prompts)
+
+				mu.Lock()
+				for _, result := range results {
+					if result.Error != "" {
+						failed++
+					}
+					encoder.Encode(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, group := range groups {
+		jobs <- group
+	}
+	close(jobs)
+	wg.Wait()
+
+	stopBatchServers()
+	fmt.Printf("✅ %d of %d prompts succeeded\n", len(prompts)-failed, len(prompts))
+	if failed > 0 {
+		exit(1)
+	}
+	exit(0)
+}
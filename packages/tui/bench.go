@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// System prompt for benchmark completions (no tools, nothing recorded)
+const benchInstructions = "You are an AI coding assistant. Answer the user's request directly and completely."
+
+// Instructions for a model grading its own reply
+const gradeInstructions = "You grade answers to programming requests. Rate how correct, complete, and clear the answer is " +
+	"on a scale from 1 (useless) to 10 (excellent). Reply with the number only."
+
+// Settings for `painika bench`
+type BenchArgs struct {
+	Models     []string // Model names, optionally prefixed with a provider ("ollama:llama3.1")
+	PromptFile string
+	Grade      bool // Ask each model to grade its own replies
+}
+
+// Outcome of one prompt on one model
+type BenchRun struct {
+	Latency time.Duration
+	Input   int
+	Output  int
+	Cost    float64
+	Grade   int // 0 when not graded
+	Err     error
+}
+
+// Parse `bench --models a,b,c --prompt-file p.txt [--grade]`
+func parseBenchArgs(args []string) (BenchArgs, error) {
+	var bench BenchArgs
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		switch flag {
+		case "--models", "--prompt-file":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return bench, fmt.Errorf("%s needs a value", flag)
+				}
+				i++
+				value = args[i]
+			}
+			if flag == "--models" {
+				bench.Models = append(bench.Models, splitList(value)...)
+			} else {
+				bench.PromptFile = value
+			}
+		case "--grade":
+			bench.Grade = true
+		default:
+			return bench, fmt.Errorf("unknown argument: %s", args[i])
+		}
+	}
+
+	if len(bench.Models) == 0 {
+		return bench, fmt.Errorf("--models is required (comma-separated, e.g. llama-3.3-70b-versatile,ollama:llama3.1)")
+	}
+	if bench.PromptFile == "" {
+		return bench, fmt.Errorf("--prompt-file is required (one prompt per line, - for stdin)")
+	}
+	return bench, nil
+}
+
+// Client configuration for one benchmarked model
+func benchModelConfig(base Config, spec string) (Config, error) {
+	config := base
+	provider, model, found := strings.Cut(spec, ":")
+	if !found || (provider != "groq" && provider != "ollama") {
+		provider, model = base.Provider, spec
+	}
+
+	config.Model = model
+	switch {
+	case provider == "ollama":
+		config.Provider = "ollama"
+		if err := configureOllama(&config); err != nil {
+			return config, fmt.Errorf("%s: %v", spec, err)
+		}
+	case base.Provider != "groq":
+		config.Provider = "groq"
+		config.BaseURL = "https://api.groq.com/openai"
+		token, err := getSecret("GROQ_API_KEY")
+		if err != nil {
+			return config, fmt.Errorf("%s: %v", spec, err)
+		}
+		config.Token = token
+		if config.Token == "" {
+			return config, fmt.Errorf("%s needs GROQ_API_KEY", spec)
+		}
+	}
+	return config, nil
+}
+
+var gradePattern = regexp.MustCompile(`\b(10|[1-9])\b`)
+
+// Run one prompt in a fresh session, then optionally let the model grade its reply
+func runBenchPrompt(client *Client, prompt string, grade bool) BenchRun {
+	var run BenchRun
+	if run.Err = client.InitSession(); run.Err != nil {
+		return run
+	}
+
+	start := time.Now()
+	reply, err := client.Complete(benchInstructions, prompt)
+	run.Latency = time.Since(start)
+	if err != nil {
+		run.Err = err
+		return run
+	}
+
+	// Provider-reported usage, estimated locally if the provider reports none
+	if usage, err := client.GetTokenUsage(); err == nil && usage.Output > 0 {
+		run.Input, run.Output = usage.Input, usage.Output
+	} else {
+		tok := tokenizerForModel(client.config.Model)
+		run.Input = tok.Count(benchInstructions) + tok.Count(prompt)
+		run.Output = tok.Count(reply)
+	}
+	if client.config.Provider != "ollama" {
+		run.Cost = estimateCost(run.Input + run.Output)
+	}
+
+	if grade {
+		answer, err := client.Complete(gradeInstructions, "Request:\n"+prompt+"\n\nAnswer:\n"+reply)
+		if err == nil {
+			if match := gradePattern.FindString(answer); match != "" {
+				run.Grade, _ = strconv.Atoi(match)
+			}
+		}
+	}
+	return run
+}
+
+// Per-model totals for the results table
+type benchSummary struct {
+	Model   string
+	Runs    []BenchRun
+	Errors  []string
+	Latency time.Duration
+	Output  int
+	Cost    float64
+	Grades  []int
+}
+
+func summarizeBench(model string, runs []BenchRun) benchSummary {
+	summary := benchSummary{Model: model, Runs: runs}
+	for _, run := range runs {
+		if run.Err != nil {
+			summary.Errors = append(summary.Errors, run.Err.Error())
+			continue
+		}
+		summary.Latency += run.Latency
+		summary.Output += run.Output
+		summary.Cost += run.Cost
+		if run.Grade > 0 {
+			summary.Grades = append(summary.Grades, run.Grade)
+		}
+	}
+	return summary
+}
+
+// Print the results table, one row per model
+func printBenchTable(summaries []benchSummary, prompts int, grade bool) {
+	header := fmt.Sprintf("%-32s %5s %9s %8s %8s %9s", "MODEL", "OK", "LATENCY", "TOK/S", "OUT", "COST")
+	if grade {
+		header += fmt.Sprintf(" %6s", "GRADE")
+	}
+	fmt.Fprintln(resultOutput, header)
+
+	for _, s := range summaries {
+		ok := len(s.Runs) - len(s.Errors)
+		row := fmt.Sprintf("%-32s %5s", truncateWidth(s.Model, 32), fmt.Sprintf("%d/%d", ok, prompts))
+		if ok == 0 {
+			row += fmt.Sprintf(" %9s %8s %8s %9s", "-", "-", "-", "-")
+		} else {
+			avg := s.Latency / time.Duration(ok)
+			rate := float64(s.Output) / s.Latency.Seconds()
+			row += fmt.Sprintf(" %8.2fs %8.1f %8d %9s", avg.Seconds(), rate, s.Output/ok, fmt.Sprintf("$%.4f", s.Cost))
+		}
+		if grade {
+			if len(s.Grades) == 0 {
+				row += fmt.Sprintf(" %6s", "-")
+			} else {
+				total := 0
+				for _, g := range s.Grades {
+					total += g
+				}
+				row += fmt.Sprintf(" %6.1f", float64(total)/float64(len(s.Grades)))
+			}
+		}
+		fmt.Fprintln(resultOutput, row)
+	}
+
+	fmt.Fprintln(resultOutput)
+	fmt.Fprintln(resultOutput, "LATENCY is the average per prompt, TOK/S output tokens per second, OUT average output tokens, COST the estimated total")
+	if grade {
+		fmt.Fprintln(resultOutput, "GRADE is each model's average self-grade from 1 to 10")
+	}
+}
+
+// Handle `painika bench`: run the same prompts on several models at once,
+// each on its own server, and print a comparison table
+func runBench(args []string) {
+	bench, err := parseBenchArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("Usage: painika bench --models a,b,c --prompt-file p.txt [--grade]")
+		exit(2)
+	}
+
+	// The table owns stdout; progress goes to stderr
+	redirectChrome()
+	renderer = PlainRenderer{}
+	setupCleanupHandlers()
+
+	prompts, err := readBatchPrompts(bench.PromptFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if len(prompts) == 0 {
+		fmt.Println("❌ No prompts to run")
+		exit(1)
+	}
+
+	base := loadConfig()
+	configs := make([]Config, len(bench.Models))
+	for i, spec := range bench.Models {
+		if configs[i], err = benchModelConfig(base, spec); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+	}
+
+	// A given server can only hold one session at a time
+	shared := getEnv("SERVER_URL", "") != ""
+	if shared {
+		fmt.Println("⚠️  SERVER_URL is set, so models run one at a time on that server")
+	} else {
+		fmt.Printf("🔄 Starting %d server(s)...\n", len(configs))
+		for i := range configs {
+			serverURL, err := startBatchServer()
+			if err != nil {
+				fmt.Printf("❌ Failed to start server: %v\n", err)
+				stopBatchServers()
+				exit(1)
+			}
+			configs[i].ServerURL = serverURL
+		}
+	}
+
+	fmt.Printf("⏱️  Running %d prompt(s) on %d model(s)...\n", len(prompts), len(configs))
+	results := make([][]BenchRun, len(configs))
+	var mu sync.Mutex
+	run := func(i int) {
+		client := NewClient(configs[i])
+		for j, prompt := range prompts {
+			result := runBenchPrompt(client, prompt, bench.Grade)
+			results[i] = append(results[i], result)
+
+			mu.Lock()
+			if result.Err != nil {
+				fmt.Printf("   ❌ %s #%d: %v\n", configs[i].Model, j+1, result.Err)
+			} else {
+				fmt.Printf("   ✓ %s #%d in %.2fs\n", configs[i].Model, j+1, result.Latency.Seconds())
+			}
+			mu.Unlock()
+		}
+	}
+
+	if shared {
+		for i := range configs {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := range configs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+	stopBatchServers()
+
+	summaries := make([]benchSummary, len(configs))
+	failed := false
+	for i, config := range configs {
+		summaries[i] = summarizeBench(config.Model, results[i])
+		if len(summaries[i].Errors) == len(prompts) {
+			failed = true
+		}
+	}
+	fmt.Println()
+	printBenchTable(summaries, len(prompts), bench.Grade)
+
+	// Every prompt failing on a model usually means a bad model name or key
+	if failed {
+		exit(1)
+	}
+	exit(0)
+}
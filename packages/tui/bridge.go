@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Where `painika bridge` takes prompts from
+type BridgeArgs struct {
+	FifoDir    string // Directory holding the "in" and "out" named pipes
+	SocketPath string // Unix domain socket; replaces the pipes when set
+}
+
+// Parse `bridge [--fifo dir | --socket path]`; pipes in ~/.painika/bridge by default
+func parseBridgeArgs(args []string) (BridgeArgs, error) {
+	var bridge BridgeArgs
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		if flag != "--fifo" && flag != "--socket" {
+			return bridge, fmt.Errorf("unknown argument: %s", args[i])
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return bridge, fmt.Errorf("%s needs a path", flag)
+			}
+			i++
+			value = args[i]
+		}
+		if flag == "--fifo" {
+			bridge.FifoDir = value
+		} else {
+			bridge.SocketPath = value
+		}
+	}
+
+	if bridge.FifoDir != "" && bridge.SocketPath != "" {
+		return bridge, fmt.Errorf("use either --fifo or --socket, not both")
+	}
+	if bridge.FifoDir == "" && bridge.SocketPath == "" {
+		dir, err := painikaDir()
+		if err != nil {
+			return bridge, err
+		}
+		bridge.FifoDir = filepath.Join(dir, "bridge")
+	}
+	return bridge, nil
+}
+
+// One session shared by every editor connected to the bridge
+type bridgeSession struct {
+	mu     sync.Mutex
+	client *Client
+	count  int
+}
+
+// A prompt line is plain text, or {"prompt": "..."} for prompts spanning lines
+func parseBridgeLine(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return line, nil
+	}
+	var request struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.Unmarshal([]byte(line), &request); err != nil {
+		return "", fmt.Errorf("invalid JSON request: %v", err)
+	}
+	return strings.TrimSpace(request.Prompt), nil
+}
+
+// Send one prompt in the shared session, one at a time
+func (s *bridgeSession) ask(line string) (BatchResult, bool) {
+	prompt, err := parseBridgeLine(line)
+	if err == nil && prompt == "" {
+		return BatchResult{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	result := BatchResult{Index: s.count, Prompt: prompt}
+	if err != nil {
+		result.Prompt = line
+		result.Error = err.Error()
+		return result, true
+	}
+
+	start := time.Now()
+	// Nobody at the bridge can answer questions; they are skipped right away
+	stopQuestions := watchQuestions(s.client)
+	response, err := s.client.SendMessage(prompt)
+	stopQuestions()
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		result.Error = err.Error()
+		fmt.Printf("   ❌ #%d: %v\n", result.Index, err)
+	} else {
+		if msg := lastMessage(response.Messages); msg != nil {
+			result.Reply = msg.Content
+		}
+		fmt.Printf("   ✓ #%d in %.2fs\n", result.Index, float64(result.DurationMs)/1000)
+	}
+	return result, true
+}
+
+// Answer every prompt line read from r with a JSON line passed to reply
+func (s *bridgeSession) serve(r io.Reader, reply func(BatchResult) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAttachmentBytes)
+	for scanner.Scan() {
+		result, ok := s.ask(scanner.Text())
+		if !ok {
+			continue
+		}
+		if err := reply(result); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Read prompts from dir/in and write each reply to dir/out. A writer's
+// prompts are answered until it closes the pipe; each reply is written on
+// its own open of dir/out, so `cat out` returns after one reply.
+func (s *bridgeSession) serveFifos(dir string) error {
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+	for {
+		file, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		err = s.serve(file, func(result BatchResult) error {
+			replies, err := os.OpenFile(out, os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer replies.Close()
+			return json.NewEncoder(replies).Encode(result)
+		})
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Answer prompts on every connection to the socket; replies go back on the same connection
+func (s *bridgeSession) serveSocket(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			encoder := json.NewEncoder(conn)
+			s.serve(conn, func(result BatchResult) error { return encoder.Encode(result) })
+		}()
+	}
+}
+
+// Create the in and out pipes, replacing ones left behind by an earlier bridge
+func createBridgeFifos(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for _, name := range []string{"in", "out"} {
+		path := filepath.Join(dir, name)
+		if info, err := os.Lstat(path); err == nil {
+			if info.Mode()&os.ModeNamedPipe == 0 {
+				return fmt.Errorf("%s exists and is not a named pipe", path)
+			}
+			os.Remove(path)
+		}
+		if err := makeFifo(path); err != nil {
+			return fmt.Errorf("failed to create %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// Handle `painika bridge`: answer prompts written to named pipes or a local
+// socket, one JSON line per reply, so editors can talk to painika with
+// nothing more than file reads and writes
+func runBridge(args []string) {
+	bridge, err := parseBridgeArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("Usage: painika bridge [--fifo <dir> | --socket <path>]")
+		exit(2)
+	}
+	renderer = PlainRenderer{}
+
+	var listener net.Listener
+	var cleanup func()
+	if bridge.SocketPath != "" {
+		// A socket left behind by a crashed bridge would refuse connections
+		if info, err := os.Stat(bridge.SocketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(bridge.SocketPath)
+		}
+		if listener, err = net.Listen("unix", bridge.SocketPath); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		cleanup = func() { listener.Close() }
+	} else {
+		if err := createBridgeFifos(bridge.FifoDir); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		cleanup = func() {
+			os.Remove(filepath.Join(bridge.FifoDir, "in"))
+			os.Remove(filepath.Join(bridge.FifoDir, "out"))
+		}
+	}
+
+	var closing atomic.Bool
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		closing.Store(true)
+		fmt.Println("\n🛑 Bridge closed")
+		cleanup()
+		cleanupAndExit()
+	}()
+
+	config := loadConfig()
+	config.SystemContext = sessionContext()
+	if getEnv("SERVER_URL", "") == "" {
+		fmt.Println("🔄 Starting server...")
+		if config.ServerURL, err = startBatchServer(); err != nil {
+			fmt.Printf("❌ Failed to start server: %v\n", err)
+			cleanup()
+			stopBatchServers()
+			exit(1)
+		}
+	}
+
+	session := &bridgeSession{client: NewClient(config)}
+	if err := session.client.InitSession(); err != nil {
+		fmt.Printf("❌ Failed to start a session: %v\n", err)
+		cleanup()
+		stopBatchServers()
+		exit(1)
+	}
+
+	if listener != nil {
+		fmt.Printf("🌉 Bridge ready on %s: write one prompt per line, read one JSON line per reply\n", bridge.SocketPath)
+		err = session.serveSocket(listener)
+	} else {
+		fmt.Printf("🌉 Bridge ready: write prompts to %s, read replies from %s\n",
+			filepath.Join(bridge.FifoDir, "in"), filepath.Join(bridge.FifoDir, "out"))
+		err = session.serveFifos(bridge.FifoDir)
+	}
+	if closing.Load() {
+		// Closing the listener ended the loop; the signal handler exits
+		select {}
+	}
+	fmt.Printf("❌ Bridge stopped: %v\n", err)
+	cleanup()
+	stopBatchServers()
+	exit(1)
+}
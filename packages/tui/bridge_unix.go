@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// Create a named pipe for the bridge
+func makeFifo(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// Windows has no named pipes in the file system; the bridge uses a socket there
+func makeFifo(path string) error {
+	return fmt.Errorf("named pipes are not supported on Windows, use painika bridge --socket <path>")
+}
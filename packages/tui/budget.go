@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fraction of the budget at which a warning is shown
+const budgetWarnRatio = 0.8
+
+// Budget state for the session
+var budgetWarned, budgetExplained bool
+
+// Load the hard spend cap in USD from MAX_SESSION_COST or max_session_cost
+// in the config file; 0 means no cap
+func budgetConfig() (float64, error) {
+	value := getEnv("MAX_SESSION_COST", "")
+	if value == "" {
+		config, err := loadUserConfig()
+		if err != nil {
+			return 0, err
+		}
+		return config.MaxSessionCost, nil
+	}
+
+	limit, err := strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid MAX_SESSION_COST %q (expected an amount in USD)", value)
+	}
+	return limit, nil
+}
+
+// Estimated spend so far; local models are free
+func (c *Client) SessionCost() (float64, error) {
+	if c.config.Provider == "ollama" {
+		return 0, nil
+	}
+	usage, err := c.GetTokenUsage()
+	if err != nil {
+		return 0, err
+	}
+	return estimateCost(usage.Total), nil
+}
+
+// Refuse model calls once the session budget is spent
+func (c *Client) checkBudget() error {
+	if c.config.MaxSessionCost <= 0 {
+		return nil
+	}
+	cost, err := c.SessionCost()
+	if err != nil {
+		return nil // Let the request itself report the server problem
+	}
+	if cost >= c.config.MaxSessionCost {
+		return &ChatError{
+			Kind:    ErrBudget,
+			Message: fmt.Sprintf("$%.4f of $%.2f spent", cost, c.config.MaxSessionCost),
+		}
+	}
+	return nil
+}
+
+// Warn once when spend crosses the warning threshold
+func warnBudget(client *Client) {
+	limit := client.config.MaxSessionCost
+	if limit <= 0 || budgetWarned {
+		return
+	}
+	cost, err := client.SessionCost()
+	if err != nil || cost < limit*budgetWarnRatio {
+		return
+	}
+	budgetWarned = true
+	fmt.Printf("⚠️  $%.4f of the $%.2f session budget used\n\n", cost, limit)
+}
+
+// Explain the blocked state and offer an export; the session stays read-only
+func explainBudget(client *Client, err *ChatError) {
+	if budgetExplained {
+		fmt.Printf("\n💸 %s (%s). Model calls are blocked; history, search, and export still work.\n\n", err.Summary(), err.Message)
+		return
+	}
+	budgetExplained = true
+
+	fmt.Printf("\n💸 %s (%s).\n", err.Summary(), err.Message)
+	fmt.Println("   Further model calls are blocked for this session. You can still use")
+	fmt.Println("   history, /context, /search, /flags, and /export (read-only).")
+	fmt.Print("   Export the conversation now? [y/N] ")
+
+	if answer, ok := stdin.ReadLine(); ok && strings.HasPrefix(strings.ToLower(answer), "y") {
+		exportConversation(client, "")
+		return
+	}
+	fmt.Println()
+}
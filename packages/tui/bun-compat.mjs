@@ -0,0 +1,124 @@
+// Stand-in for the Bun APIs the server bundle uses, so Node.js and Deno can
+// run it when Bun is not installed: node bun-compat.mjs <bundle>
+import { spawn as spawnProcess } from "node:child_process";
+import { existsSync, statSync } from "node:fs";
+import { mkdir, readFile, writeFile } from "node:fs/promises";
+import { createServer } from "node:http";
+import { Buffer } from "node:buffer";
+import path from "node:path";
+import process from "node:process";
+import { Readable } from "node:stream";
+import { pathToFileURL } from "node:url";
+
+// Node's incoming request as a fetch Request
+async function toRequest(req) {
+  const headers = new Headers();
+  for (const [name, value] of Object.entries(req.headers)) {
+    for (const item of Array.isArray(value) ? value : [value]) {
+      headers.append(name, item);
+    }
+  }
+  let body;
+  if (req.method !== "GET" && req.method !== "HEAD") {
+    const chunks = [];
+    for await (const chunk of req) {
+      chunks.push(chunk);
+    }
+    body = Buffer.concat(chunks);
+  }
+  return new Request(`http://${req.headers.host || "localhost"}${req.url}`, {
+    method: req.method,
+    headers,
+    body
+  });
+}
+
+// Write a fetch Response back, streaming its body as it is produced
+async function sendResponse(response, res) {
+  const headers = {};
+  response.headers.forEach((value, name) => {
+    headers[name] = value;
+  });
+  res.writeHead(response.status, headers);
+  if (response.body) {
+    for await (const chunk of response.body) {
+      res.write(chunk);
+    }
+  }
+  res.end();
+}
+
+// Bun.serve: a fetch handler on a port or a Unix domain socket
+function serve({ fetch, hostname, port, unix }) {
+  if (globalThis.Deno) {
+    const onListen = () => {};
+    return Deno.serve(unix ? { path: unix, onListen } : { hostname, port, onListen }, fetch);
+  }
+  const server = createServer(async (req, res) => {
+    try {
+      await sendResponse(await fetch(await toRequest(req)), res);
+    } catch (error) {
+      console.error(error);
+      res.destroy();
+    }
+  });
+  if (unix) {
+    server.listen(unix);
+  } else {
+    server.listen(port, hostname);
+  }
+  return server;
+}
+
+// Bun.spawn: stdout and stderr as web streams, exitCode once exited resolves.
+// exited follows the process itself, not its pipes, which children it left
+// behind may still hold open.
+function spawn(cmd, options = {}) {
+  const child = spawnProcess(cmd[0], cmd.slice(1), {
+    env: options.env,
+    stdio: ["ignore", "pipe", "pipe"]
+  });
+  const proc = {
+    stdout: Readable.toWeb(child.stdout),
+    stderr: Readable.toWeb(child.stderr),
+    exitCode: null,
+    kill: (signal) => child.kill(signal)
+  };
+  proc.exited = new Promise((resolve) => {
+    child.on("error", () => {
+      proc.exitCode = 127;
+      resolve(proc.exitCode);
+    });
+    child.on("exit", (code) => {
+      proc.exitCode = code ?? 1;
+      resolve(proc.exitCode);
+    });
+  });
+  return proc;
+}
+
+// Bun.file: the lazy file reference the tools read through
+function file(target) {
+  return {
+    exists: async () => existsSync(target),
+    text: () => readFile(target, "utf8"),
+    get size() {
+      try {
+        return statSync(target).size;
+      } catch {
+        return 0;
+      }
+    }
+  };
+}
+
+// Bun.write: creates missing parent directories like Bun does
+async function write(target, content) {
+  await mkdir(path.dirname(target), { recursive: true });
+  await writeFile(target, content);
+  return Buffer.byteLength(content);
+}
+
+globalThis.Bun = { serve, spawn, file, write };
+
+await import(pathToFileURL(path.resolve(process.argv[2])).href);
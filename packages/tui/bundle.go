@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Named set of files and a note, attached together with /bundle use
+type ContextBundle struct {
+	Name      string   `json:"name"`
+	Files     []string `json:"files"` // Absolute paths, read again on each use
+	Note      string   `json:"note,omitempty"`
+	CreatedAt string   `json:"createdAt"` // ISO 8601 format
+}
+
+// Get the path of the bundle store
+func bundlesPath() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bundles.json"), nil
+}
+
+// Load saved bundles (an empty list if none exist yet)
+func loadBundles() ([]ContextBundle, error) {
+	path, err := bundlesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bundles []ContextBundle
+	if err := json.Unmarshal(data, &bundles); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return bundles, nil
+}
+
+// Save bundles to the store, sorted by name
+func saveBundles(bundles []ContextBundle) error {
+	path, err := bundlesPath()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].Name < bundles[j].Name })
+	data, err := json.MarshalIndent(bundles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Index of the bundle with this name, or -1
+func findBundle(bundles []ContextBundle, name string) int {
+	for i, bundle := range bundles {
+		if strings.EqualFold(bundle.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Split arguments on spaces, keeping "quoted text" together
+func splitQuotedArgs(args string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	var quote rune
+	inField := false
+	for _, r := range args {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inField = r, true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote")
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields, nil
+}
+
+// A bundle path as the user would type it: relative when under the current directory
+func displayPath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// Handle /bundle save <name> @file... ["note"]
+func saveBundle(fields []string) {
+	if len(fields) == 0 {
+		fmt.Println("Usage: /bundle save <name> @file... [\"note\"]")
+		fmt.Println()
+		return
+	}
+	name := fields[0]
+	bundle := ContextBundle{Name: name, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	var notes []string
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "@") {
+			notes = append(notes, field)
+			continue
+		}
+		path, err := filepath.Abs(strings.TrimPrefix(field, "@"))
+		if err == nil {
+			var info os.FileInfo
+			if info, err = os.Stat(path); err == nil && info.IsDir() {
+				err = fmt.Errorf("is a directory (use /attach-dir)")
+			}
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n\n", field, err)
+			return
+		}
+		if !containsTag(bundle.Files, path) {
+			bundle.Files = append(bundle.Files, path)
+		}
+	}
+	bundle.Note = strings.Join(notes, " ")
+	if len(bundle.Files) == 0 && bundle.Note == "" {
+		fmt.Println("❌ Name at least one @file or a note to save")
+		fmt.Println()
+		return
+	}
+
+	bundles, err := loadBundles()
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	verb := "Saved"
+	if i := findBundle(bundles, name); i >= 0 {
+		bundles = append(bundles[:i], bundles[i+1:]...)
+		verb = "Replaced"
+	}
+	if err := saveBundles(append(bundles, bundle)); err != nil {
+		fmt.Printf("❌ Error saving bundle: %v\n\n", err)
+		return
+	}
+	fmt.Printf("📦 %s bundle %s: %d file(s)", verb, name, len(bundle.Files))
+	if bundle.Note != "" {
+		fmt.Print(" and a note")
+	}
+	fmt.Printf("\n💡 Attach it with /bundle use %s\n\n", name)
+}
+
+// Handle /bundle use <name>: read the files as they are now and queue them
+// for the next message
+func useBundle(client *Client, bundle ContextBundle) {
+	var paths []string
+	for _, path := range bundle.Files {
+		paths = append(paths, displayPath(path))
+	}
+	_, attachments, err := startupMessage("", paths)
+	if err != nil {
+		fmt.Printf("❌ Bundle %s: %v\n\n", bundle.Name, err)
+		return
+	}
+	if bundle.Note != "" {
+		note := Attachment{Name: bundle.Name + " (note)", Text: fmt.Sprintf("Note on the %s files: %s", bundle.Name, bundle.Note)}
+		attachments = append([]Attachment{note}, attachments...)
+	}
+
+	if !preflightAttachments(client, attachments) {
+		return
+	}
+	pendingAttachments = append(pendingAttachments, attachments...)
+	fmt.Printf("📦 Bundle %s attached to your next message\n\n", bundle.Name)
+}
+
+// Handle /bundle [list] | save | use | show | delete
+func handleBundle(client *Client, args string) {
+	fields, err := splitQuotedArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	sub := ""
+	if len(fields) > 0 {
+		sub, fields = strings.ToLower(fields[0]), fields[1:]
+	}
+	if sub == "save" {
+		saveBundle(fields)
+		return
+	}
+
+	bundles, err := loadBundles()
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	if sub == "" || sub == "list" {
+		if len(bundles) == 0 {
+			fmt.Println("📦 No bundles yet. Save one with /bundle save <name> @file... [\"note\"]")
+			fmt.Println()
+			return
+		}
+		fmt.Printf("📦 Bundles (%d):\n", len(bundles))
+		for _, bundle := range bundles {
+			fmt.Printf("   %-16s %d file(s)  %s\n", bundle.Name, len(bundle.Files), truncateWidth(bundle.Note, 50))
+		}
+		fmt.Println()
+		return
+	}
+
+	if len(fields) != 1 || (sub != "use" && sub != "show" && sub != "delete") {
+		fmt.Println("Usage: /bundle [list] | /bundle save <name> @file... [\"note\"] | /bundle use|show|delete <name>")
+		fmt.Println()
+		return
+	}
+	i := findBundle(bundles, fields[0])
+	if i < 0 {
+		fmt.Printf("❌ Unknown bundle: %s (type /bundle to list)\n\n", fields[0])
+		return
+	}
+
+	switch sub {
+	case "use":
+		useBundle(client, bundles[i])
+	case "show":
+		bundle := bundles[i]
+		fmt.Printf("📦 %s (saved %s)\n", bundle.Name, bundle.CreatedAt)
+		for _, path := range bundle.Files {
+			fmt.Printf("   %s\n", displayPath(path))
+		}
+		if bundle.Note != "" {
+			fmt.Printf("   Note: %s\n", bundle.Note)
+		}
+		fmt.Println()
+	case "delete":
+		name := bundles[i].Name
+		if err := saveBundles(append(bundles[:i], bundles[i+1:]...)); err != nil {
+			fmt.Printf("❌ Error saving bundles: %v\n\n", err)
+			return
+		}
+		fmt.Printf("🗑️  Deleted bundle %s\n\n", name)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// What the current model supports, as the server adapts requests to it
+func (c *Client) Capabilities() (*ModelCapabilities, error) {
+	resp, err := c.client.Get(c.config.ServerURL + "/capabilities")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result CapabilitiesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if !result.Success || result.Capabilities == nil {
+		return nil, fmt.Errorf("failed to get capabilities: %s", result.Error)
+	}
+
+	return result.Capabilities, nil
+}
+
+// Say what works differently with the current model; servers without
+// capabilities say nothing
+func printCapabilityNotice(client *Client) {
+	capabilities, err := client.Capabilities()
+	if err != nil || capabilities.Tools {
+		return
+	}
+	renderer.Notice(fmt.Sprintf("🧩 %s has no native tool calling; tools are described in the prompt instead", client.config.Model))
+}
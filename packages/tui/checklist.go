@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress of one plan step
+type StepState int
+
+const (
+	StepPending StepState = iota
+	StepActive
+	StepDone
+)
+
+// Step of a plan the AI wrote out as a numbered list
+type ChecklistStep struct {
+	Text  string
+	State StepState
+}
+
+// Plan being tracked across turns
+type Checklist struct {
+	Steps []ChecklistStep
+}
+
+// Plan from the AI's most recent numbered list of steps (nil if none)
+var activePlan *Checklist
+
+var (
+	numberedItem   = regexp.MustCompile(`^\s*(\d+)[.)]\s+(.+)$`)
+	planIntro      = regexp.MustCompile(`(?i)\b(plan|steps?|i'll|i will|let me|going to)\b`)
+	stepDone       = regexp.MustCompile(`(?i)\bstep\s+(\d+)\b[^.\n]*\b(done|complete|completed|finished)\b|\b(completed|finished|done with)\s+step\s+(\d+)\b`)
+	checkedMarker  = regexp.MustCompile(`^(✅|✔️?|\[x\]|~~)\s*`)
+	backtickedWord = regexp.MustCompile("`([^`]+)`")
+)
+
+// Count completed steps
+func (c *Checklist) Done() int {
+	done := 0
+	for _, step := range c.Steps {
+		if step.State == StepDone {
+			done++
+		}
+	}
+	return done
+}
+
+// Check whether every step is done
+func (c *Checklist) Finished() bool {
+	return c.Done() == len(c.Steps)
+}
+
+// Index of the first step not yet done (-1 when finished)
+func (c *Checklist) current() int {
+	for i, step := range c.Steps {
+		if step.State != StepDone {
+			return i
+		}
+	}
+	return -1
+}
+
+// Mark step i done, along with earlier steps already in progress
+func (c *Checklist) complete(i int) {
+	for j := 0; j < i; j++ {
+		if c.Steps[j].State == StepActive {
+			c.Steps[j].State = StepDone
+		}
+	}
+	c.Steps[i].State = StepDone
+}
+
+// Render the checklist for the terminal
+func (c *Checklist) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 Plan (%d/%d done)", c.Done(), len(c.Steps))
+	for i, step := range c.Steps {
+		icon := "⬜"
+		switch step.State {
+		case StepActive:
+			icon = "⏳"
+		case StepDone:
+			icon = "✅"
+		}
+		fmt.Fprintf(&b, "\n   %s %d. %s", icon, i+1, truncateWidth(step.Text, termWidth()-10))
+	}
+	return b.String()
+}
+
+// Find a plan in a reply: a numbered list of at least three steps, counting
+// from 1, introduced by text that talks about a plan or steps
+func parseChecklist(content string) *Checklist {
+	lines := strings.Split(content, "\n")
+	for start := 0; start < len(lines); start++ {
+		match := numberedItem.FindStringSubmatch(lines[start])
+		if match == nil || match[1] != "1" {
+			continue
+		}
+
+		var steps []ChecklistStep
+		for i := start; i < len(lines); i++ {
+			item := numberedItem.FindStringSubmatch(lines[i])
+			if item == nil {
+				// Wrapped or indented detail lines belong to the step above
+				if strings.TrimSpace(lines[i]) != "" && strings.HasPrefix(lines[i], " ") {
+					continue
+				}
+				break
+			}
+			if n, _ := strconv.Atoi(item[1]); n != len(steps)+1 {
+				break
+			}
+
+			text := strings.TrimSpace(item[2])
+			state := StepPending
+			if marker := checkedMarker.FindString(text); marker != "" {
+				text, state = strings.TrimSpace(strings.TrimPrefix(text, marker)), StepDone
+			}
+			steps = append(steps, ChecklistStep{Text: strings.Trim(text, "*~ "), State: state})
+		}
+
+		intro := strings.Join(lines[:start], "\n")
+		if len(steps) >= 3 && planIntro.MatchString(intro) {
+			return &Checklist{Steps: steps}
+		}
+	}
+	return nil
+}
+
+// Files, commands, and identifiers a step mentions, for matching tool calls
+func stepTargets(text string) []string {
+	var targets []string
+	for _, match := range backtickedWord.FindAllStringSubmatch(text, -1) {
+		targets = append(targets, match[1])
+	}
+	for _, word := range strings.Fields(text) {
+		word = strings.Trim(word, "`'\",.:;()")
+		if strings.ContainsAny(word, "./") && len(word) > 2 {
+			targets = append(targets, word)
+		}
+	}
+	return targets
+}
+
+// Check whether a tool call touches one of a step's targets
+func callMatches(call ToolCall, targets []string) bool {
+	for _, key := range []string{"path", "command"} {
+		value, _ := call.Parameters[key].(string)
+		if value == "" {
+			continue
+		}
+		for _, target := range targets {
+			if strings.Contains(value, target) || (key == "path" && filepath.Base(value) == filepath.Base(target)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Update the plan from one turn: a new plan replaces the old one, steps the
+// AI reports as done or whose files and commands the tool calls touch are
+// checked off, and other tool activity marks the current step in progress
+func (c *Checklist) update(messages []Message) bool {
+	changed := false
+	matched := false
+
+	for _, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+
+		for _, match := range stepDone.FindAllStringSubmatch(msg.Content, -1) {
+			n, _ := strconv.Atoi(match[1] + match[4])
+			if n >= 1 && n <= len(c.Steps) && c.Steps[n-1].State != StepDone {
+				c.complete(n - 1)
+				changed, matched = true, true
+			}
+		}
+
+		for _, call := range msg.ToolCalls {
+			for i, step := range c.Steps {
+				if step.State != StepDone && callMatches(call, stepTargets(step.Text)) {
+					c.complete(i)
+					changed, matched = true, true
+				}
+			}
+		}
+
+		if len(msg.ToolCalls) > 0 && !matched {
+			if i := c.current(); i >= 0 && c.Steps[i].State == StepPending {
+				c.Steps[i].State = StepActive
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// Follow the plan through a turn and show the checklist when it changes
+func trackPlan(messages []Message) {
+	// A reply restating the plan with checked items updates it in place
+	var latest *Checklist
+	for _, msg := range messages {
+		if msg.Role == "assistant" {
+			if plan := parseChecklist(msg.Content); plan != nil {
+				latest = plan
+			}
+		}
+	}
+
+	changed := false
+	switch {
+	case latest != nil && activePlan != nil && len(latest.Steps) == len(activePlan.Steps) && latest.Done() > 0:
+		for i, step := range latest.Steps {
+			if step.State == StepDone && activePlan.Steps[i].State != StepDone {
+				activePlan.complete(i)
+				changed = true
+			}
+		}
+	case latest != nil:
+		activePlan, changed = latest, true
+	case activePlan != nil && !activePlan.Finished():
+		changed = activePlan.update(messages)
+	}
+
+	if changed {
+		renderer.Notice(activePlan.String() + "\n")
+	}
+}
+
+// Handle /plan [done <n>|undo <n>|clear]
+func handlePlan(args string) {
+	if activePlan == nil {
+		fmt.Println("📋 No plan yet (numbered steps from the AI are tracked automatically)")
+		fmt.Println()
+		return
+	}
+
+	action, arg, _ := strings.Cut(strings.TrimSpace(args), " ")
+	switch action {
+	case "":
+	case "clear":
+		activePlan = nil
+		fmt.Println("📋 Plan cleared")
+		fmt.Println()
+		return
+	case "done", "undo":
+		n, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || n < 1 || n > len(activePlan.Steps) {
+			fmt.Printf("❌ Invalid step: %s (plan has %d steps)\n\n", arg, len(activePlan.Steps))
+			return
+		}
+		if action == "done" {
+			activePlan.complete(n - 1)
+		} else {
+			activePlan.Steps[n-1].State = StepPending
+		}
+	default:
+		fmt.Println("Usage: /plan [done <n>|undo <n>|clear]")
+		fmt.Println()
+		return
+	}
+
+	fmt.Println(activePlan)
+	fmt.Println()
+}
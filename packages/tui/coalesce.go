@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits on what `painika batch --coalesce` merges into one model call
+const (
+	coalesceMaxTokens  = 200 // Prompts longer than this always run alone
+	coalesceMaxPrompts = 5
+)
+
+// Heading each merged request's answer starts with
+var coalescedHeading = regexp.MustCompile(`(?m)^#{1,6}\s*Request\s+(\d+)\b.*$`)
+
+// Existing files a prompt mentions, sorted; URLs and directories are ignored
+func promptFiles(prompt string) []string {
+	seen := map[string]bool{}
+	var files []string
+	for _, match := range linkPattern.FindAllString(prompt, -1) {
+		if strings.Contains(match, "://") {
+			continue
+		}
+		path, _ := splitLinkLine(strings.TrimRight(match, linkTrailing))
+		resolved := resolveLinkPath(path, "")
+		if info, err := os.Stat(resolved); err != nil || info.IsDir() || seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		files = append(files, resolved)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Group prompt indexes into model calls: consecutive short prompts about
+// the same files share a call, everything else runs alone. Prompts that
+// name no file never merge, since nothing says they are related.
+func coalescePrompts(prompts []string, tok Tokenizer) [][]int {
+	var groups [][]int
+	lastKey := ""
+	for i, prompt := range prompts {
+		key := ""
+		if tok.Count(prompt) <= coalesceMaxTokens {
+			key = strings.Join(promptFiles(prompt), "\n")
+		}
+		if n := len(groups); key != "" && key == lastKey && len(groups[n-1]) < coalesceMaxPrompts {
+			groups[n-1] = append(groups[n-1], i)
+			continue
+		}
+		groups = append(groups, []int{i})
+		lastKey = key
+	}
+	return groups
+}
+
+// One message asking for every prompt in a group, answered in order
+func coalescedPrompt(prompts []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Handle these %d requests in order. They concern the same files. "+
+		"Start the answer to each with a line \"### Request <n>\" and nothing else on it.\n", len(prompts))
+	for i, prompt := range prompts {
+		fmt.Fprintf(&b, "\n### Request %d\n%s\n", i+1, prompt)
+	}
+	return b.String()
+}
+
+// Split a coalesced reply into one answer per request. If the headings
+// aren't all there, every request gets the whole reply.
+func splitCoalescedReply(reply string, count int) []string {
+	answers := make([]string, count)
+	matches := coalescedHeading.FindAllStringSubmatchIndex(reply, -1)
+	found := 0
+	for i, match := range matches {
+		n, _ := strconv.Atoi(reply[match[2]:match[3]])
+		if n != found+1 {
+			continue
+		}
+		end := len(reply)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		answers[n-1] = strings.TrimSpace(reply[match[1]:end])
+		found++
+	}
+	if found != count {
+		for i := range answers {
+			answers[i] = reply
+		}
+	}
+	return answers
+}
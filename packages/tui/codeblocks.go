@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fenced code block from a response
+type CodeBlock struct {
+	Lang string // First word of the info string after the opening fence (e.g. "go", "bash")
+	Path string // File the block is written for, from path=... in the info string
+	Code string
+}
+
+// Attributes in an info string that name the block's file
+var codeBlockPathKeys = []string{"path", "file", "filename"}
+
+// Split an info string such as `go path=cmd/main.go` into the language and
+// the file it targets ("" for none); paths may be quoted
+func parseInfoString(info string) (string, string) {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	lang := fields[0]
+	if strings.Contains(lang, "=") {
+		lang, fields = "", append([]string{""}, fields...)
+	}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if ok && containsTag(codeBlockPathKeys, strings.ToLower(key)) {
+			return lang, strings.Trim(value, `"'`)
+		}
+	}
+	return lang, ""
+}
+
+// Extract fenced code blocks from markdown, in order
+func extractCodeBlocks(content string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var lines []string
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if current != nil {
+				lines = append(lines, line)
+			}
+			continue
+		}
+
+		if current == nil {
+			lang, path := parseInfoString(strings.TrimPrefix(trimmed, "```"))
+			current = &CodeBlock{Lang: lang, Path: path}
+			lines = nil
+		} else {
+			current.Code = strings.Join(lines, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+		}
+	}
+
+	return blocks
+}
+
+// Get the content of the most recent assistant reply
+func lastAssistantMessage(client *Client) (string, error) {
+	conversation, err := client.GetConversation()
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(conversation.Messages) - 1; i >= 0; i-- {
+		msg := conversation.Messages[i]
+		if msg.Role == "assistant" && strings.TrimSpace(msg.Content) != "" {
+			return msg.Content, nil
+		}
+	}
+	return "", fmt.Errorf("no assistant response yet")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split a slash command into its name and argument string
+func splitCommand(input string) (string, string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "/")
+	name, args, _ := strings.Cut(input, " ")
+	return strings.ToLower(name), strings.TrimSpace(args)
+}
+
+// Handle slash commands like /remember
+func handleCommand(client *Client, input string) {
+	name, args := splitCommand(input)
+
+	switch name {
+	case "remember":
+		rememberFact(args)
+	case "memories":
+		manageMemories(args)
+	case "verify":
+		configureVerify(client, args)
+	case "context":
+		showContextBreakdown(client)
+	case "preview":
+		showPreview(client, args)
+	case "serverlog":
+		showServerLog(args)
+	case "dry-run", "dryrun":
+		runDryRun(client, args)
+	case "send-to-pane":
+		sendToPane(client, args)
+	case "run":
+		runShellBlock(client, args)
+	case "write":
+		handleWrite(client, args)
+	case "open":
+		handleOpen(client, args)
+	case "set":
+		handleSet(client, args)
+	case "issue":
+		handleIssue(client, args)
+	case "flag":
+		flagMessage(client, args)
+	case "flags":
+		showFlags(client, args)
+	case "search":
+		searchConversation(client, args)
+	case "export":
+		exportConversation(client, args)
+	case "gist":
+		shareGist(client, args)
+	case "persona":
+		handlePersona(client, args)
+	case "translate":
+		translateLastResponse(client, args)
+	case "job", "jobs":
+		handleJob(args)
+	case "plan":
+		handlePlan(args)
+	case "tag", "tags":
+		handleTag(client, args)
+	case "refresh-context":
+		refreshContext(client)
+	case "history":
+		if args == "full" {
+			showFullHistory(client)
+		} else {
+			showConversationHistory(client)
+		}
+	case "drop":
+		handleDrop(client, args)
+	case "usage":
+		handleUsage(client, args)
+	case "incognito":
+		handleIncognito(client, args)
+	case "env":
+		handleEnv(client, args)
+	case "fork":
+		forkConversation(client, args)
+	case "debug":
+		handleDebug(client, args)
+	case "attach-dir":
+		handleAttachDir(client, args)
+	case "bundle", "bundles":
+		handleBundle(client, args)
+	default:
+		fmt.Printf("❓ Unknown command: /%s (type 'help' for commands)\n\n", name)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Project config file, read from the current directory
+const projectConfigFile = ".painika.json"
+
+// Structured settings from ~/.painika/config.json and the project's .painika.json
+type UserConfig struct {
+	Personas       []Persona  `json:"personas,omitempty"`
+	MaxSessionCost float64    `json:"max_session_cost,omitempty"` // Hard spend cap in USD
+	Model          string     `json:"model,omitempty"`
+	Temperature    *float64   `json:"temperature,omitempty"`
+	HistoryWindow  string     `json:"history_window,omitempty"` // Same format as HISTORY_WINDOW
+	ApproveTools   []string   `json:"approve_tools,omitempty"`  // Same as APPROVE_TOOLS; a project's are added
+	Hooks          HookConfig `json:"hooks,omitempty"`          // Settings for `painika hooks`
+
+	// Policies add up across files, so a project can tighten but not loosen them
+	GuardedCommands []string `json:"guarded_commands,omitempty"` // Added to GUARDED_COMMANDS
+	ProtectedPaths  []string `json:"protected_paths,omitempty"`  // Added to PROTECTED_PATHS
+	RedactPatterns  []string `json:"redact_patterns,omitempty"`  // Added to REDACT_PATTERNS
+
+	// Per-tool timeouts and output limits, by tool name; a project's limits only apply when lower
+	ToolLimits map[string]ToolLimitConfig `json:"tool_limits,omitempty"`
+
+	// JSON mode, tool_choice, and parallel_tool_calls by model name; a project's settings win when set
+	RequestShaping map[string]RequestShapingConfig `json:"request_shaping,omitempty"`
+
+	// Only read from the global config (see syncSettings)
+	Sync SyncConfig `json:"sync,omitempty"`
+
+	// Per-turn change limits; a project's limits only apply when lower
+	MaxLinesPerTurn int `json:"max_lines_per_turn,omitempty"` // Same as MAX_LINES_PER_TURN
+	MaxFilesPerTurn int `json:"max_files_per_turn,omitempty"` // Same as MAX_FILES_PER_TURN
+}
+
+// Read one config file; a missing file is an empty config
+func readUserConfig(path string) (UserConfig, error) {
+	var config UserConfig
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	// Report every problem with its position instead of half-loading the file
+	if errs := validateConfig(path, data); len(errs) > 0 {
+		return config, errs
+	}
+	data, errs := expandConfigVars(path, data)
+	if len(errs) > 0 {
+		return config, errs
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// The user's own config file, "" when the home directory is unknown
+func globalConfigPath() string {
+	dir, err := painikaDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// Load the global config, then the project config on top of it.
+// Project personas replace global ones with the same name; other project settings win when set,
+// except the ones that keep a repository in check: a project adds tools to approve_tools and
+// may lower max_session_cost, the per-turn change limits, and tool_limits, never raise them.
+func loadUserConfig() (UserConfig, error) {
+	var merged UserConfig
+
+	global := globalConfigPath()
+	for _, path := range userConfigPaths() {
+		config, err := readUserConfig(path)
+		if err != nil {
+			return merged, err
+		}
+		merged.Personas = mergePersonas(merged.Personas, config.Personas)
+		if path != global {
+			tightenUserConfig(&merged, config)
+		} else {
+			// The user's own file sets them outright
+			if config.MaxSessionCost > 0 {
+				merged.MaxSessionCost = config.MaxSessionCost
+			}
+			if config.ApproveTools != nil {
+				merged.ApproveTools = config.ApproveTools
+			}
+			merged.ToolLimits = mergeToolLimits(merged.ToolLimits, config.ToolLimits)
+			if config.MaxLinesPerTurn > 0 {
+				merged.MaxLinesPerTurn = config.MaxLinesPerTurn
+			}
+			if config.MaxFilesPerTurn > 0 {
+				merged.MaxFilesPerTurn = config.MaxFilesPerTurn
+			}
+		}
+		if config.Model != "" {
+			merged.Model = config.Model
+		}
+		if config.Temperature != nil {
+			merged.Temperature = config.Temperature
+		}
+		if config.HistoryWindow != "" {
+			merged.HistoryWindow = config.HistoryWindow
+		}
+		merged.Hooks = mergeHookConfig(merged.Hooks, config.Hooks)
+		merged.RequestShaping = mergeRequestShaping(merged.RequestShaping, config.RequestShaping)
+		merged.GuardedCommands = append(merged.GuardedCommands, config.GuardedCommands...)
+		merged.ProtectedPaths = append(merged.ProtectedPaths, config.ProtectedPaths...)
+		merged.RedactPatterns = append(merged.RedactPatterns, config.RedactPatterns...)
+	}
+	return merged, nil
+}
+
+// Apply a project file's safety settings only where they make the merged
+// config stricter, so a cloned repository can't switch approvals off or
+// lift the limits the user set
+func tightenUserConfig(merged *UserConfig, project UserConfig) {
+	for _, tool := range project.ApproveTools {
+		if !containsTag(merged.ApproveTools, tool) {
+			merged.ApproveTools = append(merged.ApproveTools, tool)
+		}
+	}
+	if project.MaxSessionCost > 0 && (merged.MaxSessionCost == 0 || project.MaxSessionCost < merged.MaxSessionCost) {
+		merged.MaxSessionCost = project.MaxSessionCost
+	}
+	merged.MaxLinesPerTurn = lowerLimit(merged.MaxLinesPerTurn, project.MaxLinesPerTurn, defaultMaxLinesPerTurn)
+	merged.MaxFilesPerTurn = lowerLimit(merged.MaxFilesPerTurn, project.MaxFilesPerTurn, defaultMaxFilesPerTurn)
+	merged.ToolLimits = tightenToolLimits(merged.ToolLimits, project.ToolLimits)
+}
+
+// A limit after a project asks for value: kept unless value is set and
+// below the current limit, or the default when none is set (0 is no limit)
+func lowerLimit(current, value, fallback int) int {
+	effective := current
+	if effective == 0 {
+		effective = fallback
+	}
+	if value > 0 && (effective == 0 || value < effective) {
+		return value
+	}
+	return current
+}
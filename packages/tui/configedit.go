@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Environment variables that win over a config key when both are set
+var configEnvOverrides = map[string]string{
+	"model":              "MODEL",
+	"history_window":     "HISTORY_WINDOW",
+	"approve_tools":      "APPROVE_TOOLS",
+	"max_session_cost":   "MAX_SESSION_COST",
+	"max_lines_per_turn": "MAX_LINES_PER_TURN",
+	"max_files_per_turn": "MAX_FILES_PER_TURN",
+}
+
+// Keys a later config file replaces rather than adds to
+var configReplacedKeys = []string{"model", "temperature", "history_window", "approve_tools", "max_session_cost", "max_lines_per_turn", "max_files_per_turn"}
+
+// Why each key set in a config file does not take effect right now: an
+// environment variable, or a config file loaded after it
+func configOverrides(path string) map[string]string {
+	overrides := map[string]string{}
+	for key, name := range configEnvOverrides {
+		if value := getEnv(name, ""); value != "" {
+			overrides[key] = fmt.Sprintf("%s=%s in the environment", name, value)
+		}
+	}
+
+	paths := userConfigPaths()
+	later := false
+	for _, other := range paths {
+		if later {
+			var keys map[string]json.RawMessage
+			if data, err := os.ReadFile(other); err == nil && json.Unmarshal(data, &keys) == nil {
+				for _, key := range configReplacedKeys {
+					if _, set := keys[key]; set && overrides[key] == "" {
+						overrides[key] = other
+					}
+				}
+			}
+		}
+		if sameFile(other, path) {
+			later = true
+		}
+	}
+	return overrides
+}
+
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Comment block at the top of the file being edited: what went wrong last
+// time, then every setting with what overrides it. Problems are at their
+// line in the file plus offset, the length of this block.
+func configEditHeader(path string, errs ConfigErrors, offset int) string {
+	var b strings.Builder
+	b.WriteString("// Editing " + path + "\n")
+	b.WriteString("// Lines starting with // are dropped when saved; save without changes to cancel.\n")
+	if len(errs) > 0 {
+		b.WriteString("//\n// ❌ Not saved, fix these first:\n")
+		for _, err := range errs {
+			where := fmt.Sprintf("line %d, column %d", err.Line+offset, err.Column)
+			if err.Key != "" {
+				where += ": " + err.Key
+			}
+			b.WriteString(fmt.Sprintf("//    %s: %s\n", where, err.Message))
+		}
+	}
+
+	overrides := configOverrides(path)
+	names := make([]string, 0, len(userConfigSchema.Fields))
+	for name := range userConfigSchema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.WriteString("//\n// Settings:\n")
+	for _, name := range names {
+		field := userConfigSchema.Fields[name]
+		kind := field.Type
+		if field.Items != nil {
+			kind = field.Items.Type + "[]"
+		}
+		b.WriteString(fmt.Sprintf("//   %-19s %-9s %s\n", name, kind, field.Doc))
+		if override := overrides[name]; override != "" {
+			b.WriteString(fmt.Sprintf("//   %-19s %-9s ⚠️  overridden by %s\n", "", "", override))
+		}
+	}
+	return b.String()
+}
+
+// Drop the comment lines
+func stripConfigComments(data []byte) []byte {
+	var kept [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			kept = append(kept, line)
+		}
+	}
+	return bytes.Join(kept, []byte("\n"))
+}
+
+// The user's editor, or one every system has
+func configEditor() []string {
+	if editor := strings.Fields(getEnv("VISUAL", getEnv("EDITOR", ""))); len(editor) > 0 {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// Write a config file whole, keeping the permissions of the one it replaces
+func writeConfigFile(path string, data []byte) error {
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Handle `painika config edit [file]`: open the config in $EDITOR with
+// every setting described in comments, and save it only once it validates
+func runConfigEdit(args []string) {
+	if len(args) > 1 {
+		fmt.Println("Usage: painika config edit [file]")
+		exit(2)
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Println("❌ painika config edit needs a terminal")
+		exit(1)
+	}
+
+	path := ""
+	if len(args) == 1 {
+		path = args[0]
+	} else if dir, err := painikaDir(); err == nil {
+		path = filepath.Join(dir, "config.json")
+	} else {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		content = []byte("{\n}\n")
+	} else if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	scratch, err := os.CreateTemp("", "painika-config-*.json")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	scratch.Close()
+	defer os.Remove(scratch.Name())
+
+	var errs ConfigErrors
+	for {
+		offset := strings.Count(configEditHeader(path, errs, 0), "\n")
+		header := configEditHeader(path, errs, offset)
+		if err := os.WriteFile(scratch.Name(), append([]byte(header), content...), 0600); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		// Start on the first problem, or on the first line of the file itself
+		line := offset + 1
+		if len(errs) > 0 {
+			line = errs[0].Line + offset
+		}
+		cmd := editorCommand(configEditor(), scratch.Name(), line)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("❌ The editor failed: %v\n", err)
+			exit(1)
+		}
+
+		edited, err := os.ReadFile(scratch.Name())
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		stripped := stripConfigComments(edited)
+		if bytes.Equal(bytes.TrimSpace(stripped), bytes.TrimSpace(content)) {
+			if len(errs) > 0 {
+				fmt.Printf("❌ %s was not saved; it still had %d problem(s)\n", path, len(errs))
+				exit(1)
+			}
+			fmt.Println("➖ No changes")
+			return
+		}
+		content = stripped
+
+		errs = validateConfig(path, content)
+		if len(errs) == 0 {
+			_, errs = expandConfigVars(path, content)
+		}
+		if len(errs) > 0 {
+			fmt.Printf("❌ %s has %d problem(s); reopening the editor\n", path, len(errs))
+			continue
+		}
+
+		if err := writeConfigFile(path, content); err != nil {
+			fmt.Printf("❌ Failed to save %s: %v\n", path, err)
+			exit(1)
+		}
+		fmt.Printf("✅ Saved %s\n", path)
+
+		var keys map[string]json.RawMessage
+		json.Unmarshal(content, &keys)
+		overrides := configOverrides(path)
+		for _, key := range configReplacedKeys {
+			if _, set := keys[key]; set && overrides[key] != "" {
+				fmt.Printf("⚠️  %s is overridden by %s\n", key, overrides[key])
+			}
+		}
+		return
+	}
+}
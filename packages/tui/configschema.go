@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Expected shape of a config value
+type configSchema struct {
+	Type     string // "object", "array", "string", "number", or "boolean"
+	Fields   map[string]*configSchema
+	Required []string
+	Items    *configSchema
+	Values   *configSchema // Objects keyed by free-form names (e.g. tool names)
+	Min, Max *float64
+	Doc      string // Shown by `painika config edit`
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// Schema for ~/.painika/config.json, .painika/config.json, and .painika.json
+var userConfigSchema = &configSchema{
+	Type: "object",
+	Fields: map[string]*configSchema{
+		"personas": {
+			Type: "array",
+			Doc:  "Custom personas (name, description, prompt, temperature, model, tools); replace built-ins with the same name",
+			Items: &configSchema{
+				Type:     "object",
+				Required: []string{"name"},
+				Fields: map[string]*configSchema{
+					"name":        {Type: "string"},
+					"description": {Type: "string"},
+					"prompt":      {Type: "string"},
+					"temperature": {Type: "number", Min: bound(0), Max: bound(2)},
+					"model":       {Type: "string"},
+					"tools":       {Type: "array", Items: &configSchema{Type: "string"}},
+				},
+			},
+		},
+		"max_session_cost":   {Type: "number", Min: bound(0), Doc: "Hard spend cap in USD"},
+		"model":              {Type: "string", Doc: "Model to use"},
+		"temperature":        {Type: "number", Min: bound(0), Max: bound(2), Doc: "Sampling temperature"},
+		"history_window":     {Type: "string", Doc: "History sent with each message, e.g. \"20 messages\" or \"8000 tokens\""},
+		"approve_tools":      {Type: "array", Items: &configSchema{Type: "string"}, Doc: "Tools that ask before they run"},
+		"guarded_commands":   {Type: "array", Items: &configSchema{Type: "string"}, Doc: "Destructive command patterns that must be typed back to run"},
+		"protected_paths":    {Type: "array", Items: &configSchema{Type: "string"}, Doc: "Globs the AI may not write to"},
+		"redact_patterns":    {Type: "array", Items: &configSchema{Type: "string"}, Doc: "Regexes redacted before /gist uploads"},
+		"max_lines_per_turn": {Type: "number", Min: bound(1), Doc: "Ask before a turn changes more lines than this"},
+		"max_files_per_turn": {Type: "number", Min: bound(1), Doc: "Ask before a turn changes more files than this"},
+		"tool_limits": {
+			Type: "object",
+			Doc:  "Per-tool timeout_seconds and max_output_kb, by tool name",
+			Values: &configSchema{
+				Type: "object",
+				Fields: map[string]*configSchema{
+					"timeout_seconds": {Type: "number", Min: bound(1)},
+					"max_output_kb":   {Type: "number", Min: bound(1)},
+				},
+			},
+		},
+		"request_shaping": {
+			Type: "object",
+			Doc:  "Per-model json_mode, tool_choice (auto, required, none), and parallel_tool_calls, by model name",
+			Values: &configSchema{
+				Type: "object",
+				Fields: map[string]*configSchema{
+					"json_mode":           {Type: "boolean"},
+					"tool_choice":         {Type: "string"},
+					"parallel_tool_calls": {Type: "boolean"},
+				},
+			},
+		},
+		"sync": {
+			Type: "object",
+			Doc:  "Session and memory sync; only read from the global config",
+			Fields: map[string]*configSchema{
+				"url":        {Type: "string"},
+				"passphrase": {Type: "string"},
+				"endpoint":   {Type: "string"},
+				"region":     {Type: "string"},
+				"access_key": {Type: "string"},
+				"secret_key": {Type: "string"},
+				"username":   {Type: "string"},
+				"password":   {Type: "string"},
+			},
+		},
+		"hooks": {
+			Type: "object",
+			Doc:  "Settings for `painika hooks`",
+			Fields: map[string]*configSchema{
+				"block_on":        {Type: "string"},
+				"max_cost":        {Type: "number", Min: bound(0)},
+				"max_diff_tokens": {Type: "number", Min: bound(1)},
+				"model":           {Type: "string"},
+			},
+		},
+	},
+}
+
+// Problem in a config file, with its position and key path
+type ConfigError struct {
+	File    string
+	Line    int
+	Column  int
+	Key     string // e.g. personas[0].temperature ("" for syntax errors)
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Key, e.Message)
+}
+
+// All problems found in one config file
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return "invalid config:\n   " + strings.Join(lines, "\n   ")
+}
+
+// Decoded JSON value with the byte offset where it starts
+type jsonNode struct {
+	Value  interface{} // string, float64, bool, nil, []*jsonNode, or *jsonObject
+	Offset int64
+}
+
+// JSON object keeping key order and key positions
+type jsonObject struct {
+	Keys       []string
+	KeyOffsets map[string]int64
+	Values     map[string]*jsonNode
+}
+
+// Parser that records where each value starts
+type positionParser struct {
+	data []byte
+	dec  *json.Decoder
+}
+
+// Offset of the next token, skipping whitespace and separators
+func (p *positionParser) next() int64 {
+	offset := p.dec.InputOffset()
+	for offset < int64(len(p.data)) && strings.IndexByte(" \t\r\n:,", p.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func (p *positionParser) parse() (*jsonNode, error) {
+	offset := p.next()
+	token, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &jsonObject{KeyOffsets: map[string]int64{}, Values: map[string]*jsonNode{}}
+		for p.dec.More() {
+			keyOffset := p.next()
+			key, err := p.dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			name := key.(string)
+			if _, dup := object.Values[name]; !dup {
+				object.Keys = append(object.Keys, name)
+			}
+			object.KeyOffsets[name] = keyOffset
+			object.Values[name] = value
+		}
+		if _, err := p.dec.Token(); err != nil {
+			return nil, err
+		}
+		return &jsonNode{Value: object, Offset: offset}, nil
+	case json.Delim('['):
+		var items []*jsonNode
+		for p.dec.More() {
+			item, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if _, err := p.dec.Token(); err != nil {
+			return nil, err
+		}
+		return &jsonNode{Value: items, Offset: offset}, nil
+	}
+	return &jsonNode{Value: token, Offset: offset}, nil
+}
+
+// Line and column (1-based) of a byte offset
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
+
+// JSON type name of a decoded value
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case *jsonObject:
+		return "object"
+	case []*jsonNode:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// Edit distance, for suggesting the key that was probably meant
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// Check a value against its schema, collecting every problem
+func validateNode(file string, data []byte, node *jsonNode, schema *configSchema, key string) ConfigErrors {
+	var errs ConfigErrors
+	report := func(offset int64, key, format string, args ...interface{}) {
+		line, column := position(data, offset)
+		errs = append(errs, ConfigError{File: file, Line: line, Column: column, Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if got := jsonTypeName(node.Value); got != schema.Type {
+		report(node.Offset, key, "expected %s, got %s", schema.Type, got)
+		return errs
+	}
+
+	switch value := node.Value.(type) {
+	case *jsonObject:
+		names := make([]string, 0, len(schema.Fields))
+		for name := range schema.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range value.Keys {
+			path := name
+			if key != "" {
+				path = key + "." + name
+			}
+			field, ok := schema.Fields[name]
+			if !ok && schema.Values != nil {
+				field, ok = schema.Values, true
+			}
+			if !ok {
+				hint := "expected one of: " + strings.Join(names, ", ")
+				for _, known := range names {
+					if levenshtein(strings.ToLower(name), known) <= 2 {
+						hint = fmt.Sprintf("did you mean %q?", known)
+						break
+					}
+				}
+				report(value.KeyOffsets[name], path, "unknown key (%s)", hint)
+				continue
+			}
+			errs = append(errs, validateNode(file, data, value.Values[name], field, path)...)
+		}
+
+		for _, name := range schema.Required {
+			if _, ok := value.Values[name]; !ok {
+				report(node.Offset, key, "missing required key %q", name)
+			}
+		}
+	case []*jsonNode:
+		for i, item := range value {
+			errs = append(errs, validateNode(file, data, item, schema.Items, fmt.Sprintf("%s[%d]", key, i))...)
+		}
+	case float64:
+		if schema.Min != nil && value < *schema.Min || schema.Max != nil && value > *schema.Max {
+			switch {
+			case schema.Max == nil:
+				report(node.Offset, key, "must be at least %g, got %g", *schema.Min, value)
+			case schema.Min == nil:
+				report(node.Offset, key, "must be at most %g, got %g", *schema.Max, value)
+			default:
+				report(node.Offset, key, "must be between %g and %g, got %g", *schema.Min, *schema.Max, value)
+			}
+		}
+	}
+	return errs
+}
+
+// Validate config file content against the schema
+func validateConfig(file string, data []byte) ConfigErrors {
+	parser := &positionParser{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	root, err := parser.parse()
+	if err == nil {
+		// Anything after the top-level value is a syntax error too
+		if _, extra := parser.dec.Token(); extra != io.EOF {
+			err = fmt.Errorf("unexpected content after the top-level object")
+		}
+	}
+
+	if err != nil {
+		offset := parser.dec.InputOffset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("unexpected end of file")
+		}
+		line, column := position(data, offset)
+		return ConfigErrors{{File: file, Line: line, Column: column, Message: err.Error()}}
+	}
+
+	return validateNode(file, data, root, userConfigSchema, "")
+}
+
+// Config files in load order: global, then project
+func userConfigPaths() []string {
+	var paths []string
+	if path := globalConfigPath(); path != "" {
+		paths = append(paths, path)
+	}
+	if path := projectFile("config.json"); path != "" {
+		paths = append(paths, path)
+	}
+	return append(paths, projectConfigFile)
+}
+
+// Handle `painika config validate [file...]`
+func runConfigCommand(args []string) {
+	if len(args) > 0 && args[0] == "edit" {
+		runConfigEdit(args[1:])
+		return
+	}
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Println("Usage: painika config validate [file...] | painika config edit [file]")
+		exit(2)
+	}
+
+	paths := args[1:]
+	explicit := len(paths) > 0
+	if !explicit {
+		paths = userConfigPaths()
+	}
+
+	invalid := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && !explicit {
+			fmt.Printf("➖ %s (not present)\n", path)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			invalid++
+			continue
+		}
+
+		errs := validateConfig(path, data)
+		if len(errs) == 0 {
+			_, errs = expandConfigVars(path, data)
+		}
+		if len(errs) == 0 {
+			fmt.Printf("✅ %s\n", path)
+			continue
+		}
+		invalid++
+		fmt.Printf("❌ %s\n", path)
+		for _, err := range errs {
+			fmt.Printf("   %s\n", err)
+		}
+	}
+
+	if invalid > 0 {
+		exit(1)
+	}
+	exit(0)
+}
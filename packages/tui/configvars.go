@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// ${VAR}, ${VAR:-default}, and $${...} for a literal ${...}
+var configVarPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// Expand the variables in text. ${VAR} must be set (in the environment or
+// the shell config); ${VAR:-default} falls back to the default when it is
+// unset or empty. Names in keep are left for the shell, and quote escapes
+// values for where they land. Unless secrets is set, names withheld from
+// subprocesses are refused and left as written. Returns the names that
+// were not set and the ones refused.
+func expandVars(text string, keep map[string]bool, quote func(string) string, secrets bool) (string, []string, []string) {
+	var missing, refused []string
+	expanded := configVarPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if match[1] == '$' {
+			return match[1:]
+		}
+		groups := configVarPattern.FindStringSubmatch(match)
+		name, fallback := groups[1], groups[2]
+		if keep[name] {
+			return match
+		}
+		if !secrets && secretVar(name) {
+			refused = append(refused, name)
+			return match
+		}
+		if value := getEnv(name, ""); value != "" {
+			return quote(value)
+		}
+		if fallback == "" {
+			missing = append(missing, name)
+			return match
+		}
+		return fallback[2:]
+	})
+	return expanded, missing, refused
+}
+
+// Whether a variable is withheld from subprocesses (ENV_DENY and the
+// default secret patterns, unless ENV_ALLOW names it); a repository's
+// files must not copy one into a prompt or a command
+func secretVar(name string) bool {
+	policy := envPolicyConfig()
+	return matchEnvName(name, policy.Deny) && !matchEnvName(name, policy.Allow)
+}
+
+func unsetVarMessage(name string) string {
+	return fmt.Sprintf("environment variable %s is not set (use ${%s:-default} for a fallback)", name, name)
+}
+
+func secretVarMessage(name string) string {
+	return fmt.Sprintf("%s is a secret and only ~/.painika/config.json may use it", name)
+}
+
+// Expand the variables in a config file's string values, reporting each
+// one that is not set, or is a secret in a project's file, where it appears
+func expandConfigVars(file string, data []byte) ([]byte, ConfigErrors) {
+	// Values land inside JSON strings; defaults are already escaped in the file
+	quote := func(value string) string {
+		quoted, _ := json.Marshal(value)
+		return string(quoted[1 : len(quoted)-1])
+	}
+	secrets := file == globalConfigPath()
+	expanded, missing, refused := expandVars(string(data), nil, quote, secrets)
+	if len(missing) == 0 && len(refused) == 0 {
+		return []byte(expanded), nil
+	}
+
+	var errs ConfigErrors
+	for _, loc := range configVarPattern.FindAllSubmatchIndex(data, -1) {
+		name := string(data[loc[2]:loc[3]])
+		if data[loc[0]+1] == '$' {
+			continue
+		}
+		line, column := position(data, int64(loc[0]))
+		switch {
+		case !secrets && secretVar(name):
+			errs = append(errs, ConfigError{File: file, Line: line, Column: column, Message: secretVarMessage(name)})
+		case loc[4] < 0 && getEnv(name, "") == "":
+			errs = append(errs, ConfigError{File: file, Line: line, Column: column, Message: unsetVarMessage(name)})
+		}
+	}
+	return []byte(expanded), errs
+}
+
+// Expand the variables in a project tool's command and description; its
+// own parameters (like ${pattern:-.}) are read by the shell when it runs.
+// Tools come from the repository, so secrets are refused.
+func expandToolVars(tool *ProjectTool) error {
+	keep := map[string]bool{}
+	for param := range tool.Parameters {
+		keep[param] = true
+	}
+	plain := func(value string) string { return value }
+
+	var missing, refused []string
+	for _, field := range []*string{&tool.Command, &tool.Description} {
+		var unset, secret []string
+		*field, unset, secret = expandVars(*field, keep, plain, false)
+		missing = append(missing, unset...)
+		refused = append(refused, secret...)
+	}
+	if len(refused) > 0 {
+		return fmt.Errorf("tool %s: %s", tool.Name, secretVarMessage(refused[0]))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("tool %s: %s", tool.Name, unsetVarMessage(missing[0]))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Modification time and size of a config file (zero for a missing file)
+type fileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Config files checked for edits between prompts, with the settings last applied from them
+type configWatcher struct {
+	stamps map[string]fileStamp
+	config UserConfig
+}
+
+var configWatch *configWatcher
+
+func stampConfigFiles() map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, path := range userConfigPaths() {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		} else {
+			stamps[path] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// Start watching the config files the session was configured from
+func newConfigWatcher() *configWatcher {
+	config, _ := loadUserConfig()
+	return &configWatcher{stamps: stampConfigFiles(), config: config}
+}
+
+// Reload the config files if they changed and apply what can change mid-session.
+// Settings also given as environment variables keep the environment's value.
+func (w *configWatcher) Check(client *Client) {
+	if w == nil {
+		return
+	}
+	stamps := stampConfigFiles()
+	if reflect.DeepEqual(stamps, w.stamps) {
+		return
+	}
+	w.stamps = stamps
+
+	config, err := loadUserConfig()
+	if err != nil {
+		fmt.Printf("⚠️  Config not reloaded, keeping the previous settings: %v\n\n", err)
+		return
+	}
+
+	old := w.config
+	w.config = config
+
+	var applied, kept []string
+	apply := func(setting string, err error) {
+		if err != nil {
+			fmt.Printf("⚠️  Config reload: %s: %v\n", setting, err)
+			return
+		}
+		applied = append(applied, setting)
+	}
+
+	if config.Model != old.Model && config.Model != "" {
+		if getEnv("MODEL", "") != "" {
+			kept = append(kept, "model (MODEL)")
+		} else {
+			apply("model = "+config.Model, reloadModel(client, config.Model))
+		}
+	}
+
+	if !reflect.DeepEqual(config.Temperature, old.Temperature) {
+		setting := "temperature = default"
+		if config.Temperature != nil {
+			setting = fmt.Sprintf("temperature = %g", *config.Temperature)
+		}
+		err := client.UpdateSettings(map[string]interface{}{"temperature": config.Temperature})
+		if err == nil {
+			client.config.Temperature = config.Temperature
+		}
+		apply(setting, err)
+	}
+
+	if config.HistoryWindow != old.HistoryWindow {
+		if getEnv("HISTORY_WINDOW", "") != "" {
+			kept = append(kept, "history_window (HISTORY_WINDOW)")
+		} else if window, err := parseHistoryWindow(config.HistoryWindow); err != nil {
+			apply("history_window", err)
+		} else {
+			err := client.UpdateSettings(map[string]interface{}{"historyWindow": window})
+			if err == nil {
+				client.config.HistoryWindow = window
+			}
+			apply("history_window = "+window.String(), err)
+		}
+	}
+
+	toolsChanged := !reflect.DeepEqual(config.ApproveTools, old.ApproveTools)
+	if toolsChanged && getEnv("APPROVE_TOOLS", "") != "" {
+		kept = append(kept, "approve_tools (APPROVE_TOOLS)")
+		toolsChanged = false
+	}
+	if toolsChanged || !reflect.DeepEqual(config.GuardedCommands, old.GuardedCommands) {
+		if policy, err := approvalConfig(config); err != nil {
+			apply("approval policy", err)
+		} else {
+			policy.Tools = offlineApprovalTools(policy.Tools, client.config.ProjectTools)
+			err := client.UpdateSettings(map[string]interface{}{"approval": policy})
+			if err == nil {
+				client.config.Approval = policy
+			}
+			apply(fmt.Sprintf("approve_tools = %s, %d guarded patterns", describeApprovalTools(policy.Tools), len(policy.GuardedCommands)), err)
+		}
+	}
+
+	if !reflect.DeepEqual(config.ToolLimits, old.ToolLimits) {
+		limits := toolLimitsConfig(config)
+		err := client.UpdateSettings(map[string]interface{}{"toolLimits": limits})
+		if err == nil {
+			client.config.ToolLimits = limits
+		}
+		apply(fmt.Sprintf("tool_limits (%d tools)", len(limits)), err)
+	}
+
+	if !reflect.DeepEqual(config.RequestShaping, old.RequestShaping) {
+		if models, err := requestShapingConfig(config); err != nil {
+			apply("request_shaping", err)
+		} else {
+			policy := client.config.Shaping
+			policy.Models = models
+			err := client.UpdateSettings(map[string]interface{}{"requestShaping": policy})
+			if err == nil {
+				client.config.Shaping = policy
+			}
+			apply(fmt.Sprintf("request_shaping (%d models)", len(models)), err)
+		}
+	}
+
+	if config.MaxSessionCost != old.MaxSessionCost {
+		if getEnv("MAX_SESSION_COST", "") != "" {
+			kept = append(kept, "max_session_cost (MAX_SESSION_COST)")
+		} else {
+			client.config.MaxSessionCost = config.MaxSessionCost
+			apply(fmt.Sprintf("max_session_cost = $%.2f", config.MaxSessionCost), nil)
+		}
+	}
+
+	if !reflect.DeepEqual(config.Personas, old.Personas) {
+		apply("personas", reloadPersona(client))
+	}
+
+	switch {
+	case len(applied) > 0:
+		fmt.Printf("🔄 Config reloaded: %s\n", strings.Join(applied, ", "))
+	case len(kept) == 0:
+		// Saved without changing a setting
+		return
+	default:
+		fmt.Println("🔄 Config reloaded")
+	}
+	if len(kept) > 0 {
+		fmt.Printf("   Kept from the environment: %s\n", strings.Join(kept, ", "))
+	}
+	fmt.Println()
+}
+
+// Switch the base model; an active persona's model override stays until the persona ends
+func reloadModel(client *Client, model string) error {
+	if client.config.PersonaBaseModel != "" {
+		client.config.PersonaBaseModel = model
+		return nil
+	}
+	if err := client.UpdateSettings(map[string]interface{}{"model": model}); err != nil {
+		return err
+	}
+	client.config.Model = model
+	return nil
+}
+
+// Re-apply the active persona so edits to its prompt, model, or tools take effect
+func reloadPersona(client *Client) error {
+	if client.config.Persona == "" {
+		return nil
+	}
+	persona, ok := findPersona(client.config.Persona)
+	if !ok {
+		return applyPersona(client, nil)
+	}
+	return applyPersona(client, &persona)
+}
+
+func describeApprovalTools(tools []string) string {
+	if len(tools) == 0 {
+		return "none"
+	}
+	for _, tool := range tools {
+		if tool == "*" {
+			return "all"
+		}
+	}
+	return strings.Join(tools, ",")
+}
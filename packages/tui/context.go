@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Show how the conversation context breaks down by role
+func showContextBreakdown(client *Client) {
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n", err)
+		return
+	}
+
+	tok := tokenizerForModel(client.config.Model)
+
+	roles := []string{"system", "user", "assistant", "tool"}
+	byRole := make(map[string]int)
+	counts := make(map[string]int)
+	total := 0
+
+	type sizedMessage struct {
+		index  int
+		role   string
+		tokens int
+	}
+	var sized []sizedMessage
+
+	for i, msg := range conversation.Messages {
+		tokens := messageTokens(tok, msg)
+		byRole[msg.Role] += tokens
+		counts[msg.Role]++
+		total += tokens
+		sized = append(sized, sizedMessage{i + 1, msg.Role, tokens})
+	}
+
+	fmt.Printf("🧮 Context Breakdown (tokenizer: %s):\n", tok.Name())
+	for _, role := range roles {
+		if counts[role] == 0 {
+			continue
+		}
+		share := 0.0
+		if total > 0 {
+			share = float64(byRole[role]) * 100 / float64(total)
+		}
+		fmt.Printf("   %-10s %6d tokens  %5.1f%%  (%d messages)\n", role, byRole[role], share, counts[role])
+	}
+	fmt.Printf("   %-10s %6d tokens\n", "total", total)
+	fmt.Printf("   Next request input cost: ~$%.4f\n", estimateCost(total))
+
+	// Point at the heaviest messages
+	sort.Slice(sized, func(i, j int) bool { return sized[i].tokens > sized[j].tokens })
+	if len(sized) > 3 {
+		sized = sized[:3]
+	}
+	if len(sized) > 0 {
+		fmt.Println("   Largest messages:")
+		for _, m := range sized {
+			fmt.Printf("     #%d %-9s %d tokens\n", m.index, m.role, m.tokens)
+		}
+	}
+	fmt.Println()
+}
+
+// Tokens a message takes up in the context, including its tool calls
+func messageTokens(tok Tokenizer, msg Message) int {
+	tokens := tok.Count(msg.Content)
+	for _, call := range msg.ToolCalls {
+		tokens += tok.Count(call.Name) + tok.Count(fmt.Sprint(call.Parameters))
+	}
+	return tokens
+}
+
+// Tokenizer counts of the conversation's messages for the server, which
+// trims the history by them instead of its own estimate
+type tokenCounts struct {
+	mu      sync.Mutex
+	pending map[string]int // Counted, not yet sent; by message id
+	stale   bool           // Count the whole conversation again
+}
+
+// Count every message again before the next one is sent: the session is
+// new, or its system prompt or model may have changed
+func (t *tokenCounts) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stale = true
+}
+
+// Drop the counts the server now has and count the messages a turn added
+func (t *tokenCounts) sent(counted map[string]int, tok Tokenizer, messages []Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id := range counted {
+		delete(t.pending, id)
+	}
+	for _, msg := range messages {
+		if msg.ContextTokens > 0 {
+			continue
+		}
+		if t.pending == nil {
+			t.pending = make(map[string]int)
+		}
+		t.pending[msg.ID] = messageTokens(tok, msg)
+	}
+}
+
+// Counts to send with the next message
+func (c *Client) pendingCounts(tok Tokenizer) map[string]int {
+	c.counts.mu.Lock()
+	stale := c.counts.stale
+	c.counts.mu.Unlock()
+
+	var recounted map[string]int
+	if stale {
+		if conversation, err := c.GetConversation(); err == nil {
+			recounted = make(map[string]int, len(conversation.Messages))
+			for _, msg := range conversation.Messages {
+				recounted[msg.ID] = messageTokens(tok, msg)
+			}
+		}
+	}
+
+	c.counts.mu.Lock()
+	defer c.counts.mu.Unlock()
+	if recounted != nil {
+		c.counts.pending = recounted
+		c.counts.stale = false
+	}
+	counts := make(map[string]int, len(c.counts.pending))
+	for id, tokens := range c.counts.pending {
+		counts[id] = tokens
+	}
+	return counts
+}
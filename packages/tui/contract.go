@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Corrections asked for when a reply breaks the --schema contract
+const defaultSchemaRetries = 2
+
+// Most schema errors sent back to the model at once
+const maxContractErrors = 10
+
+// JSON Schema (the common subset: type, properties, required,
+// additionalProperties, items, enum, const, bounds, lengths, pattern,
+// allOf/anyOf/oneOf, and local $refs) that a one-shot reply must match
+type outputContract struct {
+	Root map[string]interface{}
+	Text string // The schema as given, for the prompt
+}
+
+// Read a --schema file
+func loadContract(path string) (*outputContract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON schema: %v", path, err)
+	}
+	return &outputContract{Root: root, Text: strings.TrimSpace(string(data))}, nil
+}
+
+// Whether the reply must be an object, so the provider's JSON mode can
+// enforce it (JSON mode only produces objects)
+func (c *outputContract) wantsObject() bool {
+	t, ok := c.Root["type"].(string)
+	return !ok || t == "object"
+}
+
+// Parse a reply and check it; returns the compact JSON and what is wrong
+func (c *outputContract) Check(reply string) (string, []string) {
+	text := strings.TrimSpace(reply)
+	if fenced, ok := strings.CutPrefix(text, "```"); ok {
+		// A code fence, with or without "json" after it
+		if _, body, found := strings.Cut(fenced, "\n"); found {
+			text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", []string{fmt.Sprintf("the reply is not valid JSON: %v", err)}
+	}
+	errs := c.validate(c.Root, value, "$")
+	if len(errs) > maxContractErrors {
+		errs = append(errs[:maxContractErrors], fmt.Sprintf("and %d more", len(errs)-maxContractErrors))
+	}
+	compact, _ := json.Marshal(value)
+	return string(compact), errs
+}
+
+// Follow a local "#/..." reference
+func (c *outputContract) resolve(ref string) (map[string]interface{}, bool) {
+	path, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, false
+	}
+	var node interface{} = c.Root
+	for _, part := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		node = object[part]
+	}
+	schema, ok := node.(map[string]interface{})
+	return schema, ok
+}
+
+// Whether a value is of a JSON Schema type
+func hasSchemaType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+// Errors in a value against a schema, each prefixed with where it is
+func (c *outputContract) validate(schema map[string]interface{}, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, found := c.resolve(ref)
+		if !found {
+			return []string{fmt.Sprintf("%s: the schema's $ref %q can't be resolved", at, ref)}
+		}
+		return c.validate(target, value, at)
+	}
+
+	switch t := schema["type"].(type) {
+	case string:
+		if !hasSchemaType(value, t) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", at, t, jsonTypeName(value))}
+		}
+	case []interface{}:
+		matched := false
+		var names []string
+		for _, option := range t {
+			name, _ := option.(string)
+			names = append(names, name)
+			matched = matched || hasSchemaType(value, name)
+		}
+		if !matched {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", at, strings.Join(names, " or "), jsonTypeName(value))}
+		}
+	}
+
+	var errs []string
+	if allowed, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range allowed {
+			found = found || reflect.DeepEqual(option, value)
+		}
+		if !found {
+			options, _ := json.Marshal(allowed)
+			errs = append(errs, fmt.Sprintf("%s: must be one of %s", at, options))
+		}
+	}
+	if want, ok := schema["const"]; ok && !reflect.DeepEqual(want, value) {
+		wanted, _ := json.Marshal(want)
+		errs = append(errs, fmt.Sprintf("%s: must be %s", at, wanted))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			errs = append(errs, fmt.Sprintf("%s: %v is below the minimum %v", at, v, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			errs = append(errs, fmt.Sprintf("%s: %v is above the maximum %v", at, v, max))
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			errs = append(errs, fmt.Sprintf("%s: shorter than %v characters", at, min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			errs = append(errs, fmt.Sprintf("%s: longer than %v characters", at, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				errs = append(errs, fmt.Sprintf("%s: does not match %s", at, pattern))
+			}
+		}
+	case []interface{}:
+		count := float64(len(v))
+		if min, ok := schema["minItems"].(float64); ok && count < min {
+			errs = append(errs, fmt.Sprintf("%s: needs at least %v items", at, min))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && count > max {
+			errs = append(errs, fmt.Sprintf("%s: allows at most %v items", at, max))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, c.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if key, _ := name.(string); key != "" {
+					if _, present := v[key]; !present {
+						errs = append(errs, fmt.Sprintf("%s: missing required field %q", at, key))
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			where := at + "." + key
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				errs = append(errs, c.validate(property, v[key], where)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					errs = append(errs, fmt.Sprintf("%s: not allowed by the schema", where))
+				}
+			case map[string]interface{}:
+				errs = append(errs, c.validate(extra, v[key], where)...)
+			}
+		}
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if s, ok := sub.(map[string]interface{}); ok {
+				errs = append(errs, c.validate(s, value, at)...)
+			}
+		}
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		options, ok := schema[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matches := 0
+		for _, sub := range options {
+			if s, ok := sub.(map[string]interface{}); ok && len(c.validate(s, value, at)) == 0 {
+				matches++
+			}
+		}
+		switch {
+		case matches == 0:
+			errs = append(errs, fmt.Sprintf("%s: matches none of the %s options", at, key))
+		case key == "oneOf" && matches > 1:
+			errs = append(errs, fmt.Sprintf("%s: matches %d of the oneOf options instead of exactly one", at, matches))
+		}
+	}
+	return errs
+}
+
+// First message of a contract run: the task, then the schema to answer in
+func contractPrompt(message string, contract *outputContract) string {
+	return message + "\n\nReply with only a JSON value, no prose or code fence, that matches this JSON schema:\n" + contract.Text
+}
+
+// Correction asked for after a reply broke the contract
+func contractCorrection(errs []string) string {
+	return "That reply does not match the schema:\n- " + strings.Join(errs, "\n- ") +
+		"\n\nReply again with only the corrected JSON."
+}
+
+// Handle `painika "<prompt>" --schema schema.json`: one turn whose reply
+// must match the schema, corrected up to startup.SchemaRetries times. The
+// JSON goes to stdout; exits 1 if the contract can't be met.
+func runContract(startup StartupArgs) {
+	contract, err := loadContract(startup.Schema)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(2)
+	}
+
+	config := loadConfig()
+	config.SystemContext = sessionContext()
+	setupCleanupHandlers()
+	if getEnv("SERVER_URL", "") == "" {
+		serverURL, err := startBatchServer()
+		if err != nil {
+			fmt.Printf("❌ Failed to start server: %v\n", err)
+			stopBatchServers()
+			exit(1)
+		}
+		config.ServerURL = serverURL
+	}
+
+	client := NewClient(config)
+	if err := client.InitSession(); err != nil {
+		fmt.Printf("❌ Failed to initialize session: %v\n", err)
+		stopBatchServers()
+		exit(1)
+	}
+	if contract.wantsObject() {
+		policy := client.config.Shaping
+		policy.Session.JSONMode = boolPtr(true)
+		if err := client.UpdateSettings(map[string]interface{}{"requestShaping": policy}); err != nil {
+			fmt.Printf("⚠️  JSON mode not set, relying on the prompt: %v\n", err)
+		}
+	}
+
+	// Nobody can answer questions in a one-shot run
+	stopQuestions := watchQuestions(client)
+
+	message := contractPrompt(startup.Message, contract)
+	for attempt := 0; attempt <= startup.SchemaRetries; attempt++ {
+		response, err := client.SendMessage(message)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			stopQuestions()
+			stopBatchServers()
+			exit(1)
+		}
+		reply := ""
+		if msg := lastMessage(response.Messages); msg != nil {
+			reply = msg.Content
+		}
+
+		output, errs := contract.Check(reply)
+		if len(errs) == 0 {
+			fmt.Fprintln(resultOutput, output)
+			stopQuestions()
+			stopBatchServers()
+			exit(0)
+		}
+		fmt.Printf("⚠️  Reply %d does not match %s:\n", attempt+1, startup.Schema)
+		for _, problem := range errs {
+			fmt.Printf("   %s\n", problem)
+		}
+		message = contractCorrection(errs)
+	}
+
+	fmt.Printf("❌ No reply matched %s after %d correction(s)\n", startup.Schema, startup.SchemaRetries)
+	stopQuestions()
+	stopBatchServers()
+	exit(1)
+}
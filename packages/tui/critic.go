@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const criticInstructions = "You review changes another AI assistant proposed for a programming request. " +
+	"List only real problems: bugs, missed requirements, unsafe or risky changes, and clearly simpler alternatives, " +
+	"most important first, as short bullet points that say where and why. " +
+	"If nothing needs to change, answer only: LGTM"
+
+// Message sending the review back to the primary model
+const criticRevision = "A reviewer (%s) raised these points about your changes:\n\n%s\n\n" +
+	"Revise the changes where a point is right, and briefly say which points you disagree with and why."
+
+// Most characters of each part of a proposal the critic is shown
+const criticPartChars = 8000
+
+var criticApproves = regexp.MustCompile(`(?i)^\W*lgtm\W*$`)
+
+// Read CRITIC_MODEL (a model name, optionally prefixed with its provider as
+// in bench) and CRITIC_REVISE: off (the default) only shows the review, ask
+// offers to send it back, on always sends it back for one revision
+func criticConfig(base Config) (*Config, string, error) {
+	revise := strings.ToLower(getEnv("CRITIC_REVISE", "off"))
+	if revise != "off" && revise != "ask" && revise != "on" {
+		return nil, "", fmt.Errorf("invalid CRITIC_REVISE %q (expected off, ask, or on)", revise)
+	}
+	spec := getEnv("CRITIC_MODEL", "")
+	if spec == "" {
+		return nil, revise, nil
+	}
+	critic, err := benchModelConfig(base, spec)
+	if err != nil {
+		return nil, revise, fmt.Errorf("CRITIC_MODEL %v", err)
+	}
+	return &critic, revise, nil
+}
+
+// Cut text to about n bytes, on a character boundary
+func clipText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n] + "\n… (cut)"
+}
+
+// What the turn proposed, for the critic: the reply and the edits its tools
+// made. Empty when the turn proposed no changes.
+func criticProposal(input string, messages []Message) string {
+	reply := ""
+	var edits []string
+	for _, message := range messages {
+		if message.Role == "assistant" && strings.TrimSpace(message.Content) != "" {
+			reply = message.Content
+		}
+		for _, call := range message.ToolCalls {
+			if !containsTag(editingTools, call.Name) || (call.Name == "rename_symbol" && call.Parameters["apply"] != true) {
+				continue
+			}
+			params, _ := json.MarshalIndent(call.Parameters, "", "  ")
+			edits = append(edits, call.Name+" "+clipText(string(params), criticPartChars))
+		}
+	}
+
+	code := false
+	for _, block := range extractCodeBlocks(reply) {
+		if !containsTag(shellLangs, strings.ToLower(block.Lang)) {
+			code = true
+		}
+	}
+	if len(edits) == 0 && !code {
+		return ""
+	}
+
+	proposal := "Request:\n" + clipText(input, criticPartChars) + "\n\nReply:\n" + clipText(reply, criticPartChars)
+	if len(edits) > 0 {
+		proposal += "\n\nEdits made:\n" + strings.Join(edits, "\n\n")
+	}
+	return proposal
+}
+
+// Have the critic model review changes the turn proposed and show what it
+// says; returns the revised turn when the review is sent back, else nil
+func reviewWithCritic(client *Client, input string, messages []Message) *ChatResponse {
+	critic := client.config.Critic
+	if critic == nil {
+		return nil
+	}
+	proposal := criticProposal(input, messages)
+	if proposal == "" {
+		return nil
+	}
+
+	fmt.Print("🧑‍⚖️ ")
+	stop := showThinking()
+	feedback, err := client.CompleteWith(critic, criticInstructions, proposal)
+	stop()
+	fmt.Print("\r\033[K")
+	if err != nil {
+		fmt.Printf("⚠️  The critic (%s) couldn't review the changes: %v\n\n", critic.Model, err)
+		return nil
+	}
+	feedback = strings.TrimSpace(feedback)
+	if feedback == "" || criticApproves.MatchString(feedback) {
+		fmt.Printf("🧑‍⚖️ %s found nothing to change\n\n", critic.Model)
+		return nil
+	}
+	fmt.Printf("🧑‍⚖️ %s reviewed the changes:\n   %s\n\n", critic.Model, linkifyReply(formatForTerminal(feedback, 3)))
+
+	switch client.config.CriticRevise {
+	case "off":
+		return nil
+	case "ask":
+		if !renderer.Interactive() {
+			return nil
+		}
+		fmt.Print("   Send the review back for one revision? [y/N] ")
+		answer, _ := stdin.ReadLine()
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			fmt.Println()
+			return nil
+		}
+	}
+	fmt.Println("✍️  Revising with the review")
+	return sendAndShow(client, fmt.Sprintf(criticRevision, critic.Model, feedback))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// When this process started, for the uptime in /debug runtime
+var processStart = time.Now()
+
+// Address of the pprof endpoint, "" when --pprof was not given
+var pprofAddr string
+
+// A byte count for the diagnostics, or "n/a" when the platform cannot tell
+func formatMemory(size int64) string {
+	if size < 0 {
+		return "n/a"
+	}
+	return formatSize(size)
+}
+
+// Memory, goroutines, and file descriptors of this process and the server
+func runtimeDiagnostics() string {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	rss, peak := processMemory(os.Getpid())
+	if peak < 0 {
+		peak = peakRSS()
+	}
+	if peak >= 0 && peak < rss {
+		peak = rss // Sampled a moment apart
+	}
+
+	var b strings.Builder
+	b.WriteString("🩺 Runtime\n")
+	fmt.Fprintf(&b, "   Client:      pid %d, up %s, %s\n", os.Getpid(), time.Since(processStart).Round(time.Second), runtime.Version())
+	fmt.Fprintf(&b, "   Memory:      %s resident (peak %s)\n", formatMemory(rss), formatMemory(peak))
+	fmt.Fprintf(&b, "   Go heap:     %s in use, %s reserved from the OS, %d GC cycles\n",
+		formatSize(int64(mem.HeapAlloc)), formatSize(int64(mem.Sys)), mem.NumGC)
+	fmt.Fprintf(&b, "   Goroutines:  %d\n", runtime.NumGoroutine())
+	if files := openFileCount(); files >= 0 {
+		fmt.Fprintf(&b, "   Open files:  %d\n", files)
+	} else {
+		b.WriteString("   Open files:  n/a\n")
+	}
+
+	if globalServerCmd != nil && globalServerCmd.Process != nil {
+		pid := globalServerCmd.Process.Pid
+		serverRSS, serverPeak := processMemory(pid)
+		peak := ""
+		if serverPeak >= 0 {
+			peak = fmt.Sprintf(" (peak %s)", formatSize(serverPeak))
+		}
+		fmt.Fprintf(&b, "   Server:      pid %d, %s resident%s\n", pid, formatMemory(serverRSS), peak)
+	} else {
+		b.WriteString("   Server:      not started by this client\n")
+	}
+
+	if pprofAddr != "" {
+		fmt.Fprintf(&b, "   pprof:       http://%s/debug/pprof/\n", pprofAddr)
+	} else {
+		b.WriteString("💡 Start with --pprof to profile over HTTP\n")
+	}
+	return b.String()
+}
+
+// Serve the pprof handlers on addr (loopback unless given a host) for the
+// rest of the run
+func startPprof(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("pprof: %v", err)
+	}
+	pprofAddr = listener.Addr().String()
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// Handle /debug runtime|server|restart
+func handleDebug(client *Client, args string) {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "runtime":
+		renderer.Notice(runtimeDiagnostics())
+	case "server":
+		renderer.Notice(monitor.Report())
+	case "restart":
+		restartServer(client)
+	default:
+		fmt.Println("Usage: /debug runtime|server|restart")
+		fmt.Println()
+	}
+}
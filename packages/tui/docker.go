@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// `painika server --docker`: run the bundle in a container instead of a
+// local runtime, for machines with Docker but no Bun, Node.js, or Deno
+var serverInDocker bool
+
+// Official Bun image the bundle runs in (SERVER_DOCKER_IMAGE overrides)
+const defaultServerImage = "oven/bun:1"
+
+// Where the bundle is mounted inside the container
+const containerBundlePath = "/opt/painika/server.mjs"
+
+// Pull the image unless Docker already has it
+func ensureDockerImage(docker, image string) error {
+	if exec.Command(docker, "image", "inspect", image).Run() == nil {
+		return nil
+	}
+	logLine("info", "🐳", fmt.Sprintf("Pulling %s...", image))
+	pull := exec.Command(docker, "pull", image)
+	pull.Stdout = os.Stderr
+	pull.Stderr = os.Stderr
+	if err := pull.Run(); err != nil {
+		return fmt.Errorf("docker pull %s failed: %v", image, err)
+	}
+	return nil
+}
+
+// Command that runs the server bundle in a container. The working directory
+// is mounted at the same path so tool paths mean the same inside and out,
+// and the server's port is published on the host's loopback only.
+func dockerServerCommand(bundlePath string, env []string) (*exec.Cmd, error) {
+	if os.Getenv("SERVER_SOCKET") != "" {
+		return nil, fmt.Errorf("--docker can't listen on SERVER_SOCKET; unset it to use a port")
+	}
+	docker, err := exec.LookPath("docker")
+	if err != nil {
+		return nil, fmt.Errorf("docker is not installed (https://docs.docker.com/get-docker/)")
+	}
+	image := getEnv("SERVER_DOCKER_IMAGE", defaultServerImage)
+	if err := ensureDockerImage(docker, image); err != nil {
+		return nil, err
+	}
+
+	// Inside the container every port is free, so pick it here where it matters
+	port := getEnv("PORT", "3000")
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("invalid PORT %q", port)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	cwd = filepath.ToSlash(cwd)
+
+	args := []string{"run", "--rm", "--init",
+		"-p", "127.0.0.1:" + port + ":" + port,
+		"-v", bundlePath + ":" + containerBundlePath + ":ro",
+		"-v", cwd + ":" + cwd,
+		"-w", cwd,
+	}
+	// Pass variables by name so values (the token, API keys) stay off the command line
+	env = append(env, "PORT="+port, "SERVER_HOST=0.0.0.0")
+	for _, entry := range env {
+		if name, _, ok := strings.Cut(entry, "="); ok && name != "" && !dockerHostOnlyEnv[name] {
+			args = append(args, "-e", name)
+		}
+	}
+	args = append(args, image, "bun", "run", containerBundlePath)
+
+	cmd := exec.Command(docker, args...)
+	cmd.Env = env
+	return cmd, nil
+}
+
+// Host variables that mean nothing inside the container
+var dockerHostOnlyEnv = map[string]bool{
+	"PATH": true, "HOME": true, "USER": true, "SHELL": true, "TMPDIR": true,
+	"HOSTNAME": true, "TERM_PROGRAM": true, "PWD": true, "OLDPWD": true,
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Handle /dry-run <prompt>
+func runDryRun(client *Client, prompt string) {
+	if prompt == "" {
+		fmt.Println("Usage: /dry-run <prompt>")
+		fmt.Println()
+		return
+	}
+
+	fmt.Print("🧪 Planning")
+	stop := showThinking()
+	plan, err := client.PlanMessage(prompt)
+	stop()
+
+	if err != nil {
+		fmt.Printf("\n❌ Error: %v\n\n", err)
+		return
+	}
+
+	fmt.Println()
+	if text := strings.TrimSpace(plan.Content); text != "" {
+		fmt.Printf("🤖 %s\n", text)
+	}
+
+	if len(plan.ToolCalls) == 0 {
+		fmt.Println("🧪 No tool calls planned - the AI would answer directly.")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf("🧪 Planned tool calls (%d, nothing was executed):\n", len(plan.ToolCalls))
+	for i, call := range plan.ToolCalls {
+		fmt.Printf("   %d. %s\n", i+1, describeToolCall(call))
+	}
+	fmt.Println()
+	fmt.Println("💡 Send the prompt normally to run it for real.")
+	fmt.Println()
+}
+
+// Summarize a tool call for display
+func describeToolCall(call ToolCall) string {
+	param := func(key string) string {
+		value, _ := call.Parameters[key].(string)
+		return value
+	}
+
+	switch call.Name {
+	case "bash":
+		return "🔧 bash: " + param("command")
+	case "readFile":
+		return "📖 read " + param("path")
+	case "writeFile":
+		return fmt.Sprintf("✏️  write %s (%s)", param("path"), summarizeContent(param("content")))
+	case "editFile":
+		return fmt.Sprintf("✏️  edit %s (replace %s)", param("path"), summarizeContent(param("oldContent")))
+	case "apply_patch":
+		return "🩹 patch " + summarizePatch(param("patch"), param("path"))
+	case "makeDir":
+		return "📁 mkdir " + param("path")
+	case "list_files":
+		return "📂 list " + param("path")
+	case "search_text":
+		return fmt.Sprintf("🔎 search %q", param("query"))
+	case "project_stats":
+		scope := param("path")
+		if scope == "" {
+			scope = "."
+		}
+		return "📊 project stats " + scope
+	case "ask_user":
+		return "❓ ask: " + param("question")
+	case "rename_symbol":
+		scope := param("path")
+		if scope == "" {
+			scope = "workspace"
+		}
+		if apply, _ := call.Parameters["apply"].(bool); !apply {
+			scope += ", preview"
+		}
+		return fmt.Sprintf("🔁 rename %s → %s (%s)", param("symbol"), param("new_name"), scope)
+	}
+
+	args, _ := json.Marshal(call.Parameters)
+	return fmt.Sprintf("🔧 %s %s", call.Name, args)
+}
+
+// Summarize a unified diff as the files it touches and its line counts
+func summarizePatch(patch, path string) string {
+	var files []string
+	hunks, added, removed := 0, 0, 0
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++ ") && i > 0 && strings.HasPrefix(lines[i-1], "--- "):
+			file := strings.TrimSpace(strings.TrimPrefix(line, "+++ "))
+			if file == "/dev/null" {
+				file = strings.TrimSpace(strings.TrimPrefix(lines[i-1], "--- ")) + " (delete)"
+			}
+			files = append(files, strings.TrimPrefix(strings.TrimPrefix(file, "b/"), "a/"))
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+		case strings.HasPrefix(line, "@@"):
+			hunks++
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	if len(files) == 0 && path != "" {
+		files = append(files, path)
+	}
+	return fmt.Sprintf("%s (%d hunks, +%d -%d)", strings.Join(files, ", "), hunks, added, removed)
+}
+
+// Summarize file content as line/byte counts and its first line
+func summarizeContent(content string) string {
+	lines := strings.Count(content, "\n") + 1
+	first, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if len(first) > 50 {
+		first = first[:47] + "..."
+	}
+	return fmt.Sprintf("%d lines, %d bytes: %q", lines, len(content), first)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// Variables subprocesses need to run at all, kept even with an allowlist
+var essentialEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LC_*", "TZ",
+	"TMPDIR", "TMP", "TEMP", "PORT", "SERVER_SOCKET", "SERVER_READY_FILE",
+	// Windows
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "USERPROFILE",
+	"HOMEDRIVE", "HOMEPATH", "APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES*",
+}
+
+// Secrets withheld from subprocesses unless explicitly allowed
+var defaultDeniedEnv = []string{
+	"*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*", "*API_KEY*", "*_KEY",
+	"*CREDENTIAL*", "AWS_*", "AZURE_*", "GOOGLE_APPLICATION_CREDENTIALS",
+	"OTEL_EXPORTER_OTLP_HEADERS", "DATABASE_URL",
+}
+
+// Which environment variables are passed to the server and its bash tool
+type EnvPolicy struct {
+	Allow []string // If set, only these (plus essentials) are passed
+	Deny  []string // Never passed unless named in Allow
+}
+
+// Load the policy from ENV_ALLOW and ENV_DENY (comma-separated names or globs)
+func envPolicyConfig() EnvPolicy {
+	return EnvPolicy{
+		Allow: splitList(getEnv("ENV_ALLOW", "")),
+		Deny:  append(append([]string{}, defaultDeniedEnv...), splitList(getEnv("ENV_DENY", ""))...),
+	}
+}
+
+// Match a variable name against glob patterns, ignoring case
+func matchEnvName(name string, patterns []string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Keep the KEY=value entries the policy allows
+func (p EnvPolicy) Filter(environ []string) []string {
+	filtered := []string{}
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		switch {
+		case matchEnvName(name, p.Allow):
+		case matchEnvName(name, p.Deny):
+			continue
+		case len(p.Allow) > 0 && !matchEnvName(name, essentialEnv):
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// Environment for the server process (and so for its tool subprocesses)
+func subprocessEnv() []string {
+	return envPolicyConfig().Filter(os.Environ())
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind of failure behind a chat request, used to pick recovery options
+type ErrorKind string
+
+const (
+	ErrUnreachable ErrorKind = "unreachable" // The server did not answer
+	ErrAuth        ErrorKind = "auth"        // The provider rejected the API key
+	ErrRateLimit   ErrorKind = "rate_limit"  // Provider rate limit or quota
+	ErrModel       ErrorKind = "model"       // Unknown or unavailable model
+	ErrProvider    ErrorKind = "provider"    // Other provider failures
+	ErrServer      ErrorKind = "server"      // The server failed the request itself
+	ErrBudget      ErrorKind = "budget"      // The session spend cap was reached
+)
+
+// Failed chat request with enough detail to recover from it
+type ChatError struct {
+	Kind           ErrorKind
+	Message        string
+	ProviderStatus int // HTTP status from the provider, 0 if none
+	Err            error
+}
+
+func (e *ChatError) Error() string {
+	return e.Message
+}
+
+func (e *ChatError) Unwrap() error {
+	return e.Err
+}
+
+// One-line description of the failure
+func (e *ChatError) Summary() string {
+	switch e.Kind {
+	case ErrUnreachable:
+		return "Server unreachable"
+	case ErrAuth:
+		return fmt.Sprintf("Provider rejected the API key (%d)", e.ProviderStatus)
+	case ErrRateLimit:
+		return "Provider rate limit reached (429)"
+	case ErrModel:
+		return "Model not available"
+	case ErrBudget:
+		return "Session budget reached"
+	case ErrProvider:
+		if e.ProviderStatus > 0 {
+			return fmt.Sprintf("Provider error (%d)", e.ProviderStatus)
+		}
+		return "Provider request failed"
+	}
+	return "Server error"
+}
+
+// Suggested next step for the failure
+func (e *ChatError) Hint() string {
+	switch e.Kind {
+	case ErrUnreachable:
+		return "Check /serverlog, or restart painika if the server exited"
+	case ErrAuth:
+		return "Check GROQ_API_KEY (or your provider's token)"
+	case ErrRateLimit:
+		return "Wait a moment and retry, or retry with a smaller model"
+	case ErrModel:
+		return "Retry with another model"
+	case ErrBudget:
+		return "Export the conversation, or start a new session"
+	}
+	return "Retry, or show the debug trace for details"
+}
+
+// Classify a failed /message response by provider status and message
+func classifyChatError(message string, status int) *ChatError {
+	err := &ChatError{
+		Kind:           ErrServer,
+		Message:        fmt.Sprintf("failed to send message: %s", message),
+		ProviderStatus: status,
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case status == 401 || status == 403:
+		err.Kind = ErrAuth
+	case status == 429:
+		err.Kind = ErrRateLimit
+	case status == 404 || (strings.Contains(lower, "model") &&
+		(strings.Contains(lower, "not found") || strings.Contains(lower, "does not exist") || strings.Contains(lower, "decommissioned"))):
+		err.Kind = ErrModel
+	case status > 0 || strings.Contains(lower, "groq"):
+		err.Kind = ErrProvider
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Render a conversation as markdown, including annotations
+func conversationMarkdown(conversation *Conversation) string {
+	byMessage := annotationsFor(conversation.ID)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Painika Session %s\n\n", conversation.ID)
+	fmt.Fprintf(&b, "- Created: %s\n- Updated: %s\n- Tokens: %d input, %d output\n\n",
+		conversation.CreatedAt, conversation.UpdatedAt,
+		conversation.TotalTokens.Input, conversation.TotalTokens.Output)
+
+	for i, msg := range conversation.Messages {
+		if msg.Role == "system" {
+			continue
+		}
+
+		fmt.Fprintf(&b, "## %d. %s\n\n", i+1, strings.ToUpper(msg.Role[:1])+msg.Role[1:])
+		for _, a := range byMessage[msg.ID] {
+			fmt.Fprintf(&b, "> %s **%s**", a.Icon(), a.Label)
+			if a.Note != "" {
+				fmt.Fprintf(&b, ": %s", a.Note)
+			}
+			b.WriteString("\n\n")
+		}
+
+		if strings.TrimSpace(msg.Content) != "" {
+			b.WriteString(msg.Content + "\n\n")
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&b, "- 🔧 %s\n", describeToolCall(call))
+		}
+		if len(msg.ToolCalls) > 0 {
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// Handle /export [file.md] [--lang <language>]
+func exportConversation(client *Client, args string) {
+	path, lang := "", ""
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "--lang" && i+1 < len(fields) {
+			lang = fields[i+1]
+			i++
+		} else {
+			path = fields[i]
+		}
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+
+	if lang != "" {
+		fmt.Printf("🌐 Translating to %s", lang)
+		stop := showThinking()
+		conversation, err = translateConversation(client, conversation, lang)
+		stop()
+		fmt.Println()
+		if err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+	}
+
+	if path == "" {
+		path = fmt.Sprintf("painika-%s.md", time.Now().Format("20060102-150405"))
+	}
+
+	if err := os.WriteFile(path, []byte(conversationMarkdown(conversation)), 0644); err != nil {
+		fmt.Printf("❌ Failed to export: %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("📤 Conversation exported to %s\n", path)
+	fmt.Println()
+}
+
+// Handle /search <text> - search messages and annotation notes
+func searchConversation(client *Client, query string) {
+	if query == "" {
+		fmt.Println("Usage: /search <text>")
+		fmt.Println()
+		return
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+
+	byMessage := annotationsFor(conversation.ID)
+	needle := strings.ToLower(query)
+
+	fmt.Printf("🔎 Results for %q:\n", query)
+	found := 0
+	for i, msg := range conversation.Messages {
+		if msg.Role == "system" {
+			continue
+		}
+
+		matched := strings.Contains(strings.ToLower(msg.Content), needle)
+		marker := ""
+		for _, a := range byMessage[msg.ID] {
+			marker += a.Icon()
+			if strings.Contains(strings.ToLower(a.Label+" "+a.Note), needle) {
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		found++
+		fmt.Printf("   #%d %s %s %s\n", i+1, msg.Role, marker, truncateWidth(msg.Content, termWidth()-20))
+	}
+
+	if found == 0 {
+		fmt.Println("   No matches")
+	}
+	fmt.Println()
+}
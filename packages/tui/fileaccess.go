@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// File access policy enforced by the server's file tools
+type FileAccessPolicy struct {
+	Root           string   `json:"root"`
+	FollowSymlinks string   `json:"followSymlinks"` // "never", "workspace", or "always"
+	ProtectedPaths []string `json:"protectedPaths"`
+	AllowProtected []string `json:"allowProtected"`
+}
+
+// Split a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Load the file access policy from the environment.
+// The workspace root defaults to the current directory.
+func fileAccessConfig() (FileAccessPolicy, error) {
+	root := getEnv("WORKSPACE_ROOT", "")
+	if root == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return FileAccessPolicy{}, err
+		}
+		root = cwd
+	}
+
+	follow := strings.ToLower(getEnv("FOLLOW_SYMLINKS", "workspace"))
+	switch follow {
+	case "never", "workspace", "always":
+	default:
+		return FileAccessPolicy{}, fmt.Errorf("invalid FOLLOW_SYMLINKS %q (expected never, workspace, or always)", follow)
+	}
+
+	return FileAccessPolicy{
+		Root:           root,
+		FollowSymlinks: follow,
+		ProtectedPaths: splitList(getEnv("PROTECTED_PATHS", "")),
+		AllowProtected: splitList(getEnv("ALLOW_PROTECTED_WRITES", "")),
+	}, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A code block a reply wrote for a file, with what the file holds now
+type fileBlock struct {
+	Path    string
+	Code    string
+	Current []string // Lines of the file as it is, nil when it doesn't exist
+	Exists  bool
+}
+
+// Lines of text without the final newline's empty line
+func fileLines(text string) []string {
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// Code blocks of a reply that name the file they are for, as in
+// ```go path=cmd/main.go; a later block for the same file replaces an earlier one
+func fileBlocks(content string) []fileBlock {
+	var blocks []fileBlock
+	index := map[string]int{}
+	for _, block := range extractCodeBlocks(content) {
+		if block.Path == "" {
+			continue
+		}
+		path := filepath.Clean(block.Path)
+		target := fileBlock{Path: path, Code: block.Code}
+		if data, err := os.ReadFile(path); err == nil {
+			target.Current, target.Exists = fileLines(string(data)), true
+		}
+		if i, ok := index[path]; ok {
+			blocks[i] = target
+			continue
+		}
+		index[path] = len(blocks)
+		blocks = append(blocks, target)
+	}
+	return blocks
+}
+
+// What writing the block would do to its file, as "new, 40 lines" or
+// "+3 -1", or "unchanged"
+func (b fileBlock) describe() string {
+	if !b.Exists {
+		return fmt.Sprintf("new, %d lines", len(fileLines(b.Code)))
+	}
+	added, removed := 0, 0
+	for _, op := range diffLines(b.Current, fileLines(b.Code)) {
+		switch op.Kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	if added == 0 && removed == 0 {
+		return "unchanged"
+	}
+	return fmt.Sprintf("+%d -%d", added, removed)
+}
+
+// List the files the reply just given wrote blocks for, numbered for /write
+func offerFileBlocks(messages []Message) {
+	msg := lastMessage(messages)
+	if msg == nil || !renderer.Interactive() {
+		return
+	}
+	blocks := fileBlocks(msg.Content)
+	if len(blocks) == 0 {
+		return
+	}
+	shown := make([]string, len(blocks))
+	for i, block := range blocks {
+		shown[i] = fmt.Sprintf("%d. %s (%s)", i+1, block.Path, block.describe())
+	}
+	fmt.Printf("📝 %s  (/write <n> or /write all)\n\n", strings.Join(shown, "  "))
+}
+
+// Handle /write [n | all]: list the file blocks of the last response, or
+// review and write one or all of them
+func handleWrite(client *Client, args string) {
+	content, err := lastAssistantMessage(client)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	blocks := fileBlocks(content)
+	if len(blocks) == 0 {
+		fmt.Println("❌ The last response has no code block for a file (```lang path=<file>)")
+		fmt.Println()
+		return
+	}
+
+	if args == "" {
+		fmt.Println("📝 File blocks in the last response:")
+		for i, block := range blocks {
+			fmt.Printf("   %d. %s (%s)\n", i+1, block.Path, block.describe())
+		}
+		fmt.Println("💡 Review and write one with /write <n>, or each in turn with /write all")
+		fmt.Println()
+		return
+	}
+
+	if strings.EqualFold(args, "all") {
+		for _, block := range blocks {
+			if !writeFileBlock(client, block) {
+				return
+			}
+		}
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	if err != nil || n < 1 || n > len(blocks) {
+		fmt.Printf("Usage: /write <n>  (1-%d, all, or /write to list them)\n\n", len(blocks))
+		return
+	}
+	writeFileBlock(client, blocks[n-1])
+}
+
+// Show the change a block makes and write it once confirmed, through the
+// writeFile tool so the approval policy and file access rules apply and
+// the write joins the conversation; false when input has ended
+func writeFileBlock(client *Client, block fileBlock) bool {
+	newLines := fileLines(block.Code)
+	diff := unifiedDiff(block.Path, block.Current, newLines, 1)
+	if !strings.Contains(diff, "@@") {
+		fmt.Printf("✅ %s already matches the block\n\n", block.Path)
+		return true
+	}
+	if !block.Exists {
+		diff = strings.Replace(diff, "--- a/"+filepath.ToSlash(block.Path), "--- /dev/null", 1)
+	}
+	fmt.Println()
+	fmt.Print(diff)
+	fmt.Println()
+
+	fmt.Printf("   Write %s? [y/N] ", block.Path)
+	answer, ok := stdin.ReadLine()
+	if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		fmt.Println("🚫 Not written")
+		fmt.Println()
+		return ok
+	}
+
+	code := block.Code
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	stopApprovals := watchApprovals(client)
+	execution, err := client.RunTool("writeFile", map[string]interface{}{"path": block.Path, "content": code})
+	stopApprovals()
+	switch {
+	case err != nil:
+		fmt.Printf("❌ Not written: %v\n\n", err)
+	case execution.Error != "":
+		fmt.Printf("❌ %s\n\n", execution.Error)
+	default:
+		fmt.Printf("✏️  Wrote %s (%d lines)\n\n", block.Path, len(newLines))
+	}
+	return true
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Lines of the file shown on each side of the error line
+const fixContextLines = 20
+
+// Locations as compilers and linters print them: file:line, file:line:col
+// (gcc, go, eslint --format unix, ruff, rustc), and file(line,col) (tsc,
+// MSVC), optionally followed by the message
+var fixLocationPattern = regexp.MustCompile(`^(.+?)(?::(\d+)(?::(\d+))?|\((\d+)(?:,\s*(\d+))?\))(?::\s*(.*))?$`)
+
+// Line numbers the excerpt is shown with, in case the reply keeps them
+var fixNumberPattern = regexp.MustCompile(`^\s*\d+[>|] ?`)
+
+// Fix instructions; the reply format is what fixReplacement reads
+const fixInstructions = "You fix one compiler or linter error. You get the error and a numbered excerpt of the file around it; " +
+	"the error line is marked with >. Make the smallest change that fixes the error, changing only lines inside the excerpt. " +
+	"Reply with the whole excerpt, corrected, in a single fenced code block: every line from its first to its last, " +
+	"without line numbers, with the original indentation. Write nothing else."
+
+// Where an error points
+type FixLocation struct {
+	File    string
+	Line    int
+	Column  int    // 0 when not given
+	Message string // Message printed after the location, if any
+}
+
+// Read a location like main.go:12, main.go:12:5: undefined: x, or app.ts(12,5)
+func parseFixLocation(text string) (FixLocation, error) {
+	match := fixLocationPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return FixLocation{}, fmt.Errorf("invalid location %q (expected <file>:<line>)", text)
+	}
+	line, column := match[2], match[3]
+	if match[4] != "" {
+		line, column = match[4], match[5]
+	}
+	location := FixLocation{File: match[1], Message: strings.TrimSpace(match[6])}
+	location.Line, _ = strconv.Atoi(line)
+	location.Column, _ = strconv.Atoi(column)
+	if location.Line < 1 {
+		return FixLocation{}, fmt.Errorf("invalid line number in %q", text)
+	}
+	return location, nil
+}
+
+// Options of `painika fix`
+type FixArgs struct {
+	Location FixLocation
+	Message  string
+	Apply    bool // Apply without asking
+	DiffOnly bool // Print the diff and leave the file alone
+}
+
+func parseFixArgs(args []string) (FixArgs, error) {
+	var parsed FixArgs
+	var rest []string
+	for _, arg := range args {
+		switch arg {
+		case "--apply", "-y":
+			parsed.Apply = true
+		case "--diff":
+			parsed.DiffOnly = true
+		default:
+			if strings.HasPrefix(arg, "-") && len(rest) == 0 {
+				return parsed, fmt.Errorf("unknown flag: %s", arg)
+			}
+			rest = append(rest, arg)
+		}
+	}
+	if parsed.Apply && parsed.DiffOnly {
+		return parsed, fmt.Errorf("--apply and --diff can't be used together")
+	}
+	if len(rest) == 0 {
+		return parsed, fmt.Errorf("missing <file>:<line>")
+	}
+
+	location, err := parseFixLocation(rest[0])
+	if err != nil {
+		return parsed, err
+	}
+	parsed.Location = location
+	parsed.Message = strings.TrimSpace(strings.Join(rest[1:], " "))
+	if parsed.Message == "" {
+		parsed.Message = location.Message
+	}
+	if parsed.Message == "" {
+		return parsed, fmt.Errorf("missing the error message")
+	}
+	return parsed, nil
+}
+
+// Excerpt of the file around the error, numbered, with the error line marked
+func fixExcerpt(lines []string, first, last, errorLine int) string {
+	width := len(strconv.Itoa(last))
+	var excerpt strings.Builder
+	for n := first; n <= last; n++ {
+		mark := "|"
+		if n == errorLine {
+			mark = ">"
+		}
+		fmt.Fprintf(&excerpt, "%*d%s %s\n", width, n, mark, lines[n-1])
+	}
+	return excerpt.String()
+}
+
+// Corrected excerpt from the reply: its first code block, without line
+// numbers if the model copied them
+func fixReplacement(reply string) ([]string, error) {
+	blocks := extractCodeBlocks(reply)
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("the reply has no code block")
+	}
+	lines := strings.Split(blocks[0].Code, "\n")
+	for _, line := range lines {
+		if line != "" && !fixNumberPattern.MatchString(line) {
+			return lines, nil
+		}
+	}
+	for i, line := range lines {
+		lines[i] = fixNumberPattern.ReplaceAllString(line, "")
+	}
+	return lines, nil
+}
+
+// One line of a line diff
+type diffOp struct {
+	Kind byte // ' ', '-', or '+'
+	Text string
+}
+
+// Line diff of two texts (longest common subsequence)
+func diffLines(old, new []string) []diffOp {
+	common := make([][]int, len(old)+1)
+	for i := range common {
+		common[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			ops = append(ops, diffOp{' ', old[i]})
+			i++
+			j++
+		case i < len(old) && (j == len(new) || common[i+1][j] >= common[i][j+1]):
+			ops = append(ops, diffOp{'-', old[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', new[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// Unified diff of a change to lines starting at line first of path, with
+// three lines of context around each hunk
+func unifiedDiff(path string, old, new []string, first int) string {
+	const context = 3
+	ops := diffLines(old, new)
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(path), filepath.ToSlash(path))
+	for start := 0; start < len(ops); {
+		if ops[start].Kind == ' ' {
+			start++
+			continue
+		}
+
+		// A hunk runs until the changes are more than two contexts apart
+		end := start
+		for next := start; next < len(ops); next++ {
+			if ops[next].Kind != ' ' {
+				if next-end > 2*context {
+					break
+				}
+				end = next + 1
+			}
+		}
+		from, to := max(start-context, 0), min(end+context, len(ops))
+
+		oldLine, newLine := first, first
+		for _, op := range ops[:from] {
+			if op.Kind != '+' {
+				oldLine++
+			}
+			if op.Kind != '-' {
+				newLine++
+			}
+		}
+		var oldCount, newCount int
+		for _, op := range ops[from:to] {
+			if op.Kind != '+' {
+				oldCount++
+			}
+			if op.Kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, op := range ops[from:to] {
+			diff.WriteString(string(op.Kind) + op.Text + "\n")
+		}
+		start = to
+	}
+	return diff.String()
+}
+
+// Ask the model for a fix and return the file's new lines
+func requestFix(client *Client, fix FixArgs, lines []string) ([]string, int, int, error) {
+	errorLine := fix.Location.Line
+	first := max(errorLine-fixContextLines, 1)
+	last := min(errorLine+fixContextLines, len(lines))
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "File: %s\n", filepath.ToSlash(fix.Location.File))
+	if fix.Location.Column > 0 {
+		fmt.Fprintf(&content, "Error at line %d, column %d: %s\n\n", errorLine, fix.Location.Column, fix.Message)
+	} else {
+		fmt.Fprintf(&content, "Error at line %d: %s\n\n", errorLine, fix.Message)
+	}
+	fmt.Fprintf(&content, "Lines %d-%d:\n```%s\n%s```", first, last, strings.TrimPrefix(filepath.Ext(fix.Location.File), "."), fixExcerpt(lines, first, last, errorLine))
+
+	reply, err := client.Complete(fixInstructions, content.String())
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	replacement, err := fixReplacement(reply)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return replacement, first, last, nil
+}
+
+// Handle `painika fix <file>:<line> "<error>"`: ask for the smallest fix,
+// show it as a diff, and apply it once confirmed
+func runFix(args []string) {
+	fix, err := parseFixArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("Usage: painika fix <file>:<line>[:col] \"<error>\" [--apply | --diff]")
+		exit(2)
+	}
+
+	data, err := os.ReadFile(fix.Location.File)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	text := string(data)
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+	trailing := strings.HasSuffix(text, newline)
+	lines := strings.Split(strings.TrimSuffix(text, newline), newline)
+	if fix.Location.Line > len(lines) {
+		fmt.Printf("❌ %s has %d lines; line %d is past its end\n", fix.Location.File, len(lines), fix.Location.Line)
+		exit(1)
+	}
+
+	config := loadConfig()
+	setupCleanupHandlers()
+	if getEnv("SERVER_URL", "") == "" {
+		serverURL, err := startBatchServer()
+		if err != nil {
+			fmt.Printf("❌ Failed to start server: %v\n", err)
+			stopBatchServers()
+			exit(1)
+		}
+		config.ServerURL = serverURL
+	}
+
+	fmt.Printf("🔧 Fixing %s:%d with %s...\n", fix.Location.File, fix.Location.Line, config.Model)
+	client := NewClient(config)
+	var replacement []string
+	var first, last int
+	if err = client.InitSession(); err == nil {
+		replacement, first, last, err = requestFix(client, fix, lines)
+	}
+	stopBatchServers()
+	if err != nil {
+		fmt.Printf("❌ No fix: %v\n", err)
+		exit(1)
+	}
+
+	diff := unifiedDiff(fix.Location.File, lines[first-1:last], replacement, first)
+	if !strings.Contains(diff, "@@") {
+		fmt.Println("🤷 The model suggested no change")
+		exit(1)
+	}
+	fmt.Println()
+	fmt.Print(diff)
+	fmt.Println()
+	if fix.DiffOnly {
+		exit(0)
+	}
+
+	if !fix.Apply {
+		if !isTerminal(os.Stdin) {
+			fmt.Println("💡 Not applied; pass --apply to apply fixes without asking")
+			exit(0)
+		}
+		stdin = newLineReader(os.Stdin)
+		fmt.Print("   Apply this fix? [y/N] ")
+		answer, _ := stdin.ReadLine()
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			fmt.Println("🚫 Not applied")
+			exit(1)
+		}
+	}
+
+	fixed := append(append(append([]string{}, lines[:first-1]...), replacement...), lines[last:]...)
+	output := strings.Join(fixed, newline)
+	if trailing {
+		output += newline
+	}
+	info, err := os.Stat(fix.Location.File)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if err := os.WriteFile(fix.Location.File, []byte(output), info.Mode().Perm()); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("✅ Fixed %s\n", fix.Location.File)
+	exit(0)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Most follow-ups offered after a reply
+const maxFollowUps = 3
+
+// What a reply says that hints at the next step
+var (
+	followUpTestPath = regexp.MustCompile(`(_test\.|\.test\.|\.spec\.|(^|/)tests?/|(^|/)test_)`)
+	followUpOptions  = regexp.MustCompile(`(?i)\b(alternatives?|options?|approach(es)?|instead|trade-?offs?|pros and cons|versus|vs\.?)\b`)
+	followUpError    = regexp.MustCompile(`(?i)\b(error|errors|fails?|failing|failed|panic|exception)\b`)
+)
+
+// Tools that change files
+var editingTools = []string{"writeFile", "editFile", "apply_patch", "rename_symbol"}
+
+// Follow-ups shown after the last reply, picked by typing their number
+var followUps []string
+
+// Read FOLLOW_UPS: on suggests a few next prompts after each reply
+func followUpsConfig() (bool, error) {
+	switch strings.ToLower(getEnv("FOLLOW_UPS", "off")) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid FOLLOW_UPS %q (expected on or off)", getEnv("FOLLOW_UPS", "off"))
+}
+
+// Files the turn's tool calls changed
+func editedFiles(messages []Message) []string {
+	var files []string
+	for _, message := range messages {
+		for _, call := range message.ToolCalls {
+			if !containsTag(editingTools, call.Name) {
+				continue
+			}
+			if call.Name == "rename_symbol" && call.Parameters["apply"] != true {
+				continue
+			}
+			path, _ := call.Parameters["path"].(string)
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Guess what the user is likely to ask next from the turn's messages
+func suggestFollowUps(messages []Message) []string {
+	reply := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" && strings.TrimSpace(messages[i].Content) != "" {
+			reply = strings.TrimSpace(messages[i].Content)
+			break
+		}
+	}
+	if reply == "" {
+		return nil
+	}
+
+	var code []CodeBlock
+	testCode := false
+	for _, block := range extractCodeBlocks(reply) {
+		if containsTag(shellLangs, strings.ToLower(block.Lang)) {
+			continue
+		}
+		code = append(code, block)
+		if strings.Contains(block.Code, "func Test") || strings.Contains(block.Code, "describe(") || strings.Contains(block.Code, "def test_") {
+			testCode = true
+		}
+	}
+	edited := editedFiles(messages)
+	for _, path := range edited {
+		if followUpTestPath.MatchString(path) {
+			testCode = true
+		}
+	}
+
+	var suggestions []string
+	if strings.HasSuffix(reply, "?") {
+		suggestions = append(suggestions, "Yes, go ahead")
+	}
+	if !testCode {
+		if len(edited) > 0 {
+			suggestions = append(suggestions, "Now add tests for these changes")
+		} else if len(code) > 0 {
+			suggestions = append(suggestions, "Now add tests for this")
+		}
+	}
+	switch commands := shellBlocks(reply); {
+	case len(commands) == 1:
+		suggestions = append(suggestions, "/run 1")
+	case len(commands) > 1:
+		suggestions = append(suggestions, "/run")
+	}
+	if followUpError.MatchString(reply) {
+		suggestions = append(suggestions, "Explain what causes the error")
+	}
+	if followUpOptions.MatchString(reply) || len(code) > 0 || len(edited) > 0 {
+		suggestions = append(suggestions, "Explain the tradeoffs of this approach")
+	}
+	if len(strings.Fields(reply)) > 250 {
+		suggestions = append(suggestions, "Summarize that in three bullet points")
+	}
+	if len(code) > 0 {
+		suggestions = append(suggestions, "Show an example of using it")
+	}
+	suggestions = append(suggestions, "Go into more detail", "Give a concrete example")
+
+	if len(suggestions) > maxFollowUps {
+		suggestions = suggestions[:maxFollowUps]
+	}
+	return suggestions
+}
+
+// Show follow-ups for the reply just given, when turned on
+func offerFollowUps(client *Client, messages []Message) {
+	followUps = nil
+	if !client.config.FollowUps || !renderer.Interactive() {
+		return
+	}
+	followUps = suggestFollowUps(messages)
+	if len(followUps) == 0 {
+		return
+	}
+	fmt.Println("💡 Follow-ups (type the number):")
+	for i, suggestion := range followUps {
+		fmt.Printf("   %d. %s\n", i+1, suggestion)
+	}
+	fmt.Println()
+}
+
+// The follow-up a bare number picks, or the input as typed; suggestions
+// only last until the next input
+func pickFollowUp(input string) string {
+	suggestions := followUps
+	followUps = nil
+	n, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || n < 1 || n > len(suggestions) {
+		return input
+	}
+	fmt.Printf("↪ %s\n", suggestions[n-1])
+	return suggestions[n-1]
+}
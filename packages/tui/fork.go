@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Check whether the conversation can end after message n (1-based) without
+// separating a tool call from its results
+func isForkPoint(messages []Message, n int) bool {
+	if len(messages[n-1].ToolCalls) > 0 {
+		return false
+	}
+	return n == len(messages) || messages[n].Role != "tool"
+}
+
+// Handle /fork <n>: continue in a new session holding messages 1..n
+func forkConversation(client *Client, args string) {
+	n, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	if err != nil {
+		fmt.Println("Usage: /fork <n>  (message numbers as in history)")
+		fmt.Println()
+		return
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+	if n < 1 || n > len(conversation.Messages) {
+		fmt.Printf("❌ No message #%d (conversation has %d messages)\n\n", n, len(conversation.Messages))
+		return
+	}
+	if !isForkPoint(conversation.Messages, n) {
+		next := n + 1
+		for next < len(conversation.Messages) && !isForkPoint(conversation.Messages, next) {
+			next++
+		}
+		fmt.Printf("❌ Message #%d is part of a tool call; fork at #%d to keep its results\n\n", n, next)
+		return
+	}
+
+	// Keep the original thread before leaving it
+	parent, err := recordSession(client)
+	if err != nil {
+		fmt.Printf("❌ Error saving session: %v\n\n", err)
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	fork := &Conversation{
+		ID:        randomID(16),
+		Messages:  append([]Message{}, conversation.Messages[:n]...),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, msg := range fork.Messages {
+		if msg.Tokens != nil {
+			fork.TotalTokens.Input += msg.Tokens.Input
+			fork.TotalTokens.Output += msg.Tokens.Output
+		}
+	}
+
+	saved := &SavedSession{
+		ID:        fork.ID,
+		ParentID:  parent.ID,
+		ForkedAt:  n,
+		Tags:      parent.Tags,
+		CreatedAt: now,
+	}
+	if err := saveSession(saved); err != nil {
+		fmt.Printf("❌ Error saving session: %v\n\n", err)
+		return
+	}
+
+	if err := restoreWithSettings(client, fork); err != nil {
+		fmt.Printf("❌ Failed to start the forked session: %v\n\n", err)
+		return
+	}
+	activePlan = nil
+	turnUsage = nil
+
+	if _, err := recordSession(client); err != nil {
+		fmt.Printf("⚠️  Session not saved: %v\n", err)
+	}
+
+	fmt.Printf("🍴 Forked %s at message #%d into session %s\n", shortID(parent.ID), n, shortID(fork.ID))
+	fmt.Println("   The original is saved; both are listed by: painika sessions list")
+	fmt.Println()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A message as it was before /drop (or the token warning) replaced it
+type compactedMessage struct {
+	Original Message
+	Seq      int // Session log event that removed it, 0 when dropped this run
+}
+
+// Messages dropped during this run, by ID, kept before the server replaced
+// them so /history full can show what was there
+var droppedOriginals = map[string]compactedMessage{}
+
+// Messages a session's log shows being compacted away: replaced ones by the
+// ID of their replacement, and the rest, which left the conversation
+// altogether, in the order they went
+func loggedCompactions(id string) (map[string]compactedMessage, []compactedMessage) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, nil
+	}
+	_, events, _, err := replaySessionLog(sessionLogPath(dir, id), 0)
+	if err != nil || len(events) == 0 {
+		return nil, nil
+	}
+
+	replaced := map[string]compactedMessage{}
+	pending := map[string]compactedMessage{}
+	var order []string
+	var conversation *Conversation
+	for _, event := range events {
+		if event.Type == "compaction" && conversation != nil {
+			for _, at := range event.Dropped {
+				if at >= 0 && at < len(conversation.Messages) {
+					message := conversation.Messages[at]
+					if _, seen := pending[message.ID]; !seen {
+						order = append(order, message.ID)
+					}
+					pending[message.ID] = compactedMessage{Original: message, Seq: event.Seq}
+				}
+			}
+		}
+		if (event.Type == "message" || event.Type == "tool") && event.Message != nil {
+			if original, ok := pending[event.Message.ID]; ok && original.Original.Content != event.Message.Content {
+				// The earliest text is the one the model saw first
+				if _, kept := replaced[event.Message.ID]; !kept {
+					replaced[event.Message.ID] = original
+				}
+			}
+			delete(pending, event.Message.ID)
+		}
+		conversation = applySessionEvent(conversation, event)
+	}
+
+	var removed []compactedMessage
+	for _, id := range order {
+		if original, ok := pending[id]; ok {
+			removed = append(removed, original)
+		}
+	}
+	return replaced, removed
+}
+
+// Index of the first non-system message the model saw in the last turn
+func lastTurnStart(client *Client, messages []Message) int {
+	var rest []Message
+	systemTokens := 0
+	for _, message := range messages {
+		if message.Role == "system" {
+			systemTokens += historyTokens(message)
+		} else {
+			rest = append(rest, message)
+		}
+	}
+	last := -1
+	for i, message := range rest {
+		if message.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return 0
+	}
+
+	room := 1 << 30
+	if capabilities, err := client.Capabilities(); err == nil {
+		room = capabilities.ContextTokens - replyTokens - systemTokens
+	}
+	lastTokens := 0
+	for _, message := range rest[last:] {
+		lastTokens += historyTokens(message)
+	}
+	return historyStart(rest[:last], client.config.HistoryWindow, room, lastTokens)
+}
+
+// Handle `history full`: every message with what the model actually saw
+// of it, the text of dropped messages, and which messages the history
+// window left out of the last turn
+func showFullHistory(client *Client) {
+	conversation, err := client.GetConversation()
+	if err != nil {
+		renderer.Error("Error getting conversation", err)
+		return
+	}
+
+	replaced, removed := loggedCompactions(conversation.ID)
+	originals := map[string]compactedMessage{}
+	for id, original := range replaced {
+		originals[id] = original
+	}
+	for id, original := range droppedOriginals {
+		if _, ok := originals[id]; !ok {
+			originals[id] = original
+		}
+	}
+	start := lastTurnStart(client, conversation.Messages)
+
+	fmt.Printf("📚 Full history (%d messages):\n", len(conversation.Messages))
+	width := termWidth() - 22
+	visible := 0
+	hidden := 0
+	for i, msg := range conversation.Messages {
+		if msg.Role == "system" {
+			continue
+		}
+		icon := "💬"
+		if msg.Role == "assistant" {
+			icon = "🤖"
+		} else if msg.Role == "tool" {
+			icon = "🔧"
+		}
+		inView := visible >= start
+		visible++
+
+		marks := ""
+		if !inView {
+			marks = "🙈 "
+			hidden++
+		}
+		original, compacted := originals[msg.ID]
+		if compacted {
+			marks += "✂️  "
+		}
+		content := truncateWidth(marks+strings.Join(strings.Fields(msg.Content), " "), width)
+		fmt.Printf("   %d. %s [%s] %s\n", i+1, icon, clockTime(msg.Timestamp), content)
+		if compacted {
+			when := "dropped this session"
+			if original.Seq > 0 {
+				when = fmt.Sprintf("dropped at log event #%d", original.Seq)
+			}
+			was := strings.Join(strings.Fields(original.Original.Content), " ")
+			fmt.Printf("      was (%s, %d characters): %s\n", when, len(original.Original.Content), truncateWidth(was, width-8))
+		}
+	}
+
+	if len(removed) > 0 {
+		fmt.Printf("🗃️  Removed from the conversation (%d):\n", len(removed))
+		for _, original := range removed {
+			msg := original.Original
+			content := truncateWidth(strings.Join(strings.Fields(msg.Content), " "), width-12)
+			fmt.Printf("   - %s at event #%d [%s] %s\n", msg.Role, original.Seq, clockTime(msg.Timestamp), content)
+		}
+	}
+
+	if hidden > 0 {
+		fmt.Printf("🙈 The model did not see %d older message(s) in the last turn (history window: /set history_window)\n", hidden)
+	}
+	if len(originals) > 0 {
+		fmt.Println("✂️  Dropped messages were sent as a placeholder; \"was\" shows what they held before")
+	}
+	if hidden == 0 && len(originals) == 0 && len(removed) == 0 {
+		fmt.Println("   Nothing was compacted; the model saw every message")
+	}
+	fmt.Println()
+}
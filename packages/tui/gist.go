@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Token for creating gists: GIST_TOKEN, or GITHUB_TOKEN (it needs the gist scope)
+func gistToken() (string, error) {
+	if getEnv("GIST_TOKEN", "") != "" {
+		return getSecret("GIST_TOKEN")
+	}
+	return getSecret("GITHUB_TOKEN")
+}
+
+// Create a secret gist holding one file and return its URL
+func createGist(token, description, filename, content string) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"description": description,
+		"public":      false,
+		"files":       map[string]interface{}{filename: map[string]string{"content": content}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := strings.TrimRight(getEnv("GITHUB_API_URL", "https://api.github.com"), "/") + "/gists"
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer(token))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return "", fmt.Errorf("%s refused the token (status %d); it needs the gist scope", req.URL.Host, resp.StatusCode)
+	default:
+		return "", fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+
+	var result struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.HTMLURL, nil
+}
+
+// Handle /gist [description]: share the conversation as a secret gist, with
+// secrets redacted and incognito messages left out
+func shareGist(client *Client, description string) {
+	token, err := gistToken()
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	if token == "" {
+		fmt.Println("❌ Set GIST_TOKEN (or GITHUB_TOKEN) to a token with the gist scope")
+		fmt.Println()
+		return
+	}
+
+	user, err := loadUserConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	rules, err := redactionRules(user)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+	markdown, redacted := redactSecrets(conversationMarkdown(client.config.Incognito.Scrub(conversation)), rules)
+
+	if description == "" {
+		description = "Painika session " + conversation.ID
+	}
+	fmt.Print("🔗 Uploading")
+	stop := showThinking()
+	url, err := createGist(token, description, fmt.Sprintf("painika-%s.md", conversation.ID), markdown)
+	stop()
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("❌ Failed to create the gist: %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("🔗 Secret gist: %s\n", url)
+	if redacted > 0 {
+		fmt.Printf("   %d secret(s) redacted\n", redacted)
+	}
+	fmt.Println("💡 Anyone with the link can read it")
+	fmt.Println()
+}
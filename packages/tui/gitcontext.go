@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Changed files listed in the git context before the rest are summarized
+const maxContextFiles = 30
+
+// Read GIT_CONTEXT_COMMITS: recent commit subjects to include (0 or off disables the git context)
+func gitContextConfig() (int, error) {
+	value := getEnv("GIT_CONTEXT_COMMITS", "5")
+	if strings.EqualFold(value, "off") {
+		return 0, nil
+	}
+	commits, err := strconv.Atoi(value)
+	if err != nil || commits < 0 {
+		return 0, fmt.Errorf("GIT_CONTEXT_COMMITS must be a number of commits or off, got %q", value)
+	}
+	return commits, nil
+}
+
+// Branch, recent commits, and dirty files of the workspace
+type GitState struct {
+	Branch  string
+	Commits []string // "<short hash> <subject>", newest first
+	Changes []string // `git status --short` lines
+}
+
+// Read the git state of dir (nil outside a repository)
+func readGitState(dir string, commits int) *GitState {
+	if !isGitRepo(dir) {
+		return nil
+	}
+
+	// symbolic-ref also names the branch of a repository without commits
+	state := &GitState{}
+	if branch, err := runGit(dir, "symbolic-ref", "--short", "HEAD"); err == nil {
+		state.Branch = strings.TrimSpace(branch)
+	} else if head, err := runGit(dir, "rev-parse", "--short", "HEAD"); err == nil {
+		state.Branch = "detached at " + strings.TrimSpace(head)
+	}
+
+	// A new repository has no commits yet
+	if output, err := runGit(dir, "log", "-n", strconv.Itoa(commits), "--format=%h %s"); err == nil {
+		state.Commits = nonEmptyLines(output)
+	}
+	if output, err := runGit(dir, "status", "--short", "--untracked-files=normal"); err == nil {
+		state.Changes = nonEmptyLines(output)
+	}
+	return state
+}
+
+func nonEmptyLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, "\r"))
+		}
+	}
+	return lines
+}
+
+// Format the git state as a system prompt section
+func (s *GitState) Context() string {
+	var b strings.Builder
+	b.WriteString("# Git Context\n")
+	b.WriteString("The user's in-flight work; align suggestions with it.\n")
+	if s.Branch != "" {
+		b.WriteString("Branch: " + s.Branch + "\n")
+	}
+
+	if len(s.Commits) > 0 {
+		b.WriteString("Recent commits:\n")
+		for _, commit := range s.Commits {
+			b.WriteString("- " + commit + "\n")
+		}
+	}
+
+	if len(s.Changes) == 0 {
+		b.WriteString("Working tree clean\n")
+	} else {
+		b.WriteString("Uncommitted changes (git status --short):\n")
+		for i, change := range s.Changes {
+			if i == maxContextFiles {
+				fmt.Fprintf(&b, "- ... and %d more\n", len(s.Changes)-maxContextFiles)
+				break
+			}
+			b.WriteString("- " + change + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Git context for the session ("" when disabled or outside a repository)
+func gitContext(config Config) string {
+	if config.GitCommits == 0 {
+		return ""
+	}
+	if state := readGitState(config.FileAccess.Root, config.GitCommits); state != nil {
+		return state.Context()
+	}
+	return ""
+}
+
+// Handle /refresh-context
+func refreshContext(client *Client) {
+	if client.config.GitCommits == 0 {
+		fmt.Println("🌿 Git context is off (set GIT_CONTEXT_COMMITS to enable it)")
+		fmt.Println()
+		return
+	}
+
+	state := readGitState(client.config.FileAccess.Root, client.config.GitCommits)
+	context := ""
+	if state != nil {
+		context = state.Context()
+	}
+
+	// Replaces the previous git context in the system prompt
+	if err := client.UpdateSettings(map[string]interface{}{"gitContext": context}); err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	if state == nil {
+		fmt.Println("🌿 Not a git repository; git context cleared")
+	} else {
+		fmt.Printf("🌿 Git context refreshed: %s, %d recent commits, %d changed files\n", state.Branch, len(state.Commits), len(state.Changes))
+	}
+	fmt.Println()
+}
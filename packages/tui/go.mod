@@ -2,4 +2,8 @@ module code-agent/tui
 
 go 1.21
 
-require github.com/joho/godotenv v1.5.1 // indirect
+require github.com/joho/godotenv v1.5.1
+
+require code-agent/server v0.0.0
+
+replace code-agent/server => ../server
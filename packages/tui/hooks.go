@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Settings for the review hooks, under "hooks" in the config files
+type HookConfig struct {
+	BlockOn       string  `json:"block_on,omitempty"`        // Lowest severity that blocks: high, medium, low, or never (default: high)
+	MaxCost       float64 `json:"max_cost,omitempty"`        // Estimated spend per review in USD (default: 0.01)
+	MaxDiffTokens int     `json:"max_diff_tokens,omitempty"` // Longer diffs are cut (default: 8000)
+	Model         string  `json:"model,omitempty"`           // Model for reviews (default: the usual model)
+}
+
+// Project hook settings win when set
+func mergeHookConfig(base, override HookConfig) HookConfig {
+	if override.BlockOn != "" {
+		base.BlockOn = override.BlockOn
+	}
+	if override.MaxCost > 0 {
+		base.MaxCost = override.MaxCost
+	}
+	if override.MaxDiffTokens > 0 {
+		base.MaxDiffTokens = override.MaxDiffTokens
+	}
+	if override.Model != "" {
+		base.Model = override.Model
+	}
+	return base
+}
+
+// Git hooks `painika hooks install` can write
+var reviewHooks = []string{"pre-commit", "pre-push"}
+
+// Severities from most to least severe; block_on picks a cut-off
+var severities = []string{"high", "medium", "low"}
+
+// Every hook painika writes says so, so hooks it did not write are left alone
+const hookMarker = "Written by `painika hooks install`"
+
+// Review instructions; the reply format is what parseFindings reads
+const reviewInstructions = "You review a git diff before it is committed. Report only real problems in the changed lines: " +
+	"bugs, security issues, data loss, broken error handling. Ignore style and naming. " +
+	"Write one finding per line as SEVERITY: file:line: problem, where SEVERITY is HIGH (must fix: the change is broken or unsafe), " +
+	"MEDIUM (likely bug), or LOW (worth a look). If there is nothing to report, reply with NO ISSUES only."
+
+// Output reserved for the reply when checking the budget
+const reviewReplyTokens = 1000
+
+var findingPattern = regexp.MustCompile(`(?i)^[\s\-*]*\**(high|medium|low)\**\s*[:\-–]\s*(.+)$`)
+
+// One problem reported by a review
+type Finding struct {
+	Severity string // "high", "medium", or "low"
+	Message  string
+}
+
+// Read the findings from a review reply; lines in other shapes are ignored
+func parseFindings(reply string) []Finding {
+	var findings []Finding
+	for _, line := range strings.Split(reply, "\n") {
+		if match := findingPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			findings = append(findings, Finding{Severity: strings.ToLower(match[1]), Message: strings.TrimSpace(match[2])})
+		}
+	}
+	return findings
+}
+
+// Findings at or above the block_on severity
+func blockingFindings(findings []Finding, blockOn string) []Finding {
+	var blocking []Finding
+	for _, finding := range findings {
+		for _, severity := range severities {
+			if finding.Severity == severity {
+				blocking = append(blocking, finding)
+			}
+			if severity == blockOn {
+				break
+			}
+		}
+	}
+	return blocking
+}
+
+// Hook settings with defaults filled in
+func hookSettings() (HookConfig, error) {
+	user, err := loadUserConfig()
+	if err != nil {
+		return HookConfig{}, err
+	}
+	hooks := mergeHookConfig(HookConfig{BlockOn: "high", MaxCost: 0.01, MaxDiffTokens: 8000}, user.Hooks)
+	hooks.BlockOn = strings.ToLower(hooks.BlockOn)
+	if hooks.BlockOn != "never" && !containsTag(severities, hooks.BlockOn) {
+		return hooks, fmt.Errorf("hooks.block_on: unknown severity %q (expected high, medium, low, or never)", hooks.BlockOn)
+	}
+	return hooks, nil
+}
+
+// Shell script for a hook; it passes on the hook's arguments (and stdin, for pre-push)
+func hookScript(hook string) string {
+	binary, err := os.Executable()
+	if err != nil {
+		binary = "painika"
+	}
+	quoted := "'" + strings.ReplaceAll(filepath.ToSlash(binary), "'", `'\''`) + "'"
+	return fmt.Sprintf("#!/bin/sh\n# %s; skip once with --no-verify or PAINIKA_SKIP_HOOKS=1\nexec %s hooks run %s \"$@\"\n",
+		hookMarker, quoted, hook)
+}
+
+// Path of a hook in the current repository, honoring core.hooksPath
+func hookPath(hook string) (string, error) {
+	output, err := runGit(".", "rev-parse", "--git-path", "hooks/"+hook)
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository")
+	}
+	return filepath.Abs(strings.TrimSpace(output))
+}
+
+// Whether painika wrote the hook at path
+func ownHook(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), hookMarker)
+}
+
+// Handle `painika hooks install [--pre-push] [--force]` and `painika hooks uninstall`
+func installHooks(args []string, remove bool) error {
+	hook, force := "pre-commit", false
+	for _, arg := range args {
+		switch arg {
+		case "--pre-push":
+			hook = "pre-push"
+		case "--force":
+			force = true
+		default:
+			return fmt.Errorf("unknown argument: %s", arg)
+		}
+	}
+
+	path, err := hookPath(hook)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+
+	if remove {
+		if !exists {
+			fmt.Printf("💡 No %s hook installed\n", hook)
+			return nil
+		}
+		if !ownHook(path) && !force {
+			return fmt.Errorf("%s was not written by painika; remove it yourself or pass --force", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Removed %s\n", path)
+		return nil
+	}
+
+	if exists && !ownHook(path) && !force {
+		return fmt.Errorf("%s already exists; pass --force to replace it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(hookScript(hook)), 0755); err != nil {
+		return err
+	}
+	fmt.Printf("🪝 Installed %s: changes are reviewed before each %s\n", path, strings.TrimPrefix(hook, "pre-"))
+	fmt.Println("💡 Configure it under \"hooks\" in .painika.json; skip a review with --no-verify")
+	return nil
+}
+
+// Diff of the commits a push sends. Git writes one line per ref to stdin:
+// <local ref> <local sha> <remote ref> <remote sha>
+func pushDiff() (string, error) {
+	const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	var diff strings.Builder
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || strings.Trim(fields[1], "0") == "" {
+			continue // Deleting a ref sends nothing to review
+		}
+		local, remote := fields[1], fields[3]
+
+		base := remote
+		if strings.Trim(remote, "0") == "" {
+			// A new branch: review the commits no remote has yet
+			commits, err := runGit(".", "rev-list", "--reverse", local, "--not", "--remotes")
+			if err != nil {
+				return "", err
+			}
+			oldest, _, _ := strings.Cut(commits, "\n")
+			if oldest == "" {
+				continue
+			}
+			base = oldest + "^"
+			if _, err := runGit(".", "rev-parse", "--verify", "--quiet", base); err != nil {
+				base = emptyTree // The branch starts at a root commit
+			}
+		}
+
+		output, err := runGit(".", "diff", "--no-color", base, local)
+		if err != nil {
+			return "", err
+		}
+		diff.WriteString(output)
+	}
+	return diff.String(), scanner.Err()
+}
+
+// Keep the start of a diff that fits in limit tokens
+func headTokens(tok Tokenizer, text string, limit int) string {
+	lines := strings.Split(text, "\n")
+	for len(lines) > 1 && tok.Count(strings.Join(lines, "\n")) > limit {
+		lines = lines[:len(lines)*3/4]
+	}
+	return strings.Join(lines, "\n") + "\n..."
+}
+
+// Handle `painika hooks run <hook>`: review the change and block it on
+// serious findings. Anything that stops the review itself (no budget, no
+// server) lets the change through with a warning.
+func runReviewHook(hook string) int {
+	if getEnv("PAINIKA_SKIP_HOOKS", "") == "1" {
+		return 0
+	}
+	hooks, err := hookSettings()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	var diff string
+	switch hook {
+	case "pre-commit":
+		diff, err = runGit(".", "diff", "--cached", "--no-color")
+	case "pre-push":
+		diff, err = pushDiff()
+	default:
+		fmt.Printf("❌ Unknown hook %q (expected pre-commit or pre-push)\n", hook)
+		return 2
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Skipping the review: %v\n", err)
+		return 0
+	}
+	if strings.TrimSpace(diff) == "" {
+		return 0
+	}
+
+	config := loadConfig()
+	if hooks.Model != "" {
+		config.Model = hooks.Model
+	}
+	config.MaxSessionCost = hooks.MaxCost
+
+	tok := tokenizerForModel(config.Model)
+	if tokens := tok.Count(diff); tokens > hooks.MaxDiffTokens {
+		fmt.Printf("⚠️  The diff is %s tokens; reviewing the first %s\n", formatTokens(tokens), formatTokens(hooks.MaxDiffTokens))
+		diff = headTokens(tok, diff, hooks.MaxDiffTokens)
+	}
+	estimate := tok.Count(reviewInstructions) + tok.Count(diff) + reviewReplyTokens
+	if config.Provider != "ollama" && estimateCost(estimate) > hooks.MaxCost {
+		fmt.Printf("⚠️  Skipping the review: it would cost about $%.4f, over hooks.max_cost ($%.4f)\n", estimateCost(estimate), hooks.MaxCost)
+		return 0
+	}
+
+	if getEnv("SERVER_URL", "") == "" {
+		serverURL, err := startBatchServer()
+		if err != nil {
+			fmt.Printf("⚠️  Skipping the review: failed to start server: %v\n", err)
+			stopBatchServers()
+			return 0
+		}
+		config.ServerURL = serverURL
+	}
+	defer stopBatchServers()
+
+	fmt.Printf("🔍 Reviewing the %s with %s...\n", map[string]string{"pre-commit": "staged changes", "pre-push": "commits to push"}[hook], config.Model)
+	client := NewClient(config)
+	reply := ""
+	if err = client.InitSession(); err == nil {
+		reply, err = client.Complete(reviewInstructions, diff)
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Skipping the review: %v\n", err)
+		return 0
+	}
+
+	findings := parseFindings(reply)
+	if len(findings) == 0 {
+		fmt.Println("✅ No issues found")
+		return 0
+	}
+	icons := map[string]string{"high": "🔴", "medium": "🟡", "low": "🔵"}
+	for _, finding := range findings {
+		fmt.Printf("   %s %s: %s\n", icons[finding.Severity], strings.ToUpper(finding.Severity), finding.Message)
+	}
+
+	if hooks.BlockOn == "never" {
+		return 0
+	}
+	blocking := blockingFindings(findings, hooks.BlockOn)
+	if len(blocking) == 0 {
+		fmt.Printf("✅ Nothing at %s severity or above; continuing\n", hooks.BlockOn)
+		return 0
+	}
+	fmt.Printf("🚫 Blocked by %d finding(s) at %s severity or above. Fix them, or skip the review with --no-verify\n",
+		len(blocking), hooks.BlockOn)
+	return 1
+}
+
+// Handle `painika hooks install|uninstall|run`
+func runHooksCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: painika hooks install [--pre-push] [--force]")
+		fmt.Println("       painika hooks uninstall [--pre-push] [--force]")
+		exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "install", "uninstall":
+		if err := installHooks(args[1:], args[0] == "uninstall"); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		exit(0) // Flushes the filtered output
+	case "run":
+		if len(args) < 2 || !containsTag(reviewHooks, args[1]) {
+			usage()
+		}
+		setupCleanupHandlers()
+		exit(runReviewHook(args[1]))
+	default:
+		fmt.Printf("❌ Unknown hooks command: %s\n", args[0])
+		usage()
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// URLs and file paths (with an optional :line or :line:col) in reply text
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `)\]]+|(?:~|\.{1,2})?/?[\w.-]+(?:/[\w.-]+)*\.\w+(?::\d+){0,2}|(?:~|\.{1,2})?/[\w.-]+(?:/[\w.-]+)+`)
+
+// Punctuation that ends a sentence rather than a URL
+const linkTrailing = ".,;:!?'\""
+
+// Editors that take +<line> before the file, and those that take file:line
+var (
+	plusLineEditors  = []string{"vi", "vim", "nvim", "gvim", "nano", "emacs", "emacsclient", "micro", "kak", "joe", "mg", "ne"}
+	colonLineEditors = []string{"subl", "zed", "hx", "helix", "mate"}
+	gotoLineEditors  = []string{"code", "code-insiders", "codium", "cursor", "windsurf"}
+)
+
+// Terminal hyperlinks (OSC 8) on file paths and URLs in replies
+type Hyperlinks struct {
+	On      bool
+	Handler string // "file" opens paths with the OS, "editor" in $EDITOR through painika://
+	Root    string // Directory relative paths are resolved from
+}
+
+// Active hyperlink settings, chosen by HYPERLINKS and LINK_HANDLER
+var hyperlinks Hyperlinks
+
+// Whether the terminal is one known to show OSC 8 hyperlinks. Multiplexers
+// drop them unless set up to pass them through, so those need
+// HYPERLINKS=on.
+func terminalSupportsHyperlinks() bool {
+	if !isTerminal(os.Stdout) || os.Getenv("TMUX") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby", "rio":
+		return true
+	}
+	if version, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && version >= 5000 {
+		return true
+	}
+	for _, env := range []string{"KITTY_WINDOW_ID", "WT_SESSION", "KONSOLE_VERSION", "ALACRITTY_WINDOW_ID", "DOMTERM"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	term := os.Getenv("TERM")
+	return strings.Contains(term, "kitty") || strings.Contains(term, "foot") || strings.Contains(term, "ghostty") || strings.Contains(term, "wezterm")
+}
+
+// Read HYPERLINKS (auto, on, or off) and LINK_HANDLER (file or editor)
+func hyperlinkConfig(root string) (Hyperlinks, error) {
+	links := Hyperlinks{Handler: strings.ToLower(getEnv("LINK_HANDLER", "file")), Root: root}
+	if links.Handler != "file" && links.Handler != "editor" {
+		return links, fmt.Errorf("invalid LINK_HANDLER %q (expected file or editor)", links.Handler)
+	}
+	switch mode := strings.ToLower(getEnv("HYPERLINKS", "auto")); mode {
+	case "auto":
+		links.On = terminalSupportsHyperlinks()
+	case "on":
+		links.On = true
+	case "off":
+	default:
+		return links, fmt.Errorf("invalid HYPERLINKS %q (expected auto, on, or off)", mode)
+	}
+	return links, nil
+}
+
+// Absolute path of a path mentioned in a reply
+func resolveLinkPath(path, root string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	return filepath.Join(root, path)
+}
+
+// Split "file.go:12:3" into the path and line (0 without one)
+func splitLinkLine(text string) (string, int) {
+	path, line := text, 0
+	for i := 0; i < 2; i++ {
+		before, after, ok := cutLast(path, ":")
+		n, err := strconv.Atoi(after)
+		if !ok || err != nil {
+			break
+		}
+		path, line = before, n
+	}
+	return path, line
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// Where a link on a file path goes
+func fileLinkTarget(path string, line int) string {
+	if hyperlinks.Handler == "editor" {
+		query := url.Values{"path": {path}}
+		if line > 0 {
+			query.Set("line", strconv.Itoa(line))
+		}
+		return (&url.URL{Scheme: "painika", Host: "open", RawQuery: query.Encode()}).String()
+	}
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed // Windows drive letters
+	}
+	host, _ := os.Hostname()
+	return (&url.URL{Scheme: "file", Host: host, Path: slashed}).String()
+}
+
+// Wrap text in an OSC 8 hyperlink
+func hyperlink(target, text string) string {
+	return "\033]8;;" + target + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// Add hyperlinks to URLs and to paths of files that exist in one line of
+// reply text; the text itself is unchanged
+func linkify(text string) string {
+	if !hyperlinks.On {
+		return text
+	}
+	return linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if strings.HasPrefix(match, "http://") || strings.HasPrefix(match, "https://") {
+			link := strings.TrimRight(match, linkTrailing)
+			return hyperlink(link, link) + match[len(link):]
+		}
+		shown := strings.TrimRight(match, linkTrailing)
+		path, line := splitLinkLine(shown)
+		abs := resolveLinkPath(path, hyperlinks.Root)
+		if _, err := os.Stat(abs); err != nil {
+			return match
+		}
+		return hyperlink(fileLinkTarget(abs, line), shown) + match[len(shown):]
+	})
+}
+
+// Add hyperlinks to laid-out reply text, leaving code blocks and diffs alone
+func linkifyReply(text string) string {
+	if !hyperlinks.On {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inCode = !inCode
+		case !inCode && !isDiffLine(trimmed):
+			lines[i] = linkify(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Command opening a file at a line in the editor
+func editorCommand(editor []string, path string, line int) *exec.Cmd {
+	args := append([]string{}, editor[1:]...)
+	name := strings.TrimSuffix(filepath.Base(editor[0]), ".exe")
+	switch {
+	case line > 0 && containsTag(plusLineEditors, name):
+		args = append(args, "+"+strconv.Itoa(line), path)
+	case line > 0 && containsTag(colonLineEditors, name):
+		args = append(args, fmt.Sprintf("%s:%d", path, line))
+	case line > 0 && containsTag(gotoLineEditors, name):
+		args = append(args, "-g", fmt.Sprintf("%s:%d", path, line))
+	default:
+		args = append(args, path)
+	}
+	return exec.Command(editor[0], args...)
+}
+
+// Command opening a file with whatever the OS uses for it
+func osOpenCommand(path string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", path)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	}
+	return exec.Command("xdg-open", path)
+}
+
+// Command opening a file at the line with EDITOR_COMMAND, $VISUAL, or
+// $EDITOR; nil when none is set
+func configuredEditorCommand(path string, line int) *exec.Cmd {
+	if template := getEnv("EDITOR_COMMAND", ""); strings.TrimSpace(template) != "" {
+		return templateEditorCommand(template, path, line)
+	}
+	if editor := strings.Fields(getEnv("VISUAL", getEnv("EDITOR", ""))); len(editor) > 0 {
+		return editorCommand(editor, path, line)
+	}
+	return nil
+}
+
+// Open a file at the line in the configured editor, or with the OS handler
+// when none is set
+func openFile(path string, line int) error {
+	cmd := configuredEditorCommand(path, line)
+	if cmd == nil {
+		cmd = osOpenCommand(path)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// Workspaces painika has been run in, from the saved sessions
+func knownWorkspaces() []string {
+	sessions, _ := loadSessions()
+	var workspaces []string
+	for _, session := range sessions {
+		if session.Workspace != "" && !containsTag(workspaces, session.Workspace) {
+			workspaces = append(workspaces, session.Workspace)
+		}
+	}
+	return workspaces
+}
+
+// Check a file a painika:// link asks to open: it must be a regular file
+// inside a workspace painika has been run in, symlinks resolved, so a link
+// on a web page can't reach downloads or anything else on the machine
+func checkLinkedFile(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(resolved); err != nil {
+		return "", err
+	} else if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	for _, workspace := range knownWorkspaces() {
+		root, err := filepath.EvalSymlinks(workspace)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not in a workspace painika has been run in", path)
+}
+
+// Register `painika open` as the handler of painika:// links
+func registerLinkHandler() error {
+	if configuredEditorCommand("", 0) == nil {
+		return fmt.Errorf("set EDITOR_COMMAND, VISUAL, or EDITOR first; links only open in an editor")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dir := filepath.Join(home, ".local", "share", "applications")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		// Terminal editors need a terminal to run in when a link is clicked
+		editor := strings.Fields(getEnv("VISUAL", getEnv("EDITOR", "")))
+		terminal := false
+		if len(editor) > 0 {
+			name := filepath.Base(editor[0])
+			terminal = containsTag(plusLineEditors, name) && name != "gvim"
+		}
+		entry := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=painika open\nExec=%q open %%u\nTerminal=%t\nNoDisplay=true\nMimeType=x-scheme-handler/painika;\n", exe, terminal)
+		if err := os.WriteFile(filepath.Join(dir, "painika-open.desktop"), []byte(entry), 0644); err != nil {
+			return err
+		}
+		if output, err := exec.Command("xdg-mime", "default", "painika-open.desktop", "x-scheme-handler/painika").CombinedOutput(); err != nil {
+			return fmt.Errorf("xdg-mime failed: %v %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	case "windows":
+		key := `HKCU\Software\Classes\painika`
+		for _, args := range [][]string{
+			{"add", key, "/ve", "/d", "URL:painika", "/f"},
+			{"add", key, "/v", "URL Protocol", "/d", "", "/f"},
+			{"add", key + `\shell\open\command`, "/ve", "/d", fmt.Sprintf(`"%s" open "%%1"`, exe), "/f"},
+		} {
+			if output, err := exec.Command("reg", args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("reg %s failed: %v %s", args[0], err, strings.TrimSpace(string(output)))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("registering a link handler on %s needs an app bundle; use LINK_HANDLER=file to open files with the OS instead", runtime.GOOS)
+}
+
+// Handle `painika open <painika://open?path=...|path[:line]>` and
+// `painika open --register`. This is what clicking a painika:// link runs,
+// possibly from a web page, so files only open in the configured editor
+// (never the OS handler, which would run programs) and only inside a
+// workspace painika has been run in.
+func runOpen(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: painika open <path[:line]> | painika open --register")
+		exit(2)
+	}
+	if args[0] == "--register" {
+		if err := registerLinkHandler(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		fmt.Println("✅ painika:// links now open in your editor; set LINK_HANDLER=editor to use them")
+		return
+	}
+
+	path, line := splitLinkLine(args[0])
+	if u, err := url.Parse(args[0]); err == nil && u.Scheme == "painika" {
+		path = u.Query().Get("path")
+		line, _ = strconv.Atoi(u.Query().Get("line"))
+	}
+	if path == "" {
+		fmt.Println("❌ No file to open")
+		exit(2)
+	}
+	file, err := checkLinkedFile(resolveLinkPath(path, ""))
+	if err != nil {
+		fmt.Printf("❌ Not opening %s: %v\n", path, err)
+		exit(1)
+	}
+	cmd := configuredEditorCommand(file, line)
+	if cmd == nil {
+		fmt.Println("❌ No editor to open it in: set EDITOR_COMMAND, VISUAL, or EDITOR")
+		exit(1)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("❌ Failed to open %s: %v\n", path, err)
+		exit(1)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What happens when the prompt sits idle
+type IdlePolicy struct {
+	Timeout    time.Duration // Lock after this long without input (0 disables)
+	Passphrase string        // Required to unlock ("" means Enter resumes)
+	StopServer bool          // Stop the server while locked to free memory
+}
+
+// Load the idle policy from IDLE_LOCK (minutes), IDLE_PASSPHRASE, and IDLE_STOP_SERVER
+func idleConfig() (IdlePolicy, error) {
+	minutes, err := strconv.ParseFloat(getEnv("IDLE_LOCK", "0"), 64)
+	if err != nil || minutes < 0 {
+		return IdlePolicy{}, fmt.Errorf("invalid IDLE_LOCK %q (expected minutes, 0 to disable)", getEnv("IDLE_LOCK", ""))
+	}
+
+	return IdlePolicy{
+		Timeout:    time.Duration(minutes * float64(time.Minute)),
+		Passphrase: getEnv("IDLE_PASSPHRASE", ""),
+		StopServer: strings.EqualFold(getEnv("IDLE_STOP_SERVER", "off"), "on"),
+	}, nil
+}
+
+// Lock the session until the user comes back, stopping the server if
+// configured. Returns false if input ended while locked.
+func lockSession(client *Client) bool {
+	policy := client.config.Idle
+
+	// A passphrase lock hides the conversation too
+	if policy.Passphrase != "" {
+		clearScreen()
+	}
+	fmt.Printf("\n🔒 Session locked after %s idle\n", formatIdle(policy.Timeout))
+
+	// Only a server this client started can be stopped and restarted
+	var saved *Conversation
+	if policy.StopServer && globalServerCmd != nil {
+		conversation, err := client.GetConversation()
+		if err != nil {
+			fmt.Printf("⚠️  Keeping the server running: %v\n", err)
+		} else {
+			saved = conversation
+			stopServer()
+			fmt.Println("💤 Server stopped to free memory")
+		}
+	}
+
+	if !unlockSession(policy.Passphrase) {
+		return false
+	}
+
+	if saved != nil {
+		if err := resumeServer(client, saved); err != nil {
+			fmt.Printf("❌ Failed to restart the server: %v\n", err)
+			fmt.Println("💡 Restart painika to continue")
+			return false
+		}
+	}
+
+	fmt.Println("🔓 Welcome back")
+	fmt.Println()
+	return true
+}
+
+// Wait for Enter, or the passphrase if one is set
+func unlockSession(passphrase string) bool {
+	for {
+		if passphrase == "" {
+			fmt.Print("   Press Enter to resume ")
+		} else {
+			fmt.Print("🔑 Passphrase: ")
+		}
+
+		input, ok := stdin.ReadLine()
+		if !ok {
+			return false
+		}
+		if passphrase == "" || input == passphrase {
+			return true
+		}
+		fmt.Println("❌ Wrong passphrase")
+	}
+}
+
+// Start a fresh server and carry the saved conversation and persona over
+func resumeServer(client *Client, conversation *Conversation) error {
+	fmt.Println("🔄 Restarting server...")
+	serverURL, err := launchServer(socketPath(client.config.ServerURL))
+	if err != nil {
+		return err
+	}
+	client.config.ServerURL = serverURL
+	return restoreWithSettings(client, conversation)
+}
+
+// Start a session with the conversation and reapply the active persona
+func restoreWithSettings(client *Client, conversation *Conversation) error {
+	if err := client.RestoreSession(conversation); err != nil {
+		return err
+	}
+
+	// The model is part of the session config; the persona is a setting
+	if client.config.Persona != "" {
+		if persona, ok := findPersona(client.config.Persona); ok {
+			if err := client.UpdateSettings(map[string]interface{}{"persona": persona}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Format an idle timeout like "15m" or "90s"
+func formatIdle(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Saved in place of the content of messages sent in incognito mode
+const incognitoPlaceholder = "[incognito]"
+
+// Incognito mode: messages sent while it is on are saved with their
+// metadata only (role, time, token counts), and nothing else keeps their content
+type Incognito struct {
+	On      bool
+	known   map[string]bool // Messages that existed when it was turned on
+	private map[string]bool // Messages from earlier incognito stretches
+}
+
+// Check whether a message was sent in incognito mode
+func (i *Incognito) Private(id string) bool {
+	return i.private[id] || (i.On && !i.known[id])
+}
+
+// Copy of the conversation with the content of incognito messages removed
+func (i *Incognito) Scrub(conversation *Conversation) *Conversation {
+	scrubbed := *conversation
+	scrubbed.Messages = make([]Message, len(conversation.Messages))
+	for n, msg := range conversation.Messages {
+		if i.Private(msg.ID) && msg.Role != "system" {
+			msg = scrubMessage(msg)
+		}
+		scrubbed.Messages[n] = msg
+	}
+	return &scrubbed
+}
+
+// Keep a message's metadata; drop its text, tool arguments, and tool results
+func scrubMessage(msg Message) Message {
+	msg.Content = incognitoPlaceholder
+	if len(msg.ToolCalls) > 0 {
+		calls := make([]ToolCall, len(msg.ToolCalls))
+		for n, call := range msg.ToolCalls {
+			calls[n] = ToolCall{ID: call.ID, Name: call.Name, Parameters: map[string]interface{}{}}
+		}
+		msg.ToolCalls = calls
+	}
+	if len(msg.ToolResults) > 0 {
+		results := make([]ToolResult, len(msg.ToolResults))
+		for n, result := range msg.ToolResults {
+			results[n] = ToolResult{ID: result.ID}
+		}
+		msg.ToolResults = results
+	}
+	return msg
+}
+
+// Handle /incognito [on|off]: toggle keeping this conversation off disk
+func handleIncognito(client *Client, args string) {
+	mode := &client.config.Incognito
+	on := !mode.On
+	switch strings.ToLower(args) {
+	case "":
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		fmt.Println("Usage: /incognito [on|off]")
+		fmt.Println()
+		return
+	}
+	if on == mode.On {
+		if on {
+			fmt.Println("🕶️  Incognito mode is already on")
+		} else {
+			fmt.Println("🕶️  Incognito mode is already off")
+		}
+		fmt.Println()
+		return
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+	if err := client.UpdateSettings(map[string]interface{}{"incognito": on}); err != nil {
+		fmt.Printf("❌ Failed to change incognito mode: %v\n\n", err)
+		return
+	}
+
+	if on {
+		mode.known = map[string]bool{}
+		for _, msg := range conversation.Messages {
+			mode.known[msg.ID] = true
+		}
+		mode.On = true
+		fmt.Println("🕶️  Incognito on: new messages are saved without their content, full command")
+		fmt.Println("   outputs are not kept, and facts the AI remembers are not saved")
+		fmt.Println()
+		return
+	}
+
+	// Messages from this stretch stay scrubbed for the rest of the session
+	if mode.private == nil {
+		mode.private = map[string]bool{}
+	}
+	count := 0
+	for _, msg := range conversation.Messages {
+		if !mode.known[msg.ID] {
+			mode.private[msg.ID] = true
+			count++
+		}
+	}
+	mode.known = nil
+	mode.On = false
+	fmt.Printf("🕶️  Incognito off: the %d messages sent while it was on stay unsaved\n", count)
+	fmt.Println()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Line reader shared by the prompt loop and in-turn confirmations.
+// A single goroutine owns stdin so a timed-out prompt never swallows
+// the next line typed at the main prompt.
+type lineReader struct {
+	lines chan string
+}
+
+// Standard input, set up when the interactive loop starts
+var stdin *lineReader
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		return scanner.Text(), true
+	}
+
+	// Edit lines typed at a terminal instead of echoing escape sequences
+	if f, ok := r.(*os.File); ok {
+		if editor := newLineEditor(f, os.Stdout); editor != nil {
+			next = editor.ReadLine
+		}
+	}
+
+	reader := &lineReader{lines: make(chan string)}
+	go func() {
+		for {
+			line, ok := next()
+			if !ok {
+				break
+			}
+			reader.lines <- line
+		}
+		close(reader.lines)
+	}()
+	return reader
+}
+
+// Read the next trimmed line; false at end of input
+func (r *lineReader) ReadLine() (string, bool) {
+	line, ok := <-r.lines
+	return strings.TrimSpace(line), ok
+}
+
+// Read the next trimmed line, giving up after timeout (0 waits forever).
+// The second result is false on timeout or end of input.
+func (r *lineReader) ReadLineTimeout(timeout time.Duration) (string, bool) {
+	line, ok, _ := r.ReadLineIdle(timeout)
+	return line, ok
+}
+
+// Like ReadLineTimeout, but reports a timeout separately from end of input
+func (r *lineReader) ReadLineIdle(timeout time.Duration) (line string, ok, idle bool) {
+	if timeout <= 0 {
+		line, ok = r.ReadLine()
+		return line, ok, false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case line, ok := <-r.lines:
+		return strings.TrimSpace(line), ok, false
+	case <-timer.C:
+		return "", false, true
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Full output of a command whose result was summarized for the AI
+type Job struct {
+	ID      string
+	Command string
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Get the directory the server stores full command outputs in
+func jobsDir() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "jobs"), nil
+}
+
+// List stored outputs, newest first
+func loadJobs() ([]Job, error) {
+	dir, err := jobsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		jobs = append(jobs, Job{
+			ID:      strings.TrimSuffix(entry.Name(), ".log"),
+			Command: jobCommand(path),
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ModTime.After(jobs[j].ModTime)
+	})
+	return jobs, nil
+}
+
+// Read the command from the "$ command" header line of a stored output
+func jobCommand(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimPrefix(strings.TrimSpace(line), "$ ")
+}
+
+// Find a stored output by ID or ID prefix; empty means the latest
+func findJob(jobs []Job, id string) (Job, error) {
+	if len(jobs) == 0 {
+		return Job{}, fmt.Errorf("no stored command output yet")
+	}
+	if id == "" {
+		return jobs[0], nil
+	}
+
+	var matches []Job
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, nil
+		}
+		if strings.HasPrefix(job.ID, id) {
+			matches = append(matches, job)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Job{}, fmt.Errorf("no stored output with ID %s", id)
+	case 1:
+		return matches[0], nil
+	}
+	return Job{}, fmt.Errorf("ID %s is ambiguous (%d matches)", id, len(matches))
+}
+
+// Format a byte count as B, KB, or MB
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%dKB", size/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// Handle /job [list|output [id]]
+func handleJob(args string) {
+	sub, id, _ := strings.Cut(strings.TrimSpace(args), " ")
+	id = strings.TrimSpace(id)
+
+	jobs, err := loadJobs()
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	switch sub {
+	case "", "list":
+		if len(jobs) == 0 {
+			fmt.Println("🗂️  No stored command output (only outputs too large for the conversation are kept)")
+			fmt.Println()
+			return
+		}
+		fmt.Printf("🗂️  Stored command output (%d):\n", len(jobs))
+		for _, job := range jobs {
+			fmt.Printf("   %s  %s  %6s  %s\n", job.ID, job.ModTime.Format("Jan 02 15:04"), formatSize(job.Size), truncateWidth(job.Command, 60))
+		}
+		fmt.Println()
+	case "output":
+		job, err := findJob(jobs, id)
+		if err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		data, err := os.ReadFile(job.Path)
+		if err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		fmt.Print(string(data))
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			fmt.Println()
+		}
+		fmt.Printf("🗂️  Full output of %s (%s): %s\n\n", job.ID, formatSize(job.Size), job.Path)
+	default:
+		fmt.Println("Usage: /job [list] | /job output [id]")
+		fmt.Println()
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Below this width the layout collapses to a compact form
+const narrowWidth = 60
+
+// Width used when stdout is not a terminal
+const defaultWidth = 100
+
+// Current terminal width, updated on resize
+var currentWidth atomic.Int64
+
+// Detect the terminal width and keep it updated on resize
+func initLayout() {
+	updateWidth()
+	watchResize(updateWidth)
+}
+
+func updateWidth() {
+	width, _ := terminalSize()
+	if width <= 0 {
+		if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+			width = cols
+		} else {
+			width = defaultWidth
+		}
+	}
+	currentWidth.Store(int64(width))
+}
+
+// Get the current terminal width
+func termWidth() int {
+	if width := int(currentWidth.Load()); width > 0 {
+		return width
+	}
+	return defaultWidth
+}
+
+// Check whether the terminal is too narrow for the full layout
+func isNarrow() bool {
+	return termWidth() < narrowWidth
+}
+
+// Truncate text to a display width, appending "..." when cut
+func truncateWidth(text string, width int) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if width < 4 || utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width-3]) + "..."
+}
+
+// Format a response for the terminal: render math as Unicode, wrap prose,
+// hard-wrap code and diff lines, and collapse tables that don't fit. indent
+// is the width already used on the first line (e.g. by the "🤖 " prefix).
+func formatForTerminal(text string, indent int) string {
+	text = renderMath(text)
+	width := termWidth() - 1
+	if width < 20 {
+		width = 20
+	}
+	pad := strings.Repeat(" ", indent)
+
+	var out []string
+	lines := strings.Split(text, "\n")
+	inCode := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inCode = !inCode
+			out = append(out, line)
+		case inCode || isDiffLine(line):
+			out = append(out, hardWrap(line, width-indent)...)
+		case strings.HasPrefix(trimmed, "|"):
+			// Gather the whole table
+			table := []string{line}
+			for i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "|") {
+				i++
+				table = append(table, lines[i])
+			}
+			out = append(out, formatTable(table, width-indent)...)
+		default:
+			out = append(out, wordWrap(line, width-indent)...)
+		}
+	}
+
+	return strings.Join(out, "\n"+pad)
+}
+
+// Check whether a line looks like part of a unified diff
+func isDiffLine(line string) bool {
+	return strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") ||
+		(len(line) > 1 && (line[0] == '+' || line[0] == '-') && line[1] != ' ')
+}
+
+// Wrap prose at word boundaries, keeping list indentation
+func wordWrap(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	// Continuation lines align with the text after a bullet or indent
+	lead := len(line) - len(strings.TrimLeft(line, " "))
+	rest := strings.TrimLeft(line, " ")
+	if strings.HasPrefix(rest, "- ") || strings.HasPrefix(rest, "* ") {
+		lead += 2
+	}
+	hanging := strings.Repeat(" ", lead)
+
+	var wrapped []string
+	current := ""
+	for _, word := range strings.Fields(line) {
+		if current == "" {
+			current = line[:len(line)-len(strings.TrimLeft(line, " "))] + word
+			continue
+		}
+		if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+			wrapped = append(wrapped, current)
+			current = hanging + word
+			continue
+		}
+		current += " " + word
+	}
+	return append(wrapped, current)
+}
+
+// Break a line at the width, marking continuations with ↪
+func hardWrap(line string, width int) []string {
+	runes := []rune(line)
+	if len(runes) <= width || width < 10 {
+		return []string{line}
+	}
+
+	var wrapped []string
+	for len(runes) > width {
+		wrapped = append(wrapped, string(runes[:width]))
+		runes = append([]rune("↪ "), runes[width:]...)
+	}
+	return append(wrapped, string(runes))
+}
+
+// Render a markdown table, collapsing rows to "header: value" lists when too wide
+func formatTable(rows []string, width int) []string {
+	fits := true
+	for _, row := range rows {
+		if utf8.RuneCountInString(row) > width {
+			fits = false
+			break
+		}
+	}
+	if fits {
+		return rows
+	}
+
+	split := func(row string) []string {
+		cells := strings.Split(strings.Trim(strings.TrimSpace(row), "|"), "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		return cells
+	}
+
+	headers := split(rows[0])
+	var out []string
+	for _, row := range rows[1:] {
+		cells := split(row)
+		if isTableSeparator(cells) {
+			continue
+		}
+		for j, cell := range cells {
+			label := ""
+			if j < len(headers) && headers[j] != "" {
+				label = headers[j] + ": "
+			}
+			prefix := "  "
+			if j == 0 {
+				prefix = "• "
+			}
+			out = append(out, wordWrap(prefix+label+cell, width)...)
+		}
+	}
+	return out
+}
+
+// Check whether table cells form a |---|---| separator row
+func isTableSeparator(cells []string) bool {
+	for _, cell := range cells {
+		if strings.Trim(cell, "-: ") != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	killRingSize = 10  // Kills kept for Ctrl-Y / Alt-Y
+	historySize  = 500 // Lines kept for Up / Down
+)
+
+// What the last key did, so consecutive kills merge and Alt-Y follows a yank
+const (
+	actionOther = iota
+	actionKill
+	actionYank
+)
+
+// Restore the terminal mode changed for line editing (no-op when unchanged)
+var restoreTerminal = func() {}
+
+// Editor reading the terminal, nil without one; see printAbovePrompt
+var activeEditor *lineEditor
+
+// Line editor for interactive terminals: cursor movement, word deletion, a
+// kill ring, and history, redrawn in place instead of echoing escape sequences.
+// The prompt is printed by whoever asks for the line, so the editor only
+// knows where the line starts once the terminal reports the cursor position.
+type lineEditor struct {
+	in  *bufio.Reader
+	out *os.File
+	mu  sync.Mutex // Held while a key is handled or a notice is printed
+
+	buf         []rune
+	pos         int // Cursor position in buf
+	cursorIndex int // Position in buf the terminal cursor is drawn at
+
+	anchored      bool // The current line has been started on screen
+	anchorCol     int  // 1-based column the line starts at (0 until reported)
+	pendingReport int  // Cursor position requests not answered yet
+
+	history      []string
+	historyIndex int
+	draft        []rune // Line being typed before browsing history
+
+	killRing   []string
+	yankStart  int
+	yankLen    int
+	yankIndex  int
+	lastAction int
+}
+
+// Set up line editing on a terminal; nil when input or output is not a
+// terminal, LINE_EDITING=off, or the terminal mode cannot be changed
+func newLineEditor(in, out *os.File) *lineEditor {
+	if strings.EqualFold(getEnv("LINE_EDITING", "on"), "off") || !isTerminal(in) || !isTerminal(out) {
+		return nil
+	}
+
+	restore, err := enableLineEditing(in, out)
+	if err != nil {
+		return nil
+	}
+	restoreTerminal = restore
+
+	activeEditor = &lineEditor{in: bufio.NewReader(in), out: out}
+	return activeEditor
+}
+
+// Read one edited line; false at end of input (Ctrl-D on an empty line)
+func (e *lineEditor) ReadLine() (string, bool) {
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", false
+		}
+		e.mu.Lock()
+		line, done, ok := e.key(r)
+		e.mu.Unlock()
+		if done {
+			return line, ok
+		}
+	}
+}
+
+// Handle one key; done once the line is submitted or input ends
+func (e *lineEditor) key(r rune) (line string, done, ok bool) {
+	if !e.anchored {
+		e.anchor()
+	}
+
+	action := actionOther
+	switch r {
+	case '\r', '\n':
+		return e.finish(), true, true
+	case 0x01: // Ctrl-A
+		e.moveTo(0)
+	case 0x05: // Ctrl-E
+		e.moveTo(len(e.buf))
+	case 0x02: // Ctrl-B
+		e.moveTo(e.pos - 1)
+	case 0x06: // Ctrl-F
+		e.moveTo(e.pos + 1)
+	case 0x7f, 0x08: // Backspace
+		e.deleteRange(e.pos-1, e.pos)
+	case 0x04: // Ctrl-D
+		if len(e.buf) == 0 {
+			e.finish()
+			return "", true, false
+		}
+		e.deleteRange(e.pos, e.pos+1)
+	case 0x0b: // Ctrl-K
+		action = e.kill(e.pos, len(e.buf))
+	case 0x15: // Ctrl-U
+		action = e.kill(0, e.pos)
+	case 0x17: // Ctrl-W
+		action = e.kill(e.spaceWordLeft(), e.pos)
+	case 0x19: // Ctrl-Y
+		action = e.yank()
+	case 0x14: // Ctrl-T
+		e.transpose()
+	case 0x10: // Ctrl-P
+		e.historyMove(-1)
+	case 0x0e: // Ctrl-N
+		e.historyMove(1)
+	case '\t':
+		e.insert([]rune("    "))
+	case 0x1b:
+		action = e.escape()
+	default:
+		if unicode.IsPrint(r) {
+			e.insert([]rune{r})
+		}
+	}
+	e.lastAction = action
+	return "", false, false
+}
+
+// Print text above the line being typed, then the prompt and the line again
+func (e *lineEditor) printAbove(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.moveCursor(0)
+	fmt.Fprint(e.out, "\r\x1b[J"+text)
+	renderer.Prompt()
+	e.redraw()
+}
+
+// Start a new line where the cursor is and ask the terminal where that is
+func (e *lineEditor) anchor() {
+	e.anchored = true
+	e.anchorCol = 0
+	e.cursorIndex = 0
+	e.pendingReport++
+	fmt.Fprint(e.out, "\x1b[6n")
+}
+
+// Handle an escape sequence or an Alt-key
+func (e *lineEditor) escape() int {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return actionOther
+	}
+
+	switch r {
+	case '[':
+		return e.csi()
+	case 'O': // Application-mode cursor keys
+		final, _, _ := e.in.ReadRune()
+		e.cursorKey("", final)
+	case 'b', 'B':
+		e.moveTo(e.wordLeft())
+	case 'f', 'F':
+		e.moveTo(e.wordRight())
+	case 'd', 'D':
+		return e.kill(e.pos, e.wordRight())
+	case 0x7f, 0x08:
+		return e.kill(e.wordLeft(), e.pos)
+	case 'y', 'Y':
+		return e.yankPop()
+	}
+	return actionOther
+}
+
+// Handle a control sequence: ESC [ parameters final
+func (e *lineEditor) csi() int {
+	var params strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return actionOther
+		}
+		if r >= 0x40 && r <= 0x7e {
+			// A cursor position report answers anchor(), not a key
+			if r == 'R' && e.pendingReport > 0 {
+				e.positionReport(params.String())
+				return e.lastAction
+			}
+			e.cursorKey(params.String(), r)
+			return actionOther
+		}
+		params.WriteRune(r)
+	}
+}
+
+// Act on a cursor or editing key; params carries modifiers like "1;5"
+func (e *lineEditor) cursorKey(params string, final rune) {
+	word := strings.HasSuffix(params, ";5") || strings.HasSuffix(params, ";3")
+
+	switch final {
+	case 'A':
+		e.historyMove(-1)
+	case 'B':
+		e.historyMove(1)
+	case 'C':
+		if word {
+			e.moveTo(e.wordRight())
+		} else {
+			e.moveTo(e.pos + 1)
+		}
+	case 'D':
+		if word {
+			e.moveTo(e.wordLeft())
+		} else {
+			e.moveTo(e.pos - 1)
+		}
+	case 'H':
+		e.moveTo(0)
+	case 'F':
+		e.moveTo(len(e.buf))
+	case '~':
+		switch strings.SplitN(params, ";", 2)[0] {
+		case "1", "7":
+			e.moveTo(0)
+		case "4", "8":
+			e.moveTo(len(e.buf))
+		case "3":
+			e.deleteRange(e.pos, e.pos+1)
+		}
+	}
+}
+
+// Record the column the line starts at from "row;col"
+func (e *lineEditor) positionReport(params string) {
+	e.pendingReport--
+	// Answers to requests for lines already finished are stale
+	if e.pendingReport > 0 || !e.anchored {
+		return
+	}
+
+	_, colStr, _ := strings.Cut(params, ";")
+	col, err := strconv.Atoi(colStr)
+	if err != nil || col < 1 {
+		return
+	}
+	e.anchorCol = col
+
+	// Text typed before the answer may have exactly filled a row
+	if e.cursorIndex == len(e.buf) {
+		e.settleAtEnd()
+	}
+}
+
+// Screen row (relative to the line start) and 0-based column of a buffer index
+func (e *lineEditor) place(index int) (int, int) {
+	if e.anchorCol == 0 {
+		return 0, index
+	}
+	offset := e.anchorCol - 1 + index
+	width := termWidth()
+	return offset / width, offset % width
+}
+
+// Move the terminal cursor to a buffer index
+func (e *lineEditor) moveCursor(to int) {
+	var b strings.Builder
+	from := e.cursorIndex
+
+	if e.anchorCol == 0 {
+		// Position unknown: assume the line fits on one row
+		if to < from {
+			fmt.Fprintf(&b, "\x1b[%dD", from-to)
+		} else if to > from {
+			fmt.Fprintf(&b, "\x1b[%dC", to-from)
+		}
+	} else {
+		fromRow, _ := e.place(from)
+		toRow, toCol := e.place(to)
+		if fromRow > toRow {
+			fmt.Fprintf(&b, "\x1b[%dA", fromRow-toRow)
+		} else if toRow > fromRow {
+			fmt.Fprintf(&b, "\x1b[%dB", toRow-fromRow)
+		}
+		fmt.Fprintf(&b, "\x1b[%dG", toCol+1)
+	}
+
+	fmt.Fprint(e.out, b.String())
+	e.cursorIndex = to
+}
+
+// After writing up to the end of the buffer, step onto the next row when
+// the text exactly fills the last one, so the cursor is where place() says
+func (e *lineEditor) settleAtEnd() {
+	if e.anchorCol == 0 || len(e.buf) == 0 {
+		return
+	}
+	if _, col := e.place(len(e.buf)); col == 0 {
+		fmt.Fprint(e.out, "\n")
+	}
+}
+
+// Redraw the whole line and put the cursor back at pos
+func (e *lineEditor) redraw() {
+	e.moveCursor(0)
+	fmt.Fprint(e.out, string(e.buf))
+	e.cursorIndex = len(e.buf)
+	e.settleAtEnd()
+	fmt.Fprint(e.out, "\x1b[J")
+	e.moveCursor(e.pos)
+}
+
+func (e *lineEditor) moveTo(pos int) {
+	e.pos = max(0, min(pos, len(e.buf)))
+	e.moveCursor(e.pos)
+}
+
+func (e *lineEditor) insert(text []rune) {
+	line := make([]rune, 0, len(e.buf)+len(text))
+	line = append(append(append(line, e.buf[:e.pos]...), text...), e.buf[e.pos:]...)
+	e.buf = line
+	e.pos += len(text)
+
+	// Typing at the end only needs the new text echoed
+	if e.pos == len(e.buf) && e.cursorIndex == e.pos-len(text) {
+		fmt.Fprint(e.out, string(text))
+		e.cursorIndex = e.pos
+		e.settleAtEnd()
+		return
+	}
+	e.redraw()
+}
+
+func (e *lineEditor) deleteRange(from, to int) {
+	from, to = max(0, from), min(to, len(e.buf))
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from:from], e.buf[to:]...)
+	e.pos = from
+	e.redraw()
+}
+
+// Delete text into the kill ring; consecutive kills extend one entry
+func (e *lineEditor) kill(from, to int) int {
+	from, to = max(0, from), min(to, len(e.buf))
+	if from >= to {
+		return e.lastAction
+	}
+
+	text := string(e.buf[from:to])
+	switch {
+	case e.lastAction == actionKill && len(e.killRing) > 0 && to == e.pos:
+		e.killRing[len(e.killRing)-1] = text + e.killRing[len(e.killRing)-1]
+	case e.lastAction == actionKill && len(e.killRing) > 0:
+		e.killRing[len(e.killRing)-1] += text
+	default:
+		e.killRing = append(e.killRing, text)
+		if len(e.killRing) > killRingSize {
+			e.killRing = e.killRing[1:]
+		}
+	}
+
+	e.deleteRange(from, to)
+	return actionKill
+}
+
+// Insert the most recent kill
+func (e *lineEditor) yank() int {
+	if len(e.killRing) == 0 {
+		return actionOther
+	}
+	e.yankIndex = len(e.killRing) - 1
+	e.yankStart = e.pos
+	text := []rune(e.killRing[e.yankIndex])
+	e.yankLen = len(text)
+	e.insert(text)
+	return actionYank
+}
+
+// Replace the text just yanked with the next older kill
+func (e *lineEditor) yankPop() int {
+	if e.lastAction != actionYank || len(e.killRing) < 2 {
+		return actionOther
+	}
+
+	e.yankIndex = (e.yankIndex - 1 + len(e.killRing)) % len(e.killRing)
+	text := []rune(e.killRing[e.yankIndex])
+
+	line := append([]rune{}, e.buf[:e.yankStart]...)
+	line = append(append(line, text...), e.buf[e.yankStart+e.yankLen:]...)
+	e.buf = line
+	e.yankLen = len(text)
+	e.pos = e.yankStart + e.yankLen
+	e.redraw()
+	return actionYank
+}
+
+// Swap the characters around the cursor, moving forward
+func (e *lineEditor) transpose() {
+	if len(e.buf) < 2 || e.pos == 0 {
+		return
+	}
+	if e.pos == len(e.buf) {
+		e.pos--
+	}
+	e.buf[e.pos-1], e.buf[e.pos] = e.buf[e.pos], e.buf[e.pos-1]
+	e.pos++
+	e.redraw()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Start of the word before the cursor (letters, digits, underscores)
+func (e *lineEditor) wordLeft() int {
+	i := e.pos
+	for i > 0 && !isWordRune(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// End of the word after the cursor
+func (e *lineEditor) wordRight() int {
+	i := e.pos
+	for i < len(e.buf) && !isWordRune(e.buf[i]) {
+		i++
+	}
+	for i < len(e.buf) && isWordRune(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// Start of the whitespace-delimited word before the cursor, as Ctrl-W in a shell
+func (e *lineEditor) spaceWordLeft() int {
+	i := e.pos
+	for i > 0 && unicode.IsSpace(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// Step through history; the line being typed is kept as a draft
+func (e *lineEditor) historyMove(delta int) {
+	index := e.historyIndex + delta
+	if index < 0 || index > len(e.history) {
+		return
+	}
+	if e.historyIndex == len(e.history) {
+		e.draft = append([]rune{}, e.buf...)
+	}
+
+	e.historyIndex = index
+	if index == len(e.history) {
+		e.buf = e.draft
+	} else {
+		e.buf = []rune(e.history[index])
+	}
+	e.pos = len(e.buf)
+	e.redraw()
+}
+
+// Submit the line and get ready for the next one
+func (e *lineEditor) finish() string {
+	e.moveCursor(len(e.buf))
+	fmt.Fprint(e.out, "\n")
+
+	line := string(e.buf)
+	// Single keys answer menus (y/n, approvals) and would crowd the history
+	if entry := strings.TrimSpace(line); len([]rune(entry)) > 1 && (len(e.history) == 0 || e.history[len(e.history)-1] != entry) {
+		e.history = append(e.history, entry)
+		if len(e.history) > historySize {
+			e.history = e.history[1:]
+		}
+	}
+
+	e.buf, e.pos, e.cursorIndex = nil, 0, 0
+	e.anchored = false
+	e.historyIndex = len(e.history)
+	e.draft = nil
+	return line
+}
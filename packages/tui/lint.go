@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Attachments at least this large need a question to go with them
+const lintPasteTokens = 1500
+
+// Tokens the server keeps free for the reply when fitting the history to the
+// model's context (MAX_REPLY_TOKENS in the server)
+const replyTokens = 4096
+
+// Words that make a sentence an instruction, and those that negate it
+var (
+	lintNegative   = regexp.MustCompile(`(?i)\b(never|don't|dont|do not|must not|mustn't|avoid|stop|no longer)\b`)
+	lintConstraint = regexp.MustCompile(`(?i)\b(always|never|don't|dont|do not|must|must not|mustn't|make sure|from now on|only ever|avoid|remember to)\b`)
+	lintWord       = regexp.MustCompile(`[a-z][a-z0-9_']+`)
+	lintSentence   = regexp.MustCompile(`[^.!?\n]+[.!?]*`)
+)
+
+// Words that ask for something when a message starts with them
+var lintRequestWords = []string{
+	"add", "analyze", "can", "change", "check", "compare", "convert", "could", "create", "debug",
+	"describe", "document", "does", "explain", "find", "fix", "help", "how", "implement", "improve",
+	"is", "list", "make", "optimize", "please", "refactor", "remove", "rename", "review", "rewrite",
+	"should", "show", "simplify", "summarize", "tell", "test", "translate", "update", "what", "when",
+	"where", "which", "who", "why", "would", "write",
+}
+
+// Words too common to tell two instructions apart
+var lintStopWords = []string{
+	"the", "and", "for", "with", "that", "this", "these", "those", "you", "your", "are", "was",
+	"will", "can", "not", "don't", "dont", "never", "always", "must", "avoid", "stop", "use",
+	"any", "all", "its", "it's", "from", "into", "when", "then", "than", "only", "ever", "make",
+	"sure", "unless", "please", "here", "there", "our", "also", "just", "more", "less", "some",
+	"longer", "now", "mustn't", "should", "would", "could", "have", "has", "been", "being",
+}
+
+// A problem found in a prompt, with what to do about it
+type LintFinding struct {
+	Problem string
+	Fix     string
+}
+
+// Read PROMPT_LINT: warn (the default) shows problems found before a message
+// is sent, ask also asks whether to send it, off skips the checks
+func promptLintConfig() (string, error) {
+	mode := strings.ToLower(getEnv("PROMPT_LINT", "warn"))
+	if mode != "warn" && mode != "ask" && mode != "off" {
+		return "", fmt.Errorf("invalid PROMPT_LINT %q (expected warn, ask, or off)", mode)
+	}
+	return mode, nil
+}
+
+// Content words of an instruction, with plurals and endings trimmed so
+// "logs" matches "log"
+func lintKeywords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range lintWord.FindAllString(strings.ToLower(text), -1) {
+		if len(word) < 3 || containsTag(lintStopWords, word) {
+			continue
+		}
+		for _, suffix := range []string{"ing", "ed", "es", "s"} {
+			if stem, ok := strings.CutSuffix(word, suffix); ok && len(stem) >= 3 {
+				word = stem
+				break
+			}
+		}
+		words[word] = true
+	}
+	return words
+}
+
+// Whether the text after an attachment asks for something
+func asksSomething(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.Contains(text, "?") {
+		return true
+	}
+	words := lintWord.FindAllString(strings.ToLower(text), -1)
+	if len(words) == 0 {
+		return false
+	}
+	if containsTag(lintRequestWords, words[0]) {
+		return true
+	}
+	// A longer sentence probably says what it wants somewhere
+	return len(words) >= 6
+}
+
+// A big attachment with nothing saying what to do with it
+func lintBigPaste(client *Client, input string, attachments []Attachment) *LintFinding {
+	tok := tokenizerForModel(client.config.Model)
+	pasted := 0
+	for _, attachment := range attachments {
+		pasted += tok.Count(attachment.Text)
+	}
+	if pasted < lintPasteTokens || asksSomething(input) {
+		return nil
+	}
+	problem := fmt.Sprintf("~%s tokens attached without a question", formatTokens(pasted))
+	if input != "" {
+		problem = fmt.Sprintf("~%s tokens attached, and %q doesn't say what to do with them", formatTokens(pasted), truncateWidth(input, 40))
+	}
+	return &LintFinding{
+		Problem: problem,
+		Fix:     "Ask for something specific (\"why does this test fail?\", \"summarize the errors\") so the model doesn't guess",
+	}
+}
+
+// Instructions in the message that say the opposite of one in the system
+// prompt; those with an "unless" already allow being overridden
+func lintConflicts(input, system string) []LintFinding {
+	var standing []string
+	for _, line := range strings.Split(system, "\n") {
+		for _, sentence := range lintSentence.FindAllString(line, -1) {
+			sentence = strings.TrimSpace(strings.TrimLeft(sentence, "-*• "))
+			if lintConstraint.MatchString(sentence) && !strings.Contains(strings.ToLower(sentence), "unless") {
+				standing = append(standing, sentence)
+			}
+		}
+	}
+
+	var findings []LintFinding
+	for _, sentence := range lintSentence.FindAllString(input, -1) {
+		sentence = strings.TrimSpace(sentence)
+		if sentence == "" || strings.HasSuffix(sentence, "?") {
+			continue
+		}
+		words := lintKeywords(sentence)
+		for _, rule := range standing {
+			if lintNegative.MatchString(rule) == lintNegative.MatchString(sentence) {
+				continue
+			}
+			ruleWords := lintKeywords(rule)
+			shared := 0
+			for word := range words {
+				if ruleWords[word] {
+					shared++
+				}
+			}
+			if shared >= 2 || (shared == 1 && (len(words) == 1 || len(ruleWords) == 1)) {
+				findings = append(findings, LintFinding{
+					Problem: fmt.Sprintf("%q goes against the standing instruction %q", sentence, rule),
+					Fix:     "Say that this message overrides it, or change it where it comes from (.painika/prompt.md, /memories, or the persona)",
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// Tokens the server trims the history with: the count this client sent
+// for the message, or the server's estimate of it
+func historyTokens(message Message) int {
+	if message.ContextTokens > 0 {
+		return message.ContextTokens
+	}
+	calls := "[]"
+	if message.ToolCalls != nil {
+		// As JSON.stringify writes them, without HTML escaping
+		var buf strings.Builder
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(message.ToolCalls)
+		calls = strings.TrimSuffix(buf.String(), "\n")
+	}
+	return estimateTokens(message.Content + calls)
+}
+
+// Index of the first message the server still sends once a message of
+// extra tokens is added, in the conversation without its system messages.
+// Mirrors the server: the newest turn always stays, older ones while they
+// fit the history window and the model's context.
+func historyStart(messages []Message, window HistoryWindow, room, extra int) int {
+	var starts []int
+	for i, message := range messages {
+		if message.Role == "user" {
+			starts = append(starts, i)
+		}
+	}
+	starts = append(starts, len(messages)) // The message about to be sent
+
+	budget := room
+	if window.Tokens > 0 {
+		budget = min(budget, window.Tokens)
+	}
+	start := 0
+	if window.Turns > 0 && len(starts) > window.Turns {
+		start = starts[len(starts)-window.Turns]
+	}
+	suffix := extra
+	budgetStart := starts[len(starts)-1]
+	for t := len(starts) - 2; t >= 0; t-- {
+		for _, message := range messages[starts[t]:starts[t+1]] {
+			suffix += historyTokens(message)
+		}
+		if suffix > budget {
+			break
+		}
+		budgetStart = starts[t]
+	}
+	return max(start, budgetStart)
+}
+
+// Constraints from earlier messages that this message pushes out of the
+// history the model sees
+func lintDroppedConstraints(client *Client, conversation *Conversation, input string, attachments []Attachment) []LintFinding {
+	var rest []Message
+	systemTokens := 0
+	for _, message := range conversation.Messages {
+		if message.Role == "system" {
+			systemTokens += historyTokens(message)
+		} else {
+			rest = append(rest, message)
+		}
+	}
+	last := -1
+	for i, message := range rest {
+		if message.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	room := 1 << 30
+	if capabilities, err := client.Capabilities(); err == nil {
+		room = capabilities.ContextTokens - replyTokens - systemTokens
+	}
+	// The message is counted with the tokenizer the client sends counts from
+	tok := tokenizerForModel(client.config.Model)
+	extra := tok.Count(input)
+	for _, attachment := range attachments {
+		extra += tok.Count(attachment.Text)
+	}
+	// What the model saw in the last turn, and what it will see in this one
+	lastTokens := 0
+	for _, message := range rest[last:] {
+		lastTokens += historyTokens(message)
+	}
+	before := historyStart(rest[:last], client.config.HistoryWindow, room, lastTokens)
+	after := historyStart(rest, client.config.HistoryWindow, room, extra)
+	if after <= before {
+		return nil
+	}
+
+	var findings []LintFinding
+	for _, message := range rest[before:after] {
+		if message.Role != "user" {
+			continue
+		}
+		for _, sentence := range lintSentence.FindAllString(message.Content, -1) {
+			sentence = strings.TrimSpace(sentence)
+			if lintConstraint.MatchString(sentence) && !strings.HasSuffix(sentence, "?") {
+				findings = append(findings, LintFinding{
+					Problem: fmt.Sprintf("%q, from an earlier message, falls out of the history the model sees with this message", truncateWidth(sentence, 80)),
+					Fix:     "Restate it in this message or widen the window (/set history_window); /remember keeps it for later sessions",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// Check a message before it is sent and show what could go wrong; returns
+// false if the user chose not to send it
+func lintPrompt(client *Client, input string, attachments []Attachment) bool {
+	mode := client.config.PromptLint
+	if mode == "off" {
+		return true
+	}
+
+	var findings []LintFinding
+	if finding := lintBigPaste(client, input, attachments); finding != nil {
+		findings = append(findings, *finding)
+	}
+	if conversation, err := client.GetConversation(); err == nil {
+		for _, message := range conversation.Messages {
+			if message.Role == "system" {
+				findings = append(findings, lintConflicts(input, message.Content)...)
+			}
+		}
+		findings = append(findings, lintDroppedConstraints(client, conversation, input, attachments)...)
+	}
+	if len(findings) == 0 {
+		return true
+	}
+
+	fmt.Println("🧐 Before this is sent:")
+	for _, finding := range findings {
+		fmt.Printf("   ⚠️  %s\n", finding.Problem)
+		fmt.Printf("      💡 %s\n", finding.Fix)
+	}
+	if mode != "ask" || !renderer.Interactive() {
+		return true
+	}
+
+	fmt.Print("   Send it anyway? [y/N] ")
+	answer, _ := stdin.ReadLine()
+	if strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes") {
+		return true
+	}
+	fmt.Println("🚫 Not sent; attachments stay for your next message")
+	fmt.Println()
+	return false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Format of `painika server` logs: "text" (emoji lines) or "json" (one
+// object per line with time and level, for journald, launchd, and log collectors)
+var serverLogFormat = "text"
+
+// Pick the log format from --log-format; without it, JSON when stdout is
+// not a terminal, as under a service manager
+func parseServerArgs(args []string) error {
+	format := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--log-format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--log-format needs text or json")
+			}
+			i++
+			format = args[i]
+		case strings.HasPrefix(args[i], "--log-format="):
+			format = strings.TrimPrefix(args[i], "--log-format=")
+		case args[i] == "--docker":
+			serverInDocker = true
+		case args[i] == "--native":
+			serverNative = true
+		default:
+			return fmt.Errorf("unknown argument: %s", args[i])
+		}
+	}
+	if serverInDocker && serverNative {
+		return fmt.Errorf("--native runs in this process and can't be combined with --docker")
+	}
+
+	switch strings.ToLower(format) {
+	case "":
+		if !isTerminal(os.Stdout) {
+			serverLogFormat = "json"
+		}
+	case "text", "json":
+		serverLogFormat = strings.ToLower(format)
+	default:
+		return fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+	return nil
+}
+
+// Write one server log line; warnings and errors go to stderr
+func logLine(level, icon, message string) {
+	out := os.Stdout
+	if level == "warn" || level == "error" {
+		out = os.Stderr
+	}
+	if serverLogFormat == "json" {
+		line, _ := json.Marshal(struct {
+			Time   string `json:"time"`
+			Level  string `json:"level"`
+			Msg    string `json:"msg"`
+			Source string `json:"source"`
+		}{time.Now().UTC().Format(time.RFC3339Nano), level, message, "painika"})
+		fmt.Fprintln(out, string(line))
+		return
+	}
+	fmt.Fprintf(out, "%s %s\n", icon, message)
+}
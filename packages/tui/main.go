@@ -5,8 +5,8 @@ import (
 	"bytes"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -29,99 +29,202 @@ var globalServerCmd *exec.Cmd
 
 // Configuration structure
 type Config struct {
-	ServerURL string
-	Token     string
-	Model     string
+	ServerURL     string
+	Provider      string // "groq" or "ollama"
+	BaseURL       string // OpenAI-compatible API base URL
+	Token         string
+	Model         string
+	Temperature   *float64 // Base sampling temperature (nil for the server default)
+	SystemContext string   // Extra context appended to the system prompt
+	GitCommits    int      // Recent commits in the git context (0 disables it)
+	FileAccess    FileAccessPolicy
+	HistoryWindow HistoryWindow
+	Approval      ApprovalPolicy
+	Idle          IdlePolicy
+	ProjectTools  []ProjectTool // Trusted tools from the project's .painika/tools.json
+	ToolLimits    map[string]ToolLimit
+	ResultPreview int           // Tool results over this many tokens are sent as a preview (0 never)
+	Shaping       ShapingPolicy // JSON mode, tool_choice, and parallel_tool_calls (request_shaping, /set)
+	Incognito     Incognito     // /incognito: messages saved without their content
+	ToolEnv       ToolEnv       // /env: variables for the tools' commands
+
+	MaxSessionCost      float64 // Hard spend cap in USD (0 for none)
+	AttachConfirmTokens int     // Attachments above this many tokens need confirmation (0 never asks)
+	PromptLint          string  // Checks before a message is sent: warn, ask, or off
+	FollowUps           bool    // Suggest next prompts after each reply
+	AutoContext         string  // Attach files a prompt mentions: ask, on, or off
+	Critic              *Config // Second model reviewing proposed changes, nil for none
+	CriticRevise        string  // Send the review back for a revision: off, ask, or on
+
+	Persona          string // Active persona ("" for the default)
+	PersonaBaseModel string // Model to restore when the persona's model override ends
+
+	VerifyCommand    string // Command run after the agent edits files ("" disables)
+	VerifyIterations int    // Corrective turns allowed when verification fails
 }
 
+// Protocol types (Message, Conversation, ChatResponse, ...) are generated
+// from packages/protocol/openapi.json into protocol_gen.go, and into
+// packages/server for the native server
+//go:generate go run ./tools/protogen -in ../protocol/openapi.json -out protocol_gen.go
+//go:generate go run ./tools/protogen -in ../protocol/openapi.json -out ../server/protocol_gen.go -package server
+
 // HTTP client wrapper
 type Client struct {
 	config Config
 	client *http.Client
+	counts tokenCounts // Tokenizer counts sent with the next message
 }
 
-// Message structure (matching TypeScript)
-type Message struct {
-	ID        string `json:"id"`
-	Role      string `json:"role"` // "user" or "assistant"
-	Content   string `json:"content"`
-	Timestamp string `json:"timestamp"` // ISO 8601 format
-}
-
-// Converation structure
-type Conversation struct {
-	ID          string    `json:"id"`
-	Messages    []Message `json:"messages"`
-	TotalTokens struct {
-		Input  int `json:"input"`
-		Output int `json:"output"`
-	} `json:"totalTokens"`
-	CreatedAt string `json:"createdAt"` // ISO 8601 format
-	UpdatedAt string `json:"updatedAt"` // ISO 8601 format
-}
-
-// Token usage structure
-type TokenUsage struct {
-	Input  int `json:"input"`
-	Output int `json:"output"`
-	Total  int `json:"total"`
+// Create a new client
+func NewClient(config Config) *Client {
+	return &Client{
+		config: config,
+		client: httpClient,
+	}
 }
 
-// Session response structure
-type SessionResponse struct {
-	Success   bool   `json:"success"`
-	SessionID string `json:"sessionId"`
-	Error     string `json:"error,omitempty"`
+func (c *Client) InitSession() error {
+	_, err := c.initSession(nil, false)
+	return err
 }
 
-// Chat response structure
-type ChatResponse struct {
-	Success  bool      `json:"success"`
-	Messages []Message `json:"messages"`
-	Error    string    `json:"error,omitempty"`
+// Start a new session that resumes a conversation from an earlier server
+func (c *Client) RestoreSession(conversation *Conversation) error {
+	_, err := c.initSession(conversation, false)
+	return err
 }
 
-// Create a new client
-func NewClient(config Config) *Client {
-	return &Client{
-		config: config,
-		client: &http.Client{},
+// Provider settings the server sends model requests with
+func providerConfig(config Config) map[string]interface{} {
+	groq := map[string]interface{}{
+		"token":    config.Token,
+		"model":    config.Model,
+		"baseURL":  config.BaseURL,
+		"provider": config.Provider,
 	}
+	if config.Temperature != nil {
+		groq["temperature"] = *config.Temperature
+	}
+	return groq
 }
 
-func (c *Client) InitSession() error {
+func (c *Client) initSession(restore *Conversation, attach bool) (bool, error) {
 	payload := map[string]interface{}{
-		"groq": map[string]string{
-			"token":   c.config.Token,
-			"model":   c.config.Model,
-			"baseURL": "https://api.groq.com/openai",
-		},
+		"groq": providerConfig(c.config),
+	}
+	if attach {
+		payload["attach"] = true
+	}
+	if c.config.SystemContext != "" {
+		payload["systemContext"] = c.config.SystemContext
+	}
+	if context := gitContext(c.config); context != "" {
+		payload["gitContext"] = context
+	}
+	payload["fileAccess"] = c.config.FileAccess
+	payload["historyWindow"] = c.config.HistoryWindow
+	payload["approval"] = c.config.Approval
+	if len(c.config.ProjectTools) > 0 {
+		payload["customTools"] = c.config.ProjectTools
+	}
+	if len(c.config.ToolLimits) > 0 {
+		payload["toolLimits"] = c.config.ToolLimits
+	}
+	payload["resultPreviewTokens"] = c.config.ResultPreview
+	payload["requestShaping"] = c.config.Shaping
+	if len(c.config.ToolEnv.Vars) > 0 {
+		payload["toolEnv"] = c.config.ToolEnv.Vars
+	}
+	if command := renameToolCommand(); command != "" && isLocalServer(c.config.ServerURL) {
+		payload["renameCommand"] = command
+	}
+	if restore != nil {
+		payload["restore"] = restore
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	resp, err := c.client.Post(c.config.ServerURL+"/session", "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer resp.Body.Close()
 
 	var result SessionResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
+		return false, err
 	}
 
 	if !result.Success {
-		return fmt.Errorf("failed to initialize session: %s", result.Error)
+		return false, fmt.Errorf("failed to initialize session: %s", result.Error)
 	}
 
-	return nil
+	c.counts.invalidate()
+	return result.Attached, nil
 }
 
 func (c *Client) SendMessage(content string) (*ChatResponse, error) {
+	return c.StreamMessage(content, nil)
+}
+
+// Send a message; with onText, the reply text is passed to onText as it is
+// generated. Servers that can't stream answer the whole turn at once.
+func (c *Client) StreamMessage(content string, onText func(string)) (*ChatResponse, error) {
+	if err := c.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	tok := tokenizerForModel(c.config.Model)
+	payload := map[string]interface{}{
+		"content":       content,
+		"contentTokens": tok.Count(content),
+	}
+	if onText != nil {
+		payload["stream"] = true
+	}
+	counted := c.pendingCounts(tok)
+	if len(counted) > 0 {
+		payload["contextTokens"] = counted
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Post(c.config.ServerURL+"/message", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, &ChatError{Kind: ErrUnreachable, Message: err.Error(), Err: err}
+	}
+	defer resp.Body.Close()
+
+	var result ChatResponse
+	if onText != nil && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		result, err = readChatStream(resp.Body, onText)
+		if err != nil {
+			return nil, &ChatError{Kind: ErrServer, Message: err.Error(), Err: err}
+		}
+	} else if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, &ChatError{Kind: ErrServer, Message: fmt.Sprintf("invalid server response: %v", err), Err: err}
+	}
+
+	if !result.Success {
+		return nil, classifyChatError(result.Error, result.ProviderStatus)
+	}
+
+	c.counts.sent(counted, tok, result.Messages)
+	return &result, nil
+}
+
+func (c *Client) PlanMessage(content string) (*Plan, error) {
+	if err := c.checkBudget(); err != nil {
+		return nil, err
+	}
+
 	payload := map[string]string{
 		"content": content,
 	}
@@ -131,22 +234,23 @@ func (c *Client) SendMessage(content string) (*ChatResponse, error) {
 		return nil, err
 	}
 
-	resp, err := c.client.Post(c.config.ServerURL+"/message", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.client.Post(c.config.ServerURL+"/plan", "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var result ChatResponse
+	var result PlanResponse
+
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
 	if !result.Success {
-		return nil, fmt.Errorf("failed to send message: %s", result.Error)
+		return nil, fmt.Errorf("failed to plan message: %s", result.Error)
 	}
 
-	return &result, nil
+	return result.Plan, nil
 }
 
 func (c *Client) GetConversation() (*Conversation, error) {
@@ -156,11 +260,7 @@ func (c *Client) GetConversation() (*Conversation, error) {
 	}
 	defer resp.Body.Close()
 
-	var result struct {
-		Success      bool          `json:"success"`
-		Conversation *Conversation `json:"conversation"`
-		Error        string        `json:"error,omitempty"`
-	}
+	var result ConversationResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
@@ -180,11 +280,7 @@ func (c *Client) GetTokenUsage() (*TokenUsage, error) {
 	}
 	defer resp.Body.Close()
 
-	var result struct {
-		Success bool        `json:"success"`
-		Usage   *TokenUsage `json:"usage"`
-		Error   string      `json:"error,omitempty"`
-	}
+	var result TokensResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
@@ -204,10 +300,7 @@ func (c *Client) ClearConversation() error {
 	}
 	defer resp.Body.Close()
 
-	var result struct {
-		Success bool   `json:"success"`
-		Error   string `json:"error,omitempty"`
-	}
+	var result StatusResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return err
@@ -220,6 +313,20 @@ func (c *Client) ClearConversation() error {
 	return nil
 }
 
+// Get the painika data directory (~/.painika), creating it if needed
+func painikaDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(homeDir, ".painika")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 // Get environment variables for configuration, checking shell config files
 func getEnv(key, defaultValue string) string {
 	// First check system environment
@@ -317,18 +424,110 @@ func main() {
 
 	// Check if running as server
 	if len(os.Args) > 1 && os.Args[1] == "server" {
+		if err := parseServerArgs(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			fmt.Println("Usage: painika server [--log-format text|json] [--docker | --native]")
+			exit(2)
+		}
+		if serverNative {
+			runNativeServer()
+			return
+		}
 		startServer()
 		return
 	}
 
+	// Check config files against the schema
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		runConfigCommand(os.Args[2:])
+		return
+	}
+
+	// List saved sessions
+	if len(os.Args) > 1 && os.Args[1] == "sessions" {
+		runSessionsCommand(os.Args[2:])
+		return
+	}
+
+	// Run a file of prompts in parallel
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		plainRedirectedOutput()
+		runBatch(os.Args[2:])
+		return
+	}
+
+	// Answer prompts from editors over named pipes or a socket
+	if len(os.Args) > 1 && os.Args[1] == "bridge" {
+		plainRedirectedOutput()
+		runBridge(os.Args[2:])
+		return
+	}
+
+	// Compare models on the same prompts
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		plainRedirectedOutput()
+		runBench(os.Args[2:])
+		return
+	}
+
+	// Review changes from git hooks
+	if len(os.Args) > 1 && os.Args[1] == "hooks" {
+		plainRedirectedOutput()
+		runHooksCommand(os.Args[2:])
+		return
+	}
+
+	// Fix one compiler or linter error
+	if len(os.Args) > 1 && os.Args[1] == "fix" {
+		plainRedirectedOutput()
+		runFix(os.Args[2:])
+		return
+	}
+
+	// Markdown digest of recent sessions
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		plainRedirectedOutput()
+		runReport(os.Args[2:])
+		return
+	}
+
+	// Rename a symbol across the workspace
+	if len(os.Args) > 1 && os.Args[1] == "rename" {
+		plainRedirectedOutput()
+		runRename(os.Args[2:])
+		return
+	}
+
+	// Open a file from a clicked link
+	if len(os.Args) > 1 && os.Args[1] == "open" {
+		plainRedirectedOutput()
+		runOpen(os.Args[2:])
+		return
+	}
+
 	// Check for help flag
 	if len(os.Args) > 1 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
 		printUsage()
 		return
 	}
 
-	// Default: run as TUI client
-	runTUI()
+	// Default: run as TUI client, optionally starting with a task
+	plainRedirectedOutput()
+	startup, err := parseStartupArgs(os.Args[1:])
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		printUsage()
+		exit(2)
+	}
+	if startup.Schema != "" {
+		redirectChrome()
+		runContract(startup)
+		return
+	}
+	if startup.PrintOnExit != "" {
+		redirectChrome()
+	}
+	runTUI(startup)
 }
 
 func printUsage() {
@@ -336,56 +535,263 @@ func printUsage() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  painika          Start the TUI client (default)")
-	fmt.Println("  painika server   Start the backend server")
+	fmt.Println("  painika \"<prompt>\" [--file <path>]...")
+	fmt.Println("                   Start with a first message and attached files")
+	fmt.Println("  painika --print-on-exit[=n]")
+	fmt.Println("                   Write the last (or nth) message to stdout on exit; everything else goes to stderr")
+	fmt.Println("  painika \"<prompt>\" --schema <schema.json> [--schema-retries <n>]")
+	fmt.Println("                   Answer once with JSON matching the schema, asking for corrections up to n times (default 2); exits 1 if none matches")
+	fmt.Println("  painika --offline")
+	fmt.Println("                   Refuse connections to all but this machine and OFFLINE_ALLOW hosts, and confirm bash and project tools; needs PROVIDER=ollama")
+	fmt.Println("  painika --attach")
+	fmt.Println("                   Join the session open on SERVER_URL; every client sees the others' turns")
+	fmt.Println("  painika --pprof[=addr]")
+	fmt.Println("                   Serve Go pprof profiles (default: localhost:6060) to diagnose memory growth")
+	fmt.Println("  painika batch [file|-] [--concurrency n] [--coalesce]")
+	fmt.Println("                   Run one prompt per line in parallel; writes a JSON line per result")
+	fmt.Println("  painika bridge [--fifo <dir> | --socket <path>]")
+	fmt.Println("                   Answer prompts written to named pipes (default: ~/.painika/bridge) or a socket")
+	fmt.Println("  painika bench --models a,b,c --prompt-file p.txt [--grade]")
+	fmt.Println("                   Run the prompts on each model at once and compare latency, speed, and cost")
+	fmt.Println("  painika sessions list [--tag <tag>]...")
+	fmt.Println("                   List saved sessions, optionally only those with all the tags")
+	fmt.Println("  painika sessions merge <a> <b> -o <c> [--concat]")
+	fmt.Println("                   Combine two sessions (IDs or session files) into session c, by time or one after the other")
+	fmt.Println("  painika sessions push | pull")
+	fmt.Println("                   Sync encrypted sessions and memories with the backend set under sync in the config")
+	fmt.Println("  painika sessions log <id>")
+	fmt.Println("                   List the changes saved to a session, one event per message or compaction")
+	fmt.Println("  painika report [--since 7d|2w|24h|2006-01-02] [--output file]")
+	fmt.Println("                   Markdown digest of saved sessions: files changed, tests fixed, top repositories, cost")
+	fmt.Println("  painika --resume <id>[@<event>]")
+	fmt.Println("                   Continue a saved session, e.g. one pulled from another machine, or as it was after an event")
+	fmt.Println("  painika hooks install [--pre-push] [--force]")
+	fmt.Println("                   Review staged changes (or commits being pushed) before git accepts them")
+	fmt.Println("  painika hooks uninstall [--pre-push]")
+	fmt.Println("                   Remove the hook painika installed")
+	fmt.Println("  painika fix <file>:<line>[:col] \"<error>\" [--apply | --diff]")
+	fmt.Println("                   Ask for the smallest fix for a compiler or linter error and apply it once confirmed")
+	fmt.Println("  painika rename <old> <new> [path...] [--apply]")
+	fmt.Println("                   Preview renaming a symbol everywhere (gopls or gofmt -r for Go, ripgrep elsewhere), then apply it")
+	fmt.Println("  painika open <path[:line]> | --register")
+	fmt.Println("                   Open a file in $EDITOR; --register makes painika:// links from replies open this way")
+	fmt.Println("  painika config validate [file...]")
+	fmt.Println("                   Check config files against the schema (default: global and project)")
+	fmt.Println("  painika config edit [file]")
+	fmt.Println("                   Edit a config file (default: global) in $EDITOR with every setting described; saved once valid")
+	fmt.Println("  painika server [--log-format text|json] [--docker | --native]")
+	fmt.Println("                   Start the backend server (JSON logs by default when not on a terminal);")
+	fmt.Println("                   --native serves the built-in Go server, which has no tools yet, instead of the bundle")
 	fmt.Println("  painika --help   Show this help message")
 	fmt.Println()
 	fmt.Println("Environment Variables:")
-	fmt.Println("  GROQ_API_KEY        Your Groq API key (required)")
+	fmt.Println("  GROQ_API_KEY        Your Groq API key (required), or a reference: op://..., vault:<path>#<field>, exec:<command>")
 	fmt.Println("  MODEL               AI model to use (default: llama-3.3-70b-versatile)")
-	fmt.Println("  SERVER_URL          Server URL (default: http://localhost:3000)")
+	fmt.Println("  PROVIDER            Model provider: groq or ollama (default: groq)")
+	fmt.Println("  OLLAMA_HOST         Ollama URL (default: http://localhost:11434)")
+	fmt.Println("  VERIFY_COMMAND      Command run after the AI edits files, e.g. \"go test ./...\"")
+	fmt.Println("  VERIFY_ITERATIONS   Corrective turns when verification fails (default: 1)")
+	fmt.Println("  WORKSPACE_ROOT      Directory the file tools are confined to (default: current)")
+	fmt.Println("  FOLLOW_SYMLINKS     Symlink policy: never, workspace, always (default: workspace)")
+	fmt.Println("  PROTECTED_PATHS     Extra comma-separated globs the AI may not write")
+	fmt.Println("  ALLOW_PROTECTED_WRITES  Comma-separated globs exempt from write protection")
+	fmt.Println("  HISTORY_WINDOW      Prior conversation sent per request, e.g. 20 or \"8000 tokens\"")
+	fmt.Println("  OTEL_EXPORTER_OTLP_ENDPOINT  Export OpenTelemetry traces via OTLP/HTTP")
+	fmt.Println("  SERVER_URL          Server URL, or unix:///path/to/socket (default: http://localhost:3000)")
+	fmt.Println("  BATCH_CONCURRENCY   Parallel prompts in batch mode (default: 4)")
+	fmt.Println("  ATTACH_CONFIRM_TOKENS  Ask before sending attachments above this many tokens (default: 8000, 0 never)")
+	fmt.Println("  RESULT_PREVIEW_TOKENS  Send tool results above this many tokens as a preview the AI expands (default: 2000, 0 never)")
+	fmt.Println("  PROMPT_LINT         Flag likely prompt mistakes before sending: warn, ask, or off (default: warn)")
+	fmt.Println("  FOLLOW_UPS          Suggest next prompts after each reply, picked by number: on or off (default: off)")
+	fmt.Println("  AUTO_CONTEXT        Offer files a message mentions as attachments: ask, on (attach), or off (default: ask)")
+	fmt.Println("  CRITIC_MODEL        Second model that reviews proposed changes, e.g. ollama:qwen2.5-coder (default: none)")
+	fmt.Println("  CRITIC_REVISE       Send the critic's review back for one revision: off, ask, or on (default: off)")
+	fmt.Println("  OFFLINE_ALLOW       Hosts --offline may reach besides this machine, e.g. a model server on your network")
+	fmt.Println("  HYPERLINKS          Clickable file paths and URLs in replies: auto, on, or off (default: auto)")
+	fmt.Println("  EDITOR_COMMAND      Editor for /open and links, e.g. \"code -g {path}:{line}\" (default: $VISUAL or $EDITOR)")
+	fmt.Println("  LINK_HANDLER        What opens a clicked file: file (the OS) or editor ($EDITOR via painika open)")
+	fmt.Println("  LINE_EDITING        Set to off to read plain lines instead of using the line editor")
+	fmt.Println("  RESOURCE_MONITOR    Seconds between samples of the server's CPU and memory shown before the prompt, or off (default: 10)")
+	fmt.Println("  STREAMING           Set to off to show each reply once it is complete instead of as it streams")
+	fmt.Println("  BANNER              Welcome banner: full, minimal (one plain line), or off, which also hides startup progress (default: full)")
+	fmt.Println("  GREETING            Message shown instead of the banner; {model}, {provider}, and {server} are filled in")
+	fmt.Println("  GREETING_COMMAND    Command whose output is shown instead of the banner (PAINIKA_MODEL, PAINIKA_PROVIDER, PAINIKA_SERVER set)")
+	fmt.Println("  GIT_CONTEXT_COMMITS Recent commits shared with the AI with branch and changes (default: 5, off)")
+	fmt.Println("  GUARDED_COMMANDS    Extra destructive command patterns that must be typed back to run")
+	fmt.Println("  GIST_TOKEN          GitHub token with the gist scope for /gist (default: GITHUB_TOKEN)")
+	fmt.Println("  REDACT_PATTERNS     Extra comma-separated regexes redacted before /gist uploads")
+	fmt.Println("  MAX_LINES_PER_TURN  Ask before a turn changes more lines than this (default: 500, 0 off)")
+	fmt.Println("  MAX_FILES_PER_TURN  Ask before a turn changes more files than this (default: 20, 0 off)")
+	fmt.Println("  SERVER_SOCKET       painika server: listen on this Unix domain socket instead of a port")
+	fmt.Println("  SERVER_READY_FILE   painika server: write {port|socket, pid, protocolVersion} as JSON here once listening")
+	fmt.Println("  SERVER_RUNTIME      Runtime for the server: bun, node, deno, or auto (default: auto, Bun first)")
+	fmt.Println("  SERVER_DOCKER_IMAGE painika server --docker: image the server runs in (default: oven/bun:1)")
+	fmt.Println("  SERVER_TOKEN        Token for requests to the server (default: new each run, or ~/.painika/server-token with SERVER_URL)")
+	fmt.Println("  CLIENT_NAME         Name others see when sharing a session with --attach (default: user@host)")
 	fmt.Println()
 }
 
 func startServer() {
-	fmt.Println("🚀 Starting Code Agent server...")
+	logLine("info", "🚀", "Starting Code Agent server...")
 
-	// Create a temporary file for the server bundle
-	tempFile, err := ioutil.TempFile("", "server-*.js")
+	// Extract the server bundle (reused if already cached)
+	bundlePath, err := extractServerBundle()
 	if err != nil {
-		log.Fatalf("❌ Failed to create temporary file: %v", err)
-	}
-	tempFileName := tempFile.Name()
-
-	// Write the embedded server bundle to the temporary file
-	if _, err := tempFile.WriteString(serverBundle); err != nil {
-		tempFile.Close()
-		os.Remove(tempFileName)
-		log.Fatalf("❌ Failed to write server bundle: %v", err)
+		logLine("error", "❌", err.Error())
+		exit(1)
 	}
-	tempFile.Close()
 
-	// Clean up temp file when server exits
-	defer os.Remove(tempFileName)
+	logLine("info", "📦", "Server bundle: "+bundlePath)
 
-	fmt.Printf("📦 Server bundle extracted to: %s\n", tempFileName)
+	// Clients on this machine pick the token up from the token file
+	if path, err := saveServerToken(serverToken()); err != nil {
+		logLine("warn", "⚠️ ", fmt.Sprintf("Could not save the server token: %v", err))
+	} else {
+		logLine("info", "🔑", fmt.Sprintf("Server token saved to %s (clients elsewhere set SERVER_TOKEN)", path))
+	}
 
-	// Start the Bun server
-	cmd := exec.Command("bun", "run", tempFileName)
+	// Start the server with Bun, or Node.js or Deno when Bun is missing,
+	// or in a container with --docker
+	env := append(serverEnv(), "LOG_FORMAT="+serverLogFormat)
+	var cmd *exec.Cmd
+	if serverInDocker {
+		cmd, err = dockerServerCommand(bundlePath, env)
+	} else {
+		cmd, err = serverCommand(bundlePath)
+	}
+	if err != nil {
+		logLine("error", "❌", err.Error())
+		exit(1)
+	}
+	if serverInDocker {
+		logLine("info", "🐳", "Running in Docker ("+getEnv("SERVER_DOCKER_IMAGE", defaultServerImage)+")")
+	} else {
+		if js, _ := findServerRuntime(); js.Name != "bun" {
+			logLine("info", "⚙️ ", fmt.Sprintf("Running on %s %s", js.Name, js.Version))
+		}
+		cmd.Env = env
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
 
 	if err := cmd.Run(); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+		logLine("error", "❌", fmt.Sprintf("Failed to start server: %v", err))
+		exit(1)
 	}
 }
 
-func runTUI() {
-	// Load configuration from environment variables
+// Load configuration from the environment, exiting with an explanation if it is invalid
+func loadConfig() Config {
+	// Malformed config files stop startup rather than silently falling back to defaults
+	user, err := loadUserConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("💡 Check your config files with: painika config validate")
+		exit(1)
+	}
+
+	// Load configuration from environment variables, then the config files
 	config := Config{
-		ServerURL: getEnv("SERVER_URL", "http://localhost:3000"),
-		Token:     getEnv("GROQ_API_KEY", ""),
-		Model:     getEnv("MODEL", "llama-3.3-70b-versatile"),
+		ServerURL:   resolveServerURL(getEnv("SERVER_URL", "http://localhost:3000")),
+		Provider:    strings.ToLower(getEnv("PROVIDER", "groq")),
+		BaseURL:     "https://api.groq.com/openai",
+		Model:       getEnv("MODEL", user.Model),
+		Temperature: user.Temperature,
+	}
+	if config.Provider != "ollama" {
+		if config.Token, err = getSecret("GROQ_API_KEY"); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+	}
+
+	// Local models need no API key, just a running Ollama
+	if config.Provider == "ollama" {
+		if err := configureOllama(&config); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			fmt.Println("💡 Install Ollama from https://ollama.com and run: ollama pull llama3.1")
+			exit(1)
+		}
+	} else if config.Provider != "groq" {
+		fmt.Printf("❌ Unknown PROVIDER %q (expected \"groq\" or \"ollama\")\n", config.Provider)
+		exit(1)
+	}
+
+	if config.Model == "" {
+		config.Model = "llama-3.3-70b-versatile"
+	}
+
+	config.VerifyCommand, config.VerifyIterations = verifyConfig()
+
+	fileAccess, err := fileAccessConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	config.FileAccess = fileAccess
+	config.FileAccess.ProtectedPaths = append(config.FileAccess.ProtectedPaths, user.ProtectedPaths...)
+
+	historyWindow, err := parseHistoryWindow(getEnv("HISTORY_WINDOW", user.HistoryWindow))
+	if err != nil {
+		fmt.Printf("❌ HISTORY_WINDOW: %v\n", err)
+		exit(1)
+	}
+	config.HistoryWindow = historyWindow
+
+	approval, err := approvalConfig(user)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	config.Approval = approval
+	config.ToolLimits = toolLimitsConfig(user)
+	if config.ResultPreview, err = resultPreviewConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if config.Shaping.Models, err = requestShapingConfig(user); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.MaxSessionCost, err = budgetConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.AttachConfirmTokens, err = attachConfirmConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.PromptLint, err = promptLintConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.FollowUps, err = followUpsConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.AutoContext, err = autoContextConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.Critic, config.CriticRevise, err = criticConfig(config); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.GitCommits, err = gitContextConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	if config.Idle, err = idleConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
 	}
 
 	// Validate configuration
@@ -401,89 +807,219 @@ func runTUI() {
 		}
 		fmt.Println()
 		fmt.Println("Get your API key from: https://console.groq.com/keys")
-		os.Exit(1)
+		exit(1)
+	}
+
+	return config
+}
+
+func runTUI(startup StartupArgs) {
+	var err error
+	if renderer, err = rendererConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if bannerPolicy, err = startupPolicyConfig(); err != nil {
+		renderer.Error("Invalid startup settings", err)
+		exit(1)
+	}
+	if startup.Offline {
+		if err := enableOfflineMode(); err != nil {
+			renderer.Error("Offline mode", err)
+			exit(1)
+		}
+		if len(offlineAllow) > 0 {
+			renderer.Notice(fmt.Sprintf("✈️  Offline: only this machine and %s can be reached", strings.Join(offlineAllow, ", ")))
+		} else {
+			renderer.Notice("✈️  Offline: only this machine can be reached")
+		}
+	}
+	config := loadConfig()
+
+	if hyperlinks, err = hyperlinkConfig(config.FileAccess.Root); err != nil {
+		renderer.Error("Invalid hyperlink settings", err)
+		exit(1)
 	}
 
+	// Prepare the session context while the server starts
+	contextReady := make(chan string, 1)
+	go func() {
+		contextReady <- sessionContext()
+	}()
+
 	// Set up signal handling for cleanup
 	setupCleanupHandlers()
 
-	// Create client
-	client := NewClient(config)
+	if startup.Pprof != "" {
+		if err := startPprof(startup.Pprof); err != nil {
+			renderer.Error("Failed to start pprof", err)
+			exit(1)
+		}
+		renderer.Notice(fmt.Sprintf("🩺 pprof on http://%s/debug/pprof/", pprofAddr))
+	}
 
-	// Check if server is running, if not start it automatically
-	if !isServerRunning(config.ServerURL) {
-		fmt.Println("🔄 Server not running, starting automatically...")
-		
-		// Start server in background and get the actual port
-		actualPort, serverCmd, err := startServerInBackgroundWithPort()
+	// Track the terminal size for wrapping output
+	initLayout()
+
+	// Hash the workspace so changes can be summarized on quit
+	sessionSnapshot = takeSnapshot(config.FileAccess.Root)
+
+	// Check if a compatible server is running, if not start one automatically
+	health, running := serverHealth(config.ServerURL)
+	if running && health.ProtocolVersion != protocolVersion {
+		renderer.Notice("⚠️  " + staleServerMessage(config.ServerURL, health))
+		if getEnv("SERVER_URL", "") != "" {
+			renderer.Notice("💡 Restart that server with this build (painika server) or unset SERVER_URL")
+			exit(1)
+		}
+		startupNotice("🔄 Starting a compatible server...")
+		running = false
+	} else if running && !health.Authorized {
+		// Another run's server, or one started with a different token
+		renderer.Notice("⚠️  " + unauthorizedServerMessage(config.ServerURL))
+		if getEnv("SERVER_URL", "") != "" {
+			renderer.Notice("💡 Set SERVER_TOKEN to the server's token (saved in ~/.painika/server-token by painika server)")
+			exit(1)
+		}
+		startupNotice("🔄 Starting a server of our own...")
+		running = false
+	} else if !running {
+		if startup.Attach {
+			renderer.Notice(fmt.Sprintf("❌ No server at %s to attach to", displayServerURL(config.ServerURL)))
+			renderer.Notice("💡 Start a shared one with: painika server, then set SERVER_URL")
+			exit(1)
+		}
+		startupNotice("🔄 Server not running, starting automatically...")
+	}
+
+	if !running {
+		// Start server in background and use its actual port
+		serverURL, err := launchServer(socketPath(config.ServerURL))
 		if err != nil {
-			fmt.Printf("❌ Failed to start server: %v\n", err)
-			fmt.Println("💡 Try starting the server manually with: painika server")
-			os.Exit(1)
+			renderer.Error("Failed to start server", err)
+			renderer.Notice("💡 Try starting the server manually with: painika server")
+			exit(1)
 		}
+		config.ServerURL = serverURL
+	}
 
-		// Store server process globally for cleanup
-		globalServerCmd = serverCmd
+	// Carry project instructions and remembered facts into the new session
+	config.SystemContext = <-contextReady
 
-		// Update config to use actual server port
-		config.ServerURL = fmt.Sprintf("http://localhost:%d", actualPort)
+	// Create client once the server URL is final
+	client := NewClient(config)
 
-		// Wait for server to be ready
-		fmt.Print("⏳ Waiting for server to start")
-		for i := 0; i < 30; i++ { // Wait up to 15 seconds
-			if isServerRunning(config.ServerURL) {
-				fmt.Println(" ✅")
-				break
-			}
-			fmt.Print(".")
-			time.Sleep(500 * time.Millisecond)
-			
-			if i == 29 {
-				fmt.Println(" ❌")
-				fmt.Println("❌ Server failed to start within 15 seconds")
-				if serverCmd != nil && serverCmd.Process != nil {
-					serverCmd.Process.Kill()
-				}
-				os.Exit(1)
-			}
-		}
+	// Initialize session, or join the one others are using
+	startupNotice("🚀 Initializing AI session...")
+	attached := false
+	if startup.Attach {
+		attached, err = client.JoinSession()
+	} else {
+		err = client.InitSession()
+	}
+	if err != nil {
+		renderer.Error("Failed to initialize session", err)
+		exit(1)
+	}
+	if attached {
+		renderer.Notice(fmt.Sprintf("👥 Joined the open session as %s; turns run one at a time", clientName()))
+	} else {
+		applyStartupPersona(client)
 	}
 
-	// Initialize session
-	fmt.Println("🚀 Initializing AI session...")
-	if err := client.InitSession(); err != nil {
-		log.Fatalf("❌ Failed to initialize session: %v", err)
+	// Others may be using a server this run didn't start
+	if getEnv("SERVER_URL", "") != "" && renderer.Interactive() {
+		watchSharedSession(client)
+	}
+	if startup.Resume != "" {
+		if err := resumeSession(client, startup.Resume); err != nil {
+			renderer.Error("Failed to resume session", err)
+			exit(1)
+		}
 	}
 
-	// Welcome message
-	fmt.Println("🤖 Code Agent initialized successfully!")
-	fmt.Printf("   Model: %s\n", config.Model)
-	fmt.Printf("   Server: %s\n", config.ServerURL)
-	fmt.Println()
-	fmt.Println("💡 Type 'help' for commands, 'quit' to exit")
-	fmt.Println("📝 Start chatting with the AI...")
-	fmt.Println()
+	showBanner(BannerInfo{Model: config.Model, Provider: config.Provider, Server: displayServerURL(config.ServerURL)})
+	printCapabilityNotice(client)
+
+	// Interactive loop; piped input becomes an attachment when prompts can
+	// come from the terminal
+	promptInput, piped := pipedStdin()
+	preloadSecrets()
+	stdin = newLineReader(promptInput)
+	if piped != nil && preflightAttachments(client, []Attachment{*piped}) {
+		pendingAttachments = append(pendingAttachments, *piped)
+		fmt.Printf("📎 %s attached to your next message\n\n", strings.TrimSuffix(strings.SplitN(piped.Text, "\n", 2)[0], ":"))
+	}
 
-	// Interactive loop
-	scanner := bufio.NewScanner(os.Stdin)
+	// Offer the project's own tools once their commands are trusted
+	setupProjectTools(client)
 
-	for {
-		fmt.Print("💬 > ")
+	// Pick up a turn a crashed run left unfinished
+	if !attached && startup.Resume == "" {
+		offerInterruptedTurn(client)
+	}
 
-		if !scanner.Scan() {
-			break
+	// Send the task from the command line as the first message
+	if startup.Message != "" {
+		fmt.Printf("💬 > %s", truncateWidth(startup.Prompt, termWidth()-6))
+		for _, path := range startup.Files {
+			fmt.Printf(" 📎 %s", path)
+		}
+		fmt.Println()
+		if preflightAttachments(client, startup.Attachments) {
+			if lintPrompt(client, startup.Prompt, append(append([]Attachment{}, pendingAttachments...), startup.Attachments...)) {
+				handleMessage(client, withPendingAttachments(startup.Message))
+			} else {
+				pendingAttachments = append(pendingAttachments, startup.Attachments...)
+			}
 		}
+	}
 
-		input := strings.TrimSpace(scanner.Text())
+	// Apply edits to the config files between prompts
+	configWatch = newConfigWatcher()
+
+	// Sample the server's CPU and memory for the indicator before the prompt
+	if interval, err := resourceMonitorConfig(); err != nil {
+		renderer.Error("Invalid resource monitor settings", err)
+		exit(1)
+	} else if interval > 0 {
+		monitor = startResourceMonitor(interval)
+	}
+
+	for {
+		configWatch.Check(client)
+		showPrompt()
+
+		input, ok, idle := stdin.ReadLineIdle(client.config.Idle.Timeout)
+		atPrompt.Store(false)
+		if idle {
+			if lockSession(client) {
+				continue
+			}
+			// Input ended while locked
+			input = "quit"
+		} else if !ok {
+			// End of input ends the session like quit
+			input = "quit"
+		}
 
 		if input == "" {
 			continue
 		}
 
+		// A number right after a reply picks one of its follow-ups
+		input = pickFollowUp(input)
+
+		// The files may have changed while the prompt was waiting
+		configWatch.Check(client)
+
 		// Handle special commands
 		switch strings.ToLower(input) {
 		case "quit", "exit", "q":
+			summarizeSession()
+			syncOnQuit()
 			fmt.Println("👋 Goodbye!")
+			writeExitOutput(client, startup.PrintOnExit)
 			cleanupAndExit()
 			return
 		case "help", "h":
@@ -492,139 +1028,44 @@ func runTUI() {
 			showTokenUsage(client)
 		case "history", "hist":
 			showConversationHistory(client)
+		case "history full", "hist full":
+			showFullHistory(client)
 		case "clear", "c":
 			clearScreen()
 		case "reset", "r":
 			resetConversation(client)
 		default:
-			// Send message to AI
-			handleMessage(client, input)
+			if strings.HasPrefix(input, "/") {
+				handleCommand(client, input)
+				continue
+			}
+
+			// Send message to AI, with anything attached since the last one
+			offerAutoContext(client, input)
+			if lintPrompt(client, input, pendingAttachments) {
+				handleMessage(client, withPendingAttachments(input))
+			}
 		}
 	}
 }
 
-func startServerInBackground() (*exec.Cmd, error) {
-	// Create a temporary file for the server bundle
-	tempFile, err := ioutil.TempFile("", "server-*.js")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary file: %v", err)
-	}
-	tempFileName := tempFile.Name()
-
-	// Write the embedded server bundle to the temporary file
-	if _, err := tempFile.WriteString(serverBundle); err != nil {
-		tempFile.Close()
-		os.Remove(tempFileName)
-		return nil, fmt.Errorf("failed to write server bundle: %v", err)
-	}
-	tempFile.Close()
-
-	// Start the Bun server in background
-	cmd := exec.Command("bun", "run", tempFileName)
-	cmd.Env = os.Environ()
-
-	// Start the process without waiting
-	if err := cmd.Start(); err != nil {
-		os.Remove(tempFileName)
-		return nil, fmt.Errorf("failed to start server: %v", err)
-	}
-
-	// Clean up temp file when process exits (in a goroutine)
-	go func() {
-		cmd.Wait() // Wait for process to finish
-		os.Remove(tempFileName)
-	}()
-
-	return cmd, nil
-}
-
+// Start the embedded server on a free port; returns the port it took
 func startServerInBackgroundWithPort() (int, *exec.Cmd, error) {
-	// Create a temporary file for the server bundle
-	tempFile, err := ioutil.TempFile("", "server-*.js")
-	if err != nil {
-		return 0, nil, fmt.Errorf("failed to create temporary file: %v", err)
-	}
-	tempFileName := tempFile.Name()
-
-	// Write the embedded server bundle to the temporary file
-	if _, err := tempFile.WriteString(serverBundle); err != nil {
-		tempFile.Close()
-		os.Remove(tempFileName)
-		return 0, nil, fmt.Errorf("failed to write server bundle: %v", err)
-	}
-	tempFile.Close()
-
-	// Start the Bun server in background and capture output
-	cmd := exec.Command("bun", "run", tempFileName)
-	cmd.Env = os.Environ()
-	
-	// Capture stdout to parse the port
-	stdout, err := cmd.StdoutPipe()
+	cmd, ready, err := startEmbeddedServer()
 	if err != nil {
-		os.Remove(tempFileName)
-		return 0, nil, fmt.Errorf("failed to create stdout pipe: %v", err)
-	}
-
-	// Start the process
-	if err := cmd.Start(); err != nil {
-		os.Remove(tempFileName)
-		return 0, nil, fmt.Errorf("failed to start server: %v", err)
-	}
-
-	// Read server output to get the actual port
-	portChan := make(chan int, 1)
-	errorChan := make(chan error, 1)
-	
-	go func() {
-		defer stdout.Close()
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			// Look for line like "🚀 Code Agent server starting on port 3001"
-			if strings.Contains(line, "server starting on port") {
-				parts := strings.Split(line, "port ")
-				if len(parts) >= 2 {
-					portStr := strings.TrimSpace(parts[1])
-					if port, err := fmt.Sscanf(portStr, "%d", new(int)); err == nil && port == 1 {
-						var actualPort int
-						fmt.Sscanf(portStr, "%d", &actualPort)
-						portChan <- actualPort
-						return
-					}
-				}
-			}
-		}
-		errorChan <- fmt.Errorf("could not parse server port from output")
-	}()
-
-	// Wait for port or timeout
-	select {
-	case port := <-portChan:
-		// Clean up temp file when process exits (in a goroutine)
-		go func() {
-			cmd.Wait() // Wait for process to finish
-			os.Remove(tempFileName)
-		}()
-		return port, cmd, nil
-	case err := <-errorChan:
-		cmd.Process.Kill()
-		os.Remove(tempFileName)
 		return 0, nil, err
-	case <-time.After(10 * time.Second):
+	}
+	if ready.Port == 0 {
 		cmd.Process.Kill()
-		os.Remove(tempFileName)
-		return 0, nil, fmt.Errorf("timeout waiting for server to start")
+		cmd.Wait()
+		return 0, nil, fmt.Errorf("server did not report the port it listens on")
 	}
+	return ready.Port, cmd, nil
 }
 
 func isServerRunning(serverURL string) bool {
-	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get(serverURL + "/health")
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == 200
+	_, ok := serverHealth(serverURL)
+	return ok
 }
 
 // Setup signal handlers for graceful cleanup
@@ -641,20 +1082,18 @@ func setupCleanupHandlers() {
 
 // Cleanup server and exit
 func cleanupAndExit() {
+	flushTraces()
+	stopBatchServers()
 	if globalServerCmd != nil && globalServerCmd.Process != nil {
 		fmt.Println("🧹 Stopping server...")
-		globalServerCmd.Process.Kill()
-		globalServerCmd.Wait() // Wait for process to finish
+		stopServer()
 		fmt.Println("✅ Server stopped")
 	}
-	os.Exit(0)
+	exit(0)
 }
 
-// Handle regular chat message
-func handleMessage(client *Client, input string) {
-	fmt.Print("🤖 ")
-
-	// Show thinking indicator
+// Show a thinking indicator until the returned stop function is called
+func showThinking() func() {
 	done := make(chan bool)
 	go func() {
 		ticker := time.NewTicker(500 * time.Millisecond)
@@ -665,27 +1104,119 @@ func handleMessage(client *Client, input string) {
 			case <-done:
 				return
 			case <-ticker.C:
-				fmt.Print(".")
+				if !promptActive.Load() {
+					fmt.Print(".")
+				}
 			}
 		}
 	}()
 
+	return func() { done <- true }
+}
+
+// Handle regular chat message
+func handleMessage(client *Client, input string) {
+	response := sendAndShow(client, input)
+	if response == nil {
+		return
+	}
+
+	// Check the agent's edits with the verification command
+	verifyEdits(client, response.Messages)
+
+	// Let the critic model review what was proposed, and maybe revise once
+	if revised := reviewWithCritic(client, input, response.Messages); revised != nil {
+		verifyEdits(client, revised.Messages)
+		response = revised
+	}
+	offerOpenFiles(response.Messages)
+	offerFileBlocks(response.Messages)
+	offerFollowUps(client, response.Messages)
+}
+
+// Send a message with a thinking indicator and show the reply
+func sendAndShow(client *Client, input string) *ChatResponse {
 	// Send message
-	response, err := client.SendMessage(input)
-	done <- true
+	stop := renderer.Thinking()
+	thinking := true
+	stopThinking := func() {
+		if thinking {
+			thinking = false
+			stop()
+		}
+	}
+	turn := startSpan("painika.turn", "")
+	// What the turn has done so far, kept in case the client dies mid-turn
+	journal := startTurnJournal(client, input)
+	stopApprovals := watchApprovals(client)
+	stopQuestions := watchQuestions(client)
+	var response *ChatResponse
+	var err error
+	streamed := false
+	if stream := renderer.Stream(); stream != nil {
+		// The first text replaces the thinking indicator
+		response, err = client.StreamMessage(input, func(text string) {
+			stopThinking()
+			streamed = true
+			journal.Text(text)
+			stream.Write(text)
+		})
+		stream.Close()
+	} else {
+		response, err = client.SendMessage(input)
+	}
+	stopQuestions()
+	stopApprovals()
+	stopThinking()
+
+	// Trace the turn with provider requests and tool executions
+	if response != nil {
+		traceTurn(turn, client.config, response.Messages)
+	} else {
+		turn.End(err)
+		exportSpans(turn)
+	}
 
 	if err != nil {
-		fmt.Printf("\n❌ Error: %v\n\n", err)
-		return
+		journal.Stop()
+		if !renderer.Interactive() {
+			renderer.Error("Failed to send message", err)
+			return nil
+		}
+
+		var chatErr *ChatError
+		if errors.As(err, &chatErr) && chatErr.Kind == ErrBudget {
+			explainBudget(client, chatErr)
+			return nil
+		}
+
+		traceID := ""
+		if tracingEnabled() {
+			traceID = turn.TraceID
+		}
+		return recoverFromError(client, input, err, traceID)
+	}
+
+	// Persist any facts the agent chose to remember (none in incognito mode)
+	if !client.config.Incognito.On {
+		recordAgentMemories(response.Messages)
 	}
 
-	// Clear thinking dots and show response
-	if len(response.Messages) > 0 {
-		fmt.Printf("\r🤖 %s\n", response.Messages[len(response.Messages)-1].Content)
+	// Clear thinking dots and show response, unless it streamed in already
+	if streamed {
+		fmt.Println()
 	} else {
-		fmt.Printf("\r🤖 No response received\n")
+		renderer.Reply(response.Messages)
 	}
-	fmt.Println()
+	trackPlan(response.Messages)
+	trackTurnUsage(client, response.Messages)
+	monitor.RecordLatency(response.Messages)
+	if _, err := recordSession(client); err != nil {
+		fmt.Printf("⚠️  Session not saved: %v\n", err)
+	}
+	journal.Stop()
+	warnBudget(client)
+	return response
 }
 
 // Show help information
@@ -694,15 +1225,57 @@ func printHelp() {
 	fmt.Println("  help, h      - Show this help message")
 	fmt.Println("  tokens, t    - Show token usage statistics")
 	fmt.Println("  history, hist - Show conversation history")
+	fmt.Println("  history full - Also show dropped messages as they were and what the history window hid")
 	fmt.Println("  clear, c     - Clear the screen")
 	fmt.Println("  reset, r     - Reset conversation history")
 	fmt.Println("  quit, q      - Exit the application")
 	fmt.Println()
+	fmt.Println("🧭 Slash Commands:")
+	fmt.Println("  /remember <fact>             - Save a fact for future sessions")
+	fmt.Println("  /memories                    - List saved memories")
+	fmt.Println("  /memories edit <n> <fact>    - Replace memory n")
+	fmt.Println("  /memories delete <n>         - Delete memory n")
+	fmt.Println("  /verify [on|off|<command>]   - Show or change the post-edit verification")
+	fmt.Println("  /context                     - Show context tokens by role")
+	fmt.Println("  /preview [full]              - Show what the next message is sent with, by section, or the exact request")
+	fmt.Println("  /serverlog [n|pane]          - Show server output or toggle the live debug pane")
+	fmt.Println("  /dry-run <prompt>            - List the tool calls the AI would make, without running them")
+	fmt.Println("  /send-to-pane <target>       - Paste the last code block into a tmux/screen pane")
+	fmt.Println("  /run [n]                     - List the last response's shell blocks, or run block n (with approval)")
+	fmt.Println("  /write [n|all]               - List the last response's file blocks (```go path=x.go), or review and write them")
+	fmt.Println("  /open [n | path[:line]]      - List the files the last response mentions, or open one in your editor")
+	fmt.Println("  /set [<key> <value>]         - Show or change settings (history_window, model, follow_ups, critic, json_mode, tool_choice, parallel_tool_calls)")
+	fmt.Println("  /issue <n> [instructions]    - Fetch a GitHub/GitLab/Bitbucket issue and send it to the AI")
+	fmt.Println("  /flag <n> [label] [note]     - Annotate message n (useful, wrong, follow-up, decision)")
+	fmt.Println("  /flags [label]               - List flagged messages")
+	fmt.Println("  /search <text>               - Search messages and annotations")
+	fmt.Println("  /export [file] [--lang l]    - Export the conversation as markdown, optionally translated")
+	fmt.Println("  /gist [description]          - Share the conversation as a secret GitHub gist, secrets redacted")
+	fmt.Println("  /persona [name|default]      - List personas or switch (reviewer, tester, documenter, architect)")
+	fmt.Println("  /translate <language>        - Show the last response translated (code blocks untouched)")
+	fmt.Println("  /job [output [id]]           - List or show full output of commands summarized for the AI")
+	fmt.Println("  /plan [done|undo <n>|clear]  - Show the AI's plan checklist, or mark steps by hand")
+	fmt.Println("  /tag [add|remove <tag>...]   - Show or change the tags of this session")
+	fmt.Println("  /refresh-context             - Update the git branch, commits, and changes the AI sees")
+	fmt.Println("  /drop <n>                    - Remove message n's content from the history sent to the AI")
+	fmt.Println("  /usage [tools]               - Show token usage, or time and tokens by tool and turn")
+	fmt.Println("  /fork <n>                    - Continue in a new session with messages 1..n; the original is kept")
+	fmt.Println("  /incognito [on|off]          - Keep new messages off disk (sessions save only their metadata)")
+	fmt.Println("  /env [set K=v|unset K]       - Environment variables for the tools' commands this session")
+	fmt.Println("  /debug runtime               - Show memory, goroutines, open files, and the server's memory")
+	fmt.Println("  /debug server                - Show the server's memory and CPU trend and the provider's latency")
+	fmt.Println("  /debug restart               - Start a fresh server with this conversation, e.g. when its memory keeps growing")
+	fmt.Println("  /attach-dir <dir> [opts]     - Attach a directory to your next message (--max-tokens n, default 8000)")
+	fmt.Println("  /bundle save|use <name> ...  - Save files and a note as a named bundle, or attach one (/bundle lists)")
+	fmt.Println()
 	fmt.Println("🔧 Available AI Tools:")
 	fmt.Println("  • bash         - Execute shell commands")
 	fmt.Println("  • read_file    - Read file contents")
 	fmt.Println("  • write_file   - Create/modify files")
 	fmt.Println("  • list_files   - List directory contents")
+	fmt.Println("  • search_text  - Search file contents, best matches first")
+	fmt.Println("  • project_stats - Lines by language, TODOs, tests, largest files, dependencies")
+	fmt.Println("  • remember     - Save durable facts across sessions")
 	fmt.Println()
 	fmt.Println("💡 The AI will automatically use tools when needed!")
 	fmt.Println()
@@ -712,68 +1285,33 @@ func printHelp() {
 func showTokenUsage(client *Client) {
 	usage, err := client.GetTokenUsage()
 	if err != nil {
-		fmt.Printf("❌ Error getting token usage: %v\n", err)
+		renderer.Error("Error getting token usage", err)
 		return
 	}
 
-	fmt.Printf("📊 Token Usage Statistics:\n")
-	fmt.Printf("   Input tokens:  %d\n", usage.Input)
-	fmt.Printf("   Output tokens: %d\n", usage.Output)
-	fmt.Printf("   Total tokens:  %d\n", usage.Total)
-
-	// Rough cost estimation (approximate)
-	estimatedCost := float64(usage.Total) * 0.00027 / 1000 // Rough estimate for Groq
-	fmt.Printf("   Estimated cost: $%.4f\n", estimatedCost)
-	fmt.Println()
+	// Rough cost estimation (approximate); local models are free
+	local := client.config.Provider == "ollama"
+	estimatedCost := 0.0
+	if !local {
+		estimatedCost = estimateCost(usage.Total)
+	}
+	renderer.TokenUsage(usage, estimatedCost, local)
+	if len(turnUsage) > 0 {
+		renderer.Notice(turnUsageTable())
+	}
 }
 
 // Show conversation history
 func showConversationHistory(client *Client) {
 	conversation, err := client.GetConversation()
 	if err != nil {
-		fmt.Printf("❌ Error getting conversation: %v\n", err)
-		return
-	}
-
-	fmt.Printf("📚 Conversation History (%d messages):\n", len(conversation.Messages))
-
-	if len(conversation.Messages) == 0 {
-		fmt.Println("   No messages yet. Start chatting!")
-		fmt.Println()
+		renderer.Error("Error getting conversation", err)
 		return
 	}
 
-	for i, msg := range conversation.Messages {
-		icon := "💬"
-		if msg.Role == "assistant" {
-			icon = "🤖"
-		} else if msg.Role == "tool" {
-			icon = "🔧"
-		} else if msg.Role == "system" {
-			continue // Skip system messages in history
-		}
-
-		// Parse timestamp from ISO 8601 format
-		parsedTime, err := time.Parse(time.RFC3339, msg.Timestamp)
-		var timestamp string
-		if err != nil {
-			timestamp = "unknown"
-		} else {
-			timestamp = parsedTime.Format("15:04:05")
-		}
-
-		// Truncate long messages
-		content := msg.Content
-		if len(content) > 100 {
-			content = content[:97] + "..."
-		}
-
-		fmt.Printf("   %d. %s [%s] %s\n", i+1, icon, timestamp, content)
-	}
-	fmt.Println()
+	renderer.History(conversation, annotationsFor(conversation.ID))
 }
 
-// Clear the screen
 func clearScreen() {
 	fmt.Print("\033[H\033[2J")
 }
@@ -786,6 +1324,8 @@ func resetConversation(client *Client) {
 		return
 	}
 
+	activePlan = nil
+	turnUsage = nil
 	fmt.Println("🧹 Conversation history cleared!")
 	fmt.Println()
 }
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Unicode for LaTeX symbol commands
+var mathSymbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "φ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"sum": "∑", "prod": "∏", "int": "∫", "iint": "∬", "oint": "∮", "partial": "∂", "nabla": "∇",
+	"infty": "∞", "pm": "±", "mp": "∓", "times": "×", "cdot": "·", "div": "÷", "ast": "∗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈",
+	"equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"leftrightarrow": "↔", "Leftrightarrow": "⇔", "implies": "⇒", "iff": "⇔", "mapsto": "↦",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "emptyset": "∅", "varnothing": "∅",
+	"forall": "∀", "exists": "∃", "neg": "¬", "lnot": "¬", "land": "∧", "wedge": "∧",
+	"lor": "∨", "vee": "∨", "oplus": "⊕", "otimes": "⊗", "perp": "⊥", "parallel": "∥",
+	"angle": "∠", "triangle": "△", "circ": "∘", "bullet": "•", "star": "⋆", "degree": "°",
+	"prime": "′", "hbar": "ℏ", "ell": "ℓ", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"mid": "|", "vert": "|", "Vert": "‖", "|": "‖", "lbrace": "{", "rbrace": "}",
+	"quad": "  ", "qquad": "    ", ",": " ", ";": " ", ":": " ", " ": " ", "!": "", "\\": "\n",
+	"{": "{", "}": "}", "$": "$", "%": "%", "&": "&", "_": "_", "#": "#",
+}
+
+// Commands that only change how their argument looks
+var mathStyleCommands = map[string]bool{
+	"text": true, "textrm": true, "textbf": true, "textit": true, "texttt": true, "mbox": true,
+	"mathrm": true, "mathbf": true, "mathit": true, "mathsf": true, "mathtt": true, "mathcal": true,
+	"operatorname": true, "boldsymbol": true, "bm": true,
+}
+
+// Commands that only affect sizing and layout
+var mathIgnoredCommands = map[string]bool{
+	"left": true, "right": true, "big": true, "Big": true, "bigg": true, "Bigg": true,
+	"bigl": true, "bigr": true, "Bigl": true, "Bigr": true, "displaystyle": true,
+	"textstyle": true, "limits": true, "nolimits": true,
+}
+
+// Combining marks for accents
+var mathAccents = map[string]string{
+	"vec": "⃗", "hat": "̂", "widehat": "̂", "bar": "̄", "overline": "̅",
+	"dot": "̇", "ddot": "̈", "tilde": "̃", "widetilde": "̃",
+}
+
+var (
+	doubleStruck    = map[rune]string{'N': "ℕ", 'Z': "ℤ", 'Q': "ℚ", 'R': "ℝ", 'C': "ℂ", 'P': "ℙ", 'H': "ℍ"}
+	vulgarFractions = map[string]string{
+		"1/2": "½", "1/3": "⅓", "2/3": "⅔", "1/4": "¼", "3/4": "¾", "1/5": "⅕", "1/6": "⅙", "1/8": "⅛",
+	}
+	// Characters that have superscript and subscript forms
+	superscripts = scriptRunes("0123456789+-=()abcdefghijklmnoprstuvwxyzT", "⁰¹²³⁴⁵⁶⁷⁸⁹⁺⁻⁼⁽⁾ᵃᵇᶜᵈᵉᶠᵍʰⁱʲᵏˡᵐⁿᵒᵖʳˢᵗᵘᵛʷˣʸᶻᵀ")
+	subscripts   = scriptRunes("0123456789+-=()aehijklmnoprstuvx", "₀₁₂₃₄₅₆₇₈₉₊₋₌₍₎ₐₑₕᵢⱼₖₗₘₙₒₚᵣₛₜᵤᵥₓ")
+)
+
+// Pair each rune of from with the rune at the same position in to
+func scriptRunes(from, to string) map[rune]rune {
+	forms := map[rune]rune{}
+	toRunes := []rune(to)
+	for i, r := range []rune(from) {
+		forms[r] = toRunes[i]
+	}
+	return forms
+}
+
+// Raise or lower a script, falling back to ^(...) or _(...)
+func mathScript(marker rune, text string) string {
+	forms := superscripts
+	if marker == '_' {
+		forms = subscripts
+	}
+	scripted := []rune(text)
+	for i, r := range scripted {
+		if scripted[i] = forms[r]; scripted[i] == 0 {
+			scripted = nil
+			break
+		}
+	}
+	if len(scripted) > 0 {
+		return string(scripted)
+	}
+	if len([]rune(text)) == 1 {
+		return string(marker) + text
+	}
+	return string(marker) + "(" + text + ")"
+}
+
+// A single number, name, or symbol that needs no parentheses
+func isMathAtom(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Wrap compound expressions in parentheses
+func mathGroup(text string) string {
+	if isMathAtom(text) || (strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")")) {
+		return text
+	}
+	return "(" + text + ")"
+}
+
+// Converter from LaTeX math to plain Unicode text
+type mathParser struct {
+	src []rune
+	pos int
+}
+
+// Read a command argument: a braced group or a single character
+func (p *mathParser) arg() string {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	if p.src[p.pos] != '{' {
+		if p.src[p.pos] == '\\' {
+			start := p.pos
+			p.pos++
+			p.name()
+			return string(p.src[start:p.pos])
+		}
+		p.pos++
+		return string(p.src[p.pos-1])
+	}
+	depth, start := 0, p.pos+1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(p.src[start : p.pos-1])
+			}
+		}
+	}
+	return string(p.src[start:])
+}
+
+// Read a command name after the backslash
+func (p *mathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// Read an optional [argument]
+func (p *mathParser) optional() string {
+	if p.pos >= len(p.src) || p.src[p.pos] != '[' {
+		return ""
+	}
+	end := p.pos + 1
+	for end < len(p.src) && p.src[end] != ']' {
+		end++
+	}
+	option := string(p.src[p.pos+1 : end])
+	p.pos = end + 1
+	return option
+}
+
+// Convert one command whose name has been read
+func (p *mathParser) command(name string) string {
+	switch {
+	case name == "frac" || name == "dfrac" || name == "tfrac":
+		num, den := latexToText(p.arg()), latexToText(p.arg())
+		if vulgar, ok := vulgarFractions[num+"/"+den]; ok {
+			return vulgar
+		}
+		return mathGroup(num) + "/" + mathGroup(den)
+	case name == "binom":
+		n, k := latexToText(p.arg()), latexToText(p.arg())
+		return "C(" + n + ", " + k + ")"
+	case name == "sqrt":
+		root := map[string]string{"": "√", "2": "√", "3": "∛", "4": "∜"}
+		index := latexToText(p.optional())
+		sign, ok := root[index]
+		if !ok {
+			sign = mathScript('^', index) + "√"
+		}
+		return sign + mathGroup(latexToText(p.arg()))
+	case name == "mathbb":
+		var b strings.Builder
+		for _, r := range latexToText(p.arg()) {
+			if s, ok := doubleStruck[r]; ok {
+				b.WriteString(s)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	case name == "begin" || name == "end":
+		p.arg()
+		return ""
+	case mathStyleCommands[name]:
+		return latexToText(p.arg())
+	case mathIgnoredCommands[name]:
+		// \left. and \right. mark an invisible delimiter
+		if p.pos < len(p.src) && p.src[p.pos] == '.' {
+			p.pos++
+		}
+		return ""
+	}
+	if accent, ok := mathAccents[name]; ok {
+		base := latexToText(p.arg())
+		if len([]rune(base)) == 1 {
+			return base + accent
+		}
+		return base
+	}
+	if symbol, ok := mathSymbols[name]; ok {
+		return symbol
+	}
+	// Functions like \sin and \log read as their names
+	return name
+}
+
+// Convert LaTeX math to readable Unicode text
+func latexToText(latex string) string {
+	p := &mathParser{src: []rune(latex)}
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		p.pos++
+		switch r {
+		case '\\':
+			b.WriteString(p.command(p.name()))
+		case '^', '_':
+			b.WriteString(mathScript(r, latexToText(p.arg())))
+		case '{', '}', '&':
+		case '~':
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Convert a math span; its source line breaks are spaces, and \\ (as in
+// cases and aligned environments) starts a new line
+func mathText(latex string) string {
+	lines := strings.Split(latexToText(strings.Join(strings.Fields(latex), " ")), "\n")
+	for n, line := range lines {
+		lines[n] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Find where a $...$ span closes: the closing $ follows a non-space and is
+// not followed by a letter or digit, so amounts like "$5 and $10" and
+// variables like $HOME/$USER stay as written
+func inlineMathEnd(runes []rune, start int) int {
+	if start >= len(runes) || unicode.IsSpace(runes[start]) {
+		return -1
+	}
+	for i := start; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\n':
+			return -1
+		case runes[i] == '\\':
+			i++
+		case runes[i] == '$' && !unicode.IsSpace(runes[i-1]) &&
+			(i+1 >= len(runes) || !(unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]))):
+			if i == start {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// Index of close in runes at or after start, -1 if missing
+func indexRunes(runes []rune, start int, close string) int {
+	if i := strings.Index(string(runes[start:]), close); i >= 0 {
+		return start + len([]rune(string(runes[start:])[:i]))
+	}
+	return -1
+}
+
+// Replace the math in prose (outside inline code spans) with Unicode text
+func renderProseMath(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		var open, close string
+		switch {
+		case r == '`':
+			end := indexRunes(runes, i+1, "`")
+			if end < 0 {
+				b.WriteString(string(runes[i:]))
+				return b.String()
+			}
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+			continue
+		case r == '\\' && next == '$':
+			b.WriteRune('$')
+			i++
+			continue
+		case r == '$' && next == '$':
+			open, close = "$$", "$$"
+		case r == '\\' && next == '[':
+			open, close = `\[`, `\]`
+		case r == '\\' && next == '(':
+			open, close = `\(`, `\)`
+		case r == '$':
+			if end := inlineMathEnd(runes, i+1); end >= 0 {
+				b.WriteString(mathText(string(runes[i+1 : end])))
+				i = end
+				continue
+			}
+		}
+		if open == "" {
+			b.WriteRune(r)
+			continue
+		}
+
+		start := i + len(open)
+		end := indexRunes(runes, start, close)
+		if end < 0 {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString(mathText(string(runes[start:end])))
+		i = end + len(close) - 1
+	}
+	return b.String()
+}
+
+// Render LaTeX math in a response as Unicode, leaving fenced code alone
+func renderMath(text string) string {
+	if !strings.ContainsAny(text, `$\`) {
+		return text
+	}
+	var out, prose []string
+	flush := func() {
+		if len(prose) > 0 {
+			out = append(out, renderProseMath(strings.Join(prose, "\n")))
+			prose = nil
+		}
+	}
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			inCode = !inCode
+			out = append(out, line)
+			continue
+		}
+		if inCode {
+			out = append(out, line)
+		} else {
+			prose = append(prose, line)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Memory structure for durable facts carried across sessions
+type Memory struct {
+	Fact      string `json:"fact"`
+	Source    string `json:"source"`    // "user" or "agent"
+	CreatedAt string `json:"createdAt"` // ISO 8601 format
+}
+
+// Get the path of the local memory store
+func memoriesPath() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "memories.json"), nil
+}
+
+// Load saved memories (an empty list if none exist yet)
+func loadMemories() ([]Memory, error) {
+	path, err := memoriesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var memories []Memory
+	if err := json.Unmarshal(data, &memories); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return memories, nil
+}
+
+// Save memories to the local store
+func saveMemories(memories []Memory) error {
+	path, err := memoriesPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(memories, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Append a fact to the store, skipping exact duplicates
+func addMemory(fact, source string) (bool, error) {
+	memories, err := loadMemories()
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range memories {
+		if strings.EqualFold(m.Fact, fact) {
+			return false, nil
+		}
+	}
+
+	memories = append(memories, Memory{
+		Fact:      fact,
+		Source:    source,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	return true, saveMemories(memories)
+}
+
+// Build the system context block injected into new sessions
+func memoryContext() string {
+	memories, err := loadMemories()
+	if err != nil || len(memories) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Remembered Facts\n")
+	b.WriteString("Durable facts about the user and project from previous sessions:\n")
+	for _, m := range memories {
+		b.WriteString("- " + m.Fact + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Persist facts the agent saved with the remember tool during a turn
+func recordAgentMemories(messages []Message) {
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			if call.Name != "remember" {
+				continue
+			}
+			fact, _ := call.Parameters["fact"].(string)
+			fact = strings.TrimSpace(fact)
+			if fact == "" {
+				continue
+			}
+			added, err := addMemory(fact, "agent")
+			if err != nil {
+				fmt.Printf("\n❌ Error saving memory: %v\n", err)
+				continue
+			}
+			if added {
+				fmt.Printf("\r🧠 Remembered: %s\n", fact)
+			}
+		}
+	}
+}
+
+// Handle /remember <fact>
+func rememberFact(fact string) {
+	if fact == "" {
+		fmt.Println("Usage: /remember <fact>")
+		fmt.Println()
+		return
+	}
+
+	added, err := addMemory(fact, "user")
+	if err != nil {
+		fmt.Printf("❌ Error saving memory: %v\n\n", err)
+		return
+	}
+
+	if added {
+		fmt.Println("🧠 Remembered! It will be included in new sessions.")
+	} else {
+		fmt.Println("🧠 Already remembered.")
+	}
+	fmt.Println()
+}
+
+// Handle /memories [edit <n> <fact> | delete <n>]
+func manageMemories(args string) {
+	memories, err := loadMemories()
+	if err != nil {
+		fmt.Printf("❌ Error loading memories: %v\n\n", err)
+		return
+	}
+
+	if args == "" {
+		showMemories(memories)
+		return
+	}
+
+	action, rest, _ := strings.Cut(args, " ")
+	indexStr, fact, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	index, err := strconv.Atoi(indexStr)
+	if err != nil || index < 1 || index > len(memories) {
+		fmt.Printf("❌ Invalid memory number: %q\n\n", indexStr)
+		return
+	}
+
+	switch strings.ToLower(action) {
+	case "edit":
+		fact = strings.TrimSpace(fact)
+		if fact == "" {
+			fmt.Println("Usage: /memories edit <n> <fact>")
+			fmt.Println()
+			return
+		}
+		memories[index-1].Fact = fact
+	case "delete", "rm":
+		memories = append(memories[:index-1], memories[index:]...)
+	default:
+		fmt.Println("Usage: /memories [edit <n> <fact> | delete <n>]")
+		fmt.Println()
+		return
+	}
+
+	if err := saveMemories(memories); err != nil {
+		fmt.Printf("❌ Error saving memories: %v\n\n", err)
+		return
+	}
+	fmt.Println("✅ Memories updated")
+	fmt.Println()
+}
+
+// Show saved memories
+func showMemories(memories []Memory) {
+	fmt.Printf("🧠 Memories (%d):\n", len(memories))
+
+	if len(memories) == 0 {
+		fmt.Println("   Nothing remembered yet. Use /remember <fact> to add one.")
+		fmt.Println()
+		return
+	}
+
+	for i, m := range memories {
+		icon := "💬"
+		if m.Source == "agent" {
+			icon = "🤖"
+		}
+		fmt.Printf("   %d. %s %s\n", i+1, icon, m.Fact)
+	}
+	fmt.Println()
+}
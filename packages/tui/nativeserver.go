@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"code-agent/server"
+)
+
+// Serve the native Go server instead of the embedded bundle (`painika server --native`)
+var serverNative bool
+
+// Run the native server in this process. It answers /health, /session,
+// /message, /conversation and /tokens, calling the model directly; it runs
+// no tools yet, so the bundle stays the default.
+func runNativeServer() {
+	token := serverToken()
+	if path, err := saveServerToken(token); err != nil {
+		logLine("warn", "⚠️ ", fmt.Sprintf("Could not save the server token: %v", err))
+	} else {
+		logLine("info", "🔑", fmt.Sprintf("Server token saved to %s (clients elsewhere set SERVER_TOKEN)", path))
+	}
+
+	addr := net.JoinHostPort(getEnv("SERVER_HOST", "127.0.0.1"), getEnv("PORT", "3000"))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logLine("error", "❌", fmt.Sprintf("Failed to start server: %v", err))
+		exit(1)
+	}
+	logLine("info", "🚀", "Native server listening on "+listener.Addr().String()+" (no tools; set SERVER_URL to use it)")
+
+	// Model requests leave without the server token the client transport adds
+	handler := server.New(token, &http.Client{}).Handler()
+	if err := http.Serve(listener, handler); err != nil {
+		logLine("error", "❌", fmt.Sprintf("Server stopped: %v", err))
+		exit(1)
+	}
+}
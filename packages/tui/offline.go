@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Proxy that nothing listens on, so tool commands that honor the proxy
+// variables (curl, pip, npm, go) fail instead of reaching the network
+const offlineProxy = "http://127.0.0.1:9"
+
+// Whether --offline is on, and the hosts it lets through besides this
+// machine (OFFLINE_ALLOW)
+var (
+	offlineMode  bool
+	offlineAllow []string
+)
+
+// Whether offline mode lets a connection to host:port (or host) through
+func offlineAllowed(addr string) bool {
+	if isLocalServer("http://" + addr) {
+		return true
+	}
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	for _, entry := range offlineAllow {
+		if strings.EqualFold(entry, addr) || strings.EqualFold(entry, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dial only hosts offline mode allows
+func offlineDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !offlineAllowed(addr) {
+			return nil, fmt.Errorf("offline mode: refusing to connect to %s (not this machine or in OFFLINE_ALLOW)", addr)
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// Host and port of an endpoint URL as dialed
+func endpointHost(endpoint string) string {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return endpoint
+	}
+	return parsed.Host
+}
+
+// Turn on offline mode (`painika --offline`): only this machine and the
+// hosts in OFFLINE_ALLOW may be reached, and the model must be one of them.
+// Every HTTP client in painika dials through the two transports guarded
+// here, proxies are bypassed so nothing is relayed elsewhere, and the server
+// and the commands it runs get the same allowlist. Runs before the
+// configuration is loaded, as that already talks to Ollama.
+func enableOfflineMode() error {
+	if provider := strings.ToLower(getEnv("PROVIDER", "groq")); provider != "ollama" {
+		return fmt.Errorf("--offline needs a local model (PROVIDER=ollama); %s is a hosted API", provider)
+	}
+	for _, entry := range strings.Split(getEnv("OFFLINE_ALLOW", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			offlineAllow = append(offlineAllow, endpointHost(entry))
+		}
+	}
+
+	model := getEnv("OLLAMA_HOST", "http://localhost:11434")
+	if !strings.Contains(model, "://") {
+		model = "http://" + model
+	}
+	if !offlineAllowed(endpointHost(model)) {
+		return fmt.Errorf("--offline: the model endpoint %s isn't on this machine; add it to OFFLINE_ALLOW if it is inside your network", model)
+	}
+	if server := getEnv("SERVER_URL", ""); server != "" && !offlineAllowed(endpointHost(resolveServerURL(server))) {
+		return fmt.Errorf("--offline: the server %s isn't on this machine; add it to OFFLINE_ALLOW if it is inside your network", server)
+	}
+
+	sharedTransport.Proxy = nil
+	sharedTransport.DialContext = offlineDial(sharedTransport.DialContext)
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.Proxy = nil
+		transport.DialContext = offlineDial(dialer.DialContext)
+	} else {
+		http.DefaultTransport = &http.Transport{DialContext: offlineDial(dialer.DialContext)}
+	}
+	offlineMode = true
+	return nil
+}
+
+// Variables for the server in offline mode: the allowlist it checks model
+// requests against, and a dead proxy for everything its commands fetch
+func offlineEnv() []string {
+	if !offlineMode {
+		return nil
+	}
+	noProxy := "localhost,127.0.0.1,::1"
+	for _, entry := range offlineAllow {
+		host := entry
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			host = h
+		}
+		noProxy += "," + host
+	}
+	env := []string{"PAINIKA_OFFLINE_ALLOW=" + strings.Join(offlineAllow, ",")}
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "http_proxy", "https_proxy", "all_proxy"} {
+		env = append(env, name+"="+offlineProxy)
+	}
+	return append(env, "NO_PROXY="+noProxy, "no_proxy="+noProxy)
+}
+
+// Add the bash tool and the given project tools to the tools that ask
+// before they run: the dead proxy only stops commands that honor it, so in
+// offline mode a command reaches the network only with the user's approval
+func offlineApprovalTools(tools []string, project []ProjectTool) []string {
+	if !offlineMode || containsTag(tools, "*") {
+		return tools
+	}
+	names := []string{"bash"}
+	for _, tool := range project {
+		names = append(names, tool.Name)
+	}
+	for _, name := range names {
+		if !containsTag(tools, name) {
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
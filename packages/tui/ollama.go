@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Models preferred as a default, best coding models first
+var preferredOllamaModels = []string{
+	"qwen2.5-coder",
+	"deepseek-coder-v2",
+	"codellama",
+	"llama3.3",
+	"llama3.1",
+	"llama3.2",
+	"mistral",
+}
+
+// Ollama /api/tags response structure
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	} `json:"models"`
+}
+
+// List the models installed in a local Ollama instance
+func listOllamaModels(host string) ([]string, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(host + "/api/tags")
+	if err != nil {
+		return nil, fmt.Errorf("Ollama is not reachable at %s: %v", host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Ollama at %s returned status %d", host, resp.StatusCode)
+	}
+
+	var tags ollamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to parse Ollama model list: %v", err)
+	}
+
+	models := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
+
+// Pick a sensible default model from the installed ones
+func pickOllamaModel(models []string) string {
+	for _, preferred := range preferredOllamaModels {
+		for _, model := range models {
+			if model == preferred || strings.HasPrefix(model, preferred+":") {
+				return model
+			}
+		}
+	}
+
+	// Skip embedding-only models when falling back
+	for _, model := range models {
+		if !strings.Contains(model, "embed") {
+			return model
+		}
+	}
+	return ""
+}
+
+// Match a requested model name against installed ones ("llama3.1" matches "llama3.1:latest")
+func matchOllamaModel(requested string, models []string) string {
+	for _, model := range models {
+		if model == requested || model == requested+":latest" {
+			return model
+		}
+	}
+	return ""
+}
+
+// Configure the session for a local Ollama server via its OpenAI-compatible API
+func configureOllama(config *Config) error {
+	host := strings.TrimRight(getEnv("OLLAMA_HOST", "http://localhost:11434"), "/")
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	models, err := listOllamaModels(host)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("no models installed in Ollama at %s", host)
+	}
+
+	if config.Model != "" {
+		model := matchOllamaModel(config.Model, models)
+		if model == "" {
+			return fmt.Errorf("model %q is not installed in Ollama (available: %s)", config.Model, strings.Join(models, ", "))
+		}
+		config.Model = model
+	} else {
+		config.Model = pickOllamaModel(models)
+		if config.Model == "" {
+			return fmt.Errorf("no chat models installed in Ollama at %s", host)
+		}
+		fmt.Printf("🦙 Found %d Ollama model(s), using %s\n", len(models), config.Model)
+	}
+
+	// Ollama serves the OpenAI chat completions API under /v1 and ignores the token
+	config.BaseURL = host
+	config.Token = "ollama"
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Files listed after a reply for /open <n>; more are still reachable by path
+const maxOpenShortcuts = 9
+
+// A file mentioned in a reply, with the line it points at (0 for none)
+type fileRef struct {
+	Path  string // Absolute
+	Shown string // As written in the reply
+	Line  int
+}
+
+// Existing files mentioned in text outside code blocks, in order of first
+// mention; a mention with a line wins over one without
+func referencedFiles(text string) []fileRef {
+	var refs []fileRef
+	index := map[string]int{}
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || isDiffLine(trimmed) {
+			continue
+		}
+		for _, match := range linkPattern.FindAllString(line, -1) {
+			if strings.Contains(match, "://") {
+				continue
+			}
+			shown := strings.TrimRight(match, linkTrailing)
+			path, lineNumber := splitLinkLine(shown)
+			abs := resolveLinkPath(path, "")
+			if info, err := os.Stat(abs); err != nil || info.IsDir() {
+				continue
+			}
+			if i, ok := index[abs]; ok {
+				if refs[i].Line == 0 && lineNumber > 0 {
+					refs[i].Shown, refs[i].Line = shown, lineNumber
+				}
+				continue
+			}
+			index[abs] = len(refs)
+			refs = append(refs, fileRef{Path: abs, Shown: shown, Line: lineNumber})
+		}
+	}
+	return refs
+}
+
+// List the files the reply just given mentions, numbered for /open <n>
+func offerOpenFiles(messages []Message) {
+	msg := lastMessage(messages)
+	if msg == nil || !renderer.Interactive() {
+		return
+	}
+	refs := referencedFiles(msg.Content)
+	if len(refs) == 0 {
+		return
+	}
+	if len(refs) > maxOpenShortcuts {
+		refs = refs[:maxOpenShortcuts]
+	}
+	shown := make([]string, len(refs))
+	for i, ref := range refs {
+		shown[i] = fmt.Sprintf("%d. %s", i+1, ref.Shown)
+	}
+	fmt.Printf("📂 %s  (/open <n>)\n\n", strings.Join(shown, "  "))
+}
+
+// Handle /open [n | path[:line]]: list the files the last response
+// mentions, or open one in the editor
+func handleOpen(client *Client, args string) {
+	if args != "" && !isShortcutNumber(args) {
+		path, line := splitLinkLine(args)
+		abs := resolveLinkPath(path, "")
+		if _, err := os.Stat(abs); err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		openReferencedFile(fileRef{Path: abs, Shown: args, Line: line})
+		return
+	}
+
+	content, err := lastAssistantMessage(client)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	refs := referencedFiles(content)
+	if len(refs) == 0 {
+		fmt.Println("❌ The last response mentions no files that exist here; use /open <path[:line]>")
+		fmt.Println()
+		return
+	}
+
+	if args == "" {
+		fmt.Println("📂 Files in the last response:")
+		for i, ref := range refs {
+			fmt.Printf("   %d. %s\n", i+1, ref.Shown)
+		}
+		fmt.Println("💡 Open one with /open <n>")
+		fmt.Println()
+		return
+	}
+
+	n, _ := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	if n < 1 || n > len(refs) {
+		fmt.Printf("Usage: /open <n>  (1-%d, or /open <path[:line]>)\n\n", len(refs))
+		return
+	}
+	openReferencedFile(refs[n-1])
+}
+
+// Whether /open's argument is a list number rather than a path
+func isShortcutNumber(args string) bool {
+	_, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	return err == nil
+}
+
+func openReferencedFile(ref fileRef) {
+	fmt.Printf("📝 Opening %s\n", ref.Shown)
+	if err := openFile(ref.Path, ref.Line); err != nil {
+		fmt.Printf("❌ Failed to open %s: %v\n", ref.Shown, err)
+	}
+	fmt.Println()
+}
+
+// Command from EDITOR_COMMAND, a template such as "code -g {path}:{line}"
+// for editors Painika doesn't know; {line} is 1 when there is no line
+func templateEditorCommand(template, path string, line int) *exec.Cmd {
+	if line < 1 {
+		line = 1
+	}
+	fields := strings.Fields(template)
+	hasPath := false
+	for i, field := range fields {
+		hasPath = hasPath || strings.Contains(field, "{path}")
+		field = strings.ReplaceAll(field, "{path}", path)
+		fields[i] = strings.ReplaceAll(field, "{line}", strconv.Itoa(line))
+	}
+	if !hasPath {
+		fields = append(fields, path)
+	}
+	return exec.Command(fields[0], fields[1:]...)
+}
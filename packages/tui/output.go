@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Where the exit result goes; the original stdout once the TUI chrome is redirected
+var resultOutput = os.Stdout
+
+// Move everything except the exit result to stderr, so `$(painika ...)`
+// captures only the result
+func redirectChrome() {
+	resultOutput = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// Write the selected message to the result output ("last" for the final reply)
+func writeExitOutput(client *Client, selector string) {
+	if selector == "" {
+		return
+	}
+
+	var content string
+	var err error
+	if selector == "last" {
+		content, err = lastAssistantMessage(client)
+	} else {
+		content, err = messageContent(client, selector)
+	}
+	if err != nil {
+		fmt.Printf("❌ --print-on-exit: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(resultOutput, content)
+}
+
+// Content of message n (1-based, as numbered in history)
+func messageContent(client *Client, selector string) (string, error) {
+	n, err := strconv.Atoi(selector)
+	if err != nil {
+		return "", fmt.Errorf("invalid message number %q", selector)
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		return "", err
+	}
+	if n < 1 || n > len(conversation.Messages) {
+		return "", fmt.Errorf("no message %d (conversation has %d)", n, len(conversation.Messages))
+	}
+	return conversation.Messages[n-1].Content, nil
+}
+
+// Redirected output goes through a pipe to a plainWriter; these are
+// flushed before the process exits
+var filteredStreams []filteredStream
+
+type filteredStream struct {
+	pipe *os.File
+	done chan struct{}
+}
+
+// Check whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Filter stdout and stderr when they are redirected, so `painika ... > out.txt`
+// gets clean text without escape sequences or spinner frames
+func plainRedirectedOutput() {
+	if !isTerminal(os.Stdout) {
+		os.Stdout = filterStream(os.Stdout)
+	}
+	if !isTerminal(os.Stderr) {
+		os.Stderr = filterStream(os.Stderr)
+	}
+}
+
+// Route writes to f through a plainWriter; f is returned unchanged if no pipe can be made
+func filterStream(f *os.File) *os.File {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return f
+	}
+
+	stream := filteredStream{pipe: w, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		plain := &plainWriter{out: f}
+		io.Copy(plain, r)
+		plain.Flush()
+		r.Close()
+	}()
+
+	filteredStreams = append(filteredStreams, stream)
+	return w
+}
+
+// Write out everything still in the filters
+func flushOutput() {
+	for _, stream := range filteredStreams {
+		stream.pipe.Close()
+		<-stream.done
+	}
+	filteredStreams = nil
+}
+
+// Exit after flushing filtered output
+func exit(code int) {
+	restoreTerminal()
+	flushOutput()
+	os.Exit(code)
+}
+
+// Writer that drops ANSI escape sequences and resolves carriage returns and
+// backspaces the way a terminal would, so overwritten spinner frames vanish
+type plainWriter struct {
+	out   io.Writer
+	line  []byte
+	state int // one of the esc* states below
+}
+
+const (
+	escNone   = iota
+	escStart  // after ESC
+	escCSI    // inside ESC [ ... final byte
+	escString // inside ESC ] ... BEL or ESC \
+	escStringEnd
+)
+
+func (w *plainWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		switch w.state {
+		case escStart:
+			switch c {
+			case '[':
+				w.state = escCSI
+			case ']', 'P', '_', '^':
+				w.state = escString
+			default:
+				w.state = escNone
+			}
+			continue
+		case escCSI:
+			if c >= 0x40 && c <= 0x7e {
+				w.state = escNone
+			}
+			continue
+		case escString:
+			if c == 0x07 {
+				w.state = escNone
+			} else if c == 0x1b {
+				w.state = escStringEnd
+			}
+			continue
+		case escStringEnd:
+			w.state = escNone
+			continue
+		}
+
+		switch c {
+		case 0x1b:
+			w.state = escStart
+		case '\n':
+			w.line = append(w.line, '\n')
+			if err := w.Flush(); err != nil {
+				return 0, err
+			}
+		case '\r':
+			// Text after a carriage return overwrites the line
+			w.line = w.line[:0]
+		case '\b':
+			if len(w.line) > 0 {
+				_, size := utf8.DecodeLastRune(w.line)
+				w.line = w.line[:len(w.line)-size]
+			}
+		case '\t':
+			w.line = append(w.line, c)
+		default:
+			if c >= 0x20 && c != 0x7f {
+				w.line = append(w.line, c)
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Write the pending partial line
+func (w *plainWriter) Flush() error {
+	if len(w.line) == 0 {
+		return nil
+	}
+	_, err := w.out.Write(w.line)
+	w.line = w.line[:0]
+	return err
+}
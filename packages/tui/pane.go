@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Handle /send-to-pane <target> [--enter]
+func sendToPane(client *Client, args string) {
+	fields := strings.Fields(args)
+	enter := false
+	target := ""
+	for _, field := range fields {
+		if field == "--enter" {
+			enter = true
+		} else if target == "" {
+			target = field
+		}
+	}
+
+	if target == "" {
+		fmt.Println("Usage: /send-to-pane <target> [--enter]")
+		fmt.Println("   tmux targets: 2, :1.2, mysession:editor.0   screen targets: window number or title")
+		fmt.Println()
+		return
+	}
+
+	content, err := lastAssistantMessage(client)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	blocks := extractCodeBlocks(content)
+	if len(blocks) == 0 {
+		fmt.Println("❌ The last response has no code block to send")
+		fmt.Println()
+		return
+	}
+	code := strings.TrimRight(blocks[len(blocks)-1].Code, "\n")
+
+	if err := pasteToMultiplexer(target, code, enter); err != nil {
+		fmt.Printf("❌ Failed to send to pane: %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("📋 Sent %d line(s) to %s\n", strings.Count(code, "\n")+1, target)
+	if !enter {
+		fmt.Println("   Review it there and press Enter to run (or use --enter)")
+	}
+	fmt.Println()
+}
+
+// Paste text into a tmux pane or screen window
+func pasteToMultiplexer(target, text string, enter bool) error {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return pasteToTmux(target, text, enter)
+	case os.Getenv("STY") != "":
+		return pasteToScreen(target, text, enter)
+	}
+
+	// Not inside a multiplexer, but a tmux server may still be running
+	if _, err := exec.LookPath("tmux"); err == nil {
+		return pasteToTmux(target, text, enter)
+	}
+	return fmt.Errorf("not running inside tmux or screen")
+}
+
+func pasteToTmux(target, text string, enter bool) error {
+	load := exec.Command("tmux", "load-buffer", "-b", "painika", "-")
+	load.Stdin = strings.NewReader(text)
+	if output, err := load.CombinedOutput(); err != nil {
+		return fmt.Errorf("tmux load-buffer: %v %s", err, strings.TrimSpace(string(output)))
+	}
+
+	// -p uses bracketed paste so multi-line commands aren't run line by line
+	paste := exec.Command("tmux", "paste-buffer", "-d", "-p", "-b", "painika", "-t", target)
+	if output, err := paste.CombinedOutput(); err != nil {
+		return fmt.Errorf("tmux paste-buffer: %v %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if enter {
+		return exec.Command("tmux", "send-keys", "-t", target, "Enter").Run()
+	}
+	return nil
+}
+
+func pasteToScreen(target, text string, enter bool) error {
+	if enter {
+		text += "\r"
+	}
+
+	cmd := exec.Command("screen", "-S", os.Getenv("STY"), "-p", target, "-X", "stuff", text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("screen stuff: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
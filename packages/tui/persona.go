@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preset bundling a system prompt, sampling temperature, model, and tool policy
+type Persona struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Prompt      string   `json:"prompt"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Model       string   `json:"model,omitempty"` // "" keeps the current model
+	Tools       []string `json:"tools,omitempty"` // Allowed tools; empty allows all
+}
+
+func temperature(t float64) *float64 {
+	return &t
+}
+
+// Read-only tools for personas that shouldn't change the workspace
+var readOnlyTools = []string{"readFile", "list_files", "search_text", "project_stats", "remember"}
+
+var builtinPersonas = []Persona{
+	{
+		Name:        "reviewer",
+		Description: "Reviews code for bugs, security issues, and style; never edits files",
+		Prompt: "Act as a senior code reviewer. Read the relevant code before commenting. " +
+			"Report concrete problems (bugs, security issues, missing error handling, unclear naming) with file and line references, most severe first. " +
+			"Do not modify files; suggest changes as short diffs instead.",
+		Temperature: temperature(0.2),
+		Tools:       readOnlyTools,
+	},
+	{
+		Name:        "tester",
+		Description: "Writes and runs tests for existing code",
+		Prompt: "Act as a test engineer. Find the project's test framework and conventions, then write focused tests " +
+			"covering normal cases, edge cases, and failures. Run the tests and fix the tests (not the code under test) until they pass, " +
+			"reporting any real bugs you find.",
+		Temperature: temperature(0.3),
+	},
+	{
+		Name:        "documenter",
+		Description: "Writes READMEs, doc comments, and usage examples",
+		Prompt: "Act as a technical writer. Read the code before documenting it and describe what it actually does. " +
+			"Match the project's existing documentation style, keep examples runnable, and do not change code behavior.",
+		Temperature: temperature(0.5),
+		Tools:       []string{"readFile", "list_files", "search_text", "writeFile", "apply_patch", "makeDir", "remember"},
+	},
+	{
+		Name:        "architect",
+		Description: "Discusses design, trade-offs, and structure without editing files",
+		Prompt: "Act as a software architect. Explore the codebase's structure first, then discuss designs, trade-offs, and migration steps. " +
+			"Prefer incremental changes that fit the existing architecture. Do not modify files.",
+		Temperature: temperature(0.6),
+		Tools:       readOnlyTools,
+	},
+}
+
+// Add or replace personas by name, keeping order
+func mergePersonas(base, overrides []Persona) []Persona {
+	merged := append([]Persona{}, base...)
+	for _, persona := range overrides {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Name, persona.Name) {
+				merged[i] = persona
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, persona)
+		}
+	}
+	return merged
+}
+
+// Built-in personas plus those from the config files
+func availablePersonas() []Persona {
+	config, err := loadUserConfig()
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	return mergePersonas(builtinPersonas, config.Personas)
+}
+
+func findPersona(name string) (Persona, bool) {
+	for _, persona := range availablePersonas() {
+		if strings.EqualFold(persona.Name, name) {
+			return persona, true
+		}
+	}
+	return Persona{}, false
+}
+
+// Switch the session to a persona, or back to the default with nil
+func applyPersona(client *Client, persona *Persona) error {
+	// Model to return to once a persona's model override ends
+	model := client.config.Model
+	if client.config.PersonaBaseModel != "" {
+		model = client.config.PersonaBaseModel
+	}
+	base := ""
+	if persona != nil && persona.Model != "" {
+		base, model = model, persona.Model
+	}
+
+	settings := map[string]interface{}{"persona": persona, "model": model}
+	if err := client.UpdateSettings(settings); err != nil {
+		return err
+	}
+
+	client.config.Model = model
+	client.config.PersonaBaseModel = base
+	client.config.Persona = ""
+	if persona != nil {
+		client.config.Persona = persona.Name
+	}
+	return nil
+}
+
+// Handle /persona [name|default]
+func handlePersona(client *Client, name string) {
+	if name == "" {
+		fmt.Println("🎭 Personas:")
+		for _, persona := range availablePersonas() {
+			marker := "  "
+			if strings.EqualFold(persona.Name, client.config.Persona) {
+				marker = "▶ "
+			}
+			fmt.Printf("   %s%-12s %s\n", marker, persona.Name, persona.Description)
+		}
+		fmt.Println("   Use /persona <name> to switch, /persona default to reset")
+		fmt.Println()
+		return
+	}
+
+	if strings.EqualFold(name, "default") || strings.EqualFold(name, "none") {
+		if err := applyPersona(client, nil); err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		fmt.Printf("🎭 Default persona (%s)\n\n", client.config.Model)
+		return
+	}
+
+	persona, ok := findPersona(name)
+	if !ok {
+		fmt.Printf("❌ Unknown persona: %s (type /persona to list)\n\n", name)
+		return
+	}
+
+	if err := applyPersona(client, &persona); err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	fmt.Printf("🎭 Persona: %s (%s)\n", persona.Name, client.config.Model)
+	if len(persona.Tools) > 0 {
+		fmt.Printf("   Tools: %s\n", strings.Join(persona.Tools, ", "))
+	}
+	fmt.Println()
+}
+
+// Apply the PERSONA environment variable at startup
+func applyStartupPersona(client *Client) {
+	name := getEnv("PERSONA", "")
+	if name == "" {
+		return
+	}
+
+	persona, ok := findPersona(name)
+	if !ok {
+		fmt.Printf("⚠️  Unknown PERSONA %q, using the default\n", name)
+		return
+	}
+	if err := applyPersona(client, &persona); err != nil {
+		fmt.Printf("⚠️  Failed to apply persona %s: %v\n", name, err)
+	}
+}
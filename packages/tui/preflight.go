@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Content attached to a message (a file, an issue, command output)
+type Attachment struct {
+	Name string
+	Text string
+}
+
+// Read ATTACH_CONFIRM_TOKENS: attachments above this many tokens need confirmation (0 never asks)
+func attachConfirmConfig() (int, error) {
+	value := getEnv("ATTACH_CONFIRM_TOKENS", "8000")
+	tokens, err := strconv.Atoi(value)
+	if err != nil || tokens < 0 {
+		return 0, fmt.Errorf("ATTACH_CONFIRM_TOKENS must be a number of tokens, got %q", value)
+	}
+	return tokens, nil
+}
+
+// Show what the attachments will cost and ask before sending more than the
+// threshold. Returns false if the user declines or cannot be asked.
+func preflightAttachments(client *Client, attachments []Attachment) bool {
+	if len(attachments) == 0 {
+		return true
+	}
+
+	tok := tokenizerForModel(client.config.Model)
+	counts := make([]int, len(attachments))
+	total := 0
+	for i, a := range attachments {
+		counts[i] = tok.Count(a.Text)
+		total += counts[i]
+	}
+
+	// Attachments stay in the history, so they are paid for again every turn
+	cost := "local model, no cost"
+	if client.config.Provider != "ollama" {
+		cost = fmt.Sprintf("~$%.4f now, and again each turn while in the history", estimateCost(total))
+	}
+	fmt.Printf("📎 Attaching ~%s tokens (%s)\n", formatTokens(total), cost)
+	if len(attachments) > 1 {
+		for i, a := range attachments {
+			fmt.Printf("   %-40s ~%s tokens\n", truncateWidth(a.Name, 40), formatTokens(counts[i]))
+		}
+	}
+
+	threshold := client.config.AttachConfirmTokens
+	if threshold == 0 || total <= threshold {
+		return true
+	}
+
+	if !renderer.Interactive() {
+		fmt.Printf("❌ Not sent: above the %s-token confirmation threshold\n", formatTokens(threshold))
+		fmt.Println("💡 Raise ATTACH_CONFIRM_TOKENS (0 never asks) to send it unattended")
+		fmt.Println()
+		return false
+	}
+
+	fmt.Printf("   Above %s tokens. Send it? [y/N] ", formatTokens(threshold))
+	answer, _ := stdin.ReadLine()
+	if strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes") {
+		return true
+	}
+	fmt.Println("🚫 Not sent")
+	fmt.Println()
+	return false
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Files attached with --file and issues fetched with /issue, inside user messages
+var (
+	fileAttachmentPattern  = regexp.MustCompile("(?s)File: ([^\n]+)\n```[^\n]*\n.*?\n```")
+	issueAttachmentPattern = regexp.MustCompile(`^\S+ issue #(\d+): `)
+)
+
+// Get the request the next message would be sent with
+func (c *Client) PreviewRequest() (*RequestPreview, error) {
+	resp, err := c.client.Get(c.config.ServerURL + "/preview")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result PreviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to preview request: %s", result.Error)
+	}
+	return result.Preview, nil
+}
+
+// Named part of the request and its size
+type previewSection struct {
+	Name   string
+	Tokens int
+}
+
+// Split the system prompt at its top-level headings
+func systemSections(tok Tokenizer, prompt string) []previewSection {
+	var sections []previewSection
+	name := "Instructions"
+	var body []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(body, "\n")); text != "" {
+			sections = append(sections, previewSection{name, tok.Count(text)})
+		}
+	}
+	for _, line := range strings.Split(prompt, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			flush()
+			name, body = strings.TrimPrefix(line, "# "), nil
+		}
+		body = append(body, line)
+	}
+	flush()
+	return sections
+}
+
+// Attachments carried by the messages in the history window
+func attachmentSections(tok Tokenizer, messages []Message) []previewSection {
+	var sections []previewSection
+	for _, msg := range messages {
+		if msg.Role != "user" {
+			continue
+		}
+		if match := issueAttachmentPattern.FindStringSubmatch(msg.Content); match != nil {
+			sections = append(sections, previewSection{"issue #" + match[1], tok.Count(msg.Content)})
+			continue
+		}
+		for _, match := range fileAttachmentPattern.FindAllStringSubmatch(msg.Content, -1) {
+			sections = append(sections, previewSection{match[1], tok.Count(match[0])})
+		}
+	}
+	return sections
+}
+
+// Payload in the provider's chat completion format, as the server builds it
+func previewPayload(preview *RequestPreview) map[string]interface{} {
+	messages := make([]map[string]interface{}, len(preview.Messages))
+	for i, msg := range preview.Messages {
+		m := map[string]interface{}{"role": msg.Role, "content": msg.Content}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]map[string]interface{}, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				arguments, _ := json.Marshal(call.Parameters)
+				calls[j] = map[string]interface{}{
+					"id":       call.ID,
+					"type":     "function",
+					"function": map[string]interface{}{"name": call.Name, "arguments": string(arguments)},
+				}
+			}
+			m["tool_calls"] = calls
+		}
+		if len(msg.ToolResults) > 0 {
+			m["tool_call_id"] = msg.ToolResults[0].ID
+		}
+		messages[i] = m
+	}
+
+	payload := map[string]interface{}{
+		"model":       preview.Model,
+		"messages":    messages,
+		"temperature": preview.Temperature,
+	}
+	if len(preview.Tools) > 0 {
+		payload["tools"] = preview.Tools
+		payload["tool_choice"] = "auto"
+	}
+	return payload
+}
+
+// Handle /preview [full]: show what the next message will be sent with
+func showPreview(client *Client, args string) {
+	if args != "" && args != "full" {
+		fmt.Println("Usage: /preview [full]")
+		fmt.Println()
+		return
+	}
+
+	preview, err := client.PreviewRequest()
+	if err != nil {
+		fmt.Printf("❌ Error previewing request: %v\n\n", err)
+		return
+	}
+
+	if args == "full" {
+		data, err := json.MarshalIndent(previewPayload(preview), "", "  ")
+		if err != nil {
+			fmt.Printf("❌ Error encoding request: %v\n\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		fmt.Println()
+		return
+	}
+
+	tok := tokenizerForModel(preview.Model)
+	row := func(indent int, name string, tokens int) {
+		fmt.Printf("   %s%-*s %8s tokens\n", strings.Repeat(" ", indent), 36-indent, truncateWidth(name, 36-indent), formatTokens(tokens))
+	}
+
+	fmt.Printf("🔍 Next request: %s, temperature %g (tokenizer: %s)\n", preview.Model, preview.Temperature, tok.Name())
+	total := 0
+
+	var history []Message
+	for _, msg := range preview.Messages {
+		if msg.Role != "system" {
+			history = append(history, msg)
+			continue
+		}
+		tokens := messageTokens(tok, msg)
+		total += tokens
+		row(0, "System prompt", tokens)
+		for _, section := range systemSections(tok, msg.Content) {
+			row(2, section.Name, section.Tokens)
+		}
+	}
+
+	historyTokens := 0
+	counts := map[string]int{}
+	for _, msg := range history {
+		historyTokens += messageTokens(tok, msg)
+		counts[msg.Role]++
+	}
+	total += historyTokens
+	row(0, fmt.Sprintf("History (%d messages)", len(history)), historyTokens)
+	var roles []string
+	for _, role := range []string{"user", "assistant", "tool"} {
+		if counts[role] > 0 {
+			roles = append(roles, fmt.Sprintf("%d %s", counts[role], role))
+		}
+	}
+	if len(roles) > 0 {
+		fmt.Printf("     %s\n", strings.Join(roles, ", "))
+	}
+	if preview.Omitted > 0 {
+		fmt.Printf("     %d older messages left out by the history window (/set history_window)\n", preview.Omitted)
+	}
+
+	if attachments := attachmentSections(tok, history); len(attachments) > 0 {
+		fmt.Println("   Attachments (part of the history)")
+		for _, a := range attachments {
+			row(2, a.Name, a.Tokens)
+		}
+	}
+
+	toolTokens := 0
+	toolSections := make([]previewSection, len(preview.Tools))
+	for i, tool := range preview.Tools {
+		data, _ := json.Marshal(tool)
+		toolSections[i] = previewSection{tool.Function.Name, tok.Count(string(data))}
+		toolTokens += toolSections[i].Tokens
+	}
+	total += toolTokens
+	row(0, fmt.Sprintf("Tools (%d)", len(preview.Tools)), toolTokens)
+	for _, section := range toolSections {
+		row(2, section.Name, section.Tokens)
+	}
+
+	cost := ""
+	if client.config.Provider != "ollama" {
+		cost = fmt.Sprintf(", ~$%.4f before your message", estimateCost(total))
+	}
+	fmt.Printf("   %-36s %8s tokens%s\n", "Total", formatTokens(total), cost)
+	fmt.Println("💡 /preview full prints the full request body")
+	fmt.Println()
+}
@@ -0,0 +1,115 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Highest resident memory this process has used, in bytes
+func peakRSS() int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return -1
+	}
+	// macOS reports bytes, Linux and the BSDs kilobytes
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
+
+// Number of open file descriptors of this process
+func openFileCount() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries) - 1 // Reading the directory opens one
+		}
+	}
+	return -1
+}
+
+// Resident and peak memory of a process in bytes; -1 when unknown.
+// Only Linux records another process's peak.
+func processMemory(pid int) (rss, peak int64) {
+	rss, peak = -1, -1
+	if data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			name, value, _ := strings.Cut(line, ":")
+			kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			if err != nil {
+				continue
+			}
+			switch name {
+			case "VmRSS":
+				rss = kb * 1024
+			case "VmHWM":
+				peak = kb * 1024
+			}
+		}
+		return rss, peak
+	}
+
+	output, err := exec.Command("ps", "-o", "rss=", "-p", strconv.Itoa(pid)).Output()
+	if kb, perr := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64); err == nil && perr == nil {
+		rss = kb * 1024
+	}
+	return rss, peak
+}
+
+// CPU time a process has used so far; -1 when unknown
+func processCPUTime(pid int) time.Duration {
+	// utime and stime, in clock ticks of 1/100s, follow the state after the
+	// command name, which may itself contain spaces and parentheses
+	if data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+		if end := strings.LastIndexByte(string(data), ')'); end >= 0 {
+			fields := strings.Fields(string(data)[end+1:])
+			if len(fields) > 12 {
+				utime, uerr := strconv.ParseInt(fields[11], 10, 64)
+				stime, serr := strconv.ParseInt(fields[12], 10, 64)
+				if uerr == nil && serr == nil {
+					return time.Duration(utime+stime) * 10 * time.Millisecond
+				}
+			}
+		}
+		return -1
+	}
+
+	// [[dd-]hh:]mm:ss[.ss]
+	output, err := exec.Command("ps", "-o", "time=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return -1
+	}
+	value := strings.TrimSpace(string(output))
+	var total time.Duration
+	if days, rest, ok := strings.Cut(value, "-"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return -1
+		}
+		total, value = time.Duration(n)*24*time.Hour, rest
+	}
+	parts := strings.Split(value, ":")
+	unit := time.Second
+	for i := len(parts) - 1; i >= 0; i-- {
+		n, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return -1
+		}
+		total += time.Duration(n * float64(unit))
+		unit *= 60
+	}
+	return total
+}
+
+// Whether a process is still running
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
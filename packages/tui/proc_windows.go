@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// Process statistics are not read on Windows; -1 shows as unknown
+
+func peakRSS() int64 {
+	return -1
+}
+
+func openFileCount() int {
+	return -1
+}
+
+func processMemory(pid int) (rss, peak int64) {
+	return -1, -1
+}
+
+func processCPUTime(pid int) time.Duration {
+	return -1
+}
+
+// Whether a process is still running
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
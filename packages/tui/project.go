@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Directory committed to a repository with settings shared by the team:
+// config.json (same format as .painika.json), prompt.md, and tools.json
+const projectDir = ".painika"
+
+// Tools the server always has; project tools may not replace them
+var builtinToolNames = []string{"bash", "readFile", "writeFile", "editFile", "apply_patch", "list_files", "search_text", "project_stats", "makeDir", "remember", "ask_user", "rename_symbol", "expand_result"}
+
+// Tool names are identifiers; parameter names become environment variables
+var (
+	toolNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Command the project offers the AI as a tool; arguments are passed to the
+// command as environment variables named after the parameters
+type ProjectTool struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Command     string            `json:"command"`
+	Parameters  map[string]string `json:"parameters,omitempty"` // Name to description
+}
+
+// Path of a file in the project directory ("" when there is none, or the
+// current directory is home and .painika is the global data directory)
+func projectFile(name string) string {
+	info, err := os.Stat(projectDir)
+	if err != nil || !info.IsDir() {
+		return ""
+	}
+	if global, err := painikaDir(); err == nil {
+		if abs, err := filepath.Abs(projectDir); err == nil && abs == global {
+			return ""
+		}
+	}
+	return filepath.Join(projectDir, name)
+}
+
+// Project instructions from .painika/prompt.md for the system prompt
+func projectPrompt() string {
+	path := projectFile("prompt.md")
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return ""
+	}
+	return "# Project Instructions\n" + strings.TrimSpace(string(data))
+}
+
+// Memories and project instructions for a new session
+func sessionContext() string {
+	var parts []string
+	for _, part := range []string{projectPrompt(), memoryContext()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Read and check .painika/tools.json; returns the file for the trust check,
+// followed by the commands as they will run when variables were expanded
+func loadProjectTools() ([]ProjectTool, []byte, error) {
+	path := projectFile("tools.json")
+	if path == "" {
+		return nil, nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var file struct {
+		Tools []ProjectTool `json:"tools"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	seen := map[string]bool{}
+	for _, tool := range file.Tools {
+		switch {
+		case !toolNamePattern.MatchString(tool.Name):
+			return nil, nil, fmt.Errorf("%s: invalid tool name %q", path, tool.Name)
+		case containsTag(builtinToolNames, tool.Name):
+			return nil, nil, fmt.Errorf("%s: %s is a built-in tool", path, tool.Name)
+		case seen[tool.Name]:
+			return nil, nil, fmt.Errorf("%s: tool %s is defined twice", path, tool.Name)
+		case strings.TrimSpace(tool.Command) == "":
+			return nil, nil, fmt.Errorf("%s: tool %s has no command", path, tool.Name)
+		}
+		for param := range tool.Parameters {
+			if !paramNamePattern.MatchString(param) {
+				return nil, nil, fmt.Errorf("%s: tool %s: parameter %q must be a valid environment variable name", path, tool.Name, param)
+			}
+		}
+		seen[tool.Name] = true
+	}
+
+	// Trust covers the commands as they will run, so a changed variable asks again
+	expanded := false
+	for i := range file.Tools {
+		command := file.Tools[i].Command
+		if err := expandToolVars(&file.Tools[i]); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		expanded = expanded || file.Tools[i].Command != command
+	}
+	if expanded {
+		commands, _ := json.Marshal(file.Tools)
+		data = append(append(data, '\n'), commands...)
+	}
+	return file.Tools, data, nil
+}
+
+// Trusted tools.json contents by project directory, in ~/.painika/trusted-projects.json
+func trustFile() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trusted-projects.json"), nil
+}
+
+func loadTrust() map[string]string {
+	trusted := map[string]string{}
+	if path, err := trustFile(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &trusted)
+		}
+	}
+	return trusted
+}
+
+func saveTrust(trusted map[string]string) error {
+	path, err := trustFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Offer the project's tools to the AI once the user trusts their commands.
+// Trust covers the exact file contents, so any change asks again.
+func setupProjectTools(client *Client) {
+	tools, data, err := loadProjectTools()
+	if err != nil {
+		fmt.Printf("⚠️  Project tools not loaded: %v\n\n", err)
+		return
+	}
+	if len(tools) == 0 {
+		return
+	}
+
+	project, err := filepath.Abs(".")
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	trusted := loadTrust()
+
+	if trusted[project] != hash {
+		if !renderer.Interactive() {
+			fmt.Printf("⚠️  Project tools in %s are not trusted yet; run painika interactively to review them\n\n", filepath.Join(projectDir, "tools.json"))
+			return
+		}
+
+		fmt.Printf("🧰 This project defines %d tool(s) that run commands on your machine:\n", len(tools))
+		for _, tool := range tools {
+			fmt.Printf("   %s: %s\n", tool.Name, tool.Description)
+			fmt.Printf("      $ %s\n", tool.Command)
+		}
+		fmt.Print("   Trust these commands? [y/N] ")
+		answer, _ := stdin.ReadLine()
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			fmt.Println("🚫 Project tools disabled for this session")
+			fmt.Println()
+			return
+		}
+
+		trusted[project] = hash
+		if err := saveTrust(trusted); err != nil {
+			fmt.Printf("⚠️  Could not remember the decision: %v\n", err)
+		}
+	}
+
+	settings := map[string]interface{}{"customTools": tools}
+	policy := client.config.Approval
+	policy.Tools = offlineApprovalTools(policy.Tools, tools)
+	if offlineMode {
+		settings["approval"] = policy
+	}
+	if err := client.UpdateSettings(settings); err != nil {
+		fmt.Printf("❌ Failed to load project tools: %v\n\n", err)
+		return
+	}
+	client.config.ProjectTools = tools
+	client.config.Approval = policy
+
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	fmt.Printf("🧰 Project tools: %s\n\n", strings.Join(names, ", "))
+}
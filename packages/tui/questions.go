@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (c *Client) PendingQuestions() ([]PendingQuestion, error) {
+	resp, err := c.client.Get(c.config.ServerURL + "/questions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result QuestionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list questions: %s", result.Error)
+	}
+
+	return result.Questions, nil
+}
+
+func (c *Client) AnswerQuestion(id string, answer QuestionAnswer) error {
+	jsonData, err := json.Marshal(answer)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.config.ServerURL+"/questions/"+id, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Success {
+		return fmt.Errorf("failed to answer question: %s", result.Error)
+	}
+
+	return nil
+}
+
+// Poll for questions from the AI while a turn runs and show a menu for each.
+// Returns a function that stops watching.
+func watchQuestions(client *Client) func() {
+	done := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+
+		seen := map[string]bool{}
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				questions, err := client.PendingQuestions()
+				if err != nil {
+					continue
+				}
+				for _, question := range questions {
+					if !seen[question.ID] {
+						seen[question.ID] = true
+						promptQuestion(client, question)
+					}
+				}
+			}
+		}
+	}()
+
+	return func() { done <- true }
+}
+
+// Show a question as a numbered menu and send back the choice
+func promptQuestion(client *Client, question PendingQuestion) {
+	// Nobody can answer without a terminal; let the AI pick and say so
+	if !renderer.Interactive() {
+		renderer.Notice("Skipped question from the AI: " + question.Question)
+		client.AnswerQuestion(question.ID, QuestionAnswer{Skipped: true})
+		return
+	}
+
+	promptActive.Store(true)
+	defer promptActive.Store(false)
+
+	fmt.Printf("\n❓ %s\n", question.Question)
+	for i, option := range question.Options {
+		fmt.Printf("   %d. %s\n", i+1, option)
+	}
+	choices := len(question.Options)
+	if question.AllowOther {
+		choices++
+		fmt.Printf("   %d. Other (type your own answer)\n", choices)
+	}
+
+	remaining := func() time.Duration {
+		if question.ExpiresAt == 0 {
+			return 0
+		}
+		if left := time.Until(time.UnixMilli(question.ExpiresAt)); left > 0 {
+			return left
+		}
+		return time.Millisecond
+	}
+
+	for {
+		fmt.Printf("   Choose 1-%d, or Enter to skip > ", choices)
+		input, ok := stdin.ReadLineTimeout(remaining())
+		if !ok {
+			// The server marks the question skipped itself when it expires
+			fmt.Println("\n⏱️  No answer, the AI will decide")
+			return
+		}
+
+		answer, valid := parseQuestionAnswer(question, input)
+		if !valid {
+			fmt.Printf("   ❌ Not an option: %s\n", input)
+			continue
+		}
+
+		if answer.Selected == nil && !answer.Skipped && answer.Text == "" {
+			fmt.Print("   Your answer: ")
+			answer.Text, _ = stdin.ReadLineTimeout(remaining())
+			if answer.Text == "" {
+				answer.Skipped = true
+			}
+		}
+
+		if err := client.AnswerQuestion(question.ID, answer); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+
+		switch {
+		case answer.Skipped:
+			fmt.Println("⏭️  Skipped, the AI will decide")
+		case answer.Selected != nil:
+			fmt.Printf("✅ %s\n", question.Options[*answer.Selected])
+		default:
+			fmt.Printf("✅ %s\n", answer.Text)
+		}
+		return
+	}
+}
+
+// Parse a menu choice: an option number, an option's text, or free text when
+// allowed. Choosing "Other" returns an answer with neither option nor text.
+func parseQuestionAnswer(question PendingQuestion, input string) (QuestionAnswer, bool) {
+	if input == "" {
+		return QuestionAnswer{Skipped: true}, true
+	}
+
+	if n, err := strconv.Atoi(input); err == nil {
+		switch {
+		case n >= 1 && n <= len(question.Options):
+			selected := n - 1
+			return QuestionAnswer{Selected: &selected}, true
+		case question.AllowOther && n == len(question.Options)+1:
+			return QuestionAnswer{}, true
+		}
+		return QuestionAnswer{}, false
+	}
+
+	for i, option := range question.Options {
+		if strings.EqualFold(input, option) {
+			selected := i
+			return QuestionAnswer{Selected: &selected}, true
+		}
+	}
+
+	if question.AllowOther {
+		return QuestionAnswer{Text: input}, true
+	}
+	return QuestionAnswer{}, false
+}
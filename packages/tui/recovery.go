@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Show the error panel for a failed message and let the user recover.
+// Returns the response of a successful retry, or nil if dismissed.
+func recoverFromError(client *Client, input string, err error, traceID string) *ChatResponse {
+	var chatErr *ChatError
+	if !errors.As(err, &chatErr) {
+		chatErr = &ChatError{Kind: ErrServer, Message: err.Error(), Err: err}
+	}
+
+	fmt.Printf("\n❌ %s\n", chatErr.Summary())
+	fmt.Printf("   %s\n", truncateWidth(chatErr.Message, termWidth()-3))
+	fmt.Printf("💡 %s\n", chatErr.Hint())
+
+	for {
+		fmt.Println("   [r] retry  [m] retry with another model  [e] edit message  [d] debug trace  [c] copy details  [Enter] dismiss")
+		fmt.Print("   > ")
+
+		answer, ok := stdin.ReadLine()
+		if !ok {
+			return nil
+		}
+
+		switch strings.ToLower(answer) {
+		case "r", "retry":
+			return sendAndShow(client, input)
+		case "m", "model":
+			fmt.Printf("   Model [%s]: ", client.config.Model)
+			model, _ := stdin.ReadLine()
+			if model == "" || model == client.config.Model {
+				continue
+			}
+			if err := client.UpdateSettings(map[string]interface{}{"model": model}); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				continue
+			}
+			client.config.Model = model
+			fmt.Printf("⚙️  model = %s\n", model)
+			return sendAndShow(client, input)
+		case "e", "edit":
+			fmt.Printf("   Message: %s\n", truncateWidth(input, termWidth()-12))
+			fmt.Print("   New message: ")
+			edited, _ := stdin.ReadLine()
+			if edited == "" {
+				continue
+			}
+			return sendAndShow(client, edited)
+		case "d", "debug":
+			fmt.Println(errorDetails(client, chatErr, traceID))
+			printServerLogTail(20)
+		case "c", "copy":
+			details := errorDetails(client, chatErr, traceID)
+			if err := copyToClipboard(details); err == nil {
+				fmt.Println("📋 Error details copied to the clipboard")
+				continue
+			}
+			// Fall back to a file when no clipboard tool is installed
+			dir, err := painikaDir()
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				continue
+			}
+			path := filepath.Join(dir, "last-error.txt")
+			if err := os.WriteFile(path, []byte(details+"\n"), 0644); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				continue
+			}
+			fmt.Printf("📋 No clipboard available; details written to %s\n", path)
+		default:
+			fmt.Println()
+			return nil
+		}
+	}
+}
+
+// Plain-text error report for debugging or sharing
+func errorDetails(client *Client, chatErr *ChatError, traceID string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Error:    %s\n", chatErr.Summary())
+	fmt.Fprintf(&b, "Kind:     %s\n", chatErr.Kind)
+	if chatErr.ProviderStatus > 0 {
+		fmt.Fprintf(&b, "Status:   %d\n", chatErr.ProviderStatus)
+	}
+	fmt.Fprintf(&b, "Provider: %s (%s)\n", client.config.Provider, client.config.Model)
+	fmt.Fprintf(&b, "Server:   %s\n", client.config.ServerURL)
+	if traceID != "" {
+		fmt.Fprintf(&b, "Trace:    %s\n", traceID)
+	}
+	fmt.Fprintf(&b, "Message:  %s", chatErr.Message)
+	return b.String()
+}
+
+// Copy text with the platform's clipboard tool
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		candidates = [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+	}
+
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return fmt.Errorf("no clipboard tool found")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Replaces whatever a redaction rule matches
+const redactedPlaceholder = "[REDACTED]"
+
+// Secrets recognized by their shape. A pattern with a group redacts only the
+// group, so `password = hunter2` keeps its key.
+var secretPatterns = []string{
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`,
+	`\b(?:sk|gsk|xai)-?[A-Za-z0-9_\-]{20,}\b`,
+	`\bxox[abprs]-[A-Za-z0-9\-]{10,}`,
+	`\beyJ[A-Za-z0-9_\-]{8,}\.eyJ[A-Za-z0-9_\-]{8,}\.[A-Za-z0-9_\-]{8,}`,
+	`(?i)\bBearer\s+([A-Za-z0-9._~+/\-]{16,}=*)`,
+	`(?i)(?:password|passwd|secret|token|api[_-]?key)["']?\s*[:=]\s*["']?([^\s"',;]{6,})`,
+	`(?i)://[^/\s:@]+:([^/\s@]+)@`,
+}
+
+// Rules for redacting text before it leaves the machine: the built-in
+// patterns, REDACT_PATTERNS, and redact_patterns from the config files
+func redactionRules(user UserConfig) ([]*regexp.Regexp, error) {
+	var rules []*regexp.Regexp
+	for _, pattern := range secretPatterns {
+		rules = append(rules, regexp.MustCompile(pattern))
+	}
+	for _, pattern := range append(splitList(getEnv("REDACT_PATTERNS", "")), user.RedactPatterns...) {
+		rule, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDACT_PATTERNS pattern %q: %v", pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Values of secret environment variables (the ones withheld from subprocesses)
+func secretEnvValues() []string {
+	var values []string
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if len(value) >= 8 && matchEnvName(name, defaultDeniedEnv) && !matchEnvName(name, essentialEnv) {
+			values = append(values, value)
+		}
+	}
+	// Longest first, so a value containing another is replaced whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// Replace secrets in text, returning the redacted text and how many were replaced
+func redactSecrets(text string, rules []*regexp.Regexp) (string, int) {
+	count := 0
+	for _, value := range secretEnvValues() {
+		count += strings.Count(text, value)
+		text = strings.ReplaceAll(text, value, redactedPlaceholder)
+	}
+	for _, rule := range rules {
+		text = rule.ReplaceAllStringFunc(text, func(match string) string {
+			if strings.Contains(match, redactedPlaceholder) {
+				return match
+			}
+			count++
+			groups := rule.FindStringSubmatchIndex(match)
+			if len(groups) < 4 || groups[2] < 0 {
+				return redactedPlaceholder
+			}
+			return match[:groups[2]] + redactedPlaceholder + match[groups[3]:]
+		})
+	}
+	return text, count
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Names a rename takes; anything else is not a symbol
+var symbolPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Hunk header of a unified diff
+var hunkPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// One line a rename changes
+type RenameChange struct {
+	File   string
+	Line   int
+	Before string
+	After  string
+}
+
+// Changes of a rename, found before anything is written
+type RenamePlan struct {
+	Old, New string
+	Changes  []RenameChange
+	Engines  []string // What found the changes, e.g. gopls and ripgrep
+	apply    []func() error
+}
+
+// Files under paths that contain old as a whole word, with the search tool
+// used: ripgrep, or git grep where it is not installed
+func symbolFiles(old string, paths []string) ([]string, string, error) {
+	var cmd *exec.Cmd
+	engine := "ripgrep"
+	if _, err := exec.LookPath("rg"); err == nil {
+		cmd = exec.Command("rg", append([]string{"--files-with-matches", "--word-regexp", "--fixed-strings", "--", old}, paths...)...)
+	} else if _, err := exec.LookPath("git"); err == nil {
+		engine = "git grep"
+		scope := "--untracked"
+		if _, err := runGit(".", "rev-parse", "--is-inside-work-tree"); err != nil {
+			scope = "--no-index"
+		}
+		cmd = exec.Command("git", append([]string{"grep", scope, "-l", "-I", "-w", "-F", "-e", old, "--"}, paths...)...)
+	} else {
+		return nil, "", fmt.Errorf("renaming needs ripgrep (rg) or git to find the symbol")
+	}
+
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return nil, engine, nil // No matches
+	}
+	if err != nil {
+		return nil, engine, fmt.Errorf("%s: %v", engine, err)
+	}
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			files = append(files, filepath.Clean(line))
+		}
+	}
+	return files, engine, nil
+}
+
+// Lines changed by a unified diff, as gopls -d and gofmt -d print them; a
+// removed line is paired with the added line in the same place
+func parseRenameDiff(diff string) []RenameChange {
+	var changes []RenameChange
+	var file string
+	var removed, added []string
+	line, start := 0, 0
+
+	flush := func() {
+		for i, before := range removed {
+			change := RenameChange{File: file, Line: start + i, Before: before}
+			if i < len(added) {
+				change.After = added[i]
+			}
+			changes = append(changes, change)
+		}
+		removed, added = nil, nil
+	}
+
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			flush()
+			file = strings.TrimPrefix(strings.TrimSpace(strings.SplitN(text[4:], "\t", 2)[0]), "b/")
+			if cwd, err := os.Getwd(); err == nil && filepath.IsAbs(file) {
+				if rel, err := filepath.Rel(cwd, file); err == nil && !strings.HasPrefix(rel, "..") {
+					file = rel
+				}
+			}
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "diff "):
+			flush()
+		case hunkPattern.MatchString(text):
+			flush()
+			line, _ = strconv.Atoi(hunkPattern.FindStringSubmatch(text)[1])
+		case strings.HasPrefix(text, "-"):
+			if len(added) > 0 {
+				flush()
+			}
+			if len(removed) == 0 {
+				start = line
+			}
+			removed = append(removed, text[1:])
+			line++
+		case strings.HasPrefix(text, "+"):
+			added = append(added, text[1:])
+		case strings.HasPrefix(text, " "):
+			flush()
+			line++
+		}
+	}
+	flush()
+	return changes
+}
+
+// Where gopls should start a rename of old: its declaration when one of the
+// files declares it, otherwise its first use
+func symbolPosition(files []string, old string) string {
+	fset := token.NewFileSet()
+	var first token.Pos
+	for _, file := range files {
+		parsed, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		var declared token.Pos
+		ast.Inspect(parsed, func(node ast.Node) bool {
+			if declared.IsValid() {
+				return false
+			}
+			var names []*ast.Ident
+			switch node := node.(type) {
+			case *ast.FuncDecl:
+				names = []*ast.Ident{node.Name}
+			case *ast.TypeSpec:
+				names = []*ast.Ident{node.Name}
+			case *ast.ValueSpec:
+				names = node.Names
+			case *ast.Field:
+				names = node.Names
+			case *ast.Ident:
+				if node.Name == old && !first.IsValid() {
+					first = node.Pos()
+				}
+			}
+			for _, name := range names {
+				if name.Name == old {
+					declared = name.Pos()
+				}
+			}
+			return true
+		})
+		if declared.IsValid() {
+			first = declared
+			break
+		}
+	}
+	if !first.IsValid() {
+		return ""
+	}
+	position := fset.Position(first)
+	return fmt.Sprintf("%s:%d:%d", position.Filename, position.Line, position.Column)
+}
+
+// Rename in Go files with gopls, which follows the symbol's references
+// across packages, or with gofmt -r, which renames every identifier of that
+// name where gopls is not installed
+func planGoRename(plan *RenamePlan, files []string) error {
+	if _, err := exec.LookPath("gopls"); err == nil {
+		position := symbolPosition(files, plan.Old)
+		if position == "" {
+			return nil // Only in comments and strings
+		}
+		output, err := exec.Command("gopls", "rename", "-d", position, plan.New).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("gopls rename: %s", strings.TrimSpace(string(output)))
+		}
+		plan.Changes = append(plan.Changes, parseRenameDiff(string(output))...)
+		plan.Engines = append(plan.Engines, "gopls")
+		plan.apply = append(plan.apply, func() error {
+			if output, err := exec.Command("gopls", "rename", "-w", position, plan.New).CombinedOutput(); err != nil {
+				return fmt.Errorf("gopls rename: %s", strings.TrimSpace(string(output)))
+			}
+			return nil
+		})
+		return nil
+	}
+
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		return fmt.Errorf("renaming in Go files needs gopls or gofmt")
+	}
+	// gofmt -r takes single lowercase letters as wildcards
+	for _, name := range []string{plan.Old, plan.New} {
+		if len(name) == 1 && name[0] >= 'a' && name[0] <= 'z' {
+			return fmt.Errorf("gofmt -r can't rename to or from the one-letter name %s; install gopls for that", name)
+		}
+	}
+	rule := plan.Old + " -> " + plan.New
+	// gofmt -d exits with 1 when it prints a diff; errors go to stderr
+	output, err := exec.Command("gofmt", append([]string{"-d", "-r", rule}, files...)...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("gofmt -r: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	} else if err != nil && !ok {
+		return fmt.Errorf("gofmt -r: %v", err)
+	}
+	plan.Changes = append(plan.Changes, parseRenameDiff(string(output))...)
+	plan.Engines = append(plan.Engines, "gofmt -r")
+	plan.apply = append(plan.apply, func() error {
+		if output, err := exec.Command("gofmt", append([]string{"-w", "-r", rule}, files...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("gofmt -r: %s", strings.TrimSpace(string(output)))
+		}
+		return nil
+	})
+	return nil
+}
+
+// Rename whole-word matches in other files, line by line
+func planTextRename(plan *RenamePlan, files []string) error {
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(plan.Old) + `\b`)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(data), "\n")
+		changed := false
+		for i, line := range lines {
+			if renamed := word.ReplaceAllLiteralString(line, plan.New); renamed != line {
+				plan.Changes = append(plan.Changes, RenameChange{File: file, Line: i + 1, Before: line, After: renamed})
+				lines[i] = renamed
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		file, output := file, strings.Join(lines, "\n")
+		plan.apply = append(plan.apply, func() error {
+			info, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(file, []byte(output), info.Mode().Perm())
+		})
+	}
+	return nil
+}
+
+// Find every change renaming old to new under paths makes
+func planRename(old, new string, paths []string) (*RenamePlan, error) {
+	for _, name := range []string{old, new} {
+		if !symbolPattern.MatchString(name) {
+			return nil, fmt.Errorf("%q is not a symbol name", name)
+		}
+	}
+	if old == new {
+		return nil, fmt.Errorf("the new name is the same as the old one")
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, engine, err := symbolFiles(old, paths)
+	if err != nil {
+		return nil, err
+	}
+	var goFiles, otherFiles []string
+	for _, file := range files {
+		if strings.HasSuffix(file, ".go") {
+			goFiles = append(goFiles, file)
+		} else {
+			otherFiles = append(otherFiles, file)
+		}
+	}
+
+	plan := &RenamePlan{Old: old, New: new}
+	if len(goFiles) > 0 {
+		if err := planGoRename(plan, goFiles); err != nil {
+			return nil, err
+		}
+	}
+	if len(otherFiles) > 0 {
+		if err := planTextRename(plan, otherFiles); err != nil {
+			return nil, err
+		}
+		plan.Engines = append(plan.Engines, engine)
+	}
+	return plan, nil
+}
+
+// Make the planned changes
+func (p *RenamePlan) Apply() error {
+	for _, apply := range p.apply {
+		if err := apply(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Files the plan changes
+func (p *RenamePlan) Files() []string {
+	var files []string
+	for _, change := range p.Changes {
+		if len(files) == 0 || files[len(files)-1] != change.File {
+			files = append(files, change.File)
+		}
+	}
+	return files
+}
+
+// The changes as a list of locations with each line before and after
+func formatRenamePlan(plan *RenamePlan) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "🔁 %s → %s: %d line(s) in %d file(s) (%s)\n", plan.Old, plan.New, len(plan.Changes), len(plan.Files()), strings.Join(plan.Engines, ", "))
+	for _, change := range plan.Changes {
+		fmt.Fprintf(&out, "   %s:%d\n", filepath.ToSlash(change.File), change.Line)
+		fmt.Fprintf(&out, "     - %s\n", strings.TrimSpace(change.Before))
+		fmt.Fprintf(&out, "     + %s\n", strings.TrimSpace(change.After))
+	}
+	return out.String()
+}
+
+// Binary the server runs as `<binary> rename` for the rename_symbol tool
+func renameToolCommand() string {
+	binary, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(binary)
+}
+
+// Lines a rename changes per file, as JSON, so the server can check each
+// file against its write policy and change limits before applying it
+func renamePlanJSON(plan *RenamePlan) string {
+	files := map[string]int{}
+	for _, change := range plan.Changes {
+		files[filepath.ToSlash(change.File)]++
+	}
+	data, _ := json.Marshal(map[string]interface{}{"files": files})
+	return string(data)
+}
+
+// Handle `painika rename <old> <new> [path...] [--apply | --json]`: list
+// every line the rename changes, and change them with --apply; --json gives
+// the lines changed per file instead
+func runRename(args []string) {
+	apply := false
+	asJSON := false
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i+1:]...)
+			break
+		}
+		switch {
+		case arg == "--apply" || arg == "-y":
+			apply = true
+		case arg == "--json":
+			asJSON = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Printf("❌ unknown flag: %s\n", arg)
+			exit(2)
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if len(rest) < 2 || (apply && asJSON) {
+		fmt.Println("Usage: painika rename <old> <new> [path...] [--apply | --json]")
+		exit(2)
+	}
+
+	plan, err := planRename(rest[0], rest[1], rest[2:])
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if asJSON {
+		fmt.Println(renamePlanJSON(plan))
+		exit(0)
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Printf("🤷 No occurrences of %s to rename\n", plan.Old)
+		exit(1)
+	}
+	fmt.Print(formatRenamePlan(plan))
+	if !apply {
+		fmt.Println("💡 Preview only; nothing was changed. Apply it with --apply")
+		exit(0)
+	}
+	if err := plan.Apply(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("✅ Renamed %s to %s in %d file(s)\n", plan.Old, plan.New, len(plan.Files()))
+	exit(0)
+}
+
+// Show the locations a rename_symbol call would change while it waits for
+// approval
+func previewRename(params map[string]interface{}) {
+	symbol, _ := params["symbol"].(string)
+	newName, _ := params["new_name"].(string)
+	var paths []string
+	if path, _ := params["path"].(string); path != "" {
+		paths = []string{path}
+	}
+	plan, err := planRename(symbol, newName, paths)
+	if err != nil {
+		fmt.Printf("   ⚠️  No preview: %v\n", err)
+		return
+	}
+	fmt.Print(formatRenamePlan(plan))
+}
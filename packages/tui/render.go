@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Renderer draws the session for one frontend. New frontends (a web UI
+// bridge, a GUI) implement it instead of changing the client or commands.
+type Renderer interface {
+	Prompt()                                                           // Ready for the next input
+	Thinking() func()                                                  // Waiting on the AI; returns a function that stops the indicator
+	Reply(messages []Message)                                          // Messages produced by one turn, ending with the reply
+	Stream() ReplyStream                                               // Reply text as it is generated, or nil to show replies whole
+	Notice(text string)                                                // Status line
+	Error(context string, err error)                                   // Failure with a short description of what was attempted
+	TokenUsage(usage *TokenUsage, cost float64, local bool)            // Token statistics
+	History(conversation *Conversation, flags map[string][]Annotation) // Conversation listing
+	Interactive() bool                                                 // Whether recovery menus may prompt for input
+	Banner(info BannerInfo)                                            // Welcome once the session is ready
+}
+
+// Draws reply text as it streams in
+type ReplyStream interface {
+	Write(text string)
+	Close()
+}
+
+// Active renderer, chosen by RENDERER (tui, plain, or json)
+var renderer Renderer = TUIRenderer{}
+
+// Pick the renderer from the environment
+func rendererConfig() (Renderer, error) {
+	switch name := strings.ToLower(getEnv("RENDERER", "tui")); name {
+	case "tui":
+		return TUIRenderer{}, nil
+	case "plain":
+		return PlainRenderer{}, nil
+	case "json":
+		return JSONRenderer{encoder: json.NewEncoder(os.Stdout)}, nil
+	default:
+		return nil, fmt.Errorf("invalid RENDERER %q (expected tui, plain, or json)", name)
+	}
+}
+
+// Last message of a turn, or nil
+func lastMessage(messages []Message) *Message {
+	if len(messages) == 0 {
+		return nil
+	}
+	return &messages[len(messages)-1]
+}
+
+// Format an ISO 8601 timestamp as a clock time
+func clockTime(timestamp string) string {
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return "unknown"
+	}
+	return parsed.Format("15:04:05")
+}
+
+// Emoji, word-wrapped terminal output
+type TUIRenderer struct{}
+
+func (TUIRenderer) Interactive() bool {
+	return true
+}
+
+func (TUIRenderer) Prompt() {
+	fmt.Print(monitor.Indicator() + "💬 > ")
+}
+
+func (TUIRenderer) Thinking() func() {
+	fmt.Print("🤖 ")
+	return showThinking()
+}
+
+func (TUIRenderer) Reply(messages []Message) {
+	if msg := lastMessage(messages); msg != nil {
+		fmt.Printf("\r🤖 %s\n", linkifyReply(formatForTerminal(msg.Content, 3)))
+	} else {
+		fmt.Printf("\r🤖 No response received\n")
+	}
+	fmt.Println()
+}
+
+// Replies stream in as they are generated, unless STREAMING=off or stdout
+// isn't a terminal (redrawing the unfinished line needs one)
+func (TUIRenderer) Stream() ReplyStream {
+	if strings.EqualFold(getEnv("STREAMING", "on"), "off") || !isTerminal(os.Stdout) {
+		return nil
+	}
+	return newMarkdownStream(3)
+}
+
+func (TUIRenderer) Notice(text string) {
+	fmt.Println(text)
+}
+
+// Welcome banner (collapsed on narrow terminals)
+func (TUIRenderer) Banner(info BannerInfo) {
+	if isNarrow() {
+		fmt.Printf("🤖 %s\n", truncateWidth(info.Model, termWidth()-3))
+		fmt.Println("💡 'help' · 'quit'")
+		fmt.Println()
+		return
+	}
+	fmt.Println("🤖 Code Agent initialized successfully!")
+	fmt.Printf("   Model: %s (%s)\n", info.Model, info.Provider)
+	fmt.Printf("   Server: %s\n", info.Server)
+	fmt.Println()
+	fmt.Println("💡 Type 'help' for commands, 'quit' to exit")
+	fmt.Println("📝 Start chatting with the AI...")
+	fmt.Println()
+}
+
+func (TUIRenderer) Error(context string, err error) {
+	fmt.Printf("❌ %s: %v\n", context, err)
+}
+
+func (TUIRenderer) TokenUsage(usage *TokenUsage, cost float64, local bool) {
+	fmt.Printf("📊 Token Usage Statistics:\n")
+	fmt.Printf("   Input tokens:  %d\n", usage.Input)
+	fmt.Printf("   Output tokens: %d\n", usage.Output)
+	fmt.Printf("   Total tokens:  %d\n", usage.Total)
+	if local {
+		fmt.Println("   Estimated cost: $0.0000 (local model)")
+	} else {
+		fmt.Printf("   Estimated cost: $%.4f\n", cost)
+	}
+	fmt.Println()
+}
+
+func (TUIRenderer) History(conversation *Conversation, flags map[string][]Annotation) {
+	fmt.Printf("📚 Conversation History (%d messages):\n", len(conversation.Messages))
+
+	if len(conversation.Messages) == 0 {
+		fmt.Println("   No messages yet. Start chatting!")
+		fmt.Println()
+		return
+	}
+
+	for i, msg := range conversation.Messages {
+		icon := "💬"
+		if msg.Role == "assistant" {
+			icon = "🤖"
+		} else if msg.Role == "tool" {
+			icon = "🔧"
+		} else if msg.Role == "system" {
+			continue // Skip system messages in history
+		}
+
+		// Mark flagged messages
+		for _, a := range flags[msg.ID] {
+			icon += a.Icon()
+		}
+
+		// Name who sent it when several clients share the session
+		author := ""
+		if msg.Author != "" {
+			author = msg.Author + ": "
+		}
+
+		// Truncate long messages to fit the terminal
+		content := truncateWidth(author+msg.Content, termWidth()-22)
+
+		fmt.Printf("   %d. %s [%s] %s\n", i+1, icon, clockTime(msg.Timestamp), content)
+	}
+	fmt.Println()
+}
+
+// Unadorned text for pipes, logs, and screen readers
+type PlainRenderer struct{}
+
+func (PlainRenderer) Interactive() bool {
+	return false
+}
+
+func (PlainRenderer) Prompt() {
+	fmt.Print("> ")
+}
+
+func (PlainRenderer) Thinking() func() {
+	return func() {}
+}
+
+func (PlainRenderer) Reply(messages []Message) {
+	if msg := lastMessage(messages); msg != nil {
+		fmt.Println(msg.Content)
+	}
+	fmt.Println()
+}
+
+func (PlainRenderer) Stream() ReplyStream {
+	return nil
+}
+
+func (PlainRenderer) Notice(text string) {
+	fmt.Println(text)
+}
+
+func (PlainRenderer) Banner(info BannerInfo) {
+	fmt.Printf("painika: %s (%s) at %s\n", info.Model, info.Provider, info.Server)
+}
+
+func (PlainRenderer) Error(context string, err error) {
+	fmt.Printf("error: %s: %v\n", context, err)
+}
+
+func (PlainRenderer) TokenUsage(usage *TokenUsage, cost float64, local bool) {
+	fmt.Printf("input=%d output=%d total=%d cost=$%.4f\n", usage.Input, usage.Output, usage.Total, cost)
+}
+
+func (PlainRenderer) History(conversation *Conversation, flags map[string][]Annotation) {
+	for i, msg := range conversation.Messages {
+		if msg.Role == "system" {
+			continue
+		}
+		labels := ""
+		for _, a := range flags[msg.ID] {
+			labels += " [" + a.Label + "]"
+		}
+		role := msg.Role
+		if msg.Author != "" {
+			role += " (" + msg.Author + ")"
+		}
+		fmt.Printf("%d. %s %s%s: %s\n", i+1, clockTime(msg.Timestamp), role, labels, msg.Content)
+	}
+}
+
+// One JSON event per line on stdout, for programs driving painika
+type JSONRenderer struct {
+	encoder *json.Encoder
+}
+
+func (r JSONRenderer) emit(event string, fields map[string]interface{}) {
+	fields["type"] = event
+	r.encoder.Encode(fields)
+}
+
+func (r JSONRenderer) Interactive() bool {
+	return false
+}
+
+func (r JSONRenderer) Prompt() {
+	r.emit("ready", map[string]interface{}{})
+}
+
+func (r JSONRenderer) Thinking() func() {
+	r.emit("thinking", map[string]interface{}{})
+	return func() {}
+}
+
+func (r JSONRenderer) Reply(messages []Message) {
+	r.emit("reply", map[string]interface{}{"messages": messages})
+}
+
+func (r JSONRenderer) Stream() ReplyStream {
+	return nil
+}
+
+func (r JSONRenderer) Notice(text string) {
+	r.emit("notice", map[string]interface{}{"text": text})
+}
+
+func (r JSONRenderer) Banner(info BannerInfo) {
+	r.emit("ready", map[string]interface{}{"model": info.Model, "provider": info.Provider, "server": info.Server})
+}
+
+func (r JSONRenderer) Error(context string, err error) {
+	fields := map[string]interface{}{"context": context, "error": err.Error()}
+	if chatErr, ok := err.(*ChatError); ok {
+		fields["kind"] = chatErr.Kind
+	}
+	r.emit("error", fields)
+}
+
+func (r JSONRenderer) TokenUsage(usage *TokenUsage, cost float64, local bool) {
+	r.emit("tokens", map[string]interface{}{"usage": usage, "cost": cost, "local": local})
+}
+
+func (r JSONRenderer) History(conversation *Conversation, flags map[string][]Annotation) {
+	r.emit("history", map[string]interface{}{"conversation": conversation, "annotations": flags})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Read a streamed turn from /message: chunks of reply text go to onText, and
+// the final event holds what a plain /message response would
+func readChatStream(body io.Reader, onText func(string)) (ChatResponse, error) {
+	scanner := bufio.NewScanner(body)
+	// The final event carries every message of the turn, tool output included
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event ChatStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return ChatResponse{}, fmt.Errorf("invalid server event: %v", err)
+		}
+		if event.Chunk != "" {
+			onText(event.Chunk)
+			continue
+		}
+		return ChatResponse{
+			Success:        event.Success,
+			Messages:       event.Messages,
+			Error:          event.Error,
+			ProviderStatus: event.ProviderStatus,
+		}, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return ChatResponse{}, fmt.Errorf("reply stream interrupted: %v", err)
+	}
+	return ChatResponse{}, fmt.Errorf("the server closed the reply stream before the turn finished")
+}
+
+// Reply text drawn as it streams in. Finished lines are laid out the way
+// formatForTerminal lays out a whole reply; the unfinished line is redrawn in
+// place as text arrives, and tables are held back until they end so they can
+// be collapsed when too wide.
+type markdownStream struct {
+	indent  int      // Width of the "🤖 " prefix
+	pending string   // Text of the unfinished line
+	printed int      // Rows of the unfinished line already printed for good
+	table   []string // Rows of the table being received
+	inCode  bool
+	started bool // Whether the first row, with the 🤖 prefix, is out
+}
+
+func newMarkdownStream(indent int) *markdownStream {
+	return &markdownStream{indent: indent}
+}
+
+// Width available to the reply text
+func (s *markdownStream) width() int {
+	width := termWidth() - 1
+	if width < 20 {
+		width = 20
+	}
+	return width - s.indent
+}
+
+// Add streamed text
+func (s *markdownStream) Write(text string) {
+	s.pending += text
+	for {
+		line, rest, ok := strings.Cut(s.pending, "\n")
+		if !ok {
+			break
+		}
+		s.pending = rest
+		s.commit(line)
+	}
+	s.draw()
+}
+
+// Finish the reply, printing whatever is still held back
+func (s *markdownStream) Close() {
+	if s.pending != "" {
+		s.commit(s.pending)
+		s.pending = ""
+	}
+	s.flushTable()
+}
+
+// Rows a line wraps to, in the current code block state
+func (s *markdownStream) layout(line string) []string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(trimmed, "```"), !s.inCode && strings.HasPrefix(trimmed, "|"):
+		return []string{line}
+	case s.inCode || isDiffLine(line):
+		return hardWrap(line, s.width())
+	default:
+		return wordWrap(renderMath(line), s.width())
+	}
+}
+
+// Lay out a finished line
+func (s *markdownStream) commit(line string) {
+	fmt.Print("\r\033[K")
+	trimmed := strings.TrimSpace(line)
+	isRow := !s.inCode && strings.HasPrefix(trimmed, "|")
+	if !isRow {
+		s.flushTable()
+	}
+
+	switch {
+	case strings.HasPrefix(trimmed, "```"):
+		s.inCode = !s.inCode
+		s.emit(line)
+	case isRow:
+		s.table = append(s.table, line)
+	default:
+		rows := s.layout(line)
+		for _, row := range rows[min(s.printed, len(rows)):] {
+			s.emit(row)
+		}
+	}
+	s.printed = 0
+}
+
+// Redraw the unfinished line. Rows it has already wrapped past can't change
+// as words are added, so they are printed for good.
+func (s *markdownStream) draw() {
+	fmt.Print("\r\033[K")
+	if s.pending == "" {
+		return
+	}
+	rows := s.layout(s.pending)
+	for ; s.printed < len(rows)-1; s.printed++ {
+		s.emit(rows[s.printed])
+	}
+	fmt.Print(s.prefix() + truncateWidth(rows[len(rows)-1], s.width()))
+}
+
+// Print the rows of a finished table
+func (s *markdownStream) flushTable() {
+	if len(s.table) == 0 {
+		return
+	}
+	for _, row := range formatTable(s.table, s.width()) {
+		s.emit(row)
+	}
+	s.table = nil
+}
+
+// The first row starts after "🤖 "; later rows line up under it
+func (s *markdownStream) prefix() string {
+	if !s.started {
+		return "🤖 "
+	}
+	return strings.Repeat(" ", s.indent)
+}
+
+// Print a row for good, with links on paths and URLs outside code
+func (s *markdownStream) emit(row string) {
+	if !s.inCode && !isDiffLine(strings.TrimSpace(row)) {
+		row = linkify(row)
+	}
+	fmt.Println(s.prefix() + row)
+	s.started = true
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Repositories and sessions listed in a report
+const (
+	reportTopRepos    = 5
+	reportMaxSessions = 20
+)
+
+// Commands that run a test suite
+var testCommandPattern = regexp.MustCompile(`\b(go test|(npm|yarn|pnpm|bun) (run )?test|pytest|cargo test|jest|vitest|mvn test|gradle test|make test|rspec|phpunit|dotnet test)\b`)
+
+// Settings for `painika report`
+type ReportArgs struct {
+	Since  time.Time
+	Period string // As given, for the title
+	Output string // File to write, "" for stdout
+}
+
+// Parse `report [--since 7d|2w|24h|2006-01-02] [--output file]`
+func parseReportArgs(args []string, now time.Time) (ReportArgs, error) {
+	report := ReportArgs{Period: "7d"}
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		switch flag {
+		case "--since", "--output", "-o":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return report, fmt.Errorf("%s needs a value", flag)
+				}
+				i++
+				value = args[i]
+			}
+			if flag == "--since" {
+				report.Period = value
+			} else {
+				report.Output = value
+			}
+		default:
+			return report, fmt.Errorf("unknown argument: %s", args[i])
+		}
+	}
+	since, err := parseSince(report.Period, now)
+	if err != nil {
+		return report, err
+	}
+	report.Since = since
+	return report, nil
+}
+
+// Start of a period given as a count of hours, days, or weeks back from
+// now (24h, 7d, 2w), or as a date
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	units := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	if len(value) > 1 {
+		if unit, ok := units[value[len(value)-1:]]; ok {
+			if n, err := strconv.Atoi(value[:len(value)-1]); err == nil && n > 0 {
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (expected e.g. 24h, 7d, 2w, or 2006-01-02)", value)
+}
+
+// Activity in one repository
+type repoActivity struct {
+	Name     string
+	Sessions int
+	Turns    int
+	Files    map[string]bool
+	Tokens   int
+}
+
+// One session's part of the report
+type sessionActivity struct {
+	Title   string
+	Repo    string
+	Updated time.Time
+	Turns   int
+	Files   int
+}
+
+// What a report covers
+type activityDigest struct {
+	Sessions   []sessionActivity
+	Repos      map[string]*repoActivity
+	Files      map[string]bool // Absolute paths
+	TestsFixed []string        // Commands that failed and later passed in the same session
+	Turns      int
+	Tokens     int
+}
+
+// Files a successful tool call changed, relative to its workspace
+func changedPaths(call ToolCall) []string {
+	switch call.Name {
+	case "writeFile", "editFile":
+		if path, _ := call.Parameters["path"].(string); path != "" {
+			return []string{path}
+		}
+	case "apply_patch":
+		if path, _ := call.Parameters["path"].(string); path != "" {
+			return []string{path}
+		}
+		patch, _ := call.Parameters["patch"].(string)
+		var paths []string
+		for _, line := range strings.Split(patch, "\n") {
+			if file, ok := strings.CutPrefix(line, "+++ "); ok {
+				file = strings.TrimPrefix(strings.Fields(file + " ")[0], "b/")
+				if file != "/dev/null" && !containsTag(paths, file) {
+					paths = append(paths, file)
+				}
+			}
+		}
+		return paths
+	case "rename_symbol":
+		if call.Parameters["apply"] == true {
+			if path, _ := call.Parameters["path"].(string); path != "" {
+				return []string{path}
+			}
+		}
+	}
+	return nil
+}
+
+// Exit code a bash tool result reports, and whether it reports one
+func commandExitCode(result ToolResult) (int, bool) {
+	var output commandResult
+	data, err := json.Marshal(result.Result)
+	if err != nil || json.Unmarshal(data, &output) != nil || output.ExitCode == nil {
+		return 0, false
+	}
+	return *output.ExitCode, true
+}
+
+// Add a saved session's activity since a time to the digest
+func (d *activityDigest) add(session *SavedSession, since time.Time) {
+	if session.Conversation == nil {
+		return
+	}
+	results := map[string]ToolResult{}
+	for _, message := range session.Conversation.Messages {
+		for _, result := range message.ToolResults {
+			results[result.ID] = result
+		}
+	}
+
+	repo := filepath.Base(session.Workspace)
+	if session.Workspace == "" {
+		repo = "(unknown)"
+	}
+	activity := sessionActivity{Title: session.Title, Repo: repo}
+	files := map[string]bool{}
+	tokens := 0
+	failing := map[string]bool{}
+	for _, message := range session.Conversation.Messages {
+		when, err := time.Parse(time.RFC3339, message.Timestamp)
+		if err != nil || when.Before(since) {
+			continue
+		}
+		if when.After(activity.Updated) {
+			activity.Updated = when
+		}
+		if message.Role == "user" {
+			activity.Turns++
+		}
+		if message.Tokens != nil {
+			tokens += message.Tokens.Input + message.Tokens.Output
+		}
+		for _, call := range message.ToolCalls {
+			result, ok := results[call.ID]
+			if !ok || result.Error != "" {
+				continue
+			}
+			for _, path := range changedPaths(call) {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(session.Workspace, path)
+				}
+				files[filepath.Clean(path)] = true
+			}
+			command, _ := call.Parameters["command"].(string)
+			command = strings.TrimSpace(command)
+			if call.Name != "bash" || !testCommandPattern.MatchString(command) {
+				continue
+			}
+			if code, ok := commandExitCode(result); ok {
+				if code != 0 {
+					failing[command] = true
+				} else if failing[command] {
+					delete(failing, command)
+					d.TestsFixed = append(d.TestsFixed, command)
+				}
+			}
+		}
+	}
+	if activity.Turns == 0 && len(files) == 0 {
+		return
+	}
+
+	activity.Files = len(files)
+	d.Sessions = append(d.Sessions, activity)
+	d.Turns += activity.Turns
+	d.Tokens += tokens
+	r := d.Repos[repo]
+	if r == nil {
+		r = &repoActivity{Name: repo, Files: map[string]bool{}}
+		d.Repos[repo] = r
+	}
+	r.Sessions++
+	r.Turns += activity.Turns
+	r.Tokens += tokens
+	for path := range files {
+		r.Files[path] = true
+		d.Files[path] = true
+	}
+}
+
+// Collect the activity of every saved session since a time
+func collectActivity(since time.Time) (*activityDigest, error) {
+	headers, err := loadSessions()
+	if err != nil {
+		return nil, err
+	}
+	digest := &activityDigest{Repos: map[string]*repoActivity{}, Files: map[string]bool{}}
+	for _, header := range headers {
+		if updated, err := time.Parse(time.RFC3339, header.UpdatedAt); err == nil && updated.Before(since) {
+			continue
+		}
+		session, err := loadSession(header.ID)
+		if err != nil || session == nil {
+			continue
+		}
+		digest.add(session, since)
+	}
+	sort.Slice(digest.Sessions, func(i, j int) bool {
+		return digest.Sessions[i].Updated.After(digest.Sessions[j].Updated)
+	})
+	return digest, nil
+}
+
+// The digest as markdown
+func formatReport(digest *activityDigest, report ReportArgs, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Painika activity: %s to %s\n\n", report.Since.Local().Format("Jan 2, 2006"), now.Local().Format("Jan 2, 2006"))
+	fmt.Fprintf(&b, "- **Sessions:** %d (%d prompts)\n", len(digest.Sessions), digest.Turns)
+	fmt.Fprintf(&b, "- **Files changed:** %d\n", len(digest.Files))
+	fmt.Fprintf(&b, "- **Test runs fixed:** %d\n", len(digest.TestsFixed))
+	fmt.Fprintf(&b, "- **Tokens:** %s (~$%.2f at Groq rates; local models are free)\n", formatTokens(digest.Tokens), estimateCost(digest.Tokens))
+
+	if len(digest.Repos) > 0 {
+		repos := make([]*repoActivity, 0, len(digest.Repos))
+		for _, repo := range digest.Repos {
+			repos = append(repos, repo)
+		}
+		sort.Slice(repos, func(i, j int) bool {
+			if repos[i].Turns != repos[j].Turns {
+				return repos[i].Turns > repos[j].Turns
+			}
+			return repos[i].Name < repos[j].Name
+		})
+		if len(repos) > reportTopRepos {
+			repos = repos[:reportTopRepos]
+		}
+		b.WriteString("\n## Top repositories\n\n")
+		b.WriteString("| Repository | Sessions | Prompts | Files changed | Est. cost |\n")
+		b.WriteString("|---|---:|---:|---:|---:|\n")
+		for _, repo := range repos {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | $%.2f |\n", repo.Name, repo.Sessions, repo.Turns, len(repo.Files), estimateCost(repo.Tokens))
+		}
+	}
+
+	if len(digest.TestsFixed) > 0 {
+		b.WriteString("\n## Tests fixed\n\n")
+		for _, command := range digest.TestsFixed {
+			fmt.Fprintf(&b, "- `%s`\n", truncateWidth(strings.Join(strings.Fields(command), " "), 80))
+		}
+	}
+
+	if len(digest.Sessions) > 0 {
+		b.WriteString("\n## Sessions\n\n")
+		for i, session := range digest.Sessions {
+			if i == reportMaxSessions {
+				fmt.Fprintf(&b, "- ...and %d more\n", len(digest.Sessions)-reportMaxSessions)
+				break
+			}
+			title := session.Title
+			if title == "" {
+				title = "(no prompt)"
+			}
+			fmt.Fprintf(&b, "- %s, %s: %s (%d prompts, %d files)\n", session.Updated.Local().Format("Mon Jan 2"), session.Repo, truncateWidth(title, 70), session.Turns, session.Files)
+		}
+	} else {
+		b.WriteString("\nNo sessions in this period.\n")
+	}
+	return b.String()
+}
+
+// Handle `painika report [--since 7d] [--output file]`
+func runReport(args []string) {
+	now := time.Now()
+	report, err := parseReportArgs(args, now)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("Usage: painika report [--since 7d|2w|24h|2006-01-02] [--output report.md]")
+		exit(2)
+	}
+	digest, err := collectActivity(report.Since)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	markdown := formatReport(digest, report, now)
+	if report.Output == "" {
+		fmt.Print(markdown)
+		exit(0)
+	}
+	if err := os.WriteFile(report.Output, []byte(markdown), 0644); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("📝 Report since %s written to %s\n", report.Since.Local().Format("Jan 2, 2006 15:04"), report.Output)
+	exit(0)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Samples and provider latencies kept for the trends
+const (
+	monitorSamples   = 60
+	monitorLatencies = 10
+)
+
+// The server's memory counts as leaking once it is this many times, and at
+// least leakMinimum more than, its lowest in the samples kept, and still rising
+const (
+	leakGrowth  = 1.5
+	leakMinimum = 200 * 1024 * 1024
+)
+
+// Read RESOURCE_MONITOR: seconds between samples of the server's CPU and
+// memory, or off
+func resourceMonitorConfig() (time.Duration, error) {
+	value := strings.ToLower(strings.TrimSpace(getEnv("RESOURCE_MONITOR", "10")))
+	if value == "off" || value == "0" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 1 {
+		return 0, fmt.Errorf("invalid RESOURCE_MONITOR %q (expected seconds between samples, at least 1, or off)", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// One reading of the server process
+type resourceSample struct {
+	At  time.Time
+	RSS int64         // Resident memory in bytes, -1 when unknown
+	CPU time.Duration // CPU time used so far, -1 when unknown
+}
+
+// Server CPU and memory sampled in the background, and the provider's
+// latency over the last turns, for the indicator before the prompt
+type resourceMonitor struct {
+	mu        sync.Mutex
+	pid       int
+	samples   []resourceSample
+	cpu       float64 // Share of one core between the last two samples, -1 when unknown
+	latencies []time.Duration
+	leaking   bool
+	warned    bool // The leak was reported above the prompt
+}
+
+// Active monitor, nil when RESOURCE_MONITOR is off
+var monitor *resourceMonitor
+
+// Start sampling every interval for the rest of the run
+func startResourceMonitor(interval time.Duration) *resourceMonitor {
+	m := &resourceMonitor{cpu: -1}
+	m.sample()
+	go func() {
+		for range time.Tick(interval) {
+			m.sample()
+		}
+	}()
+	return m
+}
+
+// PID of the server this client started, 0 when it uses another's
+func serverPID() int {
+	if cmd := globalServerCmd; cmd != nil && cmd.Process != nil {
+		return cmd.Process.Pid
+	}
+	return 0
+}
+
+// Take one reading; a restarted server starts the trend over
+func (m *resourceMonitor) sample() {
+	pid := serverPID()
+	var sample resourceSample
+	if pid != 0 {
+		sample = resourceSample{At: time.Now(), CPU: processCPUTime(pid)}
+		sample.RSS, _ = processMemory(pid)
+	}
+
+	m.mu.Lock()
+	if pid != m.pid {
+		m.pid = pid
+		m.samples = nil
+		m.cpu = -1
+		m.leaking = false
+		m.warned = false
+	}
+	if pid == 0 || sample.RSS < 0 {
+		m.mu.Unlock()
+		return
+	}
+	if n := len(m.samples); n > 0 {
+		last := m.samples[n-1]
+		if elapsed := sample.At.Sub(last.At); last.CPU >= 0 && sample.CPU >= 0 && elapsed > 0 {
+			m.cpu = 100 * float64(sample.CPU-last.CPU) / float64(elapsed)
+		}
+	}
+	m.samples = append(m.samples, sample)
+	if len(m.samples) > monitorSamples {
+		m.samples = m.samples[len(m.samples)-monitorSamples:]
+	}
+	m.leaking = m.growing()
+	warn := m.leaking && !m.warned
+	m.warned = m.warned || m.leaking
+	low := m.lowest()
+	m.mu.Unlock()
+
+	if warn {
+		printAbovePrompt(fmt.Sprintf("⚠️  The server's memory keeps growing: %s, up from %s\n💡 /debug server shows the trend; /debug restart starts a fresh server with this conversation\n",
+			formatSize(sample.RSS), formatSize(low.RSS)))
+	}
+}
+
+// Sample with the lowest memory of those kept
+func (m *resourceMonitor) lowest() resourceSample {
+	low := m.samples[0]
+	for _, sample := range m.samples {
+		if sample.RSS < low.RSS {
+			low = sample
+		}
+	}
+	return low
+}
+
+// Whether memory is well above its low point and rose over the last
+// three samples
+func (m *resourceMonitor) growing() bool {
+	n := len(m.samples)
+	if n < 4 {
+		return false
+	}
+	low := m.lowest()
+	current := m.samples[n-1].RSS
+	for i := n - 3; i < n; i++ {
+		if m.samples[i].RSS < m.samples[i-1].RSS {
+			return false
+		}
+	}
+	return float64(current) >= leakGrowth*float64(low.RSS) && current-low.RSS >= leakMinimum
+}
+
+// Note how long the provider took for each request of a turn
+func (m *resourceMonitor) RecordLatency(messages []Message) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range messages {
+		if msg.Role != "assistant" || msg.Timing == nil || msg.Timing.EndTime <= msg.Timing.StartTime {
+			continue
+		}
+		m.latencies = append(m.latencies, time.Duration(msg.Timing.EndTime-msg.Timing.StartTime)*time.Millisecond)
+	}
+	if len(m.latencies) > monitorLatencies {
+		m.latencies = m.latencies[len(m.latencies)-monitorLatencies:]
+	}
+}
+
+// Last latency, and an arrow comparing it with the average of the ones
+// before: ↑ slower, ↓ faster, → about the same
+func (m *resourceMonitor) latencyTrend() (time.Duration, string) {
+	n := len(m.latencies)
+	if n == 0 {
+		return 0, ""
+	}
+	last := m.latencies[n-1]
+	if n == 1 {
+		return last, ""
+	}
+	var total time.Duration
+	for _, latency := range m.latencies[:n-1] {
+		total += latency
+	}
+	average := total / time.Duration(n-1)
+	switch {
+	case float64(last) > 1.25*float64(average):
+		return last, "↑"
+	case float64(last) < 0.8*float64(average):
+		return last, "↓"
+	}
+	return last, "→"
+}
+
+// A latency like "850ms" or "2.4s"
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// Short status shown before the prompt, like "(server 212.4MB 3%, 1.2s↑) ";
+// "" before anything has been measured
+func (m *resourceMonitor) Indicator() string {
+	if m == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var parts []string
+	if n := len(m.samples); n > 0 {
+		server := "server " + formatSize(m.samples[n-1].RSS)
+		if m.cpu >= 0 {
+			server += fmt.Sprintf(" %.0f%%", m.cpu)
+		}
+		if m.leaking {
+			server = "⚠️ " + server + "↑"
+		}
+		parts = append(parts, server)
+	}
+	if last, arrow := m.latencyTrend(); last > 0 {
+		parts = append(parts, formatLatency(last)+arrow)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(parts, ", ") + ") "
+}
+
+// The trends behind the indicator, for /debug server
+func (m *resourceMonitor) Report() string {
+	if m == nil {
+		return "📈 Resource monitor is off (RESOURCE_MONITOR=off)\n"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("📈 Server\n")
+	if n := len(m.samples); n > 0 {
+		first, last := m.samples[0], m.samples[n-1]
+		low := m.lowest()
+		fmt.Fprintf(&b, "   Memory:      %s now, lowest %s, first sampled at %s %s ago\n",
+			formatSize(last.RSS), formatSize(low.RSS), formatSize(first.RSS), last.At.Sub(first.At).Round(time.Second))
+		if m.cpu >= 0 {
+			fmt.Fprintf(&b, "   CPU:         %.1f%% of a core since the last sample\n", m.cpu)
+		}
+		recent := m.samples
+		if len(recent) > 12 {
+			recent = recent[len(recent)-12:]
+		}
+		var trend []string
+		for _, sample := range recent {
+			trend = append(trend, formatSize(sample.RSS))
+		}
+		fmt.Fprintf(&b, "   Samples:     %s (oldest first)\n", strings.Join(trend, " "))
+		if m.leaking {
+			b.WriteString("   ⚠️  Memory keeps growing; /debug restart starts a fresh server with this conversation\n")
+		}
+	} else {
+		b.WriteString("   Not sampled: the server was not started by this client\n")
+	}
+
+	if len(m.latencies) > 0 {
+		var latencies []string
+		for _, latency := range m.latencies {
+			latencies = append(latencies, formatLatency(latency))
+		}
+		last, arrow := m.latencyTrend()
+		fmt.Fprintf(&b, "   Latency:     %s%s last; %s (oldest first)\n", formatLatency(last), arrow, strings.Join(latencies, " "))
+	}
+	return b.String()
+}
+
+// Start a fresh server and carry the conversation over, for /debug restart
+func restartServer(client *Client) {
+	if globalServerCmd == nil {
+		fmt.Println("❌ The server was not started by this client; restart it where it runs")
+		fmt.Println()
+		return
+	}
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Failed to save the conversation: %v\n\n", err)
+		return
+	}
+	stopServer()
+	if err := resumeServer(client, conversation); err != nil {
+		fmt.Printf("❌ Failed to restart the server: %v\n", err)
+		fmt.Println("💡 Restart painika to continue")
+		fmt.Println()
+		return
+	}
+	if monitor != nil {
+		monitor.sample()
+	}
+	fmt.Printf("✅ Server restarted with %d message(s)\n\n", len(conversation.Messages))
+}
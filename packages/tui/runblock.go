@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Info strings of code blocks that hold shell commands
+var shellLangs = []string{"sh", "bash", "zsh", "shell", "console", "shell-session"}
+
+// Output of the bash tool
+type commandResult struct {
+	Output   string `json:"output"`
+	Error    string `json:"error"`
+	ExitCode *int   `json:"exitCode"`
+	Note     string `json:"note"`
+}
+
+// Shell code blocks from a response, in order
+func shellBlocks(content string) []string {
+	var commands []string
+	for _, block := range extractCodeBlocks(content) {
+		if containsTag(shellLangs, strings.ToLower(block.Lang)) {
+			if command := shellCommand(block.Code); command != "" {
+				commands = append(commands, command)
+			}
+		}
+	}
+	return commands
+}
+
+// Commands of a block; in a transcript with "$ " prompts, only the prompted
+// lines are commands and the rest is their output
+func shellCommand(code string) string {
+	lines := strings.Split(strings.TrimSpace(code), "\n")
+	var prompted []string
+	for _, line := range lines {
+		if command, ok := strings.CutPrefix(strings.TrimSpace(line), "$ "); ok {
+			prompted = append(prompted, command)
+		}
+	}
+	if len(prompted) > 0 {
+		return strings.Join(prompted, "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Run a tool for the user; the server asks for approval as it would for the AI
+func (c *Client) RunTool(name string, params map[string]interface{}) (*ToolExecution, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{"name": name, "params": params})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Post(c.config.ServerURL+"/tool", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ToolRunResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+	return result.Execution, nil
+}
+
+// Handle /run [n]: list the shell blocks of the last response, or run the
+// nth through the approval policy and add its output to the conversation
+func runShellBlock(client *Client, args string) {
+	content, err := lastAssistantMessage(client)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	commands := shellBlocks(content)
+	if len(commands) == 0 {
+		fmt.Println("❌ The last response has no shell code block to run")
+		fmt.Println()
+		return
+	}
+
+	if args == "" {
+		fmt.Println("🐚 Shell blocks in the last response:")
+		for i, command := range commands {
+			first, _, multiline := strings.Cut(command, "\n")
+			if multiline {
+				first += " ..."
+			}
+			fmt.Printf("   %d. $ %s\n", i+1, first)
+		}
+		fmt.Println("💡 Run one with /run <n>")
+		fmt.Println()
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	if err != nil || n < 1 || n > len(commands) {
+		fmt.Printf("Usage: /run <n>  (1-%d, or /run to list them)\n\n", len(commands))
+		return
+	}
+	command := commands[n-1]
+
+	fmt.Printf("▶️  Running block %d:\n", n)
+	for _, line := range strings.Split(command, "\n") {
+		fmt.Printf("   $ %s\n", line)
+	}
+	stopApprovals := watchApprovals(client)
+	execution, err := client.RunTool("bash", map[string]interface{}{"command": command})
+	stopApprovals()
+	if err != nil {
+		fmt.Printf("❌ Not run: %v\n\n", err)
+		return
+	}
+	if execution.Error != "" {
+		fmt.Printf("❌ %s\n\n", execution.Error)
+		return
+	}
+
+	var result commandResult
+	if data, err := json.Marshal(execution.Output); err == nil {
+		json.Unmarshal(data, &result)
+	}
+	for _, text := range []string{result.Output, result.Error} {
+		if text != "" {
+			fmt.Println(text)
+		}
+	}
+	if result.Note != "" {
+		fmt.Printf("💡 %s\n", result.Note)
+	}
+	switch {
+	case result.ExitCode == nil:
+	case *result.ExitCode == 0:
+		fmt.Println("✅ Exited with 0")
+	default:
+		fmt.Printf("❌ Exited with %d\n", *result.ExitCode)
+	}
+	fmt.Println("📎 The command and its output are in the conversation for your next message")
+	fmt.Println()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defines the Bun APIs the bundle uses, then runs it, under Node.js or Deno
+//
+//go:embed bun-compat.mjs
+var bunCompat string
+
+// JavaScript runtime that runs the server bundle
+type serverRuntime struct {
+	Name    string // "bun", "node", or "deno"
+	Path    string
+	Version string
+}
+
+// Runtimes in order of preference, with the oldest major version that runs
+// the bundle: Node.js needs Readable.toWeb and async-iterable web streams,
+// Deno the Node.js process global
+var serverRuntimes = []struct {
+	Name     string
+	MinMajor int
+}{
+	{"bun", 1},
+	{"node", 20},
+	{"deno", 2},
+}
+
+var runtimeVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Check that a runtime is installed and new enough
+func probeRuntime(name string, minMajor int) (serverRuntime, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return serverRuntime{}, fmt.Errorf("%s is not installed", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return serverRuntime{}, fmt.Errorf("%s --version failed: %v", name, err)
+	}
+	match := runtimeVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return serverRuntime{}, fmt.Errorf("could not read the %s version from %q", name, strings.TrimSpace(string(output)))
+	}
+	if major, _ := strconv.Atoi(match[1]); major < minMajor {
+		return serverRuntime{}, fmt.Errorf("%s %s is too old (need %d or later)", name, match[0], minMajor)
+	}
+	return serverRuntime{Name: name, Path: path, Version: match[0]}, nil
+}
+
+// Pick the runtime: SERVER_RUNTIME if set, otherwise the first one installed
+func detectServerRuntime() (serverRuntime, error) {
+	wanted := strings.ToLower(getEnv("SERVER_RUNTIME", "auto"))
+	var problems []string
+	for _, candidate := range serverRuntimes {
+		if wanted != "auto" && wanted != candidate.Name {
+			continue
+		}
+		js, err := probeRuntime(candidate.Name, candidate.MinMajor)
+		if err == nil {
+			return js, nil
+		}
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) == 0 {
+		return serverRuntime{}, fmt.Errorf("unknown SERVER_RUNTIME %q (expected bun, node, deno, or auto)", wanted)
+	}
+	return serverRuntime{}, fmt.Errorf("no JavaScript runtime for the server (%s); install Bun (https://bun.sh), Node.js 20+, or Deno 2+",
+		strings.Join(problems, "; "))
+}
+
+// Detected once per run; every server started uses the same runtime
+var findServerRuntime = sync.OnceValues(detectServerRuntime)
+
+// Command that runs the server bundle with the detected runtime
+func serverCommand(bundlePath string) (*exec.Cmd, error) {
+	js, err := findServerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	if js.Name == "bun" {
+		return exec.Command(js.Path, "run", bundlePath), nil
+	}
+
+	compatPath, err := extractCached("bun-compat", ".mjs", bunCompat)
+	if err != nil {
+		return nil, err
+	}
+	if js.Name == "deno" {
+		return exec.Command(js.Path, "run", "--allow-all", compatPath, bundlePath), nil
+	}
+	return exec.Command(js.Path, compatPath, bundlePath), nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secrets (GROQ_API_KEY, GIST_TOKEN, the sync passphrase and credentials)
+// may be given as a reference to where they are kept instead of the secret
+// itself, and are read from there when first needed:
+//
+//	vault:<path>#<field>          HashiCorp Vault, KV version 1 or 2
+//	op://<vault>/<item>/<field>   1Password, through `op read`
+//	exec:<command>                The standard output of any command
+
+// Longest a secret manager may take, including a sign-in prompt
+const secretTimeout = 60 * time.Second
+
+var (
+	secretsMu sync.Mutex
+	secrets   = map[string]string{} // Resolved secrets by reference, for this run only
+)
+
+// Read a secret from the environment, following a reference if it is one
+func getSecret(key string) (string, error) {
+	value, err := resolveSecret(getEnv(key, ""))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", key, err)
+	}
+	return value, nil
+}
+
+// Whether a value points at a secret manager rather than being the secret
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, "vault:") || strings.HasPrefix(value, "op://") || strings.HasPrefix(value, "exec:")
+}
+
+// The secret a value refers to; values that aren't references are secrets
+// already and come back as they are
+func resolveSecret(value string) (string, error) {
+	if !isSecretRef(value) {
+		return value, nil
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if secret, ok := secrets[value]; ok {
+		return secret, nil
+	}
+
+	var secret string
+	var err error
+	switch {
+	case strings.HasPrefix(value, "vault:"):
+		secret, err = vaultSecret(strings.TrimPrefix(value, "vault:"))
+	case strings.HasPrefix(value, "op://"):
+		secret, err = commandSecret("op", "read", "--no-newline", value)
+	default:
+		command := strings.TrimSpace(strings.TrimPrefix(value, "exec:"))
+		if runtime.GOOS == "windows" {
+			secret, err = commandSecret("cmd", "/C", command)
+		} else {
+			secret, err = commandSecret("sh", "-c", command)
+		}
+	}
+	if err == nil && secret == "" {
+		err = fmt.Errorf("%s is empty", value)
+	}
+	if err != nil {
+		return "", err
+	}
+	secrets[value] = secret
+	return secret, nil
+}
+
+// Standard output of a secret manager's command, without the final newline.
+// The terminal stays attached so the command can ask the user to sign in,
+// until the interactive loop's reader owns stdin: from then on the command
+// gets no input rather than competing with the line editor for keystrokes.
+func commandSecret(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s gave no secret within %s", name, secretTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v", name, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// Resolve the references a session may need later (GIST_TOKEN or
+// GITHUB_TOKEN for /gist, the sync settings for the push on quit) while the
+// terminal is still free for a sign-in prompt. Failures are left for when
+// the secret is used, which reports them.
+func preloadSecrets() {
+	for _, key := range []string{"GIST_TOKEN", "GITHUB_TOKEN"} {
+		if isSecretRef(getEnv(key, "")) {
+			getSecret(key)
+		}
+	}
+	if !offlineMode {
+		syncSettings()
+	}
+}
+
+// Token Vault requests are made with: VAULT_TOKEN, or the one `vault login`
+// saved in ~/.vault-token
+func vaultToken() string {
+	if token := getEnv("VAULT_TOKEN", ""); token != "" {
+		return token
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Read one field of a Vault secret, as in vault:secret/data/painika#groq_api_key.
+// KV version 2 paths include /data/; the field is looked up in either layout.
+func vaultSecret(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault reference %q needs a path and a #field", ref)
+	}
+	addr := strings.TrimRight(getEnv("VAULT_ADDR", ""), "/")
+	if addr == "" {
+		return "", fmt.Errorf("set VAULT_ADDR to read vault:%s", ref)
+	}
+	token := vaultToken()
+	if token == "" {
+		return "", fmt.Errorf("set VAULT_TOKEN or run vault login to read vault:%s", ref)
+	}
+
+	req, err := http.NewRequest("GET", addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := getEnv("VAULT_NAMESPACE", ""); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("vault: invalid response (%s)", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: %s %s", resp.Status, strings.Join(result.Errors, "; "))
+	}
+
+	data := result.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+		data = inner
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault: %s has no field %q", path, field)
+	}
+	return value, nil
+}
@@ -1,4 +1,9 @@
 // @bun
+import { randomBytes, timingSafeEqual } from "node:crypto";
+import { existsSync, lstatSync, realpathSync, mkdirSync, writeFileSync, unlinkSync, readFileSync, readdirSync, renameSync, statSync, openSync, readSync, closeSync } from "node:fs";
+import { homedir } from "node:os";
+import path from "node:path";
+import { createServer } from "node:net";
 var __defProp = Object.defineProperty;
 var __export = (target, all) => {
   for (var name in all)
@@ -5444,6 +5449,9 @@ function createConversation() {
     updatedAt: new Date().toISOString()
   };
 }
+function estimateTokens(text) {
+  return Math.ceil(text.length / 4);
+}
 var MessageRole = exports_external.enum(["system", "user", "assistant", "tool"]);
 var ToolCall = exports_external.object({
   id: exports_external.string(),
@@ -5465,7 +5473,13 @@ var Message = exports_external.object({
   tokens: exports_external.object({
     input: exports_external.number().optional(),
     output: exports_external.number().optional()
-  }).optional()
+  }).optional(),
+  timing: exports_external.object({
+    startTime: exports_external.number(),
+    endTime: exports_external.number()
+  }).optional(),
+  author: exports_external.string().optional(),
+  contextTokens: exports_external.number().int().nonnegative().optional()
 });
 var Conversation = exports_external.object({
   id: exports_external.string(),
@@ -5480,6 +5494,770 @@ var Conversation = exports_external.object({
 if (false) {
 }
 
+// src/paths.ts
+var FileAccessPolicy = exports_external.object({
+  root: exports_external.string().default(process.cwd()),
+  followSymlinks: exports_external.enum(["never", "workspace", "always"]).default("workspace"),
+  protectedPaths: exports_external.array(exports_external.string()).default([]),
+  allowProtected: exports_external.array(exports_external.string()).default([])
+});
+var DEFAULT_PROTECTED_PATHS = [
+  ".git/**",
+  "**/.git/**",
+  "**/.env",
+  "**/package-lock.json",
+  "**/yarn.lock",
+  "**/pnpm-lock.yaml",
+  "**/bun.lockb",
+  "**/go.sum",
+  "**/Cargo.lock",
+  "**/poetry.lock",
+  "**/Gemfile.lock",
+  "**/composer.lock"
+];
+var policy = FileAccessPolicy.parse({});
+function setFileAccessPolicy(config) {
+  policy = FileAccessPolicy.parse(config || {});
+}
+function globToRegExp(glob) {
+  let pattern = "";
+  for (let i = 0;i < glob.length; i++) {
+    const ch = glob[i];
+    if (ch === "*" && glob[i + 1] === "*") {
+      if (glob[i + 2] === "/") {
+        pattern += "(?:.*/)?";
+        i += 2;
+      } else {
+        pattern += ".*";
+        i += 1;
+      }
+    } else if (ch === "*") {
+      pattern += "[^/]*";
+    } else if (ch === "?") {
+      pattern += "[^/]";
+    } else {
+      pattern += ch.replace(/[.+^${}()|[\]\\]/g, "\\$&");
+    }
+  }
+  return new RegExp(`^${pattern}$`);
+}
+function matchesAny(relative, globs) {
+  return globs.some((glob) => globToRegExp(glob).test(relative));
+}
+function isInside(root, target) {
+  const relative = path.relative(root, target);
+  return relative === "" || !relative.startsWith("..") && !path.isAbsolute(relative);
+}
+function realpathOfExisting(target) {
+  let current = target;
+  const rest = [];
+  while (!existsSync(current)) {
+    const parent = path.dirname(current);
+    if (parent === current)
+      break;
+    rest.unshift(path.basename(current));
+    current = parent;
+  }
+  return path.join(realpathSync(current), ...rest);
+}
+function hasSymlink(root, target) {
+  let current = target;
+  while (isInside(root, current) && current !== root) {
+    if (existsSync(current) && lstatSync(current).isSymbolicLink()) {
+      return true;
+    }
+    current = path.dirname(current);
+  }
+  return false;
+}
+function resolveToolPath(requested, mode) {
+  const root = path.resolve(policy.root);
+  const absolute = path.resolve(root, requested);
+  if (!isInside(root, absolute)) {
+    throw new Error(`Access denied: ${requested} is outside the workspace root`);
+  }
+  if (policy.followSymlinks === "never" && hasSymlink(root, absolute)) {
+    throw new Error(`Access denied: ${requested} goes through a symlink`);
+  }
+  if (policy.followSymlinks === "workspace") {
+    const real = realpathOfExisting(absolute);
+    if (!isInside(realpathOfExisting(root), real)) {
+      throw new Error(`Access denied: ${requested} links outside the workspace root`);
+    }
+  }
+  if (mode === "write") {
+    const relatives = [path.relative(root, absolute)];
+    const realRoot = realpathOfExisting(root);
+    const real = realpathOfExisting(absolute);
+    if (isInside(realRoot, real)) {
+      relatives.push(path.relative(realRoot, real));
+    }
+    const protectedPaths = [...DEFAULT_PROTECTED_PATHS, ...policy.protectedPaths];
+    const blocked = relatives.map((relative) => relative.split(path.sep).join("/")).some((relative) => matchesAny(relative, protectedPaths) && !matchesAny(relative, policy.allowProtected));
+    if (blocked) {
+      throw new Error(`Access denied: ${requested} is a protected path (allow it with ALLOW_PROTECTED_WRITES)`);
+    }
+  }
+  return absolute;
+}
+
+// src/output.ts
+var OUTPUT_LIMITS = {
+  maxLines: 400,
+  maxChars: 40000,
+  headLines: 40,
+  tailLines: 120,
+  failureLines: 150
+};
+var FAILURE_PATTERN = /\b(error|errors|fail|failed|failure|failing|panic|fatal|exception|traceback|assert(ion)?)\b|^\s*(---|===) FAIL|✗|✘/i;
+function summarizeOutput(text, limits = OUTPUT_LIMITS) {
+  const lines = text.split(`
+`);
+  if (lines.length <= limits.maxLines && text.length <= limits.maxChars) {
+    return { text, truncated: false, totalLines: lines.length };
+  }
+  const head = lines.slice(0, limits.headLines);
+  const tailStart = Math.max(limits.headLines, lines.length - limits.tailLines);
+  const tail = lines.slice(tailStart);
+  const failures = [];
+  let matched = 0;
+  for (let i = limits.headLines;i < tailStart; i++) {
+    if (FAILURE_PATTERN.test(lines[i])) {
+      matched++;
+      if (failures.length < limits.failureLines) {
+        failures.push(`${i + 1}: ${lines[i]}`);
+      }
+    }
+  }
+  const omitted = tailStart - limits.headLines;
+  const parts = [...head];
+  if (failures.length > 0) {
+    parts.push(`... [${omitted} lines omitted; ${matched} failure lines among them${matched > failures.length ? `, first ${failures.length} shown` : ""}] ...`, ...failures, "... [end of failure lines] ...");
+  } else {
+    parts.push(`... [${omitted} lines omitted] ...`);
+  }
+  parts.push(...tail);
+  let summary = parts.join(`
+`);
+  if (summary.length > limits.maxChars) {
+    const half = Math.floor(limits.maxChars / 2);
+    summary = `${summary.slice(0, half)}
+... [${summary.length - limits.maxChars} characters omitted] ...
+${summary.slice(-half)}`;
+  }
+  return { text: summary, truncated: true, totalLines: lines.length };
+}
+function formatSize(bytes) {
+  if (bytes < 1024)
+    return `${bytes} bytes`;
+  if (bytes < 1024 * 1024)
+    return `${Math.round(bytes / 1024)} KB`;
+  return `${Math.round(bytes / (1024 * 1024) * 10) / 10} MB`;
+}
+async function readCapped(stream, limit, signal) {
+  const reader = stream.getReader();
+  const cancel = () => {
+    reader.cancel().catch(() => {});
+  };
+  signal.addEventListener("abort", cancel);
+  const half = (limit ?? Infinity) / 2;
+  const head = [];
+  const tail = [];
+  let headBytes = 0;
+  let tailBytes = 0;
+  let total = 0;
+  try {
+    while (true) {
+      const { done, value } = await reader.read();
+      if (done)
+        break;
+      total += value.length;
+      let rest = value;
+      if (headBytes < half) {
+        const taken = rest.subarray(0, half - headBytes);
+        head.push(taken);
+        headBytes += taken.length;
+        rest = rest.subarray(taken.length);
+      }
+      if (rest.length === 0)
+        continue;
+      tail.push(rest);
+      tailBytes += rest.length;
+      while (tailBytes > half) {
+        const extra = tailBytes - half;
+        if (tail[0].length <= extra) {
+          tailBytes -= tail.shift().length;
+        } else {
+          tail[0] = tail[0].subarray(extra);
+          tailBytes -= extra;
+        }
+      }
+    }
+  } catch {} finally {
+    signal.removeEventListener("abort", cancel);
+  }
+  const decode = (chunks) => Buffer.concat(chunks).toString("utf8");
+  const dropped = total - headBytes - tailBytes;
+  if (dropped === 0) {
+    return { text: decode([...head, ...tail]), dropped };
+  }
+  return {
+    text: `${decode(head)}
+... [${formatSize(dropped)} omitted: output over the ${formatSize(limit)} limit] ...
+${decode(tail)}`,
+    dropped
+  };
+}
+function jobsDir() {
+  return path.join(homedir(), ".painika", "jobs");
+}
+var keepJobOutputs = true;
+function setJobOutputsKept(kept) {
+  keepJobOutputs = kept;
+}
+function saveJobOutput(command, output, error, exitCode) {
+  if (!keepJobOutputs) {
+    return null;
+  }
+  const id = `${Date.now().toString(36)}-${crypto.randomUUID().slice(0, 6)}`;
+  const dir = jobsDir();
+  mkdirSync(dir, { recursive: true });
+  const header = `$ ${command}
+# exit code: ${exitCode ?? "unknown"}
+`;
+  const body = error ? `${output}
+
+# stderr
+${error}
+` : `${output}
+`;
+  writeFileSync(path.join(dir, `${id}.log`), header + body);
+  return id;
+}
+
+// src/patch.ts
+var MAX_FUZZ = 2;
+function stripPrefix(header) {
+  let file = header.replace(/^(---|\+\+\+)\s+/, "").split("\t")[0].trim();
+  if (file === "/dev/null") {
+    return null;
+  }
+  if (file.startsWith('"') && file.endsWith('"')) {
+    file = file.slice(1, -1);
+  }
+  return file.replace(/^[ab]\//, "");
+}
+function parsePatch(text, defaultPath) {
+  const lines = text.replace(/\r\n/g, `
+`).split(`
+`);
+  const files = [];
+  let file = null;
+  let hunk = null;
+  for (let i = 0;i < lines.length; i++) {
+    const line = lines[i];
+    if (line.startsWith("--- ") && lines[i + 1]?.startsWith("+++ ")) {
+      file = {
+        oldPath: stripPrefix(line),
+        newPath: stripPrefix(lines[i + 1]),
+        hunks: []
+      };
+      files.push(file);
+      hunk = null;
+      i++;
+      continue;
+    }
+    if (line.startsWith("@@")) {
+      if (!file) {
+        if (!defaultPath) {
+          throw new Error("Patch has no ---/+++ file headers; pass path for a bare list of hunks");
+        }
+        file = { oldPath: defaultPath, newPath: defaultPath, hunks: [] };
+        files.push(file);
+      }
+      const match = line.match(/^@@\s+-(\d+)(?:,\d+)?\s+\+\d+(?:,\d+)?\s+@@/);
+      hunk = { header: line, oldStart: match ? Number(match[1]) : 0, ops: [] };
+      file.hunks.push(hunk);
+      continue;
+    }
+    if (!hunk) {
+      continue;
+    }
+    if (line.startsWith("diff ")) {
+      hunk = null;
+    } else if (line.startsWith("\\")) {} else if (line.startsWith("+") || line.startsWith("-") || line.startsWith(" ")) {
+      hunk.ops.push({ kind: line[0], text: line.slice(1) });
+    } else if (line === "") {
+      hunk.ops.push({ kind: " ", text: "" });
+    } else {
+      hunk = null;
+    }
+  }
+  for (const f of files) {
+    for (const h of f.hunks) {
+      while (h.ops.length > 0 && h.ops[h.ops.length - 1].kind === " " && h.ops[h.ops.length - 1].text === "") {
+        h.ops.pop();
+      }
+    }
+    f.hunks = f.hunks.filter((h) => h.ops.length > 0);
+  }
+  if (files.every((f) => f.hunks.length === 0 && f.newPath !== null)) {
+    throw new Error("Patch contains no hunks");
+  }
+  return files;
+}
+function trimContext(ops, fuzz) {
+  let start = 0;
+  let end = ops.length;
+  while (start < fuzz && start < end && ops[start].kind === " ") {
+    start++;
+  }
+  while (ops.length - end < fuzz && end > start && ops[end - 1].kind === " ") {
+    end--;
+  }
+  return { ops: ops.slice(start, end), skipped: start };
+}
+function locate(lines, old, expected, loose) {
+  const same = loose ? (a, b) => a.trimEnd() === b.trimEnd() : (a, b) => a === b;
+  const matchesAt = (at) => old.every((line, k) => same(lines[at + k], line));
+  const last = lines.length - old.length;
+  for (let distance = 0;distance <= Math.max(expected, last - expected); distance++) {
+    for (const at of distance === 0 ? [expected] : [expected - distance, expected + distance]) {
+      if (at >= 0 && at <= last && matchesAt(at)) {
+        return at;
+      }
+    }
+  }
+  return -1;
+}
+function applyHunks(content, hunks) {
+  const trailingNewline = content === "" || content.endsWith(`
+`);
+  const lines = content === "" ? [] : content.replace(/\n$/, "").split(`
+`);
+  const result = { content, applied: 0, fuzzy: [], rejected: [] };
+  let delta = 0;
+  for (const hunk of hunks) {
+    const expected = hunk.oldStart > 0 ? hunk.oldStart - 1 + delta : 0;
+    let placed = false;
+    for (let fuzz = 0;fuzz <= MAX_FUZZ && !placed; fuzz++) {
+      const { ops, skipped } = trimContext(hunk.ops, fuzz);
+      const old = ops.filter((op) => op.kind !== "+").map((op) => op.text);
+      const added = ops.filter((op) => op.kind !== "-").map((op) => op.text);
+      if (old.length === 0) {
+        if (fuzz > 0) {
+          break;
+        }
+        const at = hunk.oldStart > 0 ? Math.min(expected + skipped, lines.length) : lines.length;
+        lines.splice(at, 0, ...added);
+        delta += added.length;
+        placed = true;
+        break;
+      }
+      for (const loose of [false, true]) {
+        const at = locate(lines, old, expected + skipped, loose);
+        if (at < 0) {
+          continue;
+        }
+        lines.splice(at, old.length, ...added);
+        delta += added.length - old.length;
+        placed = true;
+        const notes = [];
+        if (hunk.oldStart > 0 && at !== expected + skipped) {
+          notes.push(`offset ${at - expected - skipped} lines`);
+        }
+        if (fuzz > 0) {
+          notes.push(`fuzz ${fuzz}`);
+        }
+        if (loose) {
+          notes.push("ignoring trailing whitespace");
+        }
+        if (notes.length > 0) {
+          result.fuzzy.push(`${hunk.header} (${notes.join(", ")})`);
+        }
+        break;
+      }
+    }
+    if (placed) {
+      result.applied++;
+      continue;
+    }
+    const first = hunk.ops.find((op) => op.kind !== "+");
+    result.rejected.push({
+      hunk: hunk.header,
+      reason: first ? `context not found in file (first line: ${JSON.stringify(first.text)})` : "hunk is empty"
+    });
+  }
+  result.content = lines.join(`
+`) + (trailingNewline && lines.length > 0 ? `
+` : "");
+  return result;
+}
+function patchedPaths(text) {
+  try {
+    return parsePatch(text).map((f) => f.newPath ?? f.oldPath);
+  } catch {
+    return [];
+  }
+}
+
+// src/search.ts
+var SKIPPED_DIRS = new Set([
+  ".git",
+  "node_modules",
+  "vendor",
+  "third_party",
+  ".venv",
+  "venv",
+  "__pycache__",
+  "dist",
+  "build",
+  "target",
+  ".next",
+  "coverage"
+]);
+var MAX_FILE_BYTES = 1024 * 1024;
+var MAX_FILES = 20000;
+var MAX_MATCHES_PER_FILE = 5;
+var DEFINITION = /\b(?:func|function|def|class|interface|struct|type|enum|const|let|var|fn|impl|module|trait)\b/;
+function listFiles(root, glob) {
+  const files = [];
+  const walk = (dir) => {
+    let entries;
+    try {
+      entries = readdirSync(dir, { withFileTypes: true });
+    } catch {
+      return;
+    }
+    entries.sort((a, b) => a.name.localeCompare(b.name));
+    for (const entry of entries) {
+      if (files.length >= MAX_FILES)
+        return;
+      const full = path.join(dir, entry.name);
+      if (entry.isDirectory()) {
+        if (!SKIPPED_DIRS.has(entry.name))
+          walk(full);
+        continue;
+      }
+      if (!entry.isFile())
+        continue;
+      const relative = path.relative(root, full).split(path.sep).join("/");
+      if (glob && !glob.test(relative) && !glob.test(entry.name))
+        continue;
+      files.push(full);
+    }
+  };
+  walk(root);
+  return files;
+}
+function readText(file) {
+  try {
+    if (statSync(file).size > MAX_FILE_BYTES)
+      return null;
+    const data = readFileSync(file);
+    if (data.subarray(0, 8000).includes(0))
+      return null;
+    return data.toString("utf8");
+  } catch {
+    return null;
+  }
+}
+function escapeRegExp(text) {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+}
+function searchText(dir, root, options) {
+  const flags = options.caseSensitive ? "g" : "gi";
+  let terms;
+  let phrase = null;
+  if (options.regex) {
+    try {
+      terms = [new RegExp(options.query, flags)];
+    } catch (error) {
+      throw new Error(`Invalid regex: ${error instanceof Error ? error.message : String(error)}`);
+    }
+  } else {
+    const words = [...new Set(options.query.split(/\s+/).filter(Boolean))];
+    if (words.length === 0)
+      throw new Error("Empty query");
+    terms = words.map((word) => new RegExp(escapeRegExp(word), flags));
+    if (words.length > 1) {
+      phrase = new RegExp(escapeRegExp(options.query.trim()), flags);
+    }
+  }
+  const count = (pattern, text) => {
+    pattern.lastIndex = 0;
+    let n = 0;
+    let match;
+    while ((match = pattern.exec(text)) && n < 1000) {
+      n++;
+      if (match[0] === "")
+        pattern.lastIndex++;
+    }
+    pattern.lastIndex = 0;
+    return n;
+  };
+  const glob = options.glob ? globToRegExp(options.glob) : null;
+  const files = listFiles(dir, glob);
+  const docs = [];
+  const df = terms.map(() => 0);
+  let totalLength = 0;
+  let searched = 0;
+  for (const file of files) {
+    const text = readText(file);
+    if (text === null)
+      continue;
+    searched++;
+    const length = text.split(/\s+/).length;
+    totalLength += length;
+    const lines = text.split(/\r?\n/);
+    const tf = terms.map(() => 0);
+    const hits = [];
+    lines.forEach((line, index) => {
+      let lineScore = 0;
+      let first = line.length;
+      terms.forEach((term, t) => {
+        const n = count(term, line);
+        if (n > 0) {
+          tf[t] += n;
+          lineScore++;
+          first = Math.min(first, line.search(term));
+        }
+      });
+      if (lineScore === 0)
+        return;
+      if (phrase && count(phrase, line) > 0)
+        lineScore += terms.length;
+      if (DEFINITION.test(line.slice(0, first)))
+        lineScore += 3;
+      hits.push({ index, score: lineScore });
+    });
+    if (hits.length === 0)
+      continue;
+    tf.forEach((n, t) => {
+      if (n > 0)
+        df[t]++;
+    });
+    docs.push({ file, lines, length, tf, hits });
+  }
+  const k1 = 1.2;
+  const b = 0.75;
+  const averageLength = totalLength / Math.max(searched, 1);
+  const ranked = docs.map((doc) => {
+    let score = 0;
+    doc.tf.forEach((n, t) => {
+      if (n === 0)
+        return;
+      const idf = Math.log(1 + (searched - df[t] + 0.5) / (df[t] + 0.5));
+      score += idf * n * (k1 + 1) / (n + k1 * (1 - b + b * doc.length / averageLength));
+    });
+    score += Math.max(...doc.hits.map((hit) => hit.score));
+    return { doc, score };
+  });
+  ranked.sort((x, y) => y.score - x.score);
+  const results = ranked.slice(0, options.maxResults).map(({ doc, score }) => {
+    const best = [...doc.hits].sort((x, y) => y.score - x.score || x.index - y.index).slice(0, MAX_MATCHES_PER_FILE).map((hit) => hit.index).sort((x, y) => x - y);
+    const shown = new Map;
+    for (const index of best) {
+      const from = Math.max(0, index - options.contextLines);
+      const to = Math.min(doc.lines.length - 1, index + options.contextLines);
+      for (let i = from;i <= to; i++) {
+        shown.set(i, shown.get(i) || i === index);
+      }
+    }
+    const out = [];
+    let previous = -1;
+    for (const index of [...shown.keys()].sort((x, y) => x - y)) {
+      if (previous >= 0 && index > previous + 1)
+        out.push("--");
+      const line = doc.lines[index];
+      out.push(`${index + 1}${shown.get(index) ? ":" : "-"} ${line.length > 300 ? line.slice(0, 300) + "..." : line}`);
+      previous = index;
+    }
+    return {
+      path: path.relative(root, doc.file).split(path.sep).join("/"),
+      score: Math.round(score * 100) / 100,
+      matches: doc.hits.length,
+      lines: out.join(`
+`)
+    };
+  });
+  return { results, searchedFiles: searched, matchedFiles: docs.length };
+}
+
+// src/stats.ts
+var LANGUAGES = {
+  ".go": "Go",
+  ".ts": "TypeScript",
+  ".tsx": "TypeScript",
+  ".js": "JavaScript",
+  ".jsx": "JavaScript",
+  ".mjs": "JavaScript",
+  ".cjs": "JavaScript",
+  ".py": "Python",
+  ".rs": "Rust",
+  ".java": "Java",
+  ".kt": "Kotlin",
+  ".rb": "Ruby",
+  ".php": "PHP",
+  ".c": "C",
+  ".h": "C",
+  ".cc": "C++",
+  ".cpp": "C++",
+  ".hpp": "C++",
+  ".cs": "C#",
+  ".swift": "Swift",
+  ".scala": "Scala",
+  ".dart": "Dart",
+  ".lua": "Lua",
+  ".ex": "Elixir",
+  ".exs": "Elixir",
+  ".zig": "Zig",
+  ".vue": "Vue",
+  ".svelte": "Svelte",
+  ".sh": "Shell",
+  ".bash": "Shell",
+  ".sql": "SQL",
+  ".html": "HTML",
+  ".css": "CSS",
+  ".scss": "CSS",
+  ".md": "Markdown",
+  ".json": "JSON",
+  ".yaml": "YAML",
+  ".yml": "YAML",
+  ".toml": "TOML"
+};
+var TEST_FILE = /(_test\.go|\.(test|spec)\.[cm]?[jt]sx?|^test_.*\.py|_test\.py|Tests?\.(java|kt|cs|swift)|_spec\.rb)$/;
+var COVERAGE_FILES = [
+  "coverage",
+  "coverage.out",
+  "cover.out",
+  "coverage.txt",
+  "coverage.xml",
+  "lcov.info",
+  ".coverage",
+  "htmlcov",
+  ".nyc_output"
+];
+var TODO = /\b(TODO|FIXME|HACK|XXX)\b/;
+var MAX_TODOS_LISTED = 10;
+function countDependencies(name, text) {
+  if (name === "package.json" || name === "composer.json") {
+    try {
+      const manifest = JSON.parse(text);
+      const dev = name === "package.json" ? "devDependencies" : "require-dev";
+      const direct = name === "package.json" ? "dependencies" : "require";
+      return {
+        direct: Object.keys(manifest[direct] ?? {}).length,
+        dev: Object.keys(manifest[dev] ?? {}).length
+      };
+    } catch {
+      return null;
+    }
+  }
+  if (name === "go.mod") {
+    const requires = [...text.matchAll(/^\s*(?:require\s+)?[\w.-]+\.[\w./-]+\s+v\S+.*$/gm)];
+    const indirect = requires.filter((line) => line[0].includes("// indirect")).length;
+    return { direct: requires.length - indirect, indirect };
+  }
+  if (/^requirements.*\.txt$/.test(name)) {
+    const lines = text.split("\n").map((line) => line.trim()).filter((line) => line && !line.startsWith("#") && !line.startsWith("-"));
+    return { direct: lines.length };
+  }
+  if (name === "Cargo.toml" || name === "pyproject.toml") {
+    const count = (table) => {
+      let n = 0;
+      let inside = false;
+      for (const line of text.split("\n")) {
+        const trimmed = line.trim();
+        if (trimmed.startsWith("[")) {
+          inside = table.test(trimmed);
+          continue;
+        }
+        if (inside && /^[\w"'-]+\s*=/.test(trimmed))
+          n++;
+      }
+      return n;
+    };
+    if (name === "Cargo.toml") {
+      return {
+        direct: count(/^\[(dependencies|build-dependencies)\]$/),
+        dev: count(/^\[dev-dependencies\]$/)
+      };
+    }
+    const list = text.match(/^dependencies\s*=\s*\[([\s\S]*?)\]/m);
+    const listed = list ? (list[1].match(/["'][^"']+["']/g) ?? []).length : 0;
+    return { direct: listed + count(/^\[tool\.poetry\.dependencies\]$/) };
+  }
+  if (name === "Gemfile") {
+    return { direct: (text.match(/^\s*gem\s+["']/gm) ?? []).length };
+  }
+  return null;
+}
+function projectStats(dir, root, top) {
+  const files = listFiles(dir, null);
+  const relative = (file) => path.relative(root, file).split(path.sep).join("/");
+  const languages = new Map;
+  const todos = [];
+  let todoCount = 0;
+  let testFiles = 0;
+  const sizes = [];
+  const dependencies = [];
+  for (const file of files) {
+    const name = path.basename(file);
+    let size = 0;
+    try {
+      size = statSync(file).size;
+    } catch {
+      continue;
+    }
+    if (TEST_FILE.test(name))
+      testFiles++;
+    const text = readText(file);
+    let lines;
+    if (text !== null) {
+      const all = text.split(/\r?\n/);
+      lines = all.filter((line) => line.trim() !== "").length;
+      const language = LANGUAGES[path.extname(name).toLowerCase()];
+      if (language) {
+        const entry = languages.get(language) ?? { files: 0, lines: 0 };
+        entry.files++;
+        entry.lines += lines;
+        languages.set(language, entry);
+      }
+      all.forEach((line, index) => {
+        if (!TODO.test(line))
+          return;
+        todoCount++;
+        if (todos.length < MAX_TODOS_LISTED) {
+          todos.push(`${relative(file)}:${index + 1}: ${line.trim().slice(0, 120)}`);
+        }
+      });
+      const declared = countDependencies(name, text);
+      if (declared) {
+        dependencies.push({ manifest: relative(file), ...declared });
+      }
+    }
+    sizes.push({ file, size, lines });
+  }
+  sizes.sort((a, b) => b.size - a.size);
+  return {
+    files: files.length,
+    languages: [...languages.entries()].map(([language, entry]) => ({ language, ...entry })).sort((a, b) => b.lines - a.lines),
+    todos: { count: todoCount, first: todos },
+    tests: {
+      files: testFiles,
+      coverageReports: COVERAGE_FILES.filter((name) => existsSync(path.join(dir, name)))
+    },
+    largestFiles: sizes.slice(0, top).map(({ file, size, lines }) => ({
+      path: relative(file),
+      size: formatSize(size),
+      lines
+    })),
+    dependencies,
+    note: files.length >= MAX_FILES ? `Only the first ${MAX_FILES} files were counted; pass a subdirectory as path for exact numbers.` : undefined
+  };
+}
+
 // src/tools.ts
 function zodToJsonSchema(schema) {
   if (schema instanceof exports_external.ZodObject) {
@@ -5546,13 +6324,36 @@ var ToolExecution = exports_external.object({
   startTime: exports_external.number(),
   endTime: exports_external.number().optional()
 });
+var ToolLimit = exports_external.object({
+  timeoutMs: exports_external.number().int().positive().optional(),
+  maxOutputBytes: exports_external.number().int().positive().optional()
+});
+var DEFAULT_TOOL_LIMITS = {
+  bash: { timeoutMs: 120000, maxOutputBytes: 200 * 1024 },
+  readFile: { maxOutputBytes: 1024 * 1024 }
+};
 
 class ToolExecutor {
   tools = new Map;
   executions = new Map;
+  limits = {};
   registerTool(tool) {
     this.tools.set(tool.name, tool);
   }
+  unregisterTool(name) {
+    this.tools.delete(name);
+  }
+  setLimits(limits) {
+    this.limits = limits ?? {};
+  }
+  limitFor(name) {
+    const tool = this.tools.get(name);
+    const inherited = tool?.limitsFrom ? this.limitFor(tool.limitsFrom) : {};
+    return { ...inherited, ...DEFAULT_TOOL_LIMITS[name], ...this.limits[name] };
+  }
+  async plannedChanges(name, params) {
+    return await this.tools.get(name)?.plannedChanges?.(params) ?? null;
+  }
   async execute(name, params) {
     const tool = this.tools.get(name);
     if (!tool) {
@@ -5569,7 +6370,7 @@ class ToolExecutor {
     try {
       execution.state = "running";
       const validatedParams = tool.parameters.parse(params);
-      const result = await tool.execute(validatedParams);
+      const result = await tool.execute(validatedParams, this.limitFor(name));
       execution.state = "completed";
       execution.output = result;
     } catch (error) {
@@ -5597,35 +6398,158 @@ class ToolExecutor {
     }));
   }
 }
+var commandEnv = {};
+function setCommandEnv(env) {
+  commandEnv = env ?? {};
+}
+async function runCommand(command, limit, env) {
+  const vars = { ...commandEnv, ...env };
+  const proc = Bun.spawn(["bash", "-c", command], {
+    env: Object.keys(vars).length ? { ...process.env, ...vars } : undefined
+  });
+  const stopped = new AbortController;
+  let timedOut = false;
+  const timer = limit.timeoutMs ? setTimeout(() => {
+    timedOut = true;
+    proc.kill("SIGKILL");
+    stopped.abort();
+  }, limit.timeoutMs) : undefined;
+  const [out, err] = await Promise.all([
+    readCapped(proc.stdout, limit.maxOutputBytes, stopped.signal),
+    readCapped(proc.stderr, limit.maxOutputBytes, stopped.signal)
+  ]);
+  await proc.exited;
+  clearTimeout(timer);
+  const output = out.text.trim();
+  const error = err.text.trim();
+  const notes = [];
+  if (timedOut) {
+    notes.push(`The command was killed after ${limit.timeoutMs / 1000}s, its time limit; the output is what it printed until then. Run long commands in the background with their output redirected to a file, or ask the user to raise the limit.`);
+  }
+  if (out.dropped > 0 || err.dropped > 0) {
+    notes.push(`Output past the ${formatSize(limit.maxOutputBytes)} limit lost its middle, which was not kept; rerun with a narrower command (grep, head, tail) if you need it.`);
+  }
+  const stdout = summarizeOutput(output);
+  const stderr = summarizeOutput(error);
+  if (!stdout.truncated && !stderr.truncated) {
+    return {
+      output,
+      error: error || undefined,
+      exitCode: proc.exitCode,
+      timedOut: timedOut || undefined,
+      note: notes.length > 0 ? notes.join(" ") : undefined
+    };
+  }
+  const jobId = saveJobOutput(command, output, error, proc.exitCode);
+  notes.push(jobId ? `Output was summarized (head, tail, and failure lines kept). The user can view the full output with /job output ${jobId}; rerun with a narrower command (grep, head, tail) if you need more.` : "Output was summarized (head, tail, and failure lines kept) and the full output was not kept; rerun with a narrower command (grep, head, tail) if you need more.");
+  return {
+    output: stdout.text,
+    error: stderr.text || undefined,
+    exitCode: proc.exitCode,
+    timedOut: timedOut || undefined,
+    truncated: true,
+    totalLines: stdout.totalLines,
+    jobId: jobId ?? undefined,
+    note: notes.join(" ")
+  };
+}
 var bashTool = {
   name: "bash",
   description: "Execute bash commands",
   parameters: exports_external.object({
     command: exports_external.string()
   }),
-  execute: async (params) => {
-    const proc = Bun.spawn(["bash", "-c", params.command]);
-    const output = await new Response(proc.stdout).text();
-    const error = await new Response(proc.stderr).text();
-    return {
-      output: output.trim(),
-      error: error.trim() || undefined,
-      exitCode: proc.exitCode
-    };
-  }
+  execute: async (params, limit) => runCommand(params.command, limit)
 };
+var CustomTool = exports_external.object({
+  name: exports_external.string().regex(/^[A-Za-z_][A-Za-z0-9_-]*$/),
+  description: exports_external.string(),
+  command: exports_external.string(),
+  parameters: exports_external.record(exports_external.string()).default({})
+});
+function createCustomTool(definition) {
+  const { name, command, parameters } = CustomTool.parse(definition);
+  const params = Object.keys(parameters);
+  const described = params.length ? `${definition.description} (parameters: ${params.map((p) => `${p}: ${parameters[p]}`).join("; ")})` : definition.description;
+  return {
+    name,
+    description: described,
+    parameters: exports_external.object(Object.fromEntries(params.map((p) => [p, exports_external.string()]))),
+    execute: async (values, limit) => runCommand(command, limit, values),
+    limitsFrom: "bash"
+  };
+}
+async function renamePlan(binary, params) {
+  const proc = Bun.spawn([binary, "rename", "--json", "--", params.symbol, params.new_name, params.path ?? "."], { env: { ...process.env, ...commandEnv }, stderr: "ignore" });
+  const output = (await new Response(proc.stdout).text()).trim();
+  await proc.exited;
+  const last = output.split("\n").pop() ?? "";
+  if (proc.exitCode !== 0 || !last.startsWith("{")) {
+    throw new Error(output || `painika rename exited with ${proc.exitCode}`);
+  }
+  const { files } = JSON.parse(last);
+  return new Map(Object.entries(files));
+}
+function createRenameSymbolTool(binary) {
+  return {
+    name: "rename_symbol",
+    description: "Rename a symbol (function, type, variable, ...) everywhere in the workspace or under a path. Call it without apply first to get every line it changes, then again with apply: true. Prefer it over editing each file for mechanical renames",
+    parameters: exports_external.object({
+      symbol: exports_external.string(),
+      new_name: exports_external.string(),
+      path: exports_external.string().optional(),
+      apply: exports_external.boolean().default(false)
+    }),
+    execute: async (params, limit) => {
+      const path = params.path ?? ".";
+      resolveToolPath(path, params.apply ? "write" : "read");
+      if (params.apply) {
+        for (const file of (await renamePlan(binary, params)).keys()) {
+          resolveToolPath(file, "write");
+        }
+      }
+      return runCommand(`"$RENAME_BINARY" rename $RENAME_APPLY -- "$RENAME_SYMBOL" "$RENAME_TO" "$RENAME_PATH"`, limit, {
+        RENAME_BINARY: binary,
+        RENAME_SYMBOL: params.symbol,
+        RENAME_TO: params.new_name,
+        RENAME_PATH: path,
+        RENAME_APPLY: params.apply ? "--apply" : ""
+      });
+    },
+    plannedChanges: async (params) => params.apply ? renamePlan(binary, params).catch(() => null) : null,
+    limitsFrom: "bash"
+  };
+}
 var readFileTool = {
   name: "readFile",
   description: "Read a file from the filesystem",
   parameters: exports_external.object({
     path: exports_external.string()
   }),
-  execute: async (params) => {
-    const file = Bun.file(params.path);
+  execute: async (params, limit) => {
+    const target = resolveToolPath(params.path, "read");
+    const file = Bun.file(target);
     const exists = await file.exists();
     if (!exists) {
       throw new Error(`File not found: ${params.path}`);
     }
+    const max = limit.maxOutputBytes;
+    if (max && file.size > max) {
+      const buffer = Buffer.alloc(max);
+      const fd = openSync(target, "r");
+      let read;
+      try {
+        read = readSync(fd, buffer, 0, max, 0);
+      } finally {
+        closeSync(fd);
+      }
+      return {
+        content: buffer.subarray(0, read).toString("utf8"),
+        size: file.size,
+        truncated: true,
+        note: `The file is ${formatSize(file.size)}; only its first ${formatSize(max)} (the readFile limit) is shown. Read further with bash (sed -n '2000,2400p' <file>) or find the part you need with search_text.`
+      };
+    }
     const content = await file.text();
     return {
       content,
@@ -5641,7 +6565,7 @@ var writeFileTool = {
     content: exports_external.string()
   }),
   execute: async (params) => {
-    await Bun.write(params.path, params.content);
+    await Bun.write(resolveToolPath(params.path, "write"), params.content);
     return {
       path: params.path,
       size: params.content.length
@@ -5657,7 +6581,8 @@ var editFileTool = {
     newContent: exports_external.string()
   }),
   execute: async (params) => {
-    const file = Bun.file(params.path);
+    const target = resolveToolPath(params.path, "write");
+    const file = Bun.file(target);
     const exists = await file.exists();
     if (!exists) {
       throw new Error(`File not found: ${params.path}`);
@@ -5667,13 +6592,63 @@ var editFileTool = {
       throw new Error(`Content not found in file: ${params.oldContent}`);
     }
     const newContent = content.replace(params.oldContent, params.newContent);
-    await Bun.write(params.path, newContent);
+    await Bun.write(target, newContent);
     return {
       path: params.path,
       size: newContent.length
     };
   }
 };
+var applyPatchTool = {
+  name: "apply_patch",
+  description: "Edit files by applying a unified diff (---/+++ headers, @@ hunks with a few context lines). Prefer this over writeFile for small changes to existing files. Hunks that do not match are rejected and reported; re-read the file and resend only those.",
+  parameters: exports_external.object({
+    patch: exports_external.string(),
+    path: exports_external.string().optional()
+  }),
+  execute: async (params) => {
+    const files = parsePatch(params.patch, params.path);
+    const results = [];
+    let rejected = 0;
+    for (const patch of files) {
+      const name = patch.newPath ?? patch.oldPath;
+      const source = patch.oldPath ? resolveToolPath(patch.oldPath, "write") : null;
+      const target = patch.newPath ? resolveToolPath(patch.newPath, "write") : null;
+      let content = "";
+      if (source) {
+        const file = Bun.file(source);
+        if (!await file.exists()) {
+          throw new Error(`File not found: ${patch.oldPath}`);
+        }
+        content = await file.text();
+      }
+      const result = applyHunks(content, patch.hunks);
+      rejected += result.rejected.length;
+      if (target && (result.applied > 0 || patch.hunks.length === 0)) {
+        await Bun.write(target, result.content);
+      }
+      if (source && (!target || source !== target) && result.rejected.length === 0) {
+        unlinkSync(source);
+      }
+      results.push({
+        path: name,
+        created: patch.oldPath === null || undefined,
+        deleted: patch.newPath === null || undefined,
+        applied: result.applied,
+        fuzzy: result.fuzzy.length > 0 ? result.fuzzy : undefined,
+        rejected: result.rejected.length > 0 ? result.rejected : undefined
+      });
+    }
+    if (rejected > 0 && results.every((r) => r.applied === 0)) {
+      throw new Error(`No hunks applied: ${results.flatMap((r) => (r.rejected ?? []).map((h) => `${r.path} ${h.hunk}: ${h.reason}`)).join("; ")}`);
+    }
+    return {
+      files: results,
+      rejected,
+      note: rejected > 0 ? "Rejected hunks were not applied. Read the file again and send a new patch for just those hunks." : undefined
+    };
+  }
+};
 var makeDirTool = {
   name: "makeDir",
   description: "Create a directory (and parent directories if needed)",
@@ -5682,7 +6657,8 @@ var makeDirTool = {
     recursive: exports_external.boolean().default(true)
   }),
   execute: async (params) => {
-    const proc = Bun.spawn(["mkdir", params.recursive ? "-p" : "", params.path].filter(Boolean));
+    const target = resolveToolPath(params.path, "write");
+    const proc = Bun.spawn(["mkdir", params.recursive ? "-p" : "", target].filter(Boolean));
     await proc.exited;
     if (proc.exitCode !== 0) {
       throw new Error(`Failed to create directory: ${params.path}`);
@@ -5700,7 +6676,7 @@ var listFilesTool = {
     path: exports_external.string().default(".")
   }),
   execute: async (params) => {
-    const proc = Bun.spawn(["ls", "-la", params.path]);
+    const proc = Bun.spawn(["ls", "-la", resolveToolPath(params.path, "read")]);
     const output = await new Response(proc.stdout).text();
     return {
       files: output.split("\n").filter((line) => line.trim()),
@@ -5708,14 +6684,141 @@ var listFilesTool = {
     };
   }
 };
+var searchTextTool = {
+  name: "search_text",
+  description: "Search file contents in the workspace, faster and more portable than grep through bash. " + "Returns the best-matching files first (BM25 ranking; definitions and exact phrases rank higher), " + 'each with its best lines as "line: match" and "line- context". ' + "query: words to find (any of them) or, with regex, a JavaScript regular expression; " + 'path: directory to search; glob: only files matching, e.g. "*.go" or "src/**/*.ts"; ' + "caseSensitive, contextLines (0-10), maxResults (files, 1-100). Skips vendored, build, binary, and large files.",
+  parameters: exports_external.object({
+    query: exports_external.string(),
+    regex: exports_external.boolean().default(false),
+    path: exports_external.string().default("."),
+    glob: exports_external.string().optional(),
+    caseSensitive: exports_external.boolean().default(false),
+    contextLines: exports_external.number().int().min(0).max(10).default(2),
+    maxResults: exports_external.number().int().min(1).max(100).default(20)
+  }),
+  execute: async (params) => {
+    const dir = resolveToolPath(params.path, "read");
+    const { results, searchedFiles, matchedFiles } = searchText(dir, resolveToolPath(".", "read"), params);
+    return {
+      results,
+      searchedFiles,
+      matchedFiles,
+      note: matchedFiles > results.length ? `Showing the best ${results.length} of ${matchedFiles} matching files; narrow the query, path, or glob to see others.` : undefined
+    };
+  }
+};
+var projectStatsTool = {
+  name: "project_stats",
+  description: "Overview of the workspace in one call, instead of many shell commands: non-blank lines and files by language, " + "TODO/FIXME counts with the first few locations, test files and coverage reports present, the largest files, " + "and dependency counts from package.json, go.mod, Cargo.toml, requirements.txt, pyproject.toml, Gemfile, and composer.json. " + "path: directory to describe; top: how many of the largest files to list (1-50). Skips vendored and build directories.",
+  parameters: exports_external.object({
+    path: exports_external.string().default("."),
+    top: exports_external.number().int().min(1).max(50).default(10)
+  }),
+  execute: async (params) => {
+    return projectStats(resolveToolPath(params.path, "read"), resolveToolPath(".", "read"), params.top);
+  }
+};
+var rememberTool = {
+  name: "remember",
+  description: "Save a durable fact about the user or project (tooling, conventions, preferences) so it is available in future sessions",
+  parameters: exports_external.object({
+    fact: exports_external.string()
+  }),
+  execute: async (params) => {
+    return {
+      remembered: params.fact
+    };
+  }
+};
 if (false) {
 }
 
+// src/log.ts
+var jsonLogs = process.env.LOG_FORMAT === "json";
+function log(level, icon, message, fields = {}) {
+  const write = level === "warn" || level === "error" ? console.error : console.log;
+  if (jsonLogs) {
+    write(JSON.stringify({
+      time: new Date().toISOString(),
+      level,
+      msg: message,
+      source: "server",
+      ...fields
+    }));
+    return;
+  }
+  write(icon ? `${icon} ${message}` : message);
+}
+
+// src/capabilities.ts
+var ModelCapabilities = exports_external.object({
+  tools: exports_external.boolean(),
+  vision: exports_external.boolean(),
+  streaming: exports_external.boolean(),
+  jsonMode: exports_external.boolean(),
+  toolChoice: exports_external.boolean(),
+  contextTokens: exports_external.number().int().positive()
+});
+var KNOWN_MODELS = [
+  { provider: "groq", prefix: "llama-3.3-70b", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "llama-3.1-8b", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "llama-3.2-11b-vision", capabilities: { vision: true, contextTokens: 8192 } },
+  { provider: "groq", prefix: "llama-3.2-90b-vision", capabilities: { vision: true, contextTokens: 8192 } },
+  { provider: "groq", prefix: "meta-llama/llama-4", capabilities: { vision: true, contextTokens: 131072 } },
+  { provider: "groq", prefix: "llama3-", capabilities: { contextTokens: 8192 } },
+  { provider: "groq", prefix: "gemma2-9b", capabilities: { contextTokens: 8192 } },
+  { provider: "groq", prefix: "mixtral-8x7b", capabilities: { contextTokens: 32768 } },
+  { provider: "groq", prefix: "deepseek-r1-distill", capabilities: { jsonMode: false, contextTokens: 131072 } },
+  { provider: "groq", prefix: "qwen", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "openai/gpt-oss", capabilities: { contextTokens: 131072 } },
+  { provider: "groq", prefix: "moonshotai/kimi-k2", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.2-vision", capabilities: { tools: false, vision: true, contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.1", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.2", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama3.3", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "llama4", capabilities: { vision: true, contextTokens: 131072 } },
+  { provider: "ollama", prefix: "qwen2.5", capabilities: { contextTokens: 32768 } },
+  { provider: "ollama", prefix: "qwen3", capabilities: { contextTokens: 40960 } },
+  { provider: "ollama", prefix: "mistral-nemo", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "mistral", capabilities: { contextTokens: 32768 } },
+  { provider: "ollama", prefix: "command-r", capabilities: { contextTokens: 131072 } },
+  { provider: "ollama", prefix: "deepseek-coder-v2", capabilities: { tools: false, contextTokens: 163840 } },
+  { provider: "ollama", prefix: "deepseek-coder", capabilities: { tools: false, contextTokens: 16384 } },
+  { provider: "ollama", prefix: "codellama", capabilities: { tools: false, contextTokens: 16384 } },
+  { provider: "ollama", prefix: "codegemma", capabilities: { tools: false } },
+  { provider: "ollama", prefix: "gemma", capabilities: { tools: false } },
+  { provider: "ollama", prefix: "phi", capabilities: { tools: false, contextTokens: 4096 } },
+  { provider: "ollama", prefix: "starcoder", capabilities: { tools: false } },
+  { provider: "ollama", prefix: "llava", capabilities: { tools: false, vision: true, contextTokens: 4096 } }
+];
+var PROVIDER_DEFAULTS = {
+  groq: { tools: true, vision: false, streaming: true, jsonMode: true, toolChoice: true, contextTokens: 32768 },
+  ollama: { tools: true, vision: false, streaming: true, jsonMode: true, toolChoice: false, contextTokens: 8192 }
+};
+function capabilitiesFor(provider, model) {
+  const name = model.toLowerCase();
+  const known = KNOWN_MODELS.find((entry) => entry.provider === provider && name.startsWith(entry.prefix));
+  return {
+    ...PROVIDER_DEFAULTS[provider] ?? PROVIDER_DEFAULTS.groq,
+    ...known?.capabilities
+  };
+}
+var TOOLS_UNSUPPORTED = /(does not support|not supported|unsupported)[^.]{0,40}\btool|\btool[^.]{0,40}(not supported|unsupported)/i;
+var JSON_MODE_UNSUPPORTED = /(response_format|json[ _]?(mode|object))[^.]{0,60}(not supported|unsupported|not available)|(does not support|not supported|unsupported)[^.]{0,40}(response_format|json)/i;
+var TOOL_CHOICE_UNSUPPORTED = /tool_choice|parallel_tool_calls/i;
+
 // src/groq.ts
 var GroqConfig = exports_external.object({
   token: exports_external.string(),
   model: exports_external.string().default("llama-3.3-70b-versatile"),
-  baseURL: exports_external.string().default("https://api.groq.com/openai")
+  baseURL: exports_external.string().default("https://api.groq.com/openai"),
+  temperature: exports_external.number().min(0).max(2).default(0.7),
+  provider: exports_external.string().default("groq")
+});
+var RequestShaping = exports_external.object({
+  jsonMode: exports_external.boolean().optional(),
+  toolChoice: exports_external.enum(["auto", "required", "none"]).optional(),
+  parallelToolCalls: exports_external.boolean().optional()
 });
 var GroqResponse = exports_external.object({
   content: exports_external.string(),
@@ -5733,8 +6836,19 @@ var GroqResponse = exports_external.object({
   })).optional()
 });
 
+class ProviderError extends Error {
+  status;
+  constructor(message, status) {
+    super(message);
+    this.status = status;
+    this.name = "ProviderError";
+  }
+}
+
 class GroqClient {
   config;
+  defaultTemperature;
+  learned = {};
   constructor(config) {
     if (!config) {
       throw new Error("GroqConfig is required");
@@ -5743,8 +6857,116 @@ class GroqClient {
       throw new Error("Groq API token is required");
     }
     this.config = GroqConfig.parse(config);
+    this.defaultTemperature = this.config.temperature;
+  }
+  setTemperature(temperature) {
+    this.config.temperature = temperature ?? this.defaultTemperature;
+  }
+  setDefaultTemperature(temperature) {
+    this.defaultTemperature = temperature ?? GroqConfig.shape.temperature.parse(undefined);
+  }
+  setModel(model) {
+    this.config.model = model;
+    this.learned = {};
   }
-  async complete(messages, tools) {
+  capabilities() {
+    return {
+      ...capabilitiesFor(this.config.provider, this.config.model),
+      ...this.learned
+    };
+  }
+  getSettings() {
+    return { model: this.config.model, temperature: this.config.temperature };
+  }
+  async complete(messages, tools, onText, shaping = {}) {
+    const capabilities = this.capabilities();
+    const nativeChoice = capabilities.tools && capabilities.toolChoice;
+    const offered = tools?.length && !(shaping.toolChoice === "none" && !nativeChoice) ? tools : undefined;
+    const shaped = withInstructions(messages, shapingInstructions(shaping, capabilities, !!offered));
+    let response;
+    if (offered && !capabilities.tools) {
+      response = await this.completeWithEmulatedTools(shaped, offered, onText);
+    } else {
+      try {
+        response = await this.request(shaped, offered, onText, shaping);
+      } catch (error) {
+        if (!this.learnUnsupported(error, shaping, !!offered)) {
+          throw error;
+        }
+        return this.complete(messages, tools, onText, shaping);
+      }
+    }
+    if (shaping.parallelToolCalls === false && (response.toolCalls?.length ?? 0) > 1) {
+      response = { ...response, toolCalls: response.toolCalls.slice(0, 1) };
+    }
+    return response;
+  }
+  learnUnsupported(error, shaping, withTools) {
+    if (!(error instanceof ProviderError) || error.status !== 400) {
+      return false;
+    }
+    const capabilities = this.capabilities();
+    const model = this.config.model;
+    if (withTools && capabilities.toolChoice && (shaping.toolChoice !== undefined || shaping.parallelToolCalls !== undefined) && TOOL_CHOICE_UNSUPPORTED.test(error.message)) {
+      log("warn", "", "Model rejected tool_choice; asking for it in the prompt instead", { model });
+      this.learned.toolChoice = false;
+      return true;
+    }
+    if (withTools && capabilities.tools && TOOLS_UNSUPPORTED.test(error.message)) {
+      log("warn", "", "Model rejected tools; describing them in the prompt instead", { model });
+      this.learned.tools = false;
+      return true;
+    }
+    if (shaping.jsonMode && capabilities.jsonMode && JSON_MODE_UNSUPPORTED.test(error.message)) {
+      log("warn", "", "Model rejected JSON mode; asking for JSON in the prompt instead", { model });
+      this.learned.jsonMode = false;
+      return true;
+    }
+    return false;
+  }
+  async completeWithEmulatedTools(messages, tools, onText) {
+    const names = new Map;
+    const converted = messages.map((msg, i) => {
+      if (msg.role === "system" && i === 0) {
+        return { ...msg, content: `${msg.content}
+
+${emulatedToolsPrompt(tools)}` };
+      }
+      if (msg.toolCalls?.length) {
+        const calls = msg.toolCalls.map((call) => {
+          names.set(call.id, call.name);
+          return toolCallBlock(call.name, call.parameters);
+        });
+        return {
+          ...msg,
+          content: [msg.content, ...calls].filter(Boolean).join(`
+`),
+          toolCalls: undefined
+        };
+      }
+      if (msg.role === "tool") {
+        const name = names.get(msg.toolResults?.[0]?.id ?? "") ?? "the tool";
+        return {
+          ...msg,
+          role: "user",
+          content: `Result of ${name}:
+${msg.content}`,
+          toolResults: undefined
+        };
+      }
+      return msg;
+    });
+    const response = await this.request(converted);
+    const { content, toolCalls } = parseEmulatedToolCalls(response.content);
+    if (onText && content) {
+      onText(content);
+    }
+    return { ...response, content, toolCalls };
+  }
+  async request(messages, tools, onText, shaping = {}) {
+    checkOffline(this.config.baseURL);
+    const capabilities = this.capabilities();
+    const stream = !!onText && capabilities.streaming;
     const payload = {
       model: this.config.model,
       messages: messages.map((msg) => {
@@ -5767,14 +6989,31 @@ class GroqClient {
         }
         return groqMsg;
       }),
-      stream: false,
-      temperature: 0.7,
-      max_tokens: 4096
+      stream,
+      temperature: this.config.temperature,
+      max_tokens: MAX_REPLY_TOKENS
     };
     if (tools && tools.length > 0) {
       payload.tools = tools;
       payload.tool_choice = "auto";
+      if (capabilities.toolChoice) {
+        payload.tool_choice = shaping.toolChoice ?? "auto";
+        if (shaping.parallelToolCalls !== undefined) {
+          payload.parallel_tool_calls = shaping.parallelToolCalls;
+        }
+      }
     }
+    if (shaping.jsonMode && capabilities.jsonMode) {
+      payload.response_format = { type: "json_object" };
+    }
+    if (stream) {
+      payload.stream_options = { include_usage: true };
+    }
+    let streamed = false;
+    const emit = (text) => {
+      streamed = true;
+      onText?.(text);
+    };
     const maxRetries = 3;
     let lastError = null;
     for (let attempt = 1;attempt <= maxRetries; attempt++) {
@@ -5790,20 +7029,26 @@ class GroqClient {
         });
         if (!response.ok) {
           const errorText = await response.text();
-          const error = new Error(`Groq API error: ${response.status} ${response.statusText} - ${errorText}`);
+          const error = new ProviderError(`Groq API error: ${response.status} ${response.statusText} - ${errorText}`, response.status);
           if (response.status === 429 || response.status >= 500) {
             lastError = error;
             if (attempt < maxRetries) {
               const delay = Math.pow(2, attempt) * 1000;
-              console.warn(`Attempt ${attempt} failed, retrying in ${delay}ms...`);
+              log("warn", "", `Attempt ${attempt} failed, retrying in ${delay}ms...`, { status: response.status, attempt, delayMs: delay });
               await new Promise((resolve) => setTimeout(resolve, delay));
               continue;
             }
           }
           throw error;
         }
+        if (stream && response.headers.get("content-type")?.includes("text/event-stream")) {
+          return await this.readCompletionStream(response, payload, emit);
+        }
         const data = await response.json();
         const choice = data.choices[0];
+        if (onText && choice?.message?.content) {
+          emit(choice.message.content);
+        }
         return {
           content: choice?.message?.content || "",
           tokens: {
@@ -5814,27 +7059,111 @@ class GroqClient {
         };
       } catch (error) {
         lastError = error instanceof Error ? error : new Error(String(error));
+        const rejected = lastError instanceof ProviderError && lastError.status !== undefined && lastError.status < 500 && lastError.status !== 429;
+        if (streamed || rejected) {
+          throw lastError;
+        }
         if (attempt < maxRetries) {
           const delay = Math.pow(2, attempt) * 1000;
-          console.warn(`Attempt ${attempt} failed, retrying in ${delay}ms...`);
+          log("warn", "", `Attempt ${attempt} failed, retrying in ${delay}ms...`, {
+            attempt,
+            delayMs: delay,
+            error: lastError.message
+          });
           await new Promise((resolve) => setTimeout(resolve, delay));
           continue;
         }
         break;
       }
     }
-    throw new Error(`Failed to complete with Groq after ${maxRetries} attempts: ${lastError?.message || "Unknown error"}`);
+    throw new ProviderError(`Failed to complete with Groq after ${maxRetries} attempts: ${lastError?.message || "Unknown error"}`, lastError instanceof ProviderError ? lastError.status : undefined);
+  }
+  async readCompletionStream(response, payload, emit) {
+    const reader = response.body?.getReader();
+    if (!reader) {
+      throw new Error("Response body is not readable");
+    }
+    const decoder = new TextDecoder;
+    let buffer = "";
+    let content = "";
+    let finished = false;
+    let usage = null;
+    const toolCalls = [];
+    try {
+      read:
+        while (true) {
+          const { done, value } = await reader.read();
+          if (done)
+            break;
+          buffer += decoder.decode(value, { stream: true });
+          const lines = buffer.split(`
+`);
+          buffer = lines.pop() || "";
+          for (const line of lines) {
+            if (!line.startsWith("data:"))
+              continue;
+            const data = line.slice(5).trim();
+            if (data === "[DONE]") {
+              finished = true;
+              break read;
+            }
+            let parsed;
+            try {
+              parsed = JSON.parse(data);
+            } catch {
+              continue;
+            }
+            usage = parsed.usage || parsed.x_groq?.usage || usage;
+            const choice = parsed.choices?.[0];
+            if (choice?.finish_reason)
+              finished = true;
+            const delta = choice?.delta;
+            if (delta?.content) {
+              content += delta.content;
+              emit(delta.content);
+            }
+            for (const part of delta?.tool_calls || []) {
+              const call = toolCalls[part.index ?? toolCalls.length] ??= {
+                id: "",
+                type: "function",
+                function: { name: "", arguments: "" }
+              };
+              call.id = part.id || call.id;
+              call.function.name += part.function?.name || "";
+              call.function.arguments += part.function?.arguments || "";
+            }
+          }
+        }
+    } catch (error) {
+      throw new ProviderError(`Stream interrupted: ${error instanceof Error ? error.message : String(error)}`);
+    } finally {
+      reader.releaseLock();
+    }
+    if (!finished) {
+      throw new ProviderError("Stream ended before the reply was complete");
+    }
+    const calls = toolCalls.filter(Boolean);
+    return {
+      content,
+      tokens: {
+        input: usage?.prompt_tokens ?? estimateTokens(JSON.stringify(payload.messages)),
+        output: usage?.completion_tokens ?? estimateTokens(content + calls.map((call) => call.function.arguments).join(""))
+      },
+      toolCalls: calls
+    };
   }
   async stream(messages) {
+    const response = await this.openStream(messages.map((msg) => ({ role: msg.role, content: msg.content })));
+    return this.resumableStream(messages, response);
+  }
+  async openStream(messages) {
+    checkOffline(this.config.baseURL);
     const payload = {
       model: this.config.model,
-      messages: messages.map((msg) => ({
-        role: msg.role,
-        content: msg.content
-      })),
+      messages,
       stream: true,
-      temperature: 0.7,
-      max_tokens: 4096
+      temperature: this.config.temperature,
+      max_tokens: MAX_REPLY_TOKENS
     };
     const response = await fetch(`${this.config.baseURL}/v1/chat/completions`, {
       method: "POST",
@@ -5847,9 +7176,53 @@ class GroqClient {
     });
     if (!response.ok) {
       const errorText = await response.text();
-      throw new Error(`Groq API error: ${response.status} ${response.statusText} - ${errorText}`);
+      throw new ProviderError(`Groq API error: ${response.status} ${response.statusText} - ${errorText}`, response.status);
+    }
+    return response;
+  }
+  async* resumableStream(messages, response) {
+    let partial = "";
+    for (let attempt = 1;; attempt++) {
+      let pending = partial === "" ? null : "";
+      const parser = this.parseStream(response);
+      let finished = false;
+      while (true) {
+        const next = await parser.next();
+        if (next.done) {
+          finished = next.value;
+          break;
+        }
+        let chunk = next.value;
+        if (pending !== null) {
+          pending += chunk;
+          if (pending.length <= MAX_RESUME_OVERLAP) {
+            continue;
+          }
+          chunk = stripOverlap(partial, pending);
+          pending = null;
+        }
+        partial += chunk;
+        yield chunk;
+      }
+      if (pending) {
+        const rest = stripOverlap(partial, pending);
+        partial += rest;
+        yield rest;
+      }
+      if (finished) {
+        return;
+      }
+      if (attempt > MAX_STREAM_RESUMES) {
+        throw new ProviderError(`Stream interrupted ${attempt} times; keeping the partial reply`);
+      }
+      const delay = Math.pow(2, attempt) * 1000;
+      log("warn", "", `Stream interrupted, resuming in ${delay}ms...`, {
+        attempt,
+        delayMs: delay
+      });
+      await new Promise((resolve) => setTimeout(resolve, delay));
+      response = await this.openStream(partial === "" ? messages.map((msg) => ({ role: msg.role, content: msg.content })) : continuationMessages(messages, partial));
     }
-    return this.parseStream(response);
   }
   async* parseStream(response) {
     const reader = response.body?.getReader();
@@ -5858,22 +7231,25 @@ class GroqClient {
     }
     const decoder = new TextDecoder;
     let buffer = "";
+    let finishReason = null;
     try {
       while (true) {
         const { done, value } = await reader.read();
         if (done)
           break;
         buffer += decoder.decode(value, { stream: true });
-        const lines = buffer.split("\n");
+        const lines = buffer.split(`
+`);
         buffer = lines.pop() || "";
         for (const line of lines) {
           if (line.startsWith("data")) {
             const data = line.slice(6);
             if (data === "[DONE]")
-              return;
+              return true;
             try {
               const parsed = JSON.parse(data);
               const content = parsed.choices[0]?.delta?.content || "";
+              finishReason = parsed.choices[0]?.finish_reason || finishReason;
               if (content) {
                 yield content;
               }
@@ -5882,35 +7258,624 @@ class GroqClient {
           }
         }
       }
-    } finally {
-      reader.releaseLock();
+    } catch (e) {
+      return false;
+    } finally {
+      reader.releaseLock();
+    }
+    return finishReason !== null;
+  }
+}
+var MAX_STREAM_RESUMES = 3;
+function checkOffline(url) {
+  const allow = process.env.PAINIKA_OFFLINE_ALLOW;
+  if (allow === undefined)
+    return;
+  const { host, hostname } = new URL(url);
+  const name = hostname.replace(/^\[|\]$/g, "");
+  const local = name === "localhost" || /^127\./.test(name) || name === "::1";
+  const allowed = allow.split(",").some((entry) => entry !== "" && (entry === host || entry === hostname));
+  if (!local && !allowed) {
+    throw new Error(`Offline mode: refusing to connect to ${host}`);
+  }
+}
+var MAX_REPLY_TOKENS = 4096;
+function shapingInstructions(shaping, capabilities, withTools) {
+  const instructions = [];
+  if (shaping.jsonMode) {
+    instructions.push("Write your final answer as a single JSON object, with no text before or after it");
+  }
+  if (withTools && !(capabilities.tools && capabilities.toolChoice)) {
+    if (shaping.toolChoice === "required") {
+      instructions.push("Call at least one tool before answering");
+    }
+    if (shaping.parallelToolCalls === false) {
+      instructions.push("Call one tool at a time");
+    }
+  }
+  return instructions;
+}
+function withInstructions(messages, instructions) {
+  const system = messages.findIndex((msg) => msg.role === "system");
+  if (instructions.length === 0 || system < 0) {
+    return messages;
+  }
+  const section = `# Reply format
+${instructions.map((line) => `- ${line}`).join(`
+`)}`;
+  return messages.map((msg, i) => i === system ? { ...msg, content: `${msg.content}
+
+${section}` } : msg);
+}
+function toolCallBlock(name, args) {
+  return "```tool_call\n" + JSON.stringify({ name, arguments: args }) + "\n```";
+}
+function emulatedToolsPrompt(tools) {
+  const list = tools.map(({ function: fn }) => `- ${fn.name}: ${fn.description}. Arguments: ${JSON.stringify(fn.parameters?.properties ?? {})}`).join(`
+`);
+  return `# Tools
+To use a tool, reply with a fenced block tagged tool_call holding a JSON object with the tool's name and arguments, for example:
+${toolCallBlock("readFile", { path: "main.go" })}
+Write one block per call and stop after the blocks; the results come back in the next message. Reply without tool_call blocks when you are done.
+To write a whole file without a tool call, reply with a fenced block whose info string names it, such as \`\`\`go path=cmd/main.go; the user reviews the change before it is written.
+
+${list}`;
+}
+function parseEmulatedToolCalls(reply) {
+  const toolCalls = [];
+  const content = reply.replace(/```tool_call[^\n]*\n([\s\S]*?)```/g, (block, body) => {
+    try {
+      const call = JSON.parse(body);
+      if (typeof call?.name !== "string") {
+        return block;
+      }
+      toolCalls.push({
+        id: `call_${crypto.randomUUID().slice(0, 8)}`,
+        type: "function",
+        function: { name: call.name, arguments: JSON.stringify(call.arguments ?? {}) }
+      });
+      return "";
+    } catch {
+      return block;
+    }
+  });
+  return { content: content.trim(), toolCalls };
+}
+var MAX_RESUME_OVERLAP = 200;
+var MIN_RESUME_OVERLAP = 8;
+function continuationMessages(messages, partial) {
+  const tail = partial.slice(-MAX_RESUME_OVERLAP);
+  return [
+    ...messages.map((msg) => ({ role: msg.role, content: msg.content })),
+    { role: "assistant", content: partial },
+    {
+      role: "user",
+      content: `Your reply was cut off. Continue from exactly where it stopped, without repeating anything or adding commentary. Continue from:
+` + tail
+    }
+  ];
+}
+function stripOverlap(partial, continuation) {
+  const max = Math.min(partial.length, continuation.length, MAX_RESUME_OVERLAP);
+  for (let length = max;length >= MIN_RESUME_OVERLAP; length--) {
+    if (partial.endsWith(continuation.slice(0, length))) {
+      return continuation.slice(length);
+    }
+  }
+  return continuation;
+}
+
+// src/approval.ts
+var DEFAULT_GUARDED_COMMANDS = [
+  "\\brm\\s+(-\\S*r\\S*f|-\\S*f\\S*r|-r\\s+-f|-f\\s+-r|--recursive\\s+--force|--force\\s+--recursive)",
+  "\\bgit\\s+push\\b.*(\\s--force|\\s-f\\b|\\s\\+\\S)",
+  "\\bgit\\s+reset\\s+--hard\\b",
+  "\\bgit\\s+clean\\s+-\\S*f",
+  "\\bdrop\\s+(table|database|schema)\\b",
+  "\\btruncate\\s+table\\b",
+  "\\bkubectl\\s+delete\\b",
+  "\\bmkfs\\b",
+  "\\bdd\\b.*\\bof=/dev/"
+];
+var ApprovalPolicy = exports_external.object({
+  tools: exports_external.array(exports_external.string()).default([]),
+  timeoutMs: exports_external.number().int().nonnegative().default(60000),
+  defaultAction: exports_external.enum(["approve", "deny"]).default("deny"),
+  guardedCommands: exports_external.array(exports_external.string()).default([]),
+  maxLinesPerTurn: exports_external.number().int().nonnegative().default(0),
+  maxFilesPerTurn: exports_external.number().int().nonnegative().default(0)
+});
+var ApprovalDecision = exports_external.object({
+  decision: exports_external.enum(["approve", "deny", "approve_turn", "approve_pattern"]),
+  pattern: exports_external.string().optional(),
+  confirmation: exports_external.string().optional()
+});
+function approvalSubject(name, params) {
+  if (name === "bash") {
+    return String(params.command ?? "");
+  }
+  if (name === "apply_patch") {
+    return patchedPaths(String(params.patch ?? "")).join(" ") || String(params.path ?? "");
+  }
+  return String(params.path ?? "");
+}
+function suggestPattern(name, subject) {
+  if (name === "bash") {
+    const command = subject.trim().split(/\s+/)[0];
+    return command ? `${command} *` : "*";
+  }
+  const slash = subject.lastIndexOf("/");
+  return slash > 0 ? `${subject.slice(0, slash)}/*` : "*";
+}
+function guardedBy(name, params, extra = []) {
+  if (name !== "bash") {
+    return;
+  }
+  const command = String(params.command ?? "");
+  return [...DEFAULT_GUARDED_COMMANDS, ...extra].find((pattern) => {
+    try {
+      return new RegExp(pattern, "i").test(command);
+    } catch {
+      return false;
+    }
+  });
+}
+function lineDelta(before, after) {
+  const counts = new Map;
+  const beforeLines = before === "" ? [] : before.split("\n");
+  const afterLines = after === "" ? [] : after.split("\n");
+  for (const line of beforeLines) {
+    counts.set(line, (counts.get(line) ?? 0) + 1);
+  }
+  let kept = 0;
+  for (const line of afterLines) {
+    const left = counts.get(line) ?? 0;
+    if (left > 0) {
+      counts.set(line, left - 1);
+      kept++;
+    }
+  }
+  return beforeLines.length + afterLines.length - 2 * kept;
+}
+function currentContent(requested) {
+  try {
+    const target = resolveToolPath(requested, "read");
+    return existsSync(target) ? readFileSync(target, "utf8") : "";
+  } catch {
+    return "";
+  }
+}
+function changeSize(name, params) {
+  const sizes = new Map;
+  if (name === "writeFile") {
+    const path2 = String(params.path ?? "");
+    sizes.set(path2, lineDelta(currentContent(path2), String(params.content ?? "")));
+  } else if (name === "editFile") {
+    sizes.set(String(params.path ?? ""), lineDelta(String(params.oldContent ?? ""), String(params.newContent ?? "")));
+  } else if (name === "apply_patch") {
+    try {
+      for (const file of parsePatch(String(params.patch ?? ""), params.path)) {
+        const path2 = file.newPath ?? file.oldPath;
+        let lines = file.hunks.reduce((sum, hunk) => sum + hunk.ops.filter((op) => op.kind !== " ").length, 0);
+        if (file.newPath === null && file.hunks.length === 0) {
+          lines = currentContent(path2).split("\n").length;
+        }
+        sizes.set(path2, (sizes.get(path2) ?? 0) + lines);
+      }
+    } catch {
+      return null;
+    }
+  } else {
+    return null;
+  }
+  return sizes;
+}
+function matchPattern(pattern, subject) {
+  const escaped = pattern.split("*").map((part) => part.replace(/[.+?^${}()|[\]\\]/g, "\\$&")).join(".*");
+  return new RegExp(`^${escaped}$`).test(subject);
+}
+var COMMAND_SEPARATORS = /&&|\|\||[;|\n]|(?<![>&])&(?![>&])/;
+var HARMLESS_REDIRECTS = /\d*>>?\s*\/dev\/null|\d*>&\d+/g;
+function matchCommandPattern(pattern, command) {
+  if (command.includes("$(") || command.includes("`")) {
+    return false;
+  }
+  const segments = command.split(COMMAND_SEPARATORS).map((segment) => segment.trim()).filter((segment) => segment !== "");
+  return segments.length > 0 && segments.every((segment) => !segment.replace(HARMLESS_REDIRECTS, "").includes(">") && matchPattern(pattern, segment));
+}
+
+class ApprovalGate {
+  policy;
+  pending = new Map;
+  patterns = [];
+  approveTurn = false;
+  turnChanges = new Map;
+  changesApproved = false;
+  changesDenied = false;
+  constructor(policy) {
+    this.policy = ApprovalPolicy.parse(policy || {});
+  }
+  setPolicy(policy) {
+    this.policy = ApprovalPolicy.parse(policy || {});
+  }
+  startTurn() {
+    this.approveTurn = false;
+    this.turnChanges.clear();
+    this.changesApproved = false;
+    this.changesDenied = false;
+  }
+  recordChanges(sizes) {
+    for (const [path2, lines] of sizes ?? []) {
+      this.turnChanges.set(path2, (this.turnChanges.get(path2) ?? 0) + lines);
+    }
+  }
+  changeLimitExceeded(sizes) {
+    const { maxLinesPerTurn, maxFilesPerTurn } = this.policy;
+    if (!sizes || this.changesApproved || maxLinesPerTurn === 0 && maxFilesPerTurn === 0) {
+      return;
+    }
+    const totals = new Map(this.turnChanges);
+    for (const [path2, lines2] of sizes) {
+      totals.set(path2, (totals.get(path2) ?? 0) + lines2);
+    }
+    const lines = [...totals.values()].reduce((sum, n) => sum + n, 0);
+    const overLines = maxLinesPerTurn > 0 && lines > maxLinesPerTurn;
+    const overFiles = maxFilesPerTurn > 0 && totals.size > maxFilesPerTurn;
+    if (!overLines && !overFiles) {
+      return;
+    }
+    const limits = [
+      maxLinesPerTurn > 0 ? `${maxLinesPerTurn} lines` : "",
+      maxFilesPerTurn > 0 ? `${maxFilesPerTurn} files` : ""
+    ].filter(Boolean);
+    const files = [...totals.entries()].sort((a, b) => b[1] - a[1]).map(([path2, n]) => `${path2} (${n})`);
+    const shown = files.slice(0, 8).join(", ") + (files.length > 8 ? `, and ${files.length - 8} more` : "");
+    return `${lines} lines in ${totals.size} files this turn, over the limit of ${limits.join(" and ")}: ${shown}`;
+  }
+  requiresApproval(name) {
+    return this.policy.tools.includes("*") || this.policy.tools.includes(name);
+  }
+  async check(name, params, planned = null) {
+    const guard = guardedBy(name, params, this.policy.guardedCommands);
+    const sizes = planned ?? changeSize(name, params);
+    const changeLimit = this.changeLimitExceeded(sizes);
+    const changeDenial = `Denied: ${changeLimit}. Stop editing and summarize the remaining changes so the user can review what is done first.`;
+    if (changeLimit && this.changesDenied) {
+      return changeDenial;
+    }
+    if (!guard && !changeLimit && (!this.requiresApproval(name) || this.approveTurn)) {
+      this.recordChanges(sizes);
+      return null;
+    }
+    const subject = approvalSubject(name, params);
+    if (!guard && !changeLimit && this.patterns.some((p) => p.tool === name && (name === "bash" ? matchCommandPattern(p.pattern, subject) : matchPattern(p.pattern, subject)))) {
+      this.recordChanges(sizes);
+      return null;
+    }
+    const now = Date.now();
+    const { timeoutMs } = this.policy;
+    const defaultAction = guard || changeLimit ? "deny" : this.policy.defaultAction;
+    const approval = {
+      id: crypto.randomUUID(),
+      name,
+      parameters: params,
+      subject,
+      suggestedPattern: suggestPattern(name, subject),
+      guardedBy: guard,
+      changeLimit,
+      createdAt: now,
+      expiresAt: timeoutMs > 0 ? now + timeoutMs : undefined
+    };
+    let timedOut = false;
+    const { decision } = await new Promise((resolve) => {
+      const entry = { approval, resolve };
+      if (timeoutMs > 0) {
+        entry.timer = setTimeout(() => {
+          timedOut = true;
+          this.pending.delete(approval.id);
+          resolve({ decision: defaultAction });
+        }, timeoutMs);
+      }
+      this.pending.set(approval.id, entry);
+    });
+    if (decision !== "deny") {
+      this.changesApproved ||= !!changeLimit;
+      this.recordChanges(sizes);
+      return null;
+    }
+    this.changesDenied ||= !!changeLimit;
+    if (timedOut) {
+      return `No approval within ${Math.round(timeoutMs / 1000)}s; denied by default`;
+    }
+    if (changeLimit) {
+      return changeDenial;
+    }
+    return guard ? "Denied: destructive command was not confirmed by the user. Do not retry it; explain what you wanted to do instead." : "Denied by user";
+  }
+  list() {
+    return [...this.pending.values()].map((entry) => entry.approval);
+  }
+  resolve(id, answer) {
+    const entry = this.pending.get(id);
+    if (!entry) {
+      return false;
+    }
+    let { decision, pattern, confirmation } = ApprovalDecision.parse(answer);
+    if (entry.timer) {
+      clearTimeout(entry.timer);
+    }
+    this.pending.delete(id);
+    if (entry.approval.guardedBy) {
+      const confirmed = confirmation?.trim() === entry.approval.subject.trim();
+      decision = decision !== "deny" && confirmed ? "approve" : "deny";
+    }
+    if (decision === "approve_turn") {
+      this.approveTurn = true;
+    } else if (decision === "approve_pattern") {
+      this.patterns.push({
+        tool: entry.approval.name,
+        pattern: pattern || entry.approval.suggestedPattern
+      });
+    }
+    entry.resolve({ decision, pattern });
+    return true;
+  }
+}
+
+// src/questions.ts
+var QuestionAnswer = exports_external.object({
+  selected: exports_external.number().int().nonnegative().optional(),
+  text: exports_external.string().optional(),
+  skipped: exports_external.boolean().optional()
+});
+
+class QuestionBoard {
+  timeoutMs;
+  pending = new Map;
+  constructor(timeoutMs = 300000) {
+    this.timeoutMs = timeoutMs;
+  }
+  ask(question, options, allowOther) {
+    const now = Date.now();
+    const pending = {
+      id: crypto.randomUUID(),
+      question,
+      options,
+      allowOther,
+      createdAt: now,
+      expiresAt: this.timeoutMs > 0 ? now + this.timeoutMs : undefined
+    };
+    return new Promise((resolve) => {
+      const entry = { question: pending, resolve };
+      if (this.timeoutMs > 0) {
+        entry.timer = setTimeout(() => {
+          this.pending.delete(pending.id);
+          resolve({ skipped: true });
+        }, this.timeoutMs);
+      }
+      this.pending.set(pending.id, entry);
+    });
+  }
+  list() {
+    return [...this.pending.values()].map((entry) => entry.question);
+  }
+  answer(id, answer) {
+    const entry = this.pending.get(id);
+    if (!entry) {
+      return false;
+    }
+    const parsed = QuestionAnswer.parse(answer);
+    const { options, allowOther } = entry.question;
+    if (parsed.selected !== undefined && parsed.selected >= options.length) {
+      throw new Error(`Option ${parsed.selected} out of range`);
+    }
+    if (parsed.selected === undefined && parsed.text && !allowOther) {
+      throw new Error("This question only accepts one of its options");
+    }
+    if (entry.timer) {
+      clearTimeout(entry.timer);
+    }
+    this.pending.delete(id);
+    entry.resolve(parsed);
+    return true;
+  }
+}
+function createAskUserTool(board) {
+  return {
+    name: "ask_user",
+    description: "Ask the user a clarifying question with 2-6 short options to choose from. Use it when the task is ambiguous and the choice matters, instead of guessing",
+    parameters: exports_external.object({
+      question: exports_external.string(),
+      options: exports_external.array(exports_external.string()).min(2).max(6),
+      allowOther: exports_external.boolean().default(false)
+    }),
+    execute: async (params) => {
+      const answer = await board.ask(params.question, params.options, params.allowOther);
+      if (answer.skipped) {
+        return {
+          skipped: true,
+          note: "The user did not answer; proceed with the most reasonable option and say which one you chose"
+        };
+      }
+      if (answer.selected !== undefined) {
+        return {
+          answer: params.options[answer.selected],
+          optionIndex: answer.selected
+        };
+      }
+      return { answer: answer.text, other: true };
+    }
+  };
+}
+
+// src/results.ts
+var DEFAULT_RESULT_PREVIEW_TOKENS = 2000;
+var PREVIEW_LINES = 40;
+function resultText(result) {
+  if (typeof result === "string") {
+    return { key: null, text: result };
+  }
+  if (result && typeof result === "object" && !Array.isArray(result)) {
+    let key = null;
+    for (const [name, value] of Object.entries(result)) {
+      if (typeof value === "string" && (key === null || value.length > result[key].length)) {
+        key = name;
+      }
     }
+    if (key !== null) {
+      return { key, text: result[key] };
+    }
+  }
+  return { key: null, text: JSON.stringify(result, null, 2) ?? "" };
+}
+function toolResultContent(output, error, previewChars) {
+  if (error) {
+    return JSON.stringify({ error });
+  }
+  const full = JSON.stringify(output ?? null);
+  if (!previewChars || full.length <= previewChars) {
+    return full;
+  }
+  const ref = `res_${crypto.randomUUID().slice(0, 8)}`;
+  const { key, text } = resultText(output);
+  const lines = text.split("\n");
+  const preview = lines.slice(0, PREVIEW_LINES).join("\n").slice(0, Math.floor(previewChars / 2));
+  const shownLines = preview.split("\n").length;
+  const notes = [
+    `This ${formatSize(full.length)} result was cut to a preview (${shownLines} of ${lines.length} lines). Call expand_result with ref "${ref}" and the line range you need (from, to) instead of running the tool again.`
+  ];
+  const shown = key !== null ? { ...output, [key]: preview } : { preview };
+  if (typeof shown.note === "string" && shown.note) {
+    notes.unshift(shown.note);
+  }
+  return JSON.stringify({ ...shown, ref, note: notes.join(" ") });
+}
+function resultRef(content) {
+  try {
+    const parsed = JSON.parse(content);
+    return typeof parsed?.ref === "string" ? parsed.ref : undefined;
+  } catch {
+    return;
   }
 }
+function createExpandResultTool(find) {
+  return {
+    name: "expand_result",
+    description: "Get lines of a tool result that was cut to a preview with a ref. Ask for the range you need (1-based, inclusive); long ranges come back in parts",
+    parameters: exports_external.object({
+      ref: exports_external.string(),
+      from: exports_external.number().int().positive().default(1),
+      to: exports_external.number().int().positive().optional()
+    }),
+    execute: async ({ ref, from, to }) => {
+      const result = find(ref);
+      if (result === undefined) {
+        throw new Error(`No result with ref ${ref} in this conversation; it may have been dropped from the history`);
+      }
+      const lines = resultText(result).text.split("\n");
+      const wanted = Math.min(to ?? lines.length, lines.length);
+      let last = Math.min(wanted, from + OUTPUT_LIMITS.maxLines - 1);
+      let content = lines.slice(from - 1, last).join("\n");
+      while (content.length > OUTPUT_LIMITS.maxChars && last > from) {
+        last = from + Math.floor((last - from) / 2);
+        content = lines.slice(from - 1, last).join("\n");
+      }
+      if (content.length > OUTPUT_LIMITS.maxChars) {
+        content = `${content.slice(0, OUTPUT_LIMITS.maxChars)}
+... [the rest of line ${from} was cut] ...`;
+      }
+      return {
+        content,
+        from,
+        to: last,
+        totalLines: lines.length,
+        note: last < wanted ? `Lines ${last + 1}-${wanted} were left out to keep this small; ask for them with from: ${last + 1}.` : undefined
+      };
+    }
+  };
+}
 
 // src/session.ts
+var HistoryWindow = exports_external.object({
+  turns: exports_external.number().int().positive().optional(),
+  tokens: exports_external.number().int().positive().optional()
+});
+var ShapingPolicy = exports_external.object({
+  models: exports_external.record(RequestShaping).optional(),
+  session: RequestShaping.optional()
+});
 var SessionConfig = exports_external.object({
   groq: exports_external.object({
     token: exports_external.string(),
     model: exports_external.string().default("llama-3.3-70b-versatile"),
-    baseURL: exports_external.string().default("https://api.groq.com/openai")
-  })
+    baseURL: exports_external.string().default("https://api.groq.com/openai"),
+    temperature: exports_external.number().min(0).max(2).optional(),
+    provider: exports_external.string().optional()
+  }),
+  systemContext: exports_external.string().optional(),
+  gitContext: exports_external.string().optional(),
+  historyWindow: HistoryWindow.optional(),
+  fileAccess: FileAccessPolicy.partial().optional(),
+  approval: ApprovalPolicy.partial().optional(),
+  customTools: exports_external.array(CustomTool).optional(),
+  toolLimits: exports_external.record(ToolLimit).optional(),
+  renameCommand: exports_external.string().optional(),
+  toolEnv: exports_external.record(exports_external.string()).optional(),
+  resultPreviewTokens: exports_external.number().int().min(0).optional(),
+  requestShaping: ShapingPolicy.optional(),
+  restore: Conversation.optional()
+});
+var Persona = exports_external.object({
+  name: exports_external.string(),
+  prompt: exports_external.string().default(""),
+  temperature: exports_external.number().min(0).max(2).optional(),
+  tools: exports_external.array(exports_external.string()).optional()
 });
+function messagesTokens(messages) {
+  return messages.reduce((total, msg) => total + (msg.contextTokens ?? estimateTokens(msg.content + JSON.stringify(msg.toolCalls || []))), 0);
+}
 
 class Session {
   conversation;
   groq;
   toolExecutor;
+  historyWindow = {};
+  approvals;
+  questions = new QuestionBoard;
+  systemPrompt;
+  systemMessage;
+  gitContext = "";
+  persona = null;
+  customTools = [];
+  resultPreviewChars = DEFAULT_RESULT_PREVIEW_TOKENS * 4;
+  shaping = {};
   constructor(config) {
     const validatedConfig = SessionConfig.parse(config);
     this.conversation = createConversation();
     this.groq = new GroqClient(validatedConfig.groq);
     this.toolExecutor = new ToolExecutor;
+    setFileAccessPolicy(validatedConfig.fileAccess);
+    this.setHistoryWindow(validatedConfig.historyWindow);
+    this.approvals = new ApprovalGate(validatedConfig.approval);
     this.toolExecutor.registerTool(bashTool);
     this.toolExecutor.registerTool(readFileTool);
     this.toolExecutor.registerTool(writeFileTool);
+    this.toolExecutor.registerTool(applyPatchTool);
     this.toolExecutor.registerTool(listFilesTool);
+    this.toolExecutor.registerTool(searchTextTool);
+    this.toolExecutor.registerTool(projectStatsTool);
     this.toolExecutor.registerTool(makeDirTool);
+    this.toolExecutor.registerTool(rememberTool);
+    this.toolExecutor.registerTool(createAskUserTool(this.questions));
+    this.toolExecutor.registerTool(createExpandResultTool((ref) => this.findResult(ref)));
+    if (validatedConfig.renameCommand) {
+      this.toolExecutor.registerTool(createRenameSymbolTool(validatedConfig.renameCommand));
+    }
+    this.setCustomTools(validatedConfig.customTools);
+    this.setToolLimits(validatedConfig.toolLimits);
+    this.setToolEnv(validatedConfig.toolEnv);
+    this.setResultPreview(validatedConfig.resultPreviewTokens);
+    this.setRequestShaping(validatedConfig.requestShaping);
     const systemMessage = createMessage("system", `You are an AI coding assistant that helps with software engineering tasks.
 
 IMPORTANT: You are a helpful coding assistant that can create, modify, and improve code for any legitimate software development purpose including games, applications, tools, and other software projects. Always follow security best practices and ethical coding standards.
@@ -5927,6 +7892,10 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
 - Use tools when you need to interact with the file system, execute code, or perform system operations
 - For simple questions like math problems or general knowledge, answer directly without using tools
 - When making file changes, first understand the file's code conventions and follow existing patterns
+- Edit existing files with apply_patch (a unified diff) rather than rewriting them with writeFile
+- Find code with search_text rather than grep or find through bash
+- Get an overview of an unfamiliar project (languages, TODOs, tests, largest files, dependencies) with project_stats rather than a series of shell commands
+- Large tool results come back as a preview with a ref; fetch the lines you need with expand_result rather than running the tool again
 
 # Code Standards
 - Follow existing code style, libraries, and patterns in the codebase
@@ -5938,17 +7907,191 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
 - Understand the codebase context before making changes
 - Mimic existing code style and use established libraries
 - Verify solutions when possible
-- Be proactive but not surprising - do what's asked, nothing more`);
+- Be proactive but not surprising - do what's asked, nothing more
+- When a request is ambiguous and the choice matters, ask with the ask_user tool and a few concrete options rather than guessing
+
+# Memory
+- Use the remember tool to save durable facts the user states about their tooling, conventions, or preferences`);
+    if (validatedConfig.systemContext) {
+      systemMessage.content += `\n\n${validatedConfig.systemContext}`;
+    }
+    this.systemPrompt = systemMessage.content;
+    this.systemMessage = systemMessage;
+    this.setGitContext(validatedConfig.gitContext);
     this.conversation.messages.push(systemMessage);
+    if (validatedConfig.restore) {
+      const { restore } = validatedConfig;
+      this.conversation = {
+        ...restore,
+        messages: [
+          systemMessage,
+          ...restore.messages.filter((msg) => msg.role !== "system")
+        ]
+      };
+    }
   }
-  async sendMessage(content) {
-    const userMessage = createMessage("user", content);
+  setPersona(persona) {
+    this.persona = persona ? Persona.parse(persona) : null;
+    this.renderSystemMessage();
+    this.groq.setTemperature(this.persona?.temperature);
+  }
+  setCustomTools(tools) {
+    for (const name of this.customTools) {
+      this.toolExecutor.unregisterTool(name);
+    }
+    this.customTools = [];
+    for (const definition of tools || []) {
+      const tool = createCustomTool(definition);
+      this.toolExecutor.registerTool(tool);
+      this.customTools.push(tool.name);
+    }
+  }
+  setTemperature(temperature) {
+    this.groq.setDefaultTemperature(temperature ?? undefined);
+    this.groq.setTemperature(this.persona?.temperature);
+  }
+  setApprovalPolicy(policy) {
+    this.approvals.setPolicy(policy);
+  }
+  setToolEnv(env) {
+    setCommandEnv(exports_external.record(exports_external.string()).parse(env || {}));
+  }
+  setResultPreview(tokens) {
+    this.resultPreviewChars = exports_external.number().int().min(0).parse(tokens ?? DEFAULT_RESULT_PREVIEW_TOKENS) * 4;
+  }
+  setRequestShaping(policy) {
+    this.shaping = ShapingPolicy.parse(policy || {});
+  }
+  requestShaping(followUp = false) {
+    const { model } = this.groq.getSettings();
+    const models = this.shaping.models ?? {};
+    const shaping = {
+      ...models[model] ?? models[model.split(":")[0]],
+      ...this.shaping.session
+    };
+    if (followUp && shaping.toolChoice === "required") {
+      shaping.toolChoice = "auto";
+    }
+    return shaping;
+  }
+  findResult(ref) {
+    const message = this.conversation.messages.find((msg) => msg.role === "tool" && resultRef(msg.content) === ref);
+    return message?.toolResults?.[0]?.result ?? undefined;
+  }
+  resultContent(execution) {
+    const previewChars = execution.name === "expand_result" ? 0 : this.resultPreviewChars;
+    return toolResultContent(execution.output, execution.error, previewChars);
+  }
+  setIncognito(incognito) {
+    setJobOutputsKept(!incognito);
+  }
+  setGitContext(context) {
+    this.gitContext = context || "";
+    this.renderSystemMessage();
+  }
+  renderSystemMessage() {
+    let content = this.systemPrompt;
+    if (this.gitContext) {
+      content += `
+
+${this.gitContext}`;
+    }
+    if (this.persona?.prompt) {
+      content += `
+
+# Persona: ${this.persona.name}
+${this.persona.prompt}`;
+    }
+    if (this.systemMessage.content !== content) {
+      this.systemMessage.content = content;
+      delete this.systemMessage.contextTokens;
+    }
+  }
+  toolAllowed(name) {
+    if (name === "ask_user") {
+      return true;
+    }
+    return !this.persona?.tools || this.persona.tools.includes(name);
+  }
+  availableTools() {
+    return this.toolExecutor.getGroqAITools().filter((tool) => this.toolAllowed(tool.function.name));
+  }
+  setModel(model) {
+    this.groq.setModel(model);
+  }
+  getCapabilities() {
+    return this.groq.capabilities();
+  }
+  setHistoryWindow(window) {
+    this.historyWindow = HistoryWindow.parse(window || {});
+  }
+  setToolLimits(limits) {
+    this.toolExecutor.setLimits(exports_external.record(ToolLimit).parse(limits || {}));
+  }
+  contextMessages() {
+    const messages = this.conversation.messages;
+    const { turns } = this.historyWindow;
+    const systemTokens = messagesTokens(messages.filter((msg) => msg.role === "system"));
+    const room = this.groq.capabilities().contextTokens - MAX_REPLY_TOKENS - systemTokens;
+    const tokens = Math.min(this.historyWindow.tokens ?? room, room);
+    if (!turns && messagesTokens(messages) - systemTokens <= tokens) {
+      return messages;
+    }
+    const system = messages.filter((msg) => msg.role === "system");
+    const rest = messages.filter((msg) => msg.role !== "system");
+    const starts = rest.map((msg, i) => msg.role === "user" ? i : -1).filter((i) => i >= 0);
+    if (starts.length === 0) {
+      return messages;
+    }
+    let start = 0;
+    if (turns && starts.length > turns) {
+      start = starts[starts.length - turns];
+    }
+    if (tokens) {
+      let budgetStart = starts[starts.length - 1];
+      for (let t = starts.length - 2;t >= 0; t--) {
+        if (messagesTokens(rest.slice(starts[t])) > tokens)
+          break;
+        budgetStart = starts[t];
+      }
+      start = Math.max(start, budgetStart);
+    }
+    return [...system, ...rest.slice(start)];
+  }
+  async sendMessage(content, onText, author, contentTokens) {
+    const userMessage = createMessage("user", content, {
+      ...author ? { author } : {},
+      ...contentTokens !== undefined ? { contextTokens: contentTokens } : {}
+    });
     this.conversation.messages.push(userMessage);
-    const tools = this.toolExecutor.getGroqAITools();
-    const response = await this.groq.complete(this.conversation.messages, tools);
+    this.approvals.startTurn();
+    const tools = this.availableTools();
+    const complete = async (followUp = false) => {
+      let last = "";
+      const response2 = await this.groq.complete(this.contextMessages(), tools, onText && ((text) => {
+        last = text;
+        onText(text);
+      }), this.requestShaping(followUp));
+      if (onText && last !== "" && !last.endsWith(`
+`)) {
+        onText(`
+`);
+      }
+      return response2;
+    };
+    const requestStart = Date.now();
+    let response;
+    try {
+      response = await complete();
+    } catch (error) {
+      this.conversation.messages.pop();
+      throw error;
+    }
+    const timing = { startTime: requestStart, endTime: Date.now() };
     if (response.toolCalls && response.toolCalls.length > 0) {
       const assistantMessage = createMessage("assistant", response.content || "", {
         tokens: response.tokens,
+        timing,
         toolCalls: response.toolCalls.map((call) => ({
           id: call.id,
           name: call.function.name,
@@ -5959,15 +8102,27 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
       for (const toolCall of response.toolCalls) {
         try {
           const params = JSON.parse(toolCall.function.arguments);
+          if (!this.toolAllowed(toolCall.function.name)) {
+            throw new Error(`Tool ${toolCall.function.name} is not available to the ${this.persona?.name} persona`);
+          }
+          const ungated = toolCall.function.name === "ask_user" || toolCall.function.name === "rename_symbol" && !params.apply;
+          const denied = ungated ? null : await this.approvals.check(toolCall.function.name, params, await this.toolExecutor.plannedChanges(toolCall.function.name, params));
+          if (denied) {
+            throw new Error(denied);
+          }
           const execution = await this.toolExecutor.execute(toolCall.function.name, params);
-          const toolMessage = createMessage("tool", JSON.stringify(execution.output), {
+          const toolMessage = createMessage("tool", this.resultContent(execution), {
             toolResults: [
               {
                 id: toolCall.id,
                 result: execution.output,
                 error: execution.error
               }
-            ]
+            ],
+            timing: {
+              startTime: execution.startTime,
+              endTime: execution.endTime || Date.now()
+            }
           });
           this.conversation.messages.push(toolMessage);
         } catch (error) {
@@ -5985,9 +8140,11 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
           this.conversation.messages.push(errorMessage);
         }
       }
-      const finalResponse = await this.groq.complete(this.conversation.messages, tools);
+      const finalStart = Date.now();
+      const finalResponse = await complete(true);
       const finalMessage = createMessage("assistant", finalResponse.content || "", {
-        tokens: finalResponse.tokens
+        tokens: finalResponse.tokens,
+        timing: { startTime: finalStart, endTime: Date.now() }
       });
       this.conversation.messages.push(finalMessage);
       this.conversation.totalTokens.input += (response.tokens?.input || 0) + (finalResponse.tokens?.input || 0);
@@ -5996,7 +8153,8 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
       return finalMessage;
     } else {
       const assistantMessage = createMessage("assistant", response.content, {
-        tokens: response.tokens
+        tokens: response.tokens,
+        timing
       });
       this.conversation.messages.push(assistantMessage);
       this.conversation.totalTokens.input += response.tokens?.input || 0;
@@ -6005,14 +8163,55 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
       return assistantMessage;
     }
   }
+  async completeOnce(instructions, content, groq) {
+    const client = groq ? new GroqClient(SessionConfig.shape.groq.parse(groq)) : this.groq;
+    const response = await client.complete([
+      createMessage("system", instructions),
+      createMessage("user", content)
+    ]);
+    this.conversation.totalTokens.input += response.tokens?.input || 0;
+    this.conversation.totalTokens.output += response.tokens?.output || 0;
+    return response.content || "";
+  }
+  async planMessage(content) {
+    const planMessages = [
+      ...this.contextMessages(),
+      createMessage("user", `${content}
+
+(DRY RUN: nothing will be executed. Issue every tool call you would need to complete this request now, in order, with complete arguments, and briefly explain the plan.)`)
+    ];
+    const response = await this.groq.complete(planMessages, this.availableTools(), undefined, this.requestShaping());
+    this.conversation.totalTokens.input += response.tokens?.input || 0;
+    this.conversation.totalTokens.output += response.tokens?.output || 0;
+    return {
+      content: response.content || "",
+      toolCalls: (response.toolCalls || []).map((call) => {
+        let parameters;
+        try {
+          parameters = JSON.parse(call.function.arguments);
+        } catch {
+          parameters = { raw: call.function.arguments };
+        }
+        return { id: call.id, name: call.function.name, parameters };
+      })
+    };
+  }
   async* streamMessage(content) {
     const userMessage = createMessage("user", content);
     this.conversation.messages.push(userMessage);
     let assistantContent = "";
-    const stream = await this.groq.stream(this.conversation.messages);
-    for await (const chunk of stream) {
-      assistantContent += chunk;
-      yield chunk;
+    const stream = await this.groq.stream(this.contextMessages());
+    try {
+      for await (const chunk of stream) {
+        assistantContent += chunk;
+        yield chunk;
+      }
+    } catch (error) {
+      if (assistantContent !== "") {
+        this.conversation.messages.push(createMessage("assistant", assistantContent));
+        this.conversation.updatedAt = new Date().toISOString();
+      }
+      throw error;
     }
     const assistantMessage = createMessage("assistant", assistantContent);
     this.conversation.messages.push(assistantMessage);
@@ -6020,43 +8219,136 @@ IMPORTANT: You are a helpful coding assistant that can create, modify, and impro
     return assistantMessage;
   }
   async executeTool(name, params) {
+    this.approvals.startTurn();
+    const denied = await this.approvals.check(name, params, await this.toolExecutor.plannedChanges(name, params));
+    if (denied) {
+      throw new Error(denied);
+    }
     const execution = await this.toolExecutor.execute(name, params);
-    const toolMessage = createMessage("tool", JSON.stringify(execution.output), {
+    this.conversation.messages.push(createMessage("assistant", "", {
+      toolCalls: [{ id: execution.id, name, parameters: params }]
+    }));
+    const toolMessage = createMessage("tool", this.resultContent(execution), {
       toolResults: [
         {
           id: execution.id,
           result: execution.output,
           error: execution.error
         }
-      ]
+      ],
+      timing: {
+        startTime: execution.startTime,
+        endTime: execution.endTime || Date.now()
+      }
     });
     this.conversation.messages.push(toolMessage);
     this.conversation.updatedAt = new Date().toISOString();
     return execution;
   }
+  getPendingApprovals() {
+    return this.approvals.list();
+  }
+  resolveApproval(id, decision) {
+    return this.approvals.resolve(id, decision);
+  }
+  getPendingQuestions() {
+    return this.questions.list();
+  }
+  answerQuestion(id, answer) {
+    return this.questions.answer(id, answer);
+  }
   getConversation() {
     return { ...this.conversation };
   }
+  setContextTokens(counts) {
+    for (const msg of this.conversation.messages) {
+      const tokens = counts[msg.id];
+      if (Number.isInteger(tokens) && tokens >= 0) {
+        msg.contextTokens = tokens;
+      }
+    }
+  }
+  dropMessage(id) {
+    const message = this.conversation.messages.find((msg) => msg.id === id);
+    if (!message || message.role === "system") {
+      return false;
+    }
+    const note = `[removed from history by the user; was ${message.content.length} characters]`;
+    message.content = message.role === "tool" ? JSON.stringify({ note }) : note;
+    delete message.contextTokens;
+    message.toolResults = message.toolResults?.map((result) => ({
+      ...result,
+      result: note
+    }));
+    this.conversation.updatedAt = new Date().toISOString();
+    return true;
+  }
   getAvailableTools() {
     return this.toolExecutor.getTools();
   }
+  previewRequest() {
+    const messages = this.contextMessages();
+    return {
+      ...this.groq.getSettings(),
+      messages,
+      tools: this.availableTools(),
+      omitted: this.conversation.messages.length - messages.length
+    };
+  }
   getTokenUsage() {
     const { input, output } = this.conversation.totalTokens;
     return { input, output, total: input + output };
   }
   clear() {
     this.conversation = createConversation();
+    this.conversation.messages.push(this.systemMessage);
+  }
+}
+
+// src/turns.ts
+class TurnLock {
+  tail = Promise.resolve();
+  listeners = new Set;
+  subscribe(listener) {
+    this.listeners.add(listener);
+    return () => this.listeners.delete(listener);
+  }
+  emit(event) {
+    for (const listener of this.listeners) {
+      try {
+        listener(event);
+      } catch {
+      }
+    }
+  }
+  async run(session, client, content, turn) {
+    const previous = this.tail;
+    let release;
+    this.tail = new Promise((resolve) => release = resolve);
+    await previous;
+    const who = { client: client.name, clientId: client.id };
+    const start = session.getConversation().messages.length;
+    this.emit({ type: "turn", ...who, content });
+    try {
+      await turn();
+      return session.getConversation().messages.slice(start);
+    } finally {
+      for (const message of session.getConversation().messages.slice(start)) {
+        this.emit({ type: "message", ...who, message });
+      }
+      this.emit({ type: "idle", ...who });
+      release();
+    }
   }
 }
 
 // src/index.ts
 async function findAvailablePort(startPort = 3000) {
   return new Promise((resolve, reject) => {
-    const net = import.meta.require("net");
     let port = startPort;
     function tryPort(portToTry) {
-      const server = net.createServer();
-      server.listen(portToTry, () => {
+      const server = createServer();
+      server.listen(portToTry, "127.0.0.1", () => {
         server.once("close", () => {
           resolve(portToTry);
         });
@@ -6074,34 +8366,232 @@ async function findAvailablePort(startPort = 3000) {
   });
 }
 var app = new Hono2;
+var PROTOCOL_VERSION = 2;
+var TOKEN_HEADER = "X-Painika-Token";
+var CLIENT_HEADER = "X-Painika-Client";
+var CLIENT_ID_HEADER = "X-Painika-Client-Id";
+var serverToken = process.env.SERVER_TOKEN || "";
+delete process.env.SERVER_TOKEN;
+if (!serverToken) {
+  serverToken = randomBytes(32).toString("hex");
+  log("info", "\uD83D\uDD11", `No SERVER_TOKEN set; clients must send this token: ${serverToken}`);
+}
+var allowedHosts = [
+  "localhost",
+  "127.0.0.1",
+  "[::1]",
+  ...splitList(process.env.SERVER_ALLOWED_HOSTS)
+];
+var allowedOrigins = splitList(process.env.SERVER_ALLOWED_ORIGINS);
+function splitList(value) {
+  return (value || "").split(",").map((item) => item.trim().toLowerCase()).filter((item) => item !== "");
+}
+function tokenMatches(token) {
+  if (!token) {
+    return false;
+  }
+  const encoder = new TextEncoder;
+  const given = encoder.encode(token);
+  const expected = encoder.encode(serverToken);
+  return given.length === expected.length && timingSafeEqual(given, expected);
+}
+app.use("*", async (c, next) => {
+  if (!process.env.SERVER_SOCKET) {
+    const host = (c.req.header("Host") || "").toLowerCase().replace(/:\d+$/, "");
+    if (!allowedHosts.includes(host)) {
+      return c.json({ success: false, error: "Host not allowed" }, 403);
+    }
+  }
+  const origin = c.req.header("Origin");
+  if (origin && !allowedOrigins.includes(origin.toLowerCase())) {
+    return c.json({ success: false, error: "Origin not allowed" }, 403);
+  }
+  if (c.req.path !== "/health" && !tokenMatches(c.req.header(TOKEN_HEADER))) {
+    return c.json({ success: false, error: "Missing or invalid server token" }, 401);
+  }
+  await next();
+});
+app.onError((err, c) => {
+  log("error", "\u274C", `${c.req.method} ${c.req.path} failed: ${err.message}`, {
+    method: c.req.method,
+    path: c.req.path,
+    stack: err.stack
+  });
+  return c.json({ success: false, error: err.message }, 500);
+});
 var currentSession = null;
+var turns = new TurnLock;
+function turnClient(c) {
+  return {
+    name: (c.req.header(CLIENT_HEADER) || "").trim().slice(0, 64),
+    id: (c.req.header(CLIENT_ID_HEADER) || "").trim().slice(0, 64)
+  };
+}
 app.get("/health", (c) => {
   return c.json({
     status: "ok",
+    protocolVersion: PROTOCOL_VERSION,
     timestamp: Date.now(),
-    hasSession: !!currentSession
+    hasSession: !!currentSession,
+    authorized: tokenMatches(c.req.header(TOKEN_HEADER))
   });
 });
 app.post("/session", async (c) => {
   try {
-    const config = await c.req.json();
-    currentSession = new Session(config);
+    const { attach, ...config } = await c.req.json();
+    const attached = !!attach && !!currentSession;
+    if (!attached) {
+      currentSession = new Session(config);
+    }
     return c.json({
       success: true,
-      sessionId: currentSession.getConversation().id
+      sessionId: currentSession.getConversation().id,
+      capabilities: currentSession.getCapabilities(),
+      attached
     });
   } catch (error) {
     return c.json({ success: false, error: "Failed to initialize session" }, 400);
   }
 });
+function streamTurn(session, content, client, contentTokens) {
+  const encoder = new TextEncoder;
+  const body = new ReadableStream({
+    async start(controller) {
+      const send = (event) => controller.enqueue(encoder.encode(`data: ${JSON.stringify(event)}
+
+`));
+      try {
+        const messages = await turns.run(session, client, content, () => session.sendMessage(content, (chunk) => send({ chunk }), client.name, contentTokens));
+        send({ success: true, messages });
+      } catch (error) {
+        send({
+          success: false,
+          error: error instanceof Error ? error.message : "Unknown error",
+          providerStatus: error instanceof ProviderError ? error.status : undefined
+        });
+      }
+      controller.close();
+    }
+  });
+  return new Response(body, {
+    headers: {
+      "Content-Type": "text/event-stream",
+      "Cache-Control": "no-cache",
+      Connection: "keep-alive"
+    }
+  });
+}
 app.post("/message", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  try {
+    const { content, stream, contentTokens: counted, contextTokens } = await c.req.json();
+    const contentTokens = Number.isInteger(counted) && counted >= 0 ? counted : undefined;
+    const client = turnClient(c);
+    const session = currentSession;
+    if (contextTokens && typeof contextTokens === "object") {
+      session.setContextTokens(contextTokens);
+    }
+    if (stream) {
+      return streamTurn(session, content, client, contentTokens);
+    }
+    const messages = await turns.run(session, client, content, () => session.sendMessage(content, undefined, client.name, contentTokens));
+    return c.json({ success: true, messages });
+  } catch (error) {
+    return c.json({
+      success: false,
+      error: error instanceof Error ? error.message : "Unknown error",
+      providerStatus: error instanceof ProviderError ? error.status : undefined
+    }, 500);
+  }
+});
+app.post("/settings", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  try {
+    const {
+      historyWindow,
+      model,
+      temperature,
+      approval,
+      customTools,
+      toolLimits,
+      persona,
+      gitContext,
+      incognito,
+      toolEnv,
+      resultPreviewTokens,
+      requestShaping
+    } = await c.req.json();
+    if (historyWindow !== undefined) {
+      currentSession.setHistoryWindow(historyWindow);
+    }
+    if (typeof model === "string" && model !== "") {
+      currentSession.setModel(model);
+    }
+    if (temperature !== undefined) {
+      currentSession.setTemperature(temperature);
+    }
+    if (approval !== undefined) {
+      currentSession.setApprovalPolicy(approval);
+    }
+    if (customTools !== undefined) {
+      currentSession.setCustomTools(customTools);
+    }
+    if (toolLimits !== undefined) {
+      currentSession.setToolLimits(toolLimits);
+    }
+    if (persona !== undefined) {
+      currentSession.setPersona(persona);
+    }
+    if (typeof gitContext === "string") {
+      currentSession.setGitContext(gitContext);
+    }
+    if (typeof incognito === "boolean") {
+      currentSession.setIncognito(incognito);
+    }
+    if (toolEnv !== undefined) {
+      currentSession.setToolEnv(toolEnv);
+    }
+    if (resultPreviewTokens !== undefined) {
+      currentSession.setResultPreview(resultPreviewTokens);
+    }
+    if (requestShaping !== undefined) {
+      currentSession.setRequestShaping(requestShaping);
+    }
+    return c.json({ success: true });
+  } catch (error) {
+    return c.json({
+      success: false,
+      error: error instanceof Error ? error.message : "Invalid settings"
+    }, 400);
+  }
+});
+app.post("/complete", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  try {
+    const { instructions, content, groq } = await c.req.json();
+    const result = await currentSession.completeOnce(instructions, content, groq);
+    return c.json({ success: true, content: result });
+  } catch (error) {
+    return c.json({
+      success: false,
+      error: error instanceof Error ? error.message : "Unknown error"
+    }, 500);
+  }
+});
+app.post("/plan", async (c) => {
   if (!currentSession) {
     return c.json({ success: false, error: "No active session" }, 400);
   }
   try {
     const { content } = await c.req.json();
-    const message = await currentSession.sendMessage(content);
-    return c.json({ success: true, messages: [message] });
+    const plan = await currentSession.planMessage(content);
+    return c.json({ success: true, plan });
   } catch (error) {
     return c.json({
       success: false,
@@ -6109,6 +8599,63 @@ app.post("/message", async (c) => {
     }, 500);
   }
 });
+app.get("/approvals", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  const approvals = currentSession.getPendingApprovals();
+  return c.json({ success: true, approvals });
+});
+app.post("/approvals/:id", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  try {
+    const decision = await c.req.json();
+    if (!currentSession.resolveApproval(c.req.param("id"), decision)) {
+      return c.json({ success: false, error: "Approval not found or already resolved" }, 404);
+    }
+    return c.json({ success: true });
+  } catch (error) {
+    return c.json({
+      success: false,
+      error: error instanceof Error ? error.message : "Invalid decision"
+    }, 400);
+  }
+});
+app.get("/questions", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  const questions = currentSession.getPendingQuestions();
+  return c.json({ success: true, questions });
+});
+app.post("/questions/:id", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  try {
+    const answer = await c.req.json();
+    if (!currentSession.answerQuestion(c.req.param("id"), answer)) {
+      return c.json({ success: false, error: "Question not found or already answered" }, 404);
+    }
+    return c.json({ success: true });
+  } catch (error) {
+    return c.json({
+      success: false,
+      error: error instanceof Error ? error.message : "Invalid answer"
+    }, 400);
+  }
+});
+app.post("/messages/:id/drop", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  if (!currentSession.dropMessage(c.req.param("id"))) {
+    return c.json({ success: false, error: "Message not found or not droppable" }, 404);
+  }
+  return c.json({ success: true });
+});
 app.get("/stream", async (c) => {
   if (!currentSession) {
     return c.json({ success: false, error: "No active session" }, 400);
@@ -6157,7 +8704,11 @@ app.post("/tool", async (c) => {
   }
   try {
     const { name, params } = await c.req.json();
-    const execution = await currentSession.executeTool(name, params);
+    const session = currentSession;
+    let execution;
+    await turns.run(session, turnClient(c), `(ran ${name})`, async () => {
+      execution = await session.executeTool(name, params);
+    });
     return c.json({ success: true, execution });
   } catch (error) {
     return c.json({
@@ -6166,6 +8717,33 @@ app.post("/tool", async (c) => {
     }, 500);
   }
 });
+app.get("/events", () => {
+  const encoder = new TextEncoder;
+  let unsubscribe = () => {
+  };
+  let keepAlive;
+  const body = new ReadableStream({
+    start(controller) {
+      unsubscribe = turns.subscribe((event) => controller.enqueue(encoder.encode(`data: ${JSON.stringify(event)}
+
+`)));
+      keepAlive = setInterval(() => controller.enqueue(encoder.encode(`: keep-alive
+
+`)), 5000);
+    },
+    cancel() {
+      unsubscribe();
+      clearInterval(keepAlive);
+    }
+  });
+  return new Response(body, {
+    headers: {
+      "Content-Type": "text/event-stream",
+      "Cache-Control": "no-cache",
+      Connection: "keep-alive"
+    }
+  });
+});
 app.get("/conversation", async (c) => {
   if (!currentSession) {
     return c.json({ success: false, error: "No active session" }, 400);
@@ -6173,6 +8751,15 @@ app.get("/conversation", async (c) => {
   const conversation = currentSession.getConversation();
   return c.json({ success: true, conversation });
 });
+app.get("/capabilities", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  return c.json({
+    success: true,
+    capabilities: currentSession.getCapabilities()
+  });
+});
 app.get("/tools", async (c) => {
   if (!currentSession) {
     return c.json({ success: false, error: "No active session" }, 400);
@@ -6180,6 +8767,13 @@ app.get("/tools", async (c) => {
   const tools = currentSession.getAvailableTools();
   return c.json({ success: true, tools });
 });
+app.get("/preview", async (c) => {
+  if (!currentSession) {
+    return c.json({ success: false, error: "No active session" }, 400);
+  }
+  const preview = currentSession.previewRequest();
+  return c.json({ success: true, preview });
+});
 app.get("/tokens", async (c) => {
   if (!currentSession) {
     return c.json({ success: false, error: "No active session" }, 400);
@@ -6194,13 +8788,41 @@ app.delete("/session", async (c) => {
   currentSession.clear();
   return c.json({ success: true });
 });
-var specifiedPort = process.env.PORT ? parseInt(process.env.PORT) : null;
-var port = specifiedPort || await findAvailablePort(3000);
-console.log(`\uD83D\uDE80 Code Agent server starting on port ${port}`);
-serve({
-  fetch: app.fetch,
-  port
-});
+function announceReady(where) {
+  const readyFile = process.env.SERVER_READY_FILE;
+  if (!readyFile) {
+    return;
+  }
+  const ready = { ...where, pid: process.pid, protocolVersion: PROTOCOL_VERSION };
+  try {
+    writeFileSync(`${readyFile}.tmp`, JSON.stringify(ready));
+    renameSync(`${readyFile}.tmp`, readyFile);
+  } catch (error) {
+    log("warn", "\u26A0\uFE0F", `Could not write ${readyFile}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+}
+var socketPath = process.env.SERVER_SOCKET;
+if (socketPath) {
+  if (existsSync(socketPath)) {
+    unlinkSync(socketPath);
+  }
+  log("info", "\uD83D\uDE80", `Code Agent server listening on ${socketPath}`, { socket: socketPath });
+  serve({
+    fetch: app.fetch,
+    unix: socketPath
+  });
+  announceReady({ socket: socketPath });
+} else {
+  const specifiedPort = process.env.PORT ? parseInt(process.env.PORT) : null;
+  const port = specifiedPort || await findAvailablePort(3000);
+  log("info", "\uD83D\uDE80", `Code Agent server starting on port ${port}`, { port });
+  serve({
+    fetch: app.fetch,
+    hostname: process.env.SERVER_HOST || "127.0.0.1",
+    port
+  });
+  announceReady({ port });
+}
 export {
   app
 };
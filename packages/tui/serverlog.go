@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of server log lines kept in memory
+const serverLogSize = 500
+
+// Ring buffer of recent server output lines
+type logRing struct {
+	mu     sync.Mutex
+	lines  []string
+	next   int
+	full   bool
+	follow bool // Print lines live (debug pane)
+}
+
+// Captured output of the background server
+var serverLog = newLogRing(serverLogSize)
+
+// Create a ring buffer holding up to size lines
+func newLogRing(size int) *logRing {
+	return &logRing{lines: make([]string, size)}
+}
+
+// Append a line, overwriting the oldest when full
+func (r *logRing) Add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := time.Now().Format("15:04:05") + " " + line
+	r.lines[r.next] = entry
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	if r.follow {
+		fmt.Printf("\r🪵 %s\n", entry)
+	}
+}
+
+// Get the last n lines, oldest first
+func (r *logRing) Tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	tail := make([]string, 0, n)
+	for i := count - n; i < count; i++ {
+		idx := i
+		if r.full {
+			idx = (r.next + i) % len(r.lines)
+		}
+		tail = append(tail, r.lines[idx])
+	}
+	return tail
+}
+
+// Toggle live printing of new lines, returning the new state
+func (r *logRing) ToggleFollow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.follow = !r.follow
+	return r.follow
+}
+
+// Copy a server output stream into the log, line by line
+func captureServerOutput(stream io.Reader, prefix string) {
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		serverLog.Add(prefix + scanner.Text())
+	}
+}
+
+// Print the last server log lines as part of an error report
+func printServerLogTail(n int) {
+	tail := serverLog.Tail(n)
+	if len(tail) == 0 {
+		return
+	}
+
+	fmt.Println("🪵 Recent server log:")
+	for _, line := range tail {
+		fmt.Printf("   %s\n", line)
+	}
+}
+
+// Handle /serverlog [n|pane]
+func showServerLog(args string) {
+	if strings.EqualFold(args, "pane") {
+		if serverLog.ToggleFollow() {
+			fmt.Println("🪵 Debug pane on: server output will be shown live")
+		} else {
+			fmt.Println("🪵 Debug pane off")
+		}
+		fmt.Println()
+		return
+	}
+
+	n := 50
+	if args != "" {
+		parsed, err := strconv.Atoi(args)
+		if err != nil || parsed < 1 {
+			fmt.Println("Usage: /serverlog [lines|pane]")
+			fmt.Println()
+			return
+		}
+		n = parsed
+	}
+
+	tail := serverLog.Tail(n)
+	if len(tail) == 0 {
+		fmt.Println("🪵 No server output captured (the server may have been started externally)")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf("🪵 Server Log (last %d lines):\n", len(tail))
+	for _, line := range tail {
+		fmt.Printf("   %s\n", line)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Saved sessions keep their messages in an append-only log next to the
+// session file. Each save appends only what changed since the one before,
+// so it costs the same however long the conversation gets; a crash loses
+// at most the event being written; and replaying part of the log gives the
+// conversation as it was at any earlier point.
+
+// One change to a saved conversation
+type SessionEvent struct {
+	Seq          int           `json:"seq"`
+	Type         string        `json:"type"`                   // "message", "tool", "compaction", or "snapshot"
+	Time         string        `json:"time"`                   // ISO 8601 format
+	Message      *Message      `json:"message,omitempty"`      // Added by message and tool events
+	At           *int          `json:"at,omitempty"`           // Where the message goes (the end when absent)
+	Dropped      []int         `json:"dropped,omitempty"`      // Positions of the messages a compaction removed
+	Conversation *Conversation `json:"conversation,omitempty"` // Replaces everything before (snapshot)
+	TotalTokens  *TokenCounts  `json:"totalTokens,omitempty"`  // Conversation totals after this save
+}
+
+// What a session's log holds, kept after each save so the next one only
+// compares against memory
+type sessionLogState struct {
+	conversation *Conversation
+	seq          int
+}
+
+var sessionLogs = map[string]*sessionLogState{}
+
+// Path of a session's event log
+func sessionLogPath(dir, id string) string {
+	return filepath.Join(dir, id+".jsonl")
+}
+
+// Apply one event to the conversation it was written after
+func applySessionEvent(conversation *Conversation, event SessionEvent) *Conversation {
+	switch event.Type {
+	case "snapshot":
+		if event.Conversation == nil {
+			return conversation
+		}
+		snapshot := *event.Conversation
+		snapshot.Messages = append([]Message{}, snapshot.Messages...)
+		conversation = &snapshot
+	case "message", "tool":
+		if conversation == nil || event.Message == nil {
+			return conversation
+		}
+		at := len(conversation.Messages)
+		if event.At != nil {
+			at = min(max(*event.At, 0), at)
+		}
+		conversation.Messages = append(conversation.Messages[:at], append([]Message{*event.Message}, conversation.Messages[at:]...)...)
+	case "compaction":
+		if conversation == nil {
+			return conversation
+		}
+		var kept []Message
+		for i, message := range conversation.Messages {
+			if !containsInt(event.Dropped, i) {
+				kept = append(kept, message)
+			}
+		}
+		conversation.Messages = kept
+	default:
+		return conversation
+	}
+	if event.TotalTokens != nil {
+		conversation.TotalTokens = *event.TotalTokens
+	}
+	conversation.UpdatedAt = event.Time
+	return conversation
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay a session's log up to and including event at (all of it when at is
+// 0). Also returns the events read and how many bytes of the file they take;
+// a line cut short by a crash ends the log. The conversation is nil when
+// there is no log.
+func replaySessionLog(path string, at int) (*Conversation, []SessionEvent, int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer file.Close()
+
+	var conversation *Conversation
+	var events []SessionEvent
+	var valid int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		var event SessionEvent
+		if json.Unmarshal(line, &event) != nil {
+			break
+		}
+		valid += int64(len(line))
+		if at > 0 && event.Seq > at {
+			continue
+		}
+		conversation = applySessionEvent(conversation, event)
+		events = append(events, event)
+	}
+	return conversation, events, valid, nil
+}
+
+// Whether two messages are the same, down to their tool calls and timing
+func sameMessage(a, b Message) bool {
+	if a.ID != b.ID || a.Content != b.Content {
+		return false
+	}
+	aData, _ := json.Marshal(a)
+	bData, _ := json.Marshal(b)
+	return bytes.Equal(aData, bData)
+}
+
+// Events that turn the logged conversation into the current one: a
+// compaction for messages no longer there, then the messages added, in
+// order (those before the last kept message, like a system prompt that
+// changed, say where they go). A conversation with nothing in common with
+// the log starts over with a snapshot.
+func sessionEventsFor(logged, current *Conversation) []SessionEvent {
+	now := time.Now().UTC().Format(time.RFC3339)
+	totals := current.TotalTokens
+	if logged == nil || logged.ID != current.ID {
+		return []SessionEvent{{Type: "snapshot", Time: now, Conversation: current}}
+	}
+
+	positions := map[string]int{}
+	for i, message := range current.Messages {
+		positions[message.ID] = i
+	}
+	kept := map[int]bool{}
+	next := 0
+	var dropped []int
+	for i, message := range logged.Messages {
+		if j, ok := positions[message.ID]; ok && j >= next && sameMessage(message, current.Messages[j]) {
+			kept[j] = true
+			next = j + 1
+		} else {
+			dropped = append(dropped, i)
+		}
+	}
+	if len(kept) == 0 && len(logged.Messages) > 0 {
+		return []SessionEvent{{Type: "snapshot", Time: now, Conversation: current}}
+	}
+
+	var events []SessionEvent
+	if len(dropped) > 0 {
+		events = append(events, SessionEvent{Type: "compaction", Time: now, Dropped: dropped})
+	}
+	for i := range current.Messages {
+		if kept[i] {
+			continue
+		}
+		event := SessionEvent{Type: "message", Time: now, Message: &current.Messages[i]}
+		if current.Messages[i].Role == "tool" {
+			event.Type = "tool"
+		}
+		if i < next {
+			at := i
+			event.At = &at
+		}
+		events = append(events, event)
+	}
+	if len(events) > 0 && totals != logged.TotalTokens {
+		events[len(events)-1].TotalTokens = &totals
+	}
+	return events
+}
+
+// Append what changed in the conversation since it was last saved; the log
+// is flushed to disk before this returns
+func appendSessionLog(dir, id string, conversation *Conversation) error {
+	path := sessionLogPath(dir, id)
+	state := sessionLogs[id]
+	if state == nil {
+		logged, events, valid, err := replaySessionLog(path, 0)
+		if err != nil {
+			return err
+		}
+		// Drop whatever a crash left half-written, so appending continues
+		// from the last whole event
+		if info, err := os.Stat(path); err == nil && info.Size() > valid {
+			if err := os.Truncate(path, valid); err != nil {
+				return err
+			}
+		}
+		state = &sessionLogState{conversation: logged}
+		if len(events) > 0 {
+			state.seq = events[len(events)-1].Seq
+		}
+	}
+
+	events := sessionEventsFor(state.conversation, conversation)
+	if len(events) == 0 {
+		sessionLogs[id] = state
+		return nil
+	}
+
+	var buf bytes.Buffer
+	seq := state.seq
+	for i := range events {
+		seq++
+		events[i].Seq = seq
+		data, err := json.Marshal(events[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	// After a failed write the log is read again next time, so a partly
+	// written event is cut off before anything else is appended
+	delete(sessionLogs, id)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	saved := *conversation
+	saved.Messages = append([]Message{}, conversation.Messages...)
+	state.conversation = &saved
+	state.seq = seq
+	sessionLogs[id] = state
+	return nil
+}
+
+// Split "<id>@<event>" into the session and the event to stop at (0 for
+// the latest state)
+func parseSessionRef(ref string) (string, int) {
+	i := strings.LastIndex(ref, "@")
+	if i < 0 {
+		return ref, 0
+	}
+	at, err := strconv.Atoi(ref[i+1:])
+	if err != nil || at <= 0 {
+		return ref, 0
+	}
+	return ref[:i], at
+}
+
+// Load a session as it was right after event at
+func loadSessionAt(id string, at int) (*SavedSession, error) {
+	session, err := loadSession(id)
+	if err != nil || session == nil || at == 0 {
+		return session, err
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	conversation, events, _, err := replaySessionLog(sessionLogPath(dir, id), at)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 || events[len(events)-1].Seq != at {
+		return nil, fmt.Errorf("session %s has no event #%d", shortID(id), at)
+	}
+	session.Conversation = conversation
+	session.Messages = 0
+	for _, message := range conversation.Messages {
+		if message.Role != "system" {
+			session.Messages++
+		}
+	}
+	return session, nil
+}
+
+// One line describing an event for `painika sessions log`
+func describeSessionEvent(event SessionEvent) string {
+	switch event.Type {
+	case "message":
+		return fmt.Sprintf("%-10s %s: %s", event.Type, event.Message.Role, truncateWidth(strings.Join(strings.Fields(event.Message.Content), " "), 50))
+	case "tool":
+		return fmt.Sprintf("%-10s %s", event.Type, truncateWidth(strings.Join(strings.Fields(event.Message.Content), " "), 50))
+	case "compaction":
+		return fmt.Sprintf("%-10s %d message(s) removed", event.Type, len(event.Dropped))
+	case "snapshot":
+		return fmt.Sprintf("%-10s %d message(s)", event.Type, len(event.Conversation.Messages))
+	}
+	return event.Type
+}
+
+// Handle `painika sessions log <id>`
+func runSessionsLog(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: painika sessions log <id>")
+		exit(2)
+	}
+	session, err := findSession(args[0])
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	_, events, _, err := replaySessionLog(sessionLogPath(dir, session.ID), 0)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	if len(events) == 0 {
+		fmt.Printf("📜 Session %s has no event log yet; it gets one the next time it is saved\n", shortID(session.ID))
+		return
+	}
+
+	fmt.Printf("📜 Session %s (%d event(s)):\n", shortID(session.ID), len(events))
+	for _, event := range events {
+		when := event.Time
+		if t, err := time.Parse(time.RFC3339, event.Time); err == nil {
+			when = t.Local().Format("Jan 02 15:04")
+		}
+		fmt.Printf("   #%-4d %s  %s\n", event.Seq, when, describeSessionEvent(event))
+	}
+	fmt.Printf("💡 Resume it as it was after an event with painika --resume %s@<event>\n", shortID(session.ID))
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Arguments of `painika sessions merge`
+type MergeArgs struct {
+	Sources [2]string // Session IDs (or prefixes) or paths to session files
+	Output  string    // ID of the merged session
+	Concat  bool      // Append the second session instead of interleaving
+}
+
+// Parse `merge <a> <b> -o <c> [--concat]`
+func parseMergeArgs(args []string) (MergeArgs, error) {
+	var merge MergeArgs
+	var sources []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--concat":
+			merge.Concat = true
+		case args[i] == "-o" || args[i] == "--output":
+			if i+1 >= len(args) {
+				return merge, fmt.Errorf("%s needs a session ID", args[i])
+			}
+			i++
+			merge.Output = args[i]
+		case strings.HasPrefix(args[i], "--output="):
+			merge.Output = strings.TrimPrefix(args[i], "--output=")
+		case strings.HasPrefix(args[i], "-"):
+			return merge, fmt.Errorf("unknown argument: %s", args[i])
+		default:
+			sources = append(sources, args[i])
+		}
+	}
+
+	if len(sources) != 2 {
+		return merge, fmt.Errorf("need exactly two sessions to merge")
+	}
+	if merge.Output == "" {
+		return merge, fmt.Errorf("name the merged session with -o")
+	}
+	if strings.ContainsAny(merge.Output, `/\`) {
+		return merge, fmt.Errorf("session ID %q cannot contain path separators", merge.Output)
+	}
+	merge.Sources = [2]string{sources[0], sources[1]}
+	return merge, nil
+}
+
+// Load a session from a file (say one copied from another machine) or by
+// its ID; a unique ID prefix, as shown by `sessions list`, is enough
+func findSession(ref string) (*SavedSession, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, err
+		}
+		var session SavedSession
+		if err := json.Unmarshal(data, &session); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", ref, err)
+		}
+		// The conversation is in the event log copied along with the file
+		if session.Conversation == nil {
+			log := strings.TrimSuffix(ref, filepath.Ext(ref)) + ".jsonl"
+			if session.Conversation, _, _, err = replaySessionLog(log, 0); err != nil {
+				return nil, err
+			}
+		}
+		return &session, nil
+	}
+
+	sessions, err := loadSessions()
+	if err != nil {
+		return nil, err
+	}
+	var matches []SavedSession
+	for _, session := range sessions {
+		if session.ID == ref {
+			return loadSession(session.ID)
+		}
+		if strings.HasPrefix(session.ID, ref) {
+			matches = append(matches, session)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no saved session %s", ref)
+	case 1:
+		return loadSession(matches[0].ID)
+	}
+	return nil, fmt.Errorf("session ID %s is ambiguous (%d matches)", ref, len(matches))
+}
+
+// Split messages into turns: a user message with the replies and tool
+// results that follow it, so merging never separates a call from its results
+func splitTurns(messages []Message) [][]Message {
+	var turns [][]Message
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		if msg.Role == "user" || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+// When a turn started; false if its timestamp cannot be read
+func turnTime(turn []Message) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, turn[0].Timestamp)
+	return t, err == nil
+}
+
+// Key matching a turn copied between machines: the message ID, or the role,
+// time, and content when the copy was given a new ID
+func turnKeys(turn []Message) []string {
+	first := turn[0]
+	return []string{"id:" + first.ID, first.Role + "\x00" + first.Timestamp + "\x00" + first.Content}
+}
+
+// Combine the turns of two sessions, dropping turns both contain. Interleaving
+// takes turns in order of time while keeping each session's own order.
+func mergeTurns(a, b [][]Message, concat bool) ([]Message, int) {
+	seen := map[string]bool{}
+	var messages []Message
+	duplicates := 0
+	add := func(turn []Message) {
+		keys := turnKeys(turn)
+		if seen[keys[0]] || seen[keys[1]] {
+			duplicates++
+			return
+		}
+		for _, key := range keys {
+			seen[key] = true
+		}
+		messages = append(messages, turn...)
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		takeB := i >= len(a)
+		if !concat && !takeB && j < len(b) {
+			ta, okA := turnTime(a[i])
+			tb, okB := turnTime(b[j])
+			takeB = okA && okB && tb.Before(ta)
+		}
+		if takeB {
+			add(b[j])
+			j++
+		} else {
+			add(a[i])
+			i++
+		}
+	}
+	return messages, duplicates
+}
+
+// Merge two saved sessions into a new one
+func mergeSessions(a, b *SavedSession, id string, concat bool) (*SavedSession, int, error) {
+	for _, session := range []*SavedSession{a, b} {
+		if session.Conversation == nil {
+			return nil, 0, fmt.Errorf("session %s has no saved conversation", shortID(session.ID))
+		}
+	}
+
+	// The first session's system prompt leads the merged conversation
+	var messages []Message
+	for _, msg := range a.Conversation.Messages {
+		if msg.Role == "system" {
+			messages = append(messages, msg)
+			break
+		}
+	}
+	turns, duplicates := mergeTurns(splitTurns(a.Conversation.Messages), splitTurns(b.Conversation.Messages), concat)
+	messages = append(messages, turns...)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	createdAt := a.CreatedAt
+	if b.CreatedAt != "" && (createdAt == "" || b.CreatedAt < createdAt) {
+		createdAt = b.CreatedAt
+	}
+	conversation := &Conversation{
+		ID:        id,
+		Messages:  messages,
+		CreatedAt: createdAt,
+		UpdatedAt: now,
+	}
+
+	merged := &SavedSession{
+		ID:           id,
+		Workspace:    a.Workspace,
+		Branch:       a.Branch,
+		AutoTags:     a.AutoTags,
+		MergedFrom:   []string{a.ID, b.ID},
+		CreatedAt:    createdAt,
+		UpdatedAt:    now,
+		Conversation: conversation,
+	}
+	for _, tag := range append(append([]string{}, a.Tags...), b.Tags...) {
+		if !containsTag(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+	for _, msg := range messages {
+		if msg.Tokens != nil {
+			conversation.TotalTokens.Input += msg.Tokens.Input
+			conversation.TotalTokens.Output += msg.Tokens.Output
+		}
+		if msg.Role == "system" {
+			continue
+		}
+		merged.Messages++
+		if merged.Title == "" && msg.Role == "user" && msg.Content != incognitoPlaceholder {
+			merged.Title = strings.Join(strings.Fields(msg.Content), " ")
+		}
+	}
+	return merged, duplicates, nil
+}
+
+// Handle `painika sessions merge <a> <b> -o <c> [--concat]`
+func runSessionsMerge(args []string) {
+	merge, err := parseMergeArgs(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("Usage: painika sessions merge <a> <b> -o <c> [--concat]")
+		exit(2)
+	}
+
+	var sources [2]*SavedSession
+	for n, ref := range merge.Sources {
+		if sources[n], err = findSession(ref); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+	}
+	if existing, err := loadSession(merge.Output); err != nil || existing != nil {
+		if err == nil {
+			err = fmt.Errorf("session %s already exists", merge.Output)
+		}
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	merged, duplicates, err := mergeSessions(sources[0], sources[1], merge.Output, merge.Concat)
+	if err == nil {
+		err = saveSession(merged)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	how := "interleaved by time"
+	if merge.Concat {
+		how = "concatenated"
+	}
+	dropped := "no duplicate turns"
+	if duplicates == 1 {
+		dropped = "1 duplicate turn dropped"
+	} else if duplicates > 1 {
+		dropped = fmt.Sprintf("%d duplicate turns dropped", duplicates)
+	}
+	fmt.Printf("🔀 Merged %s and %s (%s, %s):\n", shortID(sources[0].ID), shortID(sources[1].ID), how, dropped)
+	printSession(*merged)
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Conversation saved after each turn, with tags for finding it later
+type SavedSession struct {
+	ID           string            `json:"id"`
+	Title        string            `json:"title"` // First user message
+	Workspace    string            `json:"workspace"`
+	Branch       string            `json:"branch,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`     // Added with /tag
+	AutoTags     []string          `json:"autoTags,omitempty"` // Workspace name and git branch
+	Messages     int               `json:"messages"`
+	ParentID     string            `json:"parentId,omitempty"`   // Session this one was forked from
+	ForkedAt     int               `json:"forkedAt,omitempty"`   // Messages copied from the parent
+	MergedFrom   []string          `json:"mergedFrom,omitempty"` // Sessions combined into this one
+	Env          map[string]string `json:"env,omitempty"`        // Tool environment kept with /env set --save
+	CreatedAt    string            `json:"createdAt"`            // ISO 8601 format
+	UpdatedAt    string            `json:"updatedAt"`            // ISO 8601 format
+	Conversation *Conversation     `json:"conversation,omitempty"`
+}
+
+// All tags, manual first
+func (s SavedSession) AllTags() []string {
+	tags := append([]string{}, s.Tags...)
+	for _, tag := range s.AutoTags {
+		if !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Check whether the session carries every one of the tags
+func (s SavedSession) HasTags(tags []string) bool {
+	all := s.AllTags()
+	for _, tag := range tags {
+		if !containsTag(all, normalizeTag(tag)) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags are lower-case with dashes for spaces
+func normalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(tag, "#")))
+	return strings.Join(strings.Fields(tag), "-")
+}
+
+// Get the directory saved sessions are kept in
+func sessionsDir() (string, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+// Load one saved session without replaying its conversation (nil if it was
+// never saved)
+func loadSessionHeader(id string) (*SavedSession, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, id+".json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session SavedSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &session, nil
+}
+
+// Load one saved session with its conversation (nil if it was never saved)
+func loadSession(id string) (*SavedSession, error) {
+	session, err := loadSessionHeader(id)
+	if err != nil || session == nil {
+		return session, err
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	conversation, _, _, err := replaySessionLog(sessionLogPath(dir, id), 0)
+	if err != nil {
+		return nil, err
+	}
+	// Sessions last saved before the event log keep the conversation inline
+	if conversation != nil {
+		session.Conversation = conversation
+	}
+	return session, nil
+}
+
+// Load all saved sessions without their conversations, most recently
+// updated first
+func loadSessions() ([]SavedSession, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SavedSession
+	for _, entry := range entries {
+		// Journals of running turns sit beside the sessions (see turnjournal.go)
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || strings.HasSuffix(entry.Name(), ".turn.json") {
+			continue
+		}
+		session, err := loadSessionHeader(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || session == nil {
+			continue
+		}
+		session.Conversation = nil
+		sessions = append(sessions, *session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt > sessions[j].UpdatedAt
+	})
+	return sessions, nil
+}
+
+// Save a session: what changed in its conversation is appended to the
+// event log, and the rest replaces the session file
+func saveSession(session *SavedSession) error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if session.Conversation != nil {
+		if err := appendSessionLog(dir, session.ID, session.Conversation); err != nil {
+			return err
+		}
+	}
+	header := *session
+	header.Conversation = nil
+	data, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written aside and renamed over the old one, so a crash leaves either
+	path := filepath.Join(dir, session.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Current git branch of dir ("" outside a repository or on a detached HEAD)
+func gitBranch(dir string) string {
+	output, err := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	branch := strings.TrimSpace(output)
+	if err != nil || branch == "HEAD" {
+		return ""
+	}
+	return branch
+}
+
+// Tags derived from where the session runs
+func autoTags(workspace, branch string) []string {
+	var tags []string
+	if name := normalizeTag(filepath.Base(workspace)); name != "" && name != "." && name != string(filepath.Separator) {
+		tags = append(tags, name)
+	}
+	if branch := normalizeTag(branch); branch != "" && !containsTag(tags, branch) {
+		tags = append(tags, branch)
+	}
+	return tags
+}
+
+// Save the current conversation, keeping tags added earlier; incognito
+// messages are saved without their content
+func recordSession(client *Client) (*SavedSession, error) {
+	conversation, err := client.GetConversation()
+	if err != nil {
+		return nil, err
+	}
+	conversation = client.config.Incognito.Scrub(conversation)
+
+	session, err := loadSessionHeader(conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		session = &SavedSession{ID: conversation.ID, CreatedAt: conversation.CreatedAt}
+	}
+
+	workspace := client.config.FileAccess.Root
+	if workspace == "" {
+		workspace, _ = os.Getwd()
+	}
+	session.Workspace = workspace
+	session.Branch = gitBranch(workspace)
+	session.AutoTags = autoTags(workspace, session.Branch)
+	session.Env = client.config.ToolEnv.saved()
+	session.Conversation = conversation
+	session.Messages = 0
+	session.Title = ""
+	for _, message := range conversation.Messages {
+		if message.Role == "system" {
+			continue
+		}
+		session.Messages++
+		if session.Title == "" && message.Role == "user" && message.Content != incognitoPlaceholder {
+			session.Title = strings.Join(strings.Fields(message.Content), " ")
+		}
+	}
+	session.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	return session, saveSession(session)
+}
+
+// Continue a saved session in the new server session (`painika --resume
+// <id>`, or <id>@<event> for the session as it was after that event)
+func resumeSession(client *Client, ref string) error {
+	ref, at := parseSessionRef(ref)
+	session, err := findSession(ref)
+	if err == nil && at > 0 {
+		session, err = loadSessionAt(session.ID, at)
+	}
+	if err != nil {
+		return err
+	}
+	if session.Conversation == nil {
+		return fmt.Errorf("session %s has no saved conversation", shortID(session.ID))
+	}
+	client.config.ToolEnv.restore(session.Env)
+	if err := restoreWithSettings(client, session.Conversation); err != nil {
+		return err
+	}
+	fmt.Printf("📂 Resumed session %s (%d msgs): %s\n", shortID(session.ID), session.Messages, truncateWidth(session.Title, 50))
+	if len(session.Env) > 0 {
+		fmt.Printf("🔧 Restored %d tool environment variable(s); see /env\n", len(session.Env))
+	}
+	return nil
+}
+
+// First characters of a session ID, enough to tell sessions apart
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// Print one session line for listings
+func printSession(session SavedSession) {
+	updated := session.UpdatedAt
+	if t, err := time.Parse(time.RFC3339, session.UpdatedAt); err == nil {
+		updated = t.Local().Format("Jan 02 15:04")
+	}
+
+	title := session.Title
+	if title == "" {
+		title = "(no messages)"
+	}
+	fmt.Printf("   %s  %s  %3d msgs  %s\n", shortID(session.ID), updated, session.Messages, truncateWidth(title, 50))
+	if session.ParentID != "" {
+		fmt.Printf("             ↳ forked from %s at #%d\n", shortID(session.ParentID), session.ForkedAt)
+	}
+	if len(session.MergedFrom) == 2 {
+		fmt.Printf("             ↳ merged from %s and %s\n", shortID(session.MergedFrom[0]), shortID(session.MergedFrom[1]))
+	}
+	if tags := session.AllTags(); len(tags) > 0 {
+		fmt.Printf("             #%s\n", strings.Join(tags, " #"))
+	}
+}
+
+// Handle /tag [add|remove <tag>...]
+func handleTag(client *Client, args string) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+
+	var tags []string
+	for _, tag := range strings.Fields(rest) {
+		if tag = normalizeTag(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	switch {
+	case sub == "" || sub == "list":
+	case (sub == "add" || sub == "remove") && len(tags) > 0:
+	default:
+		fmt.Println("Usage: /tag [list] | /tag add <tag>... | /tag remove <tag>...")
+		fmt.Println()
+		return
+	}
+
+	session, err := recordSession(client)
+	if err != nil {
+		fmt.Printf("❌ Error saving session: %v\n\n", err)
+		return
+	}
+
+	switch sub {
+	case "add":
+		for _, tag := range tags {
+			if !containsTag(session.Tags, tag) {
+				session.Tags = append(session.Tags, tag)
+			}
+		}
+	case "remove":
+		var kept []string
+		for _, tag := range session.Tags {
+			if !containsTag(tags, tag) {
+				kept = append(kept, tag)
+			}
+		}
+		for _, tag := range tags {
+			if containsTag(session.AutoTags, tag) {
+				fmt.Printf("⚠️  #%s is automatic (workspace or branch) and stays\n", tag)
+			}
+		}
+		session.Tags = kept
+	}
+
+	if sub == "add" || sub == "remove" {
+		if err := saveSession(session); err != nil {
+			fmt.Printf("❌ Error saving session: %v\n\n", err)
+			return
+		}
+	}
+
+	if all := session.AllTags(); len(all) > 0 {
+		fmt.Printf("🏷️  #%s\n\n", strings.Join(all, " #"))
+	} else {
+		fmt.Println("🏷️  No tags yet (add one with /tag add <tag>)")
+		fmt.Println()
+	}
+}
+
+// Handle `painika sessions list [--tag t]...` and `painika sessions merge`
+func runSessionsCommand(args []string) {
+	if len(args) > 0 && args[0] == "merge" {
+		runSessionsMerge(args[1:])
+		return
+	}
+	if len(args) == 1 && args[0] == "push" {
+		runSessionsPush()
+		return
+	}
+	if len(args) == 1 && args[0] == "pull" {
+		runSessionsPull()
+		return
+	}
+	if len(args) > 0 && args[0] == "log" {
+		runSessionsLog(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	}
+
+	var tags []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--tag" && i+1 < len(args):
+			i++
+			tags = append(tags, args[i])
+		case strings.HasPrefix(args[i], "--tag="):
+			tags = append(tags, strings.TrimPrefix(args[i], "--tag="))
+		default:
+			fmt.Println("Usage: painika sessions list [--tag <tag>]...")
+			exit(2)
+		}
+	}
+
+	sessions, err := loadSessions()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+
+	var matched []SavedSession
+	for _, session := range sessions {
+		if session.HasTags(tags) {
+			matched = append(matched, session)
+		}
+	}
+
+	if len(matched) == 0 {
+		if len(tags) > 0 {
+			fmt.Printf("📚 No sessions tagged #%s\n", strings.Join(tags, " #"))
+		} else {
+			fmt.Println("📚 No saved sessions yet")
+		}
+		return
+	}
+
+	fmt.Printf("📚 Sessions (%d):\n", len(matched))
+	for _, session := range matched {
+		printSession(session)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// History window sent to the model: the last N turns and/or a token budget
+type HistoryWindow struct {
+	Turns  int `json:"turns,omitempty"`
+	Tokens int `json:"tokens,omitempty"`
+}
+
+// Describe a history window for display
+func (w HistoryWindow) String() string {
+	switch {
+	case w.Turns > 0 && w.Tokens > 0:
+		return fmt.Sprintf("%d turns, %d tokens", w.Turns, w.Tokens)
+	case w.Turns > 0:
+		return fmt.Sprintf("%d turns", w.Turns)
+	case w.Tokens > 0:
+		return fmt.Sprintf("%d tokens", w.Tokens)
+	}
+	return "all"
+}
+
+// Parse a history window like "20", "20 turns", "8000 tokens", "8k tokens", or "all"
+func parseHistoryWindow(value string) (HistoryWindow, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || value == "all" || value == "off" || value == "0" {
+		return HistoryWindow{}, nil
+	}
+
+	number, unit, _ := strings.Cut(value, " ")
+	for _, suffix := range []string{"tokens", "turns", "t"} {
+		if unit == "" && strings.HasSuffix(number, suffix) && len(number) > len(suffix) {
+			number, unit = strings.TrimSuffix(number, suffix), suffix
+			break
+		}
+	}
+
+	multiplier := 1
+	if strings.HasSuffix(number, "k") {
+		number, multiplier = strings.TrimSuffix(number, "k"), 1000
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return HistoryWindow{}, fmt.Errorf("invalid history window %q (try 20, \"8000 tokens\", or all)", value)
+	}
+	n *= multiplier
+
+	switch unit {
+	case "", "turn", "turns":
+		return HistoryWindow{Turns: n}, nil
+	case "t", "token", "tokens":
+		return HistoryWindow{Tokens: n}, nil
+	}
+	return HistoryWindow{}, fmt.Errorf("invalid history window unit %q (expected turns or tokens)", unit)
+}
+
+// Push runtime settings to the server
+func (c *Client) UpdateSettings(settings map[string]interface{}) error {
+	jsonData, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.config.ServerURL+"/settings", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result StatusResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Success {
+		return fmt.Errorf("failed to update settings: %s", result.Error)
+	}
+
+	// The system prompt or the model may have changed
+	c.counts.invalidate()
+	return nil
+}
+
+// Handle /set [<key> <value>]
+func handleSet(client *Client, args string) {
+	if args == "" {
+		fmt.Println("⚙️  Settings:")
+		fmt.Printf("   history_window      %s\n", client.config.HistoryWindow)
+		fmt.Printf("   model               %s\n", client.config.Model)
+		if client.config.FollowUps {
+			fmt.Println("   follow_ups          on")
+		} else {
+			fmt.Println("   follow_ups          off")
+		}
+		fmt.Printf("   auto_context        %s\n", client.config.AutoContext)
+		if client.config.Critic != nil {
+			fmt.Printf("   critic              %s (revise: %s)\n", client.config.Critic.Model, client.config.CriticRevise)
+		} else {
+			fmt.Println("   critic              off")
+		}
+		shaping := client.config.Shaping.current(client.config.Model)
+		fmt.Printf("   json_mode           %s\n", describeSwitch(shaping.JSONMode))
+		if shaping.ToolChoice != "" {
+			fmt.Printf("   tool_choice         %s\n", shaping.ToolChoice)
+		} else {
+			fmt.Println("   tool_choice         default")
+		}
+		fmt.Printf("   parallel_tool_calls %s\n", describeSwitch(shaping.ParallelToolCalls))
+		fmt.Println()
+		return
+	}
+
+	key, value, _ := strings.Cut(args, " ")
+	switch strings.ToLower(key) {
+	case "history_window":
+		window, err := parseHistoryWindow(value)
+		if err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		if err := client.UpdateSettings(map[string]interface{}{"historyWindow": window}); err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		client.config.HistoryWindow = window
+		fmt.Printf("⚙️  history_window = %s\n", window)
+	case "model":
+		if value == "" {
+			fmt.Println("❌ Usage: /set model <name>")
+			break
+		}
+		if err := client.UpdateSettings(map[string]interface{}{"model": value}); err != nil {
+			fmt.Printf("❌ %v\n\n", err)
+			return
+		}
+		client.config.Model = value
+		fmt.Printf("⚙️  model = %s\n", value)
+		printCapabilityNotice(client)
+	case "follow_ups":
+		switch strings.ToLower(value) {
+		case "on", "off":
+			client.config.FollowUps = strings.ToLower(value) == "on"
+			fmt.Printf("⚙️  follow_ups = %s\n", strings.ToLower(value))
+		default:
+			fmt.Println("❌ Usage: /set follow_ups on|off")
+		}
+	case "auto_context":
+		switch mode := strings.ToLower(value); mode {
+		case "ask", "on", "off":
+			client.config.AutoContext = mode
+			fmt.Printf("⚙️  auto_context = %s\n", mode)
+		default:
+			fmt.Println("❌ Usage: /set auto_context ask|on|off")
+		}
+	case "critic":
+		switch {
+		case value == "":
+			fmt.Println("❌ Usage: /set critic <model>|off")
+		case strings.EqualFold(value, "off"):
+			client.config.Critic = nil
+			fmt.Println("⚙️  critic = off")
+		default:
+			critic, err := benchModelConfig(client.config, value)
+			if err != nil {
+				fmt.Printf("❌ %v\n\n", err)
+				return
+			}
+			client.config.Critic = &critic
+			fmt.Printf("⚙️  critic = %s\n", critic.Model)
+		}
+	case "critic_revise":
+		switch mode := strings.ToLower(value); mode {
+		case "off", "ask", "on":
+			client.config.CriticRevise = mode
+			fmt.Printf("⚙️  critic_revise = %s\n", mode)
+		default:
+			fmt.Println("❌ Usage: /set critic_revise off|ask|on")
+		}
+	case "json_mode", "tool_choice", "parallel_tool_calls":
+		setRequestShaping(client, strings.ToLower(key), value)
+	default:
+		fmt.Printf("❌ Unknown setting: %s\n", key)
+	}
+	fmt.Println()
+}
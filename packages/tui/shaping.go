@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider features for one model's requests, from request_shaping in the
+// config files. Fields left out keep the provider's defaults.
+type RequestShapingConfig struct {
+	JSONMode          *bool  `json:"json_mode,omitempty"`
+	ToolChoice        string `json:"tool_choice,omitempty"` // auto, required, or none
+	ParallelToolCalls *bool  `json:"parallel_tool_calls,omitempty"`
+}
+
+// Request shaping as the server takes it
+type RequestShaping struct {
+	JSONMode          *bool  `json:"jsonMode,omitempty"`
+	ToolChoice        string `json:"toolChoice,omitempty"`
+	ParallelToolCalls *bool  `json:"parallelToolCalls,omitempty"`
+}
+
+// Request shaping by model name from the config, and what /set changed for
+// this session over it
+type ShapingPolicy struct {
+	Models  map[string]RequestShaping `json:"models,omitempty"`
+	Session RequestShaping            `json:"session"`
+}
+
+// Shaping the given model's requests are sent with; config entries match
+// the model's name with or without its tag (llama3.1:8b)
+func (p ShapingPolicy) current(model string) RequestShaping {
+	shaping, ok := p.Models[model]
+	if !ok {
+		name, _, _ := strings.Cut(model, ":")
+		shaping = p.Models[name]
+	}
+	if p.Session.JSONMode != nil {
+		shaping.JSONMode = p.Session.JSONMode
+	}
+	if p.Session.ToolChoice != "" {
+		shaping.ToolChoice = p.Session.ToolChoice
+	}
+	if p.Session.ParallelToolCalls != nil {
+		shaping.ParallelToolCalls = p.Session.ParallelToolCalls
+	}
+	return shaping
+}
+
+// Merge two files' request_shaping: each field set in override wins
+func mergeRequestShaping(base, override map[string]RequestShapingConfig) map[string]RequestShapingConfig {
+	if len(override) == 0 {
+		return base
+	}
+	merged := map[string]RequestShapingConfig{}
+	for model, shaping := range base {
+		merged[model] = shaping
+	}
+	for model, shaping := range override {
+		current := merged[model]
+		if shaping.JSONMode != nil {
+			current.JSONMode = shaping.JSONMode
+		}
+		if shaping.ToolChoice != "" {
+			current.ToolChoice = shaping.ToolChoice
+		}
+		if shaping.ParallelToolCalls != nil {
+			current.ParallelToolCalls = shaping.ParallelToolCalls
+		}
+		merged[model] = current
+	}
+	return merged
+}
+
+func validToolChoice(choice string) bool {
+	return choice == "auto" || choice == "required" || choice == "none"
+}
+
+// Request shaping to send to the server, by model name
+func requestShapingConfig(user UserConfig) (map[string]RequestShaping, error) {
+	models := map[string]RequestShaping{}
+	for model, shaping := range user.RequestShaping {
+		if shaping.ToolChoice != "" && !validToolChoice(shaping.ToolChoice) {
+			return nil, fmt.Errorf("request_shaping.%s.tool_choice must be auto, required, or none, got %q", model, shaping.ToolChoice)
+		}
+		models[model] = RequestShaping{
+			JSONMode:          shaping.JSONMode,
+			ToolChoice:        shaping.ToolChoice,
+			ParallelToolCalls: shaping.ParallelToolCalls,
+		}
+	}
+	return models, nil
+}
+
+func boolPtr(value bool) *bool {
+	return &value
+}
+
+func describeSwitch(value *bool) string {
+	switch {
+	case value == nil:
+		return "default"
+	case *value:
+		return "on"
+	default:
+		return "off"
+	}
+}
+
+// Say when the model can't take the setting just changed and gets it in
+// the prompt instead
+func printShapingNotice(client *Client, key string, shaping RequestShaping) {
+	capabilities, err := client.Capabilities()
+	if err != nil {
+		return
+	}
+	switch {
+	case key == "json_mode" && shaping.JSONMode != nil && *shaping.JSONMode && !capabilities.JSONMode:
+		fmt.Printf("🧩 %s has no JSON mode; JSON is asked for in the prompt instead\n", client.config.Model)
+	// tool_choice none needs no support: the tools are just not offered
+	case key == "tool_choice" && shaping.ToolChoice == "required" && !capabilities.ToolChoice,
+		key == "parallel_tool_calls" && shaping.ParallelToolCalls != nil && !capabilities.ToolChoice:
+		fmt.Printf("🧩 %s takes no %s; it is asked for in the prompt instead\n", client.config.Model, key)
+	}
+}
+
+// Handle /set json_mode, /set tool_choice, and /set parallel_tool_calls.
+// "default" goes back to the config's setting for the model.
+func setRequestShaping(client *Client, key, value string) {
+	value = strings.ToLower(value)
+	session := client.config.Shaping.Session
+
+	var on *bool
+	switch value {
+	case "on":
+		on = boolPtr(true)
+	case "off":
+		on = boolPtr(false)
+	}
+	switch key {
+	case "json_mode":
+		if on == nil && value != "default" {
+			fmt.Println("❌ Usage: /set json_mode on|off|default")
+			return
+		}
+		session.JSONMode = on
+	case "tool_choice":
+		if !validToolChoice(value) && value != "default" {
+			fmt.Println("❌ Usage: /set tool_choice auto|required|none|default")
+			return
+		}
+		session.ToolChoice = value
+		if value == "default" {
+			session.ToolChoice = ""
+		}
+	case "parallel_tool_calls":
+		if on == nil && value != "default" {
+			fmt.Println("❌ Usage: /set parallel_tool_calls on|off|default")
+			return
+		}
+		session.ParallelToolCalls = on
+	}
+
+	policy := client.config.Shaping
+	policy.Session = session
+	if err := client.UpdateSettings(map[string]interface{}{"requestShaping": policy}); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	client.config.Shaping = policy
+	fmt.Printf("⚙️  %s = %s\n", key, value)
+	printShapingNotice(client, key, policy.current(client.config.Model))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Headers naming this client to a server other clients share
+const (
+	clientHeader   = "X-Painika-Client"
+	clientIDHeader = "X-Painika-Client-Id"
+)
+
+// Name the other clients of a shared session see: CLIENT_NAME, or user@host;
+// read on first use so CLIENT_NAME from .env is seen
+var clientName = sync.OnceValue(clientNameConfig)
+
+// Tells this run apart from another with the same name
+var clientID = randomID(8)
+
+// Whether the main loop is waiting at the prompt, so notices are printed
+// above it instead of in the middle of a reply
+var atPrompt atomic.Bool
+
+// Notices that came in while a turn was running, shown before the next prompt
+var (
+	noticesMu   sync.Mutex
+	heldNotices []string
+)
+
+func clientNameConfig() string {
+	if name := strings.TrimSpace(getEnv("CLIENT_NAME", "")); name != "" {
+		return name
+	}
+	name := "painika"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += "@" + strings.SplitN(host, ".", 2)[0]
+	}
+	return name
+}
+
+// Join the session open on the server, or start one others can join when
+// none is; true if an open session was joined
+func (c *Client) JoinSession() (bool, error) {
+	return c.initSession(nil, true)
+}
+
+// Print a notice while the user may be typing: above the prompt, with the
+// line typed so far redrawn below it. Away from the prompt it would land in
+// the middle of a reply or a question, so it waits for the next prompt.
+func printAbovePrompt(text string) {
+	noticesMu.Lock()
+	defer noticesMu.Unlock()
+	if !atPrompt.Load() {
+		heldNotices = append(heldNotices, text)
+		return
+	}
+	if activeEditor != nil {
+		activeEditor.printAbove(text)
+		return
+	}
+	fmt.Print("\r\033[K" + text)
+	renderer.Prompt()
+}
+
+// Show the prompt, after the notices held back since the last one
+func showPrompt() {
+	noticesMu.Lock()
+	defer noticesMu.Unlock()
+	for _, text := range heldNotices {
+		fmt.Print(text)
+	}
+	heldNotices = nil
+	renderer.Prompt()
+	atPrompt.Store(true)
+}
+
+// Show one event from the shared session; the client's own turns are
+// already on its screen
+func showTurnEvent(event TurnEvent) {
+	if event.ClientID == clientID {
+		return
+	}
+	who := event.Client
+	if who == "" {
+		who = "another client"
+	}
+	switch event.Type {
+	case "turn":
+		printAbovePrompt(fmt.Sprintf("👥 %s: %s\n", who, truncateWidth(event.Content, termWidth()-len(who)-6)))
+	case "message":
+		if msg := event.Message; msg != nil && msg.Role == "assistant" && strings.TrimSpace(msg.Content) != "" {
+			printAbovePrompt(fmt.Sprintf("🤖 %s\n\n", linkifyReply(formatForTerminal(msg.Content, 3))))
+		}
+	}
+}
+
+// Follow the turns other clients take in the session, reconnecting when
+// the stream drops, for as long as this run lasts
+func watchSharedSession(client *Client) {
+	stream := &http.Client{Transport: authTransport{sharedTransport}}
+	go func() {
+		delay := time.Second
+		for {
+			resp, err := stream.Get(client.config.ServerURL + "/events")
+			if err == nil && resp.StatusCode == http.StatusNotFound {
+				// A server from before shared sessions
+				resp.Body.Close()
+				return
+			}
+			if err == nil {
+				delay = time.Second
+				scanner := bufio.NewScanner(resp.Body)
+				scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+				for scanner.Scan() {
+					data, ok := strings.CutPrefix(scanner.Text(), "data:")
+					if !ok {
+						continue
+					}
+					var event TurnEvent
+					if json.Unmarshal([]byte(strings.TrimSpace(data)), &event) == nil {
+						showTurnEvent(event)
+					}
+				}
+				resp.Body.Close()
+			}
+			time.Sleep(delay)
+			delay = min(delay*2, 30*time.Second)
+		}
+	}()
+}
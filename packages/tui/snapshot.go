@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directories never included in workspace snapshots
+var snapshotSkipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	".venv":        true,
+	"__pycache__":  true,
+}
+
+// Limits keeping snapshots cheap on large workspaces
+const (
+	maxSnapshotFiles    = 20000
+	maxSnapshotFileSize = 10 << 20
+)
+
+// Workspace snapshot: file path -> content hash
+type Snapshot struct {
+	Root   string
+	Hashes map[string]string
+	done   chan struct{}
+}
+
+// Workspace changes between two snapshots
+type WorkspaceChanges struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// Snapshot taken at session start, compared on quit
+var sessionSnapshot *Snapshot
+
+// Start hashing the workspace in the background
+func takeSnapshot(root string) *Snapshot {
+	snap := &Snapshot{Root: root, done: make(chan struct{})}
+	go func() {
+		defer close(snap.done)
+		snap.Hashes = hashWorkspace(root)
+	}()
+	return snap
+}
+
+// Wait for the snapshot to finish and return its hashes
+func (s *Snapshot) Wait() map[string]string {
+	<-s.done
+	return s.Hashes
+}
+
+// Hash every regular file under root, relative path -> sha256
+func hashWorkspace(root string) map[string]string {
+	hashes := make(map[string]string)
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && snapshotSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || len(hashes) >= maxSnapshotFiles {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxSnapshotFileSize {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if hash, err := hashFile(path); err == nil {
+			hashes[filepath.ToSlash(rel)] = hash
+		}
+		return nil
+	})
+
+	return hashes
+}
+
+// Compute the sha256 of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Compare the start snapshot against the workspace now
+func (s *Snapshot) Changes() WorkspaceChanges {
+	before := s.Wait()
+	after := hashWorkspace(s.Root)
+
+	var changes WorkspaceChanges
+	for path, hash := range after {
+		old, ok := before[path]
+		if !ok {
+			changes.Added = append(changes.Added, path)
+		} else if old != hash {
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+	return changes
+}
+
+// All changed paths
+func (c WorkspaceChanges) Paths() []string {
+	paths := append(append(append([]string{}, c.Added...), c.Modified...), c.Deleted...)
+	sort.Strings(paths)
+	return paths
+}
+
+// Print the changed-files report and offer to commit or write a patch
+func summarizeSession() {
+	if sessionSnapshot == nil {
+		return
+	}
+
+	changes := sessionSnapshot.Changes()
+	paths := changes.Paths()
+	if len(paths) == 0 {
+		return
+	}
+
+	fmt.Printf("📝 Files changed this session (%d):\n", len(paths))
+	for _, path := range changes.Added {
+		fmt.Printf("   + %s\n", path)
+	}
+	for _, path := range changes.Modified {
+		fmt.Printf("   ~ %s\n", path)
+	}
+	for _, path := range changes.Deleted {
+		fmt.Printf("   - %s\n", path)
+	}
+	fmt.Println()
+
+	root := sessionSnapshot.Root
+	if !isGitRepo(root) {
+		return
+	}
+
+	fmt.Print("📦 Create a git commit (c), write a patch file (p), or skip (Enter)? ")
+	answer, ok := stdin.ReadLine()
+	if !ok {
+		return
+	}
+
+	switch strings.ToLower(answer) {
+	case "c", "commit":
+		fmt.Print("   Commit message [painika session changes]: ")
+		message := "painika session changes"
+		if line, ok := stdin.ReadLine(); ok && line != "" {
+			message = line
+		}
+		if err := commitChanges(root, paths, message); err != nil {
+			fmt.Printf("❌ Commit failed: %v\n", err)
+		} else {
+			fmt.Println("✅ Changes committed")
+		}
+	case "p", "patch":
+		name := fmt.Sprintf("painika-session-%s.patch", time.Now().Format("20060102-150405"))
+		if err := writePatch(root, changes, filepath.Join(root, name)); err != nil {
+			fmt.Printf("❌ Failed to write patch: %v\n", err)
+		} else {
+			fmt.Printf("✅ Patch written to %s\n", name)
+		}
+	}
+	fmt.Println()
+}
+
+// Check whether a directory is inside a git work tree
+func isGitRepo(dir string) bool {
+	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// Run a git command in dir, returning its output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("git %s: %v %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// Drop the paths git ignores, which git add refuses. Tracked files are kept
+// even when they match an ignore rule.
+func unignoredPaths(root string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return paths, nil
+	}
+	cmd := exec.Command("git", "check-ignore", "--stdin")
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\n") + "\n")
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return paths, nil // None ignored
+	} else if err != nil {
+		return nil, fmt.Errorf("git check-ignore: %v", err)
+	}
+
+	ignored := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		ignored[line] = true
+	}
+	var kept []string
+	for _, path := range paths {
+		if !ignored[path] {
+			kept = append(kept, path)
+		}
+	}
+	return kept, nil
+}
+
+// Commit exactly the changed paths that git doesn't ignore
+func commitChanges(root string, paths []string, message string) error {
+	paths, err := unignoredPaths(root, paths)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("every changed path is ignored by git")
+	}
+	if _, err := runGit(root, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return err
+	}
+	_, err = runGit(root, append([]string{"commit", "-m", message, "--"}, paths...)...)
+	return err
+}
+
+// Write a patch of the changes against HEAD, including new files
+func writePatch(root string, changes WorkspaceChanges, output string) error {
+	// Mark new files intent-to-add so they appear in the diff
+	added, err := unignoredPaths(root, changes.Added)
+	if err != nil {
+		return err
+	}
+	if len(added) > 0 {
+		if _, err := runGit(root, append([]string{"add", "-N", "--"}, added...)...); err != nil {
+			return err
+		}
+	}
+
+	patch, err := runGit(root, append([]string{"diff", "HEAD", "--"}, changes.Paths()...)...)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, []byte(patch), 0644)
+}
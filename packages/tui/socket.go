@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Unix domain socket paths by the placeholder host used in their request
+// URLs; the shared transport dials these hosts as sockets
+var socketHosts sync.Map
+
+// Map a unix:///path/to/painika.sock server URL to an http:// URL with a
+// placeholder host, so every client method can keep appending paths to it.
+// Other URLs are returned unchanged.
+func resolveServerURL(serverURL string) string {
+	path, ok := strings.CutPrefix(serverURL, "unix://")
+	if !ok {
+		return serverURL
+	}
+
+	sum := sha256.Sum256([]byte(path))
+	host := "sock-" + hex.EncodeToString(sum[:6]) + ".painika"
+	socketHosts.Store(host, path)
+	return "http://" + host
+}
+
+// Socket path behind a resolved server URL ("" for TCP servers)
+func socketPath(serverURL string) string {
+	host := strings.TrimPrefix(serverURL, "http://")
+	if path, ok := socketHosts.Load(host); ok {
+		return path.(string)
+	}
+	return ""
+}
+
+// Whether the server runs on this machine, so it can run this binary
+func isLocalServer(serverURL string) bool {
+	if socketPath(serverURL) != "" {
+		return true
+	}
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return false
+	}
+	host := parsed.Hostname()
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
+
+// Server URL as the user wrote it, for display
+func displayServerURL(serverURL string) string {
+	if path := socketPath(serverURL); path != "" {
+		return "unix://" + path
+	}
+	return serverURL
+}
+
+// Dial a socket host's Unix domain socket; ok is false for ordinary hosts
+func dialSocketHost(addr string) (net.Conn, bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, false, nil
+	}
+	path, ok := socketHosts.Load(host)
+	if !ok {
+		return nil, false, nil
+	}
+	conn, err := net.Dial("unix", path.(string))
+	return conn, true, err
+}
+
+// Start the server listening on a Unix domain socket instead of a TCP port
+func startServerOnSocket(path string) (*exec.Cmd, error) {
+	// A socket left behind by a crashed server would refuse connections
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	cmd, _, err := startEmbeddedServer("SERVER_SOCKET=" + path)
+	return cmd, err
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Query the health endpoint; false if no server answers
+func serverHealth(serverURL string) (HealthResponse, bool) {
+	client := &http.Client{Transport: authTransport{sharedTransport}, Timeout: 2 * time.Second}
+	resp, err := client.Get(serverURL + "/health")
+	if err != nil {
+		return HealthResponse{}, false
+	}
+	defer resp.Body.Close()
+
+	var health HealthResponse
+	if resp.StatusCode != 200 || json.NewDecoder(resp.Body).Decode(&health) != nil {
+		return HealthResponse{}, false
+	}
+	return health, true
+}
+
+// Explain why a running server can't be used by this build
+func staleServerMessage(serverURL string, health HealthResponse) string {
+	serverURL = displayServerURL(serverURL)
+	if health.ProtocolVersion == 0 {
+		return fmt.Sprintf("Server at %s is from an older build without a protocol version (this build needs v%d)", serverURL, protocolVersion)
+	}
+	return fmt.Sprintf("Server at %s speaks protocol v%d, but this build needs v%d", serverURL, health.ProtocolVersion, protocolVersion)
+}
+
+// Health polling starts fast and backs off to this interval
+const (
+	minPollInterval = 20 * time.Millisecond
+	maxPollInterval = 500 * time.Millisecond
+)
+
+// Extract the embedded server bundle into the cache directory, named by
+// its hash so an already-extracted copy is reused on warm starts
+func extractServerBundle() (string, error) {
+	// .mjs so Node.js loads it as an ES module too
+	return extractCached("server", ".mjs", serverBundle)
+}
+
+// Write content to name-<hash><ext> in the cache directory, once per version
+func extractCached(name, ext, content string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	dir := filepath.Join(cacheDir, "painika")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	path := filepath.Join(dir, fmt.Sprintf("%s-%x%s", name, sum[:8], ext))
+	if info, err := os.Stat(path); err == nil && info.Size() == int64(len(content)) {
+		return path, nil
+	}
+
+	// Write to a temporary file and rename so concurrent starts never see a partial file
+	tempFile, err := os.CreateTemp(dir, name+"-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	tempFileName := tempFile.Name()
+
+	if _, err := tempFile.WriteString(content); err != nil {
+		tempFile.Close()
+		os.Remove(tempFileName)
+		return "", fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	tempFile.Close()
+
+	if err := os.Rename(tempFileName, path); err != nil {
+		os.Remove(tempFileName)
+		return "", fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	return path, nil
+}
+
+// Poll the health endpoint until the server is up, starting with a short
+// interval and backing off. With progress, prints a dot every maxPollInterval.
+func waitForServer(serverURL string, timeout time.Duration, progress bool) bool {
+	deadline := time.Now().Add(timeout)
+	interval := minPollInterval
+	lastDot := time.Now()
+
+	for time.Now().Before(deadline) {
+		if isServerRunning(serverURL) {
+			return true
+		}
+
+		time.Sleep(interval)
+		interval = interval * 3 / 2
+		if interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+
+		if progress && time.Since(lastDot) >= maxPollInterval {
+			fmt.Print(".")
+			lastDot = time.Now()
+		}
+	}
+	return false
+}
+
+// Start the embedded server with extra environment variables and wait for
+// it to say where it listens. The server writes a ServerReady to
+// SERVER_READY_FILE once it is up, so nothing depends on the wording of its
+// log; its output still goes to the server log.
+func startEmbeddedServer(env ...string) (*exec.Cmd, ServerReady, error) {
+	bundlePath, err := extractServerBundle()
+	if err != nil {
+		return nil, ServerReady{}, err
+	}
+	cmd, err := serverCommand(bundlePath)
+	if err != nil {
+		return nil, ServerReady{}, err
+	}
+
+	readyFile, err := os.CreateTemp("", "painika-ready-*.json")
+	if err != nil {
+		return nil, ServerReady{}, fmt.Errorf("failed to create readiness file: %v", err)
+	}
+	readyFile.Close()
+	readyPath := readyFile.Name()
+	defer os.Remove(readyPath)
+	cmd.Env = append(append(serverEnv(), env...), "SERVER_READY_FILE="+readyPath)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, ServerReady{}, fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, ServerReady{}, fmt.Errorf("failed to create stderr pipe: %v", err)
+	}
+	// Output ends when the server exits
+	exited := make(chan struct{})
+	go func() {
+		captureServerOutput(stdout, "")
+		close(exited)
+	}()
+	go captureServerOutput(stderr, "[stderr] ")
+
+	if err := cmd.Start(); err != nil {
+		return nil, ServerReady{}, fmt.Errorf("failed to start server: %v", err)
+	}
+	ready, err := waitForReady(readyPath, exited, 10*time.Second)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, ServerReady{}, err
+	}
+	return cmd, ready, nil
+}
+
+// Wait for a server to fill in its readiness file
+func waitForReady(path string, exited <-chan struct{}, timeout time.Duration) (ServerReady, error) {
+	deadline := time.After(timeout)
+	interval := minPollInterval
+	for {
+		var ready ServerReady
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			if err := json.Unmarshal(data, &ready); err != nil {
+				return ready, fmt.Errorf("could not read the server's readiness file: %v", err)
+			}
+			if ready.ProtocolVersion != protocolVersion {
+				return ready, fmt.Errorf("embedded server speaks protocol v%d, but this build needs v%d", ready.ProtocolVersion, protocolVersion)
+			}
+			return ready, nil
+		}
+
+		select {
+		case <-exited:
+			return ready, fmt.Errorf("server exited before it was ready")
+		case <-deadline:
+			return ready, fmt.Errorf("timeout waiting for server to start")
+		case <-time.After(interval):
+		}
+		interval = min(interval*3/2, maxPollInterval)
+	}
+}
+
+// Start the embedded server and wait until it answers; returns its URL.
+// With a socket path it listens there instead of on a TCP port.
+func launchServer(socket string) (string, error) {
+	var serverURL string
+	if socket != "" {
+		serverCmd, err := startServerOnSocket(socket)
+		if err != nil {
+			return "", err
+		}
+		globalServerCmd = serverCmd
+		serverURL = resolveServerURL("unix://" + socket)
+	} else {
+		actualPort, serverCmd, err := startServerInBackgroundWithPort()
+		if err != nil {
+			return "", err
+		}
+
+		// Store server process globally for cleanup
+		globalServerCmd = serverCmd
+		serverURL = fmt.Sprintf("http://localhost:%d", actualPort)
+	}
+
+	// Wait for server to be ready (up to 15 seconds)
+	fmt.Print("⏳ Waiting for server to start")
+	if !waitForServer(serverURL, 15*time.Second, true) {
+		fmt.Println(" ❌")
+		stopServer()
+		return "", fmt.Errorf("server failed to start within 15 seconds")
+	}
+	fmt.Println(" ✅")
+	return serverURL, nil
+}
+
+// Stop the server started by this client, if any
+func stopServer() {
+	if globalServerCmd != nil && globalServerCmd.Process != nil {
+		globalServerCmd.Process.Kill()
+		globalServerCmd.Wait() // Wait for process to finish
+	}
+	globalServerCmd = nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Most of the piped input kept; the end of a long log matters most
+const maxPipedBytes = maxAttachmentBytes
+
+// When stdin is a pipe and there is a terminal to talk on (`go test ./... |
+// painika`), read the piped text as an attachment and take prompts from the
+// terminal. Without a terminal the piped lines stay the prompts, as in scripts.
+func pipedStdin() (*os.File, *Attachment) {
+	if isTerminal(os.Stdin) {
+		return os.Stdin, nil
+	}
+	tty, err := openTerminalInput()
+	if err != nil {
+		return os.Stdin, nil
+	}
+
+	fmt.Println("📥 Reading piped input...")
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Printf("⚠️  Could not read piped input: %v\n", err)
+	}
+	text := strings.TrimRight(strings.ToValidUTF8(string(data), "�"), "\n")
+	if strings.TrimSpace(text) == "" {
+		return tty, nil
+	}
+
+	lines := strings.Count(text, "\n") + 1
+	header := fmt.Sprintf("Piped input (%d lines):", lines)
+	if len(text) > maxPipedBytes {
+		text = text[len(text)-maxPipedBytes:]
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+		header = fmt.Sprintf("Piped input (last %d of %d lines):", strings.Count(text, "\n")+1, lines)
+	}
+	return tty, &Attachment{Name: "piped input", Text: header + "\n```\n" + text + "\n```"}
+}
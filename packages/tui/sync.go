@@ -0,0 +1,653 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Remote copy of sessions and memories, under "sync" in the global config.
+// Project configs can't set it, so a repository can't send sessions elsewhere.
+type SyncConfig struct {
+	URL        string `json:"url,omitempty"`        // s3://bucket/prefix, or an https:// WebDAV folder
+	Passphrase string `json:"passphrase,omitempty"` // Encrypts everything synced; use ${VAR} or a secret reference (op://...) to keep it out of the file
+	Endpoint   string `json:"endpoint,omitempty"`   // S3-compatible endpoint (default: AWS for the region)
+	Region     string `json:"region,omitempty"`     // S3 region (default: AWS_REGION, then us-east-1)
+	AccessKey  string `json:"access_key,omitempty"` // S3 access key ID (default: AWS_ACCESS_KEY_ID)
+	SecretKey  string `json:"secret_key,omitempty"` // S3 secret access key (default: AWS_SECRET_ACCESS_KEY)
+	Username   string `json:"username,omitempty"`   // WebDAV user
+	Password   string `json:"password,omitempty"`   // WebDAV password
+}
+
+// Sync settings from ~/.painika/config.json, with the usual AWS variables
+// filling in for missing S3 credentials
+func syncSettings() (SyncConfig, error) {
+	dir, err := painikaDir()
+	if err != nil {
+		return SyncConfig{}, err
+	}
+	user, err := readUserConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return SyncConfig{}, err
+	}
+
+	settings := user.Sync
+	if settings.Region == "" {
+		settings.Region = getEnv("AWS_REGION", "us-east-1")
+	}
+	if settings.AccessKey == "" {
+		settings.AccessKey = getEnv("AWS_ACCESS_KEY_ID", "")
+	}
+	if settings.SecretKey == "" {
+		settings.SecretKey = getEnv("AWS_SECRET_ACCESS_KEY", "")
+	}
+	// Any of the secrets may be a reference to a secret manager, read only
+	// when sync is set up
+	if settings.URL == "" {
+		return settings, nil
+	}
+	for _, secret := range []*string{&settings.Passphrase, &settings.AccessKey, &settings.SecretKey, &settings.Password} {
+		if *secret, err = resolveSecret(*secret); err != nil {
+			return SyncConfig{}, err
+		}
+	}
+	return settings, nil
+}
+
+// Where the sync URL points, without any password in it
+func syncDisplayURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return raw
+}
+
+// Object store holding the encrypted files
+type syncBackend interface {
+	Get(name string) ([]byte, error) // nil without an error when the file doesn't exist
+	Put(name string, data []byte) error
+}
+
+var syncHTTP = &http.Client{Timeout: 60 * time.Second}
+
+// Describe a failed request to the sync backend
+func syncStatusError(req *http.Request, status int) error {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return fmt.Errorf("%s refused the credentials (status %d)", req.URL.Host, status)
+	}
+	return fmt.Errorf("%s %s returned status %d", req.Method, req.URL.Redacted(), status)
+}
+
+// S3 or an S3-compatible store (MinIO, R2, B2), addressed path-style
+type s3Backend struct {
+	endpoint     string // Scheme and host
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func (b *s3Backend) do(method, name string, body []byte) (*http.Response, []byte, error) {
+	key := path.Join(b.prefix, name)
+	req, err := http.NewRequest(method, b.endpoint+"/"+b.bucket+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	b.sign(req, body, time.Now().UTC())
+
+	resp, err := syncHTTP.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, data, err
+}
+
+// Sign a request with AWS Signature Version 4
+func (b *s3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if b.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", b.sessionToken)
+		headers = append(headers, "x-amz-security-token")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+	canonical := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := []byte("AWS4" + b.secretKey)
+	for _, part := range []string{date, b.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func (b *s3Backend) Get(name string) ([]byte, error) {
+	resp, data, err := b.do("GET", name, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return data, nil
+	case http.StatusNotFound:
+		return nil, nil
+	}
+	return nil, syncStatusError(resp.Request, resp.StatusCode)
+}
+
+func (b *s3Backend) Put(name string, data []byte) error {
+	resp, _, err := b.do("PUT", name, data)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return syncStatusError(resp.Request, resp.StatusCode)
+	}
+	return nil
+}
+
+// Folder on a WebDAV server (Nextcloud, ownCloud, Apache mod_dav)
+type webdavBackend struct {
+	base     string // Folder URL ending in "/"
+	username string
+	password string
+}
+
+func (b *webdavBackend) do(method, name string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, b.base+name, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+
+	resp, err := syncHTTP.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, data, err
+}
+
+func (b *webdavBackend) Get(name string) ([]byte, error) {
+	resp, data, err := b.do("GET", name, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return data, nil
+	case http.StatusNotFound:
+		return nil, nil
+	}
+	return nil, syncStatusError(resp.Request, resp.StatusCode)
+}
+
+func (b *webdavBackend) Put(name string, data []byte) error {
+	resp, _, err := b.do("PUT", name, data)
+	if err != nil {
+		return err
+	}
+	// WebDAV doesn't create missing folders on PUT
+	if resp.StatusCode == http.StatusConflict && path.Dir(name) != "." {
+		mkcol, _, err := b.do("MKCOL", path.Dir(name)+"/", nil)
+		if err != nil {
+			return err
+		}
+		if mkcol.StatusCode != http.StatusCreated && mkcol.StatusCode != http.StatusMethodNotAllowed {
+			return syncStatusError(mkcol.Request, mkcol.StatusCode)
+		}
+		if resp, _, err = b.do("PUT", name, data); err != nil {
+			return err
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+	return syncStatusError(resp.Request, resp.StatusCode)
+}
+
+// Pick the backend the sync URL names
+func newSyncBackend(settings SyncConfig) (syncBackend, error) {
+	u, err := url.Parse(settings.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync.url: %v", err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("sync.url needs a bucket, e.g. s3://my-bucket/painika")
+		}
+		if settings.AccessKey == "" || settings.SecretKey == "" {
+			return nil, fmt.Errorf("set sync.access_key and sync.secret_key (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+		}
+		endpoint := settings.Endpoint
+		if endpoint == "" {
+			endpoint = "https://s3." + settings.Region + ".amazonaws.com"
+		}
+		return &s3Backend{
+			endpoint:     strings.TrimRight(endpoint, "/"),
+			bucket:       u.Host,
+			prefix:       strings.Trim(u.Path, "/"),
+			region:       settings.Region,
+			accessKey:    settings.AccessKey,
+			secretKey:    settings.SecretKey,
+			sessionToken: getEnv("AWS_SESSION_TOKEN", ""),
+		}, nil
+	case "http", "https":
+		username, password := settings.Username, settings.Password
+		if u.User != nil {
+			username = u.User.Username()
+			password, _ = u.User.Password()
+			u.User = nil
+		}
+		return &webdavBackend{base: strings.TrimRight(u.String(), "/") + "/", username: username, password: password}, nil
+	}
+	return nil, fmt.Errorf("sync.url must start with s3://, https://, or http:// (got %q)", settings.URL)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Header of encrypted sync files, followed by the salt, nonce, and AES-GCM ciphertext
+const syncMagic = "PKS1"
+
+// PBKDF2 rounds for turning the passphrase into a key
+const syncKeyRounds = 200000
+
+// PBKDF2-HMAC-SHA256 for one 32-byte key
+func deriveSyncKey(passphrase string, salt []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(passphrase))
+	mac.Write(salt)
+	mac.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := mac.Sum(nil)
+	key := append([]byte{}, u...)
+	for i := 1; i < syncKeyRounds; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(nil)
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// Encrypts with the passphrase; derivation is slow on purpose, so each run
+// seals with one salt and keeps the keys it derives
+type syncCipher struct {
+	passphrase string
+	salt       []byte
+	keys       map[string]cipher.AEAD // By salt
+}
+
+func newSyncCipher(passphrase string) (*syncCipher, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &syncCipher{passphrase: passphrase, salt: salt, keys: map[string]cipher.AEAD{}}, nil
+}
+
+func (c *syncCipher) aead(salt []byte) (cipher.AEAD, error) {
+	if aead, ok := c.keys[string(salt)]; ok {
+		return aead, nil
+	}
+	block, err := aes.NewCipher(deriveSyncKey(c.passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[string(salt)] = aead
+	return aead, nil
+}
+
+func (c *syncCipher) seal(plain []byte) ([]byte, error) {
+	aead, err := c.aead(c.salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(syncMagic), c.salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, []byte(syncMagic)), nil
+}
+
+func (c *syncCipher) open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(syncMagic)) || len(data) < len(syncMagic)+16 {
+		return nil, fmt.Errorf("not a painika sync file")
+	}
+	salt := data[len(syncMagic) : len(syncMagic)+16]
+	aead, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(syncMagic)+16:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("sync file is truncated")
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(syncMagic))
+	if err != nil {
+		return nil, fmt.Errorf("wrong sync passphrase, or the file is damaged")
+	}
+	return plain, nil
+}
+
+// What the remote holds, so a pull needs no directory listing
+type syncIndex struct {
+	Sessions map[string]string `json:"sessions"` // Session ID -> UpdatedAt
+}
+
+// Encrypted files on the sync backend
+type syncStore struct {
+	backend syncBackend
+	cipher  *syncCipher
+}
+
+func openSyncStore(settings SyncConfig) (*syncStore, error) {
+	if settings.URL == "" {
+		return nil, fmt.Errorf("sync is not set up; add sync.url and sync.passphrase to ~/.painika/config.json")
+	}
+	if settings.Passphrase == "" {
+		return nil, fmt.Errorf("set sync.passphrase; everything synced is encrypted with it")
+	}
+	backend, err := newSyncBackend(settings)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newSyncCipher(settings.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &syncStore{backend: backend, cipher: c}, nil
+}
+
+// Read and decrypt one file into v; false if it doesn't exist
+func (s *syncStore) get(name string, v interface{}) (bool, error) {
+	data, err := s.backend.Get(name)
+	if err != nil || data == nil {
+		return false, err
+	}
+	plain, err := s.cipher.open(data)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", name, err)
+	}
+	return true, json.Unmarshal(plain, v)
+}
+
+func (s *syncStore) put(name string, v interface{}) error {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data, err := s.cipher.seal(plain)
+	if err != nil {
+		return err
+	}
+	return s.backend.Put(name, data)
+}
+
+func (s *syncStore) index() (syncIndex, error) {
+	index := syncIndex{Sessions: map[string]string{}}
+	if _, err := s.get("index.enc", &index); err != nil {
+		return index, err
+	}
+	if index.Sessions == nil {
+		index.Sessions = map[string]string{}
+	}
+	return index, nil
+}
+
+// Memories in extra that base doesn't have, appended to base
+func mergeMemories(base, extra []Memory) ([]Memory, int) {
+	added := 0
+	for _, memory := range extra {
+		known := false
+		for _, m := range base {
+			if strings.EqualFold(m.Fact, memory.Fact) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			base = append(base, memory)
+			added++
+		}
+	}
+	return base, added
+}
+
+// Upload sessions changed since they were last pushed, and memories the
+// remote doesn't have. Returns how many sessions were uploaded.
+func (s *syncStore) push() (int, error) {
+	index, err := s.index()
+	if err != nil {
+		return 0, err
+	}
+	sessions, err := loadSessions()
+	if err != nil {
+		return 0, err
+	}
+
+	pushed := 0
+	for i := range sessions {
+		session := &sessions[i]
+		if remote, ok := index.Sessions[session.ID]; ok && session.UpdatedAt <= remote {
+			continue
+		}
+		full, err := loadSession(session.ID)
+		if err != nil {
+			return pushed, err
+		}
+		if err := s.put("sessions/"+session.ID+".enc", full); err != nil {
+			return pushed, err
+		}
+		index.Sessions[session.ID] = session.UpdatedAt
+		pushed++
+	}
+
+	var remote []Memory
+	if _, err := s.get("memories.enc", &remote); err != nil {
+		return pushed, err
+	}
+	local, err := loadMemories()
+	if err != nil {
+		return pushed, err
+	}
+	if merged, added := mergeMemories(remote, local); added > 0 {
+		if err := s.put("memories.enc", merged); err != nil {
+			return pushed, err
+		}
+	}
+
+	// The index goes last, so it never lists a session that isn't there
+	if pushed > 0 {
+		return pushed, s.put("index.enc", index)
+	}
+	return pushed, nil
+}
+
+// Download sessions newer on the remote than here, and memories this
+// machine doesn't have. Returns the sessions pulled, newest first.
+func (s *syncStore) pull() ([]SavedSession, int, error) {
+	index, err := s.index()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pulled []SavedSession
+	for id, updated := range index.Sessions {
+		local, err := loadSessionHeader(id)
+		if err != nil {
+			return pulled, 0, err
+		}
+		if local != nil && local.UpdatedAt >= updated {
+			continue
+		}
+		var session SavedSession
+		found, err := s.get("sessions/"+id+".enc", &session)
+		if err != nil {
+			return pulled, 0, err
+		}
+		if !found || session.ID != id {
+			continue
+		}
+		if err := saveSession(&session); err != nil {
+			return pulled, 0, err
+		}
+		pulled = append(pulled, session)
+	}
+	sort.Slice(pulled, func(i, j int) bool { return pulled[i].UpdatedAt > pulled[j].UpdatedAt })
+
+	var remote []Memory
+	if _, err := s.get("memories.enc", &remote); err != nil {
+		return pulled, 0, err
+	}
+	local, err := loadMemories()
+	if err != nil {
+		return pulled, 0, err
+	}
+	merged, added := mergeMemories(local, remote)
+	if added > 0 {
+		if err := saveMemories(merged); err != nil {
+			return pulled, 0, err
+		}
+	}
+	return pulled, added, nil
+}
+
+// Handle `painika sessions push`
+func runSessionsPush() {
+	settings, err := syncSettings()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	store, err := openSyncStore(settings)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("☁️  Pushing to %s", syncDisplayURL(settings.URL))
+	stop := showThinking()
+	pushed, err := store.push()
+	stop()
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("❌ Push failed: %v\n", err)
+		exit(1)
+	}
+	if pushed == 0 {
+		fmt.Println("☁️  Every session was already pushed")
+		return
+	}
+	fmt.Printf("☁️  Pushed %d session(s)\n", pushed)
+}
+
+// Handle `painika sessions pull`
+func runSessionsPull() {
+	settings, err := syncSettings()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	store, err := openSyncStore(settings)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		exit(1)
+	}
+	fmt.Printf("☁️  Pulling from %s", syncDisplayURL(settings.URL))
+	stop := showThinking()
+	pulled, memories, err := store.pull()
+	stop()
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("❌ Pull failed: %v\n", err)
+		exit(1)
+	}
+
+	if memories > 0 {
+		fmt.Printf("🧠 %d new memory(ies)\n", memories)
+	}
+	if len(pulled) == 0 {
+		fmt.Println("☁️  Sessions are up to date")
+		return
+	}
+	fmt.Printf("☁️  Pulled %d session(s):\n", len(pulled))
+	for _, session := range pulled {
+		printSession(session)
+	}
+	fmt.Printf("💡 Continue the latest with: painika --resume %s\n", shortID(pulled[0].ID))
+}
+
+// Push sessions and memories as the client quits, when sync is set up
+// (never offline)
+func syncOnQuit() {
+	settings, err := syncSettings()
+	if err != nil || settings.URL == "" || offlineMode {
+		return
+	}
+	store, err := openSyncStore(settings)
+	if err == nil {
+		_, err = store.push()
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Sessions not synced: %v\n", err)
+		return
+	}
+	fmt.Printf("☁️  Sessions synced to %s\n", syncDisplayURL(settings.URL))
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// Window size structure filled by TIOCGWINSZ
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// Get the terminal size of stdout (0, 0 if it is not a terminal)
+func terminalSize() (int, int) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// Switch the terminal to reading key by key without echo, so the line
+// editor handles every key. Signals (Ctrl-C) and output processing stay on.
+func enableLineEditing(in, out *os.File) (func(), error) {
+	var saved syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, in.Fd(), ioctlGetTermios, uintptr(unsafe.Pointer(&saved))); errno != 0 {
+		return nil, errno
+	}
+
+	raw := saved
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, in.Fd(), ioctlSetTermios, uintptr(unsafe.Pointer(&raw))); errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, in.Fd(), ioctlSetTermios, uintptr(unsafe.Pointer(&saved)))
+	}, nil
+}
+
+// The controlling terminal, for prompts when stdin is a pipe; fails
+// without one (cron, CI)
+func openTerminalInput() (*os.File, error) {
+	return os.Open("/dev/tty")
+}
+
+// Call onResize whenever the terminal is resized
+func watchResize(onResize func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGWINCH)
+
+	go func() {
+		for range c {
+			onResize()
+		}
+	}()
+}
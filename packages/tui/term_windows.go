@@ -0,0 +1,90 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+)
+
+// Console mode flags (see the Win32 API)
+const (
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+// Console screen buffer info structure (see the Win32 API)
+type consoleScreenBufferInfo struct {
+	Size              [2]int16
+	CursorPosition    [2]int16
+	Attributes        uint16
+	Window            [4]int16 // Left, Top, Right, Bottom
+	MaximumWindowSize [2]int16
+}
+
+// Get the console size of stdout (0, 0 if it is not a console)
+func terminalSize() (int, int) {
+	var info consoleScreenBufferInfo
+	ok, _, _ := procGetConsoleScreenBufferInfo.Call(os.Stdout.Fd(), uintptr(unsafe.Pointer(&info)))
+	if ok == 0 {
+		return 0, 0
+	}
+	return int(info.Window[2]-info.Window[0]) + 1, int(info.Window[3]-info.Window[1]) + 1
+}
+
+// Switch the console to reading key by key without echo, with keys and
+// replies arriving as the same escape sequences a Unix terminal sends
+func enableLineEditing(in, out *os.File) (func(), error) {
+	var inMode, outMode uint32
+	if ok, _, err := procGetConsoleMode.Call(in.Fd(), uintptr(unsafe.Pointer(&inMode))); ok == 0 {
+		return nil, err
+	}
+	if ok, _, err := procGetConsoleMode.Call(out.Fd(), uintptr(unsafe.Pointer(&outMode))); ok == 0 {
+		return nil, err
+	}
+
+	// Consoles without escape sequence support keep plain line input
+	if ok, _, err := procSetConsoleMode.Call(out.Fd(), uintptr(outMode|enableVirtualTerminalProcessing)); ok == 0 {
+		return nil, err
+	}
+	if ok, _, err := procSetConsoleMode.Call(in.Fd(), uintptr(inMode&^(enableLineInput|enableEchoInput)|enableVirtualTerminalInput)); ok == 0 {
+		procSetConsoleMode.Call(out.Fd(), uintptr(outMode))
+		return nil, err
+	}
+
+	return func() {
+		procSetConsoleMode.Call(in.Fd(), uintptr(inMode))
+		procSetConsoleMode.Call(out.Fd(), uintptr(outMode))
+	}, nil
+}
+
+// The console, for prompts when stdin is a pipe; fails without one
+func openTerminalInput() (*os.File, error) {
+	return os.OpenFile("CONIN$", os.O_RDWR, 0)
+}
+
+// Call onResize whenever the console is resized.
+// Windows has no resize signal, so poll the size instead.
+func watchResize(onResize func()) {
+	go func() {
+		lastWidth, lastHeight := terminalSize()
+		for range time.Tick(time.Second) {
+			width, height := terminalSize()
+			if width != lastWidth || height != lastHeight {
+				lastWidth, lastHeight = width, height
+				onResize()
+			}
+		}
+	}()
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Tokenizer counts tokens the way a model family does
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// Model families and the BPE rank files that describe them
+var tokenizerFamilies = []struct {
+	prefix string
+	family string
+}{
+	{"llama-3", "llama3"},
+	{"llama3", "llama3"},
+	{"gpt-4o", "o200k"},
+	{"gpt-", "cl100k"},
+	{"mixtral", "mistral"},
+	{"mistral", "mistral"},
+	{"gemma", "gemma"},
+	{"qwen", "qwen2"},
+}
+
+// Loaded tokenizers, keyed by family
+var (
+	tokenizerCache = map[string]Tokenizer{}
+	tokenizerMu    sync.Mutex
+)
+
+// Get the tokenizer for a model, falling back to a heuristic
+// when no BPE file is installed in ~/.painika/tokenizers
+func tokenizerForModel(model string) Tokenizer {
+	family := ""
+	lower := strings.ToLower(model)
+	for _, f := range tokenizerFamilies {
+		if strings.HasPrefix(lower, f.prefix) {
+			family = f.family
+			break
+		}
+	}
+	if family == "" {
+		return heuristicTokenizer{}
+	}
+
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+
+	if tok, ok := tokenizerCache[family]; ok {
+		return tok
+	}
+
+	var tok Tokenizer = heuristicTokenizer{}
+	if dir, err := painikaDir(); err == nil {
+		path := filepath.Join(dir, "tokenizers", family+".tiktoken")
+		if bpe, err := loadBPETokenizer(family, path); err == nil {
+			tok = bpe
+		}
+	}
+	tokenizerCache[family] = tok
+	return tok
+}
+
+// Heuristic tokenizer used when no BPE file is available.
+// Words average ~1.3 tokens, and symbols usually stand alone.
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) Name() string {
+	return "heuristic"
+}
+
+func (heuristicTokenizer) Count(text string) int {
+	tokens := 0.0
+	wordLen := 0
+
+	flush := func() {
+		if wordLen > 0 {
+			// Long identifiers split into several tokens
+			tokens += 1 + float64(wordLen-1)/6
+			wordLen = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if r > unicode.MaxASCII {
+				tokens += 0.7 // Non-Latin scripts are token-dense
+				continue
+			}
+			wordLen++
+		case unicode.IsSpace(r):
+			flush()
+			if r == '\n' {
+				tokens += 0.5
+			}
+		default:
+			flush()
+			tokens += 0.8
+		}
+	}
+	flush()
+
+	return int(tokens + 0.5)
+}
+
+// Pieces a BPE tokenizer remembers counts for; the cache starts over when
+// it fills up, so long sessions don't grow it without bound
+const bpeCacheSize = 50000
+
+// Byte-level BPE tokenizer backed by a tiktoken-format rank file
+// (one "<base64 token> <rank>" pair per line)
+type bpeTokenizer struct {
+	name  string
+	ranks map[string]int
+	cache map[string]int
+	mu    sync.Mutex
+}
+
+// Pre-tokenization pattern splitting text into words, numbers, and symbols
+var bpeSplitPattern = regexp.MustCompile(`'(?i:[sdmt]|ll|ve|re)| ?\p{L}+| ?\p{N}{1,3}| ?[^\s\p{L}\p{N}]+|\s+`)
+
+// Load a BPE tokenizer from a rank file
+func loadBPETokenizer(name, path string) (*bpeTokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		token, rankStr, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			continue
+		}
+		rank, err := strconv.Atoi(rankStr)
+		if err != nil {
+			continue
+		}
+		ranks[string(decoded)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &bpeTokenizer{name: name, ranks: ranks, cache: make(map[string]int)}, nil
+}
+
+func (t *bpeTokenizer) Name() string {
+	return t.name + " (bpe)"
+}
+
+func (t *bpeTokenizer) Count(text string) int {
+	total := 0
+	for _, piece := range bpeSplitPattern.FindAllString(text, -1) {
+		total += t.countPiece(piece)
+	}
+	return total
+}
+
+// Count the tokens of a single pre-tokenized piece
+func (t *bpeTokenizer) countPiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if n, ok := t.cache[piece]; ok {
+		return n
+	}
+
+	// Start from single bytes and repeatedly merge the lowest-ranked pair
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	if len(t.cache) >= bpeCacheSize {
+		clear(t.cache)
+	}
+	t.cache[piece] = len(parts)
+	return len(parts)
+}
+
+// The server's estimate for text no client has counted: ~4 UTF-16 code
+// units per token, as JavaScript measures string length
+func estimateTokens(text string) int {
+	return (len(utf16.Encode([]rune(text))) + 3) / 4
+}
+
+// Trim output from the front until it fits a token budget
+func tailTokens(tok Tokenizer, output string, limit int) string {
+	output = strings.TrimSpace(output)
+	if tok.Count(output) <= limit {
+		return output
+	}
+
+	lines := strings.Split(output, "\n")
+	for len(lines) > 1 && tok.Count(strings.Join(lines, "\n")) > limit {
+		lines = lines[1:]
+	}
+	return "...\n" + strings.Join(lines, "\n")
+}
+
+// Rough cost estimate in USD for a number of tokens (approximate Groq pricing)
+func estimateCost(tokens int) float64 {
+	return float64(tokens) * 0.00027 / 1000
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Names a shell accepts as variables
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Variables set with /env for the commands the AI's tools run. They reach
+// only those commands, never painika or the server itself, and last for
+// the session unless saved with it.
+type ToolEnv struct {
+	Vars  map[string]string
+	Saved map[string]bool // Kept with the saved session (/env set --save)
+}
+
+// The variables saved with the session, nil when there are none
+func (e ToolEnv) saved() map[string]string {
+	var saved map[string]string
+	for name := range e.Saved {
+		if saved == nil {
+			saved = map[string]string{}
+		}
+		saved[name] = e.Vars[name]
+	}
+	return saved
+}
+
+// Bring back the variables a saved session kept
+func (e *ToolEnv) restore(vars map[string]string) {
+	e.Vars, e.Saved = map[string]string{}, map[string]bool{}
+	for name, value := range vars {
+		e.Vars[name] = value
+		e.Saved[name] = true
+	}
+}
+
+// Print the variables, hiding the values of names that look like secrets
+func showToolEnv(env ToolEnv) {
+	if len(env.Vars) == 0 {
+		fmt.Println("🔧 No tool environment variables set (add one with /env set KEY=value)")
+		fmt.Println()
+		return
+	}
+	names := make([]string, 0, len(env.Vars))
+	for name := range env.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("🔧 Tool environment:")
+	for _, name := range names {
+		value := env.Vars[name]
+		if matchEnvName(name, defaultDeniedEnv) {
+			value = "••••••"
+		}
+		note := ""
+		if env.Saved[name] {
+			note = " (saved with the session)"
+		}
+		fmt.Printf("   %s=%s%s\n", name, value, note)
+	}
+	fmt.Println()
+}
+
+// Handle /env, /env set [--save] KEY=value, and /env unset KEY...
+func handleEnv(client *Client, args string) {
+	env := &client.config.ToolEnv
+	sub, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(sub) {
+	case "", "list":
+		showToolEnv(*env)
+		return
+	case "set":
+		save := false
+		if after, ok := strings.CutPrefix(rest, "--save"); ok && (after == "" || after[0] == ' ') {
+			save, rest = true, strings.TrimSpace(after)
+		}
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok || !envNamePattern.MatchString(name) {
+			fmt.Println("Usage: /env set [--save] KEY=value")
+			fmt.Println()
+			return
+		}
+
+		vars := map[string]string{name: value}
+		for other, value := range env.Vars {
+			if other != name {
+				vars[other] = value
+			}
+		}
+		if err := client.UpdateSettings(map[string]interface{}{"toolEnv": vars}); err != nil {
+			fmt.Printf("❌ Failed to set %s: %v\n\n", name, err)
+			return
+		}
+		env.Vars = vars
+		if env.Saved == nil {
+			env.Saved = map[string]bool{}
+		}
+		if save {
+			env.Saved[name] = true
+			fmt.Printf("🔧 %s set for the tools' commands, and saved with the session\n\n", name)
+		} else {
+			delete(env.Saved, name)
+			fmt.Printf("🔧 %s set for the tools' commands this session\n\n", name)
+		}
+	case "unset":
+		names := strings.Fields(rest)
+		if len(names) == 0 {
+			fmt.Println("Usage: /env unset KEY...")
+			fmt.Println()
+			return
+		}
+		vars := map[string]string{}
+		for name, value := range env.Vars {
+			vars[name] = value
+		}
+		for _, name := range names {
+			if _, ok := vars[name]; !ok {
+				fmt.Printf("❌ %s was not set with /env\n\n", name)
+				return
+			}
+			delete(vars, name)
+		}
+		if err := client.UpdateSettings(map[string]interface{}{"toolEnv": vars}); err != nil {
+			fmt.Printf("❌ Failed to unset %s: %v\n\n", strings.Join(names, ", "), err)
+			return
+		}
+		env.Vars = vars
+		for _, name := range names {
+			delete(env.Saved, name)
+		}
+		fmt.Printf("🔧 Unset %s\n\n", strings.Join(names, ", "))
+	default:
+		fmt.Println("Usage: /env [set [--save] KEY=value | unset KEY...]")
+		fmt.Println()
+	}
+}
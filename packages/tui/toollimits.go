@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Limits on one tool's runs, from tool_limits in the config files. Tools left
+// out keep the server's defaults: bash stops after 120s and keeps 200 KB of
+// output, readFile returns the first 1 MB of a file.
+type ToolLimitConfig struct {
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"` // Commands only: bash and project tools
+	MaxOutputKB    int `json:"max_output_kb,omitempty"`
+}
+
+// The server's defaults for the tools that have them
+var defaultToolLimits = map[string]ToolLimitConfig{
+	"bash":     {TimeoutSeconds: 120, MaxOutputKB: 200},
+	"readFile": {MaxOutputKB: 1024},
+}
+
+// Tool limit as the server takes it
+type ToolLimit struct {
+	TimeoutMs      int `json:"timeoutMs,omitempty"`
+	MaxOutputBytes int `json:"maxOutputBytes,omitempty"`
+}
+
+// Merge two files' tool_limits: each field set in override wins
+func mergeToolLimits(base, override map[string]ToolLimitConfig) map[string]ToolLimitConfig {
+	if len(override) == 0 {
+		return base
+	}
+	merged := map[string]ToolLimitConfig{}
+	for name, limit := range base {
+		merged[name] = limit
+	}
+	for name, limit := range override {
+		current := merged[name]
+		if limit.TimeoutSeconds != 0 {
+			current.TimeoutSeconds = limit.TimeoutSeconds
+		}
+		if limit.MaxOutputKB != 0 {
+			current.MaxOutputKB = limit.MaxOutputKB
+		}
+		merged[name] = current
+	}
+	return merged
+}
+
+// Apply a project file's tool_limits only where they are lower than the
+// limits set so far, or the server's defaults
+func tightenToolLimits(base, project map[string]ToolLimitConfig) map[string]ToolLimitConfig {
+	if len(project) == 0 {
+		return base
+	}
+	merged := map[string]ToolLimitConfig{}
+	for name, limit := range base {
+		merged[name] = limit
+	}
+	for name, limit := range project {
+		current := merged[name]
+		fallback := defaultToolLimits[name]
+		current.TimeoutSeconds = lowerLimit(current.TimeoutSeconds, limit.TimeoutSeconds, fallback.TimeoutSeconds)
+		current.MaxOutputKB = lowerLimit(current.MaxOutputKB, limit.MaxOutputKB, fallback.MaxOutputKB)
+		if current != (ToolLimitConfig{}) {
+			merged[name] = current
+		}
+	}
+	return merged
+}
+
+// Tool limits to send to the server, by tool name
+func toolLimitsConfig(user UserConfig) map[string]ToolLimit {
+	limits := map[string]ToolLimit{}
+	for name, limit := range user.ToolLimits {
+		limits[name] = ToolLimit{
+			TimeoutMs:      limit.TimeoutSeconds * 1000,
+			MaxOutputBytes: limit.MaxOutputKB * 1024,
+		}
+	}
+	return limits
+}
+
+// Read RESULT_PREVIEW_TOKENS: tool results larger than this reach the model
+// as a preview it can expand with expand_result (0 always sends them whole)
+func resultPreviewConfig() (int, error) {
+	value := getEnv("RESULT_PREVIEW_TOKENS", "2000")
+	tokens, err := strconv.Atoi(value)
+	if err != nil || tokens < 0 {
+		return 0, fmt.Errorf("RESULT_PREVIEW_TOKENS must be a number of tokens, got %q", value)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,285 @@
+// Command protogen generates the Go protocol types from the OpenAPI
+// document shared by the client and server.
+//
+//	go run ./tools/protogen -in ../protocol/openapi.json -out protocol_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// JSON object that remembers key order, so types are emitted as written
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *object) get(key string) interface{} {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+func (o *object) obj(key string) *object {
+	v, _ := o.get(key).(*object)
+	return v
+}
+
+func (o *object) str(key string) string {
+	v, _ := o.get(key).(string)
+	return v
+}
+
+// Decode one JSON value, keeping object key order
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := &object{values: map[string]interface{}{}}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key)
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+	case '[':
+		var items []interface{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		_, err := dec.Token()
+		return items, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// Initialisms kept upper case in Go names
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "http": "HTTP", "json": "JSON"}
+
+// Convert a camelCase JSON name to an exported Go name
+func goName(name string) string {
+	var words []string
+	start := 0
+	runes := []rune(name)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1]) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	var b strings.Builder
+	for _, word := range words {
+		if upper, ok := initialisms[strings.ToLower(word)]; ok {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+type generator struct {
+	schemas *object
+}
+
+// Go type for a schema; optional object references and nullable
+// scalars ("type": ["integer", "null"]) become pointers
+func (g *generator) goType(schema *object, optional bool) (string, error) {
+	if types, ok := schema.get("type").([]interface{}); ok {
+		return g.nullableType(types)
+	}
+
+	if ref := schema.str("$ref"); ref != "" {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target := g.schemas.obj(name)
+		if target == nil {
+			return "", fmt.Errorf("unknown reference %s", ref)
+		}
+		if optional && target.str("type") == "object" {
+			return "*" + name, nil
+		}
+		return name, nil
+	}
+
+	switch schema.str("type") {
+	case "string":
+		return "string", nil
+	case "integer":
+		if schema.str("format") == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		items := schema.obj("items")
+		if items == nil {
+			return "[]interface{}", nil
+		}
+		elem, err := g.goType(items, false)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case "object":
+		if schema.obj("properties") != nil {
+			return "", fmt.Errorf("inline objects are not supported; add a named schema")
+		}
+		return "map[string]interface{}", nil
+	case "":
+		return "interface{}", nil
+	}
+	return "", fmt.Errorf("unsupported type %q", schema.str("type"))
+}
+
+// Pointer type for a nullable scalar, so zero values survive omitempty
+func (g *generator) nullableType(types []interface{}) (string, error) {
+	var base string
+	for _, t := range types {
+		if name, _ := t.(string); name != "null" {
+			if base != "" {
+				return "", fmt.Errorf("union types %v are not supported", types)
+			}
+			base = name
+		}
+	}
+
+	elem, err := g.goType(&object{keys: []string{"type"}, values: map[string]interface{}{"type": base}}, false)
+	if err != nil {
+		return "", err
+	}
+	return "*" + elem, nil
+}
+
+// Emit one struct type for a named object schema
+func (g *generator) writeType(b *bytes.Buffer, name string, schema *object) error {
+	if description := schema.str("description"); description != "" {
+		fmt.Fprintf(b, "// %s\n", description)
+	}
+	fmt.Fprintf(b, "type %s struct {\n", name)
+
+	required := map[string]bool{}
+	if list, ok := schema.get("required").([]interface{}); ok {
+		for _, item := range list {
+			required[item.(string)] = true
+		}
+	}
+
+	properties := schema.obj("properties")
+	if properties == nil {
+		return fmt.Errorf("%s: object schema without properties", name)
+	}
+	for _, field := range properties.keys {
+		prop := properties.obj(field)
+		optional := !required[field]
+		fieldType, err := g.goType(prop, optional)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, field, err)
+		}
+
+		tag := field
+		if optional {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(b, "\t%s %s `json:\"%s\"`", goName(field), fieldType, tag)
+		if description := prop.str("description"); description != "" {
+			fmt.Fprintf(b, " // %s", description)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("}\n\n")
+	return nil
+}
+
+func generate(doc *object, source, pkg string) ([]byte, error) {
+	g := &generator{schemas: doc.obj("components").obj("schemas")}
+	if g.schemas == nil {
+		return nil, fmt.Errorf("no components.schemas in %s", source)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by protogen from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	// info.version is the protocol version checked in the /health handshake
+	version := doc.obj("info").str("version")
+	if _, err := strconv.Atoi(version); err != nil {
+		return nil, fmt.Errorf("info.version must be an integer protocol version, got %q", version)
+	}
+	fmt.Fprintf(&b, "// Client/server protocol version; must match PROTOCOL_VERSION in the server\nconst protocolVersion = %s\n\n", version)
+
+	for _, name := range g.schemas.keys {
+		if err := g.writeType(&b, name, g.schemas.obj(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return format.Source(b.Bytes())
+}
+
+func main() {
+	in := flag.String("in", "../protocol/openapi.json", "OpenAPI document")
+	out := flag.String("out", "protocol_gen.go", "generated Go file")
+	pkg := flag.String("package", "main", "package of the generated file")
+	flag.Parse()
+
+	file, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	value, err := decodeValue(json.NewDecoder(file))
+	if err != nil {
+		log.Fatalf("failed to parse %s: %v", *in, err)
+	}
+	doc, ok := value.(*object)
+	if !ok {
+		log.Fatalf("%s is not a JSON object", *in)
+	}
+
+	source, err := generate(doc, *in, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
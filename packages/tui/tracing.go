@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP span kinds and status codes
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+// Span structure (a subset of the OTLP data model)
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Name       string
+	Kind       int
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Err        string
+}
+
+// OTLP exporter settings, read once from the standard OTEL_* variables
+var tracer struct {
+	once     sync.Once
+	endpoint string
+	headers  map[string]string
+	service  string
+	pending  sync.WaitGroup
+}
+
+// Load the exporter configuration
+func tracingConfig() {
+	tracer.once.Do(func() {
+		endpoint := getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		if endpoint == "" {
+			if base := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); base != "" {
+				endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+			}
+		}
+		tracer.endpoint = endpoint
+		tracer.service = getEnv("OTEL_SERVICE_NAME", "painika")
+
+		tracer.headers = make(map[string]string)
+		for _, pair := range splitList(getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")) {
+			if key, value, ok := strings.Cut(pair, "="); ok {
+				tracer.headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	})
+}
+
+// Check whether tracing is enabled
+func tracingEnabled() bool {
+	tracingConfig()
+	return tracer.endpoint != ""
+}
+
+// Generate a random hex ID of n bytes
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start a span; an empty traceID starts a new trace
+func startSpan(name, traceID string) *Span {
+	if traceID == "" {
+		traceID = randomID(16)
+	}
+	return &Span{
+		TraceID:    traceID,
+		SpanID:     randomID(8),
+		Name:       name,
+		Kind:       spanKindInternal,
+		StartTime:  time.Now(),
+		Attributes: map[string]interface{}{},
+	}
+}
+
+// Start a child span with explicit timing
+func (s *Span) Child(name string, start, end time.Time) *Span {
+	child := startSpan(name, s.TraceID)
+	child.ParentID = s.SpanID
+	child.StartTime = start
+	child.EndTime = end
+	return child
+}
+
+// End a span, recording an error if any
+func (s *Span) End(err error) {
+	s.EndTime = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+}
+
+// Convert Unix milliseconds to a time
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms)
+}
+
+// Build and export spans for a completed turn
+func traceTurn(turn *Span, config Config, messages []Message) {
+	turn.End(nil)
+	if !tracingEnabled() {
+		return
+	}
+
+	turn.Attributes["painika.model"] = config.Model
+	turn.Attributes["painika.provider"] = config.Provider
+	spans := []*Span{turn}
+
+	// Tool results reference their calls by ID
+	toolNames := make(map[string]string)
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Name
+		}
+	}
+
+	for _, msg := range messages {
+		if msg.Timing == nil {
+			continue
+		}
+		start, end := fromMillis(msg.Timing.StartTime), fromMillis(msg.Timing.EndTime)
+
+		switch msg.Role {
+		case "assistant":
+			span := turn.Child("provider.request", start, end)
+			span.Kind = spanKindClient
+			span.Attributes["gen_ai.system"] = config.Provider
+			span.Attributes["gen_ai.request.model"] = config.Model
+			if msg.Tokens != nil {
+				span.Attributes["gen_ai.usage.input_tokens"] = msg.Tokens.Input
+				span.Attributes["gen_ai.usage.output_tokens"] = msg.Tokens.Output
+			}
+			span.Attributes["painika.tool_calls"] = len(msg.ToolCalls)
+			spans = append(spans, span)
+		case "tool":
+			for _, result := range msg.ToolResults {
+				name := toolNames[result.ID]
+				span := turn.Child("tool."+name, start, end)
+				span.Attributes["painika.tool.name"] = name
+				span.Err = result.Error
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	exportSpans(spans...)
+}
+
+// OTLP/HTTP JSON attribute encoding
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]interface{}
+		switch val := value.(type) {
+		case int:
+			v = map[string]interface{}{"intValue": fmt.Sprint(val)}
+		case bool:
+			v = map[string]interface{}{"boolValue": val}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(val)}
+		}
+		out = append(out, map[string]interface{}{"key": key, "value": v})
+	}
+	return out
+}
+
+// Export spans in the background via OTLP/HTTP JSON
+func exportSpans(spans ...*Span) {
+	if !tracingEnabled() || len(spans) == 0 {
+		return
+	}
+
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		status := map[string]interface{}{"code": statusCodeOK}
+		if s.Err != "" {
+			status = map[string]interface{}{"code": statusCodeError, "message": s.Err}
+		}
+		otlpSpans = append(otlpSpans, map[string]interface{}{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"parentSpanId":      s.ParentID,
+			"name":              s.Name,
+			"kind":              s.Kind,
+			"startTimeUnixNano": fmt.Sprint(s.StartTime.UnixNano()),
+			"endTimeUnixNano":   fmt.Sprint(s.EndTime.UnixNano()),
+			"attributes":        otlpAttributes(s.Attributes),
+			"status":            status,
+		})
+	}
+
+	hostname, _ := os.Hostname()
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{
+						"service.name": tracer.service,
+						"host.name":    hostname,
+					}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "painika"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	tracer.pending.Add(1)
+	go func() {
+		defer tracer.pending.Done()
+
+		req, err := http.NewRequest("POST", tracer.endpoint, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range tracer.headers {
+			req.Header.Set(key, value)
+		}
+
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			serverLog.Add("[otel] export failed: " + err.Error())
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// Wait briefly for in-flight exports before exiting
+func flushTraces() {
+	done := make(chan struct{})
+	go func() {
+		tracer.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Placeholder that stands in for a fenced code block during translation
+const codePlaceholder = "[[CODE_BLOCK_%d]]"
+
+// Run a one-off completion that is not added to the conversation
+func (c *Client) Complete(instructions, content string) (string, error) {
+	return c.CompleteWith(nil, instructions, content)
+}
+
+// One-off completion from another model, such as the critic (the session's
+// own model when nil)
+func (c *Client) CompleteWith(model *Config, instructions, content string) (string, error) {
+	if err := c.checkBudget(); err != nil {
+		return "", err
+	}
+
+	payload := map[string]interface{}{
+		"instructions": instructions,
+		"content":      content,
+	}
+	if model != nil {
+		payload["groq"] = providerConfig(*model)
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.client.Post(c.config.ServerURL+"/complete", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result CompleteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if !result.Success {
+		return "", fmt.Errorf("failed to complete: %s", result.Error)
+	}
+
+	return result.Content, nil
+}
+
+// Replace fenced code blocks (fences included) with numbered placeholders
+func maskCodeBlocks(text string) (string, []string) {
+	var out, block []string
+	var blocks []string
+	inBlock := false
+
+	for _, line := range strings.Split(text, "\n") {
+		fence := strings.HasPrefix(strings.TrimSpace(line), "```")
+		switch {
+		case inBlock:
+			block = append(block, line)
+			if fence {
+				out = append(out, fmt.Sprintf(codePlaceholder, len(blocks)+1))
+				blocks = append(blocks, strings.Join(block, "\n"))
+				inBlock = false
+			}
+		case fence:
+			block = []string{line}
+			inBlock = true
+		default:
+			out = append(out, line)
+		}
+	}
+
+	// An unclosed fence runs to the end of the text
+	if inBlock {
+		out = append(out, fmt.Sprintf(codePlaceholder, len(blocks)+1))
+		blocks = append(blocks, strings.Join(block, "\n"))
+	}
+
+	return strings.Join(out, "\n"), blocks
+}
+
+// Put the original code blocks back in place of their placeholders
+func restoreCodeBlocks(text string, blocks []string) string {
+	for i, block := range blocks {
+		placeholder := fmt.Sprintf(codePlaceholder, i+1)
+		if strings.Contains(text, placeholder) {
+			text = strings.Replace(text, placeholder, block, 1)
+		} else {
+			// The model dropped the placeholder; keep the code anyway
+			text += "\n\n" + block
+		}
+	}
+	return text
+}
+
+// Translate markdown into lang, leaving fenced code blocks untouched
+func translateText(client *Client, text, lang string) (string, error) {
+	masked, blocks := maskCodeBlocks(text)
+	if strings.TrimSpace(masked) == "" {
+		return text, nil
+	}
+
+	instructions := fmt.Sprintf("Translate the user's text into %s. Keep the markdown formatting. "+
+		"Copy placeholders like [[CODE_BLOCK_1]], inline `code`, file paths, commands, and identifiers exactly as written. "+
+		"Reply with the translation only.", lang)
+
+	translated, err := client.Complete(instructions, masked)
+	if err != nil {
+		return "", err
+	}
+	return restoreCodeBlocks(strings.TrimSpace(translated), blocks), nil
+}
+
+// Handle /translate <lang> - show the last response in another language
+func translateLastResponse(client *Client, lang string) {
+	if lang == "" {
+		fmt.Println("Usage: /translate <language>")
+		fmt.Println()
+		return
+	}
+
+	content, err := lastAssistantMessage(client)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("🌐 Translating to %s", lang)
+	stop := showThinking()
+	translated, err := translateText(client, content, lang)
+	stop()
+	if err != nil {
+		fmt.Printf("\n❌ %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("\n🤖 %s\n\n", linkifyReply(formatForTerminal(translated, 3)))
+}
+
+// Translate every message in a copy of the conversation
+func translateConversation(client *Client, conversation *Conversation, lang string) (*Conversation, error) {
+	translated := *conversation
+	translated.Messages = append([]Message{}, conversation.Messages...)
+
+	for i, msg := range translated.Messages {
+		if msg.Role == "system" || msg.Role == "tool" || strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		content, err := translateText(client, msg.Content, lang)
+		if err != nil {
+			return nil, err
+		}
+		translated.Messages[i].Content = content
+	}
+
+	return &translated, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Connection pool limits; the default transport keeps only two idle
+// connections per host, so parallel requests keep opening new ones
+const (
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 32
+	idleConnTimeout     = 90 * time.Second
+)
+
+var dialer = &net.Dialer{
+	Timeout:   10 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// Dial TCP, or the Unix domain socket behind a unix:// server URL
+func dialServer(ctx context.Context, network, addr string) (net.Conn, error) {
+	if conn, ok, err := dialSocketHost(addr); ok {
+		return conn, err
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// Transport shared by every client of the server, so batch workers reuse
+// pooled connections (and HTTP/2 when the server is behind TLS)
+var sharedTransport = &http.Transport{
+	Proxy:                 serverProxy,
+	DialContext:           dialServer,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          maxIdleConns,
+	MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+	IdleConnTimeout:       idleConnTimeout,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// Use the environment's proxy, except for socket servers
+func serverProxy(req *http.Request) (*url.URL, error) {
+	if socketPath("http://"+req.URL.Host) != "" {
+		return nil, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// HTTP client for server requests; turns can run long, so no overall timeout
+var httpClient = &http.Client{Transport: authTransport{sharedTransport}}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often a running turn's journal is brought up to date
+const turnJournalInterval = time.Second
+
+// Most of an interrupted reply quoted back to the model on resume
+const maxResumedPartial = 2000
+
+// A turn in progress, written next to the saved session while it runs and
+// removed when it ends, so a client that crashes mid-turn leaves behind
+// what the turn had done
+type TurnJournal struct {
+	SessionID string    `json:"sessionId"`
+	Workspace string    `json:"workspace"`
+	PID       int       `json:"pid"`
+	Input     string    `json:"input"`
+	StartedAt string    `json:"startedAt"` // ISO 8601 format
+	UpdatedAt string    `json:"updatedAt"` // ISO 8601 format
+	Before    int       `json:"before"`    // Messages in the saved session when the turn started
+	Messages  []Message `json:"messages"`  // Added by the turn so far: the request, tool calls, results
+	Partial   string    `json:"partial"`   // Reply text streamed so far
+}
+
+// Path of a session's turn journal
+func turnJournalPath(dir, id string) string {
+	return filepath.Join(dir, id+".turn.json")
+}
+
+// Journal of the running turn
+type turnRecorder struct {
+	mu      sync.Mutex
+	journal TurnJournal
+	path    string
+	written string // Last contents written, to skip writes that change nothing
+	done    chan bool
+	stopped sync.WaitGroup
+}
+
+// Start journaling a turn: save the session as it is before the turn, then
+// keep the journal up to date until stop; nil when the turn can't or
+// shouldn't be journaled (incognito, or the session can't be saved)
+func startTurnJournal(client *Client, input string) *turnRecorder {
+	if client.config.Incognito.On {
+		return nil
+	}
+	session, err := recordSession(client)
+	if err != nil {
+		return nil
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	r := &turnRecorder{
+		journal: TurnJournal{
+			SessionID: session.ID,
+			Workspace: session.Workspace,
+			PID:       os.Getpid(),
+			Input:     input,
+			StartedAt: now,
+			UpdatedAt: now,
+			Before:    len(session.Conversation.Messages),
+		},
+		path: turnJournalPath(dir, session.ID),
+		done: make(chan bool),
+	}
+	r.write()
+
+	r.stopped.Add(1)
+	go func() {
+		defer r.stopped.Done()
+		ticker := time.NewTicker(turnJournalInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				if conversation, err := client.GetConversation(); err == nil && len(conversation.Messages) > r.journal.Before {
+					r.mu.Lock()
+					r.journal.Messages = conversation.Messages[r.journal.Before:]
+					r.mu.Unlock()
+				}
+				r.write()
+			}
+		}
+	}()
+	return r
+}
+
+// Note reply text as it streams in
+func (r *turnRecorder) Text(text string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.journal.Partial += text
+	r.mu.Unlock()
+}
+
+// Write the journal aside and rename it over the old one, so a crash
+// leaves either
+func (r *turnRecorder) write() {
+	r.mu.Lock()
+	r.journal.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(r.journal, "", "  ")
+	r.mu.Unlock()
+	if err != nil || string(data) == r.written {
+		return
+	}
+	if os.WriteFile(r.path+".tmp", data, 0600) == nil && os.Rename(r.path+".tmp", r.path) == nil {
+		r.written = string(data)
+	}
+}
+
+// End the turn; its journal is removed, the session being saved by then
+func (r *turnRecorder) Stop() {
+	if r == nil {
+		return
+	}
+	close(r.done)
+	r.stopped.Wait()
+	os.Remove(r.path)
+}
+
+// Journals of turns in this workspace whose client is gone, newest first
+func interruptedTurns(workspace string) []TurnJournal {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.turn.json"))
+	var turns []TurnJournal
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var journal TurnJournal
+		if json.Unmarshal(data, &journal) != nil || journal.SessionID == "" {
+			continue
+		}
+		if journal.Workspace != workspace || (journal.PID != os.Getpid() && processAlive(journal.PID)) {
+			continue
+		}
+		turns = append(turns, journal)
+	}
+	for i := 1; i < len(turns); i++ {
+		for j := i; j > 0 && turns[j].UpdatedAt > turns[j-1].UpdatedAt; j-- {
+			turns[j], turns[j-1] = turns[j-1], turns[j]
+		}
+	}
+	return turns
+}
+
+// Messages of an interrupted turn that can be restored as they are: a
+// final request for tool calls that have no results yet is left out, and
+// returned as the calls that were pending
+func settledTurnMessages(messages []Message) ([]Message, []ToolCall) {
+	results := map[string]bool{}
+	for _, message := range messages {
+		for _, result := range message.ToolResults {
+			results[result.ID] = true
+		}
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		var pending []ToolCall
+		for _, call := range messages[i].ToolCalls {
+			if !results[call.ID] {
+				pending = append(pending, call)
+			}
+		}
+		if len(pending) > 0 {
+			return messages[:i], pending
+		}
+	}
+	return messages, nil
+}
+
+// Message that picks an interrupted turn back up, saying what it had done
+func resumeTurnPrompt(journal TurnJournal, settled []Message, pending []ToolCall) string {
+	var b strings.Builder
+	b.WriteString("The client stopped in the middle of your last turn, before it finished.")
+	ran := 0
+	for _, message := range settled {
+		ran += len(message.ToolResults)
+	}
+	if ran > 0 {
+		fmt.Fprintf(&b, " The %d tool call(s) above had finished.", ran)
+	}
+	if len(pending) > 0 {
+		b.WriteString(" These calls had started and may or may not have taken effect; check before repeating them:")
+		for _, call := range pending {
+			b.WriteString("\n- " + describeToolCall(call))
+		}
+	}
+	if partial := strings.TrimSpace(journal.Partial); partial != "" {
+		if len(partial) > maxResumedPartial {
+			partial = "..." + partial[len(partial)-maxResumedPartial:]
+		}
+		fmt.Fprintf(&b, "\n\nYour reply so far:\n%s", partial)
+	}
+	hasRequest := false
+	for _, message := range settled {
+		hasRequest = hasRequest || message.Role == "user"
+	}
+	if !hasRequest {
+		fmt.Fprintf(&b, "\n\nThe request was:\n%s", journal.Input)
+	}
+	b.WriteString("\n\nContinue the request from where it stopped.")
+	return b.String()
+}
+
+// At startup, offer to resume or discard a turn a crashed client left
+// unfinished in this workspace
+func offerInterruptedTurn(client *Client) {
+	workspace := client.config.FileAccess.Root
+	if workspace == "" {
+		workspace, _ = os.Getwd()
+	}
+	turns := interruptedTurns(workspace)
+	if len(turns) == 0 {
+		return
+	}
+	journal := turns[0]
+	dir, _ := sessionsDir()
+	path := turnJournalPath(dir, journal.SessionID)
+
+	settled, pending := settledTurnMessages(journal.Messages)
+	when := journal.UpdatedAt
+	if t, err := time.Parse(time.RFC3339, journal.UpdatedAt); err == nil {
+		when = t.Local().Format("Jan 02 15:04")
+	}
+	renderer.Notice(fmt.Sprintf("⏸️  A turn of session %s was interrupted at %s: %s", shortID(journal.SessionID), when, truncateWidth(strings.Join(strings.Fields(journal.Input), " "), 50)))
+	ran := 0
+	for _, message := range settled {
+		ran += len(message.ToolResults)
+	}
+	renderer.Notice(fmt.Sprintf("   %d tool call(s) finished, %d pending, %d characters of reply", ran, len(pending), len(journal.Partial)))
+	for _, call := range pending {
+		renderer.Notice("   ⏳ " + truncateWidth(describeToolCall(call), termWidth()-8))
+	}
+	if !renderer.Interactive() {
+		renderer.Notice("💡 Start painika at a terminal to resume or discard it")
+		return
+	}
+
+	fmt.Print("   Resume it (r), discard it (d), or decide later (Enter)? ")
+	answer, _ := stdin.ReadLine()
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "d", "discard":
+		os.Remove(path)
+		fmt.Println("🗑️  Discarded; files the finished tool calls changed stay changed")
+		fmt.Println()
+		return
+	case "r", "resume":
+	default:
+		fmt.Println()
+		return
+	}
+
+	session, err := loadSession(journal.SessionID)
+	if err == nil && (session == nil || session.Conversation == nil) {
+		err = fmt.Errorf("session %s was not saved", shortID(journal.SessionID))
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to resume the turn: %v\n\n", err)
+		return
+	}
+	conversation := session.Conversation
+	if len(conversation.Messages) > journal.Before {
+		conversation.Messages = conversation.Messages[:journal.Before]
+	}
+	conversation.Messages = append(conversation.Messages, settled...)
+	client.config.ToolEnv.restore(session.Env)
+	if err := restoreWithSettings(client, conversation); err != nil {
+		fmt.Printf("❌ Failed to resume the turn: %v\n\n", err)
+		return
+	}
+	os.Remove(path)
+	fmt.Printf("📂 Resumed session %s with the interrupted turn (%d message(s) recovered)\n\n", shortID(session.ID), len(settled))
+	handleMessage(client, resumeTurnPrompt(journal, settled, pending))
+}
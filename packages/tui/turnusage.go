@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	anomalyFloor   = 2000  // Turns adding fewer tokens are never flagged
+	anomalyCeiling = 20000 // Turns adding more are always flagged
+	anomalyRatio   = 3.0   // Flag turns adding this many times the average turn
+	anomalyShare   = 0.5   // ...or this share of the conversation on their own
+)
+
+// Token usage of one turn
+type TurnUsage struct {
+	Turn   int
+	Input  int // Prompt tokens reported by the provider across the turn's requests
+	Output int
+	Added  int // Tokens the turn's messages added to the context
+
+	// Heaviest message of the turn
+	LargestID     string
+	LargestRole   string
+	LargestTokens int
+	Dropped       bool // Removed from history with /drop or the warning
+
+	Requests int                   // Provider requests made during the turn
+	ModelMs  int64                 // Time spent waiting on the provider
+	Tools    map[string]*ToolUsage // Calls by tool name
+}
+
+// Calls of one tool within a turn
+type ToolUsage struct {
+	Calls  int
+	Errors int
+	Ms     int64 // Execution time
+	Tokens int   // Arguments plus the result added to the context
+}
+
+// Total execution time of the turn's tool calls
+func (u TurnUsage) toolMs() int64 {
+	var ms int64
+	for _, t := range u.Tools {
+		ms += t.Ms
+	}
+	return ms
+}
+
+// Usage of every turn since the session started
+var turnUsage []TurnUsage
+
+// Why a turn stands out from the earlier ones ("" if it does not)
+func (u TurnUsage) anomaly(prior []TurnUsage) string {
+	if u.Added < anomalyFloor {
+		return ""
+	}
+	if len(prior) == 0 {
+		if u.Added >= anomalyCeiling {
+			return "on the first turn"
+		}
+		return ""
+	}
+
+	priorTotal := 0
+	for _, t := range prior {
+		priorTotal += t.Added
+	}
+	average := float64(priorTotal) / float64(len(prior))
+
+	switch {
+	case average > 0 && float64(u.Added) >= anomalyRatio*average:
+		return fmt.Sprintf("%.0f× the average turn", float64(u.Added)/average)
+	case float64(u.Added) >= anomalyShare*float64(priorTotal+u.Added):
+		return fmt.Sprintf("%.0f%% of the conversation", float64(u.Added)*100/float64(priorTotal+u.Added))
+	case u.Added >= anomalyCeiling:
+		return "more than " + formatTokens(anomalyCeiling)
+	}
+	return ""
+}
+
+// Format a token count with thousands separators
+func formatTokens(tokens int) string {
+	digits := strconv.Itoa(tokens)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// Record a turn's token usage and warn when it is out of proportion
+func trackTurnUsage(client *Client, messages []Message) {
+	tok := tokenizerForModel(client.config.Model)
+
+	usage := TurnUsage{Turn: len(turnUsage) + 1, Tools: map[string]*ToolUsage{}}
+	calls := map[string]ToolCall{}
+	for _, msg := range messages {
+		if msg.Tokens != nil {
+			usage.Input += msg.Tokens.Input
+			usage.Output += msg.Tokens.Output
+		}
+		tokens := messageTokens(tok, msg)
+		usage.Added += tokens
+		if tokens > usage.LargestTokens {
+			usage.LargestID, usage.LargestRole, usage.LargestTokens = msg.ID, msg.Role, tokens
+		}
+
+		switch msg.Role {
+		case "assistant":
+			usage.Requests++
+			if msg.Timing != nil {
+				usage.ModelMs += msg.Timing.EndTime - msg.Timing.StartTime
+			}
+			for _, call := range msg.ToolCalls {
+				calls[call.ID] = call
+			}
+		case "tool":
+			if len(msg.ToolResults) > 0 {
+				trackToolCall(tok, &usage, calls[msg.ToolResults[0].ID], msg, tokens)
+			}
+		}
+	}
+
+	prior := turnUsage
+	turnUsage = append(turnUsage, usage)
+
+	if reason := usage.anomaly(prior); reason != "" {
+		warnTokenAnomaly(client, usage, reason)
+	}
+}
+
+// Add one tool call and its result to the turn's per-tool totals
+func trackToolCall(tok Tokenizer, usage *TurnUsage, call ToolCall, result Message, resultTokens int) {
+	name := call.Name
+	if name == "" {
+		name = "unknown"
+	}
+	tool := usage.Tools[name]
+	if tool == nil {
+		tool = &ToolUsage{}
+		usage.Tools[name] = tool
+	}
+	tool.Calls++
+	if result.ToolResults[0].Error != "" {
+		tool.Errors++
+	}
+	if result.Timing != nil {
+		tool.Ms += result.Timing.EndTime - result.Timing.StartTime
+	}
+	arguments, _ := json.Marshal(call.Parameters)
+	tool.Tokens += tok.Count(string(arguments)) + resultTokens
+}
+
+// Point at the message that inflated the turn and offer to drop it
+func warnTokenAnomaly(client *Client, usage TurnUsage, reason string) {
+	renderer.Notice(fmt.Sprintf("⚠️  Turn %d added ~%s tokens to the context (%s)", usage.Turn, formatTokens(usage.Added), reason))
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		return
+	}
+	index, description := describeMessage(conversation, usage.LargestID)
+	if index == 0 {
+		return
+	}
+	renderer.Notice(fmt.Sprintf("   Most of it is message #%d (%s, ~%s tokens)", index, description, formatTokens(usage.LargestTokens)))
+
+	if !renderer.Interactive() {
+		renderer.Notice(fmt.Sprintf("💡 Drop it from history with /drop %d", index))
+		return
+	}
+
+	fmt.Println("   [d] drop it from history  [Enter] keep")
+	fmt.Print("   > ")
+	answer, _ := stdin.ReadLine()
+	if strings.EqualFold(answer, "d") || strings.EqualFold(answer, "drop") {
+		dropMessage(client, conversation, index)
+	} else {
+		fmt.Println()
+	}
+}
+
+// Position (1-based) and a short description of a message, 0 if not found
+func describeMessage(conversation *Conversation, id string) (int, string) {
+	for i, msg := range conversation.Messages {
+		if msg.ID != id {
+			continue
+		}
+		if msg.Role != "tool" || len(msg.ToolResults) == 0 {
+			return i + 1, msg.Role
+		}
+		// Name the tool call that produced the result
+		for _, prev := range conversation.Messages[:i] {
+			for _, call := range prev.ToolCalls {
+				if call.ID == msg.ToolResults[0].ID {
+					return i + 1, "result of " + truncateWidth(describeToolCall(call), 50)
+				}
+			}
+		}
+		return i + 1, "tool result"
+	}
+	return 0, ""
+}
+
+// Replace a message's content in the server's history
+func (c *Client) DropMessage(id string) error {
+	resp, err := c.client.Post(c.config.ServerURL+"/messages/"+id+"/drop", "application/json", bytes.NewBufferString("{}"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Success {
+		return fmt.Errorf("failed to drop message: %s", result.Error)
+	}
+
+	return nil
+}
+
+// Drop message n (1-based) and report the tokens freed
+func dropMessage(client *Client, conversation *Conversation, index int) {
+	msg := conversation.Messages[index-1]
+	if err := client.DropMessage(msg.ID); err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+	if _, ok := droppedOriginals[msg.ID]; !ok {
+		droppedOriginals[msg.ID] = compactedMessage{Original: msg}
+	}
+
+	freed := messageTokens(tokenizerForModel(client.config.Model), msg)
+	for i := range turnUsage {
+		if turnUsage[i].LargestID == msg.ID {
+			turnUsage[i].Added -= freed
+			turnUsage[i].Dropped = true
+		}
+	}
+	fmt.Printf("🗑️  Dropped message #%d from history (~%s tokens freed)\n\n", index, formatTokens(freed))
+}
+
+// Handle /drop <n>
+func handleDrop(client *Client, args string) {
+	index, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	if err != nil {
+		fmt.Println("Usage: /drop <n>  (message numbers as in /context and history)")
+		fmt.Println()
+		return
+	}
+
+	conversation, err := client.GetConversation()
+	if err != nil {
+		fmt.Printf("❌ Error getting conversation: %v\n\n", err)
+		return
+	}
+	if index < 1 || index > len(conversation.Messages) || conversation.Messages[index-1].Role == "system" {
+		fmt.Printf("❌ No droppable message #%d (conversation has %d messages)\n\n", index, len(conversation.Messages))
+		return
+	}
+	dropMessage(client, conversation, index)
+}
+
+// Per-turn token deltas for the tokens command
+func turnUsageTable() string {
+	var b strings.Builder
+	b.WriteString("📈 Per turn:\n")
+	b.WriteString("   turn     input   output   +context  largest\n")
+	for _, u := range turnUsage {
+		dropped := ""
+		if u.Dropped {
+			dropped = " (dropped)"
+		}
+		fmt.Fprintf(&b, "   %4d  %8s %8s  %9s  %s ~%s%s\n", u.Turn, formatTokens(u.Input), formatTokens(u.Output),
+			"+"+formatTokens(u.Added), u.LargestRole, formatTokens(u.LargestTokens), dropped)
+	}
+	return b.String()
+}
+
+// Per-tool totals across the session, the heaviest first
+func toolUsageTotals() ([]string, map[string]*ToolUsage) {
+	totals := map[string]*ToolUsage{}
+	var names []string
+	for _, u := range turnUsage {
+		for name, t := range u.Tools {
+			total := totals[name]
+			if total == nil {
+				total = &ToolUsage{}
+				totals[name] = total
+				names = append(names, name)
+			}
+			total.Calls += t.Calls
+			total.Errors += t.Errors
+			total.Ms += t.Ms
+			total.Tokens += t.Tokens
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]].Tokens != totals[names[j]].Tokens {
+			return totals[names[i]].Tokens > totals[names[j]].Tokens
+		}
+		return names[i] < names[j]
+	})
+	return names, totals
+}
+
+// Format milliseconds as seconds
+func formatMs(ms int64) string {
+	return fmt.Sprintf("%.1fs", float64(ms)/1000)
+}
+
+// Time and tokens by tool, then by turn, for /usage tools
+func toolUsageTable() string {
+	var b strings.Builder
+	names, totals := toolUsageTotals()
+
+	allTokens := 0
+	for _, t := range totals {
+		allTokens += t.Tokens
+	}
+	var requests int
+	var modelMs int64
+	for _, u := range turnUsage {
+		requests += u.Requests
+		modelMs += u.ModelMs
+	}
+
+	fmt.Fprintf(&b, "🧰 Usage by tool (%d turns):\n", len(turnUsage))
+	if len(names) == 0 {
+		b.WriteString("   No tools called yet\n")
+	} else {
+		b.WriteString("   tool              calls  errors      time     tokens  share\n")
+		for _, name := range names {
+			t := totals[name]
+			share := 0
+			if allTokens > 0 {
+				share = t.Tokens * 100 / allTokens
+			}
+			fmt.Fprintf(&b, "   %-16s %6d  %6d  %8s  %9s  %4d%%\n", truncateWidth(name, 16), t.Calls, t.Errors,
+				formatMs(t.Ms), "~"+formatTokens(t.Tokens), share)
+		}
+	}
+	fmt.Fprintf(&b, "   Model: %d requests, %s waiting on the provider\n", requests, formatMs(modelMs))
+
+	b.WriteString("📈 Per turn:\n")
+	b.WriteString("   turn     model     tools   heaviest tool\n")
+	for _, u := range turnUsage {
+		heaviest := "-"
+		var top *ToolUsage
+		for name, t := range u.Tools {
+			if top == nil || t.Tokens > top.Tokens || (t.Tokens == top.Tokens && name < heaviest) {
+				heaviest, top = name, t
+			}
+		}
+		if top != nil {
+			heaviest = fmt.Sprintf("%s ×%d (%s, ~%s tokens)", heaviest, top.Calls, formatMs(top.Ms), formatTokens(top.Tokens))
+		}
+		fmt.Fprintf(&b, "   %4d  %8s  %8s   %s\n", u.Turn, formatMs(u.ModelMs), formatMs(u.toolMs()), heaviest)
+	}
+	b.WriteString("💡 Tokens are each call's arguments plus its result, as added to the context\n")
+	return b.String()
+}
+
+// Handle /usage [tools]
+func handleUsage(client *Client, args string) {
+	switch strings.ToLower(args) {
+	case "":
+		showTokenUsage(client)
+	case "tools":
+		if len(turnUsage) == 0 {
+			fmt.Println("🧰 No turns yet this session")
+			fmt.Println()
+			return
+		}
+		renderer.Notice(toolUsageTable())
+	default:
+		fmt.Println("Usage: /usage [tools]")
+		fmt.Println()
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Issue structure common to all VCS hosts
+type Issue struct {
+	Number string
+	Title  string
+	Body   string
+	State  string
+	URL    string
+}
+
+// VCSProvider is implemented by each supported code host
+type VCSProvider interface {
+	Name() string
+	FetchIssue(number string) (*Issue, error)
+}
+
+// Repository location parsed from a git remote
+type RemoteRepo struct {
+	Host string // e.g. "gitlab.com"
+	Path string // e.g. "group/subgroup/repo"
+}
+
+// Parse ssh ("git@host:owner/repo.git") and URL-style remotes
+func parseRemote(remote string) (*RemoteRepo, error) {
+	remote = strings.TrimSuffix(strings.TrimSpace(remote), ".git")
+
+	if !strings.Contains(remote, "://") {
+		// scp-like syntax: user@host:path
+		userHost, path, ok := strings.Cut(remote, ":")
+		if !ok {
+			return nil, fmt.Errorf("unrecognized remote %q", remote)
+		}
+		_, host, found := strings.Cut(userHost, "@")
+		if !found {
+			host = userHost
+		}
+		return &RemoteRepo{Host: host, Path: strings.Trim(path, "/")}, nil
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteRepo{Host: u.Hostname(), Path: strings.Trim(u.Path, "/")}, nil
+}
+
+// Environment variable holding the token for a host, e.g. VCS_TOKEN_GITLAB_EXAMPLE_COM
+func hostTokenVar(host string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(host) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return "VCS_TOKEN_" + b.String()
+}
+
+// Look up the per-host token, falling back to the provider's usual variable
+func hostToken(host, fallbackVar string) string {
+	if token := getEnv(hostTokenVar(host), ""); token != "" {
+		return token
+	}
+	return getEnv(fallbackVar, "")
+}
+
+// Determine the provider kind for a host. Self-hosted instances are
+// mapped with VCS_HOSTS, e.g. "git.example.com=gitlab,code.corp=github".
+func providerKind(host string) string {
+	for _, pair := range splitList(getEnv("VCS_HOSTS", "")) {
+		if h, kind, ok := strings.Cut(pair, "="); ok && strings.EqualFold(strings.TrimSpace(h), host) {
+			return strings.ToLower(strings.TrimSpace(kind))
+		}
+	}
+
+	switch {
+	case host == "github.com":
+		return "github"
+	case host == "bitbucket.org":
+		return "bitbucket"
+	case strings.Contains(host, "gitlab"):
+		return "gitlab"
+	}
+	return ""
+}
+
+// Detect the VCS provider from the origin remote of a workspace
+func detectVCSProvider(dir string) (VCSProvider, error) {
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("no git remote named origin")
+	}
+
+	repo, err := parseRemote(string(output))
+	if err != nil {
+		return nil, err
+	}
+	return newVCSProvider(repo)
+}
+
+// Create the provider for a repository
+func newVCSProvider(repo *RemoteRepo) (VCSProvider, error) {
+	switch providerKind(repo.Host) {
+	case "github":
+		return &GitHubProvider{repo: repo, token: hostToken(repo.Host, "GITHUB_TOKEN")}, nil
+	case "gitlab":
+		return &GitLabProvider{repo: repo, token: hostToken(repo.Host, "GITLAB_TOKEN")}, nil
+	case "bitbucket":
+		return &BitbucketProvider{repo: repo, token: hostToken(repo.Host, "BITBUCKET_TOKEN")}, nil
+	}
+	return nil, fmt.Errorf("unsupported code host %q (map it with VCS_HOSTS=%s=gitlab)", repo.Host, repo.Host)
+}
+
+// Perform an authenticated GET and decode the JSON response
+func vcsGet(endpoint string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("not found (check the number and your token's access)")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// bearer formats an Authorization header value
+func bearer(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "Bearer " + token
+}
+
+// GitHub (github.com and Enterprise Server)
+type GitHubProvider struct {
+	repo  *RemoteRepo
+	token string
+}
+
+func (p *GitHubProvider) Name() string {
+	return "GitHub"
+}
+
+func (p *GitHubProvider) apiBase() string {
+	if p.repo.Host == "github.com" {
+		return "https://api.github.com"
+	}
+	return "https://" + p.repo.Host + "/api/v3"
+}
+
+func (p *GitHubProvider) FetchIssue(number string) (*Issue, error) {
+	var result struct {
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		State   string `json:"state"`
+		HTMLURL string `json:"html_url"`
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/issues/%s", p.apiBase(), p.repo.Path, url.PathEscape(number))
+	if err := vcsGet(endpoint, map[string]string{"Authorization": bearer(p.token)}, &result); err != nil {
+		return nil, err
+	}
+	return &Issue{Number: number, Title: result.Title, Body: result.Body, State: result.State, URL: result.HTMLURL}, nil
+}
+
+// GitLab (gitlab.com and self-managed)
+type GitLabProvider struct {
+	repo  *RemoteRepo
+	token string
+}
+
+func (p *GitLabProvider) Name() string {
+	return "GitLab"
+}
+
+func (p *GitLabProvider) FetchIssue(number string) (*Issue, error) {
+	var result struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		State       string `json:"state"`
+		WebURL      string `json:"web_url"`
+	}
+	endpoint := fmt.Sprintf("https://%s/api/v4/projects/%s/issues/%s",
+		p.repo.Host, url.PathEscape(p.repo.Path), url.PathEscape(number))
+	if err := vcsGet(endpoint, map[string]string{"PRIVATE-TOKEN": p.token}, &result); err != nil {
+		return nil, err
+	}
+	return &Issue{Number: number, Title: result.Title, Body: result.Description, State: result.State, URL: result.WebURL}, nil
+}
+
+// Bitbucket Cloud
+type BitbucketProvider struct {
+	repo  *RemoteRepo
+	token string
+}
+
+func (p *BitbucketProvider) Name() string {
+	return "Bitbucket"
+}
+
+func (p *BitbucketProvider) FetchIssue(number string) (*Issue, error) {
+	var result struct {
+		Title   string `json:"title"`
+		State   string `json:"state"`
+		Content struct {
+			Raw string `json:"raw"`
+		} `json:"content"`
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}
+	endpoint := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/issues/%s", p.repo.Path, url.PathEscape(number))
+	if err := vcsGet(endpoint, map[string]string{"Authorization": bearer(p.token)}, &result); err != nil {
+		return nil, err
+	}
+	return &Issue{Number: number, Title: result.Title, Body: result.Content.Raw, State: result.State, URL: result.Links.HTML.Href}, nil
+}
+
+// Handle /issue <number> [instructions]
+func handleIssue(client *Client, args string) {
+	number, instructions, _ := strings.Cut(args, " ")
+	number = strings.TrimPrefix(number, "#")
+	if number == "" {
+		fmt.Println("Usage: /issue <number> [instructions]")
+		fmt.Println()
+		return
+	}
+
+	provider, err := detectVCSProvider(client.config.FileAccess.Root)
+	if err != nil {
+		fmt.Printf("❌ %v\n\n", err)
+		return
+	}
+
+	issue, err := provider.FetchIssue(number)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch %s issue #%s: %v\n\n", provider.Name(), number, err)
+		return
+	}
+
+	fmt.Printf("🎫 %s #%s: %s [%s]\n", provider.Name(), issue.Number, issue.Title, issue.State)
+	if issue.URL != "" {
+		fmt.Printf("   %s\n", issue.URL)
+	}
+	fmt.Println()
+
+	instructions = strings.TrimSpace(instructions)
+	if instructions == "" {
+		instructions = "Please review this issue and suggest how to address it."
+	}
+	body := fmt.Sprintf("%s issue #%s: %s\n\n%s", provider.Name(), issue.Number, issue.Title, issue.Body)
+	if !preflightAttachments(client, []Attachment{{Name: "issue #" + issue.Number, Text: body}}) {
+		return
+	}
+	handleMessage(client, body+"\n\n"+instructions)
+}
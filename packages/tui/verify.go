@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Tools that modify files in the workspace
+var fileWritingTools = map[string]bool{
+	"writeFile":     true,
+	"editFile":      true,
+	"apply_patch":   true,
+	"rename_symbol": true,
+}
+
+// Maximum tokens of verification output fed back to the agent
+const maxVerifyFeedback = 1500
+
+// Load verification settings from the environment (VERIFY=off opts out)
+func verifyConfig() (string, int) {
+	command := getEnv("VERIFY_COMMAND", "")
+	if strings.EqualFold(getEnv("VERIFY", "on"), "off") {
+		command = ""
+	}
+
+	iterations, err := strconv.Atoi(getEnv("VERIFY_ITERATIONS", "1"))
+	if err != nil || iterations < 0 {
+		iterations = 1
+	}
+	return command, iterations
+}
+
+// Check whether a turn wrote any files
+func wroteFiles(messages []Message) bool {
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			// A rename only writes when applied; its preview changes nothing
+			if fileWritingTools[call.Name] && (call.Name != "rename_symbol" || call.Parameters["apply"] == true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run a verification command through the platform shell
+func runVerifyCommand(command string) (bool, string) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = os.Environ()
+
+	output, err := cmd.CombinedOutput()
+	return err == nil, string(output)
+}
+
+// Verify the agent's edits, giving it a chance to fix failures
+func verifyEdits(client *Client, messages []Message) {
+	command := client.config.VerifyCommand
+	if command == "" || !wroteFiles(messages) {
+		return
+	}
+
+	tok := tokenizerForModel(client.config.Model)
+	for attempt := 0; ; attempt++ {
+		fmt.Printf("🔍 Verifying: %s\n", command)
+		ok, output := runVerifyCommand(command)
+		if ok {
+			fmt.Println("✅ Verification passed")
+			fmt.Println()
+			return
+		}
+
+		if attempt >= client.config.VerifyIterations {
+			fmt.Println(tailTokens(tok, output, maxVerifyFeedback))
+			fmt.Printf("❌ Verification still failing after %d corrective attempt(s)\n", attempt)
+			fmt.Println()
+			return
+		}
+
+		fmt.Println("⚠️  Verification failed, asking the AI to fix it...")
+		fmt.Println()
+		feedback := fmt.Sprintf("The verification command `%s` failed after your changes:\n\n```\n%s\n```\n\nPlease fix the problem.",
+			command, tailTokens(tok, output, maxVerifyFeedback))
+		if sendAndShow(client, feedback) == nil {
+			return
+		}
+	}
+}
+
+// Handle /verify [on|off|<command>]
+func configureVerify(client *Client, args string) {
+	switch strings.ToLower(args) {
+	case "":
+		if client.config.VerifyCommand == "" {
+			fmt.Println("🔍 Verification is off. Enable it with /verify <command>")
+		} else {
+			fmt.Printf("🔍 Verification command: %s (up to %d corrective attempt(s))\n",
+				client.config.VerifyCommand, client.config.VerifyIterations)
+		}
+	case "off":
+		client.config.VerifyCommand = ""
+		fmt.Println("🔍 Verification disabled")
+	case "on":
+		command := getEnv("VERIFY_COMMAND", "")
+		if command == "" {
+			fmt.Println("❌ No VERIFY_COMMAND configured. Use /verify <command>")
+			break
+		}
+		client.config.VerifyCommand = command
+		fmt.Printf("🔍 Verification enabled: %s\n", command)
+	default:
+		client.config.VerifyCommand = args
+		fmt.Printf("🔍 Verification enabled: %s\n", args)
+	}
+	fmt.Println()
+}
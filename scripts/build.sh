@@ -21,17 +21,17 @@ echo "🏗️  Building TUI client for all platforms..."
 # Linux builds
 echo "🐧 Building for Linux..."
 cd packages/tui
-GOOS=linux GOARCH=amd64 go build -ldflags='-s -w' -o ../../bin/painika-linux-amd64 ./main.go
-GOOS=linux GOARCH=arm64 go build -ldflags='-s -w' -o ../../bin/painika-linux-arm64 ./main.go
+GOOS=linux GOARCH=amd64 go build -ldflags='-s -w' -o ../../bin/painika-linux-amd64 .
+GOOS=linux GOARCH=arm64 go build -ldflags='-s -w' -o ../../bin/painika-linux-arm64 .
 
 # macOS builds
 echo "🍎 Building for macOS..."
-GOOS=darwin GOARCH=amd64 go build -ldflags='-s -w' -o ../../bin/painika-darwin-amd64 ./main.go
-GOOS=darwin GOARCH=arm64 go build -ldflags='-s -w' -o ../../bin/painika-darwin-arm64 ./main.go
+GOOS=darwin GOARCH=amd64 go build -ldflags='-s -w' -o ../../bin/painika-darwin-amd64 .
+GOOS=darwin GOARCH=arm64 go build -ldflags='-s -w' -o ../../bin/painika-darwin-arm64 .
 
 # Windows builds
 echo "🪟 Building for Windows..."
-GOOS=windows GOARCH=amd64 go build -ldflags='-s -w' -o ../../bin/painika-windows-amd64.exe ./main.go
+GOOS=windows GOARCH=amd64 go build -ldflags='-s -w' -o ../../bin/painika-windows-amd64.exe .
 
 cd ../..
 